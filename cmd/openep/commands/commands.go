@@ -15,7 +15,7 @@ import (
 	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/engine"
 	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
-	"github.com/openendpoint/openendpoint/internal/storage/flatfile"
+	"github.com/openendpoint/openendpoint/internal/storage/factory"
 	"github.com/spf13/cobra"
 )
 
@@ -479,7 +479,7 @@ func getEngine() (*engine.ObjectService, error) {
 		return nil, err
 	}
 
-	storage, err := flatfile.New(cfg.Storage.DataDir)
+	storage, err := factory.New(cfg.Storage)
 	if err != nil {
 		return nil, err
 	}
@@ -541,10 +541,14 @@ func runBucketInfo(bucket string) error {
 			fmt.Printf("Bucket: %s\n", b.Name)
 			fmt.Printf("Created: %s\n", time.Unix(b.CreationDate, 0).Format("2006-01-02 15:04:05"))
 
-			// List objects count
-			result, err := eng.ListObjects(context.Background(), bucket, engine.ListObjectsOptions{MaxKeys: 1000})
+			// Count objects without holding the listing in memory
+			var count int
+			err := eng.ListObjectsFunc(context.Background(), bucket, engine.ListObjectsOptions{}, func(engine.ObjectInfo) error {
+				count++
+				return nil
+			})
 			if err == nil {
-				fmt.Printf("Objects: %d\n", len(result.Objects))
+				fmt.Printf("Objects: %d\n", count)
 			}
 			return nil
 		}
@@ -647,7 +651,8 @@ func runObjectDelete(bucket, key string) error {
 	}
 	defer eng.Close()
 
-	return eng.DeleteObject(context.Background(), bucket, key, engine.DeleteObjectOptions{})
+	_, err = eng.DeleteObject(context.Background(), bucket, key, engine.DeleteObjectOptions{})
+	return err
 }
 
 func runObjectCopy(srcBucket, srcKey, dstBucket, dstKey string) error {
@@ -689,10 +694,10 @@ func runServerStats() (map[string]interface{}, error) {
 
 	var totalObjects int64
 	for _, b := range buckets {
-		result, err := eng.ListObjects(context.Background(), b.Name, engine.ListObjectsOptions{MaxKeys: 10000})
-		if err == nil {
-			totalObjects += int64(len(result.Objects))
-		}
+		_ = eng.ListObjectsFunc(context.Background(), b.Name, engine.ListObjectsOptions{}, func(engine.ObjectInfo) error {
+			totalObjects++
+			return nil
+		})
 	}
 	stats["object_count"] = totalObjects
 
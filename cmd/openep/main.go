@@ -3,23 +3,33 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/openendpoint/openendpoint/internal/api"
+	"github.com/openendpoint/openendpoint/internal/audit"
 	"github.com/openendpoint/openendpoint/internal/auth"
 	"github.com/openendpoint/openendpoint/internal/cluster"
 	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/dashboard"
 	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/events"
+	"github.com/openendpoint/openendpoint/internal/health"
 	"github.com/openendpoint/openendpoint/internal/lifecycle"
+	"github.com/openendpoint/openendpoint/internal/metadata"
 	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
 	"github.com/openendpoint/openendpoint/internal/mgmt"
 	"github.com/openendpoint/openendpoint/internal/middleware"
-	"github.com/openendpoint/openendpoint/internal/storage/flatfile"
+	"github.com/openendpoint/openendpoint/internal/ratelimit"
+	"github.com/openendpoint/openendpoint/internal/replication"
+	"github.com/openendpoint/openendpoint/internal/storage/factory"
 	"github.com/openendpoint/openendpoint/internal/telemetry"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -118,7 +128,7 @@ func runServer(cfgPath string) error {
 	)
 
 	// Initialize storage backend
-	storage, err := flatfile.New(cfg.Storage.DataDir)
+	storage, err := factory.New(cfg.Storage)
 	if err != nil {
 		logger.Error("failed to initialize storage backend", zap.Error(err))
 		return fmt.Errorf("failed to initialize storage: %w", err)
@@ -126,15 +136,88 @@ func runServer(cfgPath string) error {
 	defer storage.Close()
 
 	// Initialize metadata store
-	metadata, err := pebble.New(cfg.Storage.DataDir)
+	pebbleStore, err := pebble.New(cfg.Storage.DataDir)
 	if err != nil {
 		logger.Error("failed to initialize metadata store", zap.Error(err))
 		return fmt.Errorf("failed to initialize metadata: %w", err)
 	}
-	defer metadata.Close()
+	var metaStore metadata.Store = pebbleStore
+	if bulk := cfg.Storage.BulkIngest; bulk.Enabled {
+		// Batched writes sync once per batch; the last flush interval of
+		// writes is lost if the process dies
+		metaStore = metadata.NewBatchWriter(pebbleStore, bulk.BatchSize, time.Duration(bulk.FlushInterval)*time.Millisecond)
+		logger.Warn("bulk ingest enabled, metadata writes are batched",
+			zap.Int("batch_size", bulk.BatchSize),
+			zap.Int("flush_interval_ms", bulk.FlushInterval),
+		)
+	}
+	defer metaStore.Close()
 
 	// Initialize object engine
-	objEngine := engine.New(storage, metadata, logger)
+	objEngine := engine.New(storage, metaStore, logger)
+	objEngine.SetMaxRetention(time.Duration(cfg.Storage.MaxRetentionDays) * 24 * time.Hour)
+	objEngine.SetRestoreDelays(map[string]time.Duration{
+		engine.RestoreTierExpedited: time.Duration(cfg.Storage.RestoreDelay.Expedited) * time.Second,
+		engine.RestoreTierStandard:  time.Duration(cfg.Storage.RestoreDelay.Standard) * time.Second,
+		engine.RestoreTierBulk:      time.Duration(cfg.Storage.RestoreDelay.Bulk) * time.Second,
+	})
+	masterKey, err := cfg.Storage.MasterKey()
+	if err != nil {
+		return err
+	}
+	if masterKey != nil {
+		if err := objEngine.SetEncryptionKey(masterKey); err != nil {
+			return err
+		}
+	}
+
+	// Audit log for object changes
+	auditCfg := audit.DefaultLoggerConfig()
+	auditCfg.OutputPath = filepath.Join(cfg.Storage.DataDir, "audit.log")
+	auditLogger, err := audit.NewLogger(auditCfg, zapLogger)
+	if err != nil {
+		logger.Warnw("audit logging disabled", "error", err)
+	} else {
+		auditLogger.Start(context.Background())
+		defer auditLogger.Stop()
+	}
+
+	// The engine publishes each object change once; notifications,
+	// replication, access and audit logging consume it independently.
+	// Closing the bus drains queued events before the consumers shut down.
+	// The dispatcher is closed after the bus, so events the bus drains on
+	// shutdown are still delivered.
+	webhookPolicy, err := events.NewWebhookPolicy(cfg.Notifications.AllowedWebhookTargets, cfg.Notifications.DeniedWebhookTargets)
+	if err != nil {
+		return fmt.Errorf("failed to configure webhook targets: %w", err)
+	}
+	objEngine.SetWebhookPolicy(webhookPolicy)
+	dispatcher := events.NewDispatcher(objEngine, logger)
+	dispatcher.SetWebhookPolicy(webhookPolicy)
+	defer dispatcher.Close()
+	eventBus := events.NewBus()
+	defer eventBus.Close()
+	objEngine.SetEventBus(eventBus)
+
+	eventBus.Subscribe("notifications", dispatcher.HandleObjectEvent)
+	// Object events go to the access log at the same sampling rate as
+	// successful requests
+	accessLogSampler := telemetry.NewLogSampler(cfg.Logging.AccessLogSampleRate,
+		time.Duration(cfg.Logging.SlowRequestThreshold)*time.Millisecond)
+	eventBus.Subscribe("access-log", func(e events.ObjectEvent) {
+		if !accessLogSampler.Keep(http.StatusOK, 0) {
+			return
+		}
+		logger.Infow("object event",
+			"event", e.Type,
+			"bucket", e.Bucket,
+			"key", e.Key,
+			"size", e.Size,
+			"versionId", e.VersionID)
+	})
+	if auditLogger != nil {
+		eventBus.Subscribe("audit", auditLogger.HandleObjectEvent)
+	}
 
 	// Initialize storage metrics from existing data
 	if bytes, objects, err := objEngine.ComputeStorageMetrics(); err == nil {
@@ -144,9 +227,30 @@ func runServer(cfgPath string) error {
 			zap.Int64("bytes", bytes),
 			zap.Int64("objects", objects))
 	}
+	// Per-bucket usage is rescanned once so the bucket metrics start out
+	// right and every bucket is tracked from here on
+	if err := objEngine.ReconcileBucketUsage(context.Background()); err != nil {
+		logger.Warnw("failed to reconcile bucket usage", "error", err)
+	}
 
 	// Initialize auth service
 	authService := auth.New(cfg.Auth)
+	authService.SetClientCertIdentities(cfg.TLS.ClientIdentities, cfg.TLS.RequireSignature)
+
+	// Presigned URLs are signed with the requesting caller's credentials and point
+	// at this server's S3 API
+	presignHost := cfg.Server.Host
+	if presignHost == "" || presignHost == "0.0.0.0" {
+		presignHost = "localhost"
+	}
+	presignScheme := "http"
+	if cfg.TLS.Enabled {
+		presignScheme = "https"
+	}
+	presignEndpoint := fmt.Sprintf("%s://%s/s3", presignScheme, net.JoinHostPort(presignHost, strconv.Itoa(cfg.Server.Port)))
+	if err := objEngine.SetPresigner(authService, presignEndpoint); err != nil {
+		logger.Warnw("presigned URLs disabled", "error", err)
+	}
 
 	// Initialize cluster (if enabled)
 	var clusterService *cluster.Cluster
@@ -169,17 +273,48 @@ func runServer(cfgPath string) error {
 		}
 	}
 
+	// Background workers share intervals that can be changed at runtime
+	// through the management settings endpoint
+	workerIntervals := config.NewWorkerIntervals(cfg.Workers)
+
 	// Initialize lifecycle processor (if enabled)
 	var lifecycleProcessor *lifecycle.Processor
 	lifecycleProcessor = lifecycle.NewProcessor(objEngine, 1*time.Hour)
-	go lifecycleProcessor.Start()
+	lifecycleProcessor.SetIntervals(workerIntervals)
+	lifecycleProcessor.Start()
 	defer lifecycleProcessor.Stop()
 
+	// Abort stale multipart uploads and reclaim part files left behind by
+	// aborted or crashed ones
+	uploadMaxAge := time.Duration(cfg.Workers.MultipartUploadMaxAge) * time.Second
+	vacuumer := engine.NewVacuumer(objEngine, 6*time.Hour, engine.DefaultVacuumMinAge)
+	vacuumer.SetIntervals(workerIntervals)
+	vacuumer.SetUploadMaxAge(uploadMaxAge)
+	vacuumer.Start()
+	defer vacuumer.Stop()
+
 	// Initialize S3 API router with all dependencies
 	s3Router := api.NewRouter(objEngine, authService, logger, cfg)
 
 	// Initialize management API router with cluster info
 	mgmtRouter := mgmt.NewRouter(objEngine, logger, cfg, clusterService, cfg.Storage.DataDir)
+	mgmtRouter.SetWorkerIntervals(workerIntervals)
+	mgmtRouter.SetEventBus(eventBus)
+	mgmtRouter.SetAuth(authService)
+	mgmtRouter.SetMultipartUploadMaxAge(uploadMaxAge)
+	// IAM users, groups and policies live in the metadata store. Their
+	// access keys sign S3 requests, which are limited to their policies.
+	if err := mgmtRouter.IAM().SetStore(context.Background(), metaStore); err != nil {
+		return fmt.Errorf("failed to load IAM state: %w", err)
+	}
+	mgmtRouter.IAM().SetCredentialRegistry(authService)
+	authService.SetPolicyChecker(mgmtRouter.IAM())
+	// Replication applies queued changes to each rule's destination bucket
+	replicationSvc := mgmtRouter.Replication()
+	replication.NewReplicator(objEngine).Attach(replicationSvc)
+	replicationSvc.Start(context.Background())
+	defer replicationSvc.Stop()
+	eventBus.Subscribe("replication", replicationSvc.HandleObjectEvent)
 
 	// Create dashboard wrapper that adapts cluster.Cluster to dashboard interface
 	var dashboardCluster interface {
@@ -193,11 +328,48 @@ func runServer(cfgPath string) error {
 	// Setup HTTP server
 	mux := http.NewServeMux()
 
-	// S3 API endpoints
-	mux.Handle("/s3/", s3Router)
+	// S3 API endpoints, and static websites of buckets with a website
+	// configuration, served anonymously outside the S3 API. Both are
+	// access logged, rate limited and compressed alike.
+	var s3Handler http.Handler = s3Router
+	var websiteHandler http.Handler = http.HandlerFunc(s3Router.ServeWebsite)
+	// Requests to buckets with a logging configuration are written to
+	// their target buckets as server access logs
+	if cfg.Logging.ServerAccessLogs {
+		accessLogger := api.NewAccessLogger(objEngine, logger,
+			time.Duration(cfg.Logging.ServerAccessLogFlushInterval)*time.Second)
+		accessLogger.Start()
+		defer accessLogger.Stop()
+		s3Handler = accessLogger.Middleware(s3Handler)
+		websiteHandler = accessLogger.Middleware(websiteHandler)
+	}
+	if cfg.RateLimit.Enabled {
+		limiter, err := newRateLimiter(cfg.RateLimit)
+		if err != nil {
+			return fmt.Errorf("failed to configure rate limiting: %w", err)
+		}
+		defer limiter.Stop()
+		s3Handler = limiter.Middleware(s3Handler)
+		websiteHandler = limiter.Middleware(websiteHandler)
+	}
+	// Verify credentials once per request; the limiter and handlers reuse
+	// the result from the request context
+	s3Handler = authService.Middleware(s3Handler)
+
+	// Listings and other XML/JSON responses are gzipped for clients that
+	// accept it
+	var mgmtHandler http.Handler = mgmtRouter
+	if cfg.Server.CompressResponses {
+		compress := middleware.CompressResponses(cfg.Server.CompressMinSize)
+		s3Handler = compress(s3Handler)
+		websiteHandler = compress(websiteHandler)
+		mgmtHandler = compress(mgmtHandler)
+	}
+	mux.Handle("/s3/", s3Handler)
+	mux.Handle(api.WebsitePathPrefix, websiteHandler)
 
 	// Management API endpoints
-	mux.Handle("/_mgmt/", mgmtRouter)
+	mux.Handle("/_mgmt/", mgmtHandler)
 
 	// Web Dashboard
 	mux.Handle("/_dashboard/", dashboard.Handler(dashboardCluster))
@@ -208,53 +380,57 @@ func runServer(cfgPath string) error {
 		w.Write([]byte("OK"))
 	})
 
-	// Readiness check
-	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("READY"))
-	})
+	// Readiness check, failing while the metadata store cannot take writes
+	readyChecker := health.NewReadyChecker()
+	readyChecker.RegisterCheck(objEngine.MetadataWritable)
+	mux.HandleFunc("/ready", readyChecker.HTTPHandler())
 
-	// Prometheus metrics - register all telemetry metrics
-	reg := prometheus.NewRegistry()
-	reg.MustRegister(telemetry.RequestsTotal)
-	reg.MustRegister(telemetry.RequestDuration)
-	reg.MustRegister(telemetry.BytesUploaded)
-	reg.MustRegister(telemetry.BytesDownloaded)
-	// Storage metrics
-	reg.MustRegister(telemetry.StorageBytesStored)
-	reg.MustRegister(telemetry.StorageObjectsTotal)
-	reg.MustRegister(telemetry.StorageBucketsTotal)
-	reg.MustRegister(telemetry.StorageDiskUsagePercent)
-	// Operation metrics
-	reg.MustRegister(telemetry.OperationDuration)
-	reg.MustRegister(telemetry.OperationsTotal)
-	// Request metrics
-	reg.MustRegister(telemetry.RequestsFailedTotal)
-	reg.MustRegister(telemetry.RequestSizeBytes)
-	reg.MustRegister(telemetry.ResponseSizeBytes)
-	// Bucket metrics
-	reg.MustRegister(telemetry.BucketObjects)
-	reg.MustRegister(telemetry.BucketBytes)
-
-	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	// Prometheus metrics
+	mux.Handle("/metrics", metricsHandler())
 
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 
-	// Apply CORS middleware for WebUI access
-	corsHandler := middleware.CORS([]string{"*"})(mux)
+	// Apply CORS middleware for WebUI access. The S3 API answers CORS from
+	// each bucket's own configuration instead.
+	webCORS := middleware.CORS([]string{"*"})(mux)
+	corsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/s3/") {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		webCORS.ServeHTTP(w, r)
+	})
 
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      telemetry.LoggingMiddleware(logger)(corsHandler),
+		Handler:      telemetry.SampledLoggingMiddleware(logger, accessLogSampler)(corsHandler),
 		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
 	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := auth.ServerTLSConfig(cfg.TLS)
+		if err != nil {
+			logger.Error("failed to configure TLS", zap.Error(err))
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		server.TLSConfig = tlsConfig
+	}
 
 	// Start server in goroutine
 	go func() {
-		logger.Info("server listening", zap.String("address", addr))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("server listening",
+			zap.String("address", addr),
+			zap.Bool("tls", cfg.TLS.Enabled),
+			zap.Bool("client_certs", cfg.TLS.ClientCAFile != ""))
+		var err error
+		if cfg.TLS.Enabled {
+			// Certificates are already loaded into server.TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("server error", zap.Error(err))
 		}
 	}()
@@ -291,3 +467,67 @@ func runServer(cfgPath string) error {
 	logger.Info("server exited")
 	return nil
 }
+
+// metricsHandler serves the Prometheus metrics of the telemetry package and
+// the S3 API
+func metricsHandler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(telemetry.RequestsTotal)
+	reg.MustRegister(telemetry.RequestDuration)
+	reg.MustRegister(telemetry.BytesUploaded)
+	reg.MustRegister(telemetry.BytesDownloaded)
+	// Storage metrics
+	reg.MustRegister(telemetry.StorageBytesStored)
+	reg.MustRegister(telemetry.StorageObjectsTotal)
+	reg.MustRegister(telemetry.StorageBucketsTotal)
+	reg.MustRegister(telemetry.StorageDiskUsagePercent)
+	// Operation metrics
+	reg.MustRegister(telemetry.OperationDuration)
+	reg.MustRegister(telemetry.OperationsTotal)
+	// Request metrics
+	reg.MustRegister(telemetry.RequestsFailedTotal)
+	reg.MustRegister(telemetry.RequestSizeBytes)
+	reg.MustRegister(telemetry.ResponseSizeBytes)
+	// Bucket metrics
+	reg.MustRegister(telemetry.BucketObjects)
+	reg.MustRegister(telemetry.BucketBytes)
+	// Vacuum metrics
+	reg.MustRegister(telemetry.VacuumPartsReclaimed)
+	reg.MustRegister(telemetry.VacuumBytesReclaimed)
+	// S3 API metrics
+	reg.MustRegister(api.Collectors()...)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// newRateLimiter builds the S3 rate limiter with separate anonymous and
+// authenticated limits. It must run behind the auth middleware: a request
+// only counts as authenticated when its signature or client certificate was
+// verified there, so forged access keys fall under the anonymous limit.
+// Presigned URLs are shared links and are limited as anonymous traffic.
+func newRateLimiter(cfg config.RateLimitConfig) (*ratelimit.ClassLimiter, error) {
+	rate, burst := cfg.Rate, cfg.Burst
+	if rate <= 0 {
+		rate = ratelimit.DefaultRate
+	}
+	if burst <= 0 {
+		burst = ratelimit.DefaultBurst
+	}
+	anonRate, anonBurst := cfg.AnonymousRate, cfg.AnonymousBurst
+	if anonRate <= 0 {
+		anonRate = rate
+	}
+	if anonBurst <= 0 {
+		anonBurst = burst
+	}
+
+	limiter := ratelimit.NewClassLimiter(
+		ratelimit.NewBucketLimiter(float64(anonBurst), float64(anonRate)),
+		ratelimit.NewBucketLimiter(float64(burst), float64(rate)),
+		auth.AuthenticatedAccessKey,
+	)
+	if err := limiter.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		limiter.Stop()
+		return nil, err
+	}
+	return limiter, nil
+}
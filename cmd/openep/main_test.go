@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/openendpoint/openendpoint/internal/api"
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"github.com/openendpoint/openendpoint/internal/storage/flatfile"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 func TestVersionCmd(t *testing.T) {
@@ -596,3 +607,81 @@ func TestCommandSuggestions(t *testing.T) {
 	}
 }
 
+
+func TestMetricsHandler_S3Requests(t *testing.T) {
+	router, _ := newMetricsTestRouter(t, &config.Config{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/s3/missing-bucket", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GET missing bucket status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	if !scrapeHas(t, "openendpoint_s3_requests_total{", `code="NoSuchBucket"`, `status="404"`) {
+		t.Error("/metrics has no openendpoint_s3_requests_total sample for the NoSuchBucket failure")
+	}
+}
+
+func TestMetricsHandler_S3RequestBucketAndDuration(t *testing.T) {
+	router, objEngine := newMetricsTestRouter(t, &config.Config{Metrics: config.MetricsConfig{BucketLabel: true}})
+	if err := objEngine.CreateBucket(context.Background(), "scraped-bucket"); err != nil {
+		t.Fatalf("CreateBucket() error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/s3/scraped-bucket", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("HEAD bucket status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if !scrapeHas(t, "openendpoint_s3_requests_total{", `bucket="scraped-bucket"`, `operation="HeadBucket"`, `status="200"`) {
+		t.Error("/metrics has no openendpoint_s3_requests_total sample with the bucket and status labels")
+	}
+	if !scrapeHas(t, "openendpoint_s3_request_duration_seconds_count{", `operation="HeadBucket"`) {
+		t.Error("/metrics has no openendpoint_s3_request_duration_seconds sample for HeadBucket")
+	}
+}
+
+// newMetricsTestRouter creates an S3 API router without authentication over
+// a flat file backend and pebble store in a temporary directory
+func newMetricsTestRouter(t *testing.T, cfg *config.Config) (*api.Router, *engine.ObjectService) {
+	t.Helper()
+	dir := t.TempDir()
+	storage, err := flatfile.New(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	store, err := pebble.New(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	logger := zap.NewNop().Sugar()
+	objEngine := engine.New(storage, store, logger)
+	return api.NewRouter(objEngine, auth.New(config.AuthConfig{}), logger, cfg), objEngine
+}
+
+// scrapeHas reports whether the /metrics handler serves a sample starting
+// with prefix and containing every label
+func scrapeHas(t *testing.T, prefix string, labels ...string) bool {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d", w.Code)
+	}
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		found := true
+		for _, label := range labels {
+			found = found && strings.Contains(line, label)
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
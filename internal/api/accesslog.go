@@ -0,0 +1,316 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"go.uber.org/zap"
+)
+
+// DefaultAccessLogFlushInterval is how often buffered server access logs
+// are written to their target buckets when no interval is configured
+const DefaultAccessLogFlushInterval = 5 * time.Minute
+
+// maxAccessLogBatchSize is the size a target's buffered log lines may reach
+// before they are flushed ahead of the interval
+const maxAccessLogBatchSize = 1 << 20
+
+// accessLogTimeFormat is the request time format of S3 server access logs
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogSubresources are the query parameters that name the resource of
+// a request in its log operation, as in REST.GET.ACL, in precedence order
+var accessLogSubresources = []struct {
+	param    string
+	resource string
+}{
+	{"uploadId", "UPLOAD"},
+	{"uploads", "UPLOADS"},
+	{"acl", "ACL"},
+	{"tagging", "TAGGING"},
+	{"retention", "RETENTION"},
+	{"legal-hold", "LEGAL_HOLD"},
+	{"versioning", "VERSIONING"},
+	{"versions", "VERSIONS"},
+	{"cors", "CORS"},
+	{"lifecycle", "LIFECYCLE"},
+	{"policy", "BUCKETPOLICY"},
+	{"logging", "LOGGING_STATUS"},
+	{"website", "WEBSITE"},
+	{"encryption", "ENCRYPTION"},
+	{"replication", "REPLICATION"},
+	{"notification", "NOTIFICATION"},
+	{"object-lock", "OBJECT_LOCK_CONFIGURATION"},
+	{"location", "LOCATION"},
+	{"delete", "MULTI_OBJECT_DELETE"},
+}
+
+// AccessLogger writes S3 server access logs. Requests to a bucket whose
+// logging configuration is enabled are recorded in the S3 server access log
+// format, buffered per target bucket and prefix, and written periodically
+// as log objects into the target bucket. Requests to other buckets are
+// served without being recorded.
+type AccessLogger struct {
+	engine   *engine.ObjectService
+	logger   *zap.SugaredLogger
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[accessLogTarget]*bytes.Buffer
+
+	flushCh  chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// accessLogTarget is where a bucket's access logs are written, and the
+// owner of the bucket they are for
+type accessLogTarget struct {
+	bucket string
+	prefix string
+	owner  string
+}
+
+// NewAccessLogger creates an access logger flushing every interval, or
+// every DefaultAccessLogFlushInterval when interval is not positive
+func NewAccessLogger(engine *engine.ObjectService, logger *zap.SugaredLogger, interval time.Duration) *AccessLogger {
+	if interval <= 0 {
+		interval = DefaultAccessLogFlushInterval
+	}
+	return &AccessLogger{
+		engine:   engine,
+		logger:   logger,
+		interval: interval,
+		pending:  make(map[accessLogTarget]*bytes.Buffer),
+		flushCh:  make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start starts the flush loop
+func (l *AccessLogger) Start() {
+	l.wg.Add(1)
+	go l.run()
+	l.logger.Infow("server access logging started", "interval", l.interval)
+}
+
+// Stop stops the flush loop and writes the logs still buffered
+func (l *AccessLogger) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopCh)
+		l.wg.Wait()
+	})
+}
+
+// run flushes the buffered logs every interval, when a batch grows past
+// maxAccessLogBatchSize, and once more on Stop
+func (l *AccessLogger) run() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.flushCh:
+		case <-l.stopCh:
+			l.Flush(context.Background())
+			return
+		}
+		l.Flush(context.Background())
+	}
+}
+
+// Flush writes the buffered log lines of each target as one log object,
+// named TargetPrefix followed by the flush time and a unique suffix. A
+// batch that cannot be written, or whose target is no longer owned by the
+// owner of the logged bucket, is dropped.
+func (l *AccessLogger) Flush(ctx context.Context) {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = make(map[accessLogTarget]*bytes.Buffer)
+	l.mu.Unlock()
+
+	for target, buf := range pending {
+		if owner, err := bucketOwner(ctx, l.engine, target.bucket); err != nil || owner != target.owner {
+			l.logger.Warnw("dropping server access logs for a target not owned by the source bucket's owner", "bucket", target.bucket, "error", err)
+			continue
+		}
+		unique := strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:16])
+		key := target.prefix + time.Now().UTC().Format("2006-01-02-15-04-05") + "-" + unique
+		if _, err := l.engine.PutObject(ctx, target.bucket, key, bytes.NewReader(buf.Bytes()), engine.PutObjectOptions{ContentType: "text/plain"}); err != nil {
+			l.logger.Warnw("failed to write server access log", "bucket", target.bucket, "key", key, "error", err)
+		}
+	}
+}
+
+// Middleware records the requests next serves for buckets with logging
+// enabled, for the S3 API and for ServeWebsite alike. It must wrap the
+// router directly, inside auth.Middleware, so it sees the authenticated
+// requester and the error code of failed requests.
+func (l *AccessLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var bucket, key string
+		if path, ok := strings.CutPrefix(req.URL.Path, WebsitePathPrefix); ok {
+			bucket, key, _ = strings.Cut(path, "/")
+		} else {
+			bucket, key, _ = parseBucketKey(req, req.URL.Path)
+		}
+		if bucket == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		// Handlers may rewrite the path, so the request line is taken first
+		requestURI := req.RequestURI
+		if requestURI == "" {
+			requestURI = req.URL.RequestURI()
+		}
+
+		start := time.Now()
+		rec := &accessLogRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+		l.record(req, bucket, key, requestURI, rec, start)
+	})
+}
+
+// record buffers the log line of a served request, if its bucket has
+// logging enabled
+func (l *AccessLogger) record(req *http.Request, bucket, key, requestURI string, rec *accessLogRecorder, start time.Time) {
+	totalTime := time.Since(start)
+	ctx := context.WithoutCancel(req.Context())
+
+	config, err := l.engine.GetBucketLogging(ctx, bucket)
+	if err != nil || config == nil || !config.LoggingEnabled || config.TargetBucket == "" {
+		return
+	}
+
+	owner, err := bucketOwner(ctx, l.engine, bucket)
+	if err != nil {
+		owner = defaultBucketOwner
+	}
+	requester, ok := auth.AuthenticatedAccessKey(req)
+	if !ok {
+		requester = auth.RequestAccessKey(req)
+	}
+	remoteIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteIP = req.RemoteAddr
+	}
+	objectSize := ""
+	if req.Method == http.MethodPut && req.ContentLength >= 0 {
+		objectSize = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	line := fmt.Sprintf("%s %s [%s] %s %s %s %s %s \"%s %s %s\" %d %s %d %s %d %s \"%s\" \"%s\" %s\n",
+		owner,
+		bucket,
+		start.UTC().Format(accessLogTimeFormat),
+		accessLogField(remoteIP),
+		accessLogField(requester),
+		accessLogField(rec.Header().Get("x-amz-request-id")),
+		accessLogOperation(req, key),
+		accessLogField(key),
+		req.Method, requestURI, req.Proto,
+		rec.status,
+		accessLogField(rec.errorCode),
+		rec.bytes,
+		accessLogField(objectSize),
+		totalTime.Milliseconds(),
+		"-",
+		accessLogField(req.Referer()),
+		accessLogField(req.UserAgent()),
+		accessLogField(rec.Header().Get("x-amz-version-id")))
+
+	target := accessLogTarget{bucket: config.TargetBucket, prefix: config.TargetPrefix, owner: owner}
+	l.mu.Lock()
+	buf, ok := l.pending[target]
+	if !ok {
+		buf = &bytes.Buffer{}
+		l.pending[target] = buf
+	}
+	buf.WriteString(line)
+	full := buf.Len() >= maxAccessLogBatchSize
+	l.mu.Unlock()
+
+	if full {
+		select {
+		case l.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// accessLogOperation names the operation of a request as S3 server access
+// logs do: REST, the method, and the resource it addresses
+func accessLogOperation(req *http.Request, key string) string {
+	resource := "BUCKET"
+	if key != "" {
+		resource = "OBJECT"
+	}
+	query := req.URL.Query()
+	for _, sub := range accessLogSubresources {
+		if query.Has(sub.param) {
+			resource = sub.resource
+			break
+		}
+	}
+	return "REST." + req.Method + "." + resource
+}
+
+// accessLogField returns a log field, or "-" for an empty one
+func accessLogField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// accessLogRecorder captures the status, error code and body size of a
+// response for its access log line
+type accessLogRecorder struct {
+	http.ResponseWriter
+	status      int
+	errorCode   string
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter
+func (a *accessLogRecorder) WriteHeader(status int) {
+	if !a.wroteHeader {
+		a.status = status
+		a.wroteHeader = true
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (a *accessLogRecorder) Write(b []byte) (int, error) {
+	a.wroteHeader = true
+	n, err := a.ResponseWriter.Write(b)
+	a.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (a *accessLogRecorder) Flush() {
+	if f, ok := a.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (a *accessLogRecorder) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestAccessLogger(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	svc := engine.New(NewMockAPIStorage(), NewMockAPIMetadata(), logger)
+	router := NewRouter(svc, auth.New(config.AuthConfig{}), logger, &config.Config{})
+	ctx := context.Background()
+	for _, bucket := range []string{"logged", "quiet", "logs"} {
+		svc.CreateBucket(ctx, bucket)
+	}
+	svc.PutBucketLogging(ctx, "logged", &metadata.LoggingConfiguration{
+		LoggingEnabled: true,
+		TargetBucket:   "logs",
+		TargetPrefix:   "access/",
+	})
+
+	accessLogger := NewAccessLogger(svc, logger, 0)
+	handler := accessLogger.Middleware(router)
+	serve := func(method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("User-Agent", "test-agent")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve(http.MethodPut, "/s3/logged/photo.jpg", "hello")
+	serve(http.MethodGet, "/s3/logged/missing.jpg", "")
+	serve(http.MethodPut, "/s3/quiet/photo.jpg", "hello")
+	// Website requests are logged for their bucket too
+	website := accessLogger.Middleware(http.HandlerFunc(router.ServeWebsite))
+	website.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/website/logged/index.html", nil))
+	accessLogger.Flush(ctx)
+
+	objects, err := svc.ListObjects(ctx, "logs", engine.ListObjectsOptions{Prefix: "access/"})
+	if err != nil {
+		t.Fatalf("ListObjects failed: %v", err)
+	}
+	if len(objects.Objects) != 1 {
+		t.Fatalf("got %d log objects, want 1", len(objects.Objects))
+	}
+	result, err := svc.GetObject(ctx, "logs", objects.Objects[0].Key, engine.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject failed: %v", err)
+	}
+	data, _ := io.ReadAll(result.Body)
+	result.Body.Close()
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d log lines, want 3:\n%s", len(lines), data)
+	}
+	for _, want := range []string{"root logged [", " REST.PUT.OBJECT photo.jpg ", `"PUT /s3/logged/photo.jpg HTTP/1.1" 200 - 0 5 `, `"test-agent"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("line %q does not contain %q", lines[0], want)
+		}
+	}
+	for _, want := range []string{" REST.GET.OBJECT missing.jpg ", " 404 NoSuchKey "} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("line %q does not contain %q", lines[1], want)
+		}
+	}
+	for _, want := range []string{" REST.GET.OBJECT index.html ", `"GET /website/logged/index.html HTTP/1.1" 404 `} {
+		if !strings.Contains(lines[2], want) {
+			t.Errorf("line %q does not contain %q", lines[2], want)
+		}
+	}
+
+	// Nothing is left to write after a flush
+	accessLogger.Flush(ctx)
+	objects, _ = svc.ListObjects(ctx, "logs", engine.ListObjectsOptions{Prefix: "access/"})
+	if len(objects.Objects) != 1 {
+		t.Errorf("got %d log objects after an empty flush, want 1", len(objects.Objects))
+	}
+}
+
+func TestAccessLoggerTargetOwner(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	meta := NewMockAPIMetadata()
+	svc := engine.New(NewMockAPIStorage(), meta, logger)
+	router := NewRouter(svc, auth.New(config.AuthConfig{}), logger, &config.Config{})
+	ctx := context.Background()
+	for _, bucket := range []string{"logged", "logs", "theirs"} {
+		svc.CreateBucket(ctx, bucket)
+	}
+	meta.buckets["theirs"].Owner = "someone-else"
+
+	putLogging := func(target string) *httptest.ResponseRecorder {
+		body := `<BucketLoggingStatus><LoggingEnabled>true</LoggingEnabled><TargetBucket>` + target + `</TargetBucket></BucketLoggingStatus>`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/s3/logged?logging=true", strings.NewReader(body)))
+		return w
+	}
+	for _, target := range []string{"missing", "theirs"} {
+		if w := putLogging(target); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "InvalidTargetBucketForLogging") {
+			t.Errorf("PutBucketLogging(%s) = %d %s, want 400 InvalidTargetBucketForLogging", target, w.Code, w.Body.String())
+		}
+	}
+	if w := putLogging("logs"); w.Code != http.StatusOK {
+		t.Fatalf("PutBucketLogging(logs) = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	// Logs buffered for a target that changed hands are not written to it
+	accessLogger := NewAccessLogger(svc, logger, 0)
+	accessLogger.Middleware(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/s3/logged/key", nil))
+	meta.buckets["logs"].Owner = "someone-else"
+	accessLogger.Flush(ctx)
+	objects, err := svc.ListObjects(ctx, "logs", engine.ListObjectsOptions{})
+	if err != nil || len(objects.Objects) != 0 {
+		t.Errorf("target owned by someone else got %d log objects, %v, want none", len(objects.Objects), err)
+	}
+}
+
+func TestAccessLogOperation(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		key    string
+		want   string
+	}{
+		{http.MethodGet, "/s3/bucket/key", "key", "REST.GET.OBJECT"},
+		{http.MethodGet, "/s3/bucket", "", "REST.GET.BUCKET"},
+		{http.MethodPut, "/s3/bucket?versioning", "", "REST.PUT.VERSIONING"},
+		{http.MethodGet, "/s3/bucket/key?acl", "key", "REST.GET.ACL"},
+		{http.MethodPost, "/s3/bucket/key?uploads", "key", "REST.POST.UPLOADS"},
+		{http.MethodPut, "/s3/bucket/key?partNumber=1&uploadId=abc", "key", "REST.PUT.UPLOAD"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := accessLogOperation(req, tt.key); got != tt.want {
+			t.Errorf("accessLogOperation(%s %s) = %s, want %s", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// xsiNamespace qualifies the type attribute of an ACL grantee
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// aclGrantHeaders maps the x-amz-grant-* headers to the permission they grant
+var aclGrantHeaders = map[string]string{
+	"x-amz-grant-read":         engine.PermissionRead,
+	"x-amz-grant-write":        engine.PermissionWrite,
+	"x-amz-grant-read-acp":     engine.PermissionReadACP,
+	"x-amz-grant-write-acp":    engine.PermissionWriteACP,
+	"x-amz-grant-full-control": engine.PermissionFullControl,
+}
+
+// accessControlPolicy is an AccessControlPolicy document as sent to PUT ?acl.
+// The grantee type is the xsi:type attribute, matched by its local name.
+type accessControlPolicy struct {
+	Owner struct {
+		ID          string `xml:"ID"`
+		DisplayName string `xml:"DisplayName"`
+	} `xml:"Owner"`
+	Grants []struct {
+		Grantee struct {
+			Type         string `xml:"type,attr"`
+			ID           string `xml:"ID"`
+			DisplayName  string `xml:"DisplayName"`
+			EmailAddress string `xml:"EmailAddress"`
+			URI          string `xml:"URI"`
+		} `xml:"Grantee"`
+		Permission string `xml:"Permission"`
+	} `xml:"AccessControlList>Grant"`
+}
+
+// aclResult is the AccessControlPolicy document returned by GET ?acl
+type aclResult struct {
+	XMLName xml.Name         `xml:"AccessControlPolicy"`
+	Xmlns   string           `xml:"xmlns,attr"`
+	Owner   aclResultOwner   `xml:"Owner"`
+	Grants  []aclResultGrant `xml:"AccessControlList>Grant"`
+}
+
+type aclResultOwner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+}
+
+type aclResultGrant struct {
+	Grantee    aclResultGrantee `xml:"Grantee"`
+	Permission string           `xml:"Permission"`
+}
+
+// aclResultGrantee writes the xsi prefix out literally, as S3 clients
+// expect it
+type aclResultGrantee struct {
+	XSI          string `xml:"xmlns:xsi,attr"`
+	Type         string `xml:"xsi:type,attr"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+}
+
+// newACLResult converts a stored ACL to its GET ?acl response
+func newACLResult(acl *metadata.AccessControlList) aclResult {
+	result := aclResult{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Owner: aclResultOwner{ID: acl.Owner.ID, DisplayName: acl.Owner.DisplayName},
+	}
+	for _, grant := range acl.Grants {
+		result.Grants = append(result.Grants, aclResultGrant{
+			Grantee: aclResultGrantee{
+				XSI:          xsiNamespace,
+				Type:         grant.Grantee.Type,
+				ID:           grant.Grantee.ID,
+				DisplayName:  grant.Grantee.DisplayName,
+				EmailAddress: grant.Grantee.EmailAddress,
+				URI:          grant.Grantee.URI,
+			},
+			Permission: grant.Permission,
+		})
+	}
+	return result
+}
+
+// requestACL builds the ACL a request sets, through the x-amz-acl canned
+// ACL, x-amz-grant-* headers or an AccessControlPolicy body. It returns nil
+// when the request sets none. The grants are checked by the engine when the
+// ACL is stored.
+func requestACL(header http.Header, body []byte) (*metadata.AccessControlList, S3Error) {
+	canned := header.Get("x-amz-acl")
+	granted := false
+	for name := range aclGrantHeaders {
+		granted = granted || header.Get(name) != ""
+	}
+
+	switch {
+	case canned != "" && granted:
+		return nil, withMessage(ErrInvalidRequest, "Specifying both Canned ACLs and Header Grants is not allowed")
+	case canned != "":
+		acl, err := engine.CannedACL(canned)
+		if err != nil {
+			return nil, withMessage(ErrInvalidArgument, "The canned ACL "+canned+" is not supported")
+		}
+		return acl, nil
+	case granted:
+		return grantHeaderACL(header)
+	case len(body) > 0:
+		return policyACL(body)
+	}
+	return nil, nil
+}
+
+// grantHeaderACL builds an ACL from the x-amz-grant-* headers. Each lists
+// its grantees as type="value" pairs, e.g. id="...", uri="..." or
+// emailAddress="...".
+func grantHeaderACL(header http.Header) (*metadata.AccessControlList, S3Error) {
+	acl := &metadata.AccessControlList{Owner: engine.Owner}
+	for name, permission := range aclGrantHeaders {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		for _, grantee := range strings.Split(value, ",") {
+			kind, value, ok := strings.Cut(strings.TrimSpace(grantee), "=")
+			value = strings.Trim(value, `"`)
+			var g metadata.ACLGrantee
+			switch {
+			case ok && strings.EqualFold(kind, "id"):
+				g = metadata.ACLGrantee{Type: engine.GranteeCanonicalUser, ID: value}
+			case ok && strings.EqualFold(kind, "uri"):
+				g = metadata.ACLGrantee{Type: engine.GranteeGroup, URI: value}
+			case ok && strings.EqualFold(kind, "emailAddress"):
+				g = metadata.ACLGrantee{Type: engine.GranteeEmail, EmailAddress: value}
+			default:
+				return nil, withMessage(ErrInvalidArgument, "Invalid grantee in "+name)
+			}
+			acl.Grants = append(acl.Grants, metadata.ACLGrant{Grantee: g, Permission: permission})
+		}
+	}
+	return acl, nil
+}
+
+// policyACL builds an ACL from an AccessControlPolicy body
+func policyACL(body []byte) (*metadata.AccessControlList, S3Error) {
+	var policy accessControlPolicy
+	if err := xml.Unmarshal(body, &policy); err != nil {
+		return nil, ErrMalformedACL
+	}
+	acl := &metadata.AccessControlList{
+		Owner: metadata.ACLOwner{ID: policy.Owner.ID, DisplayName: policy.Owner.DisplayName},
+	}
+	for _, grant := range policy.Grants {
+		acl.Grants = append(acl.Grants, metadata.ACLGrant{
+			Grantee: metadata.ACLGrantee{
+				Type:         grant.Grantee.Type,
+				ID:           grant.Grantee.ID,
+				DisplayName:  grant.Grantee.DisplayName,
+				EmailAddress: grant.Grantee.EmailAddress,
+				URI:          grant.Grantee.URI,
+			},
+			Permission: grant.Permission,
+		})
+	}
+	return acl, nil
+}
+
+// aclFromRequest returns the ACL a PUT ?acl request sets, which it must
+func (r *Router) aclFromRequest(req *http.Request) (*metadata.AccessControlList, S3Error) {
+	body, err := readLimitedBody(req.Body)
+	if err != nil {
+		return nil, toS3Error(err)
+	}
+	acl, s3err := requestACL(req.Header, body)
+	if s3err != nil {
+		return nil, s3err
+	}
+	if acl == nil {
+		return nil, withMessage(ErrMalformedACL, "The request must specify an ACL")
+	}
+	return acl, nil
+}
+
+// uploadACL returns the ACL an upload sets through its headers. A public
+// ACL the bucket's public access block blocks or ignores is dropped, so the
+// object gets the default one.
+func (r *Router) uploadACL(req *http.Request, bucket string) (*metadata.AccessControlList, S3Error) {
+	acl, s3err := requestACL(req.Header, nil)
+	if s3err != nil || acl == nil {
+		return nil, s3err
+	}
+	if r.publicACLIgnored(req.Context(), bucket, acl) {
+		r.logger.Infow("ignoring public ACL", "bucket", bucket)
+		return nil, nil
+	}
+	return acl, nil
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/iam"
+)
+
+// subresourceAction is the S3 action of a request for a subresource
+type subresourceAction struct {
+	param  string
+	action string
+}
+
+// The S3 actions of bucket subresource requests, in the order route checks
+// the subresources, so a request naming several is authorized for the one
+// it is routed to. Deleting most configurations takes the permission to
+// put them, as in AWS.
+var (
+	bucketGetActions = []subresourceAction{
+		{"versioning", "s3:GetBucketVersioning"},
+		{"lifecycle", "s3:GetLifecycleConfiguration"},
+		{"cors", "s3:GetBucketCORS"},
+		{"policy", "s3:GetBucketPolicy"},
+		{"encryption", "s3:GetEncryptionConfiguration"},
+		{"replication", "s3:GetReplicationConfiguration"},
+		{"tagging", "s3:GetBucketTagging"},
+		{"object-lock", "s3:GetBucketObjectLockConfiguration"},
+		{"public-access-block", "s3:GetBucketPublicAccessBlock"},
+		{"accelerate", "s3:GetAccelerateConfiguration"},
+		{"inventory", "s3:GetInventoryConfiguration"},
+		{"analytics", "s3:GetAnalyticsConfiguration"},
+		{"website", "s3:GetBucketWebsite"},
+		{"notification", "s3:GetBucketNotification"},
+		{"logging", "s3:GetBucketLogging"},
+		{"location", "s3:GetBucketLocation"},
+		{"ownership-controls", "s3:GetBucketOwnershipControls"},
+		{"metrics", "s3:GetMetricsConfiguration"},
+	}
+
+	bucketPutActions = []subresourceAction{
+		{"versioning", "s3:PutBucketVersioning"},
+		{"lifecycle", "s3:PutLifecycleConfiguration"},
+		{"cors", "s3:PutBucketCORS"},
+		{"policy", "s3:PutBucketPolicy"},
+		{"encryption", "s3:PutEncryptionConfiguration"},
+		{"replication", "s3:PutReplicationConfiguration"},
+		{"tagging", "s3:PutBucketTagging"},
+		{"object-lock", "s3:PutBucketObjectLockConfiguration"},
+		{"public-access-block", "s3:PutBucketPublicAccessBlock"},
+		{"accelerate", "s3:PutAccelerateConfiguration"},
+		{"inventory", "s3:PutInventoryConfiguration"},
+		{"analytics", "s3:PutAnalyticsConfiguration"},
+		{"website", "s3:PutBucketWebsite"},
+		{"notification", "s3:PutBucketNotification"},
+		{"logging", "s3:PutBucketLogging"},
+		{"location", "s3:CreateBucket"},
+		{"ownership-controls", "s3:PutBucketOwnershipControls"},
+		{"metrics", "s3:PutMetricsConfiguration"},
+	}
+
+	bucketDeleteActions = []subresourceAction{
+		{"inventory", "s3:PutInventoryConfiguration"},
+		{"analytics", "s3:PutAnalyticsConfiguration"},
+		{"website", "s3:DeleteBucketWebsite"},
+		{"policy", "s3:DeleteBucketPolicy"},
+		{"lifecycle", "s3:PutLifecycleConfiguration"},
+		{"cors", "s3:PutBucketCORS"},
+		{"encryption", "s3:PutEncryptionConfiguration"},
+		{"replication", "s3:PutReplicationConfiguration"},
+		{"tagging", "s3:PutBucketTagging"},
+		{"object-lock", "s3:PutBucketObjectLockConfiguration"},
+		{"public-access-block", "s3:PutBucketPublicAccessBlock"},
+		{"accelerate", "s3:PutAccelerateConfiguration"},
+		{"notification", "s3:PutBucketNotification"},
+		{"logging", "s3:PutBucketLogging"},
+		{"ownership-controls", "s3:PutBucketOwnershipControls"},
+		{"metrics", "s3:PutMetricsConfiguration"},
+	}
+
+	objectGetActions = []subresourceAction{
+		{"presignedurl", "s3:GetObject"},
+		{"acl", "s3:GetObjectAcl"},
+		{"tagging", "s3:GetObjectTagging"},
+		{"retention", "s3:GetObjectRetention"},
+		{"legal-hold", "s3:GetObjectLegalHold"},
+		{"attributes", "s3:GetObjectAttributes"},
+	}
+
+	objectPutActions = []subresourceAction{
+		{"presignedurl", "s3:PutObject"},
+		{"acl", "s3:PutObjectAcl"},
+		{"tagging", "s3:PutObjectTagging"},
+		{"retention", "s3:PutObjectRetention"},
+		{"legal-hold", "s3:PutObjectLegalHold"},
+	}
+)
+
+// flagParams are the subresources route recognizes when sent with no value
+var flagParams = map[string]bool{
+	"acl":        true,
+	"versions":   true,
+	"attributes": true,
+	"restore":    true,
+}
+
+// lookupAction returns the action of the first subresource of actions the
+// query names. Most subresources only count when they have a value, as in
+// route.
+func lookupAction(query url.Values, actions []subresourceAction) (string, bool) {
+	for _, a := range actions {
+		if flagParams[a.param] && query.Has(a.param) || query.Get(a.param) != "" {
+			return a.action, true
+		}
+	}
+	return "", false
+}
+
+// requestAction returns the S3 action IAM and bucket policies authorize a
+// request as. It follows the dispatch in route, so the action is the one
+// of the handler the request reaches.
+func requestAction(req *http.Request, bucket, key string) string {
+	query := req.URL.Query()
+
+	if bucket != "" && key != "" {
+		_, hasUploads := query["uploads"]
+		if hasUploads && req.Method == http.MethodPost {
+			return "s3:PutObject"
+		}
+		if query.Get("uploadId") != "" {
+			switch req.Method {
+			case http.MethodPut:
+				if query.Get("partNumber") != "" {
+					return "s3:PutObject"
+				}
+			case http.MethodPost:
+				return "s3:PutObject"
+			case http.MethodDelete:
+				return "s3:AbortMultipartUpload"
+			case http.MethodGet:
+				return "s3:ListMultipartUploadParts"
+			}
+		}
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		switch {
+		case bucket == "":
+			return "s3:ListAllMyBuckets"
+		case key == "":
+			if action, ok := lookupAction(query, bucketGetActions); ok {
+				return action
+			}
+			if query.Has("acl") {
+				return "s3:GetBucketAcl"
+			}
+			if query.Has("versions") {
+				return "s3:ListBucketVersions"
+			}
+			return "s3:ListBucket"
+		}
+		if action, ok := lookupAction(query, objectGetActions); ok {
+			return action
+		}
+		return "s3:GetObject"
+	case http.MethodPut:
+		switch {
+		case bucket == "":
+			return "s3:CreateBucket"
+		case key == "":
+			if action, ok := lookupAction(query, bucketPutActions); ok {
+				return action
+			}
+			if query.Has("acl") {
+				return "s3:PutBucketAcl"
+			}
+			return "s3:CreateBucket"
+		}
+		if action, ok := lookupAction(query, objectPutActions); ok {
+			return action
+		}
+		return "s3:PutObject"
+	case http.MethodDelete:
+		if key == "" {
+			if action, ok := lookupAction(query, bucketDeleteActions); ok {
+				return action
+			}
+			if query.Has("acl") {
+				return "s3:PutBucketAcl"
+			}
+			return "s3:DeleteBucket"
+		}
+		if query.Get("tagging") != "" {
+			return "s3:DeleteObjectTagging"
+		}
+		return "s3:DeleteObject"
+	case http.MethodHead:
+		switch {
+		case key != "":
+			return "s3:GetObject"
+		case bucket != "":
+			return "s3:ListBucket"
+		}
+		return "s3:ListAllMyBuckets"
+	case http.MethodPost:
+		switch {
+		case key != "" && query.Get("select") != "":
+			return "s3:GetObject"
+		case key != "" && query.Has("restore"):
+			return "s3:RestoreObject"
+		case key != "" && query.Get("rename") != "":
+			// The new key is authorized separately, by authorizeRequest
+			return "s3:DeleteObject"
+		case bucket != "" && key == "" && query.Get("delete") != "":
+			return "s3:DeleteObject"
+		case bucket != "" && key == "":
+			return "s3:ListBucketMultipartUploads"
+		}
+		return "s3:PutObject"
+	}
+	return "s3:" + req.Method
+}
+
+// authorizeRequest checks that the request's credentials allow the
+// operation it asks for, or for anonymous requests that the bucket policy
+// does. Copies also need read access to their source, and renames write
+// access to the new key. presigned requests have had their signature
+// verified by ServeHTTP.
+func (r *Router) authorizeRequest(req *http.Request, bucket, key string, presigned bool) S3Error {
+	resource := bucket
+	if key != "" {
+		resource = bucket + "/" + key
+	}
+	if s3err := r.authorize(req, bucket, resource, requestAction(req, bucket, key), presigned); s3err != nil {
+		return s3err
+	}
+	if key == "" {
+		return nil
+	}
+
+	query := req.URL.Query()
+	switch req.Method {
+	case http.MethodPut:
+		if req.Header.Get("x-amz-copy-source") == "" {
+			return nil
+		}
+		if _, subresource := lookupAction(query, objectPutActions); subresource && query.Get("uploadId") == "" {
+			return nil
+		}
+		srcBucket, srcKey, _, s3err := parseCopySource(req.Header.Get("x-amz-copy-source"))
+		if s3err != nil {
+			// The handler reports the malformed source
+			return nil
+		}
+		return r.authorize(req, srcBucket, srcBucket+"/"+srcKey, "s3:GetObject", presigned)
+	case http.MethodPost:
+		newKey := query.Get("rename")
+		if newKey == "" || query.Get("select") != "" || query.Has("restore") {
+			return nil
+		}
+		return r.authorize(req, bucket, bucket+"/"+newKey, "s3:PutObject", presigned)
+	}
+	return nil
+}
+
+// authorize checks one action on a "bucket" or "bucket/key" resource
+func (r *Router) authorize(req *http.Request, bucket, resource, action string, presigned bool) S3Error {
+	if presigned {
+		if !r.auth.IsAuthorized(auth.RequestAccessKey(req), resource, action) {
+			return ErrAccessDenied
+		}
+		return nil
+	}
+
+	err := r.auth.Authorize(req, resource, action)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, auth.ErrUnsigned) && r.bucketPolicyAllowsAnonymous(req, bucket, resource, action) {
+		return nil
+	}
+	r.logger.Debugw("request not authorized", "action", action, "resource", resource, "error", err)
+	return authError(err)
+}
+
+// bucketPolicyAllowsAnonymous reports whether the bucket's policy grants
+// everyone action on resource
+func (r *Router) bucketPolicyAllowsAnonymous(req *http.Request, bucket, resource, action string) bool {
+	if bucket == "" {
+		return false
+	}
+	policy, err := r.engine.GetBucketPolicy(req.Context(), bucket)
+	if err != nil || policy == nil {
+		return false
+	}
+	allowed, err := iam.PolicyAllowsAnonymous([]byte(*policy), action, auth.ResourceARN(resource))
+	if err != nil {
+		r.logger.Warnw("failed to evaluate bucket policy", "bucket", bucket, "error", err)
+		return false
+	}
+	return allowed
+}
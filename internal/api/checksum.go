@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/pkg/s3types"
+)
+
+// Headers of the additional checksums. The value of a checksum travels in
+// x-amz-checksum-<algorithm>, such as x-amz-checksum-crc32c.
+const (
+	headerChecksumAlgorithm    = "x-amz-checksum-algorithm"
+	headerSDKChecksumAlgorithm = "x-amz-sdk-checksum-algorithm"
+	headerChecksumType         = "x-amz-checksum-type"
+	headerChecksumPrefix       = "x-amz-checksum-"
+)
+
+// checksumAlgorithms are the additional checksum algorithms supported
+var checksumAlgorithms = []string{engine.ChecksumCRC32, engine.ChecksumCRC32C, engine.ChecksumSHA1, engine.ChecksumSHA256}
+
+// checksumFromRequest returns the checksum algorithm an upload asks for, from
+// x-amz-checksum-algorithm or from the checksum header it sends, and the
+// value of that header if it is set. The engine checks the data against it.
+func checksumFromRequest(header http.Header) (algorithm, value string, s3err S3Error) {
+	algorithm = strings.ToUpper(header.Get(headerChecksumAlgorithm))
+	if algorithm == "" {
+		algorithm = strings.ToUpper(header.Get(headerSDKChecksumAlgorithm))
+	}
+	for _, a := range checksumAlgorithms {
+		v := header.Get(headerChecksumPrefix + strings.ToLower(a))
+		if v == "" {
+			continue
+		}
+		if value != "" {
+			return "", "", withMessage(ErrInvalidRequest, "Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.")
+		}
+		if algorithm != "" && algorithm != a {
+			return "", "", withMessage(ErrInvalidRequest, "Value for x-amz-checksum-algorithm header is invalid.")
+		}
+		algorithm, value = a, v
+	}
+	return algorithm, value, nil
+}
+
+// setChecksumHeaders reports an object's or a part's additional checksum,
+// if it has one
+func setChecksumHeaders(w http.ResponseWriter, checksum *metadata.ObjectChecksum) {
+	if checksum == nil {
+		return
+	}
+	w.Header().Set(headerChecksumPrefix+strings.ToLower(checksum.Algorithm), checksum.Value)
+	if checksum.Type != "" {
+		w.Header().Set(headerChecksumType, checksum.Type)
+	}
+}
+
+// newChecksumResult converts a stored checksum to its XML form, or returns
+// nil if there is none
+func newChecksumResult(checksum *metadata.ObjectChecksum) *s3types.Checksum {
+	if checksum == nil {
+		return nil
+	}
+	result := &s3types.Checksum{ChecksumType: checksum.Type}
+	switch checksum.Algorithm {
+	case engine.ChecksumCRC32:
+		result.ChecksumCRC32 = checksum.Value
+	case engine.ChecksumCRC32C:
+		result.ChecksumCRC32C = checksum.Value
+	case engine.ChecksumSHA1:
+		result.ChecksumSHA1 = checksum.Value
+	case engine.ChecksumSHA256:
+		result.ChecksumSHA256 = checksum.Value
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// genericContentType is what clients send when they do not know the type
+const genericContentType = "application/octet-stream"
+
+// newContentTypeOverrides normalizes the configured extension to content
+// type map so lookups can use the lowercased extension with its dot
+func newContentTypeOverrides(configured map[string]string) map[string]string {
+	overrides := make(map[string]string, len(configured))
+	for ext, contentType := range configured {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || contentType == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		overrides[ext] = contentType
+	}
+	return overrides
+}
+
+// contentTypeFor returns the content type to serve for an object. A stored
+// type is kept unless it is missing or generic, in which case the key's
+// extension is looked up in the configured overrides and then in Go's mime
+// table.
+func (r *Router) contentTypeFor(key, stored string) string {
+	if stored != "" && !strings.EqualFold(stored, genericContentType) {
+		return stored
+	}
+
+	ext := strings.ToLower(path.Ext(key))
+	if ext == "" {
+		return stored
+	}
+	if contentType, ok := r.contentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return stored
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// corsVary lists the request headers CORS responses depend on
+const corsVary = "Origin, Access-Control-Request-Headers, Access-Control-Request-Method"
+
+// handlePreflight handles OPTIONS /bucket[/key], answering a browser's CORS
+// preflight from the bucket's CORS rules
+func (r *Router) handlePreflight(w http.ResponseWriter, req *http.Request, bucket string) {
+	origin := req.Header.Get("Origin")
+	method := req.Header.Get("Access-Control-Request-Method")
+	if origin == "" || method == "" {
+		r.writeError(w, "PreflightRequest", withMessage(ErrInvalidRequest, "Insufficient information. Origin and Access-Control-Request-Method request headers needed."))
+		return
+	}
+
+	var headers []string
+	for _, header := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	rule := r.matchCORSRule(req, bucket, method, headers)
+	if rule == nil {
+		r.writeError(w, "PreflightRequest", ErrCORSForbidden)
+		return
+	}
+
+	setCORSHeaders(w, rule, origin)
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	w.WriteHeader(http.StatusOK)
+	recordOperation(w, "PreflightRequest")
+}
+
+// applyCORS adds the Access-Control-* headers of the bucket's CORS rule that
+// allows a cross-origin request. Requests without an Origin, or that no rule
+// allows, are served without them.
+func (r *Router) applyCORS(w http.ResponseWriter, req *http.Request, bucket string) {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	if rule := r.matchCORSRule(req, bucket, req.Method, nil); rule != nil {
+		setCORSHeaders(w, rule, origin)
+	}
+}
+
+// matchCORSRule returns the bucket's CORS rule that allows a request from the
+// request's Origin, or nil if the bucket has none or no rule does
+func (r *Router) matchCORSRule(req *http.Request, bucket, method string, headers []string) *metadata.CORSRule {
+	if bucket == "" {
+		return nil
+	}
+	cors, err := r.engine.GetBucketCors(req.Context(), bucket)
+	if err != nil {
+		return nil
+	}
+	return engine.MatchCORSRule(cors, req.Header.Get("Origin"), method, headers)
+}
+
+// setCORSHeaders writes the response headers a CORS rule grants to origin.
+// Rules that allow any origin answer with "*"; others echo the origin and
+// allow credentials.
+func setCORSHeaders(w http.ResponseWriter, rule *metadata.CORSRule, origin string) {
+	h := w.Header()
+	allowOrigin := sanitizeHeaderValue(origin)
+	for _, allowed := range rule.AllowedOrigins {
+		if allowed == "*" {
+			allowOrigin = "*"
+			break
+		}
+	}
+	h.Set("Access-Control-Allow-Origin", allowOrigin)
+	if allowOrigin != "*" {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	if rule.MaxAgeSeconds > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+	h.Set("Vary", corsVary)
+}
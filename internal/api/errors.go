@@ -1,7 +1,11 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/engine"
 )
 
 // S3Error represents an S3 API error
@@ -23,6 +27,21 @@ func (e *s3Error) Message() string   { return e.message }
 func (e *s3Error) StatusCode() int    { return e.statusCode }
 func (e *s3Error) Error() string     { return fmt.Sprintf("%s: %s", e.code, e.message) }
 
+// withMessage returns a copy of err that reports msg instead of its usual
+// message, for errors whose cause the client needs to fix its request
+func withMessage(err *s3Error, msg string) S3Error {
+	return &s3Error{code: err.code, message: msg, statusCode: err.statusCode}
+}
+
+// errorCodeLabel returns the metric label for an error. Only errors declared
+// in this package are reported by code, which keeps label cardinality bounded.
+func errorCodeLabel(err S3Error) string {
+	if e, ok := err.(*s3Error); ok {
+		return e.code
+	}
+	return "Other"
+}
+
 // Common S3 errors
 var (
 	ErrInternal = &s3Error{
@@ -61,6 +80,12 @@ var (
 		statusCode: 400,
 	}
 
+	ErrRestoreAlreadyInProgress = &s3Error{
+		code:       "RestoreAlreadyInProgress",
+		message:    "Object restore is already in progress.",
+		statusCode: 409,
+	}
+
 	ErrOwnershipControlsNotFound = &s3Error{
 		code:       "OwnershipControlsNotFound",
 		message:    "The ownership controls for this bucket do not exist.",
@@ -127,18 +152,54 @@ var (
 		statusCode: 403,
 	}
 
+	ErrCORSForbidden = &s3Error{
+		code:       "AccessForbidden",
+		message:    "CORSResponse: This CORS request is not allowed.",
+		statusCode: 403,
+	}
+
 	ErrSignatureDoesNotMatch = &s3Error{
 		code:       "SignatureDoesNotMatch",
 		message:    "The request signature we calculated does not match the signature you provided.",
 		statusCode: 403,
 	}
 
+	ErrInvalidAccessKeyID = &s3Error{
+		code:       "InvalidAccessKeyId",
+		message:    "The AWS access key ID you provided does not exist in our records.",
+		statusCode: 403,
+	}
+
+	ErrInvalidToken = &s3Error{
+		code:       "InvalidToken",
+		message:    "The provided token is malformed or otherwise invalid.",
+		statusCode: 400,
+	}
+
+	ErrExpiredToken = &s3Error{
+		code:       "ExpiredToken",
+		message:    "The provided token has expired.",
+		statusCode: 400,
+	}
+
 	ErrMalformedXML = &s3Error{
 		code:       "MalformedXML",
 		message:    "The XML you provided was not well-formed or did not validate against our published schema.",
 		statusCode: 400,
 	}
 
+	ErrMalformedACL = &s3Error{
+		code:       "MalformedACLError",
+		message:    "The XML you provided was not well-formed or did not validate against our published schema.",
+		statusCode: 400,
+	}
+
+	ErrBadDigest = &s3Error{
+		code:       "BadDigest",
+		message:    "The checksum you specified did not match the calculated checksum.",
+		statusCode: 400,
+	}
+
 	ErrMissingContentLength = &s3Error{
 		code:       "MissingContentLength",
 		message:    "You must provide the Content-Length HTTP header.",
@@ -199,12 +260,30 @@ var (
 		statusCode: 400,
 	}
 
+	ErrInvalidPart = &s3Error{
+		code:       "InvalidPart",
+		message:    "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.",
+		statusCode: 400,
+	}
+
+	ErrInvalidPartOrder = &s3Error{
+		code:       "InvalidPartOrder",
+		message:    "The list of parts was not in ascending order. The parts list must be specified in order by part number.",
+		statusCode: 400,
+	}
+
 	ErrEntityTooLarge = &s3Error{
 		code:       "EntityTooLarge",
 		message:    "Your proposed upload exceeds the maximum allowed object size.",
 		statusCode: 400,
 	}
 
+	ErrMetadataTooLarge = &s3Error{
+		code:       "MetadataTooLarge",
+		message:    "Your metadata headers exceed the maximum allowed metadata size.",
+		statusCode: 400,
+	}
+
 	ErrInvalidRequest = &s3Error{
 		code:       "InvalidRequest",
 		message:    "The request is invalid.",
@@ -247,9 +326,220 @@ var (
 		statusCode: 400,
 	}
 
+	ErrInvalidStorageClass = &s3Error{
+		code:       "InvalidStorageClass",
+		message:    "The storage class you specified is not valid.",
+		statusCode: 400,
+	}
+
+	ErrInvalidUploadOffset = &s3Error{
+		code:       "InvalidUploadOffset",
+		message:    "The upload offset does not match the bytes already received.",
+		statusCode: 409,
+	}
+
 	ErrWebsiteNotFound = &s3Error{
 		code:       "NoSuchWebsiteConfiguration",
 		message:    "The specified bucket website configuration does not exist.",
 		statusCode: 404,
 	}
+
+	ErrInsufficientStorage = &s3Error{
+		code:       "InsufficientStorage",
+		message:    "The server is temporarily unable to store data. Reads are still served.",
+		statusCode: 507,
+	}
+
+	ErrIntegrityCheckFailed = &s3Error{
+		code:       "IntegrityCheckFailed",
+		message:    "The stored object data does not match its checksum.",
+		statusCode: 500,
+	}
+
+	ErrMalformedPOSTRequest = &s3Error{
+		code:       "MalformedPOSTRequest",
+		message:    "The body of your POST request is not well-formed multipart/form-data.",
+		statusCode: 400,
+	}
+
+	ErrInvalidPolicyDocument = &s3Error{
+		code:       "InvalidPolicyDocument",
+		message:    "The content of the form does not meet the conditions specified in the policy document.",
+		statusCode: 400,
+	}
+
+	ErrIncompleteBody = &s3Error{
+		code:       "IncompleteBody",
+		message:    "The request body is not a valid aws-chunked payload.",
+		statusCode: 400,
+	}
+
+	ErrNoSuchVersion = &s3Error{
+		code:       "NoSuchVersion",
+		message:    "The specified version does not exist.",
+		statusCode: 404,
+	}
+
+	ErrQuotaExceeded = &s3Error{
+		code:       "QuotaExceeded",
+		message:    "The bucket quota has been exceeded.",
+		statusCode: 403,
+	}
+
+	ErrInvalidTag = &s3Error{
+		code:       "InvalidTag",
+		message:    "The tag provided was not a valid tag.",
+		statusCode: 400,
+	}
+
+	ErrInvalidRange = &s3Error{
+		code:       "InvalidRange",
+		message:    "The requested range is not satisfiable.",
+		statusCode: 416,
+	}
+
+	ErrObjectAlreadyExists = &s3Error{
+		code:       "ObjectAlreadyExists",
+		message:    "An object already exists at the destination key.",
+		statusCode: 409,
+	}
+
+	ErrBucketReadOnly = &s3Error{
+		code:       "AccessDenied",
+		message:    "The bucket is in read-only mode.",
+		statusCode: 403,
+	}
+
+	ErrBucketMaintenance = &s3Error{
+		code:       "ServiceUnavailable",
+		message:    "The bucket is under maintenance.",
+		statusCode: 503,
+	}
+
+	ErrInvalidTargetBucketForLogging = &s3Error{
+		code:       "InvalidTargetBucketForLogging",
+		message:    "The target bucket for logging does not exist or is not owned by the owner of the source bucket.",
+		statusCode: 400,
+	}
 )
+
+// toS3Error maps an error returned by the engine to the S3 error reported to
+// the client. Anything not recognized is an internal error. Writes refused
+// because the metadata store is read-only or full get a distinct status so
+// clients can tell them apart from internal errors, and failures decoding a
+// streaming upload body are reported as client errors.
+func toS3Error(err error) S3Error {
+	switch {
+	case errors.Is(err, engine.ErrBucketNotFound):
+		return ErrNoSuchBucket
+	case errors.Is(err, engine.ErrBucketNotEmpty):
+		return ErrBucketNotEmpty
+	case errors.Is(err, engine.ErrBucketOwnedByYou):
+		return ErrBucketAlreadyOwnedByYou
+	case errors.Is(err, engine.ErrBucketExists):
+		return ErrBucketAlreadyExists
+	case errors.Is(err, engine.ErrInvalidBucketName):
+		return ErrInvalidBucketName
+	case errors.Is(err, engine.ErrObjectNotFound):
+		return ErrNoSuchKey
+	case errors.Is(err, engine.ErrNoSuchVersion):
+		return ErrNoSuchVersion
+	case errors.Is(err, engine.ErrDeleteMarker):
+		return ErrMethodNotAllowed
+	case errors.Is(err, engine.ErrEntityTooLarge):
+		return ErrEntityTooLarge
+	case errors.Is(err, engine.ErrMetadataTooLarge):
+		return ErrMetadataTooLarge
+	case errors.Is(err, engine.ErrPreconditionFailed):
+		return ErrPreconditionFailed
+	case errors.Is(err, engine.ErrQuotaExceeded):
+		return ErrQuotaExceeded
+	case errors.Is(err, engine.ErrInvalidTag):
+		return ErrInvalidTag
+	case errors.Is(err, engine.ErrInvalidCORS):
+		return withMessage(ErrMalformedXML, err.Error())
+	case errors.Is(err, engine.ErrInvalidNotification):
+		return withMessage(ErrInvalidArgument, err.Error())
+	case errors.Is(err, engine.ErrObjectExists):
+		return ErrObjectAlreadyExists
+	case errors.Is(err, engine.ErrVersionedMove):
+		return ErrInvalidRequest
+	case errors.Is(err, engine.ErrInvalidRetention):
+		return ErrInvalidArgument
+	case errors.Is(err, engine.ErrRetentionLocked), errors.Is(err, engine.ErrLegalHold):
+		return ErrAccessDenied
+	case errors.Is(err, engine.ErrInvalidLegalHold):
+		return ErrInvalidArgument
+	case errors.Is(err, engine.ErrObjectLockDisabled):
+		return withMessage(ErrInvalidRequest, "Bucket is missing Object Lock Configuration")
+	case errors.Is(err, engine.ErrInvalidPartNumber), errors.Is(err, engine.ErrTooManyParts):
+		return ErrInvalidArgument
+	case errors.Is(err, engine.ErrInvalidPart):
+		return ErrInvalidPart
+	case errors.Is(err, engine.ErrInvalidPartOrder):
+		return ErrInvalidPartOrder
+	case errors.Is(err, engine.ErrEntityTooSmall):
+		return ErrEntityTooSmall
+	case errors.Is(err, engine.ErrIntegrityMismatch):
+		return ErrIntegrityCheckFailed
+	case errors.Is(err, engine.ErrMetadataUnavailable):
+		return ErrInsufficientStorage
+	case errors.Is(err, engine.ErrBucketReadOnly):
+		return ErrBucketReadOnly
+	case errors.Is(err, engine.ErrBucketMaintenance):
+		return ErrBucketMaintenance
+	case errors.Is(err, engine.ErrInvalidObjectState):
+		return ErrInvalidObjectState
+	case errors.Is(err, engine.ErrRestoreInProgress):
+		return ErrRestoreAlreadyInProgress
+	case errors.Is(err, engine.ErrInvalidRestore):
+		return withMessage(ErrInvalidArgument, err.Error())
+	case errors.Is(err, engine.ErrInvalidCopyRange):
+		return ErrInvalidRange
+	case errors.Is(err, engine.ErrInvalidEncryption):
+		return withMessage(ErrInvalidArgument, "The encryption method specified is not supported")
+	case errors.Is(err, engine.ErrEncryptionUnavailable):
+		return withMessage(ErrNotImplemented, "Server-side encryption is not configured on this server")
+	case errors.Is(err, engine.ErrInvalidACL):
+		return ErrMalformedACL
+	case errors.Is(err, engine.ErrInvalidChecksum):
+		return withMessage(ErrInvalidRequest, err.Error())
+	case errors.Is(err, engine.ErrChecksumMismatch):
+		return ErrBadDigest
+	}
+	if s3err := streamingBodyError(err); s3err != nil {
+		return s3err
+	}
+	return ErrInternal
+}
+
+// authError maps an error returned by auth.Authorize to the S3 error
+// reported to the client. Requests that fail authentication for any other
+// reason are denied.
+func authError(err error) S3Error {
+	switch {
+	case errors.Is(err, auth.ErrSignatureMismatch):
+		return ErrSignatureDoesNotMatch
+	case errors.Is(err, auth.ErrInvalidAccessKey):
+		return ErrInvalidAccessKeyID
+	case errors.Is(err, auth.ErrInvalidToken):
+		return ErrInvalidToken
+	case errors.Is(err, auth.ErrExpiredToken):
+		return ErrExpiredToken
+	}
+	return ErrAccessDenied
+}
+
+// streamingBodyError maps a failure decoding an aws-chunked request body to
+// the S3 error reported for it, or returns nil for any other error. It is
+// the one mapping for chunk errors, used both when ServeHTTP sets up the
+// decoder and, through toS3Error, when an upload handler reads the body.
+func streamingBodyError(err error) S3Error {
+	switch {
+	case errors.Is(err, auth.ErrMalformedChunk):
+		return ErrIncompleteBody
+	case errors.Is(err, auth.ErrSignatureMismatch):
+		return ErrSignatureDoesNotMatch
+	}
+	return nil
+}
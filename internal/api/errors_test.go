@@ -1,8 +1,13 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/engine"
 )
 
 func TestS3ErrorAllMethods(t *testing.T) {
@@ -119,3 +124,36 @@ func TestS3ErrorFormat(t *testing.T) {
 		t.Error("Error() should contain code and message")
 	}
 }
+
+func TestToS3Error(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *s3Error
+	}{
+		{engine.ErrBucketNotFound, ErrNoSuchBucket},
+		{fmt.Errorf("%w: photos", engine.ErrBucketNotFound), ErrNoSuchBucket},
+		{fmt.Errorf("%w: photos", engine.ErrBucketNotEmpty), ErrBucketNotEmpty},
+		{fmt.Errorf("%w: Photos", engine.ErrInvalidBucketName), ErrInvalidBucketName},
+		{fmt.Errorf("%w: a.txt", engine.ErrObjectNotFound), ErrNoSuchKey},
+		{fmt.Errorf("source %w: a.txt", engine.ErrObjectNotFound), ErrNoSuchKey},
+		{fmt.Errorf("%w: v1", engine.ErrNoSuchVersion), ErrNoSuchVersion},
+		{fmt.Errorf("%w: 10 > 5", engine.ErrEntityTooLarge), ErrEntityTooLarge},
+		{fmt.Errorf("%w: etag mismatch", engine.ErrPreconditionFailed), ErrPreconditionFailed},
+		{fmt.Errorf("%w: photos", engine.ErrQuotaExceeded), ErrQuotaExceeded},
+		{fmt.Errorf("read: %w", engine.ErrIntegrityMismatch), ErrIntegrityCheckFailed},
+		{fmt.Errorf("put: %w", engine.ErrMetadataUnavailable), ErrInsufficientStorage},
+		{fmt.Errorf("%w: photos", engine.ErrBucketReadOnly), ErrBucketReadOnly},
+		{fmt.Errorf("%w: photos", engine.ErrBucketMaintenance), ErrBucketMaintenance},
+		{fmt.Errorf("%w: photos/a.txt", engine.ErrLegalHold), ErrAccessDenied},
+		{fmt.Errorf("%w: unknown status", engine.ErrInvalidLegalHold), ErrInvalidArgument},
+		{fmt.Errorf("body: %w", auth.ErrSignatureMismatch), ErrSignatureDoesNotMatch},
+		{fmt.Errorf("body: %w", auth.ErrMalformedChunk), ErrIncompleteBody},
+		{errors.New("disk on fire"), ErrInternal},
+	}
+
+	for _, tt := range tests {
+		if got := toS3Error(tt.err); got != S3Error(tt.want) {
+			t.Errorf("toS3Error(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/lifecycle"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	s3types "github.com/openendpoint/openendpoint/pkg/s3types"
+)
+
+// lifecycleDateLayout is how lifecycle Expiration dates are written: ISO
+// 8601 at midnight UTC
+const lifecycleDateLayout = "2006-01-02T15:04:05.000Z"
+
+// errLifecycleDateNotMidnight reports an Expiration date that is not at
+// midnight UTC, which S3 refuses
+var errLifecycleDateNotMidnight = errors.New("'Date' must be at midnight GMT")
+
+// parseLifecycleDate parses an Expiration date, either a full ISO 8601
+// timestamp or just the day, to Unix seconds
+func parseLifecycleDate(value string) (int64, error) {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if date, err = time.Parse("2006-01-02", value); err != nil {
+			return 0, err
+		}
+	}
+	date = date.UTC()
+	if !date.Equal(date.Truncate(24 * time.Hour)) {
+		return 0, errLifecycleDateNotMidnight
+	}
+	return date.Unix(), nil
+}
+
+// validLifecycleFilter reports whether a rule's Filter is well formed: a
+// prefix and a tag can only be combined under And
+func validLifecycleFilter(f *s3types.LifecycleFilter) bool {
+	if f == nil {
+		return true
+	}
+	if f.And != nil {
+		return f.Prefix == "" && f.Tag == nil
+	}
+	return f.Prefix == "" || f.Tag == nil
+}
+
+// lifecycleFilterFromS3 flattens a rule's Filter into the prefix and tags an
+// object must match
+func lifecycleFilterFromS3(f *s3types.LifecycleFilter) *metadata.LifecycleFilter {
+	if f == nil {
+		return nil
+	}
+	filter := &metadata.LifecycleFilter{Prefix: f.Prefix}
+	tags := make(map[string]string)
+	if f.Tag != nil {
+		tags[f.Tag.Key] = f.Tag.Value
+	}
+	if f.And != nil {
+		if f.And.Prefix != "" {
+			filter.Prefix = f.And.Prefix
+		}
+		for _, tag := range f.And.Tags {
+			tags[tag.Key] = tag.Value
+		}
+	}
+	if len(tags) > 0 {
+		filter.Tags = tags
+	}
+	return filter
+}
+
+// lifecycleFilterToS3 writes a filter back as S3 does: a lone prefix or tag
+// directly, anything more under And with its tags sorted by key
+func lifecycleFilterToS3(f *metadata.LifecycleFilter) *s3types.LifecycleFilter {
+	if f == nil {
+		return nil
+	}
+	if len(f.Tags) == 0 {
+		return &s3types.LifecycleFilter{Prefix: f.Prefix}
+	}
+
+	keys := make([]string, 0, len(f.Tags))
+	for key := range f.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 1 && f.Prefix == "" {
+		return &s3types.LifecycleFilter{Tag: &s3types.Tag{Key: keys[0], Value: f.Tags[keys[0]]}}
+	}
+
+	and := &s3types.LifecycleFilterAnd{Prefix: f.Prefix}
+	for _, key := range keys {
+		and.Tags = append(and.Tags, s3types.Tag{Key: key, Value: f.Tags[key]})
+	}
+	return &s3types.LifecycleFilter{And: and}
+}
+
+// lifecycleDateFromS3 parses a lifecycle action date, answering with the
+// InvalidArgument S3 returns for a malformed one
+func lifecycleDateFromS3(value string) (int64, S3Error) {
+	date, err := parseLifecycleDate(value)
+	if err != nil {
+		msg := "'Date' must be an ISO 8601 date"
+		if errors.Is(err, errLifecycleDateNotMidnight) {
+			msg = err.Error()
+		}
+		return 0, withMessage(ErrInvalidArgument, msg)
+	}
+	return date, nil
+}
+
+// validTransitionClass reports whether objects can be transitioned to a
+// storage class. STANDARD is where objects start, so it is no target.
+func validTransitionClass(class string) bool {
+	_, ok := lifecycle.StorageClasses[class]
+	return ok && class != "STANDARD"
+}
+
+// lifecycleTransitionsFromS3 converts a rule's Transition and
+// NoncurrentVersionTransition actions. Each transition needs a known target
+// class and takes a day count or a date, not both; zero days moves objects
+// on the next run.
+func lifecycleTransitionsFromS3(rule s3types.LifecycleRule) ([]metadata.Transition, []metadata.NoncurrentVersionTransition, S3Error) {
+	var transitions []metadata.Transition
+	for _, t := range rule.Transitions {
+		if !validTransitionClass(t.StorageClass) {
+			return nil, nil, ErrInvalidStorageClass
+		}
+		if t.Days < 0 || (t.Days > 0 && t.Date != "") {
+			return nil, nil, withMessage(ErrInvalidArgument, "Transition cannot specify both 'Days' and 'Date'")
+		}
+		transition := metadata.Transition{Days: t.Days, StorageClass: t.StorageClass}
+		if t.Date != "" {
+			date, s3err := lifecycleDateFromS3(t.Date)
+			if s3err != nil {
+				return nil, nil, s3err
+			}
+			transition.Date = date
+		}
+		transitions = append(transitions, transition)
+	}
+
+	var noncurrent []metadata.NoncurrentVersionTransition
+	for _, t := range rule.NoncurrentVersionTransitions {
+		if !validTransitionClass(t.StorageClass) {
+			return nil, nil, ErrInvalidStorageClass
+		}
+		if t.NoncurrentDays < 1 {
+			return nil, nil, withMessage(ErrInvalidArgument, "'NoncurrentDays' must be a positive integer")
+		}
+		noncurrent = append(noncurrent, metadata.NoncurrentVersionTransition{
+			NoncurrentDays: t.NoncurrentDays,
+			StorageClass:   t.StorageClass,
+		})
+	}
+	return transitions, noncurrent, nil
+}
+
+// lifecycleTransitionsToS3 writes a rule's transitions back in their
+// configuration form
+func lifecycleTransitionsToS3(rule metadata.LifecycleRule) ([]s3types.Transition, []s3types.NoncurrentVersionTransition) {
+	var transitions []s3types.Transition
+	for _, t := range rule.Transitions {
+		transition := s3types.Transition{Days: t.Days, StorageClass: t.StorageClass}
+		if t.Date > 0 {
+			transition.Date = time.Unix(t.Date, 0).UTC().Format(lifecycleDateLayout)
+		}
+		transitions = append(transitions, transition)
+	}
+
+	var noncurrent []s3types.NoncurrentVersionTransition
+	for _, t := range rule.NoncurrentVersionTransitions {
+		noncurrent = append(noncurrent, s3types.NoncurrentVersionTransition{
+			NoncurrentDays: t.NoncurrentDays,
+			StorageClass:   t.StorageClass,
+		})
+	}
+	return transitions, noncurrent
+}
+
+// lifecycleAbortUploadFromS3 converts a rule's AbortIncompleteMultipartUpload
+// action. Uploads carry no tags, so the action cannot go with a tag filter.
+func lifecycleAbortUploadFromS3(rule s3types.LifecycleRule) (*metadata.AbortIncompleteMultipartUpload, S3Error) {
+	abort := rule.AbortIncompleteMultipartUpload
+	if abort == nil {
+		return nil, nil
+	}
+	if abort.DaysAfterInitiation < 1 {
+		return nil, withMessage(ErrInvalidArgument, "'DaysAfterInitiation' for AbortIncompleteMultipartUpload action must be a positive integer")
+	}
+	if f := rule.Filter; f != nil && (f.Tag != nil || (f.And != nil && len(f.And.Tags) > 0)) {
+		return nil, withMessage(ErrInvalidRequest, "AbortIncompleteMultipartUpload cannot be specified with Tags.")
+	}
+	return &metadata.AbortIncompleteMultipartUpload{DaysAfterInitiation: abort.DaysAfterInitiation}, nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/telemetry"
+)
+
+// DefaultMaxBucketLabels is how many buckets get their own bucket label in
+// request metrics when no limit is configured
+const DefaultMaxBucketLabels = 100
+
+// otherBucketLabel labels requests to buckets beyond the label limit. It is
+// not a valid bucket name, so it cannot collide with a real bucket.
+const otherBucketLabel = "_other"
+
+// metricsRecorder captures what the request metrics of a response are
+// labelled with: the status it was written with, and the operation and
+// error code its handler reported
+type metricsRecorder struct {
+	http.ResponseWriter
+	start       time.Time
+	bucket      string
+	operation   string
+	code        string
+	status      int
+	wroteHeader bool
+}
+
+// newMetricsRecorder wraps w to record the metrics of req. Until a handler
+// reports its operation, the request is named by requestOperation.
+func newMetricsRecorder(w http.ResponseWriter, req *http.Request) *metricsRecorder {
+	bucket, _, _ := parseBucketKey(req, req.URL.Path)
+	return &metricsRecorder{
+		ResponseWriter: w,
+		start:          time.Now(),
+		bucket:         bucket,
+		operation:      requestOperation(req),
+		status:         http.StatusOK,
+	}
+}
+
+// WriteHeader implements http.ResponseWriter
+func (m *metricsRecorder) WriteHeader(status int) {
+	if !m.wroteHeader {
+		m.status = status
+		m.wroteHeader = true
+	}
+	m.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (m *metricsRecorder) Write(b []byte) (int, error) {
+	m.wroteHeader = true
+	return m.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (m *metricsRecorder) Flush() {
+	if f, ok := m.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (m *metricsRecorder) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
+
+// downloadCounter counts the object bytes read through it as downloaded
+type downloadCounter struct {
+	io.Reader
+}
+
+// Read implements io.Reader
+func (d downloadCounter) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	telemetry.AddBytesDownloaded(int64(n))
+	return n, err
+}
+
+// recordOperation names the operation a handler served, for the metrics of
+// its request
+func recordOperation(w http.ResponseWriter, operation string) {
+	if rec, ok := w.(*metricsRecorder); ok {
+		rec.operation = operation
+	}
+}
+
+// recordError reports a failed operation and its S3 error to the writers
+// recording the response, through any that wrap them
+func recordError(w http.ResponseWriter, operation string, err S3Error) {
+	for {
+		switch rec := w.(type) {
+		case *metricsRecorder:
+			rec.operation = operation
+			rec.code = errorCodeLabel(err)
+		case *accessLogRecorder:
+			rec.errorCode = err.Code()
+		}
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = wrapper.Unwrap()
+	}
+}
+
+// recordRequest records the request count and duration of a served request
+func (r *Router) recordRequest(rec *metricsRecorder) {
+	bucket := r.bucketLabels.label(rec.bucket, rec.status)
+	s3RequestsTotal.WithLabelValues(rec.operation, strconv.Itoa(rec.status), rec.code, bucket).Inc()
+	s3RequestDuration.WithLabelValues(rec.operation).Observe(time.Since(rec.start).Seconds())
+	if rec.bucket != "" {
+		telemetry.IncBucketRequests(rec.bucket)
+	}
+}
+
+// bucketLabeler bounds the bucket label of request metrics. The first max
+// buckets to be served successfully get their own label; requests to any
+// other bucket share otherBucketLabel, so requests naming random buckets
+// cannot grow the metric without bound.
+type bucketLabeler struct {
+	max int
+
+	mu      sync.Mutex
+	buckets map[string]struct{}
+}
+
+// newBucketLabeler creates a labeler for up to max buckets, or
+// DefaultMaxBucketLabels when max is not positive
+func newBucketLabeler(max int) *bucketLabeler {
+	if max <= 0 {
+		max = DefaultMaxBucketLabels
+	}
+	return &bucketLabeler{max: max, buckets: make(map[string]struct{})}
+}
+
+// label returns the bucket label of a request answered with status. A nil
+// labeler and requests outside any bucket get an empty label.
+func (b *bucketLabeler) label(bucket string, status int) string {
+	if b == nil || bucket == "" {
+		return ""
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.buckets[bucket]; ok {
+		return bucket
+	}
+	if status >= http.StatusBadRequest || len(b.buckets) >= b.max {
+		return otherBucketLabel
+	}
+	b.buckets[bucket] = struct{}{}
+	return bucket
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+func TestRouter_RecordsRequestMetrics(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	svc := engine.New(NewMockAPIStorage(), NewMockAPIMetadata(), logger)
+	router := NewRouter(svc, auth.New(config.AuthConfig{}), logger, &config.Config{})
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "metrics-bucket")
+	svc.PutObject(ctx, "metrics-bucket", "key", strings.NewReader("data"), engine.PutObjectOptions{})
+
+	tests := []struct {
+		method string
+		target string
+		labels []string
+	}{
+		{http.MethodGet, "/s3/metrics-bucket/missing", []string{"GetObject", "404", "NoSuchKey", ""}},
+		// Handlers used to count every success as a 200
+		{http.MethodDelete, "/s3/metrics-bucket/key", []string{"DeleteObject", "204", "", ""}},
+		// Requests failing before their handler are named from the request
+		{http.MethodPatch, "/s3/metrics-bucket", []string{"PATCH", "405", "MethodNotAllowed", ""}},
+	}
+	for _, tt := range tests {
+		counter := s3RequestsTotal.WithLabelValues(tt.labels...)
+		before := counterValue(t, counter)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+		if got := counterValue(t, counter); got != before+1 {
+			t.Errorf("%s %s: s3RequestsTotal%v = %v, want %v", tt.method, tt.target, tt.labels, got, before+1)
+		}
+	}
+}
+
+func TestRouter_BucketLabel(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	svc := engine.New(NewMockAPIStorage(), NewMockAPIMetadata(), logger)
+	cfg := &config.Config{Metrics: config.MetricsConfig{BucketLabel: true, MaxBucketLabels: 1}}
+	router := NewRouter(svc, auth.New(config.AuthConfig{}), logger, cfg)
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "labelled")
+	svc.CreateBucket(ctx, "unlabelled")
+
+	labelled := s3RequestsTotal.WithLabelValues("HeadBucket", "200", "", "labelled")
+	other := s3RequestsTotal.WithLabelValues("HeadBucket", "200", "", otherBucketLabel)
+	beforeLabelled, beforeOther := counterValue(t, labelled), counterValue(t, other)
+
+	// The first bucket takes the only label; the next shares the other one
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/s3/labelled", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/s3/unlabelled", nil))
+
+	if got := counterValue(t, labelled); got != beforeLabelled+1 {
+		t.Errorf("labelled bucket count = %v, want %v", got, beforeLabelled+1)
+	}
+	if got := counterValue(t, other); got != beforeOther+1 {
+		t.Errorf("other bucket count = %v, want %v", got, beforeOther+1)
+	}
+}
+
+func TestBucketLabeler(t *testing.T) {
+	var disabled *bucketLabeler
+	if got := disabled.label("bucket", http.StatusOK); got != "" {
+		t.Errorf("nil labeler label = %q, want empty", got)
+	}
+
+	labeler := newBucketLabeler(2)
+	if got := labeler.label("", http.StatusOK); got != "" {
+		t.Errorf("service request label = %q, want empty", got)
+	}
+	// A failed request does not take a label
+	if got := labeler.label("nonexistent", http.StatusNotFound); got != otherBucketLabel {
+		t.Errorf("failed request label = %q, want %q", got, otherBucketLabel)
+	}
+	for _, bucket := range []string{"a", "b"} {
+		if got := labeler.label(bucket, http.StatusOK); got != bucket {
+			t.Errorf("label(%s) = %q, want %q", bucket, got, bucket)
+		}
+	}
+	if got := labeler.label("c", http.StatusOK); got != otherBucketLabel {
+		t.Errorf("label beyond limit = %q, want %q", got, otherBucketLabel)
+	}
+	// Labelled buckets keep their label, also on failures
+	if got := labeler.label("a", http.StatusNotFound); got != "a" {
+		t.Errorf("label(a) on failure = %q, want a", got)
+	}
+}
+
+func TestRouter_CountsBytesTransferred(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	svc := engine.New(NewMockAPIStorage(), NewMockAPIMetadata(), logger)
+	router := NewRouter(svc, auth.New(config.AuthConfig{}), logger, &config.Config{})
+	svc.CreateBucket(context.Background(), "transfer")
+
+	uploaded := telemetry.GetBytesUploaded()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/s3/transfer/key", strings.NewReader("0123456789")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PutObject status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := telemetry.GetBytesUploaded() - uploaded; got != 10 {
+		t.Errorf("bytes uploaded grew by %v, want 10", got)
+	}
+
+	downloaded := telemetry.GetBytesDownloaded()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/s3/transfer/key", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetObject status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := telemetry.GetBytesDownloaded() - downloaded; got != 10 {
+		t.Errorf("bytes downloaded grew by %v, want 10", got)
+	}
+}
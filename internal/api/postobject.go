@@ -0,0 +1,230 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/pkg/s3types"
+)
+
+// maxPostFormFieldsSize bounds the total size of the fields sent before the
+// file in a POST upload form, as S3's 20 KB limit does
+const maxPostFormFieldsSize = 20 * 1024
+
+// isPostObject reports whether a request is a browser-based POST upload: a
+// multipart/form-data POST to a bucket
+func isPostObject(req *http.Request, bucket, key string) bool {
+	if req.Method != http.MethodPost || bucket == "" || key != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// handlePostObject handles POST /bucket with a multipart/form-data body, the
+// upload form of browser-based uploads. The form carries its own
+// credentials: a base64 policy document and its SigV4 signature, which
+// limit the key, size and other fields of what may be uploaded. Unsigned
+// forms need a bucket policy that lets anyone put the object.
+//
+// The object gets the key field, with ${filename} replaced by the name of
+// the uploaded file, and the Content-Type, Cache-Control,
+// Content-Disposition, Content-Encoding, acl and x-amz-meta-* fields. The
+// response is a 303 to success_action_redirect, or per
+// success_action_status an empty 200, a PostResponse 201 or, by default, an
+// empty 204.
+func (r *Router) handlePostObject(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		r.writeError(w, "PostObject", ErrMalformedPOSTRequest)
+		return
+	}
+	fields, file, s3err := readPostForm(reader)
+	if s3err != nil {
+		r.writeError(w, "PostObject", s3err)
+		return
+	}
+	fields["bucket"] = bucket
+
+	key := fields["key"]
+	if key == "" {
+		r.writeError(w, "PostObject", withMessage(ErrInvalidArgument, "Bucket POST must contain a field named 'key'."))
+		return
+	}
+	key = strings.ReplaceAll(key, "${filename}", file.FileName())
+	fields["key"] = key
+
+	accessKey, err := r.auth.VerifyPostSignature(fields)
+	switch {
+	case errors.Is(err, auth.ErrUnsigned):
+		if !r.bucketPolicyAllowsAnonymous(req, bucket, bucket+"/"+key, "s3:PutObject") ||
+			r.publicAccessBlock(ctx, bucket).RestrictPublicBuckets {
+			r.writeError(w, "PostObject", ErrAccessDenied)
+			return
+		}
+	case err != nil:
+		r.logger.Debugw("invalid POST upload signature", "bucket", bucket, "error", err)
+		r.writeError(w, "PostObject", authError(err))
+		return
+	case accessKey != "" && !r.auth.IsAuthorized(accessKey, bucket+"/"+key, "s3:PutObject"):
+		r.writeError(w, "PostObject", ErrAccessDenied)
+		return
+	}
+
+	var body io.Reader = file
+	if encoded := fields["policy"]; encoded != "" {
+		policy, err := auth.ParsePostPolicy(encoded)
+		if err != nil {
+			r.writeError(w, "PostObject", withMessage(ErrInvalidPolicyDocument, "Invalid Policy: "+err.Error()))
+			return
+		}
+		if err := policy.Check(fields, time.Now()); err != nil {
+			msg := "Invalid according to Policy: " + strings.TrimPrefix(err.Error(), auth.ErrPostPolicy.Error()+": ")
+			if errors.Is(err, auth.ErrPostPolicyExpired) {
+				msg = "Invalid according to Policy: Policy expired."
+			}
+			r.writeError(w, "PostObject", withMessage(ErrAccessDenied, msg))
+			return
+		}
+		if policy.HasLengthRange {
+			body = &lengthRangeReader{r: file, min: policy.MinLength, max: policy.MaxLength}
+		}
+	}
+
+	// The form fields that describe the object are named like the headers
+	// of a PutObject
+	header := make(http.Header, len(fields))
+	for name, value := range fields {
+		header.Set(name, value)
+	}
+	acl, s3err := requestACL(header, nil)
+	if s3err != nil {
+		r.writeError(w, "PostObject", s3err)
+		return
+	}
+	if r.publicACLIgnored(ctx, bucket, acl) {
+		r.logger.Infow("ignoring public ACL", "bucket", bucket)
+		acl = nil
+	}
+
+	result, err := r.engine.PutObject(ctx, bucket, key, body, engine.PutObjectOptions{
+		ContentType:        header.Get("Content-Type"),
+		ContentEncoding:    header.Get("Content-Encoding"),
+		CacheControl:       header.Get("Cache-Control"),
+		ContentDisposition: header.Get("Content-Disposition"),
+		Metadata:           extractUserMetadata(header),
+		ACL:                acl,
+	})
+	if err != nil {
+		r.logger.Warnw("failed to post object", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "PostObject", toS3Error(err))
+		return
+	}
+
+	location := postObjectLocation(req, key)
+	w.Header().Set("ETag", sanitizeHeaderValue(result.ETag))
+	w.Header().Set("Location", location)
+	r.setVersionIDHeader(w, req, bucket, result.VersionID)
+
+	if redirect, err := url.Parse(fields["success_action_redirect"]); err == nil && redirect.IsAbs() {
+		query := redirect.Query()
+		query.Set("bucket", bucket)
+		query.Set("key", key)
+		query.Set("etag", result.ETag)
+		redirect.RawQuery = query.Encode()
+		http.Redirect(w, req, redirect.String(), http.StatusSeeOther)
+		recordOperation(w, "PostObject")
+		return
+	}
+
+	switch fields["success_action_status"] {
+	case "200":
+		w.WriteHeader(http.StatusOK)
+		recordOperation(w, "PostObject")
+	case "201":
+		r.writeXML(w, http.StatusCreated, s3types.PostResponse{
+			Location: location,
+			Bucket:   bucket,
+			Key:      key,
+			ETag:     result.ETag,
+		})
+		recordOperation(w, "PostObject")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		recordOperation(w, "PostObject")
+	}
+}
+
+// lengthRangeReader streams a POST upload's file while enforcing the
+// policy's content-length-range. Reads fail once more than max bytes have
+// been read, and the read that reaches EOF fails if fewer than min were.
+type lengthRangeReader struct {
+	r        io.Reader
+	min, max int64
+	n        int64
+}
+
+// Read reads from the file and checks the bytes read so far
+func (l *lengthRangeReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, fmt.Errorf("%w: POST upload larger than %d bytes", engine.ErrEntityTooLarge, l.max)
+	}
+	if err == io.EOF && l.n < l.min {
+		return n, fmt.Errorf("%w: POST upload smaller than %d bytes", engine.ErrEntityTooSmall, l.min)
+	}
+	return n, err
+}
+
+// readPostForm reads the fields of a POST upload form up to its file, which
+// S3 requires to be the last field. Field names are lower-cased.
+func readPostForm(reader *multipart.Reader) (map[string]string, *multipart.Part, S3Error) {
+	fields := make(map[string]string)
+	remaining := int64(maxPostFormFieldsSize)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, nil, withMessage(ErrInvalidArgument, "POST requires exactly one file upload per request.")
+		}
+		if err != nil {
+			return nil, nil, ErrMalformedPOSTRequest
+		}
+
+		name := strings.ToLower(part.FormName())
+		if name == "file" {
+			return fields, part, nil
+		}
+		value, err := io.ReadAll(io.LimitReader(part, remaining+1))
+		if err != nil {
+			return nil, nil, ErrMalformedPOSTRequest
+		}
+		remaining -= int64(len(value))
+		if remaining < 0 {
+			return nil, nil, withMessage(ErrMaxMessageLengthExceeded, "Your POST form fields exceed the maximum allowed size.")
+		}
+		fields[name] = string(value)
+	}
+}
+
+// postObjectLocation returns the URL of an object uploaded by a POST to the
+// bucket URL of req
+func postObjectLocation(req *http.Request, key string) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: req.Host, Path: strings.TrimSuffix(req.URL.Path, "/") + "/" + key}
+	return u.String()
+}
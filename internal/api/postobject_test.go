@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"go.uber.org/zap"
+)
+
+// postForm builds a POST upload request with fields in order, followed by
+// the file
+func postForm(t *testing.T, target string, fields [][2]string, filename, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, field := range fields {
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			t.Fatalf("WriteField failed: %v", err)
+		}
+	}
+	if filename != "" {
+		fw, err := mw.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("CreateFormFile failed: %v", err)
+		}
+		io.WriteString(fw, content)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("POST", target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// signedPostFields returns the policy and SigV4 fields of a POST upload
+// form signed with secretKey
+func signedPostFields(accessKey, secretKey, policy string) [][2]string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(policy))
+	date := time.Now().UTC().Format("20060102")
+
+	sign := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	signingKey := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, "us-east-1", "s3", "aws4_request"} {
+		signingKey = sign(signingKey, part)
+	}
+
+	return [][2]string{
+		{"policy", encoded},
+		{"x-amz-algorithm", "AWS4-HMAC-SHA256"},
+		{"x-amz-credential", accessKey + "/" + date + "/us-east-1/s3/aws4_request"},
+		{"x-amz-signature", hex.EncodeToString(sign(signingKey, encoded))},
+	}
+}
+
+func uploadPolicy(expiration time.Time, extra string) string {
+	return fmt.Sprintf(`{
+		"expiration": %q,
+		"conditions": [
+			{"bucket": "uploads"},
+			["starts-with", "$key", "user/"],
+			["starts-with", "$success_action_status", ""],
+			["starts-with", "$Content-Type", ""],
+			["starts-with", "$x-amz-algorithm", ""],
+			["starts-with", "$x-amz-credential", ""],
+			["content-length-range", 1, 16]%s
+		]
+	}`, expiration.UTC().Format(time.RFC3339), extra)
+}
+
+func TestRouter_PostObject(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	svc := engine.New(NewMockAPIStorage(), NewMockAPIMetadata(), logger)
+	router := NewRouter(svc, auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"}), logger, &config.Config{})
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "uploads")
+
+	policy := uploadPolicy(time.Now().Add(time.Hour), "")
+	fields := append([][2]string{
+		{"key", "user/${filename}"},
+		{"Content-Type", "text/plain"},
+		{"success_action_status", "201"},
+	}, signedPostFields("test-key", "test-secret", policy)...)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, postForm(t, "/s3/uploads", fields, "note.txt", "hello"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	for _, want := range []string{"<PostResponse>", "<Bucket>uploads</Bucket>", "<Key>user/note.txt</Key>"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("body %s does not contain %s", w.Body.String(), want)
+		}
+	}
+	if w.Header().Get("ETag") == "" || !strings.HasSuffix(w.Header().Get("Location"), "/s3/uploads/user/note.txt") {
+		t.Errorf("headers ETag=%q Location=%q", w.Header().Get("ETag"), w.Header().Get("Location"))
+	}
+
+	result, err := svc.GetObject(ctx, "uploads", "user/note.txt", engine.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject failed: %v", err)
+	}
+	data, _ := io.ReadAll(result.Body)
+	result.Body.Close()
+	if string(data) != "hello" || result.ContentType != "text/plain" {
+		t.Errorf("object = %q (%s), want hello (text/plain)", data, result.ContentType)
+	}
+
+	// Without success_action_status the upload gets an empty 204
+	fields = append([][2]string{{"key", "user/other.txt"}}, signedPostFields("test-key", "test-secret", policy)...)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, postForm(t, "/s3/uploads", fields, "other.txt", "data"))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("status = %d, body %q, want empty 204", w.Code, w.Body.String())
+	}
+}
+
+func TestRouter_PostObject_Rejected(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	svc := engine.New(NewMockAPIStorage(), NewMockAPIMetadata(), logger)
+	router := NewRouter(svc, auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"}), logger, &config.Config{})
+	svc.CreateBucket(context.Background(), "uploads")
+
+	valid := uploadPolicy(time.Now().Add(time.Hour), "")
+	signed := func(key, policy string) [][2]string {
+		return append([][2]string{{"key", key}}, signedPostFields("test-key", "test-secret", policy)...)
+	}
+
+	tests := []struct {
+		name     string
+		fields   [][2]string
+		content  string
+		wantCode int
+		wantErr  string
+	}{
+		{"expired policy", signed("user/a.txt", uploadPolicy(time.Now().Add(-time.Hour), "")), "data", http.StatusForbidden, "Policy expired"},
+		{"key outside prefix", signed("other/a.txt", valid), "data", http.StatusForbidden, "Invalid according to Policy"},
+		{"uncovered field", append(signed("user/a.txt", valid), [2]string{"acl", "public-read"}), "data", http.StatusForbidden, "extra input fields: acl"},
+		{"too large", signed("user/a.txt", valid), strings.Repeat("x", 17), http.StatusBadRequest, "EntityTooLarge"},
+		{"too small", signed("user/a.txt", valid), "", http.StatusBadRequest, "EntityTooSmall"},
+		{"wrong secret", append([][2]string{{"key", "user/a.txt"}}, signedPostFields("test-key", "other", valid)...), "data", http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"unsigned", [][2]string{{"key", "user/a.txt"}}, "data", http.StatusForbidden, "AccessDenied"},
+		{"no key", signedPostFields("test-key", "test-secret", valid), "data", http.StatusBadRequest, "InvalidArgument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, postForm(t, "/s3/uploads", tt.fields, "a.txt", tt.content))
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantErr) {
+				t.Errorf("status = %d, body %s; want %d with %s", w.Code, w.Body.String(), tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+
+	// A form without a file is malformed
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, postForm(t, "/s3/uploads", signed("user/a.txt", valid), "", ""))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("form without file: status = %d, want 400", w.Code)
+	}
+}
+
+// infiniteReader is an endless upload body
+type infiniteReader struct{ read int64 }
+
+func (r *infiniteReader) Read(p []byte) (int, error) {
+	r.read += int64(len(p))
+	return len(p), nil
+}
+
+func TestLengthRangeReader(t *testing.T) {
+	// An oversized upload fails as soon as it passes the maximum rather
+	// than being read to the end first
+	body := &infiniteReader{}
+	_, err := io.Copy(io.Discard, &lengthRangeReader{r: body, min: 1, max: 1 << 20})
+	if !errors.Is(err, engine.ErrEntityTooLarge) {
+		t.Errorf("oversized read error = %v, want %v", err, engine.ErrEntityTooLarge)
+	}
+	if body.read > 2<<20 {
+		t.Errorf("read %d bytes of an upload limited to 1 MiB", body.read)
+	}
+
+	tests := []struct {
+		data    string
+		wantErr error
+	}{
+		{"", engine.ErrEntityTooSmall},
+		{"a", nil},
+		{"0123456789abcdef", nil},
+		{"0123456789abcdefg", engine.ErrEntityTooLarge},
+	}
+	for _, tt := range tests {
+		data, err := io.ReadAll(&lengthRangeReader{r: strings.NewReader(tt.data), min: 1, max: 16})
+		if !errors.Is(err, tt.wantErr) || (err == nil && string(data) != tt.data) {
+			t.Errorf("ReadAll(%q) = %q, %v; want error %v", tt.data, data, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/iam"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// publicAccessBlock returns a bucket's public access block settings, all
+// off when none are configured
+func (r *Router) publicAccessBlock(ctx context.Context, bucket string) metadata.PublicAccessBlockConfiguration {
+	config, err := r.engine.GetPublicAccessBlock(ctx, bucket)
+	if err != nil || config == nil {
+		return metadata.PublicAccessBlockConfiguration{}
+	}
+	return *config
+}
+
+// restrictedPublicAccess reports whether a request is anonymous and the
+// bucket restricts its objects to authenticated requests. Requests whose
+// signature or client certificate Authenticate verified, and presigned
+// requests ServeHTTP verified, are not anonymous.
+func (r *Router) restrictedPublicAccess(req *http.Request, bucket string, presigned bool) bool {
+	if _, ok := auth.AuthenticatedAccessKey(req); ok || presigned {
+		return false
+	}
+	return r.publicAccessBlock(req.Context(), bucket).RestrictPublicBuckets
+}
+
+// publicACLIgnored reports whether an ACL grants public access that the
+// bucket's public access block blocks or ignores, so it is to be accepted
+// without taking effect
+func (r *Router) publicACLIgnored(ctx context.Context, bucket string, acl *metadata.AccessControlList) bool {
+	block := r.publicAccessBlock(ctx, bucket)
+	if !block.BlockPublicAcls && !block.IgnorePublicAcls {
+		return false
+	}
+	return aclIsPublic(acl)
+}
+
+// aclIsPublic reports whether any grant of an ACL is public
+func aclIsPublic(acl *metadata.AccessControlList) bool {
+	if acl == nil {
+		return false
+	}
+	for _, grant := range acl.Grants {
+		if iam.GranteeIsPublic(iam.Grantee{Type: grant.Grantee.Type, URI: grant.Grantee.URI}) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/storage"
+)
+
+// parseByteRange resolves a Range header of the form "bytes=start-end",
+// "bytes=start-" or "bytes=-suffix" against an object of the given size.
+// The returned range has an exclusive End. It reports false for malformed
+// or unsatisfiable ranges; only a single range is supported, as in S3.
+func parseByteRange(header string, size int64) (*storage.Range, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, false
+	}
+
+	// Suffix range: the last n bytes
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return nil, false
+		}
+		if n > size {
+			n = size
+		}
+		return &storage.Range{Start: size - n, End: size}, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return nil, false
+	}
+	end := size
+	if last != "" {
+		inclusiveEnd, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || inclusiveEnd < start {
+			return nil, false
+		}
+		if inclusiveEnd < size-1 {
+			end = inclusiveEnd + 1
+		}
+	}
+	return &storage.Range{Start: start, End: end}, true
+}
+
+// parseCopySourceRange parses an x-amz-copy-source-range header, which
+// unlike Range must name both the first and last byte: "bytes=first-last".
+// The returned range has an exclusive End; whether it fits in the source is
+// checked once the source's size is known.
+func parseCopySourceRange(header string) (*storage.Range, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return nil, false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, false
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return nil, false
+	}
+	return &storage.Range{Start: start, End: end + 1}, true
+}
@@ -0,0 +1,64 @@
+package api
+
+import "testing"
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		size       int64
+		ok         bool
+		start, end int64
+	}{
+		{"bytes=0-0", 10, true, 0, 1},
+		{"bytes=0-9", 10, true, 0, 10},
+		{"bytes=3-", 10, true, 3, 10},
+		{"bytes=-1", 10, true, 9, 10},
+		{"bytes= 1-2", 10, true, 1, 3},
+		{"bytes=-0", 10, false, 0, 0},
+		{"bytes=-5", 0, false, 0, 0},
+		{"bytes=0-", 0, false, 0, 0},
+		{"bytes=0-1,4-5", 10, false, 0, 0},
+		{"bytes=a-b", 10, false, 0, 0},
+		{"bytes=-", 10, false, 0, 0},
+		{"bytes=5", 10, false, 0, 0},
+		{"0-5", 10, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		rng, ok := parseByteRange(tt.header, tt.size)
+		if ok != tt.ok {
+			t.Errorf("parseByteRange(%q, %d) ok = %v, want %v", tt.header, tt.size, ok, tt.ok)
+			continue
+		}
+		if ok && (rng.Start != tt.start || rng.End != tt.end) {
+			t.Errorf("parseByteRange(%q, %d) = [%d, %d), want [%d, %d)", tt.header, tt.size, rng.Start, rng.End, tt.start, tt.end)
+		}
+	}
+}
+
+func TestParseCopySourceRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		ok         bool
+		start, end int64
+	}{
+		{"bytes=0-0", true, 0, 1},
+		{"bytes=5-9", true, 5, 10},
+		{"bytes=5-", false, 0, 0},
+		{"bytes=-5", false, 0, 0},
+		{"bytes=9-5", false, 0, 0},
+		{"bytes=a-b", false, 0, 0},
+		{"0-5", false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		rng, ok := parseCopySourceRange(tt.header)
+		if ok != tt.ok {
+			t.Errorf("parseCopySourceRange(%q) ok = %v, want %v", tt.header, ok, tt.ok)
+			continue
+		}
+		if ok && (rng.Start != tt.start || rng.End != tt.end) {
+			t.Errorf("parseCopySourceRange(%q) = [%d, %d), want [%d, %d)", tt.header, rng.Start, rng.End, tt.start, tt.end)
+		}
+	}
+}
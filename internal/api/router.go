@@ -1,12 +1,19 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,8 +21,11 @@ import (
 	"github.com/openendpoint/openendpoint/internal/auth"
 	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/iam"
+	"github.com/openendpoint/openendpoint/internal/lifecycle"
 	"github.com/openendpoint/openendpoint/internal/metadata"
 	s3select "github.com/openendpoint/openendpoint/internal/s3select"
+	"github.com/openendpoint/openendpoint/internal/storage"
 	"github.com/openendpoint/openendpoint/internal/tags"
 	s3types "github.com/openendpoint/openendpoint/pkg/s3types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -33,23 +43,51 @@ type Router struct {
 	logger        *zap.SugaredLogger
 	config        *config.Config
 	selectService *s3select.SelectService
+	contentTypes  map[string]string // extension -> content type overrides
+	bucketLabels  *bucketLabeler    // nil leaves the bucket label empty
 }
 
-// s3RequestsTotal is a metric for tracking S3 API requests
+// s3RequestsTotal is a metric for tracking S3 API requests. The status label
+// is the status the response was written with, and the code label carries
+// the S3 error code for failed requests and is empty on success. The bucket
+// label is only set when enabled in the metrics configuration.
 var s3RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Name: "openendpoint_s3_requests_total",
 	Help: "Total number of S3 API requests",
-}, []string{"operation", "status"})
+}, []string{"operation", "status", "code", "bucket"})
+
+// s3RequestDuration is a metric for the time taken to serve S3 API requests
+var s3RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "openendpoint_s3_request_duration_seconds",
+	Help:    "Duration of S3 API requests by operation",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation"})
+
+// Collectors returns the S3 API's metrics, for registering with the
+// registry the server exposes
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{s3RequestsTotal, s3RequestDuration}
+}
 
 // NewRouter creates a new S3 API router
 func NewRouter(engine *engine.ObjectService, auth *auth.Auth, logger *zap.SugaredLogger, cfg *config.Config) *Router {
 	selectLogger, _ := zap.NewProduction()
+	var contentTypes map[string]string
+	var bucketLabels *bucketLabeler
+	if cfg != nil {
+		contentTypes = cfg.ContentTypes
+		if cfg.Metrics.BucketLabel {
+			bucketLabels = newBucketLabeler(cfg.Metrics.MaxBucketLabels)
+		}
+	}
 	return &Router{
 		engine:        engine,
 		auth:          auth,
 		logger:        logger,
 		config:        cfg,
 		selectService: s3select.NewSelectService(selectLogger),
+		contentTypes:  newContentTypeOverrides(contentTypes),
+		bucketLabels:  bucketLabels,
 	}
 }
 
@@ -80,15 +118,183 @@ func sanitizeHeaderValue(value string) string {
 	return result.String()
 }
 
+// userMetadataPrefix is the header prefix for user-defined object metadata
+const userMetadataPrefix = "x-amz-meta-"
+
+// extractUserMetadata collects x-amz-meta-* request headers. net/http has
+// already canonicalized the header names, so the original case is lost; as
+// in S3, names are stored lowercased.
+func extractUserMetadata(h http.Header) map[string]string {
+	var meta map[string]string
+	for name, values := range h {
+		if len(name) <= len(userMetadataPrefix) || !strings.EqualFold(name[:len(userMetadataPrefix)], userMetadataPrefix) {
+			continue
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[strings.ToLower(name[len(userMetadataPrefix):])] = strings.Join(values, ",")
+	}
+	return meta
+}
+
+// setUserMetadataHeaders writes user metadata as x-amz-meta-* response headers.
+// Names are assigned directly into the header map so they go out lowercase,
+// as S3 sends them, rather than canonicalized, and are visited in sorted
+// order so output is stable.
+func setUserMetadataHeaders(w http.ResponseWriter, meta map[string]string) {
+	if len(meta) == 0 {
+		return
+	}
+	names := make([]string, 0, len(meta))
+	for name := range meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := w.Header()
+	for _, name := range names {
+		h[userMetadataPrefix+sanitizeHeaderValue(name)] = []string{sanitizeHeaderValue(meta[name])}
+	}
+}
+
+// objectHeaders holds the stored object metadata GetObject and HeadObject
+// return as response headers
+type objectHeaders struct {
+	LastModified       int64
+	VersionID          string
+	StorageClass       string
+	ReplicationStatus  string
+	CacheControl       string
+	ContentDisposition string
+	Metadata           map[string]string
+
+	// ServerSideEncryption is the algorithm the object is stored with
+	ServerSideEncryption string
+	// Checksum is the object's additional checksum; GetObject leaves it out
+	// of ranged reads, which it does not cover
+	Checksum *metadata.ObjectChecksum
+	// Restore is the x-amz-restore status of a restored archived object
+	Restore string
+}
+
+// setObjectHeaders writes an object's stored metadata as response headers.
+// Empty values are left out, as is the default STANDARD storage class.
+func setObjectHeaders(w http.ResponseWriter, h objectHeaders) {
+	if h.LastModified != 0 {
+		w.Header().Set("Last-Modified", time.Unix(h.LastModified, 0).UTC().Format(http.TimeFormat))
+	}
+	if h.VersionID != "" {
+		w.Header().Set("x-amz-version-id", sanitizeHeaderValue(h.VersionID))
+	}
+	if h.StorageClass != "" && h.StorageClass != "STANDARD" {
+		w.Header().Set("x-amz-storage-class", sanitizeHeaderValue(h.StorageClass))
+	}
+	if h.ReplicationStatus != "" {
+		w.Header().Set("x-amz-replication-status", h.ReplicationStatus)
+	}
+	if h.Restore != "" {
+		w.Header().Set("x-amz-restore", h.Restore)
+	}
+	setEncryptionHeader(w, h.ServerSideEncryption)
+	setChecksumHeaders(w, h.Checksum)
+	if h.CacheControl != "" {
+		w.Header().Set("Cache-Control", sanitizeHeaderValue(h.CacheControl))
+	}
+	if h.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", sanitizeHeaderValue(h.ContentDisposition))
+	}
+	setUserMetadataHeaders(w, h.Metadata)
+}
+
+// headerServerSideEncryption asks for an object to be stored encrypted, and
+// tells which encryption a stored object has
+const headerServerSideEncryption = "x-amz-server-side-encryption"
+
+// setEncryptionHeader reports the server-side encryption of an object,
+// unless it is stored unencrypted
+func setEncryptionHeader(w http.ResponseWriter, algorithm string) {
+	if algorithm != "" {
+		w.Header().Set(headerServerSideEncryption, algorithm)
+	}
+}
+
+// setResponseOverrides applies the response-* query parameters of a signed
+// request, such as response-content-disposition, over the object's headers.
+// Anonymous requests cannot override headers.
+func setResponseOverrides(w http.ResponseWriter, req *http.Request) {
+	if auth.RequestAccessKey(req) == "" {
+		return
+	}
+	query := req.URL.Query()
+	for param, header := range engine.ResponseOverrides {
+		if value := query.Get(param); value != "" {
+			w.Header().Set(header, sanitizeHeaderValue(value))
+		}
+	}
+}
+
+// storageClassFromRequest returns the class requested via x-amz-storage-class.
+// An absent header leaves the class empty so the default applies.
+func storageClassFromRequest(req *http.Request) (string, S3Error) {
+	class := req.Header.Get("x-amz-storage-class")
+	if class == "" {
+		return "", nil
+	}
+	if _, ok := lifecycle.StorageClasses[class]; !ok {
+		return "", ErrInvalidStorageClass
+	}
+	return class, nil
+}
+
+// objectLockFromRequest returns the retention and legal hold requested via
+// the x-amz-object-lock headers, or nil for those not set. A retention needs
+// both its mode and its retain-until date.
+func objectLockFromRequest(req *http.Request) (*metadata.ObjectRetention, *metadata.ObjectLegalHold, S3Error) {
+	var retention *metadata.ObjectRetention
+	mode := req.Header.Get("x-amz-object-lock-mode")
+	until := req.Header.Get("x-amz-object-lock-retain-until-date")
+	if mode != "" || until != "" {
+		if mode == "" || until == "" {
+			return nil, nil, ErrInvalidArgument
+		}
+		date, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, nil, ErrInvalidArgument
+		}
+		retention = &metadata.ObjectRetention{Mode: mode, RetainUntilDate: date.Unix()}
+	}
+
+	var legalHold *metadata.ObjectLegalHold
+	if status := req.Header.Get("x-amz-object-lock-legal-hold"); status != "" {
+		legalHold = &metadata.ObjectLegalHold{Status: status}
+	}
+	return retention, legalHold, nil
+}
+
 // ServeHTTP handles S3 API requests
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Each request is counted once, with the status it is answered with
+	rec := newMetricsRecorder(w, req)
+	defer r.recordRequest(rec)
+	w = rec
+
+	// Verify the signature before the streaming body decoder rewrites the
+	// headers it covers. This is a no-op behind auth.Middleware.
+	req = r.auth.Authenticate(req)
+
 	// Check for presigned URL query parameters
-	if req.URL.Query().Get("X-Amz-Signature") != "" {
+	presigned := req.URL.Query().Get("X-Amz-Signature") != ""
+	if presigned {
 		// Verify presigned URL
 		bucket, key, err := r.auth.VerifyPresignedURL(req)
 		if err != nil {
 			r.logger.Warnw("invalid presigned URL", "error", err)
-			r.writeError(w, ErrSignatureDoesNotMatch)
+			if errors.Is(err, auth.ErrPresignedURLExpired) {
+				r.writeError(w, requestOperation(req), ErrPresignedURLExpired)
+			} else {
+				r.writeError(w, requestOperation(req), ErrSignatureDoesNotMatch)
+			}
 			return
 		}
 
@@ -100,16 +306,89 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// Strip aws-chunked framing so handlers read the object bytes
+	if err := r.auth.DecodeStreamingBody(req); err != nil {
+		r.logger.Warnw("invalid streaming upload", "error", err)
+		if s3err := streamingBodyError(err); s3err != nil {
+			r.writeError(w, requestOperation(req), s3err)
+		} else {
+			r.writeError(w, requestOperation(req), ErrAccessDenied)
+		}
+		return
+	}
+
 	// Route request
-	r.route(w, req)
+	r.route(w, req, presigned)
+}
+
+// defaultBucketOwner owns buckets whose metadata records no owner
+const defaultBucketOwner = "root"
+
+// bucketOwner returns the owner of a bucket
+func bucketOwner(ctx context.Context, svc *engine.ObjectService, bucket string) (string, error) {
+	meta, err := svc.GetBucket(ctx, bucket)
+	if err != nil {
+		return "", err
+	}
+	if meta.Owner == "" {
+		return defaultBucketOwner, nil
+	}
+	return meta.Owner, nil
+}
+
+// expectedBucketOwnerMatches checks the x-amz-expected-bucket-owner header,
+// if the request sent one, against the bucket's recorded owner. A bucket
+// that does not exist is left for the handler to report.
+func (r *Router) expectedBucketOwnerMatches(req *http.Request, bucket string) bool {
+	expected := req.Header.Get("x-amz-expected-bucket-owner")
+	if expected == "" {
+		return true
+	}
+	owner, err := bucketOwner(req.Context(), r.engine, bucket)
+	if err != nil {
+		return true
+	}
+	return owner == expected
 }
 
-// route routes the request to the appropriate handler
-func (r *Router) route(w http.ResponseWriter, req *http.Request) {
+// route routes the request to the appropriate handler. presigned requests
+// have had their presigned URL verified.
+func (r *Router) route(w http.ResponseWriter, req *http.Request, presigned bool) {
 	// Get bucket and key from path
 	bucket, key, err := parseBucketKey(req, req.URL.Path)
 	if err != nil {
-		r.writeError(w, ErrInvalidURI)
+		r.writeError(w, requestOperation(req), ErrInvalidURI)
+		return
+	}
+
+	// CORS preflights are sent unsigned and answered from the bucket's
+	// CORS rules alone
+	if req.Method == http.MethodOptions {
+		r.handlePreflight(w, req, bucket)
+		return
+	}
+	// Cross-origin responses, errors included, carry the headers of the
+	// matching CORS rule so the browser lets the page read them
+	r.applyCORS(w, req, bucket)
+
+	// Browser-based uploads carry their credentials in the form
+	if isPostObject(req, bucket, key) {
+		r.handlePostObject(w, req, bucket)
+		return
+	}
+
+	if s3err := r.authorizeRequest(req, bucket, key, presigned); s3err != nil {
+		r.writeError(w, requestOperation(req), s3err)
+		return
+	}
+
+	if bucket != "" && !r.expectedBucketOwnerMatches(req, bucket) {
+		r.writeError(w, requestOperation(req), ErrAccessDenied)
+		return
+	}
+
+	if bucket != "" && key != "" && r.restrictedPublicAccess(req, bucket, presigned) {
+		r.writeError(w, requestOperation(req), ErrAccessDenied)
 		return
 	}
 
@@ -125,7 +404,11 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 			switch req.Method {
 			case http.MethodPut:
 				if req.URL.Query().Get("partNumber") != "" {
-					r.handleUploadPart(w, req, bucket, key)
+					if req.Header.Get("x-amz-copy-source") != "" {
+						r.handleUploadPartCopy(w, req, bucket, key)
+					} else {
+						r.handleUploadPart(w, req, bucket, key)
+					}
 					return
 				}
 			case http.MethodPost:
@@ -184,9 +467,10 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 				r.handleGetBucketOwnershipControls(w, req, bucket)
 			} else if req.URL.Query().Get("metrics") != "" {
 				r.handleGetBucketMetrics(w, req, bucket)
-			} else if req.URL.Query().Get("acl") != "" {
+			} else if req.URL.Query().Has("acl") {
 				r.handleGetBucketAcl(w, req, bucket)
-			} else if req.URL.Query().Get("versions") != "" {
+			} else if req.URL.Query().Has("versions") {
+				// SDKs send ?versions with no value
 				r.handleListObjectVersions(w, req, bucket)
 			} else {
 				r.handleListObjects(w, req, bucket)
@@ -195,7 +479,7 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 			// Check for query string operations on object
 			if req.URL.Query().Get("presignedurl") != "" {
 				r.handleGetPresignedURL(w, req, bucket, key)
-			} else if req.URL.Query().Get("acl") != "" {
+			} else if req.URL.Query().Has("acl") {
 				r.handleGetObjectAcl(w, req, bucket, key)
 			} else if req.URL.Query().Get("tagging") != "" {
 				r.handleGetObjectTags(w, req, bucket, key)
@@ -203,13 +487,16 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 				r.handleGetObjectRetention(w, req, bucket, key)
 			} else if req.URL.Query().Get("legal-hold") != "" {
 				r.handleGetObjectLegalHold(w, req, bucket, key)
+			} else if req.URL.Query().Has("attributes") {
+				// SDKs send ?attributes with no value
+				r.handleGetObjectAttributes(w, req, bucket, key)
 			} else {
 				r.handleGetObject(w, req, bucket, key)
 			}
 		}
 	case http.MethodPut:
 		if bucket == "" {
-			r.writeError(w, ErrInvalidBucketName)
+			r.writeError(w, "CreateBucket", ErrInvalidBucketName)
 		} else if key == "" {
 			// Check for query string operations on bucket
 			if req.URL.Query().Get("versioning") != "" {
@@ -248,7 +535,7 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 				r.handlePutBucketOwnershipControls(w, req, bucket)
 			} else if req.URL.Query().Get("metrics") != "" {
 				r.handlePutBucketMetrics(w, req, bucket)
-			} else if req.URL.Query().Get("acl") != "" {
+			} else if req.URL.Query().Has("acl") {
 				r.handlePutBucketAcl(w, req, bucket)
 			} else {
 				r.handleCreateBucket(w, req, bucket)
@@ -257,7 +544,7 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 			// Check for query string operations on object
 			if req.URL.Query().Get("presignedurl") != "" {
 				r.handlePutPresignedURL(w, req, bucket, key)
-			} else if req.URL.Query().Get("acl") != "" {
+			} else if req.URL.Query().Has("acl") {
 				r.handlePutObjectAcl(w, req, bucket, key)
 			} else if req.URL.Query().Get("tagging") != "" {
 				r.handlePutObjectTags(w, req, bucket, key)
@@ -267,6 +554,8 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 				r.handlePutObjectLegalHold(w, req, bucket, key)
 			} else if req.Header.Get("x-amz-copy-source") != "" {
 				r.handleCopyObject(w, req, bucket, key)
+			} else if req.Header.Get(headerUploadToken) != "" {
+				r.handleResumablePutObject(w, req, bucket, key)
 			} else {
 				r.handlePutObject(w, req, bucket, key)
 			}
@@ -305,7 +594,7 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 				r.handleDeleteBucketOwnershipControls(w, req, bucket)
 			} else if req.URL.Query().Get("metrics") != "" {
 				r.handleDeleteBucketMetrics(w, req, bucket)
-			} else if req.URL.Query().Get("acl") != "" {
+			} else if req.URL.Query().Has("acl") {
 				r.handleDeleteBucketAcl(w, req, bucket)
 			} else {
 				r.handleDeleteBucket(w, req, bucket)
@@ -324,7 +613,7 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 		} else if bucket != "" {
 			r.handleHeadBucket(w, req, bucket)
 		} else {
-			r.writeError(w, ErrNotImplemented)
+			r.writeError(w, "HeadBucket", ErrNotImplemented)
 		}
 	case http.MethodPost:
 		// Handle post to bucket/key (S3 Select)
@@ -333,10 +622,15 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 		// Handle post to bucket/key (Restore Object)
-		if bucket != "" && key != "" && req.URL.Query().Get("restore") != "" {
+		if bucket != "" && key != "" && req.URL.Query().Has("restore") {
 			r.handleRestoreObject(w, req, bucket, key)
 			return
 		}
+		// Handle post to bucket/key (Rename Object extension)
+		if bucket != "" && key != "" && req.URL.Query().Get("rename") != "" {
+			r.handleRenameObject(w, req, bucket, key)
+			return
+		}
 		// Handle post to bucket
 		if bucket != "" && key == "" {
 			// Check for query string operations
@@ -348,12 +642,75 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request) {
 			r.handleListMultipartUploads(w, req, bucket)
 			return
 		}
-		r.writeError(w, ErrNotImplemented)
+		r.writeError(w, "PostObject", ErrNotImplemented)
 	default:
-		r.writeError(w, ErrMethodNotAllowed)
+		r.writeError(w, requestOperation(req), ErrMethodNotAllowed)
 	}
 }
 
+// requestOperation names the operation of a request that fails before it
+// reaches its handler, from its method and whether it addresses the service,
+// a bucket or an object. Unsupported methods are named by the method.
+func requestOperation(req *http.Request) string {
+	bucket, key, _ := parseBucketKey(req, req.URL.Path)
+	query := req.URL.Query()
+	_, hasUploads := query["uploads"]
+	multipart := query.Get("uploadId") != ""
+
+	switch req.Method {
+	case http.MethodGet:
+		switch {
+		case bucket == "":
+			return "ListBuckets"
+		case key == "":
+			return "ListObjects"
+		case multipart:
+			return "ListParts"
+		}
+		return "GetObject"
+	case http.MethodHead:
+		if key == "" {
+			return "HeadBucket"
+		}
+		return "HeadObject"
+	case http.MethodPut:
+		switch {
+		case key == "":
+			return "CreateBucket"
+		case multipart && req.Header.Get("x-amz-copy-source") != "":
+			return "UploadPartCopy"
+		case multipart:
+			return "UploadPart"
+		case req.Header.Get("x-amz-copy-source") != "":
+			return "CopyObject"
+		}
+		return "PutObject"
+	case http.MethodPost:
+		switch {
+		case key != "" && hasUploads:
+			return "CreateMultipartUpload"
+		case key != "" && multipart:
+			return "CompleteMultipartUpload"
+		case key == "" && query.Get("delete") != "":
+			return "DeleteObjects"
+		case key != "" && query.Get("rename") != "":
+			return "RenameObject"
+		}
+		return "PostObject"
+	case http.MethodDelete:
+		switch {
+		case key == "":
+			return "DeleteBucket"
+		case multipart:
+			return "AbortMultipartUpload"
+		}
+		return "DeleteObject"
+	case http.MethodOptions:
+		return "PreflightRequest"
+	}
+	return req.Method
+}
+
 // parseBucketKey parses the bucket and key from the request path
 func parseBucketKey(req *http.Request, path string) (bucket, key string, err error) {
 	// Handle S3 API path format
@@ -396,10 +753,11 @@ func findByteIndex(s string, b byte) int {
 	return -1
 }
 
-// writeError writes an error response
-func (r *Router) writeError(w http.ResponseWriter, err S3Error) {
+// writeError writes an error response and records it against the operation
+func (r *Router) writeError(w http.ResponseWriter, operation string, err S3Error) {
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(err.StatusCode())
+	recordError(w, operation, err)
 
 	resp := s3types.Error{
 		Code:      err.Code(),
@@ -424,7 +782,7 @@ func (r *Router) handleListBuckets(w http.ResponseWriter, req *http.Request) {
 	buckets, err := r.engine.ListBuckets(ctx)
 	if err != nil {
 		r.logger.Warnw("failed to list buckets", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "ListBuckets", toS3Error(err))
 		return
 	}
 
@@ -448,7 +806,7 @@ func (r *Router) handleListBuckets(w http.ResponseWriter, req *http.Request) {
 	}
 
 	r.writeXML(w, http.StatusOK, result)
-	s3RequestsTotal.WithLabelValues("ListBuckets", "200").Inc()
+	recordOperation(w, "ListBuckets")
 }
 
 // handleListObjects handles ListObjects
@@ -458,15 +816,26 @@ func (r *Router) handleListObjects(w http.ResponseWriter, req *http.Request, buc
 	prefix := req.URL.Query().Get("prefix")
 	delimiter := req.URL.Query().Get("delimiter")
 	maxKeys := parseInt(req.URL.Query().Get("max-keys"), 1000)
+	startAfter := req.URL.Query().Get("start-after")
+
+	// Continuation tokens are the base64 of the last key of the previous page
+	continuationToken := req.URL.Query().Get("continuation-token")
+	marker, err := base64.StdEncoding.DecodeString(continuationToken)
+	if err != nil {
+		r.writeError(w, "ListObjects", withMessage(ErrInvalidArgument, "The continuation token provided is incorrect"))
+		return
+	}
 
 	result, err := r.engine.ListObjects(ctx, bucket, engine.ListObjectsOptions{
-		Prefix:    prefix,
-		Delimiter: delimiter,
-		MaxKeys:   maxKeys,
+		Prefix:     prefix,
+		Delimiter:  delimiter,
+		MaxKeys:    maxKeys,
+		Marker:     string(marker),
+		StartAfter: startAfter,
 	})
 	if err != nil {
 		r.logger.Warnw("failed to list objects", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.writeError(w, "ListObjects", toS3Error(err))
 		return
 	}
 
@@ -487,60 +856,72 @@ func (r *Router) handleListObjects(w http.ResponseWriter, req *http.Request, buc
 	}
 
 	xmlResult := s3types.ListObjectsV2Output{
-		Name:                  bucket,
-		Prefix:                prefix,
-		Delimiter:             delimiter,
-		MaxKeys:               fmt.Sprintf("%d", maxKeys),
-		KeyCount:              fmt.Sprintf("%d", len(result.Objects)),
-		IsTruncated:           result.IsTruncated,
-		Contents:              contents,
-		CommonPrefixes:        result.CommonPrefixes,
-		NextContinuationToken: result.NextMarker,
+		Name:              bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		MaxKeys:           fmt.Sprintf("%d", maxKeys),
+		KeyCount:          fmt.Sprintf("%d", len(result.Objects)+len(result.CommonPrefixes)),
+		IsTruncated:       result.IsTruncated,
+		Contents:          contents,
+		CommonPrefixes:    result.CommonPrefixes,
+		ContinuationToken: continuationToken,
+		StartAfter:        startAfter,
+	}
+	if result.IsTruncated {
+		xmlResult.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(result.NextMarker))
 	}
 
 	r.writeXML(w, http.StatusOK, xmlResult)
-	s3RequestsTotal.WithLabelValues("ListObjects", "200").Inc()
+	recordOperation(w, "ListObjects")
 }
 
 // handleListObjectVersions handles ListObjectVersions (GET /bucket?versions)
 func (r *Router) handleListObjectVersions(w http.ResponseWriter, req *http.Request, bucket string) {
 	ctx := req.Context()
 
-	prefix := req.URL.Query().Get("prefix")
-	delimiter := req.URL.Query().Get("delimiter")
-	maxKeys := parseInt(req.URL.Query().Get("max-keys"), 1000)
+	query := req.URL.Query()
+	prefix := query.Get("prefix")
+	maxKeys := parseInt(query.Get("max-keys"), 1000)
+	keyMarker := query.Get("key-marker")
+	versionIDMarker := query.Get("version-id-marker")
+	if versionIDMarker != "" && keyMarker == "" {
+		r.writeError(w, "ListObjectVersions", withMessage(ErrInvalidArgument, "A version-id marker cannot be specified without a key marker."))
+		return
+	}
 
-	objects, err := r.engine.ListObjects(ctx, bucket, engine.ListObjectsOptions{
-		Prefix:    prefix,
-		Delimiter: delimiter,
-		MaxKeys:   maxKeys,
+	result, err := r.engine.ListObjectVersions(ctx, bucket, engine.ListObjectVersionsOptions{
+		Prefix:          prefix,
+		MaxKeys:         maxKeys,
+		KeyMarker:       keyMarker,
+		VersionIDMarker: versionIDMarker,
 	})
 	if err != nil {
 		r.logger.Warnw("failed to list object versions", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "ListObjectVersions", toS3Error(err))
 		return
 	}
 
-	// Build XML response - same as ListObjects for now
-	// In a full implementation, this would include version IDs
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
-	var xmlResult string
-	if len(objects.Objects) == 0 {
-		xmlResult = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
-  <Name>%s</Name>
-  <Prefix>%s</Prefix>
-  <KeyMarker></KeyMarker>
-  <VersionIdMarker></VersionIdMarker>
-  <MaxKeys>%d</MaxKeys>
-  <IsTruncated>false</IsTruncated>
-</ListVersionsResult>`, bucket, prefix, maxKeys)
-	} else {
-		var contents string
-		for _, obj := range objects.Objects {
-			contents += fmt.Sprintf(`<Version>
+	// Versions and delete markers are interleaved in the order the engine
+	// returns them, so a key's entries stay together newest first
+	var contents strings.Builder
+	for _, v := range result.Versions {
+		lastModified := time.Unix(v.LastModified, 0).UTC().Format(time.RFC3339)
+		if v.IsDeleteMarker {
+			fmt.Fprintf(&contents, `
+  <DeleteMarker>
+    <Key>%s</Key>
+    <VersionId>%s</VersionId>
+    <IsLatest>%v</IsLatest>
+    <LastModified>%s</LastModified>
+  </DeleteMarker>`,
+				tags.EscapeXML(v.Key),
+				v.VersionID,
+				v.IsLatest,
+				lastModified)
+			continue
+		}
+		fmt.Fprintf(&contents, `
+  <Version>
     <Key>%s</Key>
     <VersionId>%s</VersionId>
     <IsLatest>%v</IsLatest>
@@ -548,58 +929,185 @@ func (r *Router) handleListObjectVersions(w http.ResponseWriter, req *http.Reque
     <ETag>%s</ETag>
     <Size>%d</Size>
   </Version>`,
-				tags.EscapeXML(obj.Key),
-				obj.VersionID,
-				obj.IsLatest,
-				time.Unix(obj.LastModified, 0).Format(time.RFC3339),
-				obj.ETag,
-				obj.Size)
-		}
-		xmlResult = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+			tags.EscapeXML(v.Key),
+			v.VersionID,
+			v.IsLatest,
+			lastModified,
+			v.ETag,
+			v.Size)
+	}
+
+	var next string
+	if result.IsTruncated {
+		next = fmt.Sprintf(`
+  <NextKeyMarker>%s</NextKeyMarker>
+  <NextVersionIdMarker>%s</NextVersionIdMarker>`, tags.EscapeXML(result.NextKeyMarker), tags.EscapeXML(result.NextVersionIDMarker))
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
 <ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
   <Name>%s</Name>
   <Prefix>%s</Prefix>
-  <KeyMarker></KeyMarker>
-  <VersionIdMarker></VersionIdMarker>
+  <KeyMarker>%s</KeyMarker>
+  <VersionIdMarker>%s</VersionIdMarker>
   <MaxKeys>%d</MaxKeys>
-  <IsTruncated>false</IsTruncated>
-  %s
-</ListVersionsResult>`, bucket, prefix, maxKeys, contents)
-	}
-
-	w.Write([]byte(xmlResult))
-	s3RequestsTotal.WithLabelValues("ListObjectVersions", "200").Inc()
+  <IsTruncated>%v</IsTruncated>%s%s
+</ListVersionsResult>`, tags.EscapeXML(bucket), tags.EscapeXML(prefix), tags.EscapeXML(keyMarker), tags.EscapeXML(versionIDMarker),
+		maxKeys, result.IsTruncated, next, contents.String())
+	recordOperation(w, "ListObjectVersions")
 }
 
 // handleGetObject handles GetObject
 func (r *Router) handleGetObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	obj, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
+	// A range is resolved against the object size before the read
+	var objRange *storage.Range
+	if header := req.Header.Get("Range"); header != "" {
+		head, err := r.engine.HeadObject(ctx, bucket, key)
+		if err != nil {
+			r.logger.Warnw("failed to get object", "bucket", bucket, "key", key, "error", err)
+			r.writeError(w, "GetObject", toS3Error(err))
+			return
+		}
+		rng, ok := parseByteRange(header, head.Size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", head.Size))
+			r.writeError(w, "GetObject", ErrInvalidRange)
+			return
+		}
+		objRange = rng
+	}
+
+	opts := engine.GetObjectOptions{
+		VersionID:         req.URL.Query().Get("versionId"),
+		Range:             objRange,
+		IfMatch:           req.Header.Get("If-Match"),
+		IfNoneMatch:       req.Header.Get("If-None-Match"),
+		IfModifiedSince:   req.Header.Get("If-Modified-Since"),
+		IfUnmodifiedSince: req.Header.Get("If-Unmodified-Since"),
+		VerifyIntegrity:   req.Header.Get(headerVerifyIntegrity) == "true",
+	}
+	obj, err := r.engine.GetObject(ctx, bucket, key, opts)
+	if errors.Is(err, engine.ErrNotModified) {
+		// A 304 carries no body, so it is not sent as an S3 error document
+		w.WriteHeader(http.StatusNotModified)
+		recordOperation(w, "GetObject")
+		return
+	}
 	if err != nil {
 		r.logger.Warnw("failed to get object", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+		r.writeError(w, "GetObject", toS3Error(err))
 		return
 	}
 	defer obj.Body.Close()
 
+	// Bytes are counted as downloaded as they are streamed
+	body := io.Reader(downloadCounter{obj.Body})
+	length := obj.Size
+	if objRange != nil {
+		length = objRange.End - objRange.Start
+	}
+
+	// An integrity failure is only found at the end of the data. Reading
+	// one buffer ahead lets it be reported as an error for objects that fit
+	// in the buffer; larger objects stream and abort the connection instead.
+	if obj.Verified {
+		buffered := bufio.NewReaderSize(body, verifyReadAhead)
+		if _, err := buffered.Peek(verifyReadAhead); err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			r.logger.Warnw("failed to read object data", "bucket", bucket, "key", key, "error", err)
+			r.writeError(w, "GetObject", toS3Error(err))
+			return
+		}
+		body = buffered
+	}
+
+	// Objects are served as stored, with their Content-Encoding, unless the
+	// client asks for a gzip-encoded object to be decompressed. The
+	// decompressed length is unknown up front, so no Content-Length is sent.
+	// Ranges address the stored bytes and are always served raw.
+	contentEncoding := obj.ContentEncoding
+	if objRange == nil && req.Header.Get(headerDecompress) == "true" && isGzipEncoding(contentEncoding) {
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			r.logger.Warnw("failed to decompress object", "bucket", bucket, "key", key, "error", err)
+			r.writeError(w, "GetObject", ErrInternal)
+			return
+		}
+		defer gr.Close()
+		body = gr
+		length = -1
+		contentEncoding = ""
+	}
+
+	// The checksum only describes the body when it is the whole object as
+	// stored
+	checksum := obj.Checksum
+	if objRange != nil || contentEncoding != obj.ContentEncoding {
+		checksum = nil
+	}
+
 	// Set headers (sanitize user-controlled values to prevent header injection)
-	w.Header().Set("Content-Type", sanitizeHeaderValue(obj.ContentType))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
+	w.Header().Set("Content-Type", sanitizeHeaderValue(r.contentTypeFor(key, obj.ContentType)))
+	if length >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	if contentEncoding != "" {
+		w.Header().Set("Content-Encoding", sanitizeHeaderValue(contentEncoding))
+	}
 	w.Header().Set("ETag", sanitizeHeaderValue(obj.ETag))
+	w.Header().Set("Accept-Ranges", "bytes")
+	setObjectHeaders(w, objectHeaders{
+		LastModified:       obj.LastModified,
+		VersionID:          obj.VersionID,
+		StorageClass:       obj.StorageClass,
+		ReplicationStatus:  obj.ReplicationStatus,
+		CacheControl:       obj.CacheControl,
+		ContentDisposition: obj.ContentDisposition,
+		Metadata:           obj.Metadata,
+
+		ServerSideEncryption: obj.ServerSideEncryption,
+		Checksum:             checksum,
+		Restore:              obj.Restore,
+	})
+	setResponseOverrides(w, req)
+	if opts.VerifyIntegrity {
+		if obj.Verified {
+			w.Header().Set(headerIntegrity, "verified")
+		} else {
+			w.Header().Set(headerIntegrity, "unverifiable")
+		}
+	}
 
-	// Use a buffer to ensure data is properly sent
-	data, err := io.ReadAll(obj.Body)
-	if err != nil {
-		r.logger.Warnw("failed to read object data", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
-		return
+	status := http.StatusOK
+	if objRange != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", objRange.Start, objRange.End-1, obj.Size))
+		status = http.StatusPartialContent
 	}
+	w.WriteHeader(status)
 
-	// Write data directly
-	w.Write(data)
+	// The status line is gone once copying starts, so a failure from here
+	// on can only be logged; the client sees a short body. Data that fails
+	// verification, in the engine or in a storage backend checking its
+	// reads, aborts the connection so it cannot pass for a complete
+	// response.
+	copyBody := io.Copy
+	if obj.Verified {
+		copyBody = copyHoldingLastByte
+	}
+	if n, err := copyBody(w, body); err != nil {
+		r.logger.Warnw("failed to stream object data", "bucket", bucket, "key", key, "written", n, "size", length, "error", err)
+		if errors.Is(err, engine.ErrIntegrityMismatch) || errors.Is(err, storage.ErrChecksumMismatch) {
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
 
-	s3RequestsTotal.WithLabelValues("GetObject", "200").Inc()
+	recordOperation(w, "GetObject")
 }
 
 // handleHeadObject handles HeadObject
@@ -609,16 +1117,77 @@ func (r *Router) handleHeadObject(w http.ResponseWriter, req *http.Request, buck
 	meta, err := r.engine.HeadObject(ctx, bucket, key)
 	if err != nil {
 		r.logger.Warnw("failed to head object", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+		r.writeError(w, "HeadObject", toS3Error(err))
 		return
 	}
 
-	w.Header().Set("Content-Type", sanitizeHeaderValue(meta.ContentType))
+	w.Header().Set("Content-Type", sanitizeHeaderValue(r.contentTypeFor(key, meta.ContentType)))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", meta.Size))
 	w.Header().Set("ETag", sanitizeHeaderValue(meta.ETag))
+	w.Header().Set("Accept-Ranges", "bytes")
+	if meta.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", sanitizeHeaderValue(meta.ContentEncoding))
+	}
+	setObjectHeaders(w, objectHeaders{
+		LastModified:       meta.LastModified,
+		VersionID:          meta.VersionID,
+		StorageClass:       meta.StorageClass,
+		ReplicationStatus:  meta.ReplicationStatus,
+		CacheControl:       meta.CacheControl,
+		ContentDisposition: meta.ContentDisposition,
+		Metadata:           meta.Metadata,
+
+		ServerSideEncryption: meta.ServerSideEncryption,
+		Checksum:             meta.Checksum,
+		Restore:              meta.Restore,
+	})
 	w.WriteHeader(http.StatusOK)
 
-	s3RequestsTotal.WithLabelValues("HeadObject", "200").Inc()
+	recordOperation(w, "HeadObject")
+}
+
+// handleGetObjectAttributes handles GetObjectAttributes. Only the attributes
+// named in x-amz-object-attributes are returned.
+func (r *Router) handleGetObjectAttributes(w http.ResponseWriter, req *http.Request, bucket, key string) {
+	ctx := req.Context()
+
+	attrs, err := r.engine.GetObjectAttributes(ctx, bucket, key, req.URL.Query().Get("versionId"))
+	if err != nil {
+		r.logger.Warnw("failed to get object attributes", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "GetObjectAttributes", toS3Error(err))
+		return
+	}
+
+	var resp s3types.GetObjectAttributesOutput
+	for _, value := range req.Header.Values("x-amz-object-attributes") {
+		for _, name := range strings.Split(value, ",") {
+			switch strings.TrimSpace(name) {
+			case "ETag":
+				resp.ETag = strings.Trim(attrs.ETag, `"`)
+			case "Checksum":
+				resp.Checksum = newChecksumResult(attrs.Checksum)
+			case "ObjectParts":
+				if len(attrs.Parts) > 0 {
+					resp.ObjectParts = &s3types.ObjectParts{TotalPartsCount: len(attrs.Parts)}
+				}
+			case "StorageClass":
+				resp.StorageClass = attrs.StorageClass
+				if resp.StorageClass == "" {
+					resp.StorageClass = "STANDARD"
+				}
+			case "ObjectSize":
+				resp.ObjectSize = strconv.FormatInt(attrs.Size, 10)
+			}
+		}
+	}
+
+	w.Header().Set("Last-Modified", time.Unix(attrs.LastModified, 0).UTC().Format(http.TimeFormat))
+	if attrs.VersionID != "" {
+		w.Header().Set("x-amz-version-id", sanitizeHeaderValue(attrs.VersionID))
+	}
+	r.writeXML(w, http.StatusOK, resp)
+
+	recordOperation(w, "GetObjectAttributes")
 }
 
 // handleHeadBucket handles HeadBucket - checks if bucket exists
@@ -629,43 +1198,230 @@ func (r *Router) handleHeadBucket(w http.ResponseWriter, req *http.Request, buck
 	err := r.engine.HeadBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to head bucket", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.writeError(w, "HeadBucket", toS3Error(err))
 		return
 	}
 
 	// Set common headers
 	w.Header().Set("Content-Type", "application/xml")
 	w.Header().Set("x-amz-bucket-region", "us-east-1")
+
+	// Signed requests also get the bucket's usage from its running totals,
+	// which S3 does not report
+	if auth.RequestAccessKey(req) != "" {
+		if usage, err := r.engine.GetBucketUsage(ctx, bucket); err == nil {
+			w.Header().Set(headerBucketObjectCount, strconv.FormatInt(usage.Objects, 10))
+			w.Header().Set(headerBucketBytesUsed, strconv.FormatInt(usage.Bytes, 10))
+		} else {
+			r.logger.Warnw("failed to read bucket usage", "bucket", bucket, "error", err)
+		}
+	}
 	w.WriteHeader(http.StatusOK)
 
-	s3RequestsTotal.WithLabelValues("HeadBucket", "200").Inc()
+	recordOperation(w, "HeadBucket")
+}
+
+// putObjectOptions reads the options of a PutObject, plain or resumable,
+// from its headers
+func (r *Router) putObjectOptions(req *http.Request, bucket string) (engine.PutObjectOptions, S3Error) {
+	retention, legalHold, s3err := objectLockFromRequest(req)
+	if s3err != nil {
+		return engine.PutObjectOptions{}, s3err
+	}
+	acl, s3err := r.uploadACL(req, bucket)
+	if s3err != nil {
+		return engine.PutObjectOptions{}, s3err
+	}
+	checksumAlgorithm, checksum, s3err := checksumFromRequest(req.Header)
+	if s3err != nil {
+		return engine.PutObjectOptions{}, s3err
+	}
+
+	return engine.PutObjectOptions{
+		ContentType:        req.Header.Get("Content-Type"),
+		ContentEncoding:    req.Header.Get("Content-Encoding"),
+		CacheControl:       req.Header.Get("Cache-Control"),
+		ContentDisposition: req.Header.Get("Content-Disposition"),
+		Metadata:           extractUserMetadata(req.Header),
+		Retention:          retention,
+		LegalHold:          legalHold,
+		ACL:                acl,
+		ChecksumAlgorithm:  checksumAlgorithm,
+		Checksum:           checksum,
+
+		ServerSideEncryption: req.Header.Get(headerServerSideEncryption),
+	}, nil
 }
 
 // handlePutObject handles PutObject
 func (r *Router) handlePutObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Read content
-	data := req.Body
-	contentLength := req.ContentLength
-	contentType := req.Header.Get("Content-Type")
-
-	result, err := r.engine.PutObject(ctx, bucket, key, data, engine.PutObjectOptions{
-		ContentType: contentType,
-	})
-	_ = contentLength // Reserved for future use
+	opts, s3err := r.putObjectOptions(req, bucket)
+	if s3err != nil {
+		r.writeError(w, "PutObject", s3err)
+		return
+	}
 
+	result, err := r.engine.PutObject(ctx, bucket, key, req.Body, opts)
 	if err != nil {
 		r.logger.Warnw("failed to put object", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObject", toS3Error(err))
 		return
 	}
 
 	// Set response headers
 	w.Header().Set("ETag", sanitizeHeaderValue(result.ETag))
+	r.setVersionIDHeader(w, req, bucket, result.VersionID)
+	setEncryptionHeader(w, result.ServerSideEncryption)
+	setChecksumHeaders(w, result.Checksum)
 	w.WriteHeader(http.StatusOK)
 
-	s3RequestsTotal.WithLabelValues("PutObject", "200").Inc()
+	recordOperation(w, "PutObject")
+}
+
+// setVersionIDHeader reports the version a write created in
+// x-amz-version-id when the bucket has versioning configured. Objects in
+// buckets that never had versioning turned on get no version ID, as in S3.
+func (r *Router) setVersionIDHeader(w http.ResponseWriter, req *http.Request, bucket, versionID string) {
+	if versionID == "" {
+		return
+	}
+	versioning, err := r.engine.GetBucketVersioning(req.Context(), bucket)
+	if err != nil || versioning == nil || (versioning.Status != "Enabled" && versioning.Status != "Suspended") {
+		return
+	}
+	w.Header().Set("x-amz-version-id", sanitizeHeaderValue(versionID))
+}
+
+// Headers used by resumable PutObject. They are not part of the S3 API.
+const (
+	headerUploadToken  = "x-openendpoint-upload-token"
+	headerUploadOffset = "x-openendpoint-upload-offset"
+	headerUploadLength = "x-openendpoint-upload-length"
+)
+
+// headerBucketObjectCount and headerBucketBytesUsed report a bucket's object
+// count and total size on HeadBucket. They are not part of the S3 API.
+const (
+	headerBucketObjectCount = "x-openendpoint-object-count"
+	headerBucketBytesUsed   = "x-openendpoint-bytes-used"
+)
+
+// headerVerifyIntegrity set to "true" on a GetObject makes the server check
+// the object data against its ETag before returning it. It is not part of
+// the S3 API.
+const headerVerifyIntegrity = "x-openendpoint-verify-integrity"
+
+// headerIntegrity answers headerVerifyIntegrity with "verified", or with
+// "unverifiable" for ranged reads and objects whose ETag is not a digest of
+// their data, such as multipart uploads
+const headerIntegrity = "x-openendpoint-integrity"
+
+// copyHoldingLastByte copies src to dst like io.Copy, but writes the final
+// byte only once src has ended cleanly. A verified object fails on its last
+// read, and holding a byte back keeps a failed response visibly short of its
+// Content-Length.
+func copyHoldingLastByte(dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, 1+32*1024)
+	var written int64
+	held := 0
+	for {
+		n, rerr := src.Read(buf[held:])
+		total := held + n
+		if rerr == nil {
+			if total == 0 {
+				continue
+			}
+			w, werr := dst.Write(buf[:total-1])
+			written += int64(w)
+			if werr != nil {
+				return written, werr
+			}
+			buf[0] = buf[total-1]
+			held = 1
+			continue
+		}
+		if rerr != io.EOF {
+			return written, rerr
+		}
+		w, werr := dst.Write(buf[:total])
+		written += int64(w)
+		return written, werr
+	}
+}
+
+// verifyReadAhead is how much of a verified GetObject is read before the
+// response status is sent
+const verifyReadAhead = 64 * 1024
+
+// headerDecompress set to "true" on a GetObject asks for an object stored
+// with Content-Encoding: gzip to be sent decompressed. By default objects are
+// returned exactly as stored. It is not part of the S3 API.
+const headerDecompress = "x-openendpoint-decompress"
+
+// isGzipEncoding reports whether a Content-Encoding value means gzip
+func isGzipEncoding(encoding string) bool {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	return encoding == "gzip" || encoding == "x-gzip"
+}
+
+// handleResumablePutObject handles a PutObject that can be resumed after a
+// dropped connection. The client picks the token, which is private to its
+// access key and expires a day after the upload starts, sends the total
+// length and the offset it is writing from, and on failure re-PUTs the
+// remainder starting at the committed offset reported back in
+// x-openendpoint-upload-offset.
+func (r *Router) handleResumablePutObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
+	ctx := req.Context()
+
+	token := req.Header.Get(headerUploadToken)
+	offset, err := strconv.ParseInt(req.Header.Get(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		r.writeError(w, "PutObject", ErrInvalidArgument)
+		return
+	}
+	length, err := strconv.ParseInt(req.Header.Get(headerUploadLength), 10, 64)
+	if err != nil || length <= 0 || length < offset {
+		r.writeError(w, "PutObject", ErrInvalidArgument)
+		return
+	}
+	opts, s3err := r.putObjectOptions(req, bucket)
+	if s3err != nil {
+		r.writeError(w, "PutObject", s3err)
+		return
+	}
+
+	// Tokens are scoped to the caller, so another principal reusing one
+	// cannot write into this upload
+	owner, _ := auth.AuthenticatedAccessKey(req)
+	status, err := r.engine.ResumePutObject(ctx, bucket, key, owner, token, offset, length, req.Body, opts)
+	if status != nil {
+		w.Header().Set(headerUploadOffset, strconv.FormatInt(status.Offset, 10))
+	}
+	if err != nil {
+		r.logger.Warnw("failed to put resumable object", "bucket", bucket, "key", key, "error", err)
+		var offsetErr *engine.ResumeOffsetError
+		if errors.As(err, &offsetErr) {
+			r.writeError(w, "PutObject", ErrInvalidUploadOffset)
+			return
+		}
+		r.writeError(w, "PutObject", toS3Error(err))
+		return
+	}
+
+	if !status.Completed {
+		w.WriteHeader(http.StatusAccepted)
+		recordOperation(w, "PutObject")
+		return
+	}
+
+	w.Header().Set("ETag", sanitizeHeaderValue(status.Result.ETag))
+	r.setVersionIDHeader(w, req, bucket, status.Result.VersionID)
+	setEncryptionHeader(w, status.Result.ServerSideEncryption)
+	setChecksumHeaders(w, status.Result.Checksum)
+	w.WriteHeader(http.StatusOK)
+	recordOperation(w, "PutObject")
 }
 
 // handleCreateBucket handles CreateBucket
@@ -675,12 +1431,13 @@ func (r *Router) handleCreateBucket(w http.ResponseWriter, req *http.Request, bu
 	err := r.engine.CreateBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to create bucket", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "CreateBucket", toS3Error(err))
 		return
 	}
 
+	w.Header().Set("Location", "/"+bucket)
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("CreateBucket", "200").Inc()
+	recordOperation(w, "CreateBucket")
 }
 
 // handleDeleteBucket handles DeleteBucket
@@ -690,47 +1447,66 @@ func (r *Router) handleDeleteBucket(w http.ResponseWriter, req *http.Request, bu
 	err := r.engine.DeleteBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to delete bucket", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucket", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucket", "200").Inc()
+	recordOperation(w, "DeleteBucket")
 }
 
 // handleCopyObject handles CopyObject (PUT with x-amz-copy-source)
 func (r *Router) handleCopyObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Parse the copy source header: /bucket/key
-	copySource := req.Header.Get("x-amz-copy-source")
-	if copySource == "" {
-		r.writeError(w, ErrInvalidArgument)
+	srcBucket, srcKey, srcVersionID, s3err := parseCopySource(req.Header.Get("x-amz-copy-source"))
+	if s3err != nil {
+		r.writeError(w, "CopyObject", s3err)
 		return
 	}
 
-	// Remove leading slash if present
-	copySource = strings.TrimPrefix(copySource, "/")
-
-	// Parse source bucket and key
-	parts := strings.SplitN(copySource, "/", 2)
-	if len(parts) != 2 {
-		r.writeError(w, ErrInvalidArgument)
+	acl, s3err := r.uploadACL(req, bucket)
+	if s3err != nil {
+		r.writeError(w, "CopyObject", s3err)
 		return
 	}
 
-	srcBucket := parts[0]
-	srcKey := parts[1]
+	opts := engine.CopyObjectOptions{
+		ACL:             acl,
+		SourceVersionID: srcVersionID,
+		IfMatch:         req.Header.Get("x-amz-copy-source-if-match"),
+		IfNoneMatch:     req.Header.Get("x-amz-copy-source-if-none-match"),
+
+		ServerSideEncryption: req.Header.Get(headerServerSideEncryption),
+	}
+	switch directive := req.Header.Get("x-amz-metadata-directive"); directive {
+	case "", engine.MetadataDirectiveCopy:
+	case engine.MetadataDirectiveReplace:
+		opts.MetadataDirective = directive
+		opts.ContentType = req.Header.Get("Content-Type")
+		opts.ContentEncoding = req.Header.Get("Content-Encoding")
+		opts.CacheControl = req.Header.Get("Cache-Control")
+		opts.ContentDisposition = req.Header.Get("Content-Disposition")
+		opts.Metadata = extractUserMetadata(req.Header)
+	default:
+		r.writeError(w, "CopyObject", withMessage(ErrInvalidArgument, "Unknown metadata directive."))
+		return
+	}
 
 	// Perform the copy
-	result, err := r.engine.CopyObject(ctx, srcBucket, srcKey, bucket, key)
+	result, err := r.engine.CopyObject(ctx, srcBucket, srcKey, bucket, key, opts)
 	if err != nil {
 		r.logger.Warnw("failed to copy object", "srcBucket", srcBucket, "srcKey", srcKey, "dstBucket", bucket, "dstKey", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "CopyObject", toS3Error(err))
 		return
 	}
 
 	// Return S3 CopyObject result
+	if result.SourceVersionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", sanitizeHeaderValue(result.SourceVersionID))
+	}
+	r.setVersionIDHeader(w, req, bucket, result.VersionID)
+	setEncryptionHeader(w, result.ServerSideEncryption)
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 
@@ -743,77 +1519,103 @@ func (r *Router) handleCopyObject(w http.ResponseWriter, req *http.Request, buck
 		result.ETag)
 
 	w.Write([]byte(response))
-	s3RequestsTotal.WithLabelValues("CopyObject", "200").Inc()
+	recordOperation(w, "CopyObject")
+}
+
+// parseCopySource parses an x-amz-copy-source header: /bucket/key,
+// URL-encoded, optionally followed by ?versionId=
+func parseCopySource(header string) (bucket, key, versionID string, s3err S3Error) {
+	if header == "" {
+		return "", "", "", ErrInvalidArgument
+	}
+	source, sourceQuery, _ := strings.Cut(header, "?")
+	sourceParams, err := url.ParseQuery(sourceQuery)
+	if err != nil {
+		return "", "", "", withMessage(ErrInvalidArgument, "Invalid copy source query")
+	}
+	source, err = url.PathUnescape(source)
+	if err != nil {
+		return "", "", "", withMessage(ErrInvalidArgument, "Invalid copy source encoding")
+	}
+
+	// Remove leading slash if present
+	source = strings.TrimPrefix(source, "/")
+
+	bucket, key, ok := strings.Cut(source, "/")
+	if !ok {
+		return "", "", "", ErrInvalidArgument
+	}
+	return bucket, key, sourceParams.Get("versionId"), nil
 }
 
 // handleGetObjectAcl handles GET /bucket/key?acl
 func (r *Router) handleGetObjectAcl(w http.ResponseWriter, req *http.Request, bucket, key string) {
-	ctx := req.Context()
-
-	// Check if object exists
-	_, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
+	acl, err := r.engine.GetObjectACL(req.Context(), bucket, key)
 	if err != nil {
-		r.logger.Warnw("object not found for ACL", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+		r.logger.Warnw("failed to get object ACL", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "GetObjectAcl", toS3Error(err))
 		return
 	}
 
-	// Return default ACL (owner full control)
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
-	aclResponse := `<?xml version="1.0" encoding"?>
-<AccessControlPolicy="UTF-8 xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
-  <Owner>
-    <ID>owner</ID>
-    <DisplayName>Owner</DisplayName>
-  </Owner>
-  <AccessControlList>
-    <Grant>
-      <Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser">
-        <ID>owner</ID>
-        <DisplayName>Owner</DisplayName>
-      </Grantee>
-      <Permission>FULL_CONTROL</Permission>
-    </Grant>
-  </AccessControlList>
-</AccessControlPolicy>`
-
-	w.Write([]byte(aclResponse))
-	s3RequestsTotal.WithLabelValues("GetObjectAcl", "200").Inc()
+	r.writeXML(w, http.StatusOK, newACLResult(acl))
+	recordOperation(w, "GetObjectAcl")
 }
 
 // handlePutObjectAcl handles PUT /bucket/key?acl
 func (r *Router) handlePutObjectAcl(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Check if object exists
-	_, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
-	if err != nil {
-		r.logger.Warnw("object not found for ACL", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+	acl, s3err := r.aclFromRequest(req)
+	if s3err != nil {
+		r.writeError(w, "PutObjectAcl", s3err)
+		return
+	}
+
+	// Public grants are accepted without effect while the bucket blocks
+	// or ignores public ACLs
+	if r.publicACLIgnored(ctx, bucket, acl) {
+		r.logger.Infow("ignoring public ACL", "bucket", bucket, "key", key)
+		if _, err := r.engine.HeadObject(ctx, bucket, key); err != nil {
+			r.writeError(w, "PutObjectAcl", toS3Error(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		recordOperation(w, "PutObjectAcl")
+		return
+	}
+
+	if err := r.engine.PutObjectACL(ctx, bucket, key, acl); err != nil {
+		r.logger.Warnw("failed to put object ACL", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "PutObjectAcl", toS3Error(err))
 		return
 	}
 
-	// For now, just acknowledge the ACL was set
-	// In a full implementation, we'd parse and store the ACL
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutObjectAcl", "200").Inc()
+	recordOperation(w, "PutObjectAcl")
 }
 
 // handleDeleteObject handles DeleteObject
 func (r *Router) handleDeleteObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	err := r.engine.DeleteObject(ctx, bucket, key, engine.DeleteObjectOptions{})
+	result, err := r.engine.DeleteObject(ctx, bucket, key, engine.DeleteObjectOptions{
+		VersionID:        req.URL.Query().Get("versionId"),
+		BypassGovernance: strings.EqualFold(req.Header.Get("x-amz-bypass-governance-retention"), "true"),
+	})
 	if err != nil {
 		r.logger.Warnw("failed to delete object", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteObject", toS3Error(err))
 		return
 	}
 
+	if result.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+	}
+	if result.VersionID != "" {
+		w.Header().Set("x-amz-version-id", sanitizeHeaderValue(result.VersionID))
+	}
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteObject", "200").Inc()
+	recordOperation(w, "DeleteObject")
 }
 
 // parseInt parses an integer with default
@@ -830,12 +1632,24 @@ func parseInt(s string, defaultVal int) int {
 func (r *Router) handleCreateMultipartUpload(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
+	storageClass, s3err := storageClassFromRequest(req)
+	if s3err != nil {
+		r.writeError(w, "CreateMultipartUpload", s3err)
+		return
+	}
+
 	result, err := r.engine.CreateMultipartUpload(ctx, bucket, key, engine.PutObjectOptions{
-		ContentType: req.Header.Get("Content-Type"),
+		ContentType:       req.Header.Get("Content-Type"),
+		Metadata:          extractUserMetadata(req.Header),
+		StorageClass:      storageClass,
+		ChecksumAlgorithm: strings.ToUpper(req.Header.Get(headerChecksumAlgorithm)),
+		ChecksumType:      strings.ToUpper(req.Header.Get(headerChecksumType)),
+
+		ServerSideEncryption: req.Header.Get(headerServerSideEncryption),
 	})
 	if err != nil {
 		r.logger.Warnw("failed to create multipart upload", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "CreateMultipartUpload", toS3Error(err))
 		return
 	}
 
@@ -850,7 +1664,7 @@ func (r *Router) handleCreateMultipartUpload(w http.ResponseWriter, req *http.Re
 	xmlBytes, _ := xml.Marshal(resp)
 	w.Write(xmlBytes)
 
-	s3RequestsTotal.WithLabelValues("CreateMultipartUpload", "200").Inc()
+	recordOperation(w, "CreateMultipartUpload")
 }
 
 // handleUploadPart handles UploadPart
@@ -859,27 +1673,91 @@ func (r *Router) handleUploadPart(w http.ResponseWriter, req *http.Request, buck
 
 	uploadID := req.URL.Query().Get("uploadId")
 	partNumber := parseInt(req.URL.Query().Get("partNumber"), 0)
+	if partNumber < 1 || partNumber > engine.MaxPartNumber {
+		r.writeError(w, "UploadPart", ErrInvalidArgument)
+		return
+	}
 
 	// Read part data
 	data, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read part data", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "UploadPart", toS3Error(err))
+		return
+	}
+
+	checksumAlgorithm, checksum, s3err := checksumFromRequest(req.Header)
+	if s3err != nil {
+		r.writeError(w, "UploadPart", s3err)
 		return
 	}
 
 	// Create a reader from the data
-	result, err := r.engine.UploadPart(ctx, bucket, key, uploadID, partNumber, bytes.NewReader(data))
+	result, err := r.engine.UploadPartWithOptions(ctx, bucket, key, uploadID, partNumber, bytes.NewReader(data), engine.UploadPartOptions{
+		ChecksumAlgorithm: checksumAlgorithm,
+		Checksum:          checksum,
+	})
 	if err != nil {
 		r.logger.Warnw("failed to upload part", "bucket", bucket, "key", key, "part", partNumber, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "UploadPart", toS3Error(err))
 		return
 	}
 
 	w.Header().Set("ETag", sanitizeHeaderValue(result.ETag))
+	setChecksumHeaders(w, result.Checksum)
 	w.WriteHeader(http.StatusOK)
 
-	s3RequestsTotal.WithLabelValues("UploadPart", "200").Inc()
+	recordOperation(w, "UploadPart")
+}
+
+// handleUploadPartCopy handles UploadPartCopy (PUT of a part with
+// x-amz-copy-source)
+func (r *Router) handleUploadPartCopy(w http.ResponseWriter, req *http.Request, bucket, key string) {
+	ctx := req.Context()
+
+	uploadID := req.URL.Query().Get("uploadId")
+	partNumber := parseInt(req.URL.Query().Get("partNumber"), 0)
+	if partNumber < 1 || partNumber > engine.MaxPartNumber {
+		r.writeError(w, "UploadPartCopy", ErrInvalidArgument)
+		return
+	}
+
+	srcBucket, srcKey, srcVersionID, s3err := parseCopySource(req.Header.Get("x-amz-copy-source"))
+	if s3err != nil {
+		r.writeError(w, "UploadPartCopy", s3err)
+		return
+	}
+
+	opts := engine.UploadPartCopyOptions{
+		SourceVersionID: srcVersionID,
+		IfMatch:         req.Header.Get("x-amz-copy-source-if-match"),
+		IfNoneMatch:     req.Header.Get("x-amz-copy-source-if-none-match"),
+	}
+	if header := req.Header.Get("x-amz-copy-source-range"); header != "" {
+		rng, ok := parseCopySourceRange(header)
+		if !ok {
+			r.writeError(w, "UploadPartCopy", withMessage(ErrInvalidArgument, "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy"))
+			return
+		}
+		opts.Range = rng
+	}
+
+	result, err := r.engine.UploadPartCopy(ctx, srcBucket, srcKey, bucket, key, uploadID, partNumber, opts)
+	if err != nil {
+		r.logger.Warnw("failed to copy part", "srcBucket", srcBucket, "srcKey", srcKey, "bucket", bucket, "key", key, "part", partNumber, "error", err)
+		r.writeError(w, "UploadPartCopy", toS3Error(err))
+		return
+	}
+
+	if result.SourceVersionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", sanitizeHeaderValue(result.SourceVersionID))
+	}
+	r.writeXML(w, http.StatusOK, s3types.CopyPartResult{
+		LastModified: time.Unix(result.LastModified, 0).UTC().Format(time.RFC3339),
+		ETag:         result.ETag,
+		Checksum:     newChecksumResult(result.Checksum),
+	})
+	recordOperation(w, "UploadPartCopy")
 }
 
 // handleCompleteMultipartUpload handles CompleteMultipartUpload
@@ -899,7 +1777,7 @@ func (r *Router) handleCompleteMultipartUpload(w http.ResponseWriter, req *http.
 	decoder := xml.NewDecoder(req.Body)
 	if err := decoder.Decode(&completeBody); err != nil {
 		r.logger.Warnw("failed to parse complete body", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "CompleteMultipartUpload", ErrInternal)
 		return
 	}
 
@@ -915,12 +1793,15 @@ func (r *Router) handleCompleteMultipartUpload(w http.ResponseWriter, req *http.
 	result, err := r.engine.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
 	if err != nil {
 		r.logger.Warnw("failed to complete multipart upload", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "CompleteMultipartUpload", toS3Error(err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
 	w.Header().Set("ETag", sanitizeHeaderValue(result.ETag))
+	r.setVersionIDHeader(w, req, bucket, result.VersionID)
+	setEncryptionHeader(w, result.ServerSideEncryption)
+	setChecksumHeaders(w, result.Checksum)
 	w.WriteHeader(http.StatusOK)
 
 	resp := s3types.CompleteMultipartUploadResult{
@@ -928,11 +1809,12 @@ func (r *Router) handleCompleteMultipartUpload(w http.ResponseWriter, req *http.
 		Key:          key,
 		ETag:         result.ETag,
 		Location:     "",
+		Checksum:     newChecksumResult(result.Checksum),
 	}
 	xmlBytes, _ := xml.Marshal(resp)
 	w.Write(xmlBytes)
 
-	s3RequestsTotal.WithLabelValues("CompleteMultipartUpload", "200").Inc()
+	recordOperation(w, "CompleteMultipartUpload")
 }
 
 // handleAbortMultipartUpload handles AbortMultipartUpload
@@ -944,49 +1826,70 @@ func (r *Router) handleAbortMultipartUpload(w http.ResponseWriter, req *http.Req
 	err := r.engine.AbortMultipartUpload(ctx, bucket, key, uploadID)
 	if err != nil {
 		r.logger.Warnw("failed to abort multipart upload", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "AbortMultipartUpload", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("AbortMultipartUpload", "200").Inc()
+	recordOperation(w, "AbortMultipartUpload")
 }
 
 // handleListParts handles ListParts
 func (r *Router) handleListParts(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	uploadID := req.URL.Query().Get("uploadId")
+	query := req.URL.Query()
+	uploadID := query.Get("uploadId")
+	maxParts := parseInt(query.Get("max-parts"), engine.MaxListParts)
+	partNumberMarker := parseInt(query.Get("part-number-marker"), 0)
+	if maxParts < 0 || partNumberMarker < 0 {
+		r.writeError(w, "ListParts", withMessage(ErrInvalidArgument, "max-parts and part-number-marker must not be negative"))
+		return
+	}
 
-	parts, err := r.engine.ListParts(ctx, bucket, key, uploadID)
+	result, err := r.engine.ListPartsWithOptions(ctx, bucket, key, uploadID, engine.ListPartsOptions{
+		MaxParts:         maxParts,
+		PartNumberMarker: partNumberMarker,
+	})
 	if err != nil {
 		r.logger.Warnw("failed to list parts", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "ListParts", toS3Error(err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
 	// Convert to S3 parts
-	s3parts := make([]s3types.Part, len(parts))
-	for i, p := range parts {
+	s3parts := make([]s3types.Part, len(result.Parts))
+	for i, p := range result.Parts {
 		s3parts[i] = s3types.Part{
-			PartNumber: p.PartNumber,
-			ETag:       p.ETag,
+			PartNumber:   p.PartNumber,
+			LastModified: time.Unix(p.LastModified, 0).UTC().Format(time.RFC3339),
+			ETag:         p.ETag,
+			Size:         p.Size,
 		}
 	}
 
-	resp := s3types.ListPartsOutput{
-		Bucket:    bucket,
-		Key:       key,
-		UploadID: uploadID,
-		Parts:    s3parts,
-	}
-	xmlBytes, _ := xml.Marshal(resp)
-	w.Write(xmlBytes)
-
-	s3RequestsTotal.WithLabelValues("ListParts", "200").Inc()
+	storageClass := result.StorageClass
+	if storageClass == "" {
+		storageClass = "STANDARD"
+	}
+	owner := &s3types.Owner{
+		ID:          "root",
+		DisplayName: "root",
+	}
+	r.writeXML(w, http.StatusOK, s3types.ListPartsOutput{
+		Bucket:               bucket,
+		Key:                  key,
+		UploadID:             uploadID,
+		PartNumberMarker:     result.PartNumberMarker,
+		NextPartNumberMarker: result.NextPartNumberMarker,
+		MaxParts:             result.MaxParts,
+		IsTruncated:          result.IsTruncated,
+		Initiator:            owner,
+		Owner:                owner,
+		StorageClass:         storageClass,
+		Parts:                s3parts,
+	})
+	recordOperation(w, "ListParts")
 }
 
 // handleListMultipartUploads handles ListMultipartUploads
@@ -996,7 +1899,7 @@ func (r *Router) handleListMultipartUploads(w http.ResponseWriter, req *http.Req
 	result, err := r.engine.ListMultipartUpload(ctx, bucket, req.URL.Query().Get("prefix"))
 	if err != nil {
 		r.logger.Warnw("failed to list multipart uploads", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "ListMultipartUploads", toS3Error(err))
 		return
 	}
 
@@ -1020,7 +1923,7 @@ func (r *Router) handleListMultipartUploads(w http.ResponseWriter, req *http.Req
 	xmlBytes, _ := xml.Marshal(resp)
 	w.Write(xmlBytes)
 
-	s3RequestsTotal.WithLabelValues("ListMultipartUploads", "200").Inc()
+	recordOperation(w, "ListMultipartUploads")
 }
 
 // handleGetBucketVersioning handles GET /bucket?versioning
@@ -1030,7 +1933,7 @@ func (r *Router) handleGetBucketVersioning(w http.ResponseWriter, req *http.Requ
 	versioning, err := r.engine.GetBucketVersioning(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket versioning", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketVersioning", toS3Error(err))
 		return
 	}
 
@@ -1048,7 +1951,7 @@ func (r *Router) handleGetBucketVersioning(w http.ResponseWriter, req *http.Requ
 	xmlBytes, _ := xml.Marshal(resp)
 	w.Write(xmlBytes)
 
-	s3RequestsTotal.WithLabelValues("GetBucketVersioning", "200").Inc()
+	recordOperation(w, "GetBucketVersioning")
 }
 
 // handlePutBucketVersioning handles PUT /bucket?versioning
@@ -1059,14 +1962,14 @@ func (r *Router) handlePutBucketVersioning(w http.ResponseWriter, req *http.Requ
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketVersioning", ErrInternal)
 		return
 	}
 
 	var input s3types.PutBucketVersioningInput
 	if err := xml.Unmarshal(body, &input); err != nil {
 		r.logger.Warnw("failed to parse versioning input", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketVersioning", ErrMalformedXML)
 		return
 	}
 
@@ -1077,12 +1980,12 @@ func (r *Router) handlePutBucketVersioning(w http.ResponseWriter, req *http.Requ
 
 	if err := r.engine.PutBucketVersioning(ctx, bucket, versioning); err != nil {
 		r.logger.Warnw("failed to set bucket versioning", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketVersioning", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketVersioning", "200").Inc()
+	recordOperation(w, "PutBucketVersioning")
 }
 
 // handleGetBucketLifecycle handles GET /bucket?lifecycle
@@ -1092,7 +1995,7 @@ func (r *Router) handleGetBucketLifecycle(w http.ResponseWriter, req *http.Reque
 	rules, err := r.engine.GetBucketLifecycle(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket lifecycle", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketLifecycle", toS3Error(err))
 		return
 	}
 
@@ -1104,11 +2007,19 @@ func (r *Router) handleGetBucketLifecycle(w http.ResponseWriter, req *http.Reque
 	for i, rule := range rules {
 		s3Rules[i] = s3types.LifecycleRule{
 			ID:     rule.ID,
+			Prefix: rule.Prefix,
+			Filter: lifecycleFilterToS3(rule.Filter),
 			Status: rule.Status,
 		}
-		if rule.Expiration != nil && rule.Expiration.Days > 0 {
+		if exp := rule.Expiration; exp != nil && (exp.Days > 0 || exp.Date > 0 || exp.ExpiredObjectDeleteMarker) {
 			s3Rules[i].Expiration = &s3types.Expiration{
-				Days: rule.Expiration.Days,
+				Days: exp.Days,
+			}
+			if exp.Date > 0 {
+				s3Rules[i].Expiration.Date = time.Unix(exp.Date, 0).UTC().Format(lifecycleDateLayout)
+			}
+			if exp.ExpiredObjectDeleteMarker {
+				s3Rules[i].Expiration.ExpiredObjectDeleteMarker = &exp.ExpiredObjectDeleteMarker
 			}
 		}
 		if rule.NoncurrentVersionExpiration != nil && rule.NoncurrentVersionExpiration.NoncurrentDays > 0 {
@@ -1116,6 +2027,12 @@ func (r *Router) handleGetBucketLifecycle(w http.ResponseWriter, req *http.Reque
 				NoncurrentDays: rule.NoncurrentVersionExpiration.NoncurrentDays,
 			}
 		}
+		s3Rules[i].Transitions, s3Rules[i].NoncurrentVersionTransitions = lifecycleTransitionsToS3(rule)
+		if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+			s3Rules[i].AbortIncompleteMultipartUpload = &s3types.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: abort.DaysAfterInitiation,
+			}
+		}
 	}
 
 	resp := s3types.GetBucketLifecycleOutput{
@@ -1124,7 +2041,7 @@ func (r *Router) handleGetBucketLifecycle(w http.ResponseWriter, req *http.Reque
 	xmlBytes, _ := xml.Marshal(resp)
 	w.Write(xmlBytes)
 
-	s3RequestsTotal.WithLabelValues("GetBucketLifecycle", "200").Inc()
+	recordOperation(w, "GetBucketLifecycle")
 }
 
 // handlePutBucketLifecycle handles PUT /bucket?lifecycle
@@ -1135,44 +2052,74 @@ func (r *Router) handlePutBucketLifecycle(w http.ResponseWriter, req *http.Reque
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketLifecycle", ErrInternal)
 		return
 	}
 
 	var input s3types.PutBucketLifecycleInput
 	if err := xml.Unmarshal(body, &input); err != nil {
 		r.logger.Warnw("failed to parse lifecycle input", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketLifecycle", ErrMalformedXML)
 		return
 	}
 
 	// Convert s3types rules to metadata rules
 	rules := make([]metadata.LifecycleRule, len(input.Rules))
 	for i, rule := range input.Rules {
+		if !validLifecycleFilter(rule.Filter) || (rule.Filter != nil && rule.Prefix != "") {
+			r.writeError(w, "PutBucketLifecycle", ErrMalformedXML)
+			return
+		}
 		rules[i] = metadata.LifecycleRule{
 			ID:     rule.ID,
+			Prefix: rule.Prefix,
+			Filter: lifecycleFilterFromS3(rule.Filter),
 			Status: rule.Status,
 		}
 		if rule.Expiration != nil {
 			rules[i].Expiration = &metadata.Expiration{
 				Days: int(rule.Expiration.Days),
 			}
+			if rule.Expiration.Date != "" {
+				date, s3err := lifecycleDateFromS3(rule.Expiration.Date)
+				if s3err != nil {
+					r.writeError(w, "PutBucketLifecycle", s3err)
+					return
+				}
+				rules[i].Expiration.Date = date
+			}
+			if marker := rule.Expiration.ExpiredObjectDeleteMarker; marker != nil {
+				rules[i].Expiration.ExpiredObjectDeleteMarker = *marker
+			}
 		}
 		if rule.NoncurrentVersionExpiration != nil {
 			rules[i].NoncurrentVersionExpiration = &metadata.NoncurrentVersionExpiration{
 				NoncurrentDays: int(rule.NoncurrentVersionExpiration.NoncurrentDays),
 			}
 		}
+		transitions, noncurrent, s3err := lifecycleTransitionsFromS3(rule)
+		if s3err != nil {
+			r.writeError(w, "PutBucketLifecycle", s3err)
+			return
+		}
+		rules[i].Transitions = transitions
+		rules[i].NoncurrentVersionTransitions = noncurrent
+		abort, s3err := lifecycleAbortUploadFromS3(rule)
+		if s3err != nil {
+			r.writeError(w, "PutBucketLifecycle", s3err)
+			return
+		}
+		rules[i].AbortIncompleteMultipartUpload = abort
 	}
 
 	if err := r.engine.PutBucketLifecycle(ctx, bucket, rules); err != nil {
 		r.logger.Warnw("failed to set bucket lifecycle", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketLifecycle", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketLifecycle", "200").Inc()
+	recordOperation(w, "PutBucketLifecycle")
 }
 
 // handleGetBucketCors handles GET /bucket?cors
@@ -1182,7 +2129,7 @@ func (r *Router) handleGetBucketCors(w http.ResponseWriter, req *http.Request, b
 	cors, err := r.engine.GetBucketCors(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket cors", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketCors", toS3Error(err))
 		return
 	}
 
@@ -1192,7 +2139,7 @@ func (r *Router) handleGetBucketCors(w http.ResponseWriter, req *http.Request, b
 	}
 
 	r.writeXML(w, http.StatusOK, cors)
-	s3RequestsTotal.WithLabelValues("GetBucketCors", "200").Inc()
+	recordOperation(w, "GetBucketCors")
 }
 
 // handlePutBucketCors handles PUT /bucket?cors
@@ -1203,7 +2150,7 @@ func (r *Router) handlePutBucketCors(w http.ResponseWriter, req *http.Request, b
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketCors", ErrInternal)
 		return
 	}
 
@@ -1211,19 +2158,19 @@ func (r *Router) handlePutBucketCors(w http.ResponseWriter, req *http.Request, b
 	var cors metadata.CORSConfiguration
 	if err := xml.Unmarshal(body, &cors); err != nil {
 		r.logger.Warnw("failed to parse CORS configuration", "error", err)
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "PutBucketCors", ErrInvalidRequest)
 		return
 	}
 
 	// Store CORS configuration
 	if err := r.engine.PutBucketCors(ctx, bucket, &cors); err != nil {
 		r.logger.Warnw("failed to set bucket cors", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketCors", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketCors", "200").Inc()
+	recordOperation(w, "PutBucketCors")
 }
 
 // handleGetBucketPolicy handles GET /bucket?policy
@@ -1233,7 +2180,7 @@ func (r *Router) handleGetBucketPolicy(w http.ResponseWriter, req *http.Request,
 	policy, err := r.engine.GetBucketPolicy(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket policy", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketPolicy", toS3Error(err))
 		return
 	}
 
@@ -1242,14 +2189,14 @@ func (r *Router) handleGetBucketPolicy(w http.ResponseWriter, req *http.Request,
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("{}"))
-		s3RequestsTotal.WithLabelValues("GetBucketPolicy", "200").Inc()
+		recordOperation(w, "GetBucketPolicy")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(*policy))
-	s3RequestsTotal.WithLabelValues("GetBucketPolicy", "200").Inc()
+	recordOperation(w, "GetBucketPolicy")
 }
 
 // handlePutBucketPolicy handles PUT /bucket?policy
@@ -1260,7 +2207,7 @@ func (r *Router) handlePutBucketPolicy(w http.ResponseWriter, req *http.Request,
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketPolicy", ErrInternal)
 		return
 	}
 
@@ -1268,20 +2215,33 @@ func (r *Router) handlePutBucketPolicy(w http.ResponseWriter, req *http.Request,
 	var policyJSON map[string]interface{}
 	if err := json.Unmarshal(body, &policyJSON); err != nil {
 		r.logger.Warnw("failed to parse bucket policy", "error", err)
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "PutBucketPolicy", ErrInvalidRequest)
 		return
 	}
 
+	if r.publicAccessBlock(ctx, bucket).BlockPublicPolicy {
+		public, err := iam.PolicyIsPublic(body)
+		if err != nil {
+			r.logger.Warnw("failed to parse bucket policy", "error", err)
+			r.writeError(w, "PutBucketPolicy", ErrInvalidRequest)
+			return
+		}
+		if public {
+			r.writeError(w, "PutBucketPolicy", ErrAccessDenied)
+			return
+		}
+	}
+
 	// Store policy
 	policyStr := string(body)
 	if err := r.engine.PutBucketPolicy(ctx, bucket, &policyStr); err != nil {
 		r.logger.Warnw("failed to set bucket policy", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketPolicy", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketPolicy", "200").Inc()
+	recordOperation(w, "PutBucketPolicy")
 }
 
 // handleGetBucketEncryption handles GET /bucket?encryption
@@ -1291,7 +2251,7 @@ func (r *Router) handleGetBucketEncryption(w http.ResponseWriter, req *http.Requ
 	encryption, err := r.engine.GetBucketEncryption(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket encryption", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketEncryption", toS3Error(err))
 		return
 	}
 
@@ -1301,7 +2261,7 @@ func (r *Router) handleGetBucketEncryption(w http.ResponseWriter, req *http.Requ
 	}
 
 	r.writeXML(w, http.StatusOK, encryption)
-	s3RequestsTotal.WithLabelValues("GetBucketEncryption", "200").Inc()
+	recordOperation(w, "GetBucketEncryption")
 }
 
 // handlePutBucketEncryption handles PUT /bucket?encryption
@@ -1312,7 +2272,7 @@ func (r *Router) handlePutBucketEncryption(w http.ResponseWriter, req *http.Requ
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketEncryption", ErrInternal)
 		return
 	}
 
@@ -1320,19 +2280,19 @@ func (r *Router) handlePutBucketEncryption(w http.ResponseWriter, req *http.Requ
 	var encryption metadata.BucketEncryption
 	if err := xml.Unmarshal(body, &encryption); err != nil {
 		r.logger.Warnw("failed to parse encryption configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketEncryption", ErrMalformedXML)
 		return
 	}
 
 	// Store encryption configuration
 	if err := r.engine.PutBucketEncryption(ctx, bucket, &encryption); err != nil {
 		r.logger.Warnw("failed to set bucket encryption", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketEncryption", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketEncryption", "200").Inc()
+	recordOperation(w, "PutBucketEncryption")
 }
 
 // handleGetBucketTags handles GET /bucket?tagging
@@ -1342,7 +2302,7 @@ func (r *Router) handleGetBucketTags(w http.ResponseWriter, req *http.Request, b
 	tags, err := r.engine.GetBucketTags(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket tags", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketTags", toS3Error(err))
 		return
 	}
 
@@ -1374,7 +2334,7 @@ func (r *Router) handleGetBucketTags(w http.ResponseWriter, req *http.Request, b
 	}
 
 	r.writeXML(w, http.StatusOK, response)
-	s3RequestsTotal.WithLabelValues("GetBucketTags", "200").Inc()
+	recordOperation(w, "GetBucketTags")
 }
 
 // handlePutBucketTags handles PUT /bucket?tagging
@@ -1385,7 +2345,7 @@ func (r *Router) handlePutBucketTags(w http.ResponseWriter, req *http.Request, b
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketTags", ErrInternal)
 		return
 	}
 
@@ -1404,7 +2364,7 @@ func (r *Router) handlePutBucketTags(w http.ResponseWriter, req *http.Request, b
 	var input TaggingInput
 	if err := xml.Unmarshal(body, &input); err != nil {
 		r.logger.Warnw("failed to parse tagging input", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketTags", ErrMalformedXML)
 		return
 	}
 
@@ -1417,12 +2377,12 @@ func (r *Router) handlePutBucketTags(w http.ResponseWriter, req *http.Request, b
 	// Store tags
 	if err := r.engine.PutBucketTags(ctx, bucket, tags); err != nil {
 		r.logger.Warnw("failed to set bucket tags", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketTags", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketTags", "200").Inc()
+	recordOperation(w, "PutBucketTags")
 }
 
 // handleGetObjectLock handles GET /bucket?object-lock
@@ -1432,7 +2392,7 @@ func (r *Router) handleGetObjectLock(w http.ResponseWriter, req *http.Request, b
 	config, err := r.engine.GetObjectLock(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get object lock", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetObjectLock", toS3Error(err))
 		return
 	}
 
@@ -1442,7 +2402,7 @@ func (r *Router) handleGetObjectLock(w http.ResponseWriter, req *http.Request, b
 	}
 
 	r.writeXML(w, http.StatusOK, config)
-	s3RequestsTotal.WithLabelValues("GetObjectLock", "200").Inc()
+	recordOperation(w, "GetObjectLock")
 }
 
 // handlePutObjectLock handles PUT /bucket?object-lock
@@ -1453,27 +2413,32 @@ func (r *Router) handlePutObjectLock(w http.ResponseWriter, req *http.Request, b
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObjectLock", ErrInternal)
 		return
 	}
 
-	// Parse object lock configuration
-	var config metadata.ObjectLockConfig
-	if err := xml.Unmarshal(body, &config); err != nil {
+	// Parse object lock configuration. S3 clients send ObjectLockEnabled;
+	// Enabled is the form GetObjectLock returns.
+	var doc struct {
+		ObjectLockEnabled string
+		Enabled           bool
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
 		r.logger.Warnw("failed to parse object lock configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutObjectLock", ErrMalformedXML)
 		return
 	}
+	config := metadata.ObjectLockConfig{Enabled: doc.Enabled || doc.ObjectLockEnabled == "Enabled"}
 
 	// Store configuration
 	if err := r.engine.PutObjectLock(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to set object lock", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObjectLock", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutObjectLock", "200").Inc()
+	recordOperation(w, "PutObjectLock")
 }
 
 // handleGetPublicAccessBlock handles GET /bucket?public-access-block
@@ -1483,7 +2448,7 @@ func (r *Router) handleGetPublicAccessBlock(w http.ResponseWriter, req *http.Req
 	config, err := r.engine.GetPublicAccessBlock(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get public access block", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetPublicAccessBlock", toS3Error(err))
 		return
 	}
 
@@ -1509,7 +2474,7 @@ func (r *Router) handleGetPublicAccessBlock(w http.ResponseWriter, req *http.Req
 	}
 
 	r.writeXML(w, http.StatusOK, response)
-	s3RequestsTotal.WithLabelValues("GetPublicAccessBlock", "200").Inc()
+	recordOperation(w, "GetPublicAccessBlock")
 }
 
 // handlePutPublicAccessBlock handles PUT /bucket?public-access-block
@@ -1520,7 +2485,7 @@ func (r *Router) handlePutPublicAccessBlock(w http.ResponseWriter, req *http.Req
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutPublicAccessBlock", ErrInternal)
 		return
 	}
 
@@ -1528,19 +2493,19 @@ func (r *Router) handlePutPublicAccessBlock(w http.ResponseWriter, req *http.Req
 	var config metadata.PublicAccessBlockConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse public access block configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutPublicAccessBlock", ErrMalformedXML)
 		return
 	}
 
 	// Store configuration
 	if err := r.engine.PutPublicAccessBlock(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to set public access block", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutPublicAccessBlock", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutPublicAccessBlock", "200").Inc()
+	recordOperation(w, "PutPublicAccessBlock")
 }
 
 // handleGetBucketAccelerate handles GET /bucket?accelerate
@@ -1550,7 +2515,7 @@ func (r *Router) handleGetBucketAccelerate(w http.ResponseWriter, req *http.Requ
 	config, err := r.engine.GetBucketAccelerate(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket accelerate", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketAccelerate", toS3Error(err))
 		return
 	}
 
@@ -1560,7 +2525,7 @@ func (r *Router) handleGetBucketAccelerate(w http.ResponseWriter, req *http.Requ
 	}
 
 	r.writeXML(w, http.StatusOK, config)
-	s3RequestsTotal.WithLabelValues("GetBucketAccelerate", "200").Inc()
+	recordOperation(w, "GetBucketAccelerate")
 }
 
 // handlePutBucketAccelerate handles PUT /bucket?accelerate
@@ -1571,7 +2536,7 @@ func (r *Router) handlePutBucketAccelerate(w http.ResponseWriter, req *http.Requ
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketAccelerate", ErrInternal)
 		return
 	}
 
@@ -1579,26 +2544,26 @@ func (r *Router) handlePutBucketAccelerate(w http.ResponseWriter, req *http.Requ
 	var config metadata.BucketAccelerateConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse accelerate configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketAccelerate", ErrMalformedXML)
 		return
 	}
 
 	// Validate status
 	if config.Status != "Enabled" && config.Status != "Suspended" && config.Status != "" {
 		r.logger.Warnw("invalid accelerate status", "status", config.Status)
-		r.writeError(w, ErrInvalidAccelerateConfiguration)
+		r.writeError(w, "PutBucketAccelerate", ErrInvalidAccelerateConfiguration)
 		return
 	}
 
 	// Store configuration
 	if err := r.engine.PutBucketAccelerate(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to set bucket accelerate", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketAccelerate", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketAccelerate", "200").Inc()
+	recordOperation(w, "PutBucketAccelerate")
 }
 
 // handleGetBucketInventory handles GET /bucket?inventory
@@ -1612,17 +2577,17 @@ func (r *Router) handleGetBucketInventory(w http.ResponseWriter, req *http.Reque
 		config, err := r.engine.GetBucketInventory(ctx, bucket, inventoryID)
 		if err != nil {
 			r.logger.Warnw("failed to get bucket inventory", "bucket", bucket, "id", inventoryID, "error", err)
-			r.writeError(w, ErrInternal)
+			r.writeError(w, "GetBucketInventory", toS3Error(err))
 			return
 		}
 
 		if config == nil {
-			r.writeError(w, ErrInventoryNotFound)
+			r.writeError(w, "GetBucketInventory", ErrInventoryNotFound)
 			return
 		}
 
 		r.writeXML(w, http.StatusOK, config)
-		s3RequestsTotal.WithLabelValues("GetBucketInventory", "200").Inc()
+		recordOperation(w, "GetBucketInventory")
 		return
 	}
 
@@ -1630,7 +2595,7 @@ func (r *Router) handleGetBucketInventory(w http.ResponseWriter, req *http.Reque
 	configs, err := r.engine.ListBucketInventory(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to list bucket inventory", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketInventory", toS3Error(err))
 		return
 	}
 
@@ -1645,7 +2610,7 @@ func (r *Router) handleGetBucketInventory(w http.ResponseWriter, req *http.Reque
 	}
 
 	r.writeXML(w, http.StatusOK, response)
-	s3RequestsTotal.WithLabelValues("ListBucketInventory", "200").Inc()
+	recordOperation(w, "ListBucketInventory")
 }
 
 // handlePutBucketInventory handles PUT /bucket?inventory
@@ -1654,7 +2619,7 @@ func (r *Router) handlePutBucketInventory(w http.ResponseWriter, req *http.Reque
 
 	inventoryID := req.URL.Query().Get("inventory-id")
 	if inventoryID == "" {
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "PutBucketInventory", ErrInvalidRequest)
 		return
 	}
 
@@ -1662,7 +2627,7 @@ func (r *Router) handlePutBucketInventory(w http.ResponseWriter, req *http.Reque
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketInventory", ErrInternal)
 		return
 	}
 
@@ -1670,7 +2635,7 @@ func (r *Router) handlePutBucketInventory(w http.ResponseWriter, req *http.Reque
 	var config metadata.InventoryConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse inventory configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketInventory", ErrMalformedXML)
 		return
 	}
 
@@ -1680,12 +2645,12 @@ func (r *Router) handlePutBucketInventory(w http.ResponseWriter, req *http.Reque
 	// Store configuration
 	if err := r.engine.PutBucketInventory(ctx, bucket, inventoryID, &config); err != nil {
 		r.logger.Warnw("failed to set bucket inventory", "bucket", bucket, "id", inventoryID, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketInventory", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketInventory", "200").Inc()
+	recordOperation(w, "PutBucketInventory")
 }
 
 // handleDeleteBucketInventory handles DELETE /bucket?inventory
@@ -1694,19 +2659,19 @@ func (r *Router) handleDeleteBucketInventory(w http.ResponseWriter, req *http.Re
 
 	inventoryID := req.URL.Query().Get("inventory-id")
 	if inventoryID == "" {
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "DeleteBucketInventory", ErrInvalidRequest)
 		return
 	}
 
 	// Delete configuration
 	if err := r.engine.DeleteBucketInventory(ctx, bucket, inventoryID); err != nil {
 		r.logger.Warnw("failed to delete bucket inventory", "bucket", bucket, "id", inventoryID, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketInventory", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketInventory", "204").Inc()
+	recordOperation(w, "DeleteBucketInventory")
 }
 
 // handleGetBucketAnalytics handles GET /bucket?analytics
@@ -1720,17 +2685,17 @@ func (r *Router) handleGetBucketAnalytics(w http.ResponseWriter, req *http.Reque
 		config, err := r.engine.GetBucketAnalytics(ctx, bucket, analyticsID)
 		if err != nil {
 			r.logger.Warnw("failed to get bucket analytics", "bucket", bucket, "id", analyticsID, "error", err)
-			r.writeError(w, ErrInternal)
+			r.writeError(w, "GetBucketAnalytics", toS3Error(err))
 			return
 		}
 
 		if config == nil {
-			r.writeError(w, ErrAnalyticsNotFound)
+			r.writeError(w, "GetBucketAnalytics", ErrAnalyticsNotFound)
 			return
 		}
 
 		r.writeXML(w, http.StatusOK, config)
-		s3RequestsTotal.WithLabelValues("GetBucketAnalytics", "200").Inc()
+		recordOperation(w, "GetBucketAnalytics")
 		return
 	}
 
@@ -1738,7 +2703,7 @@ func (r *Router) handleGetBucketAnalytics(w http.ResponseWriter, req *http.Reque
 	configs, err := r.engine.ListBucketAnalytics(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to list bucket analytics", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketAnalytics", toS3Error(err))
 		return
 	}
 
@@ -1753,7 +2718,7 @@ func (r *Router) handleGetBucketAnalytics(w http.ResponseWriter, req *http.Reque
 	}
 
 	r.writeXML(w, http.StatusOK, response)
-	s3RequestsTotal.WithLabelValues("ListBucketAnalytics", "200").Inc()
+	recordOperation(w, "ListBucketAnalytics")
 }
 
 // handlePutBucketAnalytics handles PUT /bucket?analytics
@@ -1762,7 +2727,7 @@ func (r *Router) handlePutBucketAnalytics(w http.ResponseWriter, req *http.Reque
 
 	analyticsID := req.URL.Query().Get("analytics-id")
 	if analyticsID == "" {
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "PutBucketAnalytics", ErrInvalidRequest)
 		return
 	}
 
@@ -1770,7 +2735,7 @@ func (r *Router) handlePutBucketAnalytics(w http.ResponseWriter, req *http.Reque
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketAnalytics", ErrInternal)
 		return
 	}
 
@@ -1778,7 +2743,7 @@ func (r *Router) handlePutBucketAnalytics(w http.ResponseWriter, req *http.Reque
 	var config metadata.AnalyticsConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse analytics configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketAnalytics", ErrMalformedXML)
 		return
 	}
 
@@ -1788,12 +2753,12 @@ func (r *Router) handlePutBucketAnalytics(w http.ResponseWriter, req *http.Reque
 	// Store configuration
 	if err := r.engine.PutBucketAnalytics(ctx, bucket, analyticsID, &config); err != nil {
 		r.logger.Warnw("failed to set bucket analytics", "bucket", bucket, "id", analyticsID, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketAnalytics", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketAnalytics", "200").Inc()
+	recordOperation(w, "PutBucketAnalytics")
 }
 
 // handleDeleteBucketAnalytics handles DELETE /bucket?analytics
@@ -1802,19 +2767,19 @@ func (r *Router) handleDeleteBucketAnalytics(w http.ResponseWriter, req *http.Re
 
 	analyticsID := req.URL.Query().Get("analytics-id")
 	if analyticsID == "" {
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "DeleteBucketAnalytics", ErrInvalidRequest)
 		return
 	}
 
 	// Delete configuration
 	if err := r.engine.DeleteBucketAnalytics(ctx, bucket, analyticsID); err != nil {
 		r.logger.Warnw("failed to delete bucket analytics", "bucket", bucket, "id", analyticsID, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketAnalytics", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketAnalytics", "204").Inc()
+	recordOperation(w, "DeleteBucketAnalytics")
 }
 
 // handleGetBucketWebsite handles GET /bucket?website
@@ -1824,17 +2789,17 @@ func (r *Router) handleGetBucketWebsite(w http.ResponseWriter, req *http.Request
 	config, err := r.engine.GetBucketWebsite(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket website", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketWebsite", toS3Error(err))
 		return
 	}
 
 	if config == nil {
-		r.writeError(w, ErrWebsiteNotFound)
+		r.writeError(w, "GetBucketWebsite", ErrWebsiteNotFound)
 		return
 	}
 
 	r.writeXML(w, http.StatusOK, config)
-	s3RequestsTotal.WithLabelValues("GetBucketWebsite", "200").Inc()
+	recordOperation(w, "GetBucketWebsite")
 }
 
 // handlePutBucketWebsite handles PUT /bucket?website
@@ -1845,7 +2810,7 @@ func (r *Router) handlePutBucketWebsite(w http.ResponseWriter, req *http.Request
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketWebsite", ErrInternal)
 		return
 	}
 
@@ -1853,19 +2818,19 @@ func (r *Router) handlePutBucketWebsite(w http.ResponseWriter, req *http.Request
 	var config metadata.WebsiteConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse website configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketWebsite", ErrMalformedXML)
 		return
 	}
 
 	// Save configuration
 	if err := r.engine.PutBucketWebsite(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to save bucket website", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketWebsite", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketWebsite", "200").Inc()
+	recordOperation(w, "PutBucketWebsite")
 }
 
 // handleDeleteBucketWebsite handles DELETE /bucket?website
@@ -1875,12 +2840,12 @@ func (r *Router) handleDeleteBucketWebsite(w http.ResponseWriter, req *http.Requ
 	// Delete configuration
 	if err := r.engine.DeleteBucketWebsite(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket website", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketWebsite", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketWebsite", "204").Inc()
+	recordOperation(w, "DeleteBucketWebsite")
 }
 
 // handleDeleteBucketPolicy handles DELETE /bucket?policy
@@ -1889,12 +2854,12 @@ func (r *Router) handleDeleteBucketPolicy(w http.ResponseWriter, req *http.Reque
 
 	if err := r.engine.DeleteBucketPolicy(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket policy", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketPolicy", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketPolicy", "204").Inc()
+	recordOperation(w, "DeleteBucketPolicy")
 }
 
 // handleDeleteBucketLifecycle handles DELETE /bucket?lifecycle
@@ -1904,12 +2869,12 @@ func (r *Router) handleDeleteBucketLifecycle(w http.ResponseWriter, req *http.Re
 	// Delete all lifecycle rules by passing empty slice
 	if err := r.engine.PutBucketLifecycle(ctx, bucket, nil); err != nil {
 		r.logger.Warnw("failed to delete bucket lifecycle", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketLifecycle", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketLifecycle", "204").Inc()
+	recordOperation(w, "DeleteBucketLifecycle")
 }
 
 // handleDeleteBucketCors handles DELETE /bucket?cors
@@ -1918,12 +2883,12 @@ func (r *Router) handleDeleteBucketCors(w http.ResponseWriter, req *http.Request
 
 	if err := r.engine.DeleteBucketCors(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket cors", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketCors", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketCors", "204").Inc()
+	recordOperation(w, "DeleteBucketCors")
 }
 
 // handleDeleteBucketEncryption handles DELETE /bucket?encryption
@@ -1932,12 +2897,12 @@ func (r *Router) handleDeleteBucketEncryption(w http.ResponseWriter, req *http.R
 
 	if err := r.engine.DeleteBucketEncryption(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket encryption", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketEncryption", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketEncryption", "204").Inc()
+	recordOperation(w, "DeleteBucketEncryption")
 }
 
 // handleDeleteBucketTags handles DELETE /bucket?tagging
@@ -1946,12 +2911,12 @@ func (r *Router) handleDeleteBucketTags(w http.ResponseWriter, req *http.Request
 
 	if err := r.engine.DeleteBucketTags(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket tags", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketTags", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketTags", "204").Inc()
+	recordOperation(w, "DeleteBucketTags")
 }
 
 // handleDeleteObjectLock handles DELETE /bucket?object-lock
@@ -1960,33 +2925,35 @@ func (r *Router) handleDeleteObjectLock(w http.ResponseWriter, req *http.Request
 
 	if err := r.engine.DeleteObjectLock(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete object lock", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteObjectLock", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteObjectLock", "204").Inc()
+	recordOperation(w, "DeleteObjectLock")
 }
 
-// handleGetObjectRetention handles GET /object?retention
+// handleGetObjectRetention handles GET /object?retention. These handlers and
+// the tagging ones address one version with the versionId parameter.
 func (r *Router) handleGetObjectRetention(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	retention, err := r.engine.GetObjectRetention(ctx, bucket, key)
+	versionID := req.URL.Query().Get("versionId")
+	retention, err := r.engine.GetObjectRetention(ctx, bucket, key, versionID)
 	if err != nil {
 		r.logger.Warnw("failed to get object retention", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetObjectRetention", toS3Error(err))
 		return
 	}
 
 	if retention == nil {
-		r.writeError(w, ErrObjectRetentionNotFound)
+		r.writeError(w, "GetObjectRetention", ErrObjectRetentionNotFound)
 		return
 	}
 
 	data, err := xml.Marshal(retention)
 	if err != nil {
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetObjectRetention", ErrInternal)
 		return
 	}
 
@@ -1996,7 +2963,7 @@ func (r *Router) handleGetObjectRetention(w http.ResponseWriter, req *http.Reque
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(xmlResponse))
-	s3RequestsTotal.WithLabelValues("GetObjectRetention", "200").Inc()
+	recordOperation(w, "GetObjectRetention")
 }
 
 // handlePutObjectRetention handles PUT /object?retention
@@ -2006,47 +2973,56 @@ func (r *Router) handlePutObjectRetention(w http.ResponseWriter, req *http.Reque
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObjectRetention", ErrInternal)
 		return
 	}
 	defer req.Body.Close()
 
-	var retention metadata.ObjectRetention
-	if err := xml.Unmarshal(body, &retention); err != nil {
+	var doc struct {
+		Mode            string
+		RetainUntilDate string
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
 		r.logger.Warnw("failed to parse retention", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutObjectRetention", ErrMalformedXML)
 		return
 	}
+	until, err := time.Parse(time.RFC3339, doc.RetainUntilDate)
+	if err != nil {
+		r.writeError(w, "PutObjectRetention", ErrInvalidArgument)
+		return
+	}
+	retention := metadata.ObjectRetention{Mode: doc.Mode, RetainUntilDate: until.Unix()}
 
-	if err := r.engine.PutObjectRetention(ctx, bucket, key, &retention); err != nil {
+	opts := engine.RetentionOptions{
+		BypassGovernance: strings.EqualFold(req.Header.Get("x-amz-bypass-governance-retention"), "true"),
+	}
+	versionID := req.URL.Query().Get("versionId")
+	if err := r.engine.PutObjectRetention(ctx, bucket, key, versionID, &retention, opts); err != nil {
 		r.logger.Warnw("failed to put object retention", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObjectRetention", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutObjectRetention", "200").Inc()
+	recordOperation(w, "PutObjectRetention")
 }
 
 // handleGetObjectLegalHold handles GET /object?legal-hold
 func (r *Router) handleGetObjectLegalHold(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	legalHold, err := r.engine.GetObjectLegalHold(ctx, bucket, key)
+	versionID := req.URL.Query().Get("versionId")
+	legalHold, err := r.engine.GetObjectLegalHold(ctx, bucket, key, versionID)
 	if err != nil {
 		r.logger.Warnw("failed to get object legal hold", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
-		return
-	}
-
-	if legalHold == nil {
-		r.writeError(w, ErrObjectLegalHoldNotFound)
+		r.writeError(w, "GetObjectLegalHold", toS3Error(err))
 		return
 	}
 
 	data, err := xml.Marshal(legalHold)
 	if err != nil {
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetObjectLegalHold", ErrInternal)
 		return
 	}
 
@@ -2056,7 +3032,7 @@ func (r *Router) handleGetObjectLegalHold(w http.ResponseWriter, req *http.Reque
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(xmlResponse))
-	s3RequestsTotal.WithLabelValues("GetObjectLegalHold", "200").Inc()
+	recordOperation(w, "GetObjectLegalHold")
 }
 
 // handlePutObjectLegalHold handles PUT /object?legal-hold
@@ -2066,7 +3042,7 @@ func (r *Router) handlePutObjectLegalHold(w http.ResponseWriter, req *http.Reque
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObjectLegalHold", ErrInternal)
 		return
 	}
 	defer req.Body.Close()
@@ -2074,18 +3050,19 @@ func (r *Router) handlePutObjectLegalHold(w http.ResponseWriter, req *http.Reque
 	var legalHold metadata.ObjectLegalHold
 	if err := xml.Unmarshal(body, &legalHold); err != nil {
 		r.logger.Warnw("failed to parse legal hold", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutObjectLegalHold", ErrMalformedXML)
 		return
 	}
 
-	if err := r.engine.PutObjectLegalHold(ctx, bucket, key, &legalHold); err != nil {
+	versionID := req.URL.Query().Get("versionId")
+	if err := r.engine.PutObjectLegalHold(ctx, bucket, key, versionID, &legalHold); err != nil {
 		r.logger.Warnw("failed to put object legal hold", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutObjectLegalHold", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutObjectLegalHold", "200").Inc()
+	recordOperation(w, "PutObjectLegalHold")
 }
 
 // handleDeletePublicAccessBlock handles DELETE /bucket?public-access-block
@@ -2094,12 +3071,12 @@ func (r *Router) handleDeletePublicAccessBlock(w http.ResponseWriter, req *http.
 
 	if err := r.engine.DeletePublicAccessBlock(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete public access block", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeletePublicAccessBlock", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeletePublicAccessBlock", "204").Inc()
+	recordOperation(w, "DeletePublicAccessBlock")
 }
 
 // handleDeleteBucketAccelerate handles DELETE /bucket?accelerate
@@ -2108,12 +3085,12 @@ func (r *Router) handleDeleteBucketAccelerate(w http.ResponseWriter, req *http.R
 
 	if err := r.engine.DeleteBucketAccelerate(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket accelerate", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketAccelerate", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketAccelerate", "204").Inc()
+	recordOperation(w, "DeleteBucketAccelerate")
 }
 
 // handleDeleteBucketNotification handles DELETE /bucket?notification
@@ -2122,12 +3099,12 @@ func (r *Router) handleDeleteBucketNotification(w http.ResponseWriter, req *http
 
 	if err := r.engine.DeleteBucketNotification(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket notification", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketNotification", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketNotification", "204").Inc()
+	recordOperation(w, "DeleteBucketNotification")
 }
 
 // handleDeleteBucketLogging handles DELETE /bucket?logging
@@ -2136,12 +3113,12 @@ func (r *Router) handleDeleteBucketLogging(w http.ResponseWriter, req *http.Requ
 
 	if err := r.engine.DeleteBucketLogging(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket logging", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketLogging", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketLogging", "204").Inc()
+	recordOperation(w, "DeleteBucketLogging")
 }
 
 // handleGetBucketLocation handles GET /bucket?location
@@ -2152,14 +3129,14 @@ func (r *Router) handleGetBucketLocation(w http.ResponseWriter, req *http.Reques
 	_, err := r.engine.GetBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("bucket not found for location", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.writeError(w, "GetBucketLocation", toS3Error(err))
 		return
 	}
 
 	location, err := r.engine.GetBucketLocation(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket location", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketLocation", toS3Error(err))
 		return
 	}
 
@@ -2175,7 +3152,7 @@ func (r *Router) handleGetBucketLocation(w http.ResponseWriter, req *http.Reques
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(xmlResponse))
-	s3RequestsTotal.WithLabelValues("GetBucketLocation", "200").Inc()
+	recordOperation(w, "GetBucketLocation")
 }
 
 // handlePutBucketLocation handles PUT /bucket?location
@@ -2185,7 +3162,7 @@ func (r *Router) handlePutBucketLocation(w http.ResponseWriter, req *http.Reques
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketLocation", ErrInternal)
 		return
 	}
 	defer req.Body.Close()
@@ -2206,12 +3183,12 @@ func (r *Router) handlePutBucketLocation(w http.ResponseWriter, req *http.Reques
 	// Put location
 	if err := r.engine.PutBucketLocation(ctx, bucket, location); err != nil {
 		r.logger.Warnw("failed to put bucket location", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketLocation", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketLocation", "200").Inc()
+	recordOperation(w, "PutBucketLocation")
 }
 
 // handleGetBucketOwnershipControls handles GET /bucket?ownership-controls
@@ -2222,26 +3199,26 @@ func (r *Router) handleGetBucketOwnershipControls(w http.ResponseWriter, req *ht
 	_, err := r.engine.GetBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("bucket not found for ownership controls", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.writeError(w, "GetBucketOwnershipControls", toS3Error(err))
 		return
 	}
 
 	config, err := r.engine.GetBucketOwnershipControls(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket ownership controls", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketOwnershipControls", toS3Error(err))
 		return
 	}
 
 	if config == nil {
-		r.writeError(w, ErrOwnershipControlsNotFound)
+		r.writeError(w, "GetBucketOwnershipControls", ErrOwnershipControlsNotFound)
 		return
 	}
 
 	// Write XML response
 	data, err := xml.Marshal(config)
 	if err != nil {
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketOwnershipControls", ErrInternal)
 		return
 	}
 
@@ -2251,7 +3228,7 @@ func (r *Router) handleGetBucketOwnershipControls(w http.ResponseWriter, req *ht
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(xmlResponse))
-	s3RequestsTotal.WithLabelValues("GetBucketOwnershipControls", "200").Inc()
+	recordOperation(w, "GetBucketOwnershipControls")
 }
 
 // handlePutBucketOwnershipControls handles PUT /bucket?ownership-controls
@@ -2261,7 +3238,7 @@ func (r *Router) handlePutBucketOwnershipControls(w http.ResponseWriter, req *ht
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketOwnershipControls", ErrInternal)
 		return
 	}
 	defer req.Body.Close()
@@ -2269,18 +3246,18 @@ func (r *Router) handlePutBucketOwnershipControls(w http.ResponseWriter, req *ht
 	var config metadata.OwnershipControls
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse ownership controls", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketOwnershipControls", ErrMalformedXML)
 		return
 	}
 
 	if err := r.engine.PutBucketOwnershipControls(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to put bucket ownership controls", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketOwnershipControls", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketOwnershipControls", "200").Inc()
+	recordOperation(w, "PutBucketOwnershipControls")
 }
 
 // handleDeleteBucketOwnershipControls handles DELETE /bucket?ownership-controls
@@ -2289,12 +3266,12 @@ func (r *Router) handleDeleteBucketOwnershipControls(w http.ResponseWriter, req
 
 	if err := r.engine.DeleteBucketOwnershipControls(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket ownership controls", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketOwnershipControls", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketOwnershipControls", "204").Inc()
+	recordOperation(w, "DeleteBucketOwnershipControls")
 }
 
 // handleGetBucketMetrics handles GET /bucket?metrics
@@ -2305,7 +3282,7 @@ func (r *Router) handleGetBucketMetrics(w http.ResponseWriter, req *http.Request
 	_, err := r.engine.GetBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("bucket not found for metrics", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.writeError(w, "GetBucketMetrics", toS3Error(err))
 		return
 	}
 
@@ -2316,19 +3293,19 @@ func (r *Router) handleGetBucketMetrics(w http.ResponseWriter, req *http.Request
 		config, err := r.engine.GetBucketMetrics(ctx, bucket, id)
 		if err != nil {
 			r.logger.Warnw("failed to get bucket metrics", "bucket", bucket, "id", id, "error", err)
-			r.writeError(w, ErrInternal)
+			r.writeError(w, "GetBucketMetrics", toS3Error(err))
 			return
 		}
 
 		if config == nil {
-			r.writeError(w, ErrMetricsNotFound)
+			r.writeError(w, "GetBucketMetrics", ErrMetricsNotFound)
 			return
 		}
 
 		// Write XML response
 		data, err := xml.Marshal(config)
 		if err != nil {
-			r.writeError(w, ErrInternal)
+			r.writeError(w, "GetBucketMetrics", ErrInternal)
 			return
 		}
 
@@ -2338,7 +3315,7 @@ func (r *Router) handleGetBucketMetrics(w http.ResponseWriter, req *http.Request
 		w.Header().Set("Content-Type", "application/xml")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(xmlResponse))
-		s3RequestsTotal.WithLabelValues("GetBucketMetrics", "200").Inc()
+		recordOperation(w, "GetBucketMetrics")
 		return
 	}
 
@@ -2346,7 +3323,7 @@ func (r *Router) handleGetBucketMetrics(w http.ResponseWriter, req *http.Request
 	configs, err := r.engine.ListBucketMetrics(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to list bucket metrics", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketMetrics", toS3Error(err))
 		return
 	}
 
@@ -2361,7 +3338,7 @@ func (r *Router) handleGetBucketMetrics(w http.ResponseWriter, req *http.Request
 	}
 
 	r.writeXML(w, http.StatusOK, response)
-	s3RequestsTotal.WithLabelValues("ListBucketMetrics", "200").Inc()
+	recordOperation(w, "ListBucketMetrics")
 }
 
 // handlePutBucketMetrics handles PUT /bucket?metrics&id={id}
@@ -2371,7 +3348,7 @@ func (r *Router) handlePutBucketMetrics(w http.ResponseWriter, req *http.Request
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketMetrics", ErrInternal)
 		return
 	}
 	defer req.Body.Close()
@@ -2379,7 +3356,7 @@ func (r *Router) handlePutBucketMetrics(w http.ResponseWriter, req *http.Request
 	var config metadata.MetricsConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse metrics", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketMetrics", ErrMalformedXML)
 		return
 	}
 
@@ -2393,12 +3370,12 @@ func (r *Router) handlePutBucketMetrics(w http.ResponseWriter, req *http.Request
 
 	if err := r.engine.PutBucketMetrics(ctx, bucket, id, &config); err != nil {
 		r.logger.Warnw("failed to put bucket metrics", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketMetrics", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketMetrics", "200").Inc()
+	recordOperation(w, "PutBucketMetrics")
 }
 
 // handleDeleteBucketMetrics handles DELETE /bucket?metrics&id={id}
@@ -2412,12 +3389,12 @@ func (r *Router) handleDeleteBucketMetrics(w http.ResponseWriter, req *http.Requ
 
 	if err := r.engine.DeleteBucketMetrics(ctx, bucket, id); err != nil {
 		r.logger.Warnw("failed to delete bucket metrics", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketMetrics", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketMetrics", "204").Inc()
+	recordOperation(w, "DeleteBucketMetrics")
 }
 
 // handleGetBucketReplication handles GET /bucket?replication
@@ -2428,26 +3405,26 @@ func (r *Router) handleGetBucketReplication(w http.ResponseWriter, req *http.Req
 	_, err := r.engine.GetBucket(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("bucket not found for replication", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.writeError(w, "GetBucketReplication", toS3Error(err))
 		return
 	}
 
 	config, err := r.engine.GetReplicationConfig(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket replication", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketReplication", toS3Error(err))
 		return
 	}
 
 	if config == nil {
-		r.writeError(w, ErrReplicationNotFound)
+		r.writeError(w, "GetBucketReplication", ErrReplicationNotFound)
 		return
 	}
 
 	// Write XML response
 	data, err := xml.Marshal(config)
 	if err != nil {
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketReplication", ErrInternal)
 		return
 	}
 
@@ -2457,7 +3434,7 @@ func (r *Router) handleGetBucketReplication(w http.ResponseWriter, req *http.Req
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(xmlResponse))
-	s3RequestsTotal.WithLabelValues("GetBucketReplication", "200").Inc()
+	recordOperation(w, "GetBucketReplication")
 }
 
 // handlePutBucketReplication handles PUT /bucket?replication
@@ -2467,7 +3444,7 @@ func (r *Router) handlePutBucketReplication(w http.ResponseWriter, req *http.Req
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketReplication", ErrInternal)
 		return
 	}
 	defer req.Body.Close()
@@ -2475,18 +3452,18 @@ func (r *Router) handlePutBucketReplication(w http.ResponseWriter, req *http.Req
 	var config metadata.ReplicationConfig
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse replication config", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketReplication", ErrMalformedXML)
 		return
 	}
 
 	if err := r.engine.PutReplicationConfig(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to put bucket replication", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketReplication", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketReplication", "200").Inc()
+	recordOperation(w, "PutBucketReplication")
 }
 
 // handleDeleteBucketReplication handles DELETE /bucket?replication
@@ -2495,147 +3472,134 @@ func (r *Router) handleDeleteBucketReplication(w http.ResponseWriter, req *http.
 
 	if err := r.engine.DeleteReplicationConfig(ctx, bucket); err != nil {
 		r.logger.Warnw("failed to delete bucket replication", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteBucketReplication", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketReplication", "204").Inc()
+	recordOperation(w, "DeleteBucketReplication")
 }
 
 // handleGetBucketAcl handles GET /bucket?acl
 func (r *Router) handleGetBucketAcl(w http.ResponseWriter, req *http.Request, bucket string) {
-	ctx := req.Context()
-
-	// Check if bucket exists
-	_, err := r.engine.GetBucket(ctx, bucket)
+	acl, err := r.engine.GetBucketACL(req.Context(), bucket)
 	if err != nil {
-		r.logger.Warnw("bucket not found for ACL", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+		r.logger.Warnw("failed to get bucket ACL", "bucket", bucket, "error", err)
+		r.writeError(w, "GetBucketAcl", toS3Error(err))
 		return
 	}
 
-	// Return default ACL (owner full control)
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
-	aclResponse := `<?xml version="1.0" encoding="UTF-8"?>
-<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
-  <Owner>
-    <ID>owner</ID>
-    <DisplayName>Owner</DisplayName>
-  </Owner>
-  <AccessControlList>
-    <Grant>
-      <Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser">
-        <ID>owner</ID>
-        <DisplayName>Owner</DisplayName>
-      </Grantee>
-      <Permission>FULL_CONTROL</Permission>
-    </Grant>
-  </AccessControlList>
-</AccessControlPolicy>`
-
-	w.Write([]byte(aclResponse))
-	s3RequestsTotal.WithLabelValues("GetBucketAcl", "200").Inc()
+	r.writeXML(w, http.StatusOK, newACLResult(acl))
+	recordOperation(w, "GetBucketAcl")
 }
 
 // handlePutBucketAcl handles PUT /bucket?acl
 func (r *Router) handlePutBucketAcl(w http.ResponseWriter, req *http.Request, bucket string) {
 	ctx := req.Context()
 
-	// Check if bucket exists
-	_, err := r.engine.GetBucket(ctx, bucket)
-	if err != nil {
-		r.logger.Warnw("bucket not found for ACL", "bucket", bucket, "error", err)
-		r.writeError(w, ErrNoSuchBucket)
+	acl, s3err := r.aclFromRequest(req)
+	if s3err != nil {
+		r.writeError(w, "PutBucketAcl", s3err)
+		return
+	}
+
+	// Public grants are accepted without effect while the bucket blocks
+	// or ignores public ACLs
+	if r.publicACLIgnored(ctx, bucket, acl) {
+		r.logger.Infow("ignoring public ACL", "bucket", bucket)
+		w.WriteHeader(http.StatusOK)
+		recordOperation(w, "PutBucketAcl")
+		return
+	}
+
+	if err := r.engine.PutBucketACL(ctx, bucket, acl); err != nil {
+		r.logger.Warnw("failed to put bucket ACL", "bucket", bucket, "error", err)
+		r.writeError(w, "PutBucketAcl", toS3Error(err))
 		return
 	}
 
-	// For now, just acknowledge the ACL was set
-	// In a full implementation, we'd parse and store the ACL
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketAcl", "200").Inc()
+	recordOperation(w, "PutBucketAcl")
 }
 
 // handleDeleteBucketAcl handles DELETE /bucket?acl
 func (r *Router) handleDeleteBucketAcl(w http.ResponseWriter, req *http.Request, bucket string) {
 	// ACLs cannot actually be deleted, just reset to default
+	if err := r.engine.DeleteBucketACL(req.Context(), bucket); err != nil {
+		r.logger.Warnw("failed to reset bucket ACL", "bucket", bucket, "error", err)
+		r.writeError(w, "DeleteBucketAcl", toS3Error(err))
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteBucketAcl", "204").Inc()
+	recordOperation(w, "DeleteBucketAcl")
 }
 
 // handleGetObjectTags handles GET /bucket/key?tagging
 func (r *Router) handleGetObjectTags(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Check if object exists
-	obj, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
+	versionID := req.URL.Query().Get("versionId")
+	objTags, err := r.engine.GetObjectTags(ctx, bucket, key, versionID)
 	if err != nil {
-		r.logger.Warnw("object not found for tags", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+		r.logger.Warnw("failed to get object tags", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "GetObjectTags", toS3Error(err))
 		return
 	}
 
-	// Return tags if any, otherwise empty tag set
+	// Tags are kept as a map; sort them so responses are stable
+	tagging := tags.Tagging{TagSet: tags.FromMap(objTags)}
+	sort.Slice(tagging.TagSet, func(i, j int) bool {
+		return tagging.TagSet[i].Key < tagging.TagSet[j].Key
+	})
+
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
-
-	tagsXML := `<?xml version="1.0" encoding="UTF-8"?>
-<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
-  <TagSet>`
-
-	if obj.Metadata != nil {
-		for k, v := range obj.Metadata {
-			tagsXML += fmt.Sprintf(`
-    <Tag>
-      <Key>%s</Key>
-      <Value>%s</Value>
-    </Tag>`, tags.EscapeXML(k), tags.EscapeXML(v))
-		}
-	}
-
-	tagsXML += `
-  </TagSet>
-</Tagging>`
-
-	w.Write([]byte(tagsXML))
-	s3RequestsTotal.WithLabelValues("GetObjectTags", "200").Inc()
+	w.Write([]byte(tagging.ToXML()))
+	recordOperation(w, "GetObjectTags")
 }
 
 // handlePutObjectTags handles PUT /bucket/key?tagging
 func (r *Router) handlePutObjectTags(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Check if object exists
-	_, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
+	body, err := readLimitedBody(req.Body)
 	if err != nil {
-		r.logger.Warnw("object not found for tags", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+		r.logger.Warnw("failed to read request body", "error", err)
+		r.writeError(w, "PutObjectTags", ErrInternal)
 		return
 	}
 
-	// Parse tags from body (simplified - just acknowledge for now)
-	// In production, we'd parse the XML and update object metadata
+	tagging, err := tags.FromXML(body)
+	if err != nil {
+		r.logger.Warnw("failed to parse tagging input", "error", err)
+		r.writeError(w, "PutObjectTags", ErrMalformedXML)
+		return
+	}
+
+	versionID := req.URL.Query().Get("versionId")
+	if err := r.engine.PutObjectTags(ctx, bucket, key, versionID, tagging.TagSet); err != nil {
+		r.logger.Warnw("failed to set object tags", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "PutObjectTags", toS3Error(err))
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("PutObjectTags", "204").Inc()
+	recordOperation(w, "PutObjectTags")
 }
 
 // handleDeleteObjectTags handles DELETE /bucket/key?tagging
 func (r *Router) handleDeleteObjectTags(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Check if object exists
-	_, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
-	if err != nil {
-		r.logger.Warnw("object not found for tags", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+	versionID := req.URL.Query().Get("versionId")
+	if err := r.engine.DeleteObjectTags(ctx, bucket, key, versionID); err != nil {
+		r.logger.Warnw("failed to delete object tags", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "DeleteObjectTags", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	s3RequestsTotal.WithLabelValues("DeleteObjectTags", "204").Inc()
+	recordOperation(w, "DeleteObjectTags")
 }
 
 // handleGetBucketNotification handles GET /bucket?notification
@@ -2645,7 +3609,7 @@ func (r *Router) handleGetBucketNotification(w http.ResponseWriter, req *http.Re
 	config, err := r.engine.GetBucketNotification(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket notification", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketNotification", toS3Error(err))
 		return
 	}
 
@@ -2654,8 +3618,17 @@ func (r *Router) handleGetBucketNotification(w http.ResponseWriter, req *http.Re
 		config = &metadata.NotificationConfiguration{}
 	}
 
-	r.writeXML(w, http.StatusOK, config)
-	s3RequestsTotal.WithLabelValues("GetBucketNotification", "200").Inc()
+	// Webhook credentials are never returned, only whether one is set
+	redacted := *config
+	redacted.WebhookConfigurations = make([]metadata.WebhookConfiguration, len(config.WebhookConfigurations))
+	for i, webhook := range config.WebhookConfigurations {
+		webhook.AuthorizationHeaderSet = webhook.AuthorizationHeader != ""
+		webhook.AuthorizationHeader = ""
+		redacted.WebhookConfigurations[i] = webhook
+	}
+
+	r.writeXML(w, http.StatusOK, &redacted)
+	recordOperation(w, "GetBucketNotification")
 }
 
 // handlePutBucketNotification handles PUT /bucket?notification
@@ -2666,7 +3639,7 @@ func (r *Router) handlePutBucketNotification(w http.ResponseWriter, req *http.Re
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketNotification", ErrInternal)
 		return
 	}
 
@@ -2674,19 +3647,19 @@ func (r *Router) handlePutBucketNotification(w http.ResponseWriter, req *http.Re
 	var config metadata.NotificationConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse notification configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketNotification", ErrMalformedXML)
 		return
 	}
 
 	// Save configuration
 	if err := r.engine.PutBucketNotification(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to save bucket notification", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketNotification", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketNotification", "200").Inc()
+	recordOperation(w, "PutBucketNotification")
 }
 
 // handleGetBucketLogging handles GET /bucket?logging
@@ -2696,7 +3669,7 @@ func (r *Router) handleGetBucketLogging(w http.ResponseWriter, req *http.Request
 	config, err := r.engine.GetBucketLogging(ctx, bucket)
 	if err != nil {
 		r.logger.Warnw("failed to get bucket logging", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetBucketLogging", toS3Error(err))
 		return
 	}
 
@@ -2706,7 +3679,7 @@ func (r *Router) handleGetBucketLogging(w http.ResponseWriter, req *http.Request
 	}
 
 	r.writeXML(w, http.StatusOK, config)
-	s3RequestsTotal.WithLabelValues("GetBucketLogging", "200").Inc()
+	recordOperation(w, "GetBucketLogging")
 }
 
 // handlePutBucketLogging handles PUT /bucket?logging
@@ -2717,7 +3690,7 @@ func (r *Router) handlePutBucketLogging(w http.ResponseWriter, req *http.Request
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketLogging", ErrInternal)
 		return
 	}
 
@@ -2725,19 +3698,44 @@ func (r *Router) handlePutBucketLogging(w http.ResponseWriter, req *http.Request
 	var config metadata.LoggingConfiguration
 	if err := xml.Unmarshal(body, &config); err != nil {
 		r.logger.Warnw("failed to parse logging configuration", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "PutBucketLogging", ErrMalformedXML)
 		return
 	}
 
+	// Logs may only be delivered to a bucket of the same owner
+	if config.TargetBucket != "" {
+		owner, err := bucketOwner(ctx, r.engine, bucket)
+		if err != nil {
+			r.writeError(w, "PutBucketLogging", toS3Error(err))
+			return
+		}
+		if targetOwner, err := bucketOwner(ctx, r.engine, config.TargetBucket); err != nil || targetOwner != owner {
+			r.writeError(w, "PutBucketLogging", ErrInvalidTargetBucketForLogging)
+			return
+		}
+	}
+
 	// Save configuration
 	if err := r.engine.PutBucketLogging(ctx, bucket, &config); err != nil {
 		r.logger.Warnw("failed to save bucket logging", "bucket", bucket, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutBucketLogging", toS3Error(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	s3RequestsTotal.WithLabelValues("PutBucketLogging", "200").Inc()
+	recordOperation(w, "PutBucketLogging")
+}
+
+// presignAccessKey returns the access key to sign a presigned URL with: the
+// caller's own, or the configured one for anonymous callers
+func (r *Router) presignAccessKey(req *http.Request) string {
+	if accessKey := auth.RequestAccessKey(req); accessKey != "" {
+		return accessKey
+	}
+	if r.config != nil {
+		return r.config.Auth.AccessKey
+	}
+	return ""
 }
 
 // handleGetPresignedURL handles GET /bucket/key?presignedurl
@@ -2758,10 +3756,10 @@ func (r *Router) handleGetPresignedURL(w http.ResponseWriter, req *http.Request,
 	}
 
 	// Generate presigned URL
-	url, err := r.engine.GeneratePresignedURL(ctx, bucket, key, method, expires)
+	url, err := r.engine.GeneratePresignedURL(ctx, r.presignAccessKey(req), bucket, key, method, expires)
 	if err != nil {
 		r.logger.Warnw("failed to generate presigned URL", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "GetPresignedURL", toS3Error(err))
 		return
 	}
 
@@ -2775,7 +3773,7 @@ func (r *Router) handleGetPresignedURL(w http.ResponseWriter, req *http.Request,
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
-	s3RequestsTotal.WithLabelValues("GetPresignedURL", "200").Inc()
+	recordOperation(w, "GetPresignedURL")
 }
 
 // handlePutPresignedURL handles PUT /bucket/key?presignedurl (for storing custom presigned URLs)
@@ -2786,7 +3784,7 @@ func (r *Router) handlePutPresignedURL(w http.ResponseWriter, req *http.Request,
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutPresignedURL", ErrInternal)
 		return
 	}
 
@@ -2797,15 +3795,15 @@ func (r *Router) handlePutPresignedURL(w http.ResponseWriter, req *http.Request,
 
 	if err := json.Unmarshal(body, &input); err != nil {
 		r.logger.Warnw("failed to parse request body", "error", err)
-		r.writeError(w, ErrInvalidRequest)
+		r.writeError(w, "PutPresignedURL", ErrInvalidRequest)
 		return
 	}
 
 	// Generate presigned URL
-	url, err := r.engine.GeneratePresignedURL(ctx, bucket, key, input.Method, input.Expires)
+	url, err := r.engine.GeneratePresignedURL(ctx, r.presignAccessKey(req), bucket, key, input.Method, input.Expires)
 	if err != nil {
 		r.logger.Warnw("failed to generate presigned URL", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "PutPresignedURL", toS3Error(err))
 		return
 	}
 
@@ -2819,9 +3817,12 @@ func (r *Router) handlePutPresignedURL(w http.ResponseWriter, req *http.Request,
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
-	s3RequestsTotal.WithLabelValues("PutPresignedURL", "200").Inc()
+	recordOperation(w, "PutPresignedURL")
 }
 
+// maxDeleteObjects is the most keys one DeleteObjects request may name
+const maxDeleteObjects = 1000
+
 // handleDeleteObjects handles POST /bucket?delete (batch delete)
 func (r *Router) handleDeleteObjects(w http.ResponseWriter, req *http.Request, bucket string) {
 	ctx := req.Context()
@@ -2830,142 +3831,217 @@ func (r *Router) handleDeleteObjects(w http.ResponseWriter, req *http.Request, b
 	body, err := readLimitedBody(req.Body)
 	if err != nil {
 		r.logger.Warnw("failed to read request body", "error", err)
-		r.writeError(w, ErrInternal)
+		r.writeError(w, "DeleteObjects", ErrInternal)
 		return
 	}
 
 	var input s3types.DeleteObjectsInput
 	if err := xml.Unmarshal(body, &input); err != nil {
 		r.logger.Warnw("failed to parse delete input", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "DeleteObjects", ErrMalformedXML)
 		return
 	}
 
-	// Delete objects
-	var deleted []s3types.DeletedObject
-	var errors []s3types.DeleteError
+	if len(input.Objects) > maxDeleteObjects {
+		r.writeError(w, "DeleteObjects", withMessage(ErrMalformedXML, fmt.Sprintf("The request must contain no more than %d keys", maxDeleteObjects)))
+		return
+	}
 
+	// Keys that fail are reported one by one with the error S3 would have
+	// returned for a single DeleteObject; the request itself still succeeds.
+	// In quiet mode only the failures are listed.
+	var resp s3types.DeleteObjectsOutput
+	bypass := strings.EqualFold(req.Header.Get("x-amz-bypass-governance-retention"), "true")
 	for _, obj := range input.Objects {
-		err := r.engine.DeleteObject(ctx, bucket, obj.Key, engine.DeleteObjectOptions{
-			VersionID: obj.VersionID,
+		result, err := r.engine.DeleteObject(ctx, bucket, obj.Key, engine.DeleteObjectOptions{
+			VersionID:        obj.VersionID,
+			BypassGovernance: bypass,
 		})
 		if err != nil {
-			errors = append(errors, s3types.DeleteError{
+			s3err := toS3Error(err)
+			if s3err.StatusCode() == http.StatusInternalServerError {
+				r.logger.Warnw("failed to delete object", "bucket", bucket, "key", obj.Key, "error", err)
+			}
+			resp.Errors = append(resp.Errors, s3types.DeleteError{
 				Key:       obj.Key,
 				VersionID: obj.VersionID,
-				Code:      "AccessDenied",
-				Message:   err.Error(),
+				Code:      s3err.Code(),
+				Message:   s3err.Message(),
 			})
-		} else {
-			deleted = append(deleted, s3types.DeletedObject{
+		} else if !input.Quiet {
+			deleted := s3types.DeletedObject{
 				Key:       obj.Key,
 				VersionID: obj.VersionID,
-			})
+			}
+			if result.DeleteMarker {
+				deleted.DeleteMarker = true
+				deleted.DeleteMarkerVersionID = result.VersionID
+			}
+			resp.Deleted = append(resp.Deleted, deleted)
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-
-	resp := s3types.DeleteObjectsOutput{
-		Deleted: deleted,
-		Errors:  errors,
-	}
-	xmlBytes, _ := xml.Marshal(resp)
-	w.Write(xmlBytes)
-
-	s3RequestsTotal.WithLabelValues("DeleteObjects", "200").Inc()
+	r.writeXML(w, http.StatusOK, resp)
+	recordOperation(w, "DeleteObjects")
 }
 
 // handleSelectObjectContent handles S3 Select (POST /bucket/key?select)
 func (r *Router) handleSelectObjectContent(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Get the object first
-	obj, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
-	if err != nil {
-		r.logger.Warnw("failed to get object for select", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
-		return
-	}
-	defer obj.Body.Close()
-
-	// Read the body
-	data, err := io.ReadAll(obj.Body)
+	// Parse the S3 Select request body
+	body, err := readLimitedBody(req.Body)
 	if err != nil {
-		r.logger.Warnw("failed to read object data", "error", err)
-		r.writeError(w, ErrInternal)
+		r.logger.Warnw("failed to read request body", "error", err)
+		r.writeError(w, "SelectObjectContent", ErrInternal)
 		return
 	}
-
-	// Parse the S3 Select request body
 	var selectInput s3types.SelectObjectContentRequest
-	if err := xml.Unmarshal(data, &selectInput); err != nil {
+	if err := xml.Unmarshal(body, &selectInput); err != nil {
 		r.logger.Warnw("failed to parse select input", "error", err)
-		r.writeError(w, ErrMalformedXML)
+		r.writeError(w, "SelectObjectContent", ErrMalformedXML)
 		return
 	}
 
-	// Determine input format (CSV or JSON)
-	inputFormat := s3select.FormatJSON
-	if selectInput.InputSerialization.CSV != nil {
-		inputFormat = s3select.FormatCSV
+	obj, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
+	if err != nil {
+		r.logger.Warnw("failed to get object for select", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "SelectObjectContent", toS3Error(err))
+		return
 	}
+	defer obj.Body.Close()
 
-	// Create select request
+	// Create select request. The object's stored Content-Encoding is passed
+	// along so a gzip-encoded object is decompressed even when the request
+	// names no CompressionType.
 	selectReq := &s3select.SelectRequest{
 		Bucket:     bucket,
 		Key:        key,
 		Expression: selectInput.Expression,
 		InputSerialization: s3select.InputSerialization{
-			Format: inputFormat,
+			Format:          s3select.FormatJSON,
+			CompressionType: selectInput.InputSerialization.CompressionType,
 		},
+		OutputSerialization: s3select.OutputSerialization{Format: s3select.OutputJSON},
+		ContentEncoding:     obj.ContentEncoding,
+	}
+	if in := selectInput.InputSerialization.CSV; in != nil {
+		selectReq.InputSerialization.Format = s3select.FormatCSV
+		selectReq.InputSerialization.CSV = &s3select.CSVInput{
+			FileHeaderInfo:  in.FileHeaderInfo,
+			RecordDelimiter: in.RecordDelimiter,
+			FieldDelimiter:  in.FieldDelimiter,
+			QuoteCharacter:  in.QuoteCharacter,
+		}
+	} else if in := selectInput.InputSerialization.JSON; in != nil {
+		selectReq.InputSerialization.JSON = &s3select.JSONInput{Type: in.Type}
+	}
+
+	// Records are written in the input format unless another is requested
+	switch out := selectInput.OutputSerialization; {
+	case out.JSON != nil:
+		selectReq.OutputSerialization.JSON = &s3select.JSONOutput{RecordDelimiter: out.JSON.RecordDelimiter}
+	case out.CSV != nil:
+		selectReq.OutputSerialization = s3select.OutputSerialization{
+			Format: s3select.OutputCSV,
+			CSV: &s3select.CSVOutput{
+				RecordDelimiter: out.CSV.RecordDelimiter,
+				FieldDelimiter:  out.CSV.FieldDelimiter,
+				QuoteCharacter:  out.CSV.QuoteCharacter,
+			},
+		}
+	case selectReq.InputSerialization.Format == s3select.FormatCSV:
+		selectReq.OutputSerialization.Format = s3select.OutputCSV
 	}
 
-	// Execute select - pass the original object data, not the request body
-	result, err := r.selectService.Execute(ctx, selectReq, bytes.NewReader(data))
+	result, err := r.selectService.Execute(ctx, selectReq, obj.Body)
 	if err != nil {
 		r.logger.Warnw("failed to execute select", "error", err)
-		r.writeError(w, ErrInternal)
+		if errors.Is(err, s3select.ErrUnsupportedCompression) {
+			r.writeError(w, "SelectObjectContent", ErrInvalidRequest)
+			return
+		}
+		if errors.Is(err, s3select.ErrInvalidExpression) {
+			r.writeError(w, "SelectObjectContent", withMessage(ErrInvalidArgument, err.Error()))
+			return
+		}
+		r.writeError(w, "SelectObjectContent", ErrInternal)
 		return
 	}
 
-	// Write response
-	w.Header().Set("Content-Type", "application/json")
+	// Write the result as an event stream of Records, Stats and End events
+	w.Header().Set("Content-Type", s3select.EventStreamContentType)
 	w.WriteHeader(http.StatusOK)
-	w.Write(result.Payload)
+	if err := s3select.WriteEventStream(w, result); err != nil {
+		r.logger.Warnw("failed to write select response", "error", err)
+		return
+	}
 
-	s3RequestsTotal.WithLabelValues("SelectObjectContent", "200").Inc()
+	recordOperation(w, "SelectObjectContent")
 }
 
-// handleRestoreObject handles POST /bucket/key?restore (Glacier restore)
+// handleRenameObject handles POST /bucket/key?rename=newkey, an extension
+// that renames an object within its bucket without copying its data where
+// the storage backend allows. It is only served when storage.enable_rename
+// is set.
+func (r *Router) handleRenameObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
+	if r.config == nil || !r.config.Storage.EnableRename {
+		r.writeError(w, "RenameObject", ErrNotImplemented)
+		return
+	}
+
+	newKey := req.URL.Query().Get("rename")
+	if err := r.engine.MoveObject(req.Context(), bucket, key, bucket, newKey); err != nil {
+		r.logger.Warnw("failed to rename object", "bucket", bucket, "key", key, "newKey", newKey, "error", err)
+		r.writeError(w, "RenameObject", toS3Error(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	recordOperation(w, "RenameObject")
+}
+
+// handleRestoreObject handles POST /bucket/key?restore (Glacier restore).
+// The restored copy is readable right away for the requested number of
+// days; restoring an object that is already restored only moves its expiry.
 func (r *Router) handleRestoreObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
 	ctx := req.Context()
 
-	// Get the object
-	obj, err := r.engine.GetObject(ctx, bucket, key, engine.GetObjectOptions{})
+	body, err := readLimitedBody(req.Body)
 	if err != nil {
-		r.logger.Warnw("object not found for restore", "bucket", bucket, "key", key, "error", err)
-		r.writeError(w, ErrNoSuchKey)
+		r.logger.Warnw("failed to read request body", "error", err)
+		r.writeError(w, "RestoreObject", ErrInternal)
 		return
 	}
 
-	// Check if object is in Glacier storage class
-	if obj.StorageClass != "GLACIER" && obj.StorageClass != "DEEP_ARCHIVE" {
-		r.writeError(w, ErrInvalidObjectState)
+	input := s3types.RestoreRequest{Days: 1}
+	if len(body) > 0 {
+		if err := xml.Unmarshal(body, &input); err != nil {
+			r.writeError(w, "RestoreObject", ErrMalformedXML)
+			return
+		}
+	}
+	if input.Days < 1 {
+		r.writeError(w, "RestoreObject", withMessage(ErrInvalidArgument, "Days must be a positive integer"))
 		return
 	}
+	tier := input.Tier
+	if input.GlacierJobParameters != nil && input.GlacierJobParameters.Tier != "" {
+		tier = input.GlacierJobParameters.Tier
+	}
 
-	// For now, just acknowledge the restore request
-	// In a full implementation, this would initiate an async restore job
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusAccepted)
-
-	restoreResponse := `<?xml version="1.0" encoding="UTF-8"?>
-<RestoreJob>
-  <JobDescription>Restore initiated</JobDescription>
-</RestoreJob>`
+	restored, err := r.engine.RestoreObject(ctx, bucket, key, engine.RestoreObjectOptions{Days: input.Days, Tier: tier})
+	if err != nil {
+		r.logger.Warnw("failed to restore object", "bucket", bucket, "key", key, "error", err)
+		r.writeError(w, "RestoreObject", toS3Error(err))
+		return
+	}
 
-	w.Write([]byte(restoreResponse))
-	s3RequestsTotal.WithLabelValues("RestoreObject", "202").Inc()
+	if restored {
+		w.WriteHeader(http.StatusOK)
+		recordOperation(w, "RestoreObject")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	recordOperation(w, "RestoreObject")
 }
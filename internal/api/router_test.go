@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"go.uber.org/zap"
 )
 
@@ -366,7 +368,7 @@ func TestWriteError(t *testing.T) {
 	router := &Router{logger: logger.Sugar()}
 
 	w := httptest.NewRecorder()
-	router.writeError(w, ErrNoSuchBucket)
+	router.writeError(w, "Test", ErrNoSuchBucket)
 
 	if w.Code != http.StatusNotFound {
 		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
@@ -499,3 +501,69 @@ func TestAllS3Errors(t *testing.T) {
 		}
 	}
 }
+
+// counterValue returns the current value of a counter
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("reading counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestErrorCodeLabel(t *testing.T) {
+	if got := errorCodeLabel(ErrSignatureDoesNotMatch); got != "SignatureDoesNotMatch" {
+		t.Errorf("errorCodeLabel() = %q, want SignatureDoesNotMatch", got)
+	}
+	if got := errorCodeLabel(foreignError{}); got != "Other" {
+		t.Errorf("errorCodeLabel() = %q, want Other", got)
+	}
+}
+
+// foreignError is an S3Error not declared in this package
+type foreignError struct{}
+
+func (foreignError) Code() string    { return "Dynamic-1234" }
+func (foreignError) Message() string { return "dynamic" }
+func (foreignError) StatusCode() int { return 500 }
+func (foreignError) Error() string   { return "dynamic" }
+
+func TestRequestOperation(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		copy   bool
+		want   string
+	}{
+		{"GET", "/s3/", false, "ListBuckets"},
+		{"GET", "/s3/bucket", false, "ListObjects"},
+		{"GET", "/s3/bucket/key", false, "GetObject"},
+		{"GET", "/s3/bucket/key?uploadId=u1", false, "ListParts"},
+		{"HEAD", "/s3/bucket", false, "HeadBucket"},
+		{"HEAD", "/s3/bucket/key", false, "HeadObject"},
+		{"PUT", "/s3/bucket", false, "CreateBucket"},
+		{"PUT", "/s3/bucket/key", false, "PutObject"},
+		{"PUT", "/s3/bucket/key", true, "CopyObject"},
+		{"PUT", "/s3/bucket/key?partNumber=1&uploadId=u1", false, "UploadPart"},
+		{"PUT", "/s3/bucket/key?partNumber=1&uploadId=u1", true, "UploadPartCopy"},
+		{"POST", "/s3/bucket/key?uploads", false, "CreateMultipartUpload"},
+		{"POST", "/s3/bucket/key?uploadId=u1", false, "CompleteMultipartUpload"},
+		{"POST", "/s3/bucket?delete=", false, "PostObject"},
+		{"POST", "/s3/bucket?delete=1", false, "DeleteObjects"},
+		{"DELETE", "/s3/bucket", false, "DeleteBucket"},
+		{"DELETE", "/s3/bucket/key", false, "DeleteObject"},
+		{"DELETE", "/s3/bucket/key?uploadId=u1", false, "AbortMultipartUpload"},
+		{"PATCH", "/s3/bucket/key", false, "PATCH"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.copy {
+			req.Header.Set("x-amz-copy-source", "/src/key")
+		}
+		if got := requestOperation(req); got != tt.want {
+			t.Errorf("requestOperation(%s %s) = %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// WebsitePathPrefix is where ServeWebsite is mounted. A bucket's site is
+// served under WebsitePathPrefix + bucket + "/".
+const WebsitePathPrefix = "/website/"
+
+// ServeWebsite serves buckets with a website configuration as static sites,
+// like the S3 website endpoint. Requests are anonymous and get raw object
+// bodies or HTML error pages rather than S3 XML responses. As in S3, an
+// object is only served when the bucket policy grants everyone
+// s3:GetObject on it and the bucket's public access block does not restrict
+// public buckets; other requests get 403 AccessDenied.
+//
+// Directory-style requests get the IndexDocument, a missing key gets the
+// ErrorDocument with a 404, and RoutingRules and RedirectAllRequestsTo
+// redirect as in S3.
+func (r *Router) ServeWebsite(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeWebsiteError(w, req, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, WebsitePathPrefix), "/")
+	if bucket == "" {
+		writeWebsiteError(w, req, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		return
+	}
+
+	ctx := req.Context()
+	if err := r.engine.HeadBucket(ctx, bucket); err != nil {
+		writeWebsiteError(w, req, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		return
+	}
+	config, err := r.engine.GetBucketWebsite(ctx, bucket)
+	if err != nil {
+		r.logger.Warnw("failed to get bucket website", "bucket", bucket, "error", err)
+		writeWebsiteError(w, req, http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again.")
+		return
+	}
+	if config == nil {
+		writeWebsiteError(w, req, http.StatusNotFound, "NoSuchWebsiteConfiguration", "The specified bucket does not have a website configuration.")
+		return
+	}
+
+	if to := config.RedirectAllRequestsTo; to != nil && to.HostName != "" {
+		protocol := to.Protocol
+		if protocol == "" {
+			protocol = requestScheme(req)
+		}
+		http.Redirect(w, req, protocol+"://"+to.HostName+"/"+key, http.StatusMovedPermanently)
+		return
+	}
+
+	// Rules without an error code condition redirect before the lookup
+	for _, rule := range config.RoutingRules {
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			continue
+		}
+		if routingRuleMatches(rule, key) {
+			r.websiteRedirect(w, req, bucket, key, rule)
+			return
+		}
+	}
+
+	index := ""
+	if config.IndexDocument != nil {
+		index = config.IndexDocument.Suffix
+	}
+	objectKey := key
+	if key == "" || strings.HasSuffix(key, "/") {
+		objectKey = key + index
+	}
+
+	if objectKey != "" && !strings.HasSuffix(objectKey, "/") {
+		if r.serveWebsiteObject(w, req, bucket, objectKey, http.StatusOK) {
+			return
+		}
+		// A directory requested without its trailing slash is redirected
+		// to it, as in S3
+		if objectKey == key && index != "" {
+			if _, err := r.engine.HeadObject(ctx, bucket, key+"/"+index); err == nil {
+				http.Redirect(w, req, WebsitePathPrefix+bucket+"/"+key+"/", http.StatusFound)
+				return
+			}
+		}
+	}
+
+	for _, rule := range config.RoutingRules {
+		if rule.Condition == nil || rule.Condition.HttpErrorCodeReturnedEquals != "404" {
+			continue
+		}
+		if routingRuleMatches(rule, key) {
+			r.websiteRedirect(w, req, bucket, key, rule)
+			return
+		}
+	}
+
+	if config.ErrorDocument != nil && config.ErrorDocument.Key != "" {
+		if r.serveWebsiteObject(w, req, bucket, config.ErrorDocument.Key, http.StatusNotFound) {
+			return
+		}
+	}
+	writeWebsiteError(w, req, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+}
+
+// serveWebsiteObject writes an object's body with status, or 403 if the
+// object is not public. It returns false, having written nothing, if the
+// object cannot be read.
+func (r *Router) serveWebsiteObject(w http.ResponseWriter, req *http.Request, bucket, key string, status int) bool {
+	if !r.bucketPolicyAllowsAnonymous(req, bucket, bucket+"/"+key, "s3:GetObject") ||
+		r.publicAccessBlock(req.Context(), bucket).RestrictPublicBuckets {
+		writeWebsiteError(w, req, http.StatusForbidden, "AccessDenied", "Access Denied")
+		return true
+	}
+
+	opts := engine.GetObjectOptions{}
+	if status == http.StatusOK {
+		opts.IfNoneMatch = req.Header.Get("If-None-Match")
+		opts.IfModifiedSince = req.Header.Get("If-Modified-Since")
+	}
+	obj, err := r.engine.GetObject(req.Context(), bucket, key, opts)
+	if errors.Is(err, engine.ErrNotModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	if err != nil {
+		if !errors.Is(err, engine.ErrObjectNotFound) {
+			r.logger.Warnw("failed to get website object", "bucket", bucket, "key", key, "error", err)
+		}
+		return false
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Content-Type", sanitizeHeaderValue(r.contentTypeFor(key, obj.ContentType)))
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("ETag", sanitizeHeaderValue(obj.ETag))
+	if obj.LastModified != 0 {
+		w.Header().Set("Last-Modified", time.Unix(obj.LastModified, 0).UTC().Format(http.TimeFormat))
+	}
+	if obj.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", sanitizeHeaderValue(obj.ContentEncoding))
+	}
+	if obj.CacheControl != "" {
+		w.Header().Set("Cache-Control", sanitizeHeaderValue(obj.CacheControl))
+	}
+	w.WriteHeader(status)
+
+	if req.Method == http.MethodHead {
+		return true
+	}
+	if n, err := io.Copy(w, obj.Body); err != nil {
+		r.logger.Warnw("failed to stream website object", "bucket", bucket, "key", key, "written", n, "error", err)
+	}
+	return true
+}
+
+// routingRuleMatches reports whether a rule's key prefix condition holds for
+// key. A rule without one matches every key.
+func routingRuleMatches(rule metadata.RoutingRule, key string) bool {
+	if rule.Redirect == nil {
+		return false
+	}
+	return rule.Condition == nil || strings.HasPrefix(key, rule.Condition.KeyPrefixEquals)
+}
+
+// websiteRedirect redirects a request as a routing rule says. The key is
+// replaced whole by ReplaceKeyWith, or has the matched prefix replaced by
+// ReplaceKeyPrefixWith. Redirects that keep the host stay on the bucket's
+// site.
+func (r *Router) websiteRedirect(w http.ResponseWriter, req *http.Request, bucket, key string, rule metadata.RoutingRule) {
+	redirect := rule.Redirect
+
+	switch {
+	case redirect.ReplaceKeyWith != "":
+		key = redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != "":
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+
+	location := WebsitePathPrefix + bucket + "/" + key
+	if redirect.HostName != "" {
+		location = "/" + key
+	}
+	if redirect.HostName != "" || redirect.Protocol != "" {
+		protocol := redirect.Protocol
+		if protocol == "" {
+			protocol = requestScheme(req)
+		}
+		host := redirect.HostName
+		if host == "" {
+			host = req.Host
+		}
+		location = protocol + "://" + host + location
+	}
+
+	code := http.StatusMovedPermanently
+	if redirect.HttpRedirectCode != "" {
+		if c, err := strconv.Atoi(redirect.HttpRedirectCode); err == nil && c >= 300 && c <= 399 {
+			code = c
+		}
+	}
+	http.Redirect(w, req, location, code)
+}
+
+// requestScheme returns the scheme a request was made with
+func requestScheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// writeWebsiteError writes an HTML error page, as the S3 website endpoint
+// does in place of an XML error document
+func writeWebsiteError(w http.ResponseWriter, req *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if req.Method == http.MethodHead {
+		return
+	}
+	title := fmt.Sprintf("%d %s", status, http.StatusText(status))
+	fmt.Fprintf(w, "<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n<li>Code: %s</li>\n<li>Message: %s</li>\n</ul>\n</body>\n</html>\n",
+		title, title, html.EscapeString(code), html.EscapeString(message))
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+func TestRouter_ServeWebsite(t *testing.T) {
+	router, cleanup := createTestAPIRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "site")
+	router.engine.CreateBucket(ctx, "plain")
+	for key, body := range map[string]string{
+		"index.html":      "home",
+		"about.html":      "about",
+		"docs/index.html": "docs home",
+		"404.html":        "not found",
+	} {
+		if _, err := router.engine.PutObject(ctx, "site", key, bytes.NewBufferString(body), engine.PutObjectOptions{ContentType: "text/html"}); err != nil {
+			t.Fatalf("PutObject(%s) error: %v", key, err)
+		}
+	}
+	err := router.engine.PutBucketWebsite(ctx, "site", &metadata.WebsiteConfiguration{
+		IndexDocument: &metadata.IndexDocument{Suffix: "index.html"},
+		ErrorDocument: &metadata.ErrorDocument{Key: "404.html"},
+		RoutingRules: []metadata.RoutingRule{
+			{
+				Condition: &metadata.RoutingCondition{KeyPrefixEquals: "old/"},
+				Redirect:  &metadata.RoutingRedirect{ReplaceKeyPrefixWith: "docs/"},
+			},
+			{
+				Condition: &metadata.RoutingCondition{KeyPrefixEquals: "blog/", HttpErrorCodeReturnedEquals: "404"},
+				Redirect:  &metadata.RoutingRedirect{HostName: "blog.example.com", Protocol: "https", HttpRedirectCode: "302"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PutBucketWebsite() error: %v", err)
+	}
+	policy := `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::site/*"}]}`
+	if err := router.engine.PutBucketPolicy(ctx, "site", &policy); err != nil {
+		t.Fatalf("PutBucketPolicy() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"index document", "GET", "/website/site/", 200, "home", ""},
+		{"bucket root without slash", "GET", "/website/site", 200, "home", ""},
+		{"object", "GET", "/website/site/about.html", 200, "about", ""},
+		{"subdirectory index", "GET", "/website/site/docs/", 200, "docs home", ""},
+		{"subdirectory without slash", "GET", "/website/site/docs", 302, "", "/website/site/docs/"},
+		{"error document", "GET", "/website/site/missing.html", 404, "not found", ""},
+		{"prefix redirect", "GET", "/website/site/old/page.html", 301, "", "/website/site/docs/page.html"},
+		{"error code redirect", "GET", "/website/site/blog/post", 302, "", "https://blog.example.com/blog/post"},
+		{"head", "HEAD", "/website/site/about.html", 200, "", ""},
+		{"no website configuration", "GET", "/website/plain/", 404, "NoSuchWebsiteConfiguration", ""},
+		{"no bucket", "GET", "/website/none/", 404, "NoSuchBucket", ""},
+		{"write", "PUT", "/website/site/about.html", 405, "MethodNotAllowed", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeWebsite(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.name, w.Body.String(), tt.body)
+		}
+		if tt.method == "HEAD" && w.Body.Len() != 0 {
+			t.Errorf("%s: HEAD returned a body", tt.name)
+		}
+		if got := w.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.name, got, tt.location)
+		}
+	}
+}
+
+func TestRouter_ServeWebsiteRedirectAll(t *testing.T) {
+	router, cleanup := createTestAPIRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "site")
+	router.engine.PutBucketWebsite(ctx, "site", &metadata.WebsiteConfiguration{
+		RedirectAllRequestsTo: &metadata.RedirectAllRequestsTo{HostName: "www.example.com", Protocol: "https"},
+	})
+
+	req := httptest.NewRequest("GET", "/website/site/a/b.html", nil)
+	w := httptest.NewRecorder()
+	router.ServeWebsite(w, req)
+
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://www.example.com/a/b.html" {
+		t.Errorf("redirect = %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestRouter_ServeWebsitePrivateBucket(t *testing.T) {
+	router, cleanup := createTestAPIRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, bucket := range []string{"private", "restricted"} {
+		router.engine.CreateBucket(ctx, bucket)
+		router.engine.PutObject(ctx, bucket, "index.html", bytes.NewBufferString("secret"), engine.PutObjectOptions{ContentType: "text/html"})
+		router.engine.PutObject(ctx, bucket, "404.html", bytes.NewBufferString("secret 404"), engine.PutObjectOptions{ContentType: "text/html"})
+		router.engine.PutBucketWebsite(ctx, bucket, &metadata.WebsiteConfiguration{
+			IndexDocument: &metadata.IndexDocument{Suffix: "index.html"},
+			ErrorDocument: &metadata.ErrorDocument{Key: "404.html"},
+		})
+	}
+	// A public policy does not help while the bucket restricts public access
+	policy := `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::restricted/*"}]}`
+	router.engine.PutBucketPolicy(ctx, "restricted", &policy)
+	router.engine.PutPublicAccessBlock(ctx, "restricted", &metadata.PublicAccessBlockConfiguration{RestrictPublicBuckets: true})
+
+	for _, path := range []string{"/website/private/", "/website/private/index.html", "/website/private/missing.html", "/website/restricted/"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeWebsite(w, req)
+
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "AccessDenied") {
+			t.Errorf("GET %s = %d %q, want 403 AccessDenied", path, w.Code, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("GET %s served the private object", path)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/events"
 	"go.uber.org/zap"
 )
 
@@ -411,3 +412,37 @@ func (l *Logger) LogEvent(ctx context.Context, eventType EventType, tenantID, us
 
 	l.Log(event)
 }
+
+// HandleObjectEvent records an object event from the engine's event bus
+func (l *Logger) HandleObjectEvent(e events.ObjectEvent) {
+	eventType := EventObjectPut
+	switch e.Type {
+	case events.EventObjectCopied:
+		eventType = EventObjectCopy
+	case events.EventObjectRemoved, events.EventObjectDeleteMarkerCreated:
+		eventType = EventObjectDeleted
+	}
+
+	details := map[string]interface{}{
+		"bucket":     e.Bucket,
+		"object":     e.Key,
+		"size":       e.Size,
+		"etag":       e.ETag,
+		"version_id": e.VersionID,
+	}
+	if e.SourceBucket != "" {
+		details["source"] = e.SourceBucket + "/" + e.SourceKey
+	}
+	if e.UploadID != "" {
+		details["upload_id"] = e.UploadID
+	}
+
+	l.Log(&Event{
+		Timestamp: e.Time,
+		EventType: eventType,
+		Resource:  e.Bucket + "/" + e.Key,
+		Action:    string(e.Type),
+		Status:    "success",
+		Details:   details,
+	})
+}
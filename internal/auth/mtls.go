@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/openendpoint/openendpoint/internal/config"
+)
+
+// ServerTLSConfig builds the TLS configuration for the S3 listener. When a
+// client CA file is configured, clients must present a certificate chaining
+// to it and the handshake fails for anyone else.
+func ServerTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pemData, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// SetClientCertIdentities maps verified client certificate common names to
+// the access keys they authenticate as. With requireSignature set, mTLS
+// clients must still sign requests with SigV4 using that access key.
+func (a *Auth) SetClientCertIdentities(identities map[string]string, requireSignature bool) {
+	a.certIdentities = make(map[string]string, len(identities))
+	for commonName, accessKey := range identities {
+		a.certIdentities[commonName] = accessKey
+	}
+	a.certRequireSignature = requireSignature
+}
+
+// ClientCertAccessKey returns the access key a request is authenticated as
+// by its client certificate. Only certificates verified during the TLS
+// handshake count, and only when their common name is mapped to an access
+// key with a known credential.
+func (a *Auth) ClientCertAccessKey(req *http.Request) (string, bool) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	accessKey, ok := a.certIdentities[req.TLS.VerifiedChains[0][0].Subject.CommonName]
+	if !ok {
+		return "", false
+	}
+	if _, ok := a.GetCredential(accessKey); !ok {
+		return "", false
+	}
+	return accessKey, true
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/config"
+)
+
+// testCert is a certificate with its key, usable as a CA or leaf
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert issues a certificate for commonName, self-signed when parent
+// is nil
+func newTestCert(t *testing.T, commonName string, parent *testCert, isCA bool, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("serial failed: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// writePEM writes the certificate and its key to dir as PEM files
+func (c *testCert) writePEM(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	certFile = filepath.Join(dir, name+".crt")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey failed: %v", err)
+	}
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "test-ca", nil, true, 0)
+	server := newTestCert(t, "localhost", ca, false, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, "build-agent", ca, false, x509.ExtKeyUsageClientAuth)
+
+	untrustedCA := newTestCert(t, "other-ca", nil, true, 0)
+	untrusted := newTestCert(t, "build-agent", untrustedCA, false, x509.ExtKeyUsageClientAuth)
+
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	certFile, keyFile := server.writePEM(t, dir, "server")
+
+	tlsConfig, err := ServerTLSConfig(config.TLSConfig{
+		Enabled:      true,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+	})
+	if err != nil {
+		t.Fatalf("ServerTLSConfig failed: %v", err)
+	}
+
+	a := New(config.AuthConfig{AccessKey: "agent-key", SecretKey: "agent-secret-123"})
+	a.SetClientCertIdentities(map[string]string{"build-agent": "agent-key"}, false)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessKey, ok := a.ClientCertAccessKey(r)
+		if !ok {
+			t.Error("ClientCertAccessKey found no identity for a verified client")
+		}
+		if accessKey != "agent-key" {
+			t.Errorf("ClientCertAccessKey = %q, want agent-key", accessKey)
+		}
+		// No Authorization header: the certificate alone authenticates
+		if err := a.Authorize(r, "bucket", "s3:GetObject"); err != nil {
+			t.Errorf("Authorize failed for mTLS client: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientFor := func(cert *testCert) *http.Client {
+		tlsClient := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			tlsClient.Certificates = []tls.Certificate{cert.tlsCertificate()}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsClient}}
+	}
+
+	t.Run("trusted client certificate", func(t *testing.T) {
+		resp, err := clientFor(client).Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %d, want 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("untrusted client certificate", func(t *testing.T) {
+		resp, err := clientFor(untrusted).Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("request with an untrusted client certificate succeeded")
+		}
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		resp, err := clientFor(nil).Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("request without a client certificate succeeded")
+		}
+	})
+}
+
+func TestAuthorize_ClientCertRequireSignature(t *testing.T) {
+	ca := newTestCert(t, "test-ca", nil, true, 0)
+	client := newTestCert(t, "build-agent", ca, false, x509.ExtKeyUsageClientAuth)
+
+	a := New(config.AuthConfig{AccessKey: "agent-key", SecretKey: "agent-secret-123"})
+	a.SetClientCertIdentities(map[string]string{"build-agent": "agent-key"}, true)
+
+	req, _ := http.NewRequest("GET", "http://localhost/bucket/key", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{client.cert, ca.cert}}}
+
+	if _, ok := a.ClientCertAccessKey(req); !ok {
+		t.Fatal("ClientCertAccessKey found no identity")
+	}
+	if err := a.Authorize(req, "bucket", "s3:GetObject"); err == nil {
+		t.Error("Authorize should require a signature when RequireSignature is set")
+	}
+
+	// A signature from a different access key is refused even if valid
+	a.AddCredential("other-key", "other-secret-123")
+	req.Header.Set("Authorization", "AWS other-key:c2lnbmF0dXJl")
+	if err := a.Authorize(req, "bucket", "s3:GetObject"); err == nil {
+		t.Error("Authorize should reject a signature from another access key")
+	}
+}
+
+func TestClientCertAccessKey_Unmapped(t *testing.T) {
+	ca := newTestCert(t, "test-ca", nil, true, 0)
+	client := newTestCert(t, "stranger", ca, false, x509.ExtKeyUsageClientAuth)
+
+	a := New(config.AuthConfig{AccessKey: "agent-key", SecretKey: "agent-secret-123"})
+	a.SetClientCertIdentities(map[string]string{"build-agent": "agent-key"}, false)
+
+	req, _ := http.NewRequest("GET", "http://localhost/bucket/key", nil)
+	if _, ok := a.ClientCertAccessKey(req); ok {
+		t.Error("plain HTTP request should have no certificate identity")
+	}
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{client.cert, ca.cert}}}
+	if _, ok := a.ClientCertAccessKey(req); ok {
+		t.Error("unmapped common name should have no identity")
+	}
+	if err := a.Authorize(req, "bucket", "s3:GetObject"); err == nil {
+		t.Error("unmapped certificate without a signature should not be authorized")
+	}
+}
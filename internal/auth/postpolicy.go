@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPostPolicy is returned for a browser-based POST upload whose form its
+// policy document does not allow
+var ErrPostPolicy = errors.New("invalid according to policy")
+
+// ErrPostPolicyExpired is returned for a POST upload whose policy document
+// has expired
+var ErrPostPolicyExpired = errors.New("policy expired")
+
+// PostPolicy is the policy document signed for a browser-based POST upload.
+// It limits what the form may upload: every form field must match one of
+// its conditions, and the file's size must fall within its
+// content-length-range, when it has one.
+type PostPolicy struct {
+	Expiration time.Time
+
+	// MinLength and MaxLength bound the size of the uploaded file when
+	// HasLengthRange is set
+	MinLength      int64
+	MaxLength      int64
+	HasLengthRange bool
+
+	conditions []postCondition
+}
+
+// postCondition is an "eq" or "starts-with" condition on a form field
+type postCondition struct {
+	match string
+	field string
+	value string
+}
+
+// ParsePostPolicy decodes a base64 policy document, as sent in the policy
+// field of a POST upload form
+func ParsePostPolicy(encoded string) (*PostPolicy, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: policy is not valid base64", ErrPostPolicy)
+	}
+
+	var doc struct {
+		Expiration string            `json:"expiration"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: policy is not valid JSON", ErrPostPolicy)
+	}
+	expiration, err := time.Parse(time.RFC3339, doc.Expiration)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid expiration %q", ErrPostPolicy, doc.Expiration)
+	}
+
+	policy := &PostPolicy{Expiration: expiration}
+	for _, raw := range doc.Conditions {
+		if err := policy.addCondition(raw); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// addCondition parses one entry of the policy's conditions: an object of
+// exact matches, or an ["eq"|"starts-with", "$field", value] or
+// ["content-length-range", min, max] array
+func (p *PostPolicy) addCondition(raw json.RawMessage) error {
+	var exact map[string]string
+	if err := json.Unmarshal(raw, &exact); err == nil {
+		for field, value := range exact {
+			p.conditions = append(p.conditions, postCondition{match: "eq", field: strings.ToLower(field), value: value})
+		}
+		return nil
+	}
+
+	var entry []json.RawMessage
+	if err := json.Unmarshal(raw, &entry); err != nil || len(entry) != 3 {
+		return fmt.Errorf("%w: invalid condition %s", ErrPostPolicy, raw)
+	}
+	var match string
+	if err := json.Unmarshal(entry[0], &match); err != nil {
+		return fmt.Errorf("%w: invalid condition %s", ErrPostPolicy, raw)
+	}
+
+	switch match = strings.ToLower(match); match {
+	case "content-length-range":
+		if json.Unmarshal(entry[1], &p.MinLength) != nil || json.Unmarshal(entry[2], &p.MaxLength) != nil ||
+			p.MinLength < 0 || p.MaxLength < p.MinLength {
+			return fmt.Errorf("%w: invalid content-length-range %s", ErrPostPolicy, raw)
+		}
+		p.HasLengthRange = true
+	case "eq", "starts-with":
+		var field, value string
+		if json.Unmarshal(entry[1], &field) != nil || json.Unmarshal(entry[2], &value) != nil || !strings.HasPrefix(field, "$") {
+			return fmt.Errorf("%w: invalid condition %s", ErrPostPolicy, raw)
+		}
+		p.conditions = append(p.conditions, postCondition{match: match, field: strings.ToLower(field[1:]), value: value})
+	default:
+		return fmt.Errorf("%w: unknown condition %q", ErrPostPolicy, match)
+	}
+	return nil
+}
+
+// Check validates a form against the policy at now. fields holds the form
+// fields by lower-case name, with the bucket the form is posted to as
+// "bucket". Every condition must hold, and every field but the policy,
+// signature, file and x-ignore-* fields must be named by a condition.
+func (p *PostPolicy) Check(fields map[string]string, now time.Time) error {
+	if now.After(p.Expiration) {
+		return ErrPostPolicyExpired
+	}
+
+	covered := make(map[string]bool, len(p.conditions))
+	for _, cond := range p.conditions {
+		value := fields[cond.field]
+		if cond.match == "eq" && value != cond.value {
+			return fmt.Errorf("%w: condition failed: [\"eq\", \"$%s\", %q]", ErrPostPolicy, cond.field, cond.value)
+		}
+		if cond.match == "starts-with" && !strings.HasPrefix(value, cond.value) {
+			return fmt.Errorf("%w: condition failed: [\"starts-with\", \"$%s\", %q]", ErrPostPolicy, cond.field, cond.value)
+		}
+		covered[cond.field] = true
+	}
+
+	for field := range fields {
+		if covered[field] || postPolicyExempt(field) {
+			continue
+		}
+		return fmt.Errorf("%w: extra input fields: %s", ErrPostPolicy, field)
+	}
+	return nil
+}
+
+// postPolicyExempt reports whether a form field may be sent without a policy
+// condition naming it
+func postPolicyExempt(field string) bool {
+	switch field {
+	case "policy", "x-amz-signature", "file":
+		return true
+	}
+	return strings.HasPrefix(field, "x-ignore-")
+}
+
+// VerifyPostSignature checks the SigV4 signature of a POST upload form and
+// returns the access key it was signed with. fields holds the form fields by
+// lower-case name. The signature is computed over the base64 policy field
+// with the signing key of the x-amz-credential scope. Forms without a
+// signature fail with ErrUnsigned, and while no credentials are configured
+// every form is accepted, as every request is.
+func (a *Auth) VerifyPostSignature(fields map[string]string) (string, error) {
+	if !a.hasCredentials() {
+		return "", nil
+	}
+	signature := fields["x-amz-signature"]
+	if signature == "" {
+		return "", ErrUnsigned
+	}
+	if fields["policy"] == "" {
+		return "", fmt.Errorf("%w: signed form has no policy", ErrSignatureMismatch)
+	}
+	if algorithm := fields["x-amz-algorithm"]; algorithm != "AWS4-HMAC-SHA256" {
+		return "", fmt.Errorf("%w: unsupported algorithm %q", ErrSignatureMismatch, algorithm)
+	}
+
+	// Credential is AccessKey/Date/Region/Service/aws4_request
+	scope := strings.Split(fields["x-amz-credential"], "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" {
+		return "", fmt.Errorf("%w: malformed credential", ErrSignatureMismatch)
+	}
+	accessKey := scope[0]
+
+	cred, err := a.tokenCredential(accessKey, fields["x-amz-security-token"])
+	if err != nil {
+		return "", err
+	}
+
+	expected := hmacSHA256(deriveSigningKey(cred.SecretKey, scope[1], scope[2], scope[3]), []byte(fields["policy"]))
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return "", ErrSignatureMismatch
+	}
+	return accessKey, nil
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/config"
+)
+
+func encodePostPolicy(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestParsePostPolicy(t *testing.T) {
+	policy, err := ParsePostPolicy(encodePostPolicy(`{
+		"expiration": "2030-01-01T12:00:00.000Z",
+		"conditions": [
+			{"bucket": "uploads"},
+			["starts-with", "$key", "user/"],
+			["eq", "$Content-Type", "image/png"],
+			["content-length-range", 1, 1024]
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParsePostPolicy failed: %v", err)
+	}
+	if !policy.Expiration.Equal(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expiration = %v", policy.Expiration)
+	}
+	if !policy.HasLengthRange || policy.MinLength != 1 || policy.MaxLength != 1024 {
+		t.Errorf("length range = %v %d-%d, want 1-1024", policy.HasLengthRange, policy.MinLength, policy.MaxLength)
+	}
+	if len(policy.conditions) != 3 {
+		t.Errorf("got %d conditions, want 3", len(policy.conditions))
+	}
+}
+
+func TestParsePostPolicy_Invalid(t *testing.T) {
+	docs := map[string]string{
+		"not base64":        "%%%",
+		"not json":          encodePostPolicy("{"),
+		"no expiration":     encodePostPolicy(`{"conditions": []}`),
+		"unknown condition": encodePostPolicy(`{"expiration": "2030-01-01T00:00:00Z", "conditions": [["matches", "$key", "a"]]}`),
+		"field without $":   encodePostPolicy(`{"expiration": "2030-01-01T00:00:00Z", "conditions": [["eq", "key", "a"]]}`),
+		"inverted range":    encodePostPolicy(`{"expiration": "2030-01-01T00:00:00Z", "conditions": [["content-length-range", 10, 1]]}`),
+		"short condition":   encodePostPolicy(`{"expiration": "2030-01-01T00:00:00Z", "conditions": [["eq", "$key"]]}`),
+	}
+	for name, encoded := range docs {
+		if _, err := ParsePostPolicy(encoded); !errors.Is(err, ErrPostPolicy) {
+			t.Errorf("%s: err = %v, want ErrPostPolicy", name, err)
+		}
+	}
+}
+
+func TestPostPolicy_Check(t *testing.T) {
+	policy, err := ParsePostPolicy(encodePostPolicy(`{
+		"expiration": "2030-01-01T00:00:00Z",
+		"conditions": [
+			{"bucket": "uploads"},
+			["starts-with", "$key", "user/"],
+			["starts-with", "$content-type", ""]
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParsePostPolicy failed: %v", err)
+	}
+	now := time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fields := map[string]string{
+		"bucket":          "uploads",
+		"key":             "user/photo.png",
+		"content-type":    "image/png",
+		"policy":          "...",
+		"x-amz-signature": "...",
+		"x-ignore-note":   "anything",
+	}
+	if err := policy.Check(fields, now); err != nil {
+		t.Errorf("Check failed: %v", err)
+	}
+
+	if err := policy.Check(fields, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrPostPolicyExpired) {
+		t.Errorf("expired policy: err = %v, want ErrPostPolicyExpired", err)
+	}
+
+	fields["key"] = "other/photo.png"
+	if err := policy.Check(fields, now); !errors.Is(err, ErrPostPolicy) {
+		t.Errorf("key outside prefix: err = %v, want ErrPostPolicy", err)
+	}
+	fields["key"] = "user/photo.png"
+
+	fields["bucket"] = "elsewhere"
+	if err := policy.Check(fields, now); !errors.Is(err, ErrPostPolicy) {
+		t.Errorf("other bucket: err = %v, want ErrPostPolicy", err)
+	}
+	fields["bucket"] = "uploads"
+
+	fields["acl"] = "public-read"
+	if err := policy.Check(fields, now); !errors.Is(err, ErrPostPolicy) {
+		t.Errorf("uncovered field: err = %v, want ErrPostPolicy", err)
+	}
+}
+
+func TestVerifyPostSignature(t *testing.T) {
+	a := New(config.AuthConfig{AccessKey: "AKIDEXAMPLE", SecretKey: "secret"})
+	policy := encodePostPolicy(`{"expiration": "2030-01-01T00:00:00Z", "conditions": []}`)
+	signature := hex.EncodeToString(hmacSHA256(deriveSigningKey("secret", "20290101", "us-east-1", "s3"), []byte(policy)))
+
+	fields := map[string]string{
+		"policy":           policy,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": "AKIDEXAMPLE/20290101/us-east-1/s3/aws4_request",
+		"x-amz-signature":  signature,
+	}
+	accessKey, err := a.VerifyPostSignature(fields)
+	if err != nil {
+		t.Fatalf("VerifyPostSignature failed: %v", err)
+	}
+	if accessKey != "AKIDEXAMPLE" {
+		t.Errorf("access key = %q, want AKIDEXAMPLE", accessKey)
+	}
+
+	fields["policy"] = encodePostPolicy(`{"expiration": "2031-01-01T00:00:00Z", "conditions": []}`)
+	if _, err := a.VerifyPostSignature(fields); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("altered policy: err = %v, want ErrSignatureMismatch", err)
+	}
+	fields["policy"] = policy
+
+	fields["x-amz-credential"] = "UNKNOWN/20290101/us-east-1/s3/aws4_request"
+	if _, err := a.VerifyPostSignature(fields); !errors.Is(err, ErrInvalidAccessKey) {
+		t.Errorf("unknown access key: err = %v, want ErrInvalidAccessKey", err)
+	}
+
+	delete(fields, "x-amz-signature")
+	if _, err := a.VerifyPostSignature(fields); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("unsigned form: err = %v, want ErrUnsigned", err)
+	}
+
+	if _, err := New(config.AuthConfig{}).VerifyPostSignature(fields); err != nil {
+		t.Errorf("without credentials: err = %v, want nil", err)
+	}
+}
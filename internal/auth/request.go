@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+// authResultKey is the context key under which Authenticate records its result
+type authResultKey struct{}
+
+// authResult is the outcome of verifying a request's credentials
+type authResult struct {
+	accessKey string
+	err       error
+}
+
+// Authenticate verifies the request's credentials once and returns the
+// request with the result in its context. Authorize and
+// AuthenticatedAccessKey reuse that result instead of verifying again.
+func (a *Auth) Authenticate(req *http.Request) *http.Request {
+	if _, ok := req.Context().Value(authResultKey{}).(*authResult); ok {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), authResultKey{}, a.authenticate(req)))
+}
+
+// authenticate verifies the request's credentials and resolves its access key
+func (a *Auth) authenticate(req *http.Request) *authResult {
+	result := &authResult{err: a.authorize(req)}
+	if result.err == nil {
+		if accessKey, ok := a.ClientCertAccessKey(req); ok {
+			result.accessKey = accessKey
+		} else if req.Header.Get("Authorization") != "" {
+			result.accessKey = RequestAccessKey(req)
+		}
+	}
+	return result
+}
+
+// Middleware authenticates each request before passing it on
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, a.Authenticate(r))
+	})
+}
+
+// AuthenticatedAccessKey returns the access key whose credentials Authenticate
+// verified for the request. Anonymous, presigned and unverified requests
+// report false.
+func AuthenticatedAccessKey(req *http.Request) (string, bool) {
+	result, ok := req.Context().Value(authResultKey{}).(*authResult)
+	if !ok || result.err != nil || result.accessKey == "" {
+		return "", false
+	}
+	return result.accessKey, true
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/config"
+)
+
+func TestAuthenticate(t *testing.T) {
+	auth := New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"})
+
+	signed, _ := http.NewRequest("GET", "http://localhost:9000/bucket/key", nil)
+	signed.Header.Set("X-Amz-Date", "20240101T000000Z")
+	signed.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := auth.buildCanonicalRequest(signed, signedHeaders)
+	stringToSign := auth.buildStringToSign(signed, canonical, "20240101", "us-east-1", "s3")
+	signature := auth.calculateSignature("test-secret", "20240101", "us-east-1", "s3", stringToSign)
+	signed.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=test-key/20240101/us-east-1/s3/aws4_request, SignedHeaders="+
+		signedHeaders+", Signature="+signature)
+
+	req := auth.Authenticate(signed)
+	if accessKey, ok := AuthenticatedAccessKey(req); !ok || accessKey != "test-key" {
+		t.Errorf("AuthenticatedAccessKey() = %q, %v, want test-key, true", accessKey, ok)
+	}
+
+	// Authorize reuses the recorded result rather than verifying again, so
+	// removing the credential afterwards does not change it
+	delete(auth.credentials, "test-key")
+	auth.AddCredential("other-key", "other-secret")
+	if err := auth.Authorize(req, "bucket", "s3:GetObject"); err != nil {
+		t.Errorf("Authorize() after Authenticate error = %v", err)
+	}
+	if err := auth.Authorize(signed, "bucket", "s3:GetObject"); err == nil {
+		t.Error("Authorize() without Authenticate should verify the request itself")
+	}
+
+	forged := httptest.NewRequest("GET", "/bucket/key", nil)
+	forged.Header.Set("Authorization", "AWS other-key:forged")
+	if accessKey, ok := AuthenticatedAccessKey(auth.Authenticate(forged)); ok {
+		t.Errorf("AuthenticatedAccessKey() = %q for a forged signature", accessKey)
+	}
+
+	anonymous := httptest.NewRequest("GET", "/bucket/key", nil)
+	if _, ok := AuthenticatedAccessKey(anonymous); ok {
+		t.Error("unauthenticated request should report no access key")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	auth := New(config.AuthConfig{})
+
+	var sawResult bool
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, sawResult = r.Context().Value(authResultKey{}).(*authResult)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/bucket/key", nil))
+
+	if !sawResult {
+		t.Error("Middleware should record the authentication result in the request context")
+	}
+}
+
+// fakePolicyChecker allows the actions listed for each access key it manages
+// on arn:aws:s3:::bucket/key
+type fakePolicyChecker map[string][]string
+
+func (f fakePolicyChecker) IsAllowed(accessKey, action, resource string) (bool, bool) {
+	actions, ok := f[accessKey]
+	if !ok {
+		return false, false
+	}
+	for _, a := range actions {
+		if a == action && resource == "arn:aws:s3:::bucket/key" {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func TestAuthorize_PolicyChecker(t *testing.T) {
+	auth := New(config.AuthConfig{AccessKey: "root-key", SecretKey: "root-secret"})
+	auth.AddCredential("iam-key", "iam-secret")
+	auth.SetPolicyChecker(fakePolicyChecker{"iam-key": {"s3:GetObject"}})
+
+	sign := func(accessKey, secretKey string) *http.Request {
+		req, _ := http.NewRequest("GET", "http://localhost:9000/bucket/key", nil)
+		req.Header.Set("X-Amz-Date", "20240101T000000Z")
+		req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+		canonical := auth.buildCanonicalRequest(req, signedHeaders)
+		stringToSign := auth.buildStringToSign(req, canonical, "20240101", "us-east-1", "s3")
+		signature := auth.calculateSignature(secretKey, "20240101", "us-east-1", "s3", stringToSign)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/20240101/us-east-1/s3/aws4_request, SignedHeaders="+
+			signedHeaders+", Signature="+signature)
+		return auth.Authenticate(req)
+	}
+
+	iamReq := sign("iam-key", "iam-secret")
+	if err := auth.Authorize(iamReq, "bucket/key", "s3:GetObject"); err != nil {
+		t.Errorf("Authorize(GetObject) error = %v", err)
+	}
+	if err := auth.Authorize(iamReq, "bucket/key", "s3:PutObject"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("Authorize(PutObject) error = %v, want %v", err, ErrAccessDenied)
+	}
+
+	// The configured key is not managed by the checker and keeps full access
+	if err := auth.Authorize(sign("root-key", "root-secret"), "bucket/key", "s3:PutObject"); err != nil {
+		t.Errorf("Authorize() with the configured key error = %v", err)
+	}
+	if auth.IsAuthorized("iam-key", "bucket/key", "s3:DeleteObject") {
+		t.Error("IsAuthorized() should apply the key's policies")
+	}
+}
@@ -0,0 +1,32 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Now() = %v, want %v", c.Now(), start)
+	}
+
+	c.Advance(90 * time.Minute)
+	if want := start.Add(90 * time.Minute); !c.Now().Equal(want) {
+		t.Errorf("Now() after Advance = %v, want %v", c.Now(), want)
+	}
+
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Now() after Set = %v, want %v", c.Now(), start)
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	now := Real.Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("Real.Now() = %v, not the current time", now)
+	}
+}
@@ -139,17 +139,20 @@ func (r *Rebalancer) runChecker(ctx context.Context) {
 
 // checkAndRebalance checks distribution and triggers rebalancing if needed
 func (r *Rebalancer) checkAndRebalance(ctx context.Context) {
-	// Get current distribution
+	// Get current distribution. Draining nodes are being emptied, so they
+	// are neither the source nor the target of a move.
 	distribution := r.ring.GetNodeDistribution()
+	for nodeID := range distribution {
+		if r.ring.IsDraining(nodeID) {
+			delete(distribution, nodeID)
+		}
+	}
 	if len(distribution) == 0 {
 		return
 	}
 
 	// Calculate ideal distribution
-	nodeCount := r.ring.NodeCount()
-	if nodeCount == 0 {
-		return
-	}
+	nodeCount := len(distribution)
 
 	totalVN := 0
 	for _, count := range distribution {
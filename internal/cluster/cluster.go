@@ -2,13 +2,25 @@ package cluster
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// ErrNodeNotFound is returned for a node that is neither a cluster member
+// nor on the hash ring
+var ErrNodeNotFound = errors.New("node not found")
+
+// Node statuses reported for draining nodes
+const (
+	NodeStatusDraining = "draining"
+	NodeStatusDrained  = "drained"
+)
+
 // Cluster represents the main cluster manager
 type Cluster struct {
 	config      ClusterConfig
@@ -252,6 +264,75 @@ func (c *Cluster) GetRingDistribution() map[string]int {
 	return c.ring.GetNodeDistribution()
 }
 
+// DrainNode marks a node as draining. It stays a member of the cluster but
+// is no longer routed new writes, and the next Rebalance hands its ring
+// ranges to the other nodes. The drain is done once the node has no ranges
+// left in GetRingDistribution, when NodeStatus reports it as drained.
+func (c *Cluster) DrainNode(nodeID string) error {
+	if !c.initialized {
+		return fmt.Errorf("cluster not initialized")
+	}
+	if _, ok := c.manager.GetMember(nodeID); !ok {
+		if _, ok := c.ring.GetNodes()[nodeID]; !ok {
+			return fmt.Errorf("%w: %s", ErrNodeNotFound, nodeID)
+		}
+	}
+
+	c.ring.Drain(nodeID)
+	c.logger.Info("Node draining",
+		zap.String("node_id", nodeID),
+		zap.Int("ranges", c.ring.RangeCount(nodeID)))
+	return nil
+}
+
+// RebalanceResult reports the ring changes made by Rebalance
+type RebalanceResult struct {
+	Added        []string       `json:"added"`
+	Released     []string       `json:"released"`
+	Distribution map[string]int `json:"distribution"`
+}
+
+// Rebalance recomputes the assignment of ring ranges: alive members missing
+// from the ring are added to it, and draining nodes release their ranges to
+// the nodes that follow them on the ring.
+func (c *Cluster) Rebalance(ctx context.Context) (*RebalanceResult, error) {
+	if !c.initialized {
+		return nil, fmt.Errorf("cluster not initialized")
+	}
+
+	result := &RebalanceResult{Added: make([]string, 0)}
+	onRing := c.ring.GetNodes()
+	for _, node := range c.manager.Members() {
+		if _, ok := onRing[node.ID]; ok || node.State != NodeStateAlive || c.ring.IsDraining(node.ID) {
+			continue
+		}
+		c.ring.AddNode(node)
+		result.Added = append(result.Added, node.ID)
+	}
+	sort.Strings(result.Added)
+
+	result.Released = c.ring.ReleaseDrained()
+	result.Distribution = c.ring.GetNodeDistribution()
+
+	c.logger.Info("Ring rebalanced",
+		zap.Strings("added", result.Added),
+		zap.Strings("released", result.Released))
+	return result, nil
+}
+
+// NodeStatus returns the status of a node for display: its membership
+// status, or for a draining node "draining" while it still owns ring ranges
+// and "drained" once it owns none
+func (c *Cluster) NodeStatus(node *Node) string {
+	if c.ring == nil || !c.ring.IsDraining(node.ID) {
+		return node.Status()
+	}
+	if c.ring.RangeCount(node.ID) > 0 {
+		return NodeStatusDraining
+	}
+	return NodeStatusDrained
+}
+
 // ClusterInfo contains cluster information
 type ClusterInfo struct {
 	NodeID            string `json:"node_id"`
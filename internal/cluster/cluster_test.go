@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -373,6 +374,84 @@ func TestHashRingGetNodeDistribution(t *testing.T) {
 	}
 }
 
+func TestHashRingDrain(t *testing.T) {
+	ring := NewHashRing()
+	ring.AddNode(&Node{ID: "node-1", Name: "Node 1"})
+	ring.AddNode(&Node{ID: "node-2", Name: "Node 2"})
+
+	readPlacement := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		readPlacement[key], _ = ring.GetNode(key)
+	}
+
+	ring.Drain("node-2")
+	if !ring.IsDraining("node-2") {
+		t.Error("node-2 should be draining")
+	}
+	onNode2 := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if nodeID, _ := ring.GetWriteNode(key); nodeID != "node-1" {
+			t.Fatalf("GetWriteNode(%s) = %s, want node-1", key, nodeID)
+		}
+		if nodes := ring.GetNWriteNodes(key, 2); len(nodes) != 1 || nodes[0] != "node-1" {
+			t.Fatalf("GetNWriteNodes(%s) = %v, want [node-1]", key, nodes)
+		}
+		// Reads still find data on the draining node until it is released
+		if nodeID, _ := ring.GetNode(key); nodeID != readPlacement[key] {
+			t.Fatalf("GetNode(%s) = %s while draining, want %s", key, nodeID, readPlacement[key])
+		}
+		if nodes := ring.GetNNodes(key, 2); len(nodes) != 2 {
+			t.Fatalf("GetNNodes(%s) = %v while draining, want both nodes", key, nodes)
+		}
+		if readPlacement[key] == "node-2" {
+			onNode2++
+		}
+	}
+	if onNode2 == 0 {
+		t.Fatal("no test key is placed on node-2")
+	}
+
+	// The drained node keeps its ranges until they are released
+	if count := ring.RangeCount("node-2"); count != VirtualNodeCount {
+		t.Errorf("RangeCount before release = %d, want %d", count, VirtualNodeCount)
+	}
+	if released := ring.ReleaseDrained(); len(released) != 1 || released[0] != "node-2" {
+		t.Errorf("ReleaseDrained() = %v, want [node-2]", released)
+	}
+	dist := ring.GetNodeDistribution()
+	if dist["node-2"] != 0 || dist["node-1"] != VirtualNodeCount {
+		t.Errorf("distribution after release = %v", dist)
+	}
+	for key := range readPlacement {
+		if nodeID, _ := ring.GetNode(key); nodeID != "node-1" {
+			t.Fatalf("GetNode(%s) = %s after release, want node-1", key, nodeID)
+		}
+	}
+	if released := ring.ReleaseDrained(); len(released) != 0 {
+		t.Errorf("second ReleaseDrained() = %v, want none", released)
+	}
+
+	// Draining every node leaves nothing to write to
+	ring.Drain("node-1")
+	if _, ok := ring.GetWriteNode("key"); ok {
+		t.Error("GetWriteNode should fail when every node is draining")
+	}
+	if nodeID, ok := ring.GetNode("key"); !ok || nodeID != "node-1" {
+		t.Errorf("GetNode with every node draining = %s, %v; want node-1", nodeID, ok)
+	}
+	ring.ReleaseDrained()
+	if _, ok := ring.GetNode("key"); ok {
+		t.Error("GetNode should fail when every node has released its ranges")
+	}
+
+	ring.RemoveNode("node-2")
+	if ring.IsDraining("node-2") {
+		t.Error("removed node should not be draining")
+	}
+}
+
 func TestHashRingSetHashFunction(t *testing.T) {
 	ring := NewHashRing()
 	ring.AddNode(&Node{ID: "node-1", Name: "Node 1"})
@@ -737,6 +816,51 @@ func TestCluster_AddRemoveNode(t *testing.T) {
 	cluster.Stop()
 }
 
+func TestCluster_DrainAndRebalance(t *testing.T) {
+	cluster := NewCluster(zap.NewNop(), WithNodeID("node-1"), WithBindPort(0))
+	if err := cluster.DrainNode("node-1"); err == nil {
+		t.Error("DrainNode before Initialize should fail")
+	}
+	if _, err := cluster.Rebalance(context.Background()); err == nil {
+		t.Error("Rebalance before Initialize should fail")
+	}
+
+	if err := cluster.Initialize(context.Background(), ReplicationFactor(3)); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	defer cluster.Stop()
+	node := &Node{ID: "node-2", Name: "Node 2", State: NodeStateAlive}
+	cluster.AddNode(node)
+
+	if err := cluster.DrainNode("unknown"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("DrainNode(unknown) = %v, want ErrNodeNotFound", err)
+	}
+	if err := cluster.DrainNode("node-2"); err != nil {
+		t.Fatalf("DrainNode failed: %v", err)
+	}
+	if status := cluster.NodeStatus(node); status != NodeStatusDraining {
+		t.Errorf("status after drain = %s, want %s", status, NodeStatusDraining)
+	}
+
+	result, err := cluster.Rebalance(context.Background())
+	if err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if len(result.Released) != 1 || result.Released[0] != "node-2" {
+		t.Errorf("Released = %v, want [node-2]", result.Released)
+	}
+	// A drain is done once the node has no ranges left
+	if result.Distribution["node-2"] != 0 {
+		t.Errorf("node-2 has %d ranges after rebalance, want 0", result.Distribution["node-2"])
+	}
+	if status := cluster.NodeStatus(node); status != NodeStatusDrained {
+		t.Errorf("status after rebalance = %s, want %s", status, NodeStatusDrained)
+	}
+	if local := cluster.GetManager().GetLocalNode(); cluster.NodeStatus(local) != local.Status() {
+		t.Errorf("local node status = %s, want %s", cluster.NodeStatus(local), local.Status())
+	}
+}
+
 func TestCluster_GetNodes(t *testing.T) {
 	logger := zap.NewNop()
 	cluster := NewCluster(logger)
@@ -1242,6 +1366,30 @@ func TestReplicator_GetReplicationFactor(t *testing.T) {
 	}
 }
 
+func TestReplicator_DrainingTargets(t *testing.T) {
+	logger := zap.NewNop()
+	ring := NewHashRing()
+	for _, id := range []string{"node-1", "node-2", "node-3"} {
+		ring.AddNode(&Node{ID: id})
+	}
+	replicator := NewReplicator(&Manager{logger: logger}, ring, RF3, logger)
+
+	ring.Drain("node-3")
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		// Reads and deletes still reach the draining node's replicas, but
+		// new writes go elsewhere
+		if nodes := replicator.GetTargetNodes(key); len(nodes) != 3 {
+			t.Fatalf("GetTargetNodes(%s) = %v, want all 3 nodes", key, nodes)
+		}
+		for _, nodeID := range replicator.GetWriteTargetNodes(key) {
+			if nodeID == "node-3" {
+				t.Fatalf("GetWriteTargetNodes(%s) includes the draining node", key)
+			}
+		}
+	}
+}
+
 func TestFindLeastLoadedNode(t *testing.T) {
 	tests := []struct {
 		name         string
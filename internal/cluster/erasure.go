@@ -325,7 +325,8 @@ func (w *ErasureWriter) Write(ctx context.Context, key string, data []byte) erro
 		return fmt.Errorf("failed to encode: %w", err)
 	}
 
-	targetNodes := w.ring.GetNNodes(key, w.coder.GetConfig().TotalShards)
+	// Draining nodes take no new shards
+	targetNodes := w.ring.GetNWriteNodes(key, w.coder.GetConfig().TotalShards)
 	if len(targetNodes) < w.coder.GetConfig().TotalShards {
 		return fmt.Errorf("not enough nodes: have %d, need %d", len(targetNodes), w.coder.GetConfig().TotalShards)
 	}
@@ -16,6 +16,7 @@ type HashRing struct {
 	virtualNodes map[uint32]string // hash -> node ID
 	physicalNodes map[string]int   // node ID -> virtual node count
 	nodes        map[string]*Node  // node ID -> node info
+	draining     map[string]bool   // node ID -> draining
 	sortedHashes []uint32
 	lock         sync.RWMutex
 }
@@ -27,6 +28,7 @@ func NewHashRing() *HashRing {
 		virtualNodes:   make(map[uint32]string),
 		physicalNodes:  make(map[string]int),
 		nodes:          make(map[string]*Node),
+		draining:       make(map[string]bool),
 		sortedHashes:   make([]uint32, 0),
 	}
 }
@@ -85,34 +87,48 @@ func (r *HashRing) RemoveNode(nodeID string) {
 	}
 
 	delete(r.physicalNodes, nodeID)
+	delete(r.draining, nodeID)
 	r.rebuild()
 }
 
 // GetNode returns the primary node for a key
 func (r *HashRing) GetNode(key string) (string, bool) {
-	r.lock.RLock()
-	defer r.lock.RUnlock()
+	return r.firstNode(key, false)
+}
 
-	if len(r.nodes) == 0 {
-		return "", false
-	}
+// GetNNodes returns N nodes for replication
+func (r *HashRing) GetNNodes(key string, n int) []string {
+	return r.walk(key, n, false)
+}
 
-	hash := r.hashFunction([]byte(key))
-	idx := r.search(hash)
+// GetWriteNode returns the node new data for a key is written to. It is
+// GetNode without draining nodes.
+func (r *HashRing) GetWriteNode(key string) (string, bool) {
+	return r.firstNode(key, true)
+}
 
-	// Get the node ID from virtual node
-	virtualHash := r.sortedHashes[idx]
-	nodeID := r.virtualNodes[virtualHash]
+// GetNWriteNodes returns the N nodes new data for a key is written to. It
+// is GetNNodes without draining nodes.
+func (r *HashRing) GetNWriteNodes(key string, n int) []string {
+	return r.walk(key, n, true)
+}
 
-	return nodeID, true
+// firstNode returns the first node of walk
+func (r *HashRing) firstNode(key string, skipDraining bool) (string, bool) {
+	nodes := r.walk(key, 1, skipDraining)
+	if len(nodes) == 0 {
+		return "", false
+	}
+	return nodes[0], true
 }
 
-// GetNNodes returns N nodes for replication
-func (r *HashRing) GetNNodes(key string, n int) []string {
+// walk returns up to n distinct nodes owning the virtual nodes that follow
+// key's hash on the ring, leaving out draining nodes if skipDraining is set
+func (r *HashRing) walk(key string, n int, skipDraining bool) []string {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
-	if len(r.nodes) == 0 {
+	if len(r.nodes) == 0 || len(r.sortedHashes) == 0 {
 		return nil
 	}
 
@@ -127,7 +143,7 @@ func (r *HashRing) GetNNodes(key string, n int) []string {
 		virtualHash := r.sortedHashes[(idx+i)%len(r.sortedHashes)]
 		nodeID := r.virtualNodes[virtualHash]
 
-		if !seen[nodeID] {
+		if !seen[nodeID] && !(skipDraining && r.draining[nodeID]) {
 			seen[nodeID] = true
 			result = append(result, nodeID)
 		}
@@ -136,6 +152,60 @@ func (r *HashRing) GetNNodes(key string, n int) []string {
 	return result
 }
 
+// Drain marks a node as draining. GetWriteNode and GetNWriteNodes stop
+// returning it, so it takes no new writes, but the ranges it owns stay
+// assigned to it, and GetNode and GetNNodes keep finding its data there,
+// until ReleaseDrained hands them to the other nodes.
+func (r *HashRing) Drain(nodeID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.draining[nodeID] = true
+}
+
+// IsDraining reports whether a node has been marked as draining
+func (r *HashRing) IsDraining(nodeID string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.draining[nodeID]
+}
+
+// ReleaseDrained removes the virtual nodes of draining nodes from the ring,
+// so the ranges they owned fall to the next nodes on the ring. The nodes
+// stay on the ring with no ranges. It returns the IDs of the nodes that
+// released ranges, sorted.
+func (r *HashRing) ReleaseDrained() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	released := make([]string, 0)
+	for nodeID := range r.draining {
+		if r.physicalNodes[nodeID] == 0 {
+			continue
+		}
+		for hash, owner := range r.virtualNodes {
+			if owner == nodeID {
+				delete(r.virtualNodes, hash)
+			}
+		}
+		r.physicalNodes[nodeID] = 0
+		released = append(released, nodeID)
+	}
+
+	if len(released) > 0 {
+		r.rebuild()
+	}
+	sort.Strings(released)
+	return released
+}
+
+// RangeCount returns the number of ring ranges, one per virtual node,
+// assigned to a node
+func (r *HashRing) RangeCount(nodeID string) int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.physicalNodes[nodeID]
+}
+
 // GetNodesInRange returns nodes in a key range
 func (r *HashRing) GetNodesInRange(startKey, endKey string, n int) []string {
 	r.lock.RLock()
@@ -230,7 +300,11 @@ func (r *HashRing) GetNodeDistribution() map[string]int {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
-	distribution := make(map[string]int)
+	// Nodes that released their ranges are reported with none
+	distribution := make(map[string]int, len(r.nodes))
+	for nodeID := range r.nodes {
+		distribution[nodeID] = 0
+	}
 	for _, nodeID := range r.virtualNodes {
 		distribution[nodeID]++
 	}
@@ -93,12 +93,18 @@ func (r *Replicator) GetTargetNodes(key string) []string {
 	return r.ring.GetNNodes(key, int(r.replicationFactor))
 }
 
+// GetWriteTargetNodes returns the nodes new data for an object key is
+// written to, which leave out draining nodes
+func (r *Replicator) GetWriteTargetNodes(key string) []string {
+	return r.ring.GetNWriteNodes(key, int(r.replicationFactor))
+}
+
 // ReplicateWrite replicates a write operation
 func (r *Replicator) ReplicateWrite(ctx context.Context, op *ReplicationOp) error {
 	op.Status = ReplicationInProgress
 	op.StartTime = time.Now()
 
-	targetNodes := r.GetTargetNodes(op.ObjectKey)
+	targetNodes := r.GetWriteTargetNodes(op.ObjectKey)
 	op.TargetNodes = targetNodes
 
 	r.mu.Lock()
@@ -22,7 +22,14 @@ type Config struct {
 	Metrics   MetricsConfig   `mapstructure:"metrics"`
 	TLS       TLSConfig       `mapstructure:"tls"`
 	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Workers   WorkersConfig   `mapstructure:"workers"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Notifications NotificationsConfig `mapstructure:"notifications"`
 	LogLevel  string          `mapstructure:"log_level"`
+
+	// ContentTypes maps file extensions to the content type served for
+	// objects stored without one (or as application/octet-stream)
+	ContentTypes map[string]string `mapstructure:"content_types"`
 }
 
 type ServerConfig struct {
@@ -31,6 +38,11 @@ type ServerConfig struct {
 	ReadTimeout  int    `mapstructure:"read_timeout"`
 	WriteTimeout int    `mapstructure:"write_timeout"`
 	IdleTimeout  int    `mapstructure:"idle_timeout"`
+
+	// CompressResponses gzips S3 and management XML/JSON responses of at
+	// least CompressMinSize bytes for clients that accept gzip
+	CompressResponses bool `mapstructure:"compress_responses"`
+	CompressMinSize   int  `mapstructure:"compress_min_size"`
 }
 
 type StorageConfig struct {
@@ -38,7 +50,62 @@ type StorageConfig struct {
 	MaxObjectSize      int64  `mapstructure:"max_object_size"`
 	MaxBuckets         int    `mapstructure:"max_buckets"`
 	EnableCompression  bool   `mapstructure:"enable_compression"`
-	StorageBackend     string `mapstructure:"storage_backend"` // flatfile, packed
+	StorageBackend     string `mapstructure:"storage_backend"` // flatfile, memory, s3proxy
+
+	// MaxRetentionDays caps how far ahead object lock retention may be set;
+	// 0 allows any future date
+	MaxRetentionDays int `mapstructure:"max_retention_days"`
+
+	// EnableRename turns on the POST /bucket/key?rename=newkey extension,
+	// which renames an object in place instead of copying it
+	EnableRename bool `mapstructure:"enable_rename"`
+
+	// EncryptionKey is the base64-encoded 32-byte master key for
+	// server-side encryption (SSE-S3). Without one, objects cannot be stored
+	// encrypted.
+	EncryptionKey string `mapstructure:"encryption_key"`
+
+	// VerifyReads makes the flatfile backend check each whole-object read
+	// against the hash stored with the object, detecting data corrupted on
+	// disk at the cost of hashing everything read
+	VerifyReads bool `mapstructure:"verify_reads"`
+
+	// S3Proxy configures the upstream S3 service the s3proxy backend
+	// forwards object data to
+	S3Proxy S3ProxyConfig `mapstructure:"s3proxy"`
+
+	// BulkIngest batches object metadata writes for bulk loads
+	BulkIngest BulkIngestConfig `mapstructure:"bulk_ingest"`
+
+	// RestoreDelay simulates how long restoring an archived object takes
+	RestoreDelay RestoreDelayConfig `mapstructure:"restore_delay"`
+}
+
+// RestoreDelayConfig sets how long, in seconds, a restore of an archived
+// object takes in each retrieval tier before the object can be read
+type RestoreDelayConfig struct {
+	Expedited int `mapstructure:"expedited"`
+	Standard  int `mapstructure:"standard"`
+	Bulk      int `mapstructure:"bulk"`
+}
+
+// BulkIngestConfig batches object metadata writes so one sync covers many
+// objects. Writes not yet flushed are lost if the process dies, so it is
+// meant for bulk loads that can be rerun.
+type BulkIngestConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	BatchSize     int  `mapstructure:"batch_size"`
+	FlushInterval int  `mapstructure:"flush_interval"` // milliseconds
+}
+
+// S3ProxyConfig configures the upstream of the s3proxy storage backend
+type S3ProxyConfig struct {
+	Endpoint  string `mapstructure:"endpoint"` // empty uses AWS S3
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	// UsePathStyle addresses buckets as endpoint/bucket, as MinIO needs
+	UsePathStyle bool `mapstructure:"use_path_style"`
 }
 
 type AuthConfig struct {
@@ -60,18 +127,56 @@ type MetricsConfig struct {
 	Enabled     bool   `mapstructure:"enabled"`
 	Port        int    `mapstructure:"port"`
 	Path        string `mapstructure:"path"`
+
+	// BucketLabel adds the bucket to S3 request metrics. At most
+	// MaxBucketLabels buckets get their own label; requests to other
+	// buckets share one.
+	BucketLabel     bool `mapstructure:"bucket_label"`
+	MaxBucketLabels int  `mapstructure:"max_bucket_labels"`
 }
 
 type TLSConfig struct {
 	Enabled    bool   `mapstructure:"enabled"`
 	CertFile   string `mapstructure:"cert_file"`
 	KeyFile    string `mapstructure:"key_file"`
+
+	// ClientCAFile enables mTLS: clients must present a certificate signed
+	// by one of the CAs in this PEM file or the handshake is refused.
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientIdentities maps a client certificate's subject common name to
+	// the access key the client is authenticated as
+	ClientIdentities map[string]string `mapstructure:"client_identities"`
+	// RequireSignature still requires mapped mTLS clients to sign requests
+	// with SigV4, using the access key their certificate maps to
+	RequireSignature bool `mapstructure:"require_signature"`
 }
 
 type RateLimitConfig struct {
 	Enabled bool `mapstructure:"enabled"`
 	Rate    int  `mapstructure:"rate"`    // requests per second
 	Burst   int  `mapstructure:"burst"`   // max burst size
+
+	// Limits for unauthenticated requests, keyed by client IP. Rate and
+	// Burst apply to authenticated requests, keyed by access key.
+	AnonymousRate  int `mapstructure:"anonymous_rate"`  // 0 uses rate
+	AnonymousBurst int `mapstructure:"anonymous_burst"` // 0 uses burst
+
+	// TrustedProxies lists the proxies, as IPs or CIDR ranges, whose
+	// X-Forwarded-For and X-Real-IP headers name the client IP that
+	// anonymous requests are limited by. Other peers are limited by their
+	// own address.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// NotificationsConfig restricts the targets bucket notification webhooks
+// are delivered to. Entries are host names, IPs or CIDR ranges. Loopback,
+// private and link-local addresses are refused unless an allowed range
+// covers them.
+type NotificationsConfig struct {
+	// AllowedWebhookTargets, when set, are the only targets delivered to
+	AllowedWebhookTargets []string `mapstructure:"allowed_webhook_targets"`
+	// DeniedWebhookTargets are never delivered to
+	DeniedWebhookTargets []string `mapstructure:"denied_webhook_targets"`
 }
 
 type LoggingConfig struct {
@@ -83,6 +188,18 @@ type LoggingConfig struct {
 	MaxBackups int    `mapstructure:"max_backups"`  // number of backup files
 	MaxAge     int    `mapstructure:"max_age"`      // days to keep backups
 	Compress   bool   `mapstructure:"compress"`    // compress rotated logs
+
+	// AccessLogSampleRate logs 1 in N successful requests; 0 or 1 logs all.
+	// Failed requests and those slower than SlowRequestThreshold are always
+	// logged.
+	AccessLogSampleRate  int `mapstructure:"access_log_sample_rate"`
+	SlowRequestThreshold int `mapstructure:"slow_request_threshold"` // milliseconds, 0 disables
+
+	// ServerAccessLogs writes S3 server access logs for buckets with a
+	// logging configuration into their target buckets, batched and flushed
+	// every ServerAccessLogFlushInterval seconds
+	ServerAccessLogs             bool `mapstructure:"server_access_logs"`
+	ServerAccessLogFlushInterval int  `mapstructure:"server_access_log_flush_interval"`
 }
 
 type FederationConfig struct {
@@ -163,12 +280,29 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("server.read_timeout", 30)
 	v.SetDefault("server.write_timeout", 30)
 	v.SetDefault("server.idle_timeout", 60)
+	v.SetDefault("server.compress_responses", true)
+	v.SetDefault("server.compress_min_size", 1024)
 
 	v.SetDefault("storage.data_dir", "/var/lib/openendpoint")
 	v.SetDefault("storage.max_object_size", 5*1024*1024*1024) // 5GB
 	v.SetDefault("storage.max_buckets", 100)
 	v.SetDefault("storage.enable_compression", false)
 	v.SetDefault("storage.storage_backend", "flatfile")
+	v.SetDefault("storage.max_retention_days", 0)
+	v.SetDefault("storage.enable_rename", false)
+	v.SetDefault("storage.encryption_key", "")
+	v.SetDefault("storage.verify_reads", false)
+	v.SetDefault("storage.s3proxy.endpoint", "")
+	v.SetDefault("storage.s3proxy.region", "us-east-1")
+	v.SetDefault("storage.s3proxy.access_key", "")
+	v.SetDefault("storage.s3proxy.secret_key", "")
+	v.SetDefault("storage.s3proxy.use_path_style", false)
+	v.SetDefault("storage.bulk_ingest.enabled", false)
+	v.SetDefault("storage.bulk_ingest.batch_size", 256)
+	v.SetDefault("storage.bulk_ingest.flush_interval", 100)
+	v.SetDefault("storage.restore_delay.expedited", 0)
+	v.SetDefault("storage.restore_delay.standard", 0)
+	v.SetDefault("storage.restore_delay.bulk", 0)
 
 	v.SetDefault("auth.secret_key", "")
 	v.SetDefault("auth.access_key", "")
@@ -183,10 +317,14 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("metrics.enabled", true)
 	v.SetDefault("metrics.port", 9090)
 	v.SetDefault("metrics.path", "/metrics")
+	v.SetDefault("metrics.bucket_label", false)
+	v.SetDefault("metrics.max_bucket_labels", 100)
 
 	v.SetDefault("tls.enabled", false)
 	v.SetDefault("tls.cert_file", "")
 	v.SetDefault("tls.key_file", "")
+	v.SetDefault("tls.client_ca_file", "")
+	v.SetDefault("tls.require_signature", false)
 
 	v.SetDefault("log_level", "info")
 
@@ -198,6 +336,12 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("logging.max_backups", 7)
 	v.SetDefault("logging.max_age", 30)
 	v.SetDefault("logging.compress", true)
+	v.SetDefault("logging.access_log_sample_rate", 1)
+	v.SetDefault("logging.slow_request_threshold", 1000)
+	v.SetDefault("logging.server_access_logs", false)
+	v.SetDefault("logging.server_access_log_flush_interval", 300)
+
+	v.SetDefault("workers.multipart_upload_max_age", 7*24*3600) // 7 days
 
 	// If config path provided, read from it
 	if path != "" {
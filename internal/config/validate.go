@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,6 +31,9 @@ func (c *Config) Validate() error {
 	if err := isWritable(c.Storage.DataDir); err != nil {
 		return fmt.Errorf("storage data directory is not writable: %w", err)
 	}
+	if _, err := c.Storage.MasterKey(); err != nil {
+		return err
+	}
 
 	// Validate auth config
 	if c.Auth.SecretKey == "" {
@@ -39,6 +43,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("auth secret key must be at least 8 characters")
 	}
 
+	// Validate TLS config
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled {
+		return fmt.Errorf("tls client CA file requires tls to be enabled")
+	}
+
 	// Validate cluster config
 	if c.Cluster.Enabled {
 		if c.Cluster.NodeID == "" {
@@ -52,6 +61,19 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// MasterKey decodes the server-side encryption master key, which is nil
+// when none is configured
+func (s StorageConfig) MasterKey() ([]byte, error) {
+	if s.EncryptionKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("storage encryption key must be 32 bytes, base64-encoded")
+	}
+	return key, nil
+}
+
 // isWritable checks if a directory is writable
 func isWritable(path string) error {
 	// Create directory if it doesn't exist
@@ -178,6 +200,15 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("OPENEP_STORAGE_DATA_DIR"); v != "" {
 		cfg.Storage.DataDir = v
 	}
+	if v := os.Getenv("OPENEP_STORAGE_ENCRYPTION_KEY"); v != "" {
+		cfg.Storage.EncryptionKey = v
+	}
+	if v := os.Getenv("OPENEP_STORAGE_S3PROXY_ACCESS_KEY"); v != "" {
+		cfg.Storage.S3Proxy.AccessKey = v
+	}
+	if v := os.Getenv("OPENEP_STORAGE_S3PROXY_SECRET_KEY"); v != "" {
+		cfg.Storage.S3Proxy.SecretKey = v
+	}
 
 	// Auth
 	if v := os.Getenv("OPENEP_AUTH_SECRET_KEY"); v != "" {
@@ -832,3 +832,20 @@ server:
 		t.Skip("Viper handles type conversion gracefully")
 	}
 }
+
+func TestStorageConfig_MasterKey(t *testing.T) {
+	if key, err := (StorageConfig{}).MasterKey(); key != nil || err != nil {
+		t.Errorf("MasterKey() without a key = %v, %v, want nil, nil", key, err)
+	}
+
+	valid := StorageConfig{EncryptionKey: "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="}
+	if key, err := valid.MasterKey(); err != nil || len(key) != 32 {
+		t.Errorf("MasterKey() = %d bytes, %v, want 32 bytes", len(key), err)
+	}
+
+	for _, bad := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := (StorageConfig{EncryptionKey: bad}).MasterKey(); err == nil {
+			t.Errorf("MasterKey(%q) should fail", bad)
+		}
+	}
+}
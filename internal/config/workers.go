@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Background worker names, used as keys for their run intervals
+const (
+	WorkerLifecycle        = "lifecycle"
+	WorkerReplication      = "replication"
+	WorkerScrubber         = "scrubber"
+	WorkerMultipartSweeper = "multipart_sweeper"
+	WorkerRestore          = "restore"
+)
+
+// WorkersConfig sets how often each background worker runs.
+// MultipartUploadMaxAge is how long a multipart upload may stay incomplete
+// before the multipart sweeper aborts it; zero leaves that to lifecycle
+// rules.
+type WorkersConfig struct {
+	LifecycleInterval        int `mapstructure:"lifecycle_interval"`         // seconds
+	ReplicationInterval      int `mapstructure:"replication_interval"`       // seconds
+	ScrubberInterval         int `mapstructure:"scrubber_interval"`          // seconds
+	MultipartSweeperInterval int `mapstructure:"multipart_sweeper_interval"` // seconds
+	RestoreInterval          int `mapstructure:"restore_interval"`           // seconds
+	MultipartUploadMaxAge    int `mapstructure:"multipart_upload_max_age"`   // seconds
+}
+
+// defaultWorkerIntervals are used for workers whose interval is not set
+var defaultWorkerIntervals = map[string]time.Duration{
+	WorkerLifecycle:        time.Hour,
+	WorkerReplication:      time.Minute,
+	WorkerScrubber:         24 * time.Hour,
+	WorkerMultipartSweeper: 6 * time.Hour,
+	WorkerRestore:          5 * time.Minute,
+}
+
+// WorkerIntervals holds the current run interval of every background worker.
+// Workers look their interval up before each run, so changes made with Set
+// take effect without a restart.
+type WorkerIntervals struct {
+	mu        sync.RWMutex
+	intervals map[string]time.Duration
+	changed   chan struct{}
+}
+
+// NewWorkerIntervals creates worker intervals from configuration, filling in
+// defaults for anything left unset
+func NewWorkerIntervals(cfg WorkersConfig) *WorkerIntervals {
+	w := &WorkerIntervals{
+		intervals: make(map[string]time.Duration, len(defaultWorkerIntervals)),
+		changed:   make(chan struct{}),
+	}
+	for name, interval := range defaultWorkerIntervals {
+		w.intervals[name] = interval
+	}
+
+	configured := map[string]int{
+		WorkerLifecycle:        cfg.LifecycleInterval,
+		WorkerReplication:      cfg.ReplicationInterval,
+		WorkerScrubber:         cfg.ScrubberInterval,
+		WorkerMultipartSweeper: cfg.MultipartSweeperInterval,
+		WorkerRestore:          cfg.RestoreInterval,
+	}
+	for name, seconds := range configured {
+		if seconds > 0 {
+			w.intervals[name] = time.Duration(seconds) * time.Second
+		}
+	}
+	return w
+}
+
+// Get returns the interval of a worker
+func (w *WorkerIntervals) Get(name string) time.Duration {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.intervals[name]
+}
+
+// Set changes the interval of a worker. Workers waiting for their next run
+// reschedule it against the new interval.
+func (w *WorkerIntervals) Set(name string, interval time.Duration) error {
+	if _, ok := defaultWorkerIntervals[name]; !ok {
+		return fmt.Errorf("unknown worker: %s", name)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("interval for %s must be positive", name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.intervals[name] = interval
+	close(w.changed)
+	w.changed = make(chan struct{})
+	return nil
+}
+
+// All returns the interval of every worker
+func (w *WorkerIntervals) All() map[string]time.Duration {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	all := make(map[string]time.Duration, len(w.intervals))
+	for name, interval := range w.intervals {
+		all[name] = interval
+	}
+	return all
+}
+
+// Sleep blocks until the named worker's next run is due and reports whether
+// it should run, which is false once stop is closed. The wait is measured
+// from the call, so shortening an interval can make a run due immediately.
+// A nil WorkerIntervals waits for fallback instead.
+func (w *WorkerIntervals) Sleep(name string, fallback time.Duration, stop <-chan struct{}) bool {
+	start := time.Now()
+	for {
+		interval := fallback
+		var changed chan struct{}
+		if w != nil {
+			w.mu.RLock()
+			interval = w.intervals[name]
+			changed = w.changed
+			w.mu.RUnlock()
+		}
+
+		timer := time.NewTimer(time.Until(start.Add(interval)))
+		select {
+		case <-timer.C:
+			return true
+		case <-changed:
+			timer.Stop()
+		case <-stop:
+			timer.Stop()
+			return false
+		}
+	}
+}
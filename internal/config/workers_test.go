@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWorkerIntervals(t *testing.T) {
+	intervals := NewWorkerIntervals(WorkersConfig{LifecycleInterval: 120})
+
+	if got := intervals.Get(WorkerLifecycle); got != 2*time.Minute {
+		t.Errorf("lifecycle interval = %v, want %v", got, 2*time.Minute)
+	}
+	if got := intervals.Get(WorkerReplication); got != time.Minute {
+		t.Errorf("replication interval = %v, want default %v", got, time.Minute)
+	}
+	if got := len(intervals.All()); got != len(defaultWorkerIntervals) {
+		t.Errorf("All() returned %d workers, want %d", got, len(defaultWorkerIntervals))
+	}
+}
+
+func TestWorkerIntervals_SetInvalid(t *testing.T) {
+	intervals := NewWorkerIntervals(WorkersConfig{})
+
+	if err := intervals.Set("unknown", time.Minute); err == nil {
+		t.Error("Set() should reject an unknown worker")
+	}
+	if err := intervals.Set(WorkerScrubber, 0); err == nil {
+		t.Error("Set() should reject a non-positive interval")
+	}
+	if got := intervals.Get(WorkerScrubber); got != 24*time.Hour {
+		t.Errorf("scrubber interval = %v, want it unchanged", got)
+	}
+}
+
+func TestWorkerIntervals_SleepReschedules(t *testing.T) {
+	intervals := NewWorkerIntervals(WorkersConfig{RestoreInterval: 3600})
+	stop := make(chan struct{})
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- intervals.Sleep(WorkerRestore, time.Hour, stop)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	intervals.Set(WorkerRestore, time.Millisecond)
+
+	select {
+	case ran := <-done:
+		if !ran {
+			t.Error("Sleep() = false, want true")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Sleep() did not pick up the shorter interval")
+	}
+}
+
+func TestWorkerIntervals_SleepStop(t *testing.T) {
+	var intervals *WorkerIntervals
+	stop := make(chan struct{})
+	close(stop)
+
+	if intervals.Sleep(WorkerLifecycle, time.Hour, stop) {
+		t.Error("Sleep() = true after stop, want false")
+	}
+}
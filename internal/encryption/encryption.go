@@ -1,11 +1,15 @@
 package encryption
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -98,6 +102,239 @@ func Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// SegmentSize is how many bytes of an object's data are sealed together.
+// Every segment has its own tag, so objects are encrypted and decrypted as
+// they stream, and a range only opens the segments it covers.
+const SegmentSize = 64 * 1024
+
+// Overhead is how many bytes sealing adds to each segment
+const Overhead = 16
+
+// SealedSize returns how many bytes size bytes of data take once sealed.
+// Empty data is still sealed as one segment, so it is authenticated too.
+func SealedSize(size int64) int64 {
+	return size + segmentCount(size)*Overhead
+}
+
+// SealedRange returns the range of sealed data, from start up to but not
+// including end, that holds plaintext bytes from offset up to limit of an
+// object of size bytes, and the index of the first segment in it
+func SealedRange(size, offset, limit int64) (start, end, segment int64) {
+	segment = offset / SegmentSize
+	last := max(segment, (limit-1)/SegmentSize)
+	start = segment * (SegmentSize + Overhead)
+	end = min((last+1)*(SegmentSize+Overhead), SealedSize(size))
+	return start, end, segment
+}
+
+// segmentCount returns how many segments size bytes of data are sealed in
+func segmentCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + SegmentSize - 1) / SegmentSize
+}
+
+// ObjectKey is the data key and nonce an object's data is sealed with
+type ObjectKey struct {
+	aead  cipher.AEAD
+	nonce []byte
+}
+
+// NewObjectKey generates a fresh data key and nonce for an object. The data
+// key is returned encrypted with masterKey; it and the nonce are needed to
+// open the object.
+func NewObjectKey(masterKey []byte) (key *ObjectKey, wrappedKey, nonce []byte, err error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(randReader, dataKey); err != nil {
+		return nil, nil, nil, err
+	}
+	gcm, err := objectGCM(dataKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(randReader, nonce); err != nil {
+		return nil, nil, nil, err
+	}
+	if wrappedKey, err = Encrypt(masterKey, dataKey); err != nil {
+		return nil, nil, nil, err
+	}
+	return &ObjectKey{aead: gcm, nonce: nonce}, wrappedKey, nonce, nil
+}
+
+// OpenObjectKey decrypts the data key of an object with masterKey
+func OpenObjectKey(masterKey, wrappedKey, nonce []byte) (*ObjectKey, error) {
+	dataKey, err := Decrypt(masterKey, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := objectGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return &ObjectKey{aead: gcm, nonce: nonce}, nil
+}
+
+// segmentNonce derives the nonce of a segment from the object's nonce, with
+// the segment's index XORed into its last eight bytes
+func (k *ObjectKey) segmentNonce(segment int64) []byte {
+	nonce := append([]byte(nil), k.nonce...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^uint64(segment))
+	return nonce
+}
+
+// segmentData is the additional data a segment is sealed with. Marking the
+// final segment means data cut short at a segment boundary fails to open.
+func segmentData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Seal returns a reader of plaintext sealed segment by segment
+func (k *ObjectKey) Seal(plaintext io.Reader) io.Reader {
+	return &sealReader{
+		key:   k,
+		src:   bufio.NewReaderSize(plaintext, SegmentSize),
+		plain: make([]byte, SegmentSize),
+	}
+}
+
+// Open returns a reader of the plaintext of sealed data, starting at
+// segment first, of an object of size bytes. Each segment is authenticated
+// before any of it is returned.
+func (k *ObjectKey) Open(sealed io.Reader, size, first int64) io.Reader {
+	return &openReader{
+		key:     k,
+		src:     sealed,
+		size:    size,
+		segment: first,
+		buf:     make([]byte, SegmentSize+Overhead),
+	}
+}
+
+// sealReader seals the data of src a segment at a time
+type sealReader struct {
+	key     *ObjectKey
+	src     *bufio.Reader
+	plain   []byte
+	sealed  []byte
+	out     []byte
+	segment int64
+	done    bool
+}
+
+// Read reads sealed data, sealing the next segment once the last is used up
+func (r *sealReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.plain)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return 0, err
+		}
+		if !final {
+			if _, err := r.src.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		r.sealed = r.key.aead.Seal(r.sealed[:0], r.key.segmentNonce(r.segment), r.plain[:n], segmentData(final))
+		r.out = r.sealed
+		r.segment++
+		r.done = final
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// openReader opens sealed data a segment at a time
+type openReader struct {
+	key     *ObjectKey
+	src     io.Reader
+	size    int64
+	segment int64
+	buf     []byte
+	out     []byte
+}
+
+// Read reads plaintext, opening the next segment once the last is used up
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		last := segmentCount(r.size) - 1
+		if r.segment > last {
+			return 0, io.EOF
+		}
+		length := int64(SegmentSize)
+		if r.segment == last {
+			length = r.size - last*SegmentSize
+		}
+		sealed := r.buf[:length+Overhead]
+		if _, err := io.ReadFull(r.src, sealed); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return 0, fmt.Errorf("sealed segment %d is truncated", r.segment)
+			}
+			return 0, err
+		}
+		plain, err := r.key.aead.Open(sealed[:0], r.key.segmentNonce(r.segment), sealed, segmentData(r.segment == last))
+		if err != nil {
+			return 0, fmt.Errorf("failed to open segment %d: %w", r.segment, err)
+		}
+		r.out = plain
+		r.segment++
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// SealObject encrypts an object's data under a fresh data key. It returns
+// the sealed data, the data key encrypted with masterKey and the object's
+// nonce; all three are needed to open it.
+func SealObject(masterKey, plaintext []byte) (ciphertext, wrappedKey, nonce []byte, err error) {
+	key, wrappedKey, nonce, err := NewObjectKey(masterKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ciphertext, err = io.ReadAll(key.Seal(bytes.NewReader(plaintext))); err != nil {
+		return nil, nil, nil, err
+	}
+	return ciphertext, wrappedKey, nonce, nil
+}
+
+// OpenObject decrypts data sealed by SealObject
+func OpenObject(masterKey, ciphertext, wrappedKey, nonce []byte) ([]byte, error) {
+	key, err := OpenObjectKey(masterKey, wrappedKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	segments := (int64(len(ciphertext)) + SegmentSize + Overhead - 1) / (SegmentSize + Overhead)
+	size := int64(len(ciphertext)) - max(segments, 1)*Overhead
+	if size < 0 || SealedSize(size) != int64(len(ciphertext)) {
+		return nil, errors.New("invalid sealed data length")
+	}
+	return io.ReadAll(key.Open(bytes.NewReader(ciphertext), size, 0))
+}
+
+// objectGCM returns the AES-GCM cipher for an object's data key
+func objectGCM(dataKey []byte) (cipher.AEAD, error) {
+	block, err := newCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(block)
+}
+
 // EncryptString encrypts a string and returns base64 encoded result
 func EncryptString(key []byte, plaintext string) (string, error) {
 	ciphertext, err := Encrypt(key, []byte(plaintext))
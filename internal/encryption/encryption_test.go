@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"errors"
+	"io"
 	"testing"
 )
 
@@ -430,3 +431,98 @@ func TestDecryptStringDecryptError(t *testing.T) {
 		t.Error("DecryptString with too short ciphertext should fail")
 	}
 }
+
+func TestSealOpenObject(t *testing.T) {
+	masterKey := make([]byte, 32)
+	rand.Read(masterKey)
+	plaintext := []byte("object data")
+
+	ciphertext, wrappedKey, nonce, err := SealObject(masterKey, plaintext)
+	if err != nil {
+		t.Fatalf("SealObject() error = %v", err)
+	}
+	if len(ciphertext) != len(plaintext)+Overhead {
+		t.Errorf("ciphertext is %d bytes, want %d", len(ciphertext), len(plaintext)+Overhead)
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Error("ciphertext contains the plaintext")
+	}
+
+	got, err := OpenObject(masterKey, ciphertext, wrappedKey, nonce)
+	if err != nil {
+		t.Fatalf("OpenObject() error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("OpenObject() = %q, want %q", got, plaintext)
+	}
+
+	otherKey := make([]byte, 32)
+	rand.Read(otherKey)
+	if _, err := OpenObject(otherKey, ciphertext, wrappedKey, nonce); err == nil {
+		t.Error("OpenObject() with another master key should fail")
+	}
+	ciphertext[0] ^= 1
+	if _, err := OpenObject(masterKey, ciphertext, wrappedKey, nonce); err == nil {
+		t.Error("OpenObject() of tampered data should fail")
+	}
+}
+
+func TestObjectKeySegments(t *testing.T) {
+	masterKey := make([]byte, 32)
+	rand.Read(masterKey)
+	plaintext := make([]byte, 2*SegmentSize+100)
+	rand.Read(plaintext)
+
+	key, wrappedKey, nonce, err := NewObjectKey(masterKey)
+	if err != nil {
+		t.Fatalf("NewObjectKey() error = %v", err)
+	}
+	sealed, err := io.ReadAll(key.Seal(bytes.NewReader(plaintext)))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	size := int64(len(plaintext))
+	if int64(len(sealed)) != SealedSize(size) {
+		t.Errorf("sealed data is %d bytes, want %d", len(sealed), SealedSize(size))
+	}
+
+	opened, err := OpenObjectKey(masterKey, wrappedKey, nonce)
+	if err != nil {
+		t.Fatalf("OpenObjectKey() error = %v", err)
+	}
+
+	// A range across a segment boundary opens only the segments holding it
+	offset, limit := int64(SegmentSize-10), int64(SegmentSize+10)
+	start, end, segment := SealedRange(size, offset, limit)
+	if segment != 0 || start != 0 || end != 2*(SegmentSize+Overhead) {
+		t.Errorf("SealedRange() = %d, %d, %d", start, end, segment)
+	}
+	got := make([]byte, limit)
+	if _, err := io.ReadFull(opened.Open(bytes.NewReader(sealed[start:end]), size, segment), got); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(got[offset:], plaintext[offset:limit]) {
+		t.Error("Open() of a range returned the wrong data")
+	}
+
+	start, end, segment = SealedRange(size, size-1, size)
+	if segment != 2 || end != int64(len(sealed)) {
+		t.Errorf("SealedRange() of the last byte = %d, %d, %d", start, end, segment)
+	}
+	got, err = io.ReadAll(opened.Open(bytes.NewReader(sealed[start:end]), size, segment))
+	if err != nil || !bytes.Equal(got, plaintext[2*SegmentSize:]) {
+		t.Errorf("Open() of the last segment = %d bytes, %v", len(got), err)
+	}
+
+	// Segments can be neither reordered nor dropped
+	swapped := append(append([]byte{}, sealed[SegmentSize+Overhead:2*(SegmentSize+Overhead)]...), sealed[:SegmentSize+Overhead]...)
+	if _, err := io.ReadAll(opened.Open(bytes.NewReader(swapped), size, 0)); err == nil {
+		t.Error("Open() of reordered segments should fail")
+	}
+	if _, err := io.ReadAll(opened.Open(bytes.NewReader(sealed[:2*(SegmentSize+Overhead)]), 2*SegmentSize, 0)); err == nil {
+		t.Error("Open() with the final segment dropped should fail")
+	}
+	if _, err := io.ReadAll(opened.Open(bytes.NewReader(sealed[:len(sealed)-1]), size, 0)); err == nil {
+		t.Error("Open() of truncated data should fail")
+	}
+}
@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// ACL permissions
+const (
+	PermissionRead        = "READ"
+	PermissionWrite       = "WRITE"
+	PermissionReadACP     = "READ_ACP"
+	PermissionWriteACP    = "WRITE_ACP"
+	PermissionFullControl = "FULL_CONTROL"
+)
+
+// Grantee types
+const (
+	GranteeCanonicalUser = "CanonicalUser"
+	GranteeEmail         = "AmazonCustomerByEmail"
+	GranteeGroup         = "Group"
+)
+
+// The predefined groups a grant can name by URI
+const (
+	GroupAllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	GroupAuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	GroupLogDelivery        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+// Owner owns every bucket and object; the server has a single account
+var Owner = metadata.ACLOwner{ID: "root", DisplayName: "root"}
+
+// cannedGrants are the grants each canned ACL makes on top of the owner's
+// full control. The bucket-owner ACLs add nothing, since the bucket owner
+// owns every object.
+var cannedGrants = map[string][]metadata.ACLGrant{
+	"private":                   nil,
+	"bucket-owner-read":         nil,
+	"bucket-owner-full-control": nil,
+	"aws-exec-read":             nil,
+	"public-read": {
+		groupGrant(GroupAllUsers, PermissionRead),
+	},
+	"public-read-write": {
+		groupGrant(GroupAllUsers, PermissionRead),
+		groupGrant(GroupAllUsers, PermissionWrite),
+	},
+	"authenticated-read": {
+		groupGrant(GroupAuthenticatedUsers, PermissionRead),
+	},
+	"log-delivery-write": {
+		groupGrant(GroupLogDelivery, PermissionWrite),
+		groupGrant(GroupLogDelivery, PermissionReadACP),
+	},
+}
+
+func groupGrant(uri, permission string) metadata.ACLGrant {
+	return metadata.ACLGrant{Grantee: metadata.ACLGrantee{Type: GranteeGroup, URI: uri}, Permission: permission}
+}
+
+// CannedACL expands a canned ACL, as sent in x-amz-acl, into the grants it
+// makes. Unknown names are ErrInvalidACL.
+func CannedACL(canned string) (*metadata.AccessControlList, error) {
+	grants, ok := cannedGrants[canned]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown canned ACL %q", ErrInvalidACL, canned)
+	}
+	acl := &metadata.AccessControlList{
+		Owner: Owner,
+		Grants: []metadata.ACLGrant{{
+			Grantee:    metadata.ACLGrantee{Type: GranteeCanonicalUser, ID: Owner.ID, DisplayName: Owner.DisplayName},
+			Permission: PermissionFullControl,
+		}},
+	}
+	acl.Grants = append(acl.Grants, grants...)
+	return acl, nil
+}
+
+// defaultACL is the ACL of a bucket or object that was never given one
+func defaultACL() *metadata.AccessControlList {
+	acl, _ := CannedACL("private")
+	return acl
+}
+
+// validateACL checks an ACL's grants and fills in its owner if it has none
+func validateACL(acl *metadata.AccessControlList) error {
+	if acl == nil {
+		return fmt.Errorf("%w: no ACL", ErrInvalidACL)
+	}
+	if acl.Owner.ID == "" {
+		acl.Owner = Owner
+	}
+	for _, grant := range acl.Grants {
+		switch grant.Permission {
+		case PermissionRead, PermissionWrite, PermissionReadACP, PermissionWriteACP, PermissionFullControl:
+		default:
+			return fmt.Errorf("%w: unknown permission %q", ErrInvalidACL, grant.Permission)
+		}
+
+		grantee := grant.Grantee
+		var named bool
+		switch grantee.Type {
+		case GranteeCanonicalUser:
+			named = grantee.ID != ""
+		case GranteeEmail:
+			named = grantee.EmailAddress != ""
+		case GranteeGroup:
+			named = grantee.URI == GroupAllUsers || grantee.URI == GroupAuthenticatedUsers || grantee.URI == GroupLogDelivery
+		default:
+			return fmt.Errorf("%w: unknown grantee type %q", ErrInvalidACL, grantee.Type)
+		}
+		if !named {
+			return fmt.Errorf("%w: grantee of type %s is not identified", ErrInvalidACL, grantee.Type)
+		}
+	}
+	return nil
+}
+
+// PutBucketACL replaces the ACL of a bucket
+func (s *ObjectService) PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error {
+	if err := validateACL(acl); err != nil {
+		return err
+	}
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+	return s.checkMetadataWrite(s.metadata.PutBucketACL(ctx, bucket, acl))
+}
+
+// GetBucketACL returns the ACL of a bucket, the default one granting the
+// owner full control if it was never set
+func (s *ObjectService) GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error) {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	acl, err := s.metadata.GetBucketACL(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if acl == nil {
+		return defaultACL(), nil
+	}
+	return acl, nil
+}
+
+// DeleteBucketACL resets the ACL of a bucket to the default
+func (s *ObjectService) DeleteBucketACL(ctx context.Context, bucket string) error {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+	return s.checkMetadataWrite(s.metadata.DeleteBucketACL(ctx, bucket))
+}
+
+// PutObjectACL replaces the ACL of an existing object
+func (s *ObjectService) PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := validateACL(acl); err != nil {
+		return err
+	}
+	if err := s.requireObject(ctx, bucket, key); err != nil {
+		return err
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+	return s.checkMetadataWrite(s.metadata.PutObjectACL(ctx, bucket, key, acl))
+}
+
+// GetObjectACL returns the ACL of an existing object, the default one
+// granting the owner full control if it was never set
+func (s *ObjectService) GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.requireObject(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
+	acl, err := s.metadata.GetObjectACL(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if acl == nil {
+		return defaultACL(), nil
+	}
+	return acl, nil
+}
+
+// setObjectACL stores the ACL an object was written with. Without one the
+// object gets the default ACL, so one left by an object it replaced is
+// dropped.
+func (s *ObjectService) setObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	if acl != nil {
+		if err := s.checkMetadataWrite(s.metadata.PutObjectACL(ctx, bucket, key, acl)); err != nil {
+			return fmt.Errorf("failed to save object ACL: %w", err)
+		}
+		return nil
+	}
+	stored, err := s.metadata.GetObjectACL(ctx, bucket, key)
+	if err != nil || stored == nil {
+		return err
+	}
+	if err := s.checkMetadataWrite(s.metadata.DeleteObjectACL(ctx, bucket, key)); err != nil {
+		return fmt.Errorf("failed to reset object ACL: %w", err)
+	}
+	return nil
+}
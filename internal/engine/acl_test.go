@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestCannedACL(t *testing.T) {
+	acl, err := CannedACL("public-read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.Owner != Owner || len(acl.Grants) != 2 {
+		t.Fatalf("CannedACL(public-read) = %+v", acl)
+	}
+	if g := acl.Grants[0]; g.Grantee.ID != Owner.ID || g.Permission != PermissionFullControl {
+		t.Errorf("first grant = %+v, want the owner's full control", g)
+	}
+	if g := acl.Grants[1]; g.Grantee.URI != GroupAllUsers || g.Permission != PermissionRead {
+		t.Errorf("second grant = %+v, want READ for all users", g)
+	}
+
+	if _, err := CannedACL("everyone"); !errors.Is(err, ErrInvalidACL) {
+		t.Errorf("CannedACL(everyone) error = %v, want ErrInvalidACL", err)
+	}
+}
+
+func TestObjectService_ObjectACL(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	publicRead, _ := CannedACL("public-read")
+	if _, err := svc.PutObject(ctx, "bucket", "a.txt", bytes.NewBufferString("data"), PutObjectOptions{ACL: publicRead}); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	got, err := svc.GetObjectACL(ctx, "bucket", "a.txt")
+	if err != nil || len(got.Grants) != 2 || got.Grants[1].Grantee.URI != GroupAllUsers {
+		t.Errorf("GetObjectACL() = %+v, %v, want the public-read grants", got, err)
+	}
+
+	// Resumable uploads store theirs when they complete
+	if _, err := svc.ResumePutObject(ctx, "bucket", "b.txt", "owner", "token", 0, 4, bytes.NewBufferString("data"), PutObjectOptions{ACL: publicRead}); err != nil {
+		t.Fatalf("ResumePutObject() error = %v", err)
+	}
+	if got, err := svc.GetObjectACL(ctx, "bucket", "b.txt"); err != nil || len(got.Grants) != 2 {
+		t.Errorf("GetObjectACL() after ResumePutObject() = %+v, %v, want the public-read grants", got, err)
+	}
+
+	// An ACL set later replaces it; one without an owner gets the default
+	err = svc.PutObjectACL(ctx, "bucket", "a.txt", &metadata.AccessControlList{Grants: []metadata.ACLGrant{{
+		Grantee:    metadata.ACLGrantee{Type: GranteeEmail, EmailAddress: "dev@example.com"},
+		Permission: PermissionReadACP,
+	}}})
+	if err != nil {
+		t.Fatalf("PutObjectACL() error = %v", err)
+	}
+	got, _ = svc.GetObjectACL(ctx, "bucket", "a.txt")
+	if got.Owner != Owner || len(got.Grants) != 1 || got.Grants[0].Permission != PermissionReadACP {
+		t.Errorf("GetObjectACL() after PutObjectACL = %+v", got)
+	}
+
+	for _, grant := range []metadata.ACLGrant{
+		{Grantee: metadata.ACLGrantee{Type: GranteeCanonicalUser, ID: "someone"}, Permission: "EVERYTHING"},
+		{Grantee: metadata.ACLGrantee{Type: GranteeGroup, URI: "http://example.com/group"}, Permission: PermissionRead},
+		{Grantee: metadata.ACLGrantee{Type: GranteeCanonicalUser}, Permission: PermissionRead},
+		{Grantee: metadata.ACLGrantee{Type: "Robot", ID: "someone"}, Permission: PermissionRead},
+	} {
+		acl := &metadata.AccessControlList{Grants: []metadata.ACLGrant{grant}}
+		if err := svc.PutObjectACL(ctx, "bucket", "a.txt", acl); !errors.Is(err, ErrInvalidACL) {
+			t.Errorf("PutObjectACL(%+v) error = %v, want ErrInvalidACL", grant, err)
+		}
+	}
+	if err := svc.PutObjectACL(ctx, "bucket", "missing", publicRead); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("PutObjectACL(missing) error = %v, want ErrObjectNotFound", err)
+	}
+
+	// Overwriting the object without an ACL resets it to the default
+	svc.PutObject(ctx, "bucket", "a.txt", bytes.NewBufferString("new"), PutObjectOptions{})
+	got, _ = svc.GetObjectACL(ctx, "bucket", "a.txt")
+	if len(got.Grants) != 1 || got.Grants[0].Permission != PermissionFullControl {
+		t.Errorf("GetObjectACL() after overwrite = %+v, want the default ACL", got)
+	}
+
+	// Copies get the ACL they are written with, not the source's
+	svc.PutObjectACL(ctx, "bucket", "a.txt", publicRead)
+	svc.CopyObject(ctx, "bucket", "a.txt", "bucket", "b.txt", CopyObjectOptions{})
+	if got, _ := svc.GetObjectACL(ctx, "bucket", "b.txt"); len(got.Grants) != 1 {
+		t.Errorf("GetObjectACL(copy) = %+v, want the default ACL", got)
+	}
+
+	// Deleting the object drops its ACL
+	svc.DeleteObject(ctx, "bucket", "a.txt", DeleteObjectOptions{})
+	svc.PutObject(ctx, "bucket", "a.txt", bytes.NewBufferString("again"), PutObjectOptions{})
+	if got, _ := svc.GetObjectACL(ctx, "bucket", "a.txt"); len(got.Grants) != 1 {
+		t.Errorf("GetObjectACL() after delete and recreate = %+v, want the default ACL", got)
+	}
+}
+
+func TestObjectService_BucketACL(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	got, err := svc.GetBucketACL(ctx, "bucket")
+	if err != nil || got.Owner != Owner || len(got.Grants) != 1 {
+		t.Errorf("GetBucketACL() = %+v, %v, want the default ACL", got, err)
+	}
+
+	logDelivery, _ := CannedACL("log-delivery-write")
+	if err := svc.PutBucketACL(ctx, "bucket", logDelivery); err != nil {
+		t.Fatalf("PutBucketACL() error = %v", err)
+	}
+	if got, _ := svc.GetBucketACL(ctx, "bucket"); len(got.Grants) != 3 || got.Grants[1].Grantee.URI != GroupLogDelivery {
+		t.Errorf("GetBucketACL() = %+v, want the log-delivery-write grants", got)
+	}
+
+	if err := svc.DeleteBucketACL(ctx, "bucket"); err != nil {
+		t.Fatalf("DeleteBucketACL() error = %v", err)
+	}
+	if got, _ := svc.GetBucketACL(ctx, "bucket"); len(got.Grants) != 1 {
+		t.Errorf("GetBucketACL() after delete = %+v, want the default ACL", got)
+	}
+
+	if _, err := svc.GetBucketACL(ctx, "missing"); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("GetBucketACL(missing) error = %v, want ErrBucketNotFound", err)
+	}
+}
@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// archived reports whether objects in a storage class must be restored
+// before their data can be read
+func archived(storageClass string) bool {
+	return storageClass == "GLACIER" || storageClass == "DEEP_ARCHIVE"
+}
+
+// readable reports whether an object's data can be read at now: it is not
+// archived, or a restore of it has completed and not yet expired
+func readable(meta *metadata.ObjectMetadata, now time.Time) bool {
+	if !archived(meta.StorageClass) {
+		return true
+	}
+	return meta.RestoreReadyAt <= now.Unix() && meta.RestoreExpiry > now.Unix()
+}
+
+// TransitionObject moves an object, or one version of it, to another storage
+// class. Only the metadata changes; the data stays where it is. Moving to an
+// archive class drops any restored copy, so the object needs a new restore
+// before it can be read.
+func (s *ObjectService) TransitionObject(ctx context.Context, bucket, key, versionID, storageClass string) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	unlock := s.locker.Lock(bucket, key)
+	defer unlock()
+
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+
+	meta := s.objectVersion(ctx, bucket, key, versionID)
+	if meta == nil {
+		if versionID != "" {
+			return fmt.Errorf("%w: %s/%s?versionId=%s", ErrNoSuchVersion, bucket, key, versionID)
+		}
+		return fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if meta.IsDeleteMarker {
+		return fmt.Errorf("%w: %s/%s?versionId=%s", ErrDeleteMarker, bucket, key, meta.VersionID)
+	}
+	if meta.StorageClass == storageClass {
+		return nil
+	}
+
+	updated := *meta
+	updated.StorageClass = storageClass
+	if archived(storageClass) {
+		updated.RestoreReadyAt = 0
+		updated.RestoreExpiry = 0
+	}
+	return s.checkMetadataWrite(s.metadata.UpdateObjectVersion(ctx, bucket, key, &updated))
+}
+
+// Restore retrieval tiers
+const (
+	RestoreTierExpedited = "Expedited"
+	RestoreTierStandard  = "Standard"
+	RestoreTierBulk      = "Bulk"
+)
+
+// RestoreObjectOptions describes a restore request
+type RestoreObjectOptions struct {
+	// Days is how long the restored copy stays readable once the restore
+	// completes. It must be positive.
+	Days int
+	// Tier is the retrieval tier, Standard if empty
+	Tier string
+}
+
+// restoredVersion identifies an object version with a restored copy
+type restoredVersion struct {
+	bucket, key, versionID string
+}
+
+// restoreSchedule holds the simulated retrieval time of each tier and the
+// restored copies waiting to be re-archived
+type restoreSchedule struct {
+	mu      sync.Mutex
+	delays  map[string]time.Duration
+	pending map[restoredVersion]int64 // restore expiry, unix seconds
+}
+
+// SetRestoreDelays sets how long a restore takes to complete in each
+// retrieval tier, simulating archive retrieval. Tiers without a delay, all
+// of them by default, complete at once.
+func (s *ObjectService) SetRestoreDelays(delays map[string]time.Duration) {
+	s.restores.mu.Lock()
+	defer s.restores.mu.Unlock()
+	s.restores.delays = delays
+}
+
+// restoreDelay returns the retrieval time of a tier
+func (s *ObjectService) restoreDelay(tier string) time.Duration {
+	s.restores.mu.Lock()
+	defer s.restores.mu.Unlock()
+	return s.restores.delays[tier]
+}
+
+// scheduleRearchive records a restored copy so ExpireRestores re-archives it
+func (s *ObjectService) scheduleRearchive(v restoredVersion, expiry int64) {
+	s.restores.mu.Lock()
+	defer s.restores.mu.Unlock()
+	if s.restores.pending == nil {
+		s.restores.pending = make(map[restoredVersion]int64)
+	}
+	s.restores.pending[v] = expiry
+}
+
+// restoreStatus returns the x-amz-restore value of an object at now: whether
+// a restore is in progress and, once it is done, when the restored copy
+// expires. It is empty for objects without a restore.
+func restoreStatus(meta *metadata.ObjectMetadata, now time.Time) string {
+	if !archived(meta.StorageClass) || meta.RestoreExpiry <= now.Unix() {
+		return ""
+	}
+	if meta.RestoreReadyAt > now.Unix() {
+		return `ongoing-request="true"`
+	}
+	expiry := time.Unix(meta.RestoreExpiry, 0).UTC().Format(http.TimeFormat)
+	return fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, expiry)
+}
+
+// RestoreObject starts restoring an archived object. The restore completes
+// after the tier's delay, from when the object is readable for opts.Days
+// days. Restoring an object that is already restored sets a new expiry. It
+// reports whether the object was already restored, and fails with
+// ErrRestoreInProgress while a restore is under way, ErrInvalidRestore for
+// a bad tier or number of days, and ErrInvalidObjectState for an object
+// that is not archived.
+func (s *ObjectService) RestoreObject(ctx context.Context, bucket, key string, opts RestoreObjectOptions) (bool, error) {
+	if opts.Days < 1 {
+		return false, fmt.Errorf("%w: days must be positive", ErrInvalidRestore)
+	}
+	tier := opts.Tier
+	switch tier {
+	case "":
+		tier = RestoreTierStandard
+	case RestoreTierExpedited, RestoreTierStandard, RestoreTierBulk:
+	default:
+		return false, fmt.Errorf("%w: unknown tier %q", ErrInvalidRestore, tier)
+	}
+
+	key = s.normalizeKey(ctx, bucket, key)
+	unlock := s.locker.Lock(bucket, key)
+	defer unlock()
+
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return false, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return false, err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return false, err
+	}
+
+	meta, err := s.metadata.GetObject(ctx, bucket, key, "")
+	if err != nil {
+		return false, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if !archived(meta.StorageClass) {
+		return false, fmt.Errorf("%w: %s/%s", ErrInvalidObjectState, bucket, key)
+	}
+
+	now := s.clock.Now()
+	if meta.RestoreReadyAt > now.Unix() && meta.RestoreExpiry > now.Unix() {
+		return false, fmt.Errorf("%w: %s/%s", ErrRestoreInProgress, bucket, key)
+	}
+	restored := meta.RestoreExpiry > now.Unix()
+	ready := now
+	if !restored {
+		ready = now.Add(s.restoreDelay(tier))
+	}
+
+	updated := *meta
+	updated.RestoreReadyAt = ready.Unix()
+	updated.RestoreExpiry = ready.AddDate(0, 0, opts.Days).Unix()
+	if err := s.checkMetadataWrite(s.metadata.UpdateObjectVersion(ctx, bucket, key, &updated)); err != nil {
+		return false, err
+	}
+	s.scheduleRearchive(restoredVersion{bucket, key, meta.VersionID}, updated.RestoreExpiry)
+	return restored, nil
+}
+
+// ExpireRestores re-archives the restored copies whose restore window has
+// passed, clearing their restore state, and returns how many it
+// re-archived. The lifecycle processor calls it on every pass. Copies that
+// fail to re-archive are kept for the next pass, and their errors returned
+// together. Restored copies are only tracked in memory, so ones restored
+// before a restart are not re-archived, though they stop being readable
+// when they expire all the same.
+func (s *ObjectService) ExpireRestores(ctx context.Context) (int, error) {
+	now := s.clock.Now().Unix()
+
+	s.restores.mu.Lock()
+	due := make(map[restoredVersion]int64)
+	for v, expiry := range s.restores.pending {
+		if expiry <= now {
+			due[v] = expiry
+			delete(s.restores.pending, v)
+		}
+	}
+	s.restores.mu.Unlock()
+
+	expired := 0
+	var errs []error
+	for v, expiry := range due {
+		done, err := s.rearchive(ctx, v, expiry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to re-archive %s/%s: %w", v.bucket, v.key, err))
+			s.retryRearchive(v, expiry)
+			continue
+		}
+		if done {
+			expired++
+		}
+	}
+	return expired, errors.Join(errs...)
+}
+
+// retryRearchive puts back a copy that failed to re-archive, unless it has
+// been restored again in the meantime
+func (s *ObjectService) retryRearchive(v restoredVersion, expiry int64) {
+	s.restores.mu.Lock()
+	defer s.restores.mu.Unlock()
+	if _, ok := s.restores.pending[v]; !ok {
+		s.restores.pending[v] = expiry
+	}
+}
+
+// rearchive clears the restore state of a version, unless it has been
+// restored again or changed since
+func (s *ObjectService) rearchive(ctx context.Context, v restoredVersion, expiry int64) (bool, error) {
+	unlock := s.locker.Lock(v.bucket, v.key)
+	defer unlock()
+
+	meta := s.objectVersion(ctx, v.bucket, v.key, v.versionID)
+	if meta == nil || meta.VersionID != v.versionID || meta.RestoreExpiry != expiry {
+		return false, nil
+	}
+	updated := *meta
+	updated.RestoreReadyAt = 0
+	updated.RestoreExpiry = 0
+	if err := s.checkMetadataWrite(s.metadata.UpdateObjectVersion(ctx, v.bucket, v.key, &updated)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/clock"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_RestoreObjectWorkflow(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.SetClock(fake)
+	svc.SetRestoreDelays(map[string]time.Duration{RestoreTierStandard: time.Hour, RestoreTierBulk: 5 * time.Hour})
+
+	if err := svc.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket() error: %v", err)
+	}
+	if _, err := svc.PutObject(ctx, "bucket", "cold", bytes.NewReader([]byte("data")), PutObjectOptions{StorageClass: "GLACIER"}); err != nil {
+		t.Fatalf("PutObject() error: %v", err)
+	}
+
+	if _, err := svc.RestoreObject(ctx, "bucket", "cold", RestoreObjectOptions{Days: 1, Tier: "Instant"}); !errors.Is(err, ErrInvalidRestore) {
+		t.Errorf("RestoreObject() with an unknown tier error = %v, expected ErrInvalidRestore", err)
+	}
+	if _, err := svc.RestoreObject(ctx, "bucket", "cold", RestoreObjectOptions{}); !errors.Is(err, ErrInvalidRestore) {
+		t.Errorf("RestoreObject() without days error = %v, expected ErrInvalidRestore", err)
+	}
+
+	restored, err := svc.RestoreObject(ctx, "bucket", "cold", RestoreObjectOptions{Days: 2})
+	if err != nil || restored {
+		t.Fatalf("RestoreObject() = %v, %v, expected a new restore", restored, err)
+	}
+
+	// The restore is ongoing until the Standard delay has passed
+	if _, err := svc.GetObject(ctx, "bucket", "cold", GetObjectOptions{}); !errors.Is(err, ErrInvalidObjectState) {
+		t.Errorf("GetObject() during the restore error = %v, expected ErrInvalidObjectState", err)
+	}
+	info, err := svc.HeadObject(ctx, "bucket", "cold")
+	if err != nil || info.Restore != `ongoing-request="true"` {
+		t.Errorf("HeadObject() during the restore Restore = %q, %v", info.Restore, err)
+	}
+	if _, err := svc.RestoreObject(ctx, "bucket", "cold", RestoreObjectOptions{Days: 2}); !errors.Is(err, ErrRestoreInProgress) {
+		t.Errorf("second RestoreObject() error = %v, expected ErrRestoreInProgress", err)
+	}
+
+	fake.Advance(time.Hour)
+	obj, err := svc.GetObject(ctx, "bucket", "cold", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() after the restore error: %v", err)
+	}
+	obj.Body.Close()
+	want := `ongoing-request="false", expiry-date="Wed, 03 Jan 2024 13:00:00 GMT"`
+	if obj.Restore != want {
+		t.Errorf("GetObject() Restore = %q, expected %q", obj.Restore, want)
+	}
+
+	// Nothing is due for re-archival inside the restore window
+	if n, err := svc.ExpireRestores(ctx); err != nil || n != 0 {
+		t.Errorf("ExpireRestores() inside the window = %d, %v", n, err)
+	}
+
+	fake.Advance(48 * time.Hour)
+	if n, err := svc.ExpireRestores(ctx); err != nil || n != 1 {
+		t.Fatalf("ExpireRestores() after the window = %d, %v, expected 1", n, err)
+	}
+	info, err = svc.HeadObject(ctx, "bucket", "cold")
+	if err != nil || info.Restore != "" {
+		t.Errorf("HeadObject() after re-archival Restore = %q, %v", info.Restore, err)
+	}
+	if _, err := svc.GetObject(ctx, "bucket", "cold", GetObjectOptions{}); !errors.Is(err, ErrInvalidObjectState) {
+		t.Errorf("GetObject() after re-archival error = %v, expected ErrInvalidObjectState", err)
+	}
+
+	// A re-archived object can be restored again, in another tier
+	if _, err := svc.RestoreObject(ctx, "bucket", "cold", RestoreObjectOptions{Days: 1, Tier: RestoreTierBulk}); err != nil {
+		t.Fatalf("RestoreObject() after re-archival error: %v", err)
+	}
+	fake.Advance(4 * time.Hour)
+	if info, _ := svc.HeadObject(ctx, "bucket", "cold"); !strings.Contains(info.Restore, `ongoing-request="true"`) {
+		t.Errorf("Bulk restore after 4h Restore = %q, expected it ongoing", info.Restore)
+	}
+}
+
+// failingUpdateMetadata fails UpdateObjectVersion for the keys in fail
+type failingUpdateMetadata struct {
+	*MockMetadataStore
+	fail map[string]bool
+}
+
+func (m *failingUpdateMetadata) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
+	if m.fail[key] {
+		return errors.New("update failed")
+	}
+	return m.MockMetadataStore.UpdateObjectVersion(ctx, bucket, key, meta)
+}
+
+func TestObjectService_ExpireRestoresErrors(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	meta := &failingUpdateMetadata{MockMetadataStore: NewMockMetadataStore(), fail: map[string]bool{}}
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	svc.SetClock(fake)
+	svc.CreateBucket(ctx, "bucket")
+	for _, key := range []string{"a", "b", "c"} {
+		svc.PutObject(ctx, "bucket", key, bytes.NewReader([]byte("data")), PutObjectOptions{StorageClass: "GLACIER"})
+		if _, err := svc.RestoreObject(ctx, "bucket", key, RestoreObjectOptions{Days: 1}); err != nil {
+			t.Fatalf("RestoreObject(%s) error: %v", key, err)
+		}
+	}
+
+	// A failure does not stop the others from being re-archived
+	meta.fail["b"] = true
+	fake.Advance(48 * time.Hour)
+	if n, err := svc.ExpireRestores(ctx); err == nil || n != 2 {
+		t.Fatalf("ExpireRestores() with a failing update = %d, %v, expected 2 and an error", n, err)
+	}
+
+	// and the failed copy is retried on the next pass
+	meta.fail["b"] = false
+	if n, err := svc.ExpireRestores(ctx); err != nil || n != 1 {
+		t.Errorf("ExpireRestores() retry = %d, %v, expected 1", n, err)
+	}
+	if n, err := svc.ExpireRestores(ctx); err != nil || n != 0 {
+		t.Errorf("ExpireRestores() with nothing due = %d, %v", n, err)
+	}
+}
+
+func TestObjectService_RestoreObjectNotArchived(t *testing.T) {
+	ctx := context.Background()
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "hot", bytes.NewReader([]byte("data")), PutObjectOptions{})
+
+	if _, err := svc.RestoreObject(ctx, "bucket", "hot", RestoreObjectOptions{Days: 1}); !errors.Is(err, ErrInvalidObjectState) {
+		t.Errorf("RestoreObject() of a STANDARD object error = %v, expected ErrInvalidObjectState", err)
+	}
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// Bucket modes. A bucket with no mode set is read-write.
+const (
+	BucketModeReadWrite   = "read-write"
+	BucketModeReadOnly    = "read-only"
+	BucketModeMaintenance = "maintenance"
+)
+
+// checkBucketMode refuses an operation the bucket's mode does not allow.
+// Read-only buckets fail writes with ErrBucketReadOnly; buckets under
+// maintenance fail reads and writes alike with ErrBucketMaintenance.
+func (s *ObjectService) checkBucketMode(ctx context.Context, bucket string, write bool) error {
+	mode, err := s.metadata.GetBucketMode(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get bucket mode: %w", err)
+	}
+	if mode == nil {
+		return nil
+	}
+
+	switch mode.Mode {
+	case BucketModeMaintenance:
+		return fmt.Errorf("%w: %s", ErrBucketMaintenance, bucket)
+	case BucketModeReadOnly:
+		if write {
+			return fmt.Errorf("%w: %s", ErrBucketReadOnly, bucket)
+		}
+	}
+	return nil
+}
+
+// PutBucketMode sets the access mode of a bucket, failing with
+// ErrInvalidBucketMode for an unknown mode. Setting the read-write mode
+// lifts any earlier restriction.
+func (s *ObjectService) PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error {
+	switch mode.Mode {
+	case BucketModeReadWrite, BucketModeReadOnly, BucketModeMaintenance:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidBucketMode, mode.Mode)
+	}
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+
+	return s.checkMetadataWrite(s.metadata.PutBucketMode(ctx, bucket, mode))
+}
+
+// GetBucketMode returns the access mode of a bucket. Buckets that never set
+// one report the read-write mode.
+func (s *ObjectService) GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error) {
+	mode, err := s.metadata.GetBucketMode(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if mode == nil {
+		mode = &metadata.BucketMode{Mode: BucketModeReadWrite}
+	}
+	return mode, nil
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_BucketMode(t *testing.T) {
+	ctx := context.Background()
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+	svc.CreateBucket(ctx, "other")
+	if _, err := svc.PutObject(ctx, "bucket", "a.txt", bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+		t.Fatalf("PutObject() error: %v", err)
+	}
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "big.bin", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error: %v", err)
+	}
+
+	writes := map[string]func() error{
+		"PutObject": func() error {
+			_, err := svc.PutObject(ctx, "bucket", "b.txt", bytes.NewReader([]byte("data")), PutObjectOptions{})
+			return err
+		},
+		"DeleteObject": func() error {
+			_, err := svc.DeleteObject(ctx, "bucket", "a.txt", DeleteObjectOptions{})
+			return err
+		},
+		"CopyObject into": func() error {
+			_, err := svc.CopyObject(ctx, "other", "a.txt", "bucket", "c.txt", CopyObjectOptions{})
+			return err
+		},
+		"CreateMultipartUpload": func() error {
+			_, err := svc.CreateMultipartUpload(ctx, "bucket", "d.bin", PutObjectOptions{})
+			return err
+		},
+		"UploadPart": func() error {
+			_, err := svc.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, bytes.NewReader([]byte("part")))
+			return err
+		},
+		"CompleteMultipartUpload": func() error {
+			_, err := svc.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, nil)
+			return err
+		},
+		"AbortMultipartUpload": func() error {
+			return svc.AbortMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID)
+		},
+	}
+	reads := map[string]func() error{
+		"GetObject": func() error {
+			result, err := svc.GetObject(ctx, "bucket", "a.txt", GetObjectOptions{})
+			if err == nil {
+				result.Body.Close()
+			}
+			return err
+		},
+		"HeadObject": func() error {
+			_, err := svc.HeadObject(ctx, "bucket", "a.txt")
+			return err
+		},
+		"ListObjects": func() error {
+			_, err := svc.ListObjects(ctx, "bucket", ListObjectsOptions{})
+			return err
+		},
+		"CopyObject out": func() error {
+			_, err := svc.CopyObject(ctx, "bucket", "a.txt", "other", "a.txt", CopyObjectOptions{})
+			return err
+		},
+	}
+
+	if err := svc.PutBucketMode(ctx, "bucket", &metadata.BucketMode{Mode: BucketModeReadOnly}); err != nil {
+		t.Fatalf("PutBucketMode() error: %v", err)
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrBucketReadOnly) {
+			t.Errorf("read-only: %s error = %v, expected ErrBucketReadOnly", name, err)
+		}
+	}
+	for name, read := range reads {
+		if err := read(); err != nil {
+			t.Errorf("read-only: %s error = %v", name, err)
+		}
+	}
+
+	if err := svc.PutBucketMode(ctx, "bucket", &metadata.BucketMode{Mode: BucketModeMaintenance}); err != nil {
+		t.Fatalf("PutBucketMode() error: %v", err)
+	}
+	for name, op := range reads {
+		if name == "CopyObject out" {
+			continue
+		}
+		if err := op(); !errors.Is(err, ErrBucketMaintenance) {
+			t.Errorf("maintenance: %s error = %v, expected ErrBucketMaintenance", name, err)
+		}
+	}
+	if err := writes["PutObject"](); !errors.Is(err, ErrBucketMaintenance) {
+		t.Errorf("maintenance: PutObject error = %v, expected ErrBucketMaintenance", err)
+	}
+
+	if err := svc.PutBucketMode(ctx, "bucket", &metadata.BucketMode{Mode: BucketModeReadWrite}); err != nil {
+		t.Fatalf("PutBucketMode() error: %v", err)
+	}
+	if err := writes["PutObject"](); err != nil {
+		t.Errorf("read-write: PutObject error = %v", err)
+	}
+}
+
+func TestObjectService_PutBucketModeInvalid(t *testing.T) {
+	ctx := context.Background()
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+
+	if err := svc.PutBucketMode(ctx, "bucket", &metadata.BucketMode{Mode: "frozen"}); !errors.Is(err, ErrInvalidBucketMode) {
+		t.Errorf("PutBucketMode(frozen) error = %v, expected ErrInvalidBucketMode", err)
+	}
+	if err := svc.PutBucketMode(ctx, "missing", &metadata.BucketMode{Mode: BucketModeReadOnly}); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("PutBucketMode() on a missing bucket error = %v, expected ErrBucketNotFound", err)
+	}
+	mode, err := svc.GetBucketMode(ctx, "bucket")
+	if err != nil || mode.Mode != BucketModeReadWrite {
+		t.Errorf("GetBucketMode() = %+v, %v, expected read-write", mode, err)
+	}
+}
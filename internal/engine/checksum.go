@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// Additional checksum algorithms an upload may ask for, besides the ETag
+const (
+	ChecksumCRC32  = "CRC32"
+	ChecksumCRC32C = "CRC32C"
+	ChecksumSHA1   = "SHA1"
+	ChecksumSHA256 = "SHA256"
+)
+
+// Checksum types. A FULL_OBJECT checksum is computed over the object's data;
+// a COMPOSITE one, only for multipart objects, over the checksums of its
+// parts.
+const (
+	ChecksumTypeFullObject = "FULL_OBJECT"
+	ChecksumTypeComposite  = "COMPOSITE"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// newChecksumHash returns a hash computing checksums with algorithm
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumCRC32:
+		return crc32.NewIEEE(), nil
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidChecksum, algorithm)
+}
+
+// checksumFor computes the algorithm checksum of data. If expected is set,
+// the data must match it. Without an algorithm there is no checksum, and
+// none may be expected.
+func checksumFor(algorithm, expected string, data []byte) (*metadata.ObjectChecksum, error) {
+	if algorithm == "" {
+		if expected != "" {
+			return nil, fmt.Errorf("%w: checksum given without its algorithm", ErrInvalidChecksum)
+		}
+		return nil, nil
+	}
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	value := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if expected != "" && expected != value {
+		return nil, fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, algorithm, value, expected)
+	}
+	return &metadata.ObjectChecksum{Algorithm: algorithm, Type: ChecksumTypeFullObject, Value: value}, nil
+}
+
+// multipartChecksumFor returns the checksum a multipart upload asks for, with
+// its type defaulting to COMPOSITE. SHA checksums cannot be combined across
+// parts, so only CRCs may be FULL_OBJECT.
+func multipartChecksumFor(algorithm, checksumType string) (*metadata.ObjectChecksum, error) {
+	if algorithm == "" {
+		if checksumType != "" {
+			return nil, fmt.Errorf("%w: checksum type given without an algorithm", ErrInvalidChecksum)
+		}
+		return nil, nil
+	}
+	if _, err := newChecksumHash(algorithm); err != nil {
+		return nil, err
+	}
+	switch checksumType {
+	case "":
+		checksumType = ChecksumTypeComposite
+	case ChecksumTypeComposite:
+	case ChecksumTypeFullObject:
+		if algorithm != ChecksumCRC32 && algorithm != ChecksumCRC32C {
+			return nil, fmt.Errorf("%w: %s checksums cannot be %s", ErrInvalidChecksum, algorithm, checksumType)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown checksum type %q", ErrInvalidChecksum, checksumType)
+	}
+	return &metadata.ObjectChecksum{Algorithm: algorithm, Type: checksumType}, nil
+}
+
+// multipartChecksum computes the checksum of a multipart object as its parts
+// are streamed through it. A nil multipartChecksum computes none.
+type multipartChecksum struct {
+	checksum metadata.ObjectChecksum
+	hash     hash.Hash
+	part     hash.Hash
+	parts    int
+}
+
+// newMultipartChecksum starts computing the checksum requested for an upload,
+// or returns nil if the upload did not ask for one
+func newMultipartChecksum(requested *metadata.ObjectChecksum) (*multipartChecksum, error) {
+	if requested == nil || requested.Algorithm == "" {
+		return nil, nil
+	}
+	h, err := newChecksumHash(requested.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	c := &multipartChecksum{checksum: *requested, hash: h}
+	if c.checksum.Type == ChecksumTypeComposite {
+		c.part, _ = newChecksumHash(requested.Algorithm)
+	}
+	return c, nil
+}
+
+// Write adds data of the current part
+func (c *multipartChecksum) Write(data []byte) (int, error) {
+	if c == nil {
+		return len(data), nil
+	}
+	if c.part != nil {
+		return c.part.Write(data)
+	}
+	return c.hash.Write(data)
+}
+
+// endPart ends the current part; later writes belong to the next one
+func (c *multipartChecksum) endPart() {
+	if c == nil {
+		return
+	}
+	c.parts++
+	if c.part != nil {
+		c.hash.Write(c.part.Sum(nil))
+		c.part.Reset()
+	}
+}
+
+// sum returns the checksum of the parts added
+func (c *multipartChecksum) sum() *metadata.ObjectChecksum {
+	if c == nil {
+		return nil
+	}
+	checksum := c.checksum
+	checksum.Value = base64.StdEncoding.EncodeToString(c.hash.Sum(nil))
+	if checksum.Type == ChecksumTypeComposite {
+		checksum.Value += fmt.Sprintf("-%d", c.parts)
+	}
+	return &checksum
+}
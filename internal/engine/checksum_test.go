@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"go.uber.org/zap"
+)
+
+func crc32cBase64(data []byte) string {
+	return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc32.Checksum(data, crc32cTable)))
+}
+
+func newChecksumService(t *testing.T) *ObjectService {
+	t.Helper()
+	meta, err := pebble.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { meta.Close() })
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	svc.CreateBucket(context.Background(), "bucket")
+	return svc
+}
+
+func TestObjectService_Checksum_PutObject(t *testing.T) {
+	svc := newChecksumService(t)
+	ctx := context.Background()
+	data := []byte("checksummed data")
+	want := crc32cBase64(data)
+
+	put, err := svc.PutObject(ctx, "bucket", "key", bytes.NewReader(data), PutObjectOptions{
+		ChecksumAlgorithm: ChecksumCRC32C,
+		Checksum:          want,
+	})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if put.Checksum == nil || put.Checksum.Value != want || put.Checksum.Type != ChecksumTypeFullObject {
+		t.Errorf("PutObject() checksum = %+v, want CRC32C %s", put.Checksum, want)
+	}
+
+	info, err := svc.HeadObject(ctx, "bucket", "key")
+	if err != nil || info.Checksum == nil || info.Checksum.Algorithm != ChecksumCRC32C || info.Checksum.Value != want {
+		t.Errorf("HeadObject() = %+v, %v, want the stored CRC32C", info, err)
+	}
+	attrs, err := svc.GetObjectAttributes(ctx, "bucket", "key", "")
+	if err != nil || attrs.Checksum == nil || attrs.Checksum.Value != want {
+		t.Errorf("GetObjectAttributes() = %+v, %v, want the stored CRC32C", attrs, err)
+	}
+
+	// A copy holds the same data, so it keeps the checksum
+	if _, err := svc.CopyObject(ctx, "bucket", "key", "bucket", "copy", CopyObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, result := readObject(t, svc, "bucket", "copy", GetObjectOptions{}); result.Checksum == nil || result.Checksum.Value != want {
+		t.Errorf("copy checksum = %+v, want %s", result.Checksum, want)
+	}
+
+	tests := []struct {
+		name string
+		opts PutObjectOptions
+		want error
+	}{
+		{"mismatch", PutObjectOptions{ChecksumAlgorithm: ChecksumCRC32C, Checksum: crc32cBase64([]byte("other"))}, ErrChecksumMismatch},
+		{"unknown algorithm", PutObjectOptions{ChecksumAlgorithm: "MD4"}, ErrInvalidChecksum},
+		{"value without algorithm", PutObjectOptions{Checksum: want}, ErrInvalidChecksum},
+	}
+	for _, tt := range tests {
+		if _, err := svc.PutObject(ctx, "bucket", "bad", bytes.NewReader(data), tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("PutObject(%s) error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := svc.HeadObject(ctx, "bucket", "bad"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("HeadObject() after refused puts error = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestObjectService_Checksum_Multipart(t *testing.T) {
+	svc := newChecksumService(t)
+	ctx := context.Background()
+	parts := [][]byte{[]byte("first part "), []byte("second part")}
+
+	upload := func(key string, opts PutObjectOptions) *ObjectResult {
+		t.Helper()
+		created, err := svc.CreateMultipartUpload(ctx, "bucket", key, opts)
+		if err != nil {
+			t.Fatalf("CreateMultipartUpload(%s) error = %v", key, err)
+		}
+		var infos []PartInfo
+		for i, data := range parts {
+			part, err := svc.UploadPart(ctx, "bucket", key, created.UploadID, i+1, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("UploadPart(%s, %d) error = %v", key, i+1, err)
+			}
+			if part.Checksum == nil || part.Checksum.Algorithm != opts.ChecksumAlgorithm {
+				t.Errorf("UploadPart(%s, %d) checksum = %+v, want %s", key, i+1, part.Checksum, opts.ChecksumAlgorithm)
+			}
+			infos = append(infos, PartInfo{PartNumber: i + 1, ETag: part.ETag})
+		}
+		done, err := svc.completeMultipartUpload(ctx, "bucket", key, created.UploadID, infos, 0, nil)
+		if err != nil {
+			t.Fatalf("completeMultipartUpload(%s) error = %v", key, err)
+		}
+		return done
+	}
+
+	// COMPOSITE is the default: a checksum of the part checksums
+	composite := sha256.New()
+	for _, data := range parts {
+		sum := sha256.Sum256(data)
+		composite.Write(sum[:])
+	}
+	done := upload("composite", PutObjectOptions{ChecksumAlgorithm: ChecksumSHA256})
+	want := base64.StdEncoding.EncodeToString(composite.Sum(nil)) + "-2"
+	if done.Checksum == nil || done.Checksum.Type != ChecksumTypeComposite || done.Checksum.Value != want {
+		t.Errorf("COMPOSITE checksum = %+v, want %s", done.Checksum, want)
+	}
+
+	done = upload("full", PutObjectOptions{ChecksumAlgorithm: ChecksumCRC32C, ChecksumType: ChecksumTypeFullObject})
+	want = crc32cBase64(bytes.Join(parts, nil))
+	if done.Checksum == nil || done.Checksum.Type != ChecksumTypeFullObject || done.Checksum.Value != want {
+		t.Errorf("FULL_OBJECT checksum = %+v, want %s", done.Checksum, want)
+	}
+	if info, err := svc.HeadObject(ctx, "bucket", "full"); err != nil || info.Checksum == nil || info.Checksum.Value != want {
+		t.Errorf("HeadObject(full) = %+v, %v", info, err)
+	}
+
+	if _, err := svc.CreateMultipartUpload(ctx, "bucket", "sha", PutObjectOptions{ChecksumAlgorithm: ChecksumSHA1, ChecksumType: ChecksumTypeFullObject}); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("CreateMultipartUpload(SHA1 FULL_OBJECT) error = %v, want ErrInvalidChecksum", err)
+	}
+
+	// Parts are checked against the checksum the client sends
+	created, err := svc.CreateMultipartUpload(ctx, "bucket", "checked", PutObjectOptions{ChecksumAlgorithm: ChecksumCRC32C})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.UploadPartWithOptions(ctx, "bucket", "checked", created.UploadID, 1, bytes.NewReader(parts[0]), UploadPartOptions{
+		ChecksumAlgorithm: ChecksumCRC32C,
+		Checksum:          crc32cBase64(parts[1]),
+	})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("UploadPartWithOptions() with a wrong checksum error = %v, want ErrChecksumMismatch", err)
+	}
+	_, err = svc.UploadPartWithOptions(ctx, "bucket", "checked", created.UploadID, 1, bytes.NewReader(parts[0]), UploadPartOptions{
+		ChecksumAlgorithm: ChecksumSHA256,
+	})
+	if !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("UploadPartWithOptions() with another algorithm error = %v, want ErrInvalidChecksum", err)
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// checkConditions evaluates the conditional headers of a read against the
+// object, in the order of RFC 7232: If-Match, else If-Unmodified-Since, then
+// If-None-Match, else If-Modified-Since. A failed If-Match or
+// If-Unmodified-Since is ErrPreconditionFailed; a matching If-None-Match or
+// an object unchanged since If-Modified-Since is ErrNotModified. Dates that
+// do not parse are ignored, as HTTP requires.
+func checkConditions(meta *metadata.ObjectMetadata, opts GetObjectOptions) error {
+	lastModified := time.Unix(meta.LastModified, 0)
+
+	if opts.IfMatch != "" {
+		if !etagListMatches(opts.IfMatch, meta.ETag) {
+			return fmt.Errorf("%w: If-Match %s", ErrPreconditionFailed, opts.IfMatch)
+		}
+	} else if t, ok := parseHTTPDate(opts.IfUnmodifiedSince); ok && lastModified.After(t) {
+		return fmt.Errorf("%w: modified since %s", ErrPreconditionFailed, opts.IfUnmodifiedSince)
+	}
+
+	if opts.IfNoneMatch != "" {
+		if etagListMatches(opts.IfNoneMatch, meta.ETag) {
+			return fmt.Errorf("%w: If-None-Match %s", ErrNotModified, opts.IfNoneMatch)
+		}
+	} else if t, ok := parseHTTPDate(opts.IfModifiedSince); ok && !lastModified.After(t) {
+		return fmt.Errorf("%w: not modified since %s", ErrNotModified, opts.IfModifiedSince)
+	}
+
+	return nil
+}
+
+// etagListMatches reports whether a comma-separated If-Match or If-None-Match
+// value names etag. Quotes and weak prefixes are ignored on both sides, so
+// clients that send a bare ETag still match, and "*" matches any object.
+func etagListMatches(list, etag string) bool {
+	etag = normalizeETag(etag)
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || normalizeETag(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeETag strips the weak prefix and surrounding quotes from an ETag
+func normalizeETag(etag string) string {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strings.Trim(etag, `"`)
+}
+
+// parseHTTPDate parses a conditional header date; it reports false for an
+// empty or malformed value
+func parseHTTPDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+func TestCheckConditions(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	meta := &metadata.ObjectMetadata{ETag: `"abc123"`, LastModified: modified.Unix()}
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	after := modified.Add(time.Hour).Format(http.TimeFormat)
+	at := modified.Format(http.TimeFormat)
+
+	tests := []struct {
+		name string
+		opts GetObjectOptions
+		want error
+	}{
+		{"no conditions", GetObjectOptions{}, nil},
+		{"If-Match quoted", GetObjectOptions{IfMatch: `"abc123"`}, nil},
+		{"If-Match unquoted", GetObjectOptions{IfMatch: "abc123"}, nil},
+		{"If-Match list", GetObjectOptions{IfMatch: `"other", "abc123"`}, nil},
+		{"If-Match star", GetObjectOptions{IfMatch: "*"}, nil},
+		{"If-Match mismatch", GetObjectOptions{IfMatch: `"other"`}, ErrPreconditionFailed},
+		{"If-None-Match quoted", GetObjectOptions{IfNoneMatch: `"abc123"`}, ErrNotModified},
+		{"If-None-Match unquoted", GetObjectOptions{IfNoneMatch: "abc123"}, ErrNotModified},
+		{"If-None-Match weak", GetObjectOptions{IfNoneMatch: `W/"abc123"`}, ErrNotModified},
+		{"If-None-Match mismatch", GetObjectOptions{IfNoneMatch: `"other"`}, nil},
+		{"If-Modified-Since before", GetObjectOptions{IfModifiedSince: before}, nil},
+		{"If-Modified-Since at", GetObjectOptions{IfModifiedSince: at}, ErrNotModified},
+		{"If-Modified-Since after", GetObjectOptions{IfModifiedSince: after}, ErrNotModified},
+		{"If-Modified-Since malformed", GetObjectOptions{IfModifiedSince: "yesterday"}, nil},
+		{"If-Unmodified-Since after", GetObjectOptions{IfUnmodifiedSince: after}, nil},
+		{"If-Unmodified-Since before", GetObjectOptions{IfUnmodifiedSince: before}, ErrPreconditionFailed},
+		// If-Match takes precedence over If-Unmodified-Since, and
+		// If-None-Match over If-Modified-Since
+		{"If-Match overrides If-Unmodified-Since", GetObjectOptions{IfMatch: "abc123", IfUnmodifiedSince: before}, nil},
+		{"If-None-Match overrides If-Modified-Since", GetObjectOptions{IfNoneMatch: `"other"`, IfModifiedSince: after}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConditions(meta, tt.opts)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("checkConditions() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkConditions() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
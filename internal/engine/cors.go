@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// corsMethods are the methods a CORS rule may allow
+var corsMethods = map[string]bool{
+	"GET":    true,
+	"PUT":    true,
+	"POST":   true,
+	"DELETE": true,
+	"HEAD":   true,
+}
+
+// validateCORS checks a CORS configuration before it is stored, so a rule
+// that could never match a browser request is rejected up front instead of
+// failing at preflight. Every rule needs at least one origin and one method,
+// methods must be GET, PUT, POST, DELETE or HEAD, origins must be "*" or
+// scheme://host with at most one wildcard, and MaxAgeSeconds must not be
+// negative.
+func validateCORS(cors *metadata.CORSConfiguration) error {
+	if len(cors.CORSRules) == 0 {
+		return fmt.Errorf("%w: at least one CORSRule is required", ErrInvalidCORS)
+	}
+
+	for i, rule := range cors.CORSRules {
+		n := i + 1
+		if len(rule.AllowedOrigins) == 0 {
+			return fmt.Errorf("%w: rule %d has no AllowedOrigin", ErrInvalidCORS, n)
+		}
+		if len(rule.AllowedMethods) == 0 {
+			return fmt.Errorf("%w: rule %d has no AllowedMethod", ErrInvalidCORS, n)
+		}
+		for _, method := range rule.AllowedMethods {
+			if !corsMethods[method] {
+				return fmt.Errorf("%w: rule %d allows unsupported method %q", ErrInvalidCORS, n, method)
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if !validCORSOrigin(origin) {
+				return fmt.Errorf("%w: rule %d has malformed origin %q", ErrInvalidCORS, n, origin)
+			}
+		}
+		if rule.MaxAgeSeconds < 0 {
+			return fmt.Errorf("%w: rule %d has negative MaxAgeSeconds %d", ErrInvalidCORS, n, rule.MaxAgeSeconds)
+		}
+	}
+	return nil
+}
+
+// validCORSOrigin reports whether origin can match the Origin header of a
+// browser request
+func validCORSOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	if strings.Count(origin, "*") > 1 || strings.ContainsAny(origin, " \t\r\n") {
+		return false
+	}
+	scheme, host, ok := strings.Cut(origin, "://")
+	return ok && scheme != "" && host != "" && !strings.Contains(host, "/")
+}
+
+// MatchCORSRule returns the first rule of a CORS configuration that allows a
+// request from origin using method and sending headers, or nil if none does.
+// Origins and headers may contain one "*" wildcard, and headers are matched
+// without regard to case.
+func MatchCORSRule(cors *metadata.CORSConfiguration, origin, method string, headers []string) *metadata.CORSRule {
+	if cors == nil {
+		return nil
+	}
+	for i := range cors.CORSRules {
+		rule := &cors.CORSRules[i]
+		if corsRuleMatches(rule, origin, method, headers) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// corsRuleMatches reports whether a rule allows origin, method and every
+// one of headers
+func corsRuleMatches(rule *metadata.CORSRule, origin, method string, headers []string) bool {
+	if !containsWildcardMatch(rule.AllowedOrigins, origin, false) {
+		return false
+	}
+	methodAllowed := false
+	for _, m := range rule.AllowedMethods {
+		if m == method {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		return false
+	}
+	for _, header := range headers {
+		if !containsWildcardMatch(rule.AllowedHeaders, header, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsWildcardMatch reports whether value matches any of patterns, each
+// of which may contain one "*" standing for any run of characters
+func containsWildcardMatch(patterns []string, value string, foldCase bool) bool {
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern = strings.ToLower(pattern)
+		}
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		if !wildcard {
+			if pattern == value {
+				return true
+			}
+			continue
+		}
+		if len(value) >= len(prefix)+len(suffix) && strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix) {
+			return true
+		}
+	}
+	return false
+}
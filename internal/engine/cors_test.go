@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_PutBucketCorsValidation(t *testing.T) {
+	valid := metadata.CORSRule{AllowedMethods: []string{"GET", "PUT"}, AllowedOrigins: []string{"https://*.example.com"}, MaxAgeSeconds: 3000}
+
+	tests := []struct {
+		name  string
+		rules []metadata.CORSRule
+	}{
+		{"no rules", nil},
+		{"no origin", []metadata.CORSRule{{AllowedMethods: []string{"GET"}}}},
+		{"no method", []metadata.CORSRule{{AllowedOrigins: []string{"*"}}}},
+		{"unsupported method", []metadata.CORSRule{{AllowedMethods: []string{"GET", "PATCH"}, AllowedOrigins: []string{"*"}}}},
+		{"lowercase method", []metadata.CORSRule{{AllowedMethods: []string{"get"}, AllowedOrigins: []string{"*"}}}},
+		{"origin without scheme", []metadata.CORSRule{{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{"example.com"}}}},
+		{"origin with two wildcards", []metadata.CORSRule{{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{"https://*.*.example.com"}}}},
+		{"origin with path", []metadata.CORSRule{{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{"https://example.com/app"}}}},
+		{"empty origin", []metadata.CORSRule{{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{""}}}},
+		{"negative max age", []metadata.CORSRule{{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{"*"}, MaxAgeSeconds: -1}}},
+		{"one bad rule among good ones", []metadata.CORSRule{valid, {AllowedMethods: []string{"TRACE"}, AllowedOrigins: []string{"*"}}}},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		meta := NewMockMetadataStore()
+		svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+
+		err := svc.PutBucketCors(ctx, "bucket", &metadata.CORSConfiguration{CORSRules: tt.rules})
+		if !errors.Is(err, ErrInvalidCORS) {
+			t.Errorf("%s: PutBucketCors() error = %v, expected ErrInvalidCORS", tt.name, err)
+		}
+		if stored, _ := meta.GetBucketCors(ctx, "bucket"); stored != nil {
+			t.Errorf("%s: invalid configuration was stored: %+v", tt.name, stored)
+		}
+	}
+
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	if err := svc.PutBucketCors(ctx, "bucket", &metadata.CORSConfiguration{CORSRules: []metadata.CORSRule{valid, {AllowedMethods: []string{"HEAD"}, AllowedOrigins: []string{"*", "http://localhost:3000"}}}}); err != nil {
+		t.Errorf("PutBucketCors() with valid rules error = %v", err)
+	}
+}
+
+func TestMatchCORSRule(t *testing.T) {
+	cors := &metadata.CORSConfiguration{CORSRules: []metadata.CORSRule{
+		{AllowedMethods: []string{"GET", "PUT"}, AllowedOrigins: []string{"https://*.example.com"}, AllowedHeaders: []string{"Content-Type", "x-amz-*"}},
+		{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{"*"}},
+	}}
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers []string
+		want    int // index of the matching rule, -1 for none
+	}{
+		{"wildcard subdomain", "https://app.example.com", "PUT", nil, 0},
+		{"allowed headers", "https://app.example.com", "PUT", []string{"content-type", "X-Amz-Date"}, 0},
+		{"header not allowed", "https://app.example.com", "PUT", []string{"Authorization"}, -1},
+		{"no rule allows the header", "https://app.example.com", "GET", []string{"Authorization"}, -1},
+		{"wildcard origin", "http://localhost:3000", "GET", nil, 1},
+		{"method not allowed", "http://localhost:3000", "PUT", nil, -1},
+		{"origin without the subdomain", "https://example.com", "PUT", nil, -1},
+		{"other scheme", "http://app.example.com", "PUT", nil, -1},
+	}
+
+	for _, tt := range tests {
+		got := MatchCORSRule(cors, tt.origin, tt.method, tt.headers)
+		switch {
+		case tt.want < 0 && got != nil:
+			t.Errorf("%s: MatchCORSRule() = %+v, want no match", tt.name, *got)
+		case tt.want >= 0 && got != &cors.CORSRules[tt.want]:
+			t.Errorf("%s: MatchCORSRule() = %v, want rule %d", tt.name, got, tt.want)
+		}
+	}
+
+	if MatchCORSRule(nil, "https://app.example.com", "GET", nil) != nil {
+		t.Error("MatchCORSRule(nil) matched a rule")
+	}
+}
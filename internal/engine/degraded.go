@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// ErrMetadataUnavailable is returned for writes while the metadata store is
+// read-only or out of space. Reads keep working in that state.
+var ErrMetadataUnavailable = errors.New("metadata store is not accepting writes")
+
+// writeProbeInterval limits how often a degraded service retries the
+// metadata store before failing writes fast
+const writeProbeInterval = 5 * time.Second
+
+// writeProbeKey is a scratch record used to test whether the metadata store
+// accepts writes again. It is deleted right after being written.
+const writeProbeKey = "openendpoint:write-probe"
+
+// writeHealth tracks whether the metadata store currently accepts writes
+type writeHealth struct {
+	mu        sync.Mutex
+	cause     error
+	since     time.Time
+	lastProbe time.Time
+}
+
+// checkMetadataWrite inspects the result of a metadata write. Failures caused
+// by a read-only or full store put the service into degraded mode and are
+// reported as ErrMetadataUnavailable; a successful write leaves it.
+func (s *ObjectService) checkMetadataWrite(err error) error {
+	if err == nil {
+		s.markWritable()
+		return nil
+	}
+	if !metadata.IsUnwritable(err) {
+		return err
+	}
+
+	s.writeHealth.mu.Lock()
+	if s.writeHealth.cause == nil {
+		s.writeHealth.since = s.clock.Now()
+		s.logger.Errorw("metadata store stopped accepting writes, serving reads only", "error", err)
+	}
+	s.writeHealth.cause = err
+	s.writeHealth.lastProbe = s.clock.Now()
+	s.writeHealth.mu.Unlock()
+
+	return fmt.Errorf("%w: %v", ErrMetadataUnavailable, err)
+}
+
+// markWritable leaves degraded mode
+func (s *ObjectService) markWritable() {
+	s.writeHealth.mu.Lock()
+	defer s.writeHealth.mu.Unlock()
+
+	if s.writeHealth.cause != nil {
+		s.logger.Infow("metadata store accepts writes again", "degradedFor", s.clock.Now().Sub(s.writeHealth.since).String())
+		s.writeHealth.cause = nil
+	}
+}
+
+// requireWritable fails fast while the metadata store is degraded so object
+// data is not written to storage for a write that cannot be recorded. The
+// store is probed at most once per writeProbeInterval.
+func (s *ObjectService) requireWritable(ctx context.Context) error {
+	s.writeHealth.mu.Lock()
+	cause := s.writeHealth.cause
+	probe := cause != nil && s.clock.Now().Sub(s.writeHealth.lastProbe) >= writeProbeInterval
+	s.writeHealth.mu.Unlock()
+
+	if cause == nil {
+		return nil
+	}
+	if !probe {
+		return fmt.Errorf("%w: %v", ErrMetadataUnavailable, cause)
+	}
+	return s.probeMetadataWrite(ctx)
+}
+
+// probeMetadataWrite writes and removes a scratch record to test the store
+func (s *ObjectService) probeMetadataWrite(ctx context.Context) error {
+	err := s.metadata.PutPresignedURL(ctx, writeProbeKey, &metadata.PresignedURLRequest{})
+	if err = s.checkMetadataWrite(err); err != nil {
+		return err
+	}
+	s.metadata.DeletePresignedURL(ctx, writeProbeKey)
+	return nil
+}
+
+// MetadataWritable reports whether the metadata store accepts writes. While
+// degraded it probes the store, so polling it from a readiness check is
+// enough to notice recovery.
+func (s *ObjectService) MetadataWritable(ctx context.Context) error {
+	s.writeHealth.mu.Lock()
+	degraded := s.writeHealth.cause != nil
+	s.writeHealth.mu.Unlock()
+
+	if !degraded {
+		return nil
+	}
+	return s.probeMetadataWrite(ctx)
+}
@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// DefaultEmptyBucketConcurrency is how many objects EmptyBucket deletes at
+// once when no concurrency is given
+const DefaultEmptyBucketConcurrency = 16
+
+// errBatchFull stops collecting a batch of objects to delete
+var errBatchFull = errors.New("batch full")
+
+// EmptyBucketOptions contains options for emptying a bucket
+type EmptyBucketOptions struct {
+	// Concurrency bounds how many objects are deleted at once
+	Concurrency int
+	// DeleteBucket deletes the bucket itself once it is empty
+	DeleteBucket bool
+	// Progress, if set, is called after each batch of deletions
+	Progress func(EmptyBucketProgress)
+}
+
+// EmptyBucketProgress reports how much of a bucket has been removed
+type EmptyBucketProgress struct {
+	Objects  int64 `json:"objects"`
+	Versions int64 `json:"versions"`
+	Bytes    int64 `json:"bytes"`
+	Uploads  int64 `json:"uploads"`
+}
+
+// EmptyBucket deletes every object, version, delete marker and in-progress
+// multipart upload in a bucket, along with their stored data. Objects are
+// deleted a page at a time with bounded concurrency, through DeleteObject so
+// usage counters follow along as it goes. It stops at
+// the first failed delete or when ctx is cancelled, returning what was
+// removed so far.
+func (s *ObjectService) EmptyBucket(ctx context.Context, bucket string, opts EmptyBucketOptions) (*EmptyBucketProgress, error) {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return nil, err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return nil, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultEmptyBucketConcurrency
+	}
+
+	progress := &EmptyBucketProgress{}
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(*progress)
+		}
+	}
+
+	uploads, err := s.metadata.ListMultipartUploads(ctx, bucket, "")
+	if err != nil {
+		return progress, fmt.Errorf("failed to list multipart uploads: %w", err)
+	}
+	for _, u := range uploads {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		if err := s.AbortMultipartUpload(ctx, bucket, u.Key, u.UploadID); err != nil {
+			return progress, fmt.Errorf("failed to abort upload %s: %w", u.UploadID, err)
+		}
+		progress.Uploads++
+	}
+	if len(uploads) > 0 {
+		report()
+	}
+
+	if s.versioningStatus(ctx, bucket) != "" {
+		err = s.emptyVersions(ctx, bucket, opts.Concurrency, progress, report)
+	} else {
+		err = s.emptyObjects(ctx, bucket, opts.Concurrency, progress, report)
+	}
+	if err != nil {
+		return progress, err
+	}
+
+	if progress.Objects > 0 || progress.Uploads > 0 {
+		s.logger.Infow("emptied bucket",
+			"bucket", bucket,
+			"objects", progress.Objects,
+			"versions", progress.Versions,
+			"bytes", progress.Bytes,
+			"uploads", progress.Uploads)
+	}
+
+	if opts.DeleteBucket {
+		if err := s.DeleteBucket(ctx, bucket); err != nil {
+			return progress, err
+		}
+	}
+	return progress, nil
+}
+
+// emptyObjects deletes the objects of an unversioned bucket, streaming them
+// from the metadata store a page at a time
+func (s *ObjectService) emptyObjects(ctx context.Context, bucket string, concurrency int, progress *EmptyBucketProgress, report func()) error {
+	marker := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch := make([]metadata.ObjectMetadata, 0, metadata.ListPageSize)
+		err := s.metadata.ListObjectsFunc(ctx, bucket, "", marker, func(meta metadata.ObjectMetadata) error {
+			if len(batch) == metadata.ListPageSize {
+				return errBatchFull
+			}
+			batch = append(batch, meta)
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchFull) {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		marker = batch[len(batch)-1].Key
+
+		err = s.emptyBatch(ctx, bucket, batch, false, concurrency, progress)
+		report()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// emptyVersions deletes every version of every key in a bucket with
+// versioning configured. Keys whose latest version is a delete marker have no
+// current object, so the keys come from the version listing, which the
+// metadata store only returns whole. They are deleted a page of keys at a
+// time.
+func (s *ObjectService) emptyVersions(ctx context.Context, bucket string, concurrency int, progress *EmptyBucketProgress, report func()) error {
+	versions, err := s.metadata.ListObjectVersions(ctx, bucket, "")
+	if err != nil {
+		return fmt.Errorf("failed to list object versions: %w", err)
+	}
+	seen := make(map[string]bool)
+	var keys []metadata.ObjectMetadata
+	for _, v := range versions {
+		if !seen[v.Key] {
+			seen[v.Key] = true
+			keys = append(keys, metadata.ObjectMetadata{Key: v.Key})
+		}
+	}
+
+	for len(keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := keys[:min(len(keys), metadata.ListPageSize)]
+		keys = keys[len(batch):]
+
+		err := s.emptyBatch(ctx, bucket, batch, true, concurrency, progress)
+		report()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emptyBatch deletes a batch of objects with at most concurrency deletes in
+// flight, adding what it removed to progress
+func (s *ObjectService) emptyBatch(ctx context.Context, bucket string, batch []metadata.ObjectMetadata, versioned bool, concurrency int, progress *EmptyBucketProgress) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, concurrency)
+	for _, meta := range batch {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(meta metadata.ObjectMetadata) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			versions, bytes, err := s.emptyKey(ctx, bucket, meta, versioned)
+			mu.Lock()
+			defer mu.Unlock()
+			progress.Versions += versions
+			progress.Bytes += bytes
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to delete %s: %w", meta.Key, err)
+				}
+				cancel()
+				return
+			}
+			progress.Objects++
+		}(meta)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// emptyKey deletes every version of a key. In a versioned bucket the
+// noncurrent versions go before the current one, so deleting the current
+// version does not promote another.
+func (s *ObjectService) emptyKey(ctx context.Context, bucket string, obj metadata.ObjectMetadata, versioned bool) (int64, int64, error) {
+	if !versioned {
+		if _, err := s.DeleteObject(ctx, bucket, obj.Key, DeleteObjectOptions{}); err != nil {
+			return 0, 0, err
+		}
+		return 1, obj.Size, nil
+	}
+
+	all, err := s.metadata.ListObjectVersions(ctx, bucket, obj.Key)
+	if err != nil {
+		return 0, 0, err
+	}
+	current := s.currentObject(ctx, bucket, obj.Key)
+	var ordered []metadata.ObjectMetadata
+	for _, v := range all {
+		if v.Key != obj.Key || (current != nil && v.VersionID == current.VersionID) {
+			continue
+		}
+		ordered = append(ordered, v)
+	}
+	if current != nil {
+		ordered = append(ordered, *current)
+	}
+
+	var versions, bytes int64
+	for _, v := range ordered {
+		if err := ctx.Err(); err != nil {
+			return versions, bytes, err
+		}
+		if _, err := s.DeleteObject(ctx, bucket, v.Key, DeleteObjectOptions{VersionID: v.VersionID}); err != nil {
+			return versions, bytes, err
+		}
+		versions++
+		if !v.IsDeleteMarker {
+			bytes += v.Size
+		}
+	}
+
+	// Tags and the ACL are only removed with the unversioned delete
+	if err := s.metadata.DeleteObjectTags(ctx, bucket, obj.Key, ""); err != nil {
+		s.logger.Warnw("failed to delete object tags", "bucket", bucket, "key", obj.Key, "error", err)
+	}
+	if err := s.metadata.DeleteObjectACL(ctx, bucket, obj.Key); err != nil {
+		s.logger.Warnw("failed to delete object ACL", "bucket", bucket, "key", obj.Key, "error", err)
+	}
+	return versions, bytes, nil
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_EmptyBucket(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	objects := metadata.ListPageSize*2 + 10
+	for i := 0; i < objects; i++ {
+		key := fmt.Sprintf("obj-%05d", i)
+		if _, err := svc.PutObject(ctx, "bucket", key, bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+			t.Fatalf("PutObject(%s) error = %v", key, err)
+		}
+	}
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "big.bin", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	if _, err := svc.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, bytes.NewReader([]byte("part"))); err != nil {
+		t.Fatalf("UploadPart() error = %v", err)
+	}
+
+	if err := svc.DeleteBucket(ctx, "bucket"); !errors.Is(err, ErrBucketNotEmpty) {
+		t.Fatalf("DeleteBucket() before emptying error = %v, want ErrBucketNotEmpty", err)
+	}
+
+	var reports []EmptyBucketProgress
+	progress, err := svc.EmptyBucket(ctx, "bucket", EmptyBucketOptions{
+		Concurrency: 4,
+		Progress:    func(p EmptyBucketProgress) { reports = append(reports, p) },
+	})
+	if err != nil {
+		t.Fatalf("EmptyBucket() error = %v", err)
+	}
+	want := EmptyBucketProgress{Objects: int64(objects), Versions: int64(objects), Bytes: int64(objects * 4), Uploads: 1}
+	if *progress != want {
+		t.Errorf("EmptyBucket() progress = %+v, want %+v", *progress, want)
+	}
+	// One report for the uploads, then one per page of objects
+	if len(reports) != 4 {
+		t.Errorf("EmptyBucket() reported progress %d times, want 4", len(reports))
+	}
+	if len(reports) > 0 && reports[len(reports)-1] != want {
+		t.Errorf("last progress report = %+v, want %+v", reports[len(reports)-1], want)
+	}
+	assertUsage(t, svc, "bucket", 0, 0)
+
+	uploads, _ := svc.metadata.ListMultipartUploads(ctx, "bucket", "")
+	if len(uploads) != 0 {
+		t.Errorf("multipart uploads after EmptyBucket() = %d, want 0", len(uploads))
+	}
+	if err := svc.DeleteBucket(ctx, "bucket"); err != nil {
+		t.Errorf("DeleteBucket() after emptying error = %v", err)
+	}
+}
+
+func TestObjectService_EmptyBucket_DeleteBucket(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "a.txt", bytes.NewReader([]byte("data")), PutObjectOptions{})
+
+	if _, err := svc.EmptyBucket(ctx, "bucket", EmptyBucketOptions{DeleteBucket: true}); err != nil {
+		t.Fatalf("EmptyBucket() error = %v", err)
+	}
+	if _, err := svc.GetBucket(ctx, "bucket"); err == nil {
+		t.Error("GetBucket() after EmptyBucket(DeleteBucket) succeeded, want the bucket gone")
+	}
+
+	if _, err := svc.EmptyBucket(ctx, "missing", EmptyBucketOptions{}); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("EmptyBucket(missing) error = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestObjectService_EmptyBucket_Cancel(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.CreateBucket(ctx, "bucket")
+
+	objects := metadata.ListPageSize + 10
+	for i := 0; i < objects; i++ {
+		svc.PutObject(ctx, "bucket", fmt.Sprintf("obj-%05d", i), bytes.NewReader([]byte("data")), PutObjectOptions{})
+	}
+
+	// Cancelling after the first page leaves the rest of the bucket in place
+	progress, err := svc.EmptyBucket(ctx, "bucket", EmptyBucketOptions{
+		Progress: func(EmptyBucketProgress) { cancel() },
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("EmptyBucket() error = %v, want context.Canceled", err)
+	}
+	if progress.Objects != int64(metadata.ListPageSize) {
+		t.Errorf("EmptyBucket() deleted %d objects before cancelling, want %d", progress.Objects, metadata.ListPageSize)
+	}
+	assertUsage(t, svc, "bucket", 10*4, 10)
+}
+
+func TestObjectService_EmptyBucket_Versioned(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := pebble.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutBucketVersioning(ctx, "bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("obj-%02d", i)
+		for v := 0; v < 3; v++ {
+			if _, err := svc.PutObject(ctx, "bucket", key, bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+				t.Fatalf("PutObject(%s) error = %v", key, err)
+			}
+		}
+		if i%2 == 0 {
+			svc.DeleteObject(ctx, "bucket", key, DeleteObjectOptions{})
+		}
+	}
+
+	progress, err := svc.EmptyBucket(ctx, "bucket", EmptyBucketOptions{DeleteBucket: true})
+	if err != nil {
+		t.Fatalf("EmptyBucket() error = %v", err)
+	}
+	// Three versions of every key, plus a delete marker on every other one
+	want := EmptyBucketProgress{Objects: 20, Versions: 70, Bytes: 60 * 4}
+	if *progress != want {
+		t.Errorf("EmptyBucket() progress = %+v, want %+v", *progress, want)
+	}
+	versions, _ := store.ListObjectVersions(ctx, "bucket", "")
+	if len(versions) != 0 {
+		t.Errorf("versions after EmptyBucket() = %d, want 0", len(versions))
+	}
+}
@@ -0,0 +1,52 @@
+package engine
+
+import "errors"
+
+// Errors returned by ObjectService. They are wrapped with the bucket or key
+// concerned, so test for them with errors.Is rather than by message.
+var (
+	ErrBucketNotFound     = errors.New("bucket not found")
+	ErrBucketNotEmpty     = errors.New("bucket not empty")
+	ErrBucketExists       = errors.New("bucket already exists")
+	ErrBucketOwnedByYou   = errors.New("bucket already exists and is owned by you")
+	ErrInvalidBucketName  = errors.New("invalid bucket name")
+	ErrObjectNotFound     = errors.New("object not found")
+	ErrObjectExists       = errors.New("object already exists")
+	ErrNoSuchVersion      = errors.New("version not found")
+	ErrDeleteMarker       = errors.New("version is a delete marker")
+	ErrEntityTooLarge     = errors.New("object size exceeds maximum allowed size")
+	ErrMetadataTooLarge   = errors.New("user metadata exceeds maximum allowed size")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrNotModified        = errors.New("not modified")
+	ErrQuotaExceeded      = errors.New("quota exceeded")
+	ErrInvalidPartNumber  = errors.New("invalid part number")
+	ErrTooManyParts       = errors.New("too many parts")
+	ErrInvalidPart        = errors.New("invalid part")
+	ErrInvalidPartOrder   = errors.New("parts not in ascending order")
+	ErrEntityTooSmall     = errors.New("part smaller than the minimum allowed size")
+	ErrInvalidTag         = errors.New("invalid tag")
+	ErrVersionedMove      = errors.New("objects in a versioned bucket cannot be moved")
+	ErrInvalidRetention   = errors.New("invalid retention")
+	ErrRetentionLocked    = errors.New("object is locked by its retention")
+	ErrLegalHold          = errors.New("object is under legal hold")
+	ErrInvalidLegalHold   = errors.New("invalid legal hold")
+	ErrObjectLockDisabled = errors.New("object lock is not enabled on the bucket")
+	ErrInvalidPresign     = errors.New("invalid presign request")
+	ErrInvalidBucketMode  = errors.New("invalid bucket mode")
+	ErrBucketReadOnly     = errors.New("bucket is read-only")
+	ErrBucketMaintenance  = errors.New("bucket is under maintenance")
+	ErrInvalidCORS        = errors.New("invalid CORS configuration")
+	ErrInvalidObjectState = errors.New("operation is not valid for the object's storage class")
+	ErrInvalidCopyRange   = errors.New("invalid copy source range")
+
+	ErrPublicAccessRestricted = errors.New("public access is restricted by the bucket's public access block")
+	ErrInvalidNotification    = errors.New("invalid notification configuration")
+	ErrInvalidRestore         = errors.New("invalid restore request")
+	ErrRestoreInProgress      = errors.New("object restore is already in progress")
+
+	ErrInvalidEncryption     = errors.New("unsupported server-side encryption")
+	ErrEncryptionUnavailable = errors.New("server-side encryption is not configured")
+	ErrInvalidACL            = errors.New("invalid ACL")
+	ErrInvalidChecksum       = errors.New("invalid checksum")
+	ErrChecksumMismatch      = errors.New("checksum does not match the data")
+)
@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"github.com/openendpoint/openendpoint/internal/events"
+)
+
+// SetEventBus makes the service publish an event for every object change.
+// Notification, replication and audit consumers subscribe to the bus rather
+// than hooking into individual operations.
+func (s *ObjectService) SetEventBus(bus *events.Bus) {
+	s.eventBus = bus
+}
+
+// publish sends an object event to the event bus, if one is set
+func (s *ObjectService) publish(event events.ObjectEvent) {
+	if s.eventBus != nil {
+		s.eventBus.Publish(event)
+	}
+}
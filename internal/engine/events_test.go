@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/events"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_PutObject_PublishesEvent(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	bus := events.NewBus()
+	svc.SetEventBus(bus)
+
+	notifications := make(chan events.ObjectEvent, 10)
+	audit := make(chan events.ObjectEvent, 10)
+	bus.Subscribe("notifications", func(e events.ObjectEvent) { notifications <- e })
+	bus.Subscribe("audit", func(e events.ObjectEvent) { audit <- e })
+
+	result, err := svc.PutObject(ctx, "bucket", "key.txt", bytes.NewReader([]byte("hello")), PutObjectOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	bus.Close()
+
+	for name, ch := range map[string]chan events.ObjectEvent{"notifications": notifications, "audit": audit} {
+		if len(ch) != 1 {
+			t.Fatalf("%s received %d events, want 1", name, len(ch))
+		}
+		e := <-ch
+		if e.Type != events.EventObjectUploaded || e.Bucket != "bucket" || e.Key != "key.txt" {
+			t.Errorf("%s event = %+v, want ObjectCreated:Put for bucket/key.txt", name, e)
+		}
+		if e.Size != 5 || e.ETag != result.ETag || e.VersionID != result.VersionID || e.ContentType != "text/plain" {
+			t.Errorf("%s event is missing object context: %+v", name, e)
+		}
+	}
+}
+
+func TestObjectService_DeleteAndCopy_PublishEvents(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("data")), PutObjectOptions{})
+
+	bus := events.NewBus()
+	svc.SetEventBus(bus)
+	received := make(chan events.ObjectEvent, 10)
+	bus.Subscribe("test", func(e events.ObjectEvent) { received <- e })
+
+	svc.CopyObject(ctx, "bucket", "src", "bucket", "dst", CopyObjectOptions{})
+	svc.DeleteObject(ctx, "bucket", "src", DeleteObjectOptions{})
+
+	var got []events.ObjectEvent
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case e := <-received:
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("received %d events, want 2", len(got))
+		}
+	}
+	svc.DeleteObject(ctx, "bucket", "missing", DeleteObjectOptions{})
+	bus.Close()
+
+	if len(received) != 0 {
+		t.Errorf("deleting a missing key published %+v", <-received)
+	}
+
+	if got[0].Type != events.EventObjectCopied || got[0].SourceKey != "src" || got[0].Key != "dst" {
+		t.Errorf("copy event = %+v", got[0])
+	}
+	if got[1].Type != events.EventObjectRemoved || got[1].Key != "src" || got[1].Size != 4 {
+		t.Errorf("delete event = %+v", got[1])
+	}
+}
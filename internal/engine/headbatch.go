@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// MaxHeadBatchSize is the largest number of keys HeadObjects resolves per call
+const MaxHeadBatchSize = 1000
+
+// ObjectHead reports whether a key exists and, if so, its basic attributes
+type ObjectHead struct {
+	Key          string
+	Exists       bool
+	Size         int64
+	ETag         string
+	LastModified int64
+}
+
+// HeadObjects looks up many keys of a bucket at once. Only the metadata store
+// is consulted, so no object bodies are opened. Results are returned in the
+// order of keys, and the lookup stops early if ctx is cancelled. Keys with
+// no metadata are reported as missing; any other metadata failure fails the
+// whole batch rather than being mistaken for a missing key.
+func (s *ObjectService) HeadObjects(ctx context.Context, bucket string, keys []string) ([]ObjectHead, error) {
+	if len(keys) > MaxHeadBatchSize {
+		return nil, fmt.Errorf("too many keys: %d (maximum %d)", len(keys), MaxHeadBatchSize)
+	}
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
+
+	normalization, err := s.GetBucketKeyNormalization(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	heads := make([]ObjectHead, len(keys))
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		heads[i].Key = key
+		meta, err := s.metadata.GetObject(ctx, bucket, applyKeyNormalization(normalization, key), "")
+		if errors.Is(err, metadata.ErrObjectNotFound) || (err == nil && meta == nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("head %s/%s: %w", bucket, key, err)
+		}
+		heads[i].Exists = true
+		heads[i].Size = meta.Size
+		heads[i].ETag = meta.ETag
+		heads[i].LastModified = meta.LastModified
+	}
+
+	return heads, nil
+}
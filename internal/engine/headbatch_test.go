@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestObjectService_HeadObjects(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "present", bytes.NewReader([]byte("hello")), PutObjectOptions{})
+
+	heads, err := svc.HeadObjects(ctx, "bucket", []string{"absent", "present"})
+	if err != nil {
+		t.Fatalf("HeadObjects() error = %v", err)
+	}
+	if heads[0].Key != "absent" || heads[0].Exists {
+		t.Errorf("heads[0] = %+v, want missing key \"absent\"", heads[0])
+	}
+	if heads[1].Key != "present" || !heads[1].Exists || heads[1].Size != 5 {
+		t.Errorf("heads[1] = %+v, want existing 5 byte object", heads[1])
+	}
+
+	if _, err := svc.HeadObjects(ctx, "bucket", make([]string, MaxHeadBatchSize+1)); err == nil {
+		t.Error("HeadObjects() should reject batches over MaxHeadBatchSize")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := svc.HeadObjects(cancelled, "bucket", []string{"present"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("HeadObjects() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestObjectService_HeadObjects_MetadataError(t *testing.T) {
+	store := &errorMetadataStore{MockMetadataStore: NewMockMetadataStore()}
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	store.getObjErr = errors.New("disk I/O error")
+	if _, err := svc.HeadObjects(ctx, "bucket", []string{"key"}); err == nil {
+		t.Error("HeadObjects() should fail when the metadata store fails, not report the key as missing")
+	}
+
+	if _, err := svc.HeadObjects(ctx, "no-such-bucket", []string{"key"}); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("HeadObjects() on unknown bucket error = %v, want ErrBucketNotFound", err)
+	}
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrIntegrityMismatch is returned at the end of a verified read when the
+// object data does not hash to its stored ETag
+var ErrIntegrityMismatch = errors.New("object data does not match its checksum")
+
+// contentHash returns the digest an ETag was computed from and the hash that
+// produced it: SHA-256 for objects written by PutObject, MD5 for ETags in the
+// S3 convention. Multipart ETags are digests of part digests, not of the
+// data, so they cannot be checked on read.
+func contentHash(etag string) (string, func() hash.Hash, bool) {
+	sum := strings.Trim(etag, "\"")
+	var newHash func() hash.Hash
+	switch len(sum) {
+	case sha256.Size * 2:
+		newHash = sha256.New
+	case md5.Size * 2:
+		newHash = md5.New
+	default:
+		return "", nil, false
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", nil, false
+	}
+	return strings.ToLower(sum), newHash, true
+}
+
+// verifyingReader hashes an object as it is read and fails the read that
+// reaches the end of the data if the hash differs from the expected one, so
+// callers never see a clean EOF for corrupted data
+type verifyingReader struct {
+	body     io.ReadCloser
+	hasher   hash.Hash
+	expected string
+
+	logger *zap.SugaredLogger
+	bucket string
+	key    string
+}
+
+// newVerifyingReader wraps body so reading it verifies the data against the
+// expected digest computed by newHash
+func newVerifyingReader(body io.ReadCloser, expected string, newHash func() hash.Hash, logger *zap.SugaredLogger, bucket, key string) *verifyingReader {
+	return &verifyingReader{
+		body:     body,
+		hasher:   newHash(),
+		expected: expected,
+		logger:   logger,
+		bucket:   bucket,
+		key:      key,
+	}
+}
+
+// Read reads from the object and checks its hash at EOF
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.body.Read(p)
+	v.hasher.Write(p[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(v.hasher.Sum(nil)); actual != v.expected {
+			v.logger.Errorw("object failed integrity check on read",
+				"bucket", v.bucket,
+				"key", v.key,
+				"expected", v.expected,
+				"actual", actual)
+			return n, ErrIntegrityMismatch
+		}
+	}
+	return n, err
+}
+
+// Close closes the underlying object reader
+func (v *verifyingReader) Close() error {
+	return v.body.Close()
+}
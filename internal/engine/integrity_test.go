@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/storage"
+	"go.uber.org/zap"
+)
+
+// corruptObject overwrites an object's stored data without touching its metadata
+func corruptObject(t *testing.T, store *MockStorageBackend, bucket, key string, data []byte) {
+	t.Helper()
+	if err := store.Put(context.Background(), bucket, key, bytes.NewReader(data), int64(len(data)), storage.PutOptions{}); err != nil {
+		t.Fatalf("failed to corrupt object: %v", err)
+	}
+}
+
+func TestObjectService_GetObject_VerifyIntegrity(t *testing.T) {
+	store := NewMockStorageBackend()
+	svc := New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	if _, err := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("original data")), PutObjectOptions{}); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+
+	// Intact data verifies cleanly
+	obj, err := svc.GetObject(ctx, "bucket", "key", GetObjectOptions{VerifyIntegrity: true})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	if !obj.Verified {
+		t.Error("GetObject() should report a verified read")
+	}
+	if data, err := io.ReadAll(obj.Body); err != nil || string(data) != "original data" {
+		t.Errorf("verified read = %q, %v; want the original data", data, err)
+	}
+	obj.Body.Close()
+
+	corruptObject(t, store, "bucket", "key", []byte("original dat4"))
+
+	obj, err = svc.GetObject(ctx, "bucket", "key", GetObjectOptions{VerifyIntegrity: true})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	if _, err := io.ReadAll(obj.Body); !errors.Is(err, ErrIntegrityMismatch) {
+		t.Errorf("verified read of corrupted object error = %v, want ErrIntegrityMismatch", err)
+	}
+	obj.Body.Close()
+
+	// Verification is opt-in
+	obj, err = svc.GetObject(ctx, "bucket", "key", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	if _, err := io.ReadAll(obj.Body); err != nil {
+		t.Errorf("unverified read error = %v", err)
+	}
+	obj.Body.Close()
+
+	// Ranged reads cannot be checked against the whole-object hash
+	obj, err = svc.GetObject(ctx, "bucket", "key", GetObjectOptions{
+		VerifyIntegrity: true,
+		Range:           &storage.Range{Start: 0, End: 3},
+	})
+	if err != nil {
+		t.Fatalf("GetObject() with range error = %v", err)
+	}
+	if obj.Verified {
+		t.Error("GetObject() should not report a ranged read as verified")
+	}
+	if _, err := io.ReadAll(obj.Body); err != nil {
+		t.Errorf("verified ranged read error = %v, want none", err)
+	}
+	obj.Body.Close()
+}
+
+func TestContentHash(t *testing.T) {
+	for _, sum := range []string{
+		"\"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae\"",
+		"\"d41d8cd98f00b204e9800998ecf8427e\"",
+	} {
+		if got, _, ok := contentHash(sum); !ok || got != sum[1:len(sum)-1] {
+			t.Errorf("contentHash(%s) = %q, %v", sum, got, ok)
+		}
+	}
+
+	for _, etag := range []string{"", "\"abc\"", "\"d41d8cd98f00b204e9800998ecf8427e-3\"", "\"0b9e3c1e-5d4c-4d0f-9c43-6f5e2a1b7c8d\""} {
+		if _, _, ok := contentHash(etag); ok {
+			t.Errorf("contentHash(%s) should not be a content hash", etag)
+		}
+	}
+}
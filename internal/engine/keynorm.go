@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeKey applies the bucket's key normalization to an object key or
+// prefix. Keys are returned unchanged unless normalization is enabled.
+func (s *ObjectService) normalizeKey(ctx context.Context, bucket, key string) string {
+	config, err := s.metadata.GetBucketKeyNormalization(ctx, bucket)
+	if err != nil || config == nil {
+		return key
+	}
+	return applyKeyNormalization(config, key)
+}
+
+// applyKeyNormalization rewrites key according to config
+func applyKeyNormalization(config *metadata.KeyNormalizationConfig, key string) string {
+	if config.UnicodeNFC {
+		key = norm.NFC.String(key)
+	}
+	if config.CaseInsensitive {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// PutBucketKeyNormalization sets how object keys in a bucket are normalized.
+// The mode can only change while the bucket is empty, since existing keys
+// would otherwise become unreachable under their normalized names.
+func (s *ObjectService) PutBucketKeyNormalization(ctx context.Context, bucket string, config *metadata.KeyNormalizationConfig) error {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+
+	current, err := s.GetBucketKeyNormalization(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if *current == *config {
+		return nil
+	}
+
+	objects, err := s.metadata.ListObjects(ctx, bucket, "", metadata.ListOptions{MaxKeys: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list bucket: %w", err)
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("key normalization can only be changed on an empty bucket: %s", bucket)
+	}
+
+	return s.metadata.PutBucketKeyNormalization(ctx, bucket, config)
+}
+
+// GetBucketKeyNormalization returns the key normalization of a bucket. Buckets
+// that never configured it report the zero value, i.e. byte-exact keys.
+func (s *ObjectService) GetBucketKeyNormalization(ctx context.Context, bucket string) (*metadata.KeyNormalizationConfig, error) {
+	config, err := s.metadata.GetBucketKeyNormalization(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &metadata.KeyNormalizationConfig{}
+	}
+	return config, nil
+}
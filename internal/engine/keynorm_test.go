@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_KeyNormalization_OffByDefault(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	svc.PutObject(ctx, "bucket", "Foo.txt", bytes.NewReader([]byte("upper")), PutObjectOptions{})
+	svc.PutObject(ctx, "bucket", "foo.txt", bytes.NewReader([]byte("lower")), PutObjectOptions{})
+
+	for key, want := range map[string]string{"Foo.txt": "upper", "foo.txt": "lower"} {
+		obj, err := svc.GetObject(ctx, "bucket", key, GetObjectOptions{})
+		if err != nil {
+			t.Fatalf("GetObject(%s) error = %v", key, err)
+		}
+		got, _ := io.ReadAll(obj.Body)
+		obj.Body.Close()
+		if string(got) != want {
+			t.Errorf("GetObject(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	config, err := svc.GetBucketKeyNormalization(ctx, "bucket")
+	if err != nil {
+		t.Fatalf("GetBucketKeyNormalization() error = %v", err)
+	}
+	if config.CaseInsensitive || config.UnicodeNFC {
+		t.Errorf("default key normalization = %+v, want none", config)
+	}
+}
+
+func TestObjectService_KeyNormalization_CaseInsensitive(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	if err := svc.PutBucketKeyNormalization(ctx, "bucket", &metadata.KeyNormalizationConfig{CaseInsensitive: true}); err != nil {
+		t.Fatalf("PutBucketKeyNormalization() error = %v", err)
+	}
+
+	svc.PutObject(ctx, "bucket", "Foo.txt", bytes.NewReader([]byte("first")), PutObjectOptions{})
+	svc.PutObject(ctx, "bucket", "FOO.TXT", bytes.NewReader([]byte("second")), PutObjectOptions{})
+
+	obj, err := svc.GetObject(ctx, "bucket", "foo.txt", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	got, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(got) != "second" {
+		t.Errorf("GetObject() = %q, want %q", got, "second")
+	}
+
+	list, err := svc.ListObjects(ctx, "bucket", ListObjectsOptions{Prefix: "FOO", MaxKeys: 100})
+	if err != nil {
+		t.Fatalf("ListObjects() error = %v", err)
+	}
+	if len(list.Objects) != 1 {
+		t.Errorf("ListObjects() returned %d objects, want 1", len(list.Objects))
+	}
+
+	if _, err := svc.DeleteObject(ctx, "bucket", "fOo.TxT", DeleteObjectOptions{}); err != nil {
+		t.Fatalf("DeleteObject() error = %v", err)
+	}
+	if _, err := svc.HeadObject(ctx, "bucket", "Foo.txt"); err == nil {
+		t.Error("object should be gone after deleting a differently cased key")
+	}
+}
+
+func TestObjectService_KeyNormalization_NFC(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutBucketKeyNormalization(ctx, "bucket", &metadata.KeyNormalizationConfig{UnicodeNFC: true})
+
+	decomposed := "cafe\u0301.txt" // e + combining acute accent
+	composed := "caf\u00e9.txt"
+
+	svc.PutObject(ctx, "bucket", decomposed, bytes.NewReader([]byte("data")), PutObjectOptions{})
+	if _, err := svc.HeadObject(ctx, "bucket", composed); err != nil {
+		t.Errorf("HeadObject(composed) error = %v, want the decomposed upload", err)
+	}
+}
+
+func TestObjectService_PutBucketKeyNormalization_RequiresEmptyBucket(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "Foo.txt", bytes.NewReader([]byte("data")), PutObjectOptions{})
+
+	err := svc.PutBucketKeyNormalization(ctx, "bucket", &metadata.KeyNormalizationConfig{CaseInsensitive: true})
+	if err == nil {
+		t.Error("PutBucketKeyNormalization() should refuse to change a non-empty bucket")
+	}
+
+	// Re-applying the current mode is always allowed
+	if err := svc.PutBucketKeyNormalization(ctx, "bucket", &metadata.KeyNormalizationConfig{}); err != nil {
+		t.Errorf("PutBucketKeyNormalization() with unchanged mode error = %v", err)
+	}
+}
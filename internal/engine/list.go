@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// errListLimit stops a streaming listing once MaxKeys objects were listed
+var errListLimit = errors.New("list limit reached")
+
+// ListObjectsFunc calls fn for each object in a bucket in key order. Objects
+// are streamed from the metadata store a page at a time, so memory stays
+// bounded however large the bucket is. Only opts.Prefix, opts.Marker and a
+// positive opts.MaxKeys apply; there is no delimiter grouping. Delete markers
+// are skipped. An error from fn stops the listing and is returned.
+func (s *ObjectService) ListObjectsFunc(ctx context.Context, bucket string, opts ListObjectsOptions, fn func(ObjectInfo) error) error {
+	if opts.Delimiter != "" {
+		return fmt.Errorf("delimiter is not supported when streaming a listing")
+	}
+	opts.Prefix = s.normalizeKey(ctx, bucket, opts.Prefix)
+	opts.Marker = s.normalizeKey(ctx, bucket, opts.Marker)
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return err
+	}
+
+	listed := 0
+	err := s.metadata.ListObjectsFunc(ctx, bucket, opts.Prefix, opts.Marker, func(meta metadata.ObjectMetadata) error {
+		if meta.IsDeleteMarker {
+			return nil
+		}
+		if opts.MaxKeys > 0 && listed == opts.MaxKeys {
+			return errListLimit
+		}
+		listed++
+		return fn(ObjectInfo{
+			Key:             meta.Key,
+			Size:            meta.Size,
+			ETag:            meta.ETag,
+			ContentType:     meta.ContentType,
+			ContentEncoding: meta.ContentEncoding,
+			CacheControl:    meta.CacheControl,
+			Metadata:        meta.Metadata,
+			StorageClass:    meta.StorageClass,
+			LastModified:    meta.LastModified,
+			VersionID:       meta.VersionID,
+			IsLatest:        true,
+		})
+	})
+	if errors.Is(err, errListLimit) {
+		return nil
+	}
+	return err
+}
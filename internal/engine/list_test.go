@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_ListObjectsFunc(t *testing.T) {
+	ctx := context.Background()
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("logs/%d.txt", i)
+		if _, err := svc.PutObject(ctx, "bucket", key, bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+			t.Fatalf("PutObject(%s) error: %v", key, err)
+		}
+	}
+	svc.PutObject(ctx, "bucket", "other.txt", bytes.NewReader([]byte("data")), PutObjectOptions{})
+	svc.metadata.PutObject(ctx, "bucket", "logs/2a.txt", &metadata.ObjectMetadata{Key: "logs/2a.txt", Bucket: "bucket", IsDeleteMarker: true})
+
+	tests := []struct {
+		name string
+		opts ListObjectsOptions
+		want []string
+	}{
+		{"prefix", ListObjectsOptions{Prefix: "logs/"}, []string{"logs/0.txt", "logs/1.txt", "logs/2.txt", "logs/3.txt", "logs/4.txt"}},
+		{"marker", ListObjectsOptions{Prefix: "logs/", Marker: "logs/2.txt"}, []string{"logs/3.txt", "logs/4.txt"}},
+		{"max keys", ListObjectsOptions{MaxKeys: 2}, []string{"logs/0.txt", "logs/1.txt"}},
+	}
+	for _, tt := range tests {
+		var keys []string
+		err := svc.ListObjectsFunc(ctx, "bucket", tt.opts, func(obj ObjectInfo) error {
+			keys = append(keys, obj.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: ListObjectsFunc() error: %v", tt.name, err)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: ListObjectsFunc() listed %v, expected %v", tt.name, keys, tt.want)
+		}
+	}
+
+	stop := errors.New("stop")
+	if err := svc.ListObjectsFunc(ctx, "bucket", ListObjectsOptions{}, func(ObjectInfo) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("ListObjectsFunc() error = %v, expected the callback's error", err)
+	}
+	if err := svc.ListObjectsFunc(ctx, "missing", ListObjectsOptions{}, func(ObjectInfo) error { return nil }); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("ListObjectsFunc() on a missing bucket error = %v, expected ErrBucketNotFound", err)
+	}
+}
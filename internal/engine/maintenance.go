@@ -0,0 +1,27 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// CompactMetadata compacts the metadata store, reclaiming the space of
+// deleted entries, and reports how long it took. Requests are served while
+// it runs.
+func (s *ObjectService) CompactMetadata(ctx context.Context) (time.Duration, error) {
+	start := s.clock.Now()
+	if err := s.checkMetadataWrite(s.metadata.Compact(ctx)); err != nil {
+		return 0, fmt.Errorf("failed to compact metadata: %w", err)
+	}
+	elapsed := s.clock.Now().Sub(start)
+	s.logger.Infow("metadata compacted", "duration", elapsed)
+	return elapsed, nil
+}
+
+// MetadataStats returns the metadata store's size on disk
+func (s *ObjectService) MetadataStats() (*metadata.Stats, error) {
+	return s.metadata.Stats()
+}
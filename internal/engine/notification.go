@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/events"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// SetWebhookPolicy sets the policy webhook targets are checked against
+// when they are configured. By default any public address is allowed.
+func (s *ObjectService) SetWebhookPolicy(policy *events.WebhookPolicy) {
+	s.webhookPolicy = policy
+}
+
+// validateWebhooks checks webhook notification targets before they are
+// stored, so a target that could never be delivered to is rejected up
+// front. Every webhook needs an absolute http or https URL the webhook
+// policy allows and at least one s3: event, and its filter rules must be
+// prefix or suffix rules.
+func (s *ObjectService) validateWebhooks(webhooks []metadata.WebhookConfiguration) error {
+	policy := s.webhookPolicy
+	if policy == nil {
+		policy = &events.WebhookPolicy{}
+	}
+	for i, webhook := range webhooks {
+		n := i + 1
+		if err := policy.CheckURL(webhook.URL); err != nil {
+			return fmt.Errorf("%w: webhook %d: %v", ErrInvalidNotification, n, err)
+		}
+		if len(webhook.Events) == 0 {
+			return fmt.Errorf("%w: webhook %d has no Event", ErrInvalidNotification, n)
+		}
+		for _, event := range webhook.Events {
+			if !strings.HasPrefix(event, "s3:") {
+				return fmt.Errorf("%w: webhook %d has unsupported event %q", ErrInvalidNotification, n, event)
+			}
+		}
+		for _, rule := range webhook.FilterRules {
+			if name := strings.ToLower(rule.Name); name != "prefix" && name != "suffix" {
+				return fmt.Errorf("%w: webhook %d has unsupported filter rule %q", ErrInvalidNotification, n, rule.Name)
+			}
+		}
+	}
+	return nil
+}
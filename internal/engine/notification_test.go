@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/events"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_PutBucketNotificationWebhookValidation(t *testing.T) {
+	valid := metadata.WebhookConfiguration{ID: "hook", URL: "https://hooks.example.com/s3", Events: []string{"s3:ObjectCreated:*"}}
+
+	tests := []struct {
+		name     string
+		webhooks []metadata.WebhookConfiguration
+	}{
+		{"no URL", []metadata.WebhookConfiguration{{Events: []string{"s3:ObjectCreated:*"}}}},
+		{"unsupported scheme", []metadata.WebhookConfiguration{{URL: "ftp://example.com", Events: []string{"s3:ObjectCreated:*"}}}},
+		{"relative URL", []metadata.WebhookConfiguration{{URL: "/hooks", Events: []string{"s3:ObjectCreated:*"}}}},
+		{"no event", []metadata.WebhookConfiguration{{URL: "http://example.com"}}},
+		{"unknown event", []metadata.WebhookConfiguration{{URL: "http://example.com", Events: []string{"ObjectCreated:*"}}}},
+		{"unknown filter rule", []metadata.WebhookConfiguration{{URL: "http://example.com", Events: []string{"s3:ObjectCreated:*"}, FilterRules: []metadata.FilterRule{{Name: "contains", Value: "x"}}}}},
+		{"one bad webhook among good ones", []metadata.WebhookConfiguration{valid, {URL: "example.com", Events: []string{"s3:ObjectCreated:*"}}}},
+		{"loopback", []metadata.WebhookConfiguration{{URL: "http://127.0.0.1:9000/", Events: []string{"s3:ObjectCreated:*"}}}},
+		{"metadata endpoint", []metadata.WebhookConfiguration{{URL: "http://169.254.169.254/latest/", Events: []string{"s3:ObjectCreated:*"}}}},
+		{"management API", []metadata.WebhookConfiguration{{URL: "https://hooks.example.com/_mgmt/cluster", Events: []string{"s3:ObjectCreated:*"}}}},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+
+		err := svc.PutBucketNotification(ctx, "bucket", &metadata.NotificationConfiguration{WebhookConfigurations: tt.webhooks})
+		if !errors.Is(err, ErrInvalidNotification) {
+			t.Errorf("%s: PutBucketNotification() error = %v, expected ErrInvalidNotification", tt.name, err)
+		}
+	}
+
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	valid.FilterRules = []metadata.FilterRule{{Name: "Prefix", Value: "logs/"}}
+	config := &metadata.NotificationConfiguration{WebhookConfigurations: []metadata.WebhookConfiguration{valid}}
+	if err := svc.PutBucketNotification(ctx, "bucket", config); err != nil {
+		t.Errorf("PutBucketNotification() with a valid webhook error: %v", err)
+	}
+
+	// The configured policy decides which targets are allowed
+	policy, err := events.NewWebhookPolicy([]string{"10.0.0.0/8"}, []string{"hooks.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetWebhookPolicy(policy)
+	if err := svc.PutBucketNotification(ctx, "bucket", config); !errors.Is(err, ErrInvalidNotification) {
+		t.Errorf("PutBucketNotification() to a denied host error = %v, expected ErrInvalidNotification", err)
+	}
+	config.WebhookConfigurations[0].URL = "http://10.1.2.3/hooks"
+	if err := svc.PutBucketNotification(ctx, "bucket", config); err != nil {
+		t.Errorf("PutBucketNotification() to an allowed network error: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// ResponseOverrides maps each query parameter a GET may use to override a
+// response header to the header it sets
+var ResponseOverrides = map[string]string{
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+	"response-content-language":    "Content-Language",
+	"response-content-type":        "Content-Type",
+	"response-expires":             "Expires",
+}
+
+// PresignOptions contains options for PresignObject
+type PresignOptions struct {
+	Method string
+	Expiry time.Duration
+	// ResponseHeaders overrides headers of the GET response, keyed by
+	// header name such as Content-Disposition
+	ResponseHeaders map[string]string
+}
+
+// PresignObject generates a SigV4 presigned URL for an object, signed with
+// the secret of accessKey. The expiry must be between one second and
+// auth.MaxPresignedExpiry, and response headers can only be overridden on
+// GET, or the request fails with ErrInvalidPresign. GET and HEAD URLs are
+// only issued for objects that exist.
+func (s *ObjectService) PresignObject(ctx context.Context, accessKey, bucket, key string, opts PresignOptions) (string, error) {
+	if s.signer == nil {
+		return "", fmt.Errorf("presigned URLs are not enabled")
+	}
+	if key == "" {
+		return "", fmt.Errorf("%w: key is required", ErrInvalidPresign)
+	}
+
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		return "", fmt.Errorf("%w: unsupported method %q", ErrInvalidPresign, opts.Method)
+	}
+	if opts.Expiry < time.Second || opts.Expiry > auth.MaxPresignedExpiry {
+		return "", fmt.Errorf("%w: expiry must be between 1s and %s, got %s", ErrInvalidPresign, auth.MaxPresignedExpiry, opts.Expiry)
+	}
+
+	params, err := responseOverrideParams(opts.ResponseHeaders)
+	if err != nil {
+		return "", err
+	}
+	if len(params) > 0 && method != http.MethodGet {
+		return "", fmt.Errorf("%w: response headers can only be overridden on GET", ErrInvalidPresign)
+	}
+
+	if method == http.MethodGet || method == http.MethodHead {
+		if _, err := s.HeadObject(ctx, bucket, key); err != nil {
+			return "", err
+		}
+	} else if err := s.HeadBucket(ctx, bucket); err != nil {
+		return "", err
+	}
+
+	return s.presign(ctx, accessKey, bucket, key, method, opts.Expiry, params)
+}
+
+// responseOverrideParams turns response header overrides into the query
+// parameters that carry them
+func responseOverrideParams(headers map[string]string) (url.Values, error) {
+	params := url.Values{}
+	for name, value := range headers {
+		param := "response-" + strings.ToLower(name)
+		if _, ok := ResponseOverrides[param]; !ok {
+			return nil, fmt.Errorf("%w: header %q cannot be overridden", ErrInvalidPresign, name)
+		}
+		params.Set(param, value)
+	}
+	return params, nil
+}
+
+// presign signs a URL for an object and records it with the metadata store
+func (s *ObjectService) presign(ctx context.Context, accessKey, bucket, key, method string, expiry time.Duration, params url.Values) (string, error) {
+	urlStr, err := s.signer.GeneratePresignedURLWithParams(accessKey, s.presignEndpoint, bucket, key, method, expiry, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign presigned URL: %w", err)
+	}
+
+	endpoint, _ := url.Parse(s.presignEndpoint)
+	req := &metadata.PresignedURLRequest{
+		Bucket:  bucket,
+		Key:     key,
+		Method:  method,
+		Expires: int64(expiry / time.Second),
+		Scheme:  endpoint.Scheme,
+		Host:    endpoint.Host,
+	}
+
+	// Store the presigned URL metadata
+	if err := s.metadata.PutPresignedURL(ctx, urlStr, req); err != nil {
+		return "", fmt.Errorf("failed to store presigned URL: %w", err)
+	}
+
+	return urlStr, nil
+}
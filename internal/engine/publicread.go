@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/iam"
+)
+
+// publicReadSid identifies the bucket policy statement SetBucketPublicRead
+// manages, so turning public read off leaves the rest of the policy alone
+const publicReadSid = "OpenEndpointPublicRead"
+
+// SetBucketPublicRead lets anyone, signed or not, GET and HEAD the objects
+// of a bucket, or stops doing so. Public read is a statement of the bucket
+// policy granting everyone s3:GetObject, which the router honors like any
+// other; writes still need credentials. Enabling it fails with
+// ErrPublicAccessRestricted while the bucket's public access block
+// restricts public buckets or blocks public policies.
+func (s *ObjectService) SetBucketPublicRead(ctx context.Context, bucket string, enabled bool) error {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if enabled {
+		block, err := s.metadata.GetPublicAccessBlock(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("failed to get public access block: %w", err)
+		}
+		if block != nil && (block.RestrictPublicBuckets || block.BlockPublicPolicy) {
+			return fmt.Errorf("%w: %s", ErrPublicAccessRestricted, bucket)
+		}
+	}
+
+	policy := map[string]interface{}{"Version": "2012-10-17"}
+	current, err := s.metadata.GetBucketPolicy(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get bucket policy: %w", err)
+	}
+	if current != nil {
+		if err := json.Unmarshal([]byte(*current), &policy); err != nil {
+			return fmt.Errorf("failed to parse bucket policy: %w", err)
+		}
+	}
+
+	// Statement may be a single statement or a list of them
+	var statements []interface{}
+	switch stmt := policy["Statement"].(type) {
+	case []interface{}:
+		statements = stmt
+	case map[string]interface{}:
+		statements = []interface{}{stmt}
+	}
+	kept := make([]interface{}, 0, len(statements)+1)
+	for _, stmt := range statements {
+		if m, ok := stmt.(map[string]interface{}); ok && m["Sid"] == publicReadSid {
+			continue
+		}
+		kept = append(kept, stmt)
+	}
+	if enabled {
+		kept = append(kept, map[string]interface{}{
+			"Sid":       publicReadSid,
+			"Effect":    "Allow",
+			"Principal": "*",
+			"Action":    "s3:GetObject",
+			"Resource":  "arn:aws:s3:::" + bucket + "/*",
+		})
+	}
+
+	if len(kept) == 0 {
+		if current == nil {
+			return nil
+		}
+		return s.checkMetadataWrite(s.metadata.DeleteBucketPolicy(ctx, bucket))
+	}
+	policy["Statement"] = kept
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to encode bucket policy: %w", err)
+	}
+	updated := string(data)
+	return s.checkMetadataWrite(s.metadata.PutBucketPolicy(ctx, bucket, &updated))
+}
+
+// BucketPublicRead reports whether the bucket policy lets anyone read the
+// bucket's objects, by SetBucketPublicRead or a statement of its own, and
+// whether the public access block keeps that from taking effect
+func (s *ObjectService) BucketPublicRead(ctx context.Context, bucket string) (enabled, restricted bool, err error) {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return false, false, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	policy, err := s.metadata.GetBucketPolicy(ctx, bucket)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get bucket policy: %w", err)
+	}
+	if policy != nil {
+		enabled, err = iam.PolicyAllowsAnonymous([]byte(*policy), "s3:GetObject", "arn:aws:s3:::"+bucket+"/*")
+		if err != nil {
+			return false, false, err
+		}
+	}
+	block, err := s.metadata.GetPublicAccessBlock(ctx, bucket)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get public access block: %w", err)
+	}
+	return enabled, block != nil && block.RestrictPublicBuckets, nil
+}
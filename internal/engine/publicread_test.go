@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_BucketPublicRead(t *testing.T) {
+	ctx := context.Background()
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+
+	if err := svc.SetBucketPublicRead(ctx, "missing", true); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("SetBucketPublicRead(missing) error = %v, want %v", err, ErrBucketNotFound)
+	}
+
+	// Public read is added to the policy beside its other statements
+	own := `{"Version":"2012-10-17","Statement":{"Sid":"Own","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::1:root"},"Action":"s3:*","Resource":"*"}}`
+	if err := svc.PutBucketPolicy(ctx, "bucket", &own); err != nil {
+		t.Fatalf("PutBucketPolicy() error: %v", err)
+	}
+	if err := svc.SetBucketPublicRead(ctx, "bucket", true); err != nil {
+		t.Fatalf("SetBucketPublicRead(true) error: %v", err)
+	}
+	enabled, restricted, err := svc.BucketPublicRead(ctx, "bucket")
+	if err != nil || !enabled || restricted {
+		t.Errorf("BucketPublicRead() = %v, %v, %v, want true, false, nil", enabled, restricted, err)
+	}
+	policy, _ := svc.GetBucketPolicy(ctx, "bucket")
+	if !strings.Contains(*policy, `"Own"`) || !strings.Contains(*policy, publicReadSid) {
+		t.Errorf("policy = %s, want both statements", *policy)
+	}
+
+	// Enabling it twice keeps one statement
+	if err := svc.SetBucketPublicRead(ctx, "bucket", true); err != nil {
+		t.Fatalf("SetBucketPublicRead(true) again error: %v", err)
+	}
+	policy, _ = svc.GetBucketPolicy(ctx, "bucket")
+	if n := strings.Count(*policy, publicReadSid); n != 1 {
+		t.Errorf("policy has %d public read statements, want 1", n)
+	}
+
+	// Disabling it leaves the other statements alone
+	if err := svc.SetBucketPublicRead(ctx, "bucket", false); err != nil {
+		t.Fatalf("SetBucketPublicRead(false) error: %v", err)
+	}
+	policy, _ = svc.GetBucketPolicy(ctx, "bucket")
+	if policy == nil || !strings.Contains(*policy, `"Own"`) || strings.Contains(*policy, publicReadSid) {
+		t.Errorf("policy after disabling = %v, want only the own statement", policy)
+	}
+	if enabled, _, _ := svc.BucketPublicRead(ctx, "bucket"); enabled {
+		t.Error("BucketPublicRead() after disabling = true")
+	}
+
+	// A policy holding nothing but public read is removed with it
+	svc.DeleteBucketPolicy(ctx, "bucket")
+	svc.SetBucketPublicRead(ctx, "bucket", true)
+	svc.SetBucketPublicRead(ctx, "bucket", false)
+	if policy, _ := svc.GetBucketPolicy(ctx, "bucket"); policy != nil {
+		t.Errorf("policy after disabling = %s, want none", *policy)
+	}
+}
+
+func TestObjectService_BucketPublicRead_Restricted(t *testing.T) {
+	ctx := context.Background()
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+
+	if err := svc.SetBucketPublicRead(ctx, "bucket", true); err != nil {
+		t.Fatalf("SetBucketPublicRead(true) error: %v", err)
+	}
+	svc.PutPublicAccessBlock(ctx, "bucket", &metadata.PublicAccessBlockConfiguration{RestrictPublicBuckets: true})
+
+	// Public read set earlier is reported as restricted
+	enabled, restricted, err := svc.BucketPublicRead(ctx, "bucket")
+	if err != nil || !enabled || !restricted {
+		t.Errorf("BucketPublicRead() = %v, %v, %v, want true, true, nil", enabled, restricted, err)
+	}
+
+	// and cannot be enabled again, but can be turned off
+	if err := svc.SetBucketPublicRead(ctx, "bucket", false); err != nil {
+		t.Fatalf("SetBucketPublicRead(false) error: %v", err)
+	}
+	if err := svc.SetBucketPublicRead(ctx, "bucket", true); !errors.Is(err, ErrPublicAccessRestricted) {
+		t.Errorf("SetBucketPublicRead(true) error = %v, want %v", err, ErrPublicAccessRestricted)
+	}
+}
@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetReplicationStatus records how far an object, or one version of it, has
+// got in being replicated. Like a storage class transition it only touches
+// the metadata, so the object keeps its ETag and modification time.
+func (s *ObjectService) SetReplicationStatus(ctx context.Context, bucket, key, versionID, status string) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	unlock := s.locker.Lock(bucket, key)
+	defer unlock()
+
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+
+	meta := s.objectVersion(ctx, bucket, key, versionID)
+	if meta == nil {
+		if versionID != "" {
+			return fmt.Errorf("%w: %s/%s?versionId=%s", ErrNoSuchVersion, bucket, key, versionID)
+		}
+		return fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if meta.IsDeleteMarker {
+		return fmt.Errorf("%w: %s/%s?versionId=%s", ErrDeleteMarker, bucket, key, meta.VersionID)
+	}
+	if meta.ReplicationStatus == status {
+		return nil
+	}
+
+	updated := *meta
+	updated.ReplicationStatus = status
+	return s.checkMetadataWrite(s.metadata.UpdateObjectVersion(ctx, bucket, key, &updated))
+}
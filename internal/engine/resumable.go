@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// resumableNamespace seeds the upload IDs derived from client upload tokens
+var resumableNamespace = uuid.MustParse("6f1c9a52-3d0e-4b8a-9a57-2f4be1f0c7d3")
+
+// resumableChunkSize bounds how much of a resumable body is held in memory;
+// the body is staged one part of at most this size at a time
+const resumableChunkSize = 8 * 1024 * 1024
+
+// DefaultResumableUploadTTL is how long a resumable upload may stay
+// incomplete before the vacuum job aborts it and frees its parts
+const DefaultResumableUploadTTL = 24 * time.Hour
+
+// ResumableUploadStatus describes the progress of a resumable PutObject
+type ResumableUploadStatus struct {
+	Token     string
+	UploadID  string
+	Offset    int64
+	Length    int64
+	Completed bool
+	Result    *ObjectResult
+}
+
+// ErrInvalidUploadLength is returned for a resumable upload declared with no
+// bytes; it is committed by completing a multipart upload, which needs at
+// least one part
+var ErrInvalidUploadLength = errors.New("resumable upload length must be at least one byte")
+
+// ResumeOffsetError is returned when a resumed write does not start at the
+// number of bytes already committed for the upload
+type ResumeOffsetError struct {
+	Expected int64
+	Got      int64
+}
+
+func (e *ResumeOffsetError) Error() string {
+	return fmt.Sprintf("upload offset mismatch: expected %d, got %d", e.Expected, e.Got)
+}
+
+// resumableUploadID derives the multipart upload ID backing a client token.
+// The ID is deterministic so a client that never saw a response (because the
+// connection dropped) can still resume with the token it chose. It includes
+// the uploading access key, so the same token used by another principal names
+// a different upload. These are version 5 UUIDs, while regular multipart
+// uploads get random version 4 ones, which is how the vacuum job tells them
+// apart.
+func resumableUploadID(owner, bucket, key, token string) string {
+	name := strings.Join([]string{owner, bucket, key, token}, "\x00")
+	return uuid.NewSHA1(resumableNamespace, []byte(name)).String()
+}
+
+// isResumableUploadID reports whether a multipart upload ID was derived by
+// resumableUploadID
+func isResumableUploadID(uploadID string) bool {
+	id, err := uuid.Parse(uploadID)
+	return err == nil && id.Version() == 5
+}
+
+// ResumePutObject writes data for a single-PUT upload that can be resumed after
+// an interruption. Bytes are staged as parts of a multipart upload keyed by the
+// uploading access key (empty for anonymous requests) and the client-supplied
+// token; offset must equal the bytes already committed. Whatever arrives
+// before the reader fails is kept, and the object is only committed once
+// length bytes have been received. Uploads left incomplete for longer than
+// DefaultResumableUploadTTL are aborted by the vacuum job.
+func (s *ObjectService) ResumePutObject(ctx context.Context, bucket, key, owner, token string, offset, length int64, data io.Reader, opts PutObjectOptions) (*ResumableUploadStatus, error) {
+	if token == "" {
+		return nil, fmt.Errorf("upload token is required")
+	}
+	key = s.normalizeKey(ctx, bucket, key)
+	if length <= 0 {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidUploadLength, length)
+	}
+
+	uploadID := resumableUploadID(owner, bucket, key, token)
+	unlock := s.locker.Lock(bucket, key+"?uploadId="+uploadID)
+	defer unlock()
+
+	if err := s.checkResumeWrite(ctx, bucket, key, length, opts); err != nil {
+		return nil, err
+	}
+	// Encryption and the checksum are applied when the upload completes, but
+	// bad headers are refused before anything is staged
+	if _, err := s.encryptionFor(ctx, bucket, opts.ServerSideEncryption); err != nil {
+		return nil, err
+	}
+	if opts.ChecksumAlgorithm != "" {
+		if _, err := newChecksumHash(opts.ChecksumAlgorithm); err != nil {
+			return nil, err
+		}
+	} else if opts.Checksum != "" {
+		return nil, fmt.Errorf("%w: checksum given without its algorithm", ErrInvalidChecksum)
+	}
+
+	status := &ResumableUploadStatus{
+		Token:    token,
+		UploadID: uploadID,
+		Length:   length,
+	}
+
+	exists, err := s.resumableUploadExists(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []metadata.PartMetadata
+	if exists {
+		parts, err = s.metadata.ListParts(ctx, bucket, key, uploadID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		for _, p := range parts {
+			status.Offset += p.Size
+		}
+	}
+
+	if offset != status.Offset {
+		return status, &ResumeOffsetError{Expected: status.Offset, Got: offset}
+	}
+
+	if !exists {
+		meta := &metadata.ObjectMetadata{
+			Key:         key,
+			Bucket:      bucket,
+			ContentType: opts.ContentType,
+			Metadata:    opts.Metadata,
+		}
+		if err := s.checkMetadataWrite(s.metadata.CreateMultipartUpload(ctx, bucket, key, uploadID, meta)); err != nil {
+			return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+		}
+	}
+
+	// Stage the body a chunk at a time, keeping whatever was received even if
+	// the reader fails part way. A body that ends early leaves the upload
+	// open for the client to resume.
+	buf := make([]byte, min(resumableChunkSize, length-status.Offset))
+	for status.Offset < length {
+		n, readErr := fill(data, buf[:min(int64(len(buf)), length-status.Offset)])
+		if n > 0 {
+			if err := s.checkResumeWrite(ctx, bucket, key, status.Offset+int64(n), opts); err != nil {
+				return status, err
+			}
+			part, err := s.UploadPart(ctx, bucket, key, uploadID, len(parts)+1, bytes.NewReader(buf[:n]))
+			if err != nil {
+				return status, err
+			}
+			parts = append(parts, metadata.PartMetadata{PartNumber: part.PartNumber, ETag: part.ETag, Size: part.Size})
+			status.Offset += part.Size
+		}
+		if readErr == io.EOF {
+			return status, nil
+		}
+		if readErr != nil {
+			return status, fmt.Errorf("upload interrupted at offset %d: %w", status.Offset, readErr)
+		}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	completed := make([]PartInfo, len(parts))
+	for i, p := range parts {
+		completed[i] = PartInfo{PartNumber: p.PartNumber, ETag: p.ETag, Size: p.Size}
+	}
+
+	if err := s.checkResumeWrite(ctx, bucket, key, length, opts); err != nil {
+		return status, err
+	}
+	// Parts end wherever a connection dropped, so they are exempt from the
+	// minimum part size. The object takes the headers of the request that
+	// completes it.
+	result, err := s.completeMultipartUpload(ctx, bucket, key, uploadID, completed, 0, &opts)
+	if err != nil {
+		return status, err
+	}
+	if err := s.applyObjectLockOptions(ctx, bucket, key, opts); err != nil {
+		return status, err
+	}
+	if opts.ACL != nil {
+		if err := s.setObjectACL(ctx, bucket, key, opts.ACL); err != nil {
+			return status, err
+		}
+	}
+	status.Completed = true
+	status.Result = result
+	return status, nil
+}
+
+// checkResumeWrite makes PutObject's checks for a resumable upload of size
+// bytes. They are repeated before every chunk is staged and before the
+// upload is committed, since a resumed upload may run long after it began.
+func (s *ObjectService) checkResumeWrite(ctx context.Context, bucket, key string, size int64, opts PutObjectOptions) error {
+	if size > MaxUploadSize {
+		return fmt.Errorf("%w (%d bytes)", ErrEntityTooLarge, MaxUploadSize)
+	}
+	return s.checkPutObject(ctx, bucket, key, opts)
+}
+
+// resumableUploadExists reports whether the multipart upload backing a token is live
+func (s *ObjectService) resumableUploadExists(ctx context.Context, bucket, key, uploadID string) (bool, error) {
+	upload, err := s.multipartUpload(ctx, bucket, key, uploadID)
+	return upload != nil, err
+}
+
+// fill reads from r until buf is full, returning io.EOF if the body ended
+// first and any other error if the read failed
+func fill(r io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ExpireResumableUploads aborts resumable uploads started more than ttl ago
+// and not completed since, deleting their staged parts. Regular multipart
+// uploads are left alone. It returns the number of uploads aborted.
+func (s *ObjectService) ExpireResumableUploads(ctx context.Context, ttl time.Duration) (int, error) {
+	buckets, err := s.ListBuckets(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := s.clock.Now().Add(-ttl).Unix()
+	expired := 0
+	for _, bucket := range buckets {
+		if err := ctx.Err(); err != nil {
+			return expired, err
+		}
+		uploads, err := s.metadata.ListMultipartUploads(ctx, bucket.Name, "")
+		if err != nil {
+			s.logger.Warnw("failed to list multipart uploads", "bucket", bucket.Name, "error", err)
+			continue
+		}
+		for _, upload := range uploads {
+			if !isResumableUploadID(upload.UploadID) || upload.Initiated > cutoff {
+				continue
+			}
+			if err := s.abortResumableUpload(ctx, upload); err != nil {
+				s.logger.Warnw("failed to abort expired resumable upload",
+					"bucket", upload.Bucket, "key", upload.Key, "uploadId", upload.UploadID, "error", err)
+				continue
+			}
+			expired++
+		}
+	}
+
+	if expired > 0 {
+		s.logger.Infow("aborted expired resumable uploads", "uploads", expired)
+	}
+	return expired, nil
+}
+
+// abortResumableUpload aborts one resumable upload under the lock its writes
+// take, so a client resuming at that moment either finishes first or finds
+// the upload gone and starts again at offset zero
+func (s *ObjectService) abortResumableUpload(ctx context.Context, upload metadata.MultipartUploadMetadata) error {
+	unlock := s.locker.Lock(upload.Bucket, upload.Key+"?uploadId="+upload.UploadID)
+	defer unlock()
+
+	return s.AbortMultipartUpload(ctx, upload.Bucket, upload.Key, upload.UploadID)
+}
@@ -0,0 +1,273 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+// interruptedReader yields the first n bytes of data and then fails, like a
+// request body whose connection dropped mid-transfer
+type interruptedReader struct {
+	data []byte
+	n    int
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	c := copy(p, r.data)
+	r.data = r.data[c:]
+	r.n -= c
+	return c, nil
+}
+
+// zeroReader yields zero bytes without end
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestObjectService_ResumePutObject(t *testing.T) {
+	store := NewMockStorageBackend()
+	meta := NewMockMetadataStore()
+	svc := New(store, meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	length := int64(len(content))
+
+	// First attempt drops after 300 bytes
+	status, err := svc.ResumePutObject(ctx, "bucket", "big.bin", "owner", "token-1", 0, length,
+		&interruptedReader{data: content, n: 300}, PutObjectOptions{})
+	if err == nil {
+		t.Fatal("ResumePutObject() should report the interruption")
+	}
+	if status == nil || status.Offset != 300 {
+		t.Fatalf("status after interruption = %+v, want offset 300", status)
+	}
+	if _, err := svc.HeadObject(ctx, "bucket", "big.bin"); err == nil {
+		t.Fatal("object should not be visible before the upload completes")
+	}
+
+	// Resuming from the wrong offset is rejected and reports the committed offset
+	status, err = svc.ResumePutObject(ctx, "bucket", "big.bin", "owner", "token-1", 0, length,
+		bytes.NewReader(content), PutObjectOptions{})
+	var offsetErr *ResumeOffsetError
+	if !errors.As(err, &offsetErr) || offsetErr.Expected != 300 {
+		t.Fatalf("ResumePutObject() error = %v, want offset mismatch at 300", err)
+	}
+	if status.Offset != 300 {
+		t.Errorf("status.Offset = %d, want 300", status.Offset)
+	}
+
+	// Resume from the committed offset
+	status, err = svc.ResumePutObject(ctx, "bucket", "big.bin", "owner", "token-1", 300, length,
+		bytes.NewReader(content[300:]), PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("ResumePutObject() error = %v", err)
+	}
+	if !status.Completed || status.Offset != length {
+		t.Fatalf("status = %+v, want completed at %d", status, length)
+	}
+
+	obj, err := svc.GetObject(ctx, "bucket", "big.bin", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	got, _ := io.ReadAll(obj.Body)
+	if !bytes.Equal(got, content) {
+		t.Errorf("object content length = %d, want %d and identical bytes", len(got), len(content))
+	}
+}
+
+func TestObjectService_ResumePutObject_FreshTokenMustStartAtZero(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+
+	_, err := svc.ResumePutObject(ctx, "bucket", "key", "owner", "unknown", 10, 20, bytes.NewReader(nil), PutObjectOptions{})
+	var offsetErr *ResumeOffsetError
+	if !errors.As(err, &offsetErr) || offsetErr.Expected != 0 {
+		t.Errorf("ResumePutObject() error = %v, want offset mismatch at 0", err)
+	}
+}
+
+func TestResumableUploadID_Deterministic(t *testing.T) {
+	a := resumableUploadID("owner", "bucket", "key", "token")
+	if a != resumableUploadID("owner", "bucket", "key", "token") {
+		t.Error("resumableUploadID() should be stable for the same token")
+	}
+	if a == resumableUploadID("owner", "bucket", "other", "token") {
+		t.Error("resumableUploadID() should differ per key")
+	}
+	if a == resumableUploadID("other-owner", "bucket", "key", "token") {
+		t.Error("resumableUploadID() should differ per access key")
+	}
+	if !isResumableUploadID(a) {
+		t.Error("isResumableUploadID() should recognize a derived ID")
+	}
+}
+
+func TestObjectService_ResumePutObject_StagesInChunks(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+
+	// The body ends before the declared length: everything sent is staged in
+	// bounded parts and the upload waits to be resumed
+	length := int64(3*resumableChunkSize + 10)
+	sent := int64(2*resumableChunkSize + 5)
+	status, err := svc.ResumePutObject(ctx, "bucket", "key", "owner", "token", 0, length,
+		io.LimitReader(zeroReader{}, sent), PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("ResumePutObject() error = %v", err)
+	}
+	if status.Completed || status.Offset != sent {
+		t.Fatalf("status = %+v, want incomplete at %d", status, sent)
+	}
+
+	parts, _ := meta.ListParts(ctx, "bucket", "key", status.UploadID)
+	if len(parts) != 3 {
+		t.Fatalf("staged %d parts, want 3", len(parts))
+	}
+	for _, p := range parts {
+		if p.Size > resumableChunkSize {
+			t.Errorf("part %d is %d bytes, more than the %d byte chunk", p.PartNumber, p.Size, resumableChunkSize)
+		}
+	}
+}
+
+func TestObjectService_ResumePutObject_OtherOwner(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+
+	if _, err := svc.ResumePutObject(ctx, "bucket", "key", "alice", "token", 0, 20,
+		bytes.NewReader(make([]byte, 10)), PutObjectOptions{}); err != nil {
+		t.Fatalf("ResumePutObject() error = %v", err)
+	}
+
+	// The same token under another access key is a separate, fresh upload
+	_, err := svc.ResumePutObject(ctx, "bucket", "key", "mallory", "token", 10, 20,
+		bytes.NewReader(make([]byte, 10)), PutObjectOptions{})
+	var offsetErr *ResumeOffsetError
+	if !errors.As(err, &offsetErr) || offsetErr.Expected != 0 {
+		t.Errorf("ResumePutObject() by another owner error = %v, want offset mismatch at 0", err)
+	}
+}
+
+func TestObjectService_ResumePutObject_Validation(t *testing.T) {
+	ctx := context.Background()
+	meta := &objectLockMetadataStore{retentionMetadataStore: retentionMetadataStore{MockMetadataStore: NewMockMetadataStore()}}
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	meta.CreateBucket(ctx, "bucket")
+	resume := func(token string, offset, length int64, data []byte, opts PutObjectOptions) (*ResumableUploadStatus, error) {
+		return svc.ResumePutObject(ctx, "bucket", "key", "owner", token, offset, length, bytes.NewReader(data), opts)
+	}
+
+	if _, err := resume("empty", 0, 0, nil, PutObjectOptions{}); !errors.Is(err, ErrInvalidUploadLength) {
+		t.Errorf("ResumePutObject() of zero bytes error = %v, expected ErrInvalidUploadLength", err)
+	}
+	if _, err := resume("huge", 0, MaxUploadSize+1, nil, PutObjectOptions{}); !errors.Is(err, ErrEntityTooLarge) {
+		t.Errorf("ResumePutObject() over the size cap error = %v, expected ErrEntityTooLarge", err)
+	}
+	if uploads, _ := meta.ListMultipartUploads(ctx, "bucket", ""); len(uploads) != 0 {
+		t.Errorf("refused ResumePutObject() staged %d uploads", len(uploads))
+	}
+
+	// A resume is checked again, so one arriving after the bucket is gone
+	// stages nothing
+	status, err := resume("gone", 0, 8, []byte("half"), PutObjectOptions{})
+	if err != nil || status.Offset != 4 {
+		t.Fatalf("ResumePutObject() = %+v, %v, want offset 4", status, err)
+	}
+	meta.DeleteBucket(ctx, "bucket")
+	if _, err := resume("gone", 4, 8, []byte("rest"), PutObjectOptions{}); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("ResumePutObject() into a deleted bucket error = %v, expected ErrBucketNotFound", err)
+	}
+	if parts, _ := meta.ListParts(ctx, "bucket", "key", status.UploadID); len(parts) != 1 {
+		t.Errorf("ResumePutObject() into a deleted bucket left %d parts, want 1", len(parts))
+	}
+	meta.CreateBucket(ctx, "bucket")
+
+	// Likewise once the metadata store stops accepting writes
+	status, err = resume("degraded", 0, 8, []byte("half"), PutObjectOptions{})
+	if err != nil || status.Offset != 4 {
+		t.Fatalf("ResumePutObject() = %+v, %v, want offset 4", status, err)
+	}
+	svc.writeHealth.cause = errors.New("read-only")
+	svc.writeHealth.lastProbe = time.Now()
+	if _, err := resume("degraded", 4, 8, []byte("rest"), PutObjectOptions{}); !errors.Is(err, ErrMetadataUnavailable) {
+		t.Errorf("ResumePutObject() while degraded error = %v, expected ErrMetadataUnavailable", err)
+	}
+	if parts, _ := meta.ListParts(ctx, "bucket", "key", status.UploadID); len(parts) != 1 {
+		t.Errorf("ResumePutObject() while degraded left %d parts, want 1", len(parts))
+	}
+	svc.writeHealth.cause = nil
+
+	// Object lock headers are checked up front and applied on completion
+	retention := &metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: time.Now().Add(time.Hour).Unix()}
+	if _, err := resume("locked", 0, 4, []byte("data"), PutObjectOptions{Retention: retention}); !errors.Is(err, ErrObjectLockDisabled) {
+		t.Errorf("ResumePutObject() with retention and no object lock error = %v, expected ErrObjectLockDisabled", err)
+	}
+	meta.lock = &metadata.ObjectLockConfig{Enabled: true}
+	status, err = resume("retained", 0, 4, []byte("data"), PutObjectOptions{Retention: retention})
+	if err != nil || !status.Completed {
+		t.Fatalf("ResumePutObject() = %+v, %v, want completed", status, err)
+	}
+	if got, _ := svc.GetObjectRetention(ctx, "bucket", "key", ""); got == nil || got.Mode != RetentionGovernance {
+		t.Errorf("retention after ResumePutObject() = %+v, want %s", got, RetentionGovernance)
+	}
+}
+
+func TestObjectService_ExpireResumableUploads(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	status, err := svc.ResumePutObject(ctx, "bucket", "resumable", "owner", "token", 0, 20,
+		bytes.NewReader(make([]byte, 10)), PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("ResumePutObject() error = %v", err)
+	}
+	regular, err := svc.CreateMultipartUpload(ctx, "bucket", "regular", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	if n, err := svc.ExpireResumableUploads(ctx, time.Hour); err != nil || n != 0 {
+		t.Fatalf("ExpireResumableUploads(1h) = %d, %v, want nothing expired yet", n, err)
+	}
+
+	// With no TTL left every resumable upload has expired, but regular
+	// multipart uploads are never touched
+	if n, err := svc.ExpireResumableUploads(ctx, -time.Second); err != nil || n != 1 {
+		t.Fatalf("ExpireResumableUploads() = %d, %v, want 1", n, err)
+	}
+	uploads, _ := meta.ListMultipartUploads(ctx, "bucket", "")
+	if len(uploads) != 1 || uploads[0].UploadID != regular.UploadID {
+		t.Errorf("uploads after expiry = %+v, want only the regular one", uploads)
+	}
+	if parts, _ := meta.ListParts(ctx, "bucket", "resumable", status.UploadID); len(parts) != 0 {
+		t.Errorf("expired upload still has %d parts", len(parts))
+	}
+}
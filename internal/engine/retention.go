@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// Object lock retention modes
+const (
+	RetentionGovernance = "GOVERNANCE"
+	RetentionCompliance = "COMPLIANCE"
+)
+
+// RetentionOptions contains options for PutObjectRetention
+type RetentionOptions struct {
+	// BypassGovernance allows a GOVERNANCE retention to be shortened or
+	// changed to another mode
+	BypassGovernance bool
+}
+
+// SetMaxRetention limits how far ahead PutObjectRetention may set a
+// retention date. Zero, the default, allows any future date.
+func (s *ObjectService) SetMaxRetention(max time.Duration) {
+	s.maxRetention = max
+}
+
+// PutObjectRetention sets object retention. The mode must be GOVERNANCE or
+// COMPLIANCE and the date must be in the future and within the configured
+// maximum, or the request fails with ErrInvalidRetention. A COMPLIANCE
+// retention can only be extended; a GOVERNANCE one can be shortened or
+// change mode only with BypassGovernance. Either is refused with
+// ErrRetentionLocked. A versionID sets the retention of that version only.
+func (s *ObjectService) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention, opts RetentionOptions) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.validateRetention(retention); err != nil {
+		return err
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.objectRetention(ctx, bucket, key, version)
+	if err != nil {
+		return fmt.Errorf("failed to get object retention: %w", err)
+	}
+	if err := checkRetentionChange(current, retention, opts, s.clock.Now()); err != nil {
+		return fmt.Errorf("%w: %s/%s", err, bucket, key)
+	}
+
+	return s.metadata.PutObjectRetention(ctx, bucket, key, version, retention)
+}
+
+// GetObjectRetention gets the retention of an object or of one of its
+// versions, or nil if none is set
+func (s *ObjectService) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.objectRetention(ctx, bucket, key, version)
+}
+
+// objectRetention returns the retention of a version, or the object's own
+// when the version has none. The key must already be normalized.
+func (s *ObjectService) objectRetention(ctx context.Context, bucket, key, version string) (*metadata.ObjectRetention, error) {
+	retention, err := s.metadata.GetObjectRetention(ctx, bucket, key, version)
+	if err != nil || retention != nil || version == "" {
+		return retention, err
+	}
+	return s.metadata.GetObjectRetention(ctx, bucket, key, "")
+}
+
+// validateRetention checks a retention on its own, before it is compared with
+// the one it replaces
+func (s *ObjectService) validateRetention(retention *metadata.ObjectRetention) error {
+	if retention == nil {
+		return fmt.Errorf("%w: retention is required", ErrInvalidRetention)
+	}
+	if retention.Mode != RetentionGovernance && retention.Mode != RetentionCompliance {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidRetention, retention.Mode)
+	}
+
+	now := s.clock.Now()
+	until := time.Unix(retention.RetainUntilDate, 0)
+	if !until.After(now) {
+		return fmt.Errorf("%w: retain-until date %s is not in the future", ErrInvalidRetention, until.UTC().Format(time.RFC3339))
+	}
+	if s.maxRetention > 0 && until.After(now.Add(s.maxRetention)) {
+		return fmt.Errorf("%w: retain-until date %s is more than %s ahead", ErrInvalidRetention, until.UTC().Format(time.RFC3339), s.maxRetention)
+	}
+	return nil
+}
+
+// checkRetentionChange refuses to weaken a retention that is still in force
+// at now
+func checkRetentionChange(current, next *metadata.ObjectRetention, opts RetentionOptions, now time.Time) error {
+	if current == nil || current.RetainUntilDate <= now.Unix() {
+		return nil
+	}
+	weakened := next.RetainUntilDate < current.RetainUntilDate || next.Mode != current.Mode
+
+	switch current.Mode {
+	case RetentionCompliance:
+		if weakened {
+			return fmt.Errorf("%w: COMPLIANCE retention can only be extended", ErrRetentionLocked)
+		}
+	case RetentionGovernance:
+		// Moving to COMPLIANCE only strengthens the lock
+		if next.Mode == RetentionCompliance && next.RetainUntilDate >= current.RetainUntilDate {
+			return nil
+		}
+		if weakened && !opts.BypassGovernance {
+			return fmt.Errorf("%w: changing GOVERNANCE retention requires bypass", ErrRetentionLocked)
+		}
+	}
+	return nil
+}
+
+// Legal hold statuses
+const (
+	LegalHoldOn  = "ON"
+	LegalHoldOff = "OFF"
+)
+
+// PutObjectLegalHold sets the legal hold of an object, or of one of its
+// versions when versionID is set
+func (s *ObjectService) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return err
+	}
+	return s.metadata.PutObjectLegalHold(ctx, bucket, key, version, legalHold)
+}
+
+// GetObjectLegalHold gets the legal hold of an existing object or of one of
+// its versions. As in S3, one that was never set is reported as OFF.
+func (s *ObjectService) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.requireObject(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := s.objectLegalHold(ctx, bucket, key, version)
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil {
+		return &metadata.ObjectLegalHold{Status: LegalHoldOff}, nil
+	}
+	return hold, nil
+}
+
+// objectLegalHold returns the legal hold of a version, or the object's own
+// when the version has none. The key must already be normalized.
+func (s *ObjectService) objectLegalHold(ctx context.Context, bucket, key, version string) (*metadata.ObjectLegalHold, error) {
+	hold, err := s.metadata.GetObjectLegalHold(ctx, bucket, key, version)
+	if err != nil || hold != nil || version == "" {
+		return hold, err
+	}
+	return s.metadata.GetObjectLegalHold(ctx, bucket, key, "")
+}
+
+// checkObjectLockOptions validates the retention and legal hold requested
+// with a PutObject. Either needs object lock enabled on the bucket, and a
+// retention may not weaken one already in force on the key.
+func (s *ObjectService) checkObjectLockOptions(ctx context.Context, bucket, key string, opts PutObjectOptions) error {
+	if opts.Retention == nil && opts.LegalHold == nil {
+		return nil
+	}
+	config, err := s.metadata.GetObjectLock(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get object lock configuration: %w", err)
+	}
+	if config == nil || !config.Enabled {
+		return fmt.Errorf("%w: %s", ErrObjectLockDisabled, bucket)
+	}
+
+	if opts.Retention != nil {
+		if err := s.validateRetention(opts.Retention); err != nil {
+			return err
+		}
+		current, err := s.metadata.GetObjectRetention(ctx, bucket, key, "")
+		if err != nil {
+			return fmt.Errorf("failed to get object retention: %w", err)
+		}
+		if err := checkRetentionChange(current, opts.Retention, RetentionOptions{}, s.clock.Now()); err != nil {
+			return fmt.Errorf("%w: %s/%s", err, bucket, key)
+		}
+	}
+	if opts.LegalHold != nil && opts.LegalHold.Status != LegalHoldOn && opts.LegalHold.Status != LegalHoldOff {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidLegalHold, opts.LegalHold.Status)
+	}
+	return nil
+}
+
+// applyObjectLockOptions stores the retention and legal hold requested with a
+// PutObject, once the object is written
+func (s *ObjectService) applyObjectLockOptions(ctx context.Context, bucket, key string, opts PutObjectOptions) error {
+	if opts.Retention != nil {
+		if err := s.checkMetadataWrite(s.metadata.PutObjectRetention(ctx, bucket, key, "", opts.Retention)); err != nil {
+			return fmt.Errorf("failed to save object retention: %w", err)
+		}
+	}
+	if opts.LegalHold != nil {
+		if err := s.checkMetadataWrite(s.metadata.PutObjectLegalHold(ctx, bucket, key, "", opts.LegalHold)); err != nil {
+			return fmt.Errorf("failed to save object legal hold: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkDeleteLock refuses to delete an object, or in a versioned bucket the
+// version versionID, under legal hold or under a retention still in force. A
+// version's own entries take the place of the object's. A GOVERNANCE
+// retention can be bypassed.
+func (s *ObjectService) checkDeleteLock(ctx context.Context, bucket, key, versionID string, bypassGovernance bool) error {
+	version := ""
+	if s.versioningStatus(ctx, bucket) != "" {
+		version = versionID
+	}
+	hold, err := s.objectLegalHold(ctx, bucket, key, version)
+	if err != nil {
+		return fmt.Errorf("failed to get object legal hold: %w", err)
+	}
+	if hold != nil && hold.Status == LegalHoldOn {
+		return fmt.Errorf("%w: %s/%s", ErrLegalHold, bucket, key)
+	}
+
+	retention, err := s.objectRetention(ctx, bucket, key, version)
+	if err != nil {
+		return fmt.Errorf("failed to get object retention: %w", err)
+	}
+	if retention == nil || retention.RetainUntilDate <= s.clock.Now().Unix() {
+		return nil
+	}
+	if retention.Mode == RetentionGovernance && bypassGovernance {
+		return nil
+	}
+	return fmt.Errorf("%w: %s/%s until %s", ErrRetentionLocked, bucket, key,
+		time.Unix(retention.RetainUntilDate, 0).UTC().Format(time.RFC3339))
+}
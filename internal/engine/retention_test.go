@@ -0,0 +1,246 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/clock"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"github.com/openendpoint/openendpoint/internal/tags"
+	"go.uber.org/zap"
+)
+
+// retentionMetadataStore keeps the retention most recently stored
+type retentionMetadataStore struct {
+	*MockMetadataStore
+	retention *metadata.ObjectRetention
+}
+
+func (m *retentionMetadataStore) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error {
+	m.retention = retention
+	return nil
+}
+
+func (m *retentionMetadataStore) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
+	return m.retention, nil
+}
+
+// objectLockMetadataStore keeps a bucket's object lock configuration and the
+// retention and legal hold most recently stored
+type objectLockMetadataStore struct {
+	retentionMetadataStore
+	lock      *metadata.ObjectLockConfig
+	legalHold *metadata.ObjectLegalHold
+}
+
+func (m *objectLockMetadataStore) GetObjectLock(ctx context.Context, bucket string) (*metadata.ObjectLockConfig, error) {
+	return m.lock, nil
+}
+
+func (m *objectLockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
+	m.legalHold = legalHold
+	return nil
+}
+
+func (m *objectLockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
+	return m.legalHold, nil
+}
+
+func TestObjectService_PutObjectRetentionValidation(t *testing.T) {
+	svc := New(NewMockStorageBackend(), &retentionMetadataStore{MockMetadataStore: NewMockMetadataStore()}, zap.NewNop().Sugar())
+	svc.SetMaxRetention(30 * 24 * time.Hour)
+	ctx := context.Background()
+	day := time.Now().Add(24 * time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		retention *metadata.ObjectRetention
+	}{
+		{"missing", nil},
+		{"unknown mode", &metadata.ObjectRetention{Mode: "LEGAL", RetainUntilDate: day}},
+		{"past date", &metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: time.Now().Add(-time.Hour).Unix()}},
+		{"beyond maximum", &metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: time.Now().Add(31 * 24 * time.Hour).Unix()}},
+	}
+	for _, tt := range tests {
+		if err := svc.PutObjectRetention(ctx, "bucket", "key", "", tt.retention, RetentionOptions{}); !errors.Is(err, ErrInvalidRetention) {
+			t.Errorf("%s: PutObjectRetention() error = %v, expected ErrInvalidRetention", tt.name, err)
+		}
+	}
+
+	if err := svc.PutObjectRetention(ctx, "bucket", "key", "", &metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: day}, RetentionOptions{}); err != nil {
+		t.Errorf("PutObjectRetention() within maximum error = %v", err)
+	}
+}
+
+func TestObjectService_PutObjectRetentionLocked(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	short := now.Add(time.Hour).Unix()
+	long := now.Add(48 * time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		current metadata.ObjectRetention
+		next    metadata.ObjectRetention
+		bypass  bool
+		locked  bool
+	}{
+		{"compliance extended", metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: short}, metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: long}, false, false},
+		{"compliance shortened", metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: long}, metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: short}, true, true},
+		{"compliance to governance", metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: short}, metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: long}, true, true},
+		{"governance shortened", metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: long}, metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: short}, false, true},
+		{"governance shortened with bypass", metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: long}, metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: short}, true, false},
+		{"governance to compliance", metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: short}, metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: long}, false, false},
+		{"expired compliance", metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: now.Add(-time.Hour).Unix()}, metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: short}, false, false},
+	}
+	for _, tt := range tests {
+		store := &retentionMetadataStore{MockMetadataStore: NewMockMetadataStore(), retention: &tt.current}
+		svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+
+		err := svc.PutObjectRetention(ctx, "bucket", "key", "", &tt.next, RetentionOptions{BypassGovernance: tt.bypass})
+		if tt.locked && !errors.Is(err, ErrRetentionLocked) {
+			t.Errorf("%s: PutObjectRetention() error = %v, expected ErrRetentionLocked", tt.name, err)
+		}
+		if !tt.locked && err != nil {
+			t.Errorf("%s: PutObjectRetention() error = %v", tt.name, err)
+		}
+		if tt.locked && *store.retention != tt.current {
+			t.Errorf("%s: retention changed to %+v after a refused update", tt.name, *store.retention)
+		}
+	}
+}
+
+func TestObjectService_PutObjectWithLock(t *testing.T) {
+	ctx := context.Background()
+	store := &objectLockMetadataStore{retentionMetadataStore: retentionMetadataStore{MockMetadataStore: NewMockMetadataStore()}}
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	svc.CreateBucket(ctx, "bucket")
+	put := func(opts PutObjectOptions) error {
+		_, err := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("data")), opts)
+		return err
+	}
+	retention := &metadata.ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: time.Now().Add(time.Hour).Unix()}
+
+	if err := put(PutObjectOptions{Retention: retention}); !errors.Is(err, ErrObjectLockDisabled) {
+		t.Fatalf("PutObject() with retention and no object lock error = %v, expected ErrObjectLockDisabled", err)
+	}
+	if _, err := svc.HeadObject(ctx, "bucket", "key"); err == nil {
+		t.Error("PutObject() refused for its retention still stored the object")
+	}
+
+	store.lock = &metadata.ObjectLockConfig{Enabled: true}
+	if err := put(PutObjectOptions{Retention: &metadata.ObjectRetention{Mode: "LEGAL", RetainUntilDate: retention.RetainUntilDate}}); !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("PutObject() with unknown retention mode error = %v, expected ErrInvalidRetention", err)
+	}
+	if err := put(PutObjectOptions{LegalHold: &metadata.ObjectLegalHold{Status: "MAYBE"}}); !errors.Is(err, ErrInvalidLegalHold) {
+		t.Errorf("PutObject() with unknown legal hold status error = %v, expected ErrInvalidLegalHold", err)
+	}
+
+	if err := put(PutObjectOptions{Retention: retention}); err != nil {
+		t.Fatalf("PutObject() with retention error = %v", err)
+	}
+	if store.retention == nil || *store.retention != *retention {
+		t.Fatalf("stored retention = %+v, expected %+v", store.retention, retention)
+	}
+
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{}); !errors.Is(err, ErrRetentionLocked) {
+		t.Errorf("DeleteObject() under GOVERNANCE retention error = %v, expected ErrRetentionLocked", err)
+	}
+	if _, err := svc.HeadObject(ctx, "bucket", "key"); err != nil {
+		t.Errorf("HeadObject() after refused delete error = %v", err)
+	}
+
+	store.legalHold = &metadata.ObjectLegalHold{Status: LegalHoldOn}
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{BypassGovernance: true}); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("DeleteObject() under legal hold error = %v, expected ErrLegalHold", err)
+	}
+
+	store.legalHold = &metadata.ObjectLegalHold{Status: LegalHoldOff}
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{BypassGovernance: true}); err != nil {
+		t.Errorf("DeleteObject() bypassing GOVERNANCE retention error = %v", err)
+	}
+}
+
+func TestObjectService_RetentionExpiresWithClock(t *testing.T) {
+	ctx := context.Background()
+	store := &objectLockMetadataStore{
+		retentionMetadataStore: retentionMetadataStore{MockMetadataStore: NewMockMetadataStore()},
+		lock:                   &metadata.ObjectLockConfig{Enabled: true},
+	}
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	svc.SetClock(fake)
+	svc.CreateBucket(ctx, "bucket")
+
+	retainUntil := fake.Now().Add(24 * time.Hour)
+	retention := &metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: retainUntil.Unix()}
+	if _, err := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("data")), PutObjectOptions{Retention: retention}); err != nil {
+		t.Fatalf("PutObject() with retention error = %v", err)
+	}
+
+	fake.Advance(24*time.Hour - time.Second)
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{}); !errors.Is(err, ErrRetentionLocked) {
+		t.Fatalf("DeleteObject() a second before retention ends error = %v, expected ErrRetentionLocked", err)
+	}
+
+	// A retain-until date that has already passed is rejected
+	past := &metadata.ObjectRetention{Mode: RetentionCompliance, RetainUntilDate: fake.Now().Add(-time.Second).Unix()}
+	if err := svc.PutObjectRetention(ctx, "bucket", "other", "", past, RetentionOptions{}); !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("PutObjectRetention() in the past error = %v, expected ErrInvalidRetention", err)
+	}
+
+	fake.Advance(time.Second)
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{}); err != nil {
+		t.Errorf("DeleteObject() once retention ends error = %v", err)
+	}
+}
+
+func TestObjectService_VersionLegalHoldAndTags(t *testing.T) {
+	store, err := pebble.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutBucketVersioning(ctx, "bucket", &metadata.BucketVersioning{Status: "Enabled"})
+	first, _ := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("first")), PutObjectOptions{})
+	second, _ := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("second")), PutObjectOptions{})
+
+	if err := svc.PutObjectLegalHold(ctx, "bucket", "key", first.VersionID, &metadata.ObjectLegalHold{Status: LegalHoldOn}); err != nil {
+		t.Fatalf("PutObjectLegalHold(first) error = %v", err)
+	}
+	if err := svc.PutObjectTags(ctx, "bucket", "key", first.VersionID, tags.TagSet{{Key: "env", Value: "prod"}}); err != nil {
+		t.Fatalf("PutObjectTags(first) error = %v", err)
+	}
+	if err := svc.PutObjectLegalHold(ctx, "bucket", "key", "missing", &metadata.ObjectLegalHold{Status: LegalHoldOn}); !errors.Is(err, ErrNoSuchVersion) {
+		t.Errorf("PutObjectLegalHold(unknown version) error = %v, expected ErrNoSuchVersion", err)
+	}
+
+	for _, check := range []struct{ versionID, want string }{{first.VersionID, LegalHoldOn}, {second.VersionID, LegalHoldOff}, {"", LegalHoldOff}} {
+		hold, err := svc.GetObjectLegalHold(ctx, "bucket", "key", check.versionID)
+		if err != nil || hold.Status != check.want {
+			t.Errorf("GetObjectLegalHold(%q) = %+v, %v, expected %s", check.versionID, hold, err, check.want)
+		}
+	}
+	if got, _ := svc.GetObjectTags(ctx, "bucket", "key", first.VersionID); got["env"] != "prod" {
+		t.Errorf("GetObjectTags(first) = %v, expected env=prod", got)
+	}
+	if got, _ := svc.GetObjectTags(ctx, "bucket", "key", second.VersionID); len(got) != 0 {
+		t.Errorf("GetObjectTags(second) = %v, expected none", got)
+	}
+
+	// Only the held version is protected from deletion
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{VersionID: first.VersionID}); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("DeleteObject(first) error = %v, expected ErrLegalHold", err)
+	}
+	if _, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{VersionID: second.VersionID}); err != nil {
+		t.Errorf("DeleteObject(second) error = %v", err)
+	}
+}
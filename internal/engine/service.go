@@ -3,17 +3,29 @@ package engine
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/clock"
+	"github.com/openendpoint/openendpoint/internal/events"
 	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/s3select"
 	"github.com/openendpoint/openendpoint/internal/storage"
 	"github.com/openendpoint/openendpoint/internal/telemetry"
 	"go.uber.org/zap"
@@ -22,12 +34,58 @@ import (
 // MaxUploadSize is the maximum size for object uploads (5GB by default, matching S3)
 const MaxUploadSize = 5 * 1024 * 1024 * 1024
 
+// MinPartSize is the smallest every part of a multipart upload but the last
+// may be (5MB, matching S3)
+const MinPartSize = 5 * 1024 * 1024
+
+// MaxPartNumber is the highest part number, and so the most parts, a
+// multipart upload may have (10,000, matching S3)
+const MaxPartNumber = 10000
+
+// MaxListParts is the most parts ListPartsWithOptions returns in one page
+// (1,000, matching S3)
+const MaxListParts = 1000
+
+// MaxUserMetadataSize is the most user metadata, names and values together,
+// an object may carry (2KB, matching S3)
+const MaxUserMetadataSize = 2 * 1024
+
+// checkUserMetadata rejects user metadata larger than MaxUserMetadataSize
+func checkUserMetadata(meta map[string]string) error {
+	size := 0
+	for name, value := range meta {
+		size += len(name) + len(value)
+	}
+	if size > MaxUserMetadataSize {
+		return fmt.Errorf("%w (%d bytes, limit %d)", ErrMetadataTooLarge, size, MaxUserMetadataSize)
+	}
+	return nil
+}
+
 // ObjectService provides the core object storage operations
 type ObjectService struct {
 	storage   storage.StorageBackend
 	metadata  metadata.Store
 	logger    *zap.SugaredLogger
 	locker    *Locker
+	usage     *usageTracker
+	eventBus  *events.Bus
+
+	signer          *auth.Auth
+	presignEndpoint string // base URL presigned URLs point at
+
+	minPartSize  int64         // smallest allowed multipart part but the last
+	maxRetention time.Duration // furthest ahead retention may be set; 0 for no limit
+
+	clock clock.Clock // time source for timestamps, retention and expiry
+
+	masterKey []byte // wraps the data keys of encrypted objects; nil disables SSE
+
+	webhookPolicy *events.WebhookPolicy // targets webhooks may be configured with
+
+	restores restoreSchedule
+
+	writeHealth writeHealth
 }
 
 // New creates a new ObjectService
@@ -37,9 +95,25 @@ func New(storage storage.StorageBackend, metadata metadata.Store, logger *zap.Su
 		metadata: metadata,
 		logger:   logger,
 		locker:   NewLocker(),
+		usage:    newUsageTracker(metadata, logger),
+
+		minPartSize: MinPartSize,
+		clock:       clock.Real,
 	}
 }
 
+// SetClock replaces the clock the service reads the time from, which is the
+// wall clock by default. Tests use it to control time-dependent behavior.
+func (s *ObjectService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Clock returns the clock the service reads the time from, for workers that
+// act on the service's objects to share it
+func (s *ObjectService) Clock() clock.Clock {
+	return s.clock
+}
+
 // Close closes the ObjectService and releases resources
 func (s *ObjectService) Close() error {
 	if s.locker != nil {
@@ -62,15 +136,46 @@ func (s *ObjectService) ComputeStorageMetrics() (int64, int64, error) {
 	return 0, 0, nil
 }
 
+// checkPutObject makes the checks a write of a whole object must pass
+// before any of its data is stored
+func (s *ObjectService) checkPutObject(ctx context.Context, bucket, key string, opts PutObjectOptions) error {
+	// Check bucket exists
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+	if err := s.checkObjectLockOptions(ctx, bucket, key, opts); err != nil {
+		return err
+	}
+	if err := checkUserMetadata(opts.Metadata); err != nil {
+		return err
+	}
+	if opts.ACL != nil {
+		if err := validateACL(opts.ACL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PutObject stores an object
 func (s *ObjectService) PutObject(ctx context.Context, bucket, key string, data io.Reader, opts PutObjectOptions) (*ObjectResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Lock the object
 	unlock := s.locker.Lock(bucket, key)
 	defer unlock()
 
-	// Check bucket exists
-	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return nil, fmt.Errorf("bucket not found: %s", bucket)
+	if err := s.checkPutObject(ctx, bucket, key, opts); err != nil {
+		return nil, err
+	}
+	algorithm, err := s.encryptionFor(ctx, bucket, opts.ServerSideEncryption)
+	if err != nil {
+		return nil, err
 	}
 
 	// Read all data into memory first (required for hash calculation and storage)
@@ -80,7 +185,11 @@ func (s *ObjectService) PutObject(ctx context.Context, bucket, key string, data
 		return nil, fmt.Errorf("failed to read data: %w", err)
 	}
 	if int64(len(dataBytes)) > MaxUploadSize {
-		return nil, fmt.Errorf("object size exceeds maximum allowed size (%d bytes)", MaxUploadSize)
+		return nil, fmt.Errorf("%w (%d bytes)", ErrEntityTooLarge, MaxUploadSize)
+	}
+	checksum, err := checksumFor(opts.ChecksumAlgorithm, opts.Checksum, dataBytes)
+	if err != nil {
+		return nil, err
 	}
 
 	// Calculate size and hash
@@ -91,6 +200,13 @@ func (s *ObjectService) PutObject(ctx context.Context, bucket, key string, data
 	// Generate ETag
 	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(hasher.Sum(nil)))
 
+	prev := s.currentObject(ctx, bucket, key)
+	versionID := s.newVersionID(ctx, bucket)
+	replaced, err := s.keepNoncurrent(ctx, bucket, key, prev, versionID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create storage options
 	storeOpts := storage.PutOptions{
 		ContentType:     opts.ContentType,
@@ -100,43 +216,66 @@ func (s *ObjectService) PutObject(ctx context.Context, bucket, key string, data
 		StorageClass:    opts.StorageClass,
 	}
 
-	// Store the object
-	if err := s.storage.Put(ctx, bucket, key, bytes.NewReader(dataBytes), size, storeOpts); err != nil {
+	// Store the object, encrypted if the request or the bucket asks for it
+	stored, storedSize, encrypted, err := s.seal(algorithm, bytes.NewReader(dataBytes), size)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(ctx, bucket, key, stored, storedSize, storeOpts); err != nil {
 		return nil, fmt.Errorf("failed to store object: %w", err)
 	}
 
 	// Create metadata
-	now := time.Now().Unix()
+	now := s.clock.Now().Unix()
 	objMeta := &metadata.ObjectMetadata{
-		Key:             key,
-		Bucket:          bucket,
-		Size:            size,
-		ETag:            etag,
-		ContentType:     opts.ContentType,
-		ContentEncoding: opts.ContentEncoding,
-		CacheControl:    opts.CacheControl,
-		Metadata:        opts.Metadata,
-		StorageClass:    opts.StorageClass,
-		VersionID:       uuid.New().String(),
-		IsLatest:        true,
-		LastModified:    now,
-	}
+		Key:                key,
+		Bucket:             bucket,
+		Size:               size,
+		ETag:               etag,
+		ContentType:        opts.ContentType,
+		ContentEncoding:    opts.ContentEncoding,
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		Metadata:           opts.Metadata,
+		StorageClass:       opts.StorageClass,
+		VersionID:          versionID,
+		IsLatest:           true,
+		LastModified:       now,
+		Checksum:           checksum,
+	}
+	encrypted.apply(objMeta)
 
 	// Save metadata
-	if err := s.metadata.PutObject(ctx, bucket, key, objMeta); err != nil {
+	if err := s.checkMetadataWrite(s.metadata.PutObject(ctx, bucket, key, objMeta)); err != nil {
 		s.logger.Error("failed to save metadata", zap.Error(err))
 		return nil, fmt.Errorf("failed to save object metadata: %w", err)
 	}
+	if err := s.applyObjectLockOptions(ctx, bucket, key, opts); err != nil {
+		return nil, err
+	}
+	if err := s.setObjectACL(ctx, bucket, key, opts.ACL); err != nil {
+		return nil, err
+	}
+
+	s.usage.recordWrite(ctx, bucket, replaced, size)
+	s.publish(events.ObjectEvent{
+		Type:        events.EventObjectUploaded,
+		Bucket:      bucket,
+		Key:         key,
+		Size:        size,
+		ETag:        etag,
+		VersionID:   objMeta.VersionID,
+		ContentType: opts.ContentType,
+	})
 
 	// Update telemetry metrics
 	start := time.Now()
 	telemetry.IncStorageBytes(size)
-	telemetry.IncBucketObjects(bucket)
 	telemetry.IncTotalObjects()
 	telemetry.IncOperation("PutObject")
 	telemetry.OperationsTotal.WithLabelValues("PutObject", "success").Inc()
 	telemetry.OperationDuration.WithLabelValues("PutObject", "success").Observe(time.Since(start).Seconds())
-	telemetry.UpdateDashboardMetrics(size, 0)
+	telemetry.AddBytesUploaded(size)
 	telemetry.UpdateLatency("PutObject", time.Since(start).Seconds())
 
 	return &ObjectResult{
@@ -144,100 +283,371 @@ func (s *ObjectService) PutObject(ctx context.Context, bucket, key string, data
 		Size:         size,
 		VersionID:    objMeta.VersionID,
 		LastModified: now,
+
+		ServerSideEncryption: algorithm,
+		Checksum:             checksum,
 	}, nil
 }
 
+// Metadata directives for CopyObject
+const (
+	MetadataDirectiveCopy    = "COPY"
+	MetadataDirectiveReplace = "REPLACE"
+)
+
+// CopyObjectOptions contains options for a copy operation
+type CopyObjectOptions struct {
+	// SourceVersionID copies that version of the source instead of the
+	// current one
+	SourceVersionID string
+
+	// MetadataDirective is MetadataDirectiveCopy, the default, to keep the
+	// source's metadata, or MetadataDirectiveReplace to store the fields
+	// below instead
+	MetadataDirective  string
+	ContentType        string
+	ContentEncoding    string
+	CacheControl       string
+	ContentDisposition string
+	Metadata           map[string]string
+
+	// IfMatch and IfNoneMatch are preconditions on the source ETag; either
+	// failing is ErrPreconditionFailed
+	IfMatch     string
+	IfNoneMatch string
+
+	// ServerSideEncryption is the algorithm to store the copy with; empty
+	// applies the destination bucket's default encryption
+	ServerSideEncryption string
+
+	// ACL is the ACL to store the copy with; nil gives it the default ACL,
+	// as ACLs are not copied
+	ACL *metadata.AccessControlList
+}
+
 // CopyObjectResult contains the result of a copy operation
 type CopyObjectResult struct {
 	ETag         string
 	LastModified int64
 	VersionID    string
+	// SourceVersionID is the version of the source that was copied
+	SourceVersionID string
+	// ServerSideEncryption is the algorithm the copy was stored with
+	ServerSideEncryption string
 }
 
 // CopyObject copies an object to another location
-func (s *ObjectService) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) (*CopyObjectResult, error) {
+func (s *ObjectService) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts CopyObjectOptions) (*CopyObjectResult, error) {
+	srcKey = s.normalizeKey(ctx, srcBucket, srcKey)
+	dstKey = s.normalizeKey(ctx, dstBucket, dstKey)
 	// Lock for write
 	unlock := s.locker.Lock(dstBucket, dstKey)
 	defer unlock()
 
 	// Check source bucket exists
 	if _, err := s.metadata.GetBucket(ctx, srcBucket); err != nil {
-		return nil, fmt.Errorf("source bucket not found: %s", srcBucket)
+		return nil, fmt.Errorf("source %w: %s", ErrBucketNotFound, srcBucket)
 	}
 
 	// Check destination bucket exists
 	if _, err := s.metadata.GetBucket(ctx, dstBucket); err != nil {
-		return nil, fmt.Errorf("destination bucket not found: %s", dstBucket)
+		return nil, fmt.Errorf("destination %w: %s", ErrBucketNotFound, dstBucket)
+	}
+	if err := s.checkBucketMode(ctx, srcBucket, false); err != nil {
+		return nil, err
+	}
+	if err := s.checkBucketMode(ctx, dstBucket, true); err != nil {
+		return nil, err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return nil, err
+	}
+
+	replace := opts.MetadataDirective == MetadataDirectiveReplace
+	if replace {
+		if err := checkUserMetadata(opts.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ACL != nil {
+		if err := validateACL(opts.ACL); err != nil {
+			return nil, err
+		}
+	}
+	algorithm, err := s.encryptionFor(ctx, dstBucket, opts.ServerSideEncryption)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get source object metadata
-	srcMeta, err := s.metadata.GetObject(ctx, srcBucket, srcKey, "")
+	srcMeta, err := s.metadata.GetObject(ctx, srcBucket, srcKey, opts.SourceVersionID)
 	if err != nil {
-		return nil, fmt.Errorf("source object not found: %s/%s", srcBucket, srcKey)
+		if opts.SourceVersionID != "" {
+			return nil, fmt.Errorf("source %w: %s/%s?versionId=%s", ErrNoSuchVersion, srcBucket, srcKey, opts.SourceVersionID)
+		}
+		return nil, fmt.Errorf("source %w: %s/%s", ErrObjectNotFound, srcBucket, srcKey)
+	}
+	if srcMeta.IsDeleteMarker {
+		return nil, fmt.Errorf("source %w: %s/%s?versionId=%s", ErrDeleteMarker, srcBucket, srcKey, srcMeta.VersionID)
+	}
+	if opts.IfMatch != "" && !etagListMatches(opts.IfMatch, srcMeta.ETag) {
+		return nil, fmt.Errorf("%w: x-amz-copy-source-if-match %s", ErrPreconditionFailed, opts.IfMatch)
+	}
+	if opts.IfNoneMatch != "" && etagListMatches(opts.IfNoneMatch, srcMeta.ETag) {
+		return nil, fmt.Errorf("%w: x-amz-copy-source-if-none-match %s", ErrPreconditionFailed, opts.IfNoneMatch)
 	}
 
 	// Get source object data
-	data, err := s.storage.Get(ctx, srcBucket, srcKey, storage.GetOptions{})
+	srcDataKey := srcKey
+	if opts.SourceVersionID != "" {
+		srcDataKey = s.dataKey(ctx, srcBucket, srcKey, srcMeta)
+	}
+	data, err := s.openData(ctx, srcBucket, srcDataKey, srcMeta, storage.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read source object: %w", err)
 	}
 	defer data.Close()
 
+	// An encrypted copy is sealed under a data key of its own
+	body, bodySize, encrypted, err := s.seal(algorithm, data, srcMeta.Size)
+	if err != nil {
+		return nil, err
+	}
+
 	// Copy to destination
 	dstMeta := &metadata.ObjectMetadata{
-		Key:             dstKey,
-		Bucket:          dstBucket,
-		Size:            srcMeta.Size,
-		ETag:            srcMeta.ETag,
-		ContentType:     srcMeta.ContentType,
-		ContentEncoding: srcMeta.ContentEncoding,
-		CacheControl:    srcMeta.CacheControl,
-		Metadata:        srcMeta.Metadata,
-		StorageClass:    srcMeta.StorageClass,
-		VersionID:       uuid.New().String(),
-		IsLatest:        true,
-		LastModified:    time.Now().Unix(),
+		Key:                dstKey,
+		Bucket:             dstBucket,
+		Size:               srcMeta.Size,
+		ETag:               srcMeta.ETag,
+		ContentType:        srcMeta.ContentType,
+		ContentEncoding:    srcMeta.ContentEncoding,
+		CacheControl:       srcMeta.CacheControl,
+		ContentDisposition: srcMeta.ContentDisposition,
+		Metadata:           srcMeta.Metadata,
+		StorageClass:       srcMeta.StorageClass,
+		VersionID:          s.newVersionID(ctx, dstBucket),
+		IsLatest:           true,
+		LastModified:       s.clock.Now().Unix(),
+		Checksum:           srcMeta.Checksum,
+	}
+	if replace {
+		dstMeta.ContentType = opts.ContentType
+		dstMeta.ContentEncoding = opts.ContentEncoding
+		dstMeta.CacheControl = opts.CacheControl
+		dstMeta.ContentDisposition = opts.ContentDisposition
+		dstMeta.Metadata = opts.Metadata
+	}
+	encrypted.apply(dstMeta)
+
+	prev := s.currentObject(ctx, dstBucket, dstKey)
+	replaced, err := s.keepNoncurrent(ctx, dstBucket, dstKey, prev, dstMeta.VersionID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Write data to destination
 	putOpts := storage.PutOptions{
-		ContentType:     srcMeta.ContentType,
-		ContentEncoding: srcMeta.ContentEncoding,
-		CacheControl:    srcMeta.CacheControl,
-		Metadata:        srcMeta.Metadata,
-		StorageClass:    srcMeta.StorageClass,
+		ContentType:     dstMeta.ContentType,
+		ContentEncoding: dstMeta.ContentEncoding,
+		CacheControl:    dstMeta.CacheControl,
+		Metadata:        dstMeta.Metadata,
+		StorageClass:    dstMeta.StorageClass,
 	}
-	if err := s.storage.Put(ctx, dstBucket, dstKey, data, srcMeta.Size, putOpts); err != nil {
+	if err := s.storage.Put(ctx, dstBucket, dstKey, body, bodySize, putOpts); err != nil {
 		return nil, fmt.Errorf("failed to write destination object: %w", err)
 	}
 
 	// Save metadata
-	if err := s.metadata.PutObject(ctx, dstBucket, dstKey, dstMeta); err != nil {
+	if err := s.checkMetadataWrite(s.metadata.PutObject(ctx, dstBucket, dstKey, dstMeta)); err != nil {
 		s.logger.Error("failed to save copy metadata", zap.Error(err))
+		if errors.Is(err, ErrMetadataUnavailable) {
+			return nil, err
+		}
+	} else {
+		if err := s.setObjectACL(ctx, dstBucket, dstKey, opts.ACL); err != nil {
+			return nil, err
+		}
+		s.usage.recordWrite(ctx, dstBucket, replaced, dstMeta.Size)
+		s.publish(events.ObjectEvent{
+			Type:         events.EventObjectCopied,
+			Bucket:       dstBucket,
+			Key:          dstKey,
+			Size:         dstMeta.Size,
+			ETag:         dstMeta.ETag,
+			VersionID:    dstMeta.VersionID,
+			ContentType:  dstMeta.ContentType,
+			SourceBucket: srcBucket,
+			SourceKey:    srcKey,
+		})
 	}
 
 	return &CopyObjectResult{
-		ETag:         dstMeta.ETag,
-		LastModified: dstMeta.LastModified,
-		VersionID:    dstMeta.VersionID,
+		ETag:            dstMeta.ETag,
+		LastModified:    dstMeta.LastModified,
+		VersionID:       dstMeta.VersionID,
+		SourceVersionID: srcMeta.VersionID,
+
+		ServerSideEncryption: algorithm,
 	}, nil
 }
 
+// MoveObject renames an object. The metadata switch from the old key to the
+// new one, tags, ACL, retention and legal hold included, is a single atomic store
+// write. Backends
+// implementing storage.Renamer move the bytes in place; others fall back to
+// copy+delete.
+//
+// A move never replaces an object: it fails with ErrObjectExists when the
+// destination key is taken. Buckets that have had versioning turned on are
+// refused with ErrVersionedMove, since a rename would carry only the current
+// version and S3 keeps the others, noncurrent ones included, at their key.
+func (s *ObjectService) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	srcKey = s.normalizeKey(ctx, srcBucket, srcKey)
+	dstKey = s.normalizeKey(ctx, dstBucket, dstKey)
+	if srcBucket == dstBucket && srcKey == dstKey {
+		return nil
+	}
+
+	unlock := s.locker.LockPair(srcBucket, srcKey, dstBucket, dstKey)
+	defer unlock()
+
+	if _, err := s.metadata.GetBucket(ctx, srcBucket); err != nil {
+		return fmt.Errorf("source %w: %s", ErrBucketNotFound, srcBucket)
+	}
+	if _, err := s.metadata.GetBucket(ctx, dstBucket); err != nil {
+		return fmt.Errorf("destination %w: %s", ErrBucketNotFound, dstBucket)
+	}
+	for _, bucket := range []string{srcBucket, dstBucket} {
+		if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+			return err
+		}
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+
+	for _, bucket := range []string{srcBucket, dstBucket} {
+		if s.versioned(ctx, bucket) {
+			return fmt.Errorf("%w: %s", ErrVersionedMove, bucket)
+		}
+	}
+
+	srcMeta, err := s.metadata.GetObject(ctx, srcBucket, srcKey, "")
+	if err != nil {
+		return fmt.Errorf("source %w: %s/%s", ErrObjectNotFound, srcBucket, srcKey)
+	}
+	// Checked before any bytes move, as a rename would replace the
+	// destination's data
+	if s.currentObject(ctx, dstBucket, dstKey) != nil {
+		return fmt.Errorf("destination %w: %s/%s", ErrObjectExists, dstBucket, dstKey)
+	}
+
+	if renamer, ok := s.storage.(storage.Renamer); ok {
+		if err := renamer.Rename(ctx, srcBucket, srcKey, dstBucket, dstKey); err != nil {
+			return fmt.Errorf("failed to move object: %w", err)
+		}
+		if err := s.checkMetadataWrite(s.metadata.MoveObject(ctx, srcBucket, srcKey, dstBucket, dstKey)); err != nil {
+			// Put the bytes back so the old key stays readable
+			if rerr := renamer.Rename(ctx, dstBucket, dstKey, srcBucket, srcKey); rerr != nil {
+				s.logger.Errorw("failed to roll back object move", "bucket", srcBucket, "key", srcKey, "error", rerr)
+			}
+			return fmt.Errorf("failed to move object metadata: %w", err)
+		}
+	} else {
+		data, err := s.storage.Get(ctx, srcBucket, srcKey, storage.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to read source object: %w", err)
+		}
+		putOpts := storage.PutOptions{
+			ContentType:     srcMeta.ContentType,
+			ContentEncoding: srcMeta.ContentEncoding,
+			CacheControl:    srcMeta.CacheControl,
+			Metadata:        srcMeta.Metadata,
+			StorageClass:    srcMeta.StorageClass,
+		}
+		err = s.storage.Put(ctx, dstBucket, dstKey, data, storedSize(srcMeta), putOpts)
+		data.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write destination object: %w", err)
+		}
+		if err := s.checkMetadataWrite(s.metadata.MoveObject(ctx, srcBucket, srcKey, dstBucket, dstKey)); err != nil {
+			s.storage.Delete(ctx, dstBucket, dstKey)
+			return fmt.Errorf("failed to move object metadata: %w", err)
+		}
+		// The metadata already points at the new key; a leftover source file
+		// is unreachable and only costs space
+		if err := s.storage.Delete(ctx, srcBucket, srcKey); err != nil {
+			s.logger.Warnw("failed to delete moved source object", "bucket", srcBucket, "key", srcKey, "error", err)
+		}
+	}
+
+	s.usage.recordDelete(ctx, srcBucket, srcMeta)
+	s.usage.recordWrite(ctx, dstBucket, nil, srcMeta.Size)
+	s.publish(events.ObjectEvent{
+		Type:         events.EventObjectCopied,
+		Bucket:       dstBucket,
+		Key:          dstKey,
+		Size:         srcMeta.Size,
+		ETag:         srcMeta.ETag,
+		VersionID:    srcMeta.VersionID,
+		ContentType:  srcMeta.ContentType,
+		SourceBucket: srcBucket,
+		SourceKey:    srcKey,
+	})
+	s.publish(events.ObjectEvent{
+		Type:      events.EventObjectRemoved,
+		Bucket:    srcBucket,
+		Key:       srcKey,
+		Size:      srcMeta.Size,
+		ETag:      srcMeta.ETag,
+		VersionID: srcMeta.VersionID,
+	})
+
+	telemetry.IncOperation("MoveObject")
+	telemetry.OperationsTotal.WithLabelValues("MoveObject", "success").Inc()
+
+	return nil
+}
+
 // GetObject retrieves an object
 func (s *ObjectService) GetObject(ctx context.Context, bucket, key string, opts GetObjectOptions) (*GetObjectResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Lock for read
 	unlock := s.locker.RLock(bucket, key)
 	defer unlock()
 
 	// Check bucket exists
 	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return nil, fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
 	}
 
 	// Get metadata
 	meta, err := s.metadata.GetObject(ctx, bucket, key, opts.VersionID)
 	if err != nil {
-		return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		if opts.VersionID != "" {
+			return nil, fmt.Errorf("%w: %s/%s?versionId=%s", ErrNoSuchVersion, bucket, key, opts.VersionID)
+		}
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if meta.IsDeleteMarker {
+		return nil, fmt.Errorf("%w: %s/%s?versionId=%s", ErrDeleteMarker, bucket, key, meta.VersionID)
+	}
+	// An archived object has to be restored before it can be read
+	if !readable(meta, s.clock.Now()) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrInvalidObjectState, bucket, key)
+	}
+
+	if err := checkConditions(meta, opts); err != nil {
+		return nil, err
+	}
+
+	dataKey := key
+	if opts.VersionID != "" {
+		dataKey = s.dataKey(ctx, bucket, key, meta)
 	}
 
 	// Convert storage options
@@ -250,114 +660,241 @@ func (s *ObjectService) GetObject(ctx context.Context, bucket, key string, opts
 	}
 
 	// Get the object - caller is responsible for closing
-	reader, err := s.storage.Get(ctx, bucket, key, storeOpts)
+	reader, err := s.openData(ctx, bucket, dataKey, meta, storeOpts)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+		}
 		return nil, fmt.Errorf("failed to get object: %w", err)
 	}
 
+	// A range covers only part of the data, so it cannot be checked against
+	// the whole-object hash. A backend that checks whole-object reads itself
+	// verifies every object, whatever its ETag.
+	verified := false
+	if opts.Range == nil {
+		if v, ok := s.storage.(storage.ReadVerifier); ok && v.VerifiesReads() {
+			verified = true
+		} else if opts.VerifyIntegrity {
+			if expected, newHash, ok := contentHash(meta.ETag); ok {
+				reader = newVerifyingReader(reader, expected, newHash, s.logger, bucket, key)
+				verified = true
+			}
+		}
+	}
+
 	// Update telemetry metrics
 	start := time.Now()
 	telemetry.IncOperation("GetObject")
 	telemetry.OperationsTotal.WithLabelValues("GetObject", "success").Inc()
 	telemetry.OperationDuration.WithLabelValues("GetObject", "success").Observe(time.Since(start).Seconds())
 	telemetry.UpdateLatency("GetObject", time.Since(start).Seconds())
-	// Note: actual bytes downloaded would be tracked when the reader is read
 
 	return &GetObjectResult{
-		Body:         reader,
-		Size:         meta.Size,
-		ETag:         meta.ETag,
-		ContentType:  meta.ContentType,
-		Metadata:     meta.Metadata,
-		LastModified: meta.LastModified,
-		VersionID:    meta.VersionID,
+		Body:               reader,
+		Size:               meta.Size,
+		ETag:               meta.ETag,
+		ContentType:        meta.ContentType,
+		ContentEncoding:    meta.ContentEncoding,
+		CacheControl:       meta.CacheControl,
+		ContentDisposition: meta.ContentDisposition,
+		Metadata:           meta.Metadata,
+		LastModified:       meta.LastModified,
+		VersionID:          meta.VersionID,
+		StorageClass:       meta.StorageClass,
+		ReplicationStatus:  meta.ReplicationStatus,
+		Verified:           verified,
+
+		ServerSideEncryption: meta.SSEAlgorithm,
+		Checksum:             meta.Checksum,
+		Restore:              restoreStatus(meta, s.clock.Now()),
 	}, nil
 }
 
-// DeleteObject deletes an object
-func (s *ObjectService) DeleteObject(ctx context.Context, bucket, key string, opts DeleteObjectOptions) error {
+// DeleteObject deletes an object. In a bucket with versioning configured a
+// delete without a version ID adds a delete marker instead.
+func (s *ObjectService) DeleteObject(ctx context.Context, bucket, key string, opts DeleteObjectOptions) (*DeleteObjectResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Lock the object
 	unlock := s.locker.Lock(bucket, key)
 	defer unlock()
 
 	// Check bucket exists
 	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return nil, err
 	}
 
-	// Delete from storage
-	if err := s.storage.Delete(ctx, bucket, key); err != nil {
-		return fmt.Errorf("failed to delete object: %w", err)
+	if err := s.requireWritable(ctx); err != nil {
+		return nil, err
 	}
 
-	// Delete metadata
-	if err := s.metadata.DeleteObject(ctx, bucket, key, opts.VersionID); err != nil {
-		s.logger.Warn("failed to delete metadata", zap.Error(err))
+	versioned := s.versioningStatus(ctx, bucket) != ""
+	if versioned && opts.VersionID == "" {
+		versionID, err := s.putDeleteMarker(ctx, bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		return &DeleteObjectResult{DeleteMarker: true, VersionID: versionID}, nil
+	}
+
+	// Only deletes that remove data are refused by a lock; a delete marker
+	// leaves the locked version in place
+	if err := s.checkDeleteLock(ctx, bucket, key, opts.VersionID, opts.BypassGovernance); err != nil {
+		return nil, err
+	}
+
+	// The version being deleted is what leaves the bucket's usage. Deleting a
+	// version that is not stored succeeds without changing anything.
+	result := &DeleteObjectResult{VersionID: opts.VersionID}
+	prev := s.objectVersion(ctx, bucket, key, opts.VersionID)
+	if prev == nil && opts.VersionID != "" {
+		return result, nil
+	}
+	if prev != nil && opts.VersionID != "" {
+		result.DeleteMarker = prev.IsDeleteMarker
+	}
+	dataKey := key
+	var current *metadata.ObjectMetadata
+	if versioned {
+		current = s.currentObject(ctx, bucket, key)
+		dataKey = s.dataKey(ctx, bucket, key, prev)
+	}
+
+	// Delete metadata before the data, so a failed metadata write leaves the
+	// object intact rather than listed without a body
+	if err := s.checkMetadataWrite(s.metadata.DeleteObject(ctx, bucket, key, opts.VersionID)); err != nil {
+		s.logger.Error("failed to delete metadata", zap.Error(err))
+		return nil, fmt.Errorf("failed to delete object metadata: %w", err)
+	}
+	if prev == nil || !prev.IsDeleteMarker {
+		s.usage.recordDelete(ctx, bucket, prev)
+
+		// Delete from storage
+		if err := s.storage.Delete(ctx, bucket, dataKey); err != nil {
+			return nil, fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+	if versioned {
+		s.promoteCurrent(ctx, bucket, key, current)
+	}
+
+	// Tags and the ACL belong to the object, so they go with it. In a
+	// versioned bucket each version has its own tags.
+	if opts.VersionID == "" {
+		if err := s.metadata.DeleteObjectTags(ctx, bucket, key, ""); err != nil {
+			s.logger.Warnw("failed to delete object tags", "bucket", bucket, "key", key, "error", err)
+		}
+		if err := s.metadata.DeleteObjectACL(ctx, bucket, key); err != nil {
+			s.logger.Warnw("failed to delete object ACL", "bucket", bucket, "key", key, "error", err)
+		}
+	} else if versioned {
+		if err := s.metadata.DeleteObjectTags(ctx, bucket, key, opts.VersionID); err != nil {
+			s.logger.Warnw("failed to delete object tags", "bucket", bucket, "key", key, "versionId", opts.VersionID, "error", err)
+		}
+	}
+
+	// Deleting a key that does not exist succeeds, but changes nothing that
+	// consumers or the object gauges need to hear about
+	if prev != nil {
+		s.publish(events.ObjectEvent{
+			Type:      events.EventObjectRemoved,
+			Bucket:    bucket,
+			Key:       key,
+			VersionID: opts.VersionID,
+			Size:      prev.Size,
+			ETag:      prev.ETag,
+		})
+		if !prev.IsDeleteMarker {
+			telemetry.DecStorageBytes(prev.Size)
+			telemetry.DecTotalObjects()
+		}
 	}
 
 	// Update telemetry metrics
-	telemetry.DecBucketObjects(bucket)
-	telemetry.DecTotalObjects()
 	telemetry.IncOperation("DeleteObject")
 	telemetry.OperationsTotal.WithLabelValues("DeleteObject", "success").Inc()
 	telemetry.OperationDuration.WithLabelValues("DeleteObject", "success").Observe(0) // Quick operation
 
-	return nil
+	return result, nil
 }
 
 // HeadObject returns object metadata without reading the body
 func (s *ObjectService) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Check bucket exists
 	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return nil, fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
 	}
 
 	// Get metadata
 	meta, err := s.metadata.GetObject(ctx, bucket, key, "")
 	if err != nil {
-		return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
 	}
 
 	// Also get from storage to ensure it exists
 	storageMeta, err := s.storage.Head(ctx, bucket, key)
 	if err != nil {
-		return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+
+	// The write time is kept in metadata, so HeadObject and GetObject agree;
+	// objects written without one fall back to the storage modification time
+	lastModified := meta.LastModified
+	if lastModified == 0 {
+		lastModified = storageMeta.LastModified
 	}
 
 	// Update telemetry metrics
 	telemetry.OperationsTotal.WithLabelValues("HeadObject", "success").Inc()
 
 	return &ObjectInfo{
-		Key:             key,
-		Size:            meta.Size,
-		ETag:            meta.ETag,
-		ContentType:     meta.ContentType,
-		ContentEncoding: meta.ContentEncoding,
-		CacheControl:    meta.CacheControl,
-		Metadata:        meta.Metadata,
-		StorageClass:    meta.StorageClass,
-		LastModified:    storageMeta.LastModified,
-		VersionID:       meta.VersionID,
+		Key:                key,
+		Size:               meta.Size,
+		ETag:               meta.ETag,
+		ContentType:        meta.ContentType,
+		ContentEncoding:    meta.ContentEncoding,
+		CacheControl:       meta.CacheControl,
+		ContentDisposition: meta.ContentDisposition,
+		Metadata:           meta.Metadata,
+		StorageClass:       meta.StorageClass,
+		ReplicationStatus:  meta.ReplicationStatus,
+		LastModified:       lastModified,
+		VersionID:          meta.VersionID,
+
+		ServerSideEncryption: meta.SSEAlgorithm,
+		Checksum:             meta.Checksum,
+		Restore:              restoreStatus(meta, s.clock.Now()),
 	}, nil
 }
 
 // GetObjectAttributes returns object attributes
 func (s *ObjectService) GetObjectAttributes(ctx context.Context, bucket, key, versionID string) (*ObjectAttributes, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Check bucket exists
 	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return nil, fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
 	}
 
 	// Get object metadata
 	meta, err := s.metadata.GetObject(ctx, bucket, key, versionID)
 	if err != nil {
-		return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
 	}
 
 	// Get storage info
 	storageMeta, err := s.storage.Head(ctx, bucket, key)
 	if err != nil {
-		return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
 	}
 
 	// Get parts if this is a multipart upload
@@ -379,6 +916,8 @@ func (s *ObjectService) GetObjectAttributes(ctx context.Context, bucket, key, ve
 		ContentEncoding:     meta.ContentEncoding,
 		Metadata:            meta.Metadata,
 		Parts:               parts,
+
+		Checksum: meta.Checksum,
 	}, nil
 }
 
@@ -393,6 +932,9 @@ type ObjectAttributes struct {
 	ContentEncoding     string
 	Metadata            map[string]string
 	Parts               []metadata.PartMetadata
+
+	// Checksum is the additional checksum the object was stored with
+	Checksum *metadata.ObjectChecksum
 }
 
 // SelectObjectContentResult contains the result of a select query
@@ -404,68 +946,131 @@ type SelectObjectContentResult struct {
 
 // SelectObjectContent performs a select query on object data
 func (s *ObjectService) SelectObjectContent(ctx context.Context, bucket, key, expression string) (*SelectObjectContentResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Check bucket exists
 	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return nil, fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
 	}
 
 	// Get object
-	_, err := s.metadata.GetObject(ctx, bucket, key, "")
+	meta, err := s.metadata.GetObject(ctx, bucket, key, "")
 	if err != nil {
-		return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		return nil, fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
 	}
 
 	// Get the object data from storage
-	data, err := s.storage.Get(ctx, bucket, key, storage.GetOptions{})
+	data, err := s.openData(ctx, bucket, key, meta, storage.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read object: %w", err)
 	}
 	defer data.Close()
 
-	// Read all data
-	content, err := io.ReadAll(data)
+	// Objects stored as CSV are queried as CSV with a header row, anything
+	// else as JSON lines; the result is in the same format
+	format := s3select.FormatJSON
+	if strings.Contains(strings.ToLower(meta.ContentType), "csv") || strings.HasSuffix(strings.ToLower(key), ".csv") {
+		format = s3select.FormatCSV
+	}
+	req := &s3select.SelectRequest{
+		Bucket:             bucket,
+		Key:                key,
+		Expression:         expression,
+		ExpressionType:     s3select.ExpressionTypeSQL,
+		InputSerialization: s3select.InputSerialization{Format: format},
+		ContentEncoding:    meta.ContentEncoding,
+	}
+	result, err := s3select.NewSelectService(s.logger.Desugar()).Execute(ctx, req, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read object data: %w", err)
+		return nil, err
 	}
 
-	// For now, implement a simple CSV/JSON filter
-	// In production, this would be a full SQL engine
-	result := s.processSelectExpression(string(content), expression)
-
 	return &SelectObjectContentResult{
-		Body:        result,
-		BytesScanned: int64(len(content)),
-		BytesReturned: int64(len(result)),
+		Body:        string(result.Payload),
+		BytesScanned: result.Stats.BytesScanned,
+		BytesReturned: result.Stats.BytesReturned,
 	}, nil
 }
 
-// processSelectExpression processes a simple select expression
-// This is a simplified implementation - production would use a proper SQL engine
-func (s *ObjectService) processSelectExpression(content, expression string) string {
-	// Simple implementation: return content as-is for now
-	// A full implementation would parse SQL-like expressions
-	return content
-}
-
 // ListObjects lists objects in a bucket
 func (s *ObjectService) ListObjects(ctx context.Context, bucket string, opts ListObjectsOptions) (*ListObjectsResult, error) {
+	opts.Prefix = s.normalizeKey(ctx, bucket, opts.Prefix)
+	opts.Marker = s.normalizeKey(ctx, bucket, opts.Marker)
+	if startAfter := s.normalizeKey(ctx, bucket, opts.StartAfter); startAfter > opts.Marker {
+		opts.Marker = startAfter
+	}
 	// Check bucket exists
 	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
-		return nil, fmt.Errorf("bucket not found: %s", bucket)
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
 	}
 
-	// Convert options
+	// List raw keys from storage and group them here: version data stored
+	// alongside the objects is skipped and, with a delimiter, keys under the
+	// same folder roll up into one common prefix. Keys and common prefixes
+	// both count towards MaxKeys, so storage is read a page at a time until
+	// an entry past a full page turns up or the listing ends.
 	storeOpts := storage.ListOptions{
-		Prefix:    opts.Prefix,
-		Delimiter: opts.Delimiter,
-		MaxKeys:   opts.MaxKeys,
-		Marker:    opts.Marker,
+		Prefix: opts.Prefix,
+		Marker: opts.Marker,
+	}
+	if opts.MaxKeys > 0 {
+		storeOpts.MaxKeys = opts.MaxKeys + 1
 	}
 
-	// List from storage
-	result, err := s.storage.List(ctx, bucket, opts.Prefix, storeOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+	var objectInfos []ObjectInfo
+	var commonPrefixes []string
+	var nextMarker string
+	truncated := false
+	// A marker that is itself a common prefix skips the rest of that folder
+	lastPrefix := ""
+	if cp := commonPrefix(opts.Marker, opts.Prefix, opts.Delimiter); cp == opts.Marker {
+		lastPrefix = cp
+	}
+listing:
+	for {
+		result, err := s.storage.List(ctx, bucket, opts.Prefix, storeOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range result.Objects {
+			if isVersionDataKey(bucket, obj.Key) {
+				continue
+			}
+			cp := commonPrefix(obj.Key, opts.Prefix, opts.Delimiter)
+			if cp != "" && cp == lastPrefix {
+				continue
+			}
+			if opts.MaxKeys > 0 && len(objectInfos)+len(commonPrefixes) == opts.MaxKeys {
+				truncated = true
+				break listing
+			}
+			if cp != "" {
+				lastPrefix = cp
+				commonPrefixes = append(commonPrefixes, cp)
+				nextMarker = cp
+				continue
+			}
+			objectInfos = append(objectInfos, ObjectInfo{
+				Key:          obj.Key,
+				Size:         obj.Size,
+				ETag:         obj.ETag,
+				LastModified: obj.LastModified,
+			})
+			nextMarker = obj.Key
+		}
+		if storeOpts.MaxKeys == 0 || len(result.Objects) < storeOpts.MaxKeys {
+			break
+		}
+		last := result.Objects[len(result.Objects)-1].Key
+		if last <= storeOpts.Marker {
+			break
+		}
+		storeOpts.Marker = last
 	}
 
 	// Update telemetry metrics
@@ -474,50 +1079,61 @@ func (s *ObjectService) ListObjects(ctx context.Context, bucket string, opts Lis
 	telemetry.OperationsTotal.WithLabelValues("ListObjects", "success").Inc()
 	telemetry.OperationDuration.WithLabelValues("ListObjects", "success").Observe(time.Since(start).Seconds())
 
-	// Convert to results
-	var objectInfos []ObjectInfo
-	for _, obj := range result.Objects {
-		objectInfos = append(objectInfos, ObjectInfo{
-			Key:          obj.Key,
-			Size:         obj.Size,
-			ETag:         obj.ETag,
-			LastModified: obj.LastModified,
-		})
-	}
-
-	// Get next marker
-	var nextMarker string
-	if len(objectInfos) > 0 {
-		nextMarker = objectInfos[len(objectInfos)-1].Key
-	}
-
 	return &ListObjectsResult{
 		Objects:        objectInfos,
-		CommonPrefixes: result.CommonPrefixes,
+		CommonPrefixes: commonPrefixes,
 		Prefix:         opts.Prefix,
 		Delimiter:      opts.Delimiter,
 		MaxKeys:        opts.MaxKeys,
 		NextMarker:     nextMarker,
-		IsTruncated:    len(objectInfos) == opts.MaxKeys,
+		IsTruncated:    truncated,
 	}, nil
 }
 
-// CreateBucket creates a new bucket
+// commonPrefix returns the common prefix a key rolls up into when listing
+// prefix with delimiter: the key up to and including the first delimiter
+// after prefix. It returns "" when the key is listed as itself.
+func commonPrefix(key, prefix, delimiter string) string {
+	if delimiter == "" || !strings.HasPrefix(key, prefix) {
+		return ""
+	}
+	idx := strings.Index(key[len(prefix):], delimiter)
+	if idx < 0 {
+		return ""
+	}
+	return key[:len(prefix)+idx+len(delimiter)]
+}
+
+// CreateBucket creates a new bucket. Creating one that already exists fails
+// with ErrBucketOwnedByYou when it belongs to Owner, or ErrBucketExists when
+// it belongs to someone else.
 func (s *ObjectService) CreateBucket(ctx context.Context, bucket string) error {
 	// Validate bucket name
 	if err := validateBucketName(bucket); err != nil {
 		return err
 	}
 
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+
+	if existing, err := s.metadata.GetBucket(ctx, bucket); err == nil && existing != nil {
+		if existing.Owner == "" || existing.Owner == Owner.ID {
+			return fmt.Errorf("%w: %s", ErrBucketOwnedByYou, bucket)
+		}
+		return fmt.Errorf("%w: %s", ErrBucketExists, bucket)
+	}
+
 	// Create in storage
 	if err := s.storage.CreateBucket(ctx, bucket); err != nil {
 		return fmt.Errorf("failed to create bucket: %w", err)
 	}
 
 	// Create in metadata
-	if err := s.metadata.CreateBucket(ctx, bucket); err != nil {
+	if err := s.checkMetadataWrite(s.metadata.CreateBucket(ctx, bucket)); err != nil {
 		return fmt.Errorf("failed to create bucket metadata: %w", err)
 	}
+	s.usage.set(ctx, bucket, BucketUsage{})
 
 	// Update telemetry metrics
 	telemetry.SetStorageBuckets(0) // This will be updated by ListBuckets
@@ -527,14 +1143,27 @@ func (s *ObjectService) CreateBucket(ctx context.Context, bucket string) error {
 
 // DeleteBucket deletes a bucket
 func (s *ObjectService) DeleteBucket(ctx context.Context, bucket string) error {
-	// Check if bucket is empty
-	result, err := s.storage.List(ctx, bucket, "", storage.ListOptions{MaxKeys: 1})
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+
+	// Hold the bucket so no object can be written between the check and
+	// the delete
+	unlock := s.locker.LockBucket(bucket)
+	defer unlock()
+
+	// The metadata is checked rather than the storage, so versions and
+	// delete markers keep a bucket from being deleted too
+	empty, err := s.metadata.BucketIsEmpty(ctx, bucket)
 	if err != nil {
-		return fmt.Errorf("failed to list bucket: %w", err)
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !empty {
+		return fmt.Errorf("%w: %s", ErrBucketNotEmpty, bucket)
 	}
 
-	if len(result.Objects) > 0 {
-		return fmt.Errorf("bucket not empty: %s", bucket)
+	if err := s.requireWritable(ctx); err != nil {
+		return err
 	}
 
 	// Delete from storage
@@ -543,9 +1172,14 @@ func (s *ObjectService) DeleteBucket(ctx context.Context, bucket string) error {
 	}
 
 	// Delete from metadata
-	if err := s.metadata.DeleteBucket(ctx, bucket); err != nil {
+	if err := s.checkMetadataWrite(s.metadata.DeleteBucket(ctx, bucket)); err != nil {
 		s.logger.Warn("failed to delete bucket metadata", zap.Error(err))
 	}
+	if err := s.metadata.DeleteBucketACL(ctx, bucket); err != nil {
+		s.logger.Warn("failed to delete bucket ACL", zap.Error(err))
+	}
+
+	s.usage.remove(bucket)
 
 	// Update telemetry metrics
 	telemetry.DeleteBucketMetrics(bucket)
@@ -576,14 +1210,17 @@ func (s *ObjectService) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
 
 // GetBucket retrieves bucket metadata
 func (s *ObjectService) GetBucket(ctx context.Context, bucket string) (*metadata.BucketMetadata, error) {
-	return s.metadata.GetBucket(ctx, bucket)
+	meta, err := s.metadata.GetBucket(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	return meta, nil
 }
 
 // HeadBucket checks if bucket exists (for S3 HEAD bucket operation)
 func (s *ObjectService) HeadBucket(ctx context.Context, bucket string) error {
-	_, err := s.metadata.GetBucket(ctx, bucket)
-	if err != nil {
-		return err
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
 	}
 	return nil
 }
@@ -599,19 +1236,37 @@ func (s *ObjectService) BucketExists(ctx context.Context, bucket string) (bool,
 
 // CreateMultipartUpload initiates a multipart upload
 func (s *ObjectService) CreateMultipartUpload(ctx context.Context, bucket, key string, opts PutObjectOptions) (*CreateMultipartUploadResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return nil, err
+	}
+	if err := checkUserMetadata(opts.Metadata); err != nil {
+		return nil, err
+	}
+	algorithm, err := s.encryptionFor(ctx, bucket, opts.ServerSideEncryption)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := multipartChecksumFor(opts.ChecksumAlgorithm, opts.ChecksumType)
+	if err != nil {
+		return nil, err
+	}
 	// Generate upload ID
 	uploadID := uuid.New().String()
 
 	// Create metadata
 	meta := &metadata.ObjectMetadata{
-		Key:         key,
-		Bucket:      bucket,
-		ContentType: opts.ContentType,
-		Metadata:    opts.Metadata,
+		Key:          key,
+		Bucket:       bucket,
+		ContentType:  opts.ContentType,
+		Metadata:     opts.Metadata,
+		StorageClass: opts.StorageClass,
+		SSEAlgorithm: algorithm,
+		Checksum:     checksum,
 	}
 
 	// Save to metadata
-	if err := s.metadata.CreateMultipartUpload(ctx, bucket, key, uploadID, meta); err != nil {
+	if err := s.checkMetadataWrite(s.metadata.CreateMultipartUpload(ctx, bucket, key, uploadID, meta)); err != nil {
 		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
 	}
 
@@ -632,12 +1287,63 @@ func (s *ObjectService) InitiateMultipartUpload(ctx context.Context, bucket, key
 
 // UploadPart uploads a part
 func (s *ObjectService) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, data io.Reader) (*UploadPartResult, error) {
-	// Calculate size and hash
-	hasher := sha256.New()
-	size, err := io.Copy(hasher, data)
+	return s.UploadPartWithOptions(ctx, bucket, key, uploadID, partNumber, data, UploadPartOptions{})
+}
+
+// UploadPartWithOptions uploads a part, checking it against the checksum the
+// client computed. A part of an upload created with a checksum algorithm has
+// its checksum computed and returned.
+func (s *ObjectService) UploadPartWithOptions(ctx context.Context, bucket, key, uploadID string, partNumber int, data io.Reader, opts UploadPartOptions) (*UploadPartResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return nil, fmt.Errorf("%w: %d is not between 1 and %d", ErrInvalidPartNumber, partNumber, MaxPartNumber)
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return nil, err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := s.multipartUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	checksumAlgorithm := opts.ChecksumAlgorithm
+	if upload != nil && upload.Checksum != nil {
+		if checksumAlgorithm != "" && checksumAlgorithm != upload.Checksum.Algorithm {
+			return nil, fmt.Errorf("%w: part checksum is %s, the upload's is %s", ErrInvalidChecksum, checksumAlgorithm, upload.Checksum.Algorithm)
+		}
+		checksumAlgorithm = upload.Checksum.Algorithm
+	} else if checksumAlgorithm == "" && opts.Checksum != "" {
+		return nil, fmt.Errorf("%w: checksum given without its algorithm", ErrInvalidChecksum)
+	}
+	var checksumHasher hash.Hash
+	if checksumAlgorithm != "" {
+		if checksumHasher, err = newChecksumHash(checksumAlgorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	// Calculate size and MD5; as in S3, the part ETag is the part's MD5 and
+	// feeds the multipart ETag
+	md5Hasher := md5.New()
+	hashers := io.Writer(md5Hasher)
+	if checksumHasher != nil {
+		hashers = io.MultiWriter(md5Hasher, checksumHasher)
+	}
+	size, err := io.Copy(hashers, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read data: %w", err)
 	}
+	var checksum *metadata.ObjectChecksum
+	if checksumHasher != nil {
+		value := base64.StdEncoding.EncodeToString(checksumHasher.Sum(nil))
+		if opts.Checksum != "" && opts.Checksum != value {
+			return nil, fmt.Errorf("%w: part %d %s is %s, expected %s", ErrChecksumMismatch, partNumber, checksumAlgorithm, value, opts.Checksum)
+		}
+		checksum = &metadata.ObjectChecksum{Algorithm: checksumAlgorithm, Value: value}
+	}
 
 	// Reset reader if it implements Seeker
 	if seeker, ok := data.(io.Seeker); ok {
@@ -646,35 +1352,170 @@ func (s *ObjectService) UploadPart(ctx context.Context, bucket, key, uploadID st
 		}
 	}
 
-	// Generate ETag
-	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(hasher.Sum(nil)))
+	digest := hex.EncodeToString(md5Hasher.Sum(nil))
+	etag := fmt.Sprintf("\"%s\"", digest)
+
+	// Store part data
+	partKey := fmt.Sprintf("%s/%s/%s/%d", bucket, key, uploadID, partNumber)
+	storeOpts := storage.PutOptions{}
+	if err := s.storage.Put(ctx, bucket, partKey, data, size, storeOpts); err != nil {
+		return nil, fmt.Errorf("failed to store part: %w", err)
+	}
+
+	// Save part metadata
+	partMeta := &metadata.PartMetadata{
+		UploadID:     uploadID,
+		Key:          key,
+		Bucket:       bucket,
+		PartNumber:   partNumber,
+		ETag:         etag,
+		MD5:          digest,
+		Size:         size,
+		LastModified: s.clock.Now().Unix(),
+	}
+
+	if err := s.recordPart(ctx, partMeta); err != nil {
+		return nil, err
+	}
+	telemetry.AddBytesUploaded(size)
+
+	return &UploadPartResult{
+		ETag:       etag,
+		PartNumber: partNumber,
+		Size:       size,
+		Checksum:   checksum,
+	}, nil
+}
+
+// UploadPartCopy stores a byte range of an existing object, or all of it, as
+// a part of a multipart upload. The data is streamed from the source into the
+// part.
+func (s *ObjectService) UploadPartCopy(ctx context.Context, srcBucket, srcKey, bucket, key, uploadID string, partNumber int, opts UploadPartCopyOptions) (*UploadPartCopyResult, error) {
+	srcKey = s.normalizeKey(ctx, srcBucket, srcKey)
+	key = s.normalizeKey(ctx, bucket, key)
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return nil, fmt.Errorf("%w: %d is not between 1 and %d", ErrInvalidPartNumber, partNumber, MaxPartNumber)
+	}
+	if _, err := s.metadata.GetBucket(ctx, srcBucket); err != nil {
+		return nil, fmt.Errorf("source %w: %s", ErrBucketNotFound, srcBucket)
+	}
+	if err := s.checkBucketMode(ctx, srcBucket, false); err != nil {
+		return nil, err
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return nil, err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := s.multipartUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	var checksumHasher hash.Hash
+	if upload != nil && upload.Checksum != nil {
+		if checksumHasher, err = newChecksumHash(upload.Checksum.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	unlock := s.locker.RLock(srcBucket, srcKey)
+	defer unlock()
+
+	srcMeta, err := s.metadata.GetObject(ctx, srcBucket, srcKey, opts.SourceVersionID)
+	if err != nil {
+		if opts.SourceVersionID != "" {
+			return nil, fmt.Errorf("source %w: %s/%s?versionId=%s", ErrNoSuchVersion, srcBucket, srcKey, opts.SourceVersionID)
+		}
+		return nil, fmt.Errorf("source %w: %s/%s", ErrObjectNotFound, srcBucket, srcKey)
+	}
+	if srcMeta.IsDeleteMarker {
+		return nil, fmt.Errorf("source %w: %s/%s?versionId=%s", ErrDeleteMarker, srcBucket, srcKey, srcMeta.VersionID)
+	}
+	if !readable(srcMeta, s.clock.Now()) {
+		return nil, fmt.Errorf("source %w: %s/%s", ErrInvalidObjectState, srcBucket, srcKey)
+	}
+	if opts.IfMatch != "" && !etagListMatches(opts.IfMatch, srcMeta.ETag) {
+		return nil, fmt.Errorf("%w: x-amz-copy-source-if-match %s", ErrPreconditionFailed, opts.IfMatch)
+	}
+	if opts.IfNoneMatch != "" && etagListMatches(opts.IfNoneMatch, srcMeta.ETag) {
+		return nil, fmt.Errorf("%w: x-amz-copy-source-if-none-match %s", ErrPreconditionFailed, opts.IfNoneMatch)
+	}
+
+	size := srcMeta.Size
+	if rng := opts.Range; rng != nil {
+		if rng.Start < 0 || rng.Start >= rng.End || rng.End > srcMeta.Size {
+			return nil, fmt.Errorf("%w: bytes %d-%d of a source of %d bytes", ErrInvalidCopyRange, rng.Start, rng.End-1, srcMeta.Size)
+		}
+		size = rng.End - rng.Start
+	}
+	if size > MaxUploadSize {
+		return nil, fmt.Errorf("%w: part of %d bytes", ErrEntityTooLarge, size)
+	}
+
+	srcDataKey := srcKey
+	if opts.SourceVersionID != "" {
+		srcDataKey = s.dataKey(ctx, srcBucket, srcKey, srcMeta)
+	}
+	data, err := s.openData(ctx, srcBucket, srcDataKey, srcMeta, storage.GetOptions{Range: opts.Range})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source object: %w", err)
+	}
+	defer data.Close()
 
-	// Store part data
+	// The part's MD5 and checksum are computed as it is stored
+	md5Hasher := md5.New()
+	hashers := io.Writer(md5Hasher)
+	if checksumHasher != nil {
+		hashers = io.MultiWriter(md5Hasher, checksumHasher)
+	}
 	partKey := fmt.Sprintf("%s/%s/%s/%d", bucket, key, uploadID, partNumber)
-	storeOpts := storage.PutOptions{}
-	if err := s.storage.Put(ctx, bucket, partKey, data, size, storeOpts); err != nil {
+	if err := s.storage.Put(ctx, bucket, partKey, io.TeeReader(data, hashers), size, storage.PutOptions{}); err != nil {
 		return nil, fmt.Errorf("failed to store part: %w", err)
 	}
 
-	// Save part metadata
+	digest := hex.EncodeToString(md5Hasher.Sum(nil))
 	partMeta := &metadata.PartMetadata{
-		UploadID:   uploadID,
-		Key:        key,
-		Bucket:     bucket,
-		PartNumber: partNumber,
-		ETag:       etag,
-		Size:       size,
+		UploadID:     uploadID,
+		Key:          key,
+		Bucket:       bucket,
+		PartNumber:   partNumber,
+		ETag:         fmt.Sprintf("\"%s\"", digest),
+		MD5:          digest,
+		Size:         size,
+		LastModified: s.clock.Now().Unix(),
+	}
+	if err := s.recordPart(ctx, partMeta); err != nil {
+		return nil, err
 	}
 
-	if err := s.metadata.PutPart(ctx, bucket, key, uploadID, partNumber, partMeta); err != nil {
-		s.logger.Error("failed to save part metadata", zap.Error(err))
+	result := &UploadPartCopyResult{
+		ETag:            partMeta.ETag,
+		LastModified:    partMeta.LastModified,
+		Size:            size,
+		SourceVersionID: srcMeta.VersionID,
+	}
+	if checksumHasher != nil {
+		result.Checksum = &metadata.ObjectChecksum{
+			Algorithm: upload.Checksum.Algorithm,
+			Value:     base64.StdEncoding.EncodeToString(checksumHasher.Sum(nil)),
+		}
 	}
+	return result, nil
+}
 
-	return &UploadPartResult{
-		ETag:       etag,
-		PartNumber: partNumber,
-		Size:       size,
-	}, nil
+// recordPart saves the metadata of a stored part. Only an unavailable
+// metadata store fails the upload; the part's data is already in place.
+func (s *ObjectService) recordPart(ctx context.Context, part *metadata.PartMetadata) error {
+	err := s.checkMetadataWrite(s.metadata.PutPart(ctx, part.Bucket, part.Key, part.UploadID, part.PartNumber, part))
+	if err != nil {
+		s.logger.Error("failed to save part metadata", zap.Error(err))
+		if errors.Is(err, ErrMetadataUnavailable) {
+			return err
+		}
+	}
+	return nil
 }
 
 // PutPart is an alias for UploadPart
@@ -684,73 +1525,167 @@ func (s *ObjectService) PutPart(ctx context.Context, bucket, key, uploadID strin
 	return err
 }
 
-// CompleteMultipartUpload completes a multipart upload
+// CompleteMultipartUpload completes a multipart upload from the parts the
+// client lists. Every listed part must have been uploaded with the ETag given,
+// part numbers must ascend, and every part but the last must be at least
+// MinPartSize. The object is assembled from the listed parts only; any other
+// uploaded parts are discarded.
 func (s *ObjectService) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []PartInfo) (*ObjectResult, error) {
+	return s.completeMultipartUpload(ctx, bucket, key, uploadID, parts, s.minPartSize, nil)
+}
+
+// completeMultipartUpload completes a multipart upload, requiring every
+// listed part but the last to be at least minPartSize. When put is set the
+// upload backs a resumable PutObject, and the object takes put's content
+// headers, encryption and full-object checksum instead of those recorded
+// with the upload.
+func (s *ObjectService) completeMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []PartInfo, minPartSize int64, put *PutObjectOptions) (*ObjectResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
 	// Lock the object
 	unlock := s.locker.Lock(bucket, key)
 	defer unlock()
 
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return nil, err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := s.multipartUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	var storageClass, algorithm string
+	var userMetadata map[string]string
+	var requestedChecksum *metadata.ObjectChecksum
+	if upload != nil {
+		storageClass = upload.StorageClass
+		algorithm = upload.SSEAlgorithm
+		userMetadata = upload.Metadata
+		requestedChecksum = upload.Checksum
+	}
+	if put != nil {
+		algorithm = put.ServerSideEncryption
+		requestedChecksum = nil
+		if put.ChecksumAlgorithm != "" {
+			requestedChecksum = &metadata.ObjectChecksum{Algorithm: put.ChecksumAlgorithm, Type: ChecksumTypeFullObject}
+		} else if put.Checksum != "" {
+			return nil, fmt.Errorf("%w: checksum given without its algorithm", ErrInvalidChecksum)
+		}
+	}
+	// An upload created without encryption takes the bucket's default as it
+	// is when the upload completes
+	if algorithm, err = s.encryptionFor(ctx, bucket, algorithm); err != nil {
+		return nil, err
+	}
+
 	// Get parts from metadata
 	partMetas, err := s.metadata.ListParts(ctx, bucket, key, uploadID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list parts: %w", err)
 	}
 
-	// Sort parts by part number
-	for i := 0; i < len(partMetas)-1; i++ {
-		for j := i + 1; j < len(partMetas); j++ {
-			if partMetas[i].PartNumber > partMetas[j].PartNumber {
-				partMetas[i], partMetas[j] = partMetas[j], partMetas[i]
-			}
+	if len(parts) > MaxPartNumber || len(partMetas) > MaxPartNumber {
+		return nil, fmt.Errorf("%w: upload %s has more than %d parts", ErrTooManyParts, uploadID, MaxPartNumber)
+	}
+
+	selected, err := selectParts(parts, partMetas, minPartSize)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := newMultipartChecksum(requestedChecksum)
+	if err != nil {
+		return nil, err
+	}
+	// A checksum the client computed is checked before anything is written,
+	// so a mismatch leaves the current object in place
+	if put != nil && put.Checksum != "" {
+		if err := s.verifyPartsChecksum(ctx, bucket, key, uploadID, selected, requestedChecksum, put.Checksum); err != nil {
+			return nil, err
 		}
 	}
 
-	// Read all parts and concatenate into final object
+	// The object's size is known from the part metadata, so the parts can be
+	// streamed into storage one after another instead of being assembled in
+	// memory
 	var totalSize int64
-	var allData []byte
-	for _, p := range partMetas {
-		partKey := fmt.Sprintf("%s/%s/%s/%d", bucket, key, uploadID, p.PartNumber)
-		reader, err := s.storage.Get(ctx, bucket, partKey, storage.GetOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("failed to read part %d: %w", p.PartNumber, err)
-		}
-		data, err := io.ReadAll(io.LimitReader(reader, MaxUploadSize+1))
-		reader.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read part data: %w", err)
-		}
-		allData = append(allData, data...)
-		totalSize += int64(len(data))
+	for _, p := range selected {
+		totalSize += p.Size
+	}
+	data := &partsReader{
+		ctx:      ctx,
+		storage:  s.storage,
+		bucket:   bucket,
+		prefix:   fmt.Sprintf("%s/%s/%s", bucket, key, uploadID),
+		parts:    selected,
+		checksum: checksum,
 	}
+	defer data.Close()
 
-	// Write final object to storage
-	storeOpts := storage.PutOptions{}
-	if err := s.storage.Put(ctx, bucket, key, bytes.NewReader(allData), totalSize, storeOpts); err != nil {
+	prev := s.currentObject(ctx, bucket, key)
+	versionID := s.newVersionID(ctx, bucket)
+	replaced, err := s.keepNoncurrent(ctx, bucket, key, prev, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write final object to storage, encrypted as it streams if it is to be
+	stored, storedSize, encrypted, err := s.seal(algorithm, data, totalSize)
+	if err != nil {
+		return nil, err
+	}
+	err = s.storage.Put(ctx, bucket, key, stored, storedSize, storage.PutOptions{StorageClass: storageClass})
+	if err != nil {
 		return nil, fmt.Errorf("failed to write final object: %w", err)
 	}
 
 	// Create final object metadata
-	now := time.Now().Unix()
-	etag := fmt.Sprintf("\"%s\"", uuid.New().String())
+	now := s.clock.Now().Unix()
+	etag := multipartETag(data.digests)
 
 	objMeta := &metadata.ObjectMetadata{
 		Key:          key,
 		Bucket:       bucket,
 		Size:         totalSize,
 		ETag:         etag,
-		VersionID:    uuid.New().String(),
+		Metadata:     userMetadata,
+		StorageClass: storageClass,
+		VersionID:    versionID,
 		IsLatest:    true,
 		LastModified: now,
-		Parts:        convertToMetadataParts(parts),
+		Parts:        selectedPartInfo(selected),
+		Checksum:     checksum.sum(),
 	}
+	if put != nil {
+		objMeta.ContentType = put.ContentType
+		objMeta.ContentEncoding = put.ContentEncoding
+		objMeta.CacheControl = put.CacheControl
+		objMeta.ContentDisposition = put.ContentDisposition
+	}
+	encrypted.apply(objMeta)
 
 	// Save final object metadata
-	if err := s.metadata.PutObject(ctx, bucket, key, objMeta); err != nil {
+	if err := s.checkMetadataWrite(s.metadata.PutObject(ctx, bucket, key, objMeta)); err != nil {
 		return nil, fmt.Errorf("failed to save metadata: %w", err)
 	}
+	if err := s.setObjectACL(ctx, bucket, key, nil); err != nil {
+		return nil, err
+	}
+	s.usage.recordWrite(ctx, bucket, replaced, totalSize)
+	s.publish(events.ObjectEvent{
+		Type:      events.EventObjectMultipart,
+		Bucket:    bucket,
+		Key:       key,
+		Size:      totalSize,
+		ETag:      etag,
+		VersionID: objMeta.VersionID,
+		UploadID:  uploadID,
+	})
 
 	// Complete multipart upload (cleanup)
-	if err := s.metadata.CompleteMultipartUpload(ctx, bucket, key, uploadID, convertToMetadataParts(parts)); err != nil {
+	if err := s.metadata.CompleteMultipartUpload(ctx, bucket, key, uploadID, objMeta.Parts); err != nil {
 		s.logger.Warn("failed to cleanup multipart upload", zap.Error(err))
 	}
 
@@ -767,11 +1702,18 @@ func (s *ObjectService) CompleteMultipartUpload(ctx context.Context, bucket, key
 		Size:         totalSize,
 		VersionID:    objMeta.VersionID,
 		LastModified: now,
+
+		ServerSideEncryption: algorithm,
+		Checksum:             objMeta.Checksum,
 	}, nil
 }
 
 // AbortMultipartUpload aborts a multipart upload
 func (s *ObjectService) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
 	// Delete all parts from storage
 	partMetas, err := s.metadata.ListParts(ctx, bucket, key, uploadID)
 	if err == nil {
@@ -785,8 +1727,27 @@ func (s *ObjectService) AbortMultipartUpload(ctx context.Context, bucket, key, u
 	return s.metadata.AbortMultipartUpload(ctx, bucket, key, uploadID)
 }
 
+// multipartUpload returns the record of an in-progress multipart upload, or
+// nil if there is no such upload
+func (s *ObjectService) multipartUpload(ctx context.Context, bucket, key, uploadID string) (*metadata.MultipartUploadMetadata, error) {
+	uploads, err := s.metadata.ListMultipartUploads(ctx, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list multipart uploads: %w", err)
+	}
+	for i := range uploads {
+		if uploads[i].UploadID == uploadID && uploads[i].Key == key {
+			return &uploads[i], nil
+		}
+	}
+	return nil, nil
+}
+
 // ListMultipartUploads lists multipart uploads
 func (s *ObjectService) ListMultipartUpload(ctx context.Context, bucket, prefix string) (*ListMultipartUploadsResult, error) {
+	prefix = s.normalizeKey(ctx, bucket, prefix)
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
 	uploads, err := s.metadata.ListMultipartUploads(ctx, bucket, prefix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list multipart uploads: %w", err)
@@ -810,23 +1771,271 @@ func (s *ObjectService) ListMultipartUpload(ctx context.Context, bucket, prefix
 
 // ListParts lists parts of a multipart upload
 func (s *ObjectService) ListParts(ctx context.Context, bucket, key, uploadID string) ([]PartInfo, error) {
-	// Verify upload exists
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
+	return s.listParts(ctx, bucket, key, uploadID)
+}
+
+// ListPartsWithOptions lists a page of the parts uploaded so far, in part
+// number order, starting after opts.PartNumberMarker
+func (s *ObjectService) ListPartsWithOptions(ctx context.Context, bucket, key, uploadID string, opts ListPartsOptions) (*ListPartsResult, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
+	if opts.MaxParts <= 0 || opts.MaxParts > MaxListParts {
+		opts.MaxParts = MaxListParts
+	}
+
+	parts, err := s.listParts(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	start := sort.Search(len(parts), func(i int) bool { return parts[i].PartNumber > opts.PartNumberMarker })
+	parts = parts[start:]
+
+	result := &ListPartsResult{
+		PartNumberMarker: opts.PartNumberMarker,
+		MaxParts:         opts.MaxParts,
+	}
+	if len(parts) > opts.MaxParts {
+		parts = parts[:opts.MaxParts]
+		result.IsTruncated = true
+	}
+	if len(parts) > 0 {
+		result.NextPartNumberMarker = parts[len(parts)-1].PartNumber
+	}
+	result.Parts = parts
+
+	upload, err := s.multipartUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload != nil {
+		result.StorageClass = upload.StorageClass
+	}
+	return result, nil
+}
+
+// listParts returns every part stored for an upload, in part number order
+func (s *ObjectService) listParts(ctx context.Context, bucket, key, uploadID string) ([]PartInfo, error) {
 	partMetas, err := s.metadata.ListParts(ctx, bucket, key, uploadID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list parts: %w", err)
 	}
 
+	sortPartMetas(partMetas)
+
 	var parts []PartInfo
 	for _, pm := range partMetas {
 		parts = append(parts, PartInfo{
-			PartNumber: pm.PartNumber,
-			ETag:       pm.ETag,
+			PartNumber:   pm.PartNumber,
+			ETag:         pm.ETag,
+			Size:         pm.Size,
+			LastModified: pm.LastModified,
 		})
 	}
 
 	return parts, nil
 }
 
+// sortPartMetas orders parts by part number. Stores return parts in key
+// order, which only matches numeric order for part keys written with
+// zero-padded numbers.
+func sortPartMetas(parts []metadata.PartMetadata) {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+}
+
+// selectParts matches the parts a client lists to complete an upload against
+// the parts stored for it, and returns the stored parts to assemble in the
+// order listed
+func selectParts(listed []PartInfo, stored []metadata.PartMetadata, minPartSize int64) ([]metadata.PartMetadata, error) {
+	if len(listed) == 0 {
+		return nil, fmt.Errorf("%w: no parts listed", ErrInvalidPart)
+	}
+
+	byNumber := make(map[int]metadata.PartMetadata, len(stored))
+	for _, p := range stored {
+		byNumber[p.PartNumber] = p
+	}
+
+	for i := 1; i < len(listed); i++ {
+		if listed[i].PartNumber <= listed[i-1].PartNumber {
+			return nil, fmt.Errorf("%w: part %d listed after part %d", ErrInvalidPartOrder, listed[i].PartNumber, listed[i-1].PartNumber)
+		}
+	}
+
+	selected := make([]metadata.PartMetadata, 0, len(listed))
+	for i, p := range listed {
+		part, ok := byNumber[p.PartNumber]
+		if !ok {
+			return nil, fmt.Errorf("%w: part %d was not uploaded", ErrInvalidPart, p.PartNumber)
+		}
+		if strings.Trim(p.ETag, "\"") != strings.Trim(part.ETag, "\"") {
+			return nil, fmt.Errorf("%w: part %d has ETag %s, not %s", ErrInvalidPart, p.PartNumber, part.ETag, p.ETag)
+		}
+		if i < len(listed)-1 && part.Size < minPartSize {
+			return nil, fmt.Errorf("%w: part %d is %d bytes, below the %d byte minimum", ErrEntityTooSmall, p.PartNumber, part.Size, minPartSize)
+		}
+		selected = append(selected, part)
+	}
+	return selected, nil
+}
+
+// selectedPartInfo lists the parts an object was assembled from
+func selectedPartInfo(parts []metadata.PartMetadata) []metadata.PartInfo {
+	result := make([]metadata.PartInfo, len(parts))
+	for i, p := range parts {
+		result[i] = metadata.PartInfo{PartNumber: p.PartNumber, ETag: p.ETag, Size: p.Size}
+	}
+	return result
+}
+
+// verifyPartsChecksum reads the selected parts of an upload and checks their
+// requested checksum against the value the client expects
+func (s *ObjectService) verifyPartsChecksum(ctx context.Context, bucket, key, uploadID string, parts []metadata.PartMetadata, requested *metadata.ObjectChecksum, expected string) error {
+	checksum, err := newMultipartChecksum(requested)
+	if err != nil {
+		return err
+	}
+	data := &partsReader{
+		ctx:      ctx,
+		storage:  s.storage,
+		bucket:   bucket,
+		prefix:   fmt.Sprintf("%s/%s/%s", bucket, key, uploadID),
+		parts:    parts,
+		checksum: checksum,
+	}
+	defer data.Close()
+	if _, err := io.Copy(io.Discard, data); err != nil {
+		return err
+	}
+	if value := checksum.sum().Value; value != expected {
+		return fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, requested.Algorithm, value, expected)
+	}
+	return nil
+}
+
+// partsReader reads the selected parts of a multipart upload in order,
+// opening each part only once the one before it is used up. The part digests
+// for the ETag and the upload's checksum are computed as the data is read.
+type partsReader struct {
+	ctx      context.Context
+	storage  storage.StorageBackend
+	bucket   string
+	prefix   string // bucket/key/uploadID
+	parts    []metadata.PartMetadata
+	checksum *multipartChecksum
+	digests  [][]byte
+
+	current io.ReadCloser
+	md5     hash.Hash
+	read    int64
+}
+
+// Read reads data of the current part, moving on to the next part at its end
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.digests) == len(r.parts) {
+				return 0, io.EOF
+			}
+			if err := r.openPart(); err != nil {
+				return 0, err
+			}
+		}
+		part := r.parts[len(r.digests)]
+		n, err := r.current.Read(p)
+		if n > 0 {
+			r.read += int64(n)
+			if r.read > part.Size {
+				return 0, fmt.Errorf("part %d holds more than its %d bytes", part.PartNumber, part.Size)
+			}
+			if r.md5 != nil {
+				r.md5.Write(p[:n])
+			}
+			r.checksum.Write(p[:n])
+		}
+		if err == io.EOF {
+			if err := r.endPart(part); err != nil {
+				return n, err
+			}
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to read part %d: %w", part.PartNumber, err)
+		}
+		return n, nil
+	}
+}
+
+// openPart opens the next part. Its MD5 is only computed when none was
+// recorded at upload time.
+func (r *partsReader) openPart() error {
+	part := r.parts[len(r.digests)]
+	reader, err := r.storage.Get(r.ctx, r.bucket, fmt.Sprintf("%s/%d", r.prefix, part.PartNumber), storage.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read part %d: %w", part.PartNumber, err)
+	}
+	r.current = reader
+	r.read = 0
+	r.md5 = nil
+	if recordedPartDigest(part) == nil {
+		r.md5 = md5.New()
+	}
+	return nil
+}
+
+// endPart closes the current part once all of it has been read
+func (r *partsReader) endPart(part metadata.PartMetadata) error {
+	r.current.Close()
+	r.current = nil
+	if r.read != part.Size {
+		return fmt.Errorf("part %d is %d bytes, expected %d", part.PartNumber, r.read, part.Size)
+	}
+	digest := recordedPartDigest(part)
+	if digest == nil {
+		digest = r.md5.Sum(nil)
+	}
+	r.digests = append(r.digests, digest)
+	r.checksum.endPart()
+	return nil
+}
+
+// Close closes the part being read, if any
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
+
+// recordedPartDigest returns the raw MD5 of a part recorded at upload time,
+// or nil if none was
+func recordedPartDigest(part metadata.PartMetadata) []byte {
+	if digest, err := hex.DecodeString(part.MD5); err == nil && len(digest) == md5.Size {
+		return digest
+	}
+	return nil
+}
+
+// multipartETag formats the S3 ETag of a multipart object: the MD5 of the
+// concatenated part digests, suffixed with the number of parts
+func multipartETag(partDigests [][]byte) string {
+	hasher := md5.New()
+	for _, digest := range partDigests {
+		hasher.Write(digest)
+	}
+	return fmt.Sprintf("\"%s-%d\"", hex.EncodeToString(hasher.Sum(nil)), len(partDigests))
+}
+
 // PutLifecycleRule adds a lifecycle rule to a bucket
 func (s *ObjectService) PutLifecycleRule(ctx context.Context, bucket string, rule *metadata.LifecycleRule) error {
 	return s.metadata.PutLifecycleRule(ctx, bucket, rule)
@@ -842,6 +2051,12 @@ func (s *ObjectService) DeleteLifecycleRule(ctx context.Context, bucket, ruleID
 	return s.metadata.DeleteLifecycleRule(ctx, bucket, ruleID)
 }
 
+// versioned reports whether versioning has ever been turned on for a bucket.
+// Suspending it keeps the versions already written.
+func (s *ObjectService) versioned(ctx context.Context, bucket string) bool {
+	return s.versioningStatus(ctx, bucket) != ""
+}
+
 // PutBucketVersioning sets bucket versioning
 func (s *ObjectService) PutBucketVersioning(ctx context.Context, bucket string, versioning *metadata.BucketVersioning) error {
 	return s.metadata.PutBucketVersioning(ctx, bucket, versioning)
@@ -883,11 +2098,15 @@ func (s *ObjectService) GetBucketLifecycle(ctx context.Context, bucket string) (
 	return s.metadata.GetLifecycleRules(ctx, bucket)
 }
 
-// PutBucketCors sets CORS configuration for a bucket
+// PutBucketCors sets CORS configuration for a bucket. A configuration with
+// any invalid rule is rejected as a whole with ErrInvalidCORS.
 func (s *ObjectService) PutBucketCors(ctx context.Context, bucket string, cors *metadata.CORSConfiguration) error {
 	if cors == nil {
 		return fmt.Errorf("CORS configuration is required")
 	}
+	if err := validateCORS(cors); err != nil {
+		return err
+	}
 	return s.metadata.PutBucketCors(ctx, bucket, cors)
 }
 
@@ -989,26 +2208,6 @@ func (s *ObjectService) DeleteObjectLock(ctx context.Context, bucket string) err
 	return s.metadata.DeleteObjectLock(ctx, bucket)
 }
 
-// PutObjectRetention sets object retention
-func (s *ObjectService) PutObjectRetention(ctx context.Context, bucket, key string, retention *metadata.ObjectRetention) error {
-	return s.metadata.PutObjectRetention(ctx, bucket, key, retention)
-}
-
-// GetObjectRetention gets object retention
-func (s *ObjectService) GetObjectRetention(ctx context.Context, bucket, key string) (*metadata.ObjectRetention, error) {
-	return s.metadata.GetObjectRetention(ctx, bucket, key)
-}
-
-// PutObjectLegalHold sets object legal hold
-func (s *ObjectService) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *metadata.ObjectLegalHold) error {
-	return s.metadata.PutObjectLegalHold(ctx, bucket, key, legalHold)
-}
-
-// GetObjectLegalHold gets object legal hold
-func (s *ObjectService) GetObjectLegalHold(ctx context.Context, bucket, key string) (*metadata.ObjectLegalHold, error) {
-	return s.metadata.GetObjectLegalHold(ctx, bucket, key)
-}
-
 // PutPublicAccessBlock sets public access block configuration for a bucket
 func (s *ObjectService) PutPublicAccessBlock(ctx context.Context, bucket string, config *metadata.PublicAccessBlockConfiguration) error {
 	if config == nil {
@@ -1091,8 +2290,22 @@ func (s *ObjectService) DeleteBucketAnalytics(ctx context.Context, bucket, id st
 	return s.metadata.DeleteBucketAnalytics(ctx, bucket, id)
 }
 
-// GeneratePresignedURL generates a presigned URL for an object
-func (s *ObjectService) GeneratePresignedURL(ctx context.Context, bucket, key, method string, expires int64) (string, error) {
+// SetPresigner enables GeneratePresignedURL, which signs URLs with the
+// credentials of signer and points them at endpoint, the base URL of the S3
+// API such as "http://localhost:8080/s3"
+func (s *ObjectService) SetPresigner(signer *auth.Auth, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid presign endpoint: %q", endpoint)
+	}
+	s.signer = signer
+	s.presignEndpoint = endpoint
+	return nil
+}
+
+// GeneratePresignedURL generates a SigV4 presigned URL for an object, signed
+// with the secret of accessKey. expires is the validity in seconds.
+func (s *ObjectService) GeneratePresignedURL(ctx context.Context, accessKey, bucket, key, method string, expires int64) (string, error) {
 	if bucket == "" {
 		return "", fmt.Errorf("bucket is required")
 	}
@@ -1102,39 +2315,19 @@ func (s *ObjectService) GeneratePresignedURL(ctx context.Context, bucket, key, m
 	if method == "" {
 		return "", fmt.Errorf("method is required")
 	}
+	if s.signer == nil {
+		return "", fmt.Errorf("presigned URLs are not enabled")
+	}
 
 	// Check if the object exists (for PUT/DELETE)
 	if method == "PUT" || method == "DELETE" {
 		_, err := s.metadata.GetObject(ctx, bucket, key, "")
 		if err != nil {
-			return "", fmt.Errorf("object not found: %w", err)
+			return "", fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
 		}
 	}
 
-	// Generate presigned URL using AWS Signature V4
-	// For now, we'll create a simple presigned URL that includes the expiration
-	// In production, this would use proper AWS Signature V4 signing
-	scheme := "http"
-	host := "localhost:8080"
-
-	// Create the presigned URL request
-	req := &metadata.PresignedURLRequest{
-		Bucket:  bucket,
-		Key:     key,
-		Method:  method,
-		Expires: expires,
-		Scheme:  scheme,
-		Host:    host,
-	}
-
-	// Store the presigned URL metadata
-	urlStr := fmt.Sprintf("%s://%s/s3/%s/%s?presigned=%d", scheme, host, bucket, key, time.Now().Unix()+expires)
-	err := s.metadata.PutPresignedURL(ctx, urlStr, req)
-	if err != nil {
-		return "", fmt.Errorf("failed to store presigned URL: %w", err)
-	}
-
-	return urlStr, nil
+	return s.presign(ctx, accessKey, bucket, key, method, time.Duration(expires)*time.Second, nil)
 }
 
 // ValidatePresignedURL validates a presigned URL
@@ -1165,11 +2358,15 @@ func (s *ObjectService) DeleteBucketWebsite(ctx context.Context, bucket string)
 	return s.metadata.DeleteBucketWebsite(ctx, bucket)
 }
 
-// PutBucketNotification sets bucket notification configuration
+// PutBucketNotification sets bucket notification configuration. Invalid
+// webhook targets are rejected with ErrInvalidNotification.
 func (s *ObjectService) PutBucketNotification(ctx context.Context, bucket string, config *metadata.NotificationConfiguration) error {
 	if config == nil {
 		return fmt.Errorf("notification configuration is required")
 	}
+	if err := s.validateWebhooks(config.WebhookConfigurations); err != nil {
+		return err
+	}
 	return s.metadata.PutBucketNotification(ctx, bucket, config)
 }
 
@@ -1248,11 +2445,34 @@ func (s *ObjectService) ListBucketMetrics(ctx context.Context, bucket string) ([
 
 // Options for PutObject
 type PutObjectOptions struct {
-	ContentType     string
-	ContentEncoding string
-	CacheControl   string
-	Metadata       map[string]string
-	StorageClass   string
+	ContentType        string
+	ContentEncoding    string
+	CacheControl       string
+	ContentDisposition string
+	Metadata           map[string]string
+	StorageClass       string
+
+	// ServerSideEncryption is the x-amz-server-side-encryption algorithm to
+	// store the object with; empty applies the bucket's default encryption
+	ServerSideEncryption string
+
+	// Retention and LegalHold lock the object as it is written. They are
+	// only accepted by PutObject, in buckets with object lock enabled.
+	Retention *metadata.ObjectRetention
+	LegalHold *metadata.ObjectLegalHold
+
+	// ACL is the ACL to store the object with, as set by x-amz-acl; nil
+	// gives it the default ACL. Only PutObject applies it.
+	ACL *metadata.AccessControlList
+
+	// ChecksumAlgorithm is the additional checksum to store the object
+	// with, and Checksum the base64 value the client computed, which the
+	// data must match. CreateMultipartUpload takes the algorithm and
+	// ChecksumType, FULL_OBJECT or COMPOSITE, and ignores Checksum.
+	// ResumePutObject checks Checksum against the whole object.
+	ChecksumAlgorithm string
+	ChecksumType      string
+	Checksum          string
 }
 
 // Result from PutObject
@@ -1261,6 +2481,10 @@ type ObjectResult struct {
 	Size         int64
 	VersionID    string
 	LastModified int64
+	// ServerSideEncryption is the algorithm the object was stored with
+	ServerSideEncryption string
+	// Checksum is the additional checksum the object was stored with
+	Checksum *metadata.ObjectChecksum
 }
 
 // Options for GetObject
@@ -1271,38 +2495,82 @@ type GetObjectOptions struct {
 	IfNoneMatch       string
 	IfModifiedSince   string
 	IfUnmodifiedSince string
+
+	// VerifyIntegrity makes reading the body fail with ErrIntegrityMismatch
+	// if the data does not match the object's ETag. It has no effect on
+	// ranged reads or on objects whose ETag is not a content hash; the
+	// result's Verified field tells the two cases apart.
+	VerifyIntegrity bool
 }
 
 // Result from GetObject
 type GetObjectResult struct {
-	Body         io.ReadCloser
-	Size         int64
-	ETag         string
-	ContentType  string
-	Metadata     map[string]string
-	LastModified int64
-	VersionID    string
-	StorageClass string
+	Body               io.ReadCloser
+	Size               int64
+	ETag               string
+	ContentType        string
+	ContentEncoding    string
+	CacheControl       string
+	ContentDisposition string
+	Metadata           map[string]string
+	LastModified       int64
+	VersionID          string
+	StorageClass       string
+	ReplicationStatus  string
+	// ServerSideEncryption is the algorithm the object is stored with
+	ServerSideEncryption string
+	// Checksum is the additional checksum the object was stored with
+	Checksum *metadata.ObjectChecksum
+	// Restore is the x-amz-restore status of a restored archived object
+	Restore string
+
+	// Verified is set when reading Body checks the data, against the ETag
+	// (failing with ErrIntegrityMismatch) or, in a backend that verifies
+	// reads, against the hash stored with it (failing with
+	// storage.ErrChecksumMismatch)
+	Verified bool
 }
 
 // Options for DeleteObject
 type DeleteObjectOptions struct {
 	VersionID string
+
+	// BypassGovernance allows deleting an object under GOVERNANCE retention
+	BypassGovernance bool
+}
+
+// DeleteObjectResult describes what a DeleteObject removed or added
+type DeleteObjectResult struct {
+	// DeleteMarker is set when the delete created a delete marker, or
+	// removed the delete marker version it named
+	DeleteMarker bool
+	// VersionID is the version of the delete marker created, or the version
+	// deleted. It is empty in a bucket without versioning.
+	VersionID string
 }
 
 // Object info
 type ObjectInfo struct {
-	Key             string
-	Size            int64
-	ETag            string
-	ContentType     string
-	ContentEncoding string
-	CacheControl    string
-	Metadata        map[string]string
-	StorageClass    string
-	LastModified    int64
-	VersionID       string
-	IsLatest        bool
+	Key                string
+	Size               int64
+	ETag               string
+	ContentType        string
+	ContentEncoding    string
+	CacheControl       string
+	ContentDisposition string
+	Metadata           map[string]string
+	StorageClass       string
+	ReplicationStatus  string
+	LastModified       int64
+	VersionID          string
+	IsLatest           bool
+	// ServerSideEncryption is the algorithm the object is stored with
+	ServerSideEncryption string
+	// Checksum is the additional checksum the object was stored with
+	Checksum *metadata.ObjectChecksum
+	// Restore is the x-amz-restore status of an archived object being or
+	// having been restored
+	Restore string
 }
 
 // Options for ListObjects
@@ -1311,6 +2579,9 @@ type ListObjectsOptions struct {
 	Delimiter string
 	MaxKeys   int
 	Marker    string
+	// StartAfter lists keys after this one, like Marker; the later of the
+	// two wins
+	StartAfter string
 }
 
 // Result from ListObjects
@@ -1342,13 +2613,74 @@ type UploadPartResult struct {
 	ETag       string
 	PartNumber int
 	Size       int64
+	// Checksum is the part's additional checksum, when the upload or the
+	// request asked for one
+	Checksum *metadata.ObjectChecksum
+}
+
+// UploadPartOptions contains options for UploadPartWithOptions
+type UploadPartOptions struct {
+	// ChecksumAlgorithm is the additional checksum the client computed for
+	// the part, and Checksum its base64 value, which the data must match.
+	// The algorithm must be the one the upload was created with, if any.
+	ChecksumAlgorithm string
+	Checksum          string
+}
+
+// UploadPartCopyOptions contains options for UploadPartCopy
+type UploadPartCopyOptions struct {
+	// SourceVersionID selects a version of the source; empty copies its
+	// current version
+	SourceVersionID string
+	// Range is the byte range of the source to copy, with an exclusive End;
+	// nil copies the whole source
+	Range *storage.Range
+
+	// IfMatch and IfNoneMatch are preconditions on the source ETag; either
+	// failing is ErrPreconditionFailed
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// UploadPartCopyResult contains the result of UploadPartCopy
+type UploadPartCopyResult struct {
+	ETag         string
+	LastModified int64
+	Size         int64
+	// SourceVersionID is the version of the source that was copied
+	SourceVersionID string
+	// Checksum is the part's additional checksum, when the upload asked for
+	// one
+	Checksum *metadata.ObjectChecksum
 }
 
 // Part info for CompleteMultipartUpload
 type PartInfo struct {
-	PartNumber int    `json:"PartNumber"`
-	ETag       string `json:"ETag"`
-	Size       int64  `json:"Size"`
+	PartNumber   int    `json:"PartNumber"`
+	ETag         string `json:"ETag"`
+	Size         int64  `json:"Size"`
+	LastModified int64  `json:"LastModified"`
+}
+
+// ListPartsOptions contains options for ListPartsWithOptions
+type ListPartsOptions struct {
+	// MaxParts caps the parts listed, at most MaxListParts; zero lists
+	// MaxListParts
+	MaxParts int
+	// PartNumberMarker lists the parts after this part number
+	PartNumberMarker int
+}
+
+// ListPartsResult is the result of ListPartsWithOptions
+type ListPartsResult struct {
+	Parts            []PartInfo
+	PartNumberMarker int
+	MaxParts         int
+	IsTruncated      bool
+	// NextPartNumberMarker continues a truncated listing
+	NextPartNumberMarker int
+	// StorageClass is the storage class the upload's object will have
+	StorageClass string
 }
 
 // Result from ListMultipartUploads
@@ -1365,35 +2697,69 @@ type MultipartUpload struct {
 	Initiated int64
 }
 
-// validateBucketName validates bucket name according to S3 conventions
+// validateBucketName validates bucket name according to the S3 naming rules:
+// 3 to 63 lowercase letters, digits, hyphens and dots, starting and ending
+// with a letter or digit, with no dot next to another dot or a hyphen, not
+// formatted as an IP address and without a prefix or suffix S3 reserves.
+// Each broken rule is reported as ErrInvalidBucketName with its reason.
 func validateBucketName(name string) error {
 	if len(name) < 3 || len(name) > 63 {
-		return fmt.Errorf("bucket name must be between 3 and 63 characters")
+		return fmt.Errorf("%w: must be between 3 and 63 characters", ErrInvalidBucketName)
 	}
 
-	// Check for valid characters
-	validChars := "abcdefghijklmnopqrstuvwxyz0123456789.-"
 	for _, c := range name {
-		if !strings.ContainsRune(validChars, c) {
-			return fmt.Errorf("bucket name contains invalid characters")
+		switch {
+		case c >= 'A' && c <= 'Z':
+			return fmt.Errorf("%w: must not contain uppercase letters", ErrInvalidBucketName)
+		case (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '.' && c != '-':
+			return fmt.Errorf("%w: contains invalid characters", ErrInvalidBucketName)
 		}
 	}
 
-	// Check for IP address format
-	if len(name) >= 7 && name[len(name)-7:] == ".ipaddr" {
-		return fmt.Errorf("bucket name cannot be an IP address")
+	if !isBucketNameEdge(name[0]) || !isBucketNameEdge(name[len(name)-1]) {
+		return fmt.Errorf("%w: must start and end with a letter or digit", ErrInvalidBucketName)
+	}
+	for _, seq := range []string{"..", ".-", "-."} {
+		if strings.Contains(name, seq) {
+			return fmt.Errorf("%w: must not contain %q", ErrInvalidBucketName, seq)
+		}
 	}
 
-	// Check for dots (not allowed in virtual-hosted style)
-	// This is optional and depends on region
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("%w: cannot be an IP address", ErrInvalidBucketName)
+	}
 
+	for _, prefix := range reservedBucketPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("%w: must not start with %q", ErrInvalidBucketName, prefix)
+		}
+	}
+	for _, suffix := range reservedBucketSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Errorf("%w: must not end with %q", ErrInvalidBucketName, suffix)
+		}
+	}
 	return nil
 }
 
-// Locker provides per-object locking
+// Bucket name prefixes and suffixes S3 reserves for its own use
+var (
+	reservedBucketPrefixes = []string{"xn--", "sthree-"}
+	reservedBucketSuffixes = []string{"-s3alias", "--ol-s3"}
+)
+
+// isBucketNameEdge reports whether c may start or end a bucket name
+func isBucketNameEdge(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// Locker provides per-object locking. Every exclusive object lock also
+// holds its bucket's lock shared, so LockBucket waits out in-flight writes
+// and keeps new ones from starting.
 type Locker struct {
 	mu              sync.RWMutex
 	locks           map[string]*sync.RWMutex
+	buckets         map[string]*sync.RWMutex
 	maxLocks        int
 	cleanupInterval time.Duration
 	stopCleanup     chan struct{}
@@ -1403,6 +2769,7 @@ type Locker struct {
 func NewLocker() *Locker {
 	l := &Locker{
 		locks:           make(map[string]*sync.RWMutex),
+		buckets:         make(map[string]*sync.RWMutex),
 		maxLocks:        10000, // Maximum number of locks to keep
 		cleanupInterval: 5 * time.Minute,
 		stopCleanup:     make(chan struct{}),
@@ -1450,12 +2817,54 @@ func (l *Locker) Lock(bucket, key string) func() {
 		l.locks[keyStr] = &sync.RWMutex{}
 	}
 	mu := l.locks[keyStr]
+	bucketMu := l.bucketLock(bucket)
+	l.mu.Unlock()
+
+	bucketMu.RLock()
+	mu.Lock()
+	return func() {
+		mu.Unlock()
+		bucketMu.RUnlock()
+	}
+}
+
+// LockBucket acquires an exclusive lock on a bucket, which no object in it
+// can be locked for writing alongside
+func (l *Locker) LockBucket(bucket string) func() {
+	l.mu.Lock()
+	mu := l.bucketLock(bucket)
 	l.mu.Unlock()
 
 	mu.Lock()
 	return func() { mu.Unlock() }
 }
 
+// bucketLock returns a bucket's lock, creating it if needed. l.mu must be
+// held for writing.
+func (l *Locker) bucketLock(bucket string) *sync.RWMutex {
+	if l.buckets[bucket] == nil {
+		l.buckets[bucket] = &sync.RWMutex{}
+	}
+	return l.buckets[bucket]
+}
+
+// LockPair acquires exclusive locks on two objects in a fixed order so that
+// concurrent callers locking the same pair cannot deadlock
+func (l *Locker) LockPair(bucket1, key1, bucket2, key2 string) func() {
+	if bucket1 == bucket2 && key1 == key2 {
+		return l.Lock(bucket1, key1)
+	}
+	if bucket1+"/"+key1 > bucket2+"/"+key2 {
+		bucket1, key1, bucket2, key2 = bucket2, key2, bucket1, key1
+	}
+	unlock1 := l.Lock(bucket1, key1)
+	unlock2 := l.Lock(bucket2, key2)
+	return func() {
+		unlock2()
+		unlock1()
+	}
+}
+
 // RLock acquires a read lock
 func (l *Locker) RLock(bucket, key string) func() {
 	l.mu.RLock()
@@ -1481,16 +2890,3 @@ func parseInt(s string, defaultVal int) int {
 	}
 	return i
 }
-
-// convertToMetadataParts converts engine PartInfo to metadata.PartInfo
-func convertToMetadataParts(parts []PartInfo) []metadata.PartInfo {
-	result := make([]metadata.PartInfo, len(parts))
-	for i, p := range parts {
-		result[i] = metadata.PartInfo{
-			PartNumber: p.PartNumber,
-			ETag:       p.ETag,
-			Size:       p.Size,
-		}
-	}
-	return result
-}
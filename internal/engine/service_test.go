@@ -3,11 +3,20 @@ package engine
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/openendpoint/openendpoint/internal/auth"
+	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/metadata"
 	"github.com/openendpoint/openendpoint/internal/storage"
 	"go.uber.org/zap"
@@ -32,13 +41,13 @@ func (m *MockStorageBackend) objectKey(bucket, key string) string {
 }
 
 func (m *MockStorageBackend) Put(ctx context.Context, bucket, key string, data io.Reader, size int64, opts storage.PutOptions) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	b, err := io.ReadAll(data)
 	if err != nil {
 		return err
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.objects[m.objectKey(bucket, key)] = b
 	return nil
 }
@@ -51,6 +60,13 @@ func (m *MockStorageBackend) Get(ctx context.Context, bucket, key string, opts s
 	if !ok {
 		return nil, io.EOF
 	}
+	if opts.Range != nil {
+		rng, ok := opts.Range.Resolve(int64(len(data)))
+		if !ok {
+			return nil, io.EOF
+		}
+		data = data[rng.Start:rng.End]
+	}
 	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
@@ -82,13 +98,17 @@ func (m *MockStorageBackend) List(ctx context.Context, bucket, prefix string, op
 	var objects []storage.ObjectInfo
 	prefixKey := bucket + "/" + prefix
 	for k, v := range m.objects {
-		if len(k) >= len(prefixKey) && k[:len(prefixKey)] == prefixKey {
+		if len(k) >= len(prefixKey) && k[:len(prefixKey)] == prefixKey && k[len(bucket)+1:] > opts.Marker {
 			objects = append(objects, storage.ObjectInfo{
 				Key:  k[len(bucket)+1:],
 				Size: int64(len(v)),
 			})
 		}
 	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	if opts.MaxKeys > 0 && len(objects) > opts.MaxKeys {
+		objects = objects[:opts.MaxKeys]
+	}
 	return &storage.ListResult{Objects: objects}, nil
 }
 
@@ -142,10 +162,17 @@ type MockMetadataStore struct {
 	policies    map[string]*string
 	encryption  map[string]*metadata.BucketEncryption
 	tags        map[string]map[string]string
+	objectTags  map[string]map[string]string
 	replication map[string]*metadata.ReplicationConfig
 	lifecycle   map[string][]metadata.LifecycleRule
 	uploads     map[string][]metadata.MultipartUploadMetadata
 	parts       map[string][]metadata.PartMetadata
+
+	keyNormalization map[string]*metadata.KeyNormalizationConfig
+	usage            map[string]*metadata.BucketUsage
+	modes            map[string]*metadata.BucketMode
+	acls             map[string]*metadata.AccessControlList
+	publicAccess     map[string]*metadata.PublicAccessBlockConfiguration
 }
 
 func NewMockMetadataStore() *MockMetadataStore {
@@ -157,10 +184,17 @@ func NewMockMetadataStore() *MockMetadataStore {
 		policies:    make(map[string]*string),
 		encryption:  make(map[string]*metadata.BucketEncryption),
 		tags:        make(map[string]map[string]string),
+		objectTags:  make(map[string]map[string]string),
 		replication: make(map[string]*metadata.ReplicationConfig),
 		lifecycle:   make(map[string][]metadata.LifecycleRule),
 		uploads:     make(map[string][]metadata.MultipartUploadMetadata),
 		parts:       make(map[string][]metadata.PartMetadata),
+
+		keyNormalization: make(map[string]*metadata.KeyNormalizationConfig),
+		usage:            make(map[string]*metadata.BucketUsage),
+		modes:            make(map[string]*metadata.BucketMode),
+		acls:             make(map[string]*metadata.AccessControlList),
+		publicAccess:     make(map[string]*metadata.PublicAccessBlockConfiguration),
 	}
 }
 
@@ -214,7 +248,7 @@ func (m *MockMetadataStore) GetObject(ctx context.Context, bucket, key string, v
 	if o, ok := m.objects[m.objectKey(bucket, key)]; ok {
 		return o, nil
 	}
-	return nil, io.EOF
+	return nil, metadata.ErrObjectNotFound
 }
 
 func (m *MockMetadataStore) DeleteObject(ctx context.Context, bucket, key string, versionID string) error {
@@ -223,6 +257,35 @@ func (m *MockMetadataStore) DeleteObject(ctx context.Context, bucket, key string
 	delete(m.objects, m.objectKey(bucket, key))
 	return nil
 }
+func (m *MockMetadataStore) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.objects[m.objectKey(srcBucket, srcKey)]
+	if !ok {
+		return io.EOF
+	}
+	if _, ok := m.objects[m.objectKey(dstBucket, dstKey)]; ok {
+		return metadata.ErrObjectExists
+	}
+	delete(m.objects, m.objectKey(srcBucket, srcKey))
+	moved := *o
+	moved.Bucket, moved.Key = dstBucket, dstKey
+	m.objects[m.objectKey(dstBucket, dstKey)] = &moved
+	if tags, ok := m.objectTags[srcBucket+"/"+srcKey]; ok {
+		delete(m.objectTags, srcBucket+"/"+srcKey)
+		m.objectTags[dstBucket+"/"+dstKey] = tags
+	}
+	return nil
+}
+func (m *MockMetadataStore) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[m.objectKey(bucket, key)]; !ok {
+		return metadata.ErrObjectNotFound
+	}
+	m.objects[m.objectKey(bucket, key)] = meta
+	return nil
+}
 
 func (m *MockMetadataStore) ListObjects(ctx context.Context, bucket, prefix string, opts metadata.ListOptions) ([]metadata.ObjectMetadata, error) {
 	m.mu.RLock()
@@ -237,18 +300,65 @@ func (m *MockMetadataStore) ListObjects(ctx context.Context, bucket, prefix stri
 	return objects, nil
 }
 
+func (m *MockMetadataStore) ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(metadata.ObjectMetadata) error) error {
+	objects, _ := m.ListObjects(ctx, bucket, prefix, metadata.ListOptions{})
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	for _, obj := range objects {
+		if obj.Key <= marker {
+			continue
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockMetadataStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]metadata.ObjectMetadata, error) {
+	return m.ListObjects(ctx, bucket, prefix, metadata.ListOptions{})
+}
+
+func (m *MockMetadataStore) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	objects, err := m.ListObjects(ctx, bucket, "", metadata.ListOptions{})
+	return len(objects) == 0, err
+}
+
 func (m *MockMetadataStore) Close() error {
 	return nil
 }
 
+func (m *MockMetadataStore) Compact(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockMetadataStore) Stats() (*metadata.Stats, error) {
+	return &metadata.Stats{}, nil
+}
+
+func (m *MockMetadataStore) PutIAMEntity(ctx context.Context, kind, id string, data []byte) error {
+	return nil
+}
+
+func (m *MockMetadataStore) DeleteIAMEntity(ctx context.Context, kind, id string) error {
+	return nil
+}
+
+func (m *MockMetadataStore) ListIAMEntities(ctx context.Context, kind string) ([][]byte, error) {
+	return nil, nil
+}
+
 // Stub methods to satisfy interface
 func (m *MockMetadataStore) CreateMultipartUpload(ctx context.Context, bucket, key, uploadID string, meta *metadata.ObjectMetadata) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.uploads[bucket] = append(m.uploads[bucket], metadata.MultipartUploadMetadata{
-		UploadID: uploadID,
-		Key:      key,
-		Bucket:   bucket,
+		UploadID:     uploadID,
+		Key:          key,
+		Bucket:       bucket,
+		Initiated:    time.Now().Unix(),
+		Metadata:     meta.Metadata,
+		StorageClass: meta.StorageClass,
+		Checksum:     meta.Checksum,
 	})
 	return nil
 }
@@ -268,7 +378,13 @@ func (m *MockMetadataStore) CompleteMultipartUpload(ctx context.Context, bucket,
 func (m *MockMetadataStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.uploads, bucket)
+	var remaining []metadata.MultipartUploadMetadata
+	for _, u := range m.uploads[bucket] {
+		if u.UploadID != uploadID {
+			remaining = append(remaining, u)
+		}
+	}
+	m.uploads[bucket] = remaining
 	delete(m.parts, bucket+":"+uploadID)
 	return nil
 }
@@ -404,6 +520,33 @@ func (m *MockMetadataStore) DeleteBucketTags(ctx context.Context, bucket string)
 	delete(m.tags, bucket)
 	return nil
 }
+
+// objectEntryKey keys the tags, retention and legal hold of an object or of
+// one of its versions
+func objectEntryKey(bucket, key, versionID string) string {
+	if versionID == "" {
+		return bucket + "/" + key
+	}
+	return bucket + "/" + key + "\x00" + versionID
+}
+
+func (m *MockMetadataStore) PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objectTags[objectEntryKey(bucket, key, versionID)] = tags
+	return nil
+}
+func (m *MockMetadataStore) GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.objectTags[objectEntryKey(bucket, key, versionID)], nil
+}
+func (m *MockMetadataStore) DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objectTags, bucket+"/"+key)
+	return nil
+}
 func (m *MockMetadataStore) PutObjectLock(ctx context.Context, bucket string, config *metadata.ObjectLockConfig) error {
 	return nil
 }
@@ -413,25 +556,59 @@ func (m *MockMetadataStore) GetObjectLock(ctx context.Context, bucket string) (*
 func (m *MockMetadataStore) DeleteObjectLock(ctx context.Context, bucket string) error {
 	return nil
 }
-func (m *MockMetadataStore) PutObjectRetention(ctx context.Context, bucket, key string, retention *metadata.ObjectRetention) error {
+func (m *MockMetadataStore) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error {
 	return nil
 }
-func (m *MockMetadataStore) GetObjectRetention(ctx context.Context, bucket, key string) (*metadata.ObjectRetention, error) {
+func (m *MockMetadataStore) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
 	return nil, nil
 }
-func (m *MockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *metadata.ObjectLegalHold) error {
+func (m *MockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
 	return nil
 }
-func (m *MockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key string) (*metadata.ObjectLegalHold, error) {
+func (m *MockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
 	return nil, nil
 }
 func (m *MockMetadataStore) PutPublicAccessBlock(ctx context.Context, bucket string, config *metadata.PublicAccessBlockConfiguration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publicAccess[bucket] = config
 	return nil
 }
 func (m *MockMetadataStore) GetPublicAccessBlock(ctx context.Context, bucket string) (*metadata.PublicAccessBlockConfiguration, error) {
-	return nil, nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.publicAccess[bucket], nil
 }
 func (m *MockMetadataStore) DeletePublicAccessBlock(ctx context.Context, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.publicAccess, bucket)
+	return nil
+}
+func (m *MockMetadataStore) PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error {
+	return m.PutObjectACL(ctx, bucket, "", acl)
+}
+func (m *MockMetadataStore) GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error) {
+	return m.GetObjectACL(ctx, bucket, "")
+}
+func (m *MockMetadataStore) DeleteBucketACL(ctx context.Context, bucket string) error {
+	return m.DeleteObjectACL(ctx, bucket, "")
+}
+func (m *MockMetadataStore) PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acls[bucket+"/"+key] = acl
+	return nil
+}
+func (m *MockMetadataStore) GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.acls[bucket+"/"+key], nil
+}
+func (m *MockMetadataStore) DeleteObjectACL(ctx context.Context, bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.acls, bucket+"/"+key)
 	return nil
 }
 func (m *MockMetadataStore) PutBucketAccelerate(ctx context.Context, bucket string, config *metadata.BucketAccelerateConfiguration) error {
@@ -443,6 +620,41 @@ func (m *MockMetadataStore) GetBucketAccelerate(ctx context.Context, bucket stri
 func (m *MockMetadataStore) DeleteBucketAccelerate(ctx context.Context, bucket string) error {
 	return nil
 }
+func (m *MockMetadataStore) PutBucketKeyNormalization(ctx context.Context, bucket string, config *metadata.KeyNormalizationConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyNormalization[bucket] = config
+	return nil
+}
+func (m *MockMetadataStore) GetBucketKeyNormalization(ctx context.Context, bucket string) (*metadata.KeyNormalizationConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.keyNormalization[bucket], nil
+}
+func (m *MockMetadataStore) PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *mode
+	m.modes[bucket] = &stored
+	return nil
+}
+func (m *MockMetadataStore) GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.modes[bucket], nil
+}
+func (m *MockMetadataStore) PutBucketUsage(ctx context.Context, bucket string, usage *metadata.BucketUsage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *usage
+	m.usage[bucket] = &stored
+	return nil
+}
+func (m *MockMetadataStore) GetBucketUsage(ctx context.Context, bucket string) (*metadata.BucketUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.usage[bucket], nil
+}
 func (m *MockMetadataStore) PutBucketInventory(ctx context.Context, bucket, id string, config *metadata.InventoryConfiguration) error {
 	return nil
 }
@@ -595,6 +807,60 @@ func TestObjectService_PutObject(t *testing.T) {
 	}
 }
 
+func TestObjectService_PutObject_StandardHeaders(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+
+	opts := PutObjectOptions{
+		ContentType:        "text/csv",
+		ContentEncoding:    "gzip",
+		CacheControl:       "no-cache",
+		ContentDisposition: "attachment",
+		Metadata:           map[string]string{"owner": "finance"},
+	}
+	if _, err := svc.PutObject(ctx, "test-bucket", "report.csv", bytes.NewReader([]byte("data")), opts); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+
+	info, err := svc.HeadObject(ctx, "test-bucket", "report.csv")
+	if err != nil {
+		t.Fatalf("HeadObject() error = %v", err)
+	}
+	if info.ContentEncoding != "gzip" || info.CacheControl != "no-cache" || info.ContentDisposition != "attachment" || info.Metadata["owner"] != "finance" {
+		t.Errorf("HeadObject() = %+v, want the headers it was stored with", info)
+	}
+
+	obj, err := svc.GetObject(ctx, "test-bucket", "report.csv", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	obj.Body.Close()
+	if obj.CacheControl != "no-cache" || obj.ContentDisposition != "attachment" {
+		t.Errorf("GetObject() CacheControl = %q, ContentDisposition = %q", obj.CacheControl, obj.ContentDisposition)
+	}
+}
+
+func TestObjectService_PutObject_MetadataTooLarge(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+
+	big := PutObjectOptions{Metadata: map[string]string{
+		"a": strings.Repeat("x", MaxUserMetadataSize/2),
+		"b": strings.Repeat("x", MaxUserMetadataSize/2),
+	}}
+	if _, err := svc.PutObject(ctx, "test-bucket", "key", bytes.NewReader([]byte("data")), big); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Errorf("PutObject() error = %v, want ErrMetadataTooLarge", err)
+	}
+	if _, err := svc.CreateMultipartUpload(ctx, "test-bucket", "key", big); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Errorf("CreateMultipartUpload() error = %v, want ErrMetadataTooLarge", err)
+	}
+	if _, err := svc.HeadObject(ctx, "test-bucket", "key"); err == nil {
+		t.Error("HeadObject() found an object rejected for its metadata")
+	}
+}
+
 func TestObjectService_PutObject_BucketNotFound(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -724,7 +990,7 @@ func TestObjectService_DeleteObject(t *testing.T) {
 		t.Fatalf("PutObject() error = %v", err)
 	}
 
-	err = svc.DeleteObject(ctx, "test-bucket", "test-key", DeleteObjectOptions{})
+	_, err = svc.DeleteObject(ctx, "test-bucket", "test-key", DeleteObjectOptions{})
 	if err != nil {
 		t.Fatalf("DeleteObject() error = %v", err)
 	}
@@ -737,7 +1003,7 @@ func TestObjectService_DeleteObject_BucketNotFound(t *testing.T) {
 
 	svc := New(storage, meta, logger)
 
-	err := svc.DeleteObject(context.Background(), "nonexistent", "key", DeleteObjectOptions{})
+	_, err := svc.DeleteObject(context.Background(), "nonexistent", "key", DeleteObjectOptions{})
 	if err == nil {
 		t.Error("DeleteObject() should fail for nonexistent bucket")
 	}
@@ -1275,6 +1541,43 @@ func TestObjectService_ListParts(t *testing.T) {
 	_ = result
 }
 
+func TestObjectService_ListPartsWithOptions(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	upload, err := svc.CreateMultipartUpload(ctx, "test-bucket", "test-key", PutObjectOptions{StorageClass: "STANDARD_IA"})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	for n := 1; n <= 5; n++ {
+		if _, err := svc.UploadPart(ctx, "test-bucket", "test-key", upload.UploadID, n, bytes.NewReader([]byte("part data"))); err != nil {
+			t.Fatalf("UploadPart(%d) error = %v", n, err)
+		}
+	}
+
+	result, err := svc.ListPartsWithOptions(ctx, "test-bucket", "test-key", upload.UploadID, ListPartsOptions{MaxParts: 2, PartNumberMarker: 1})
+	if err != nil {
+		t.Fatalf("ListPartsWithOptions() error = %v", err)
+	}
+	if len(result.Parts) != 2 || result.Parts[0].PartNumber != 2 || !result.IsTruncated || result.NextPartNumberMarker != 3 {
+		t.Errorf("ListPartsWithOptions() = %+v, want parts 2-3, truncated", result)
+	}
+	if p := result.Parts[0]; p.Size != 9 || p.LastModified == 0 {
+		t.Errorf("part = %+v, want Size 9 and a LastModified", p)
+	}
+	if result.StorageClass != "STANDARD_IA" {
+		t.Errorf("StorageClass = %q, want STANDARD_IA", result.StorageClass)
+	}
+
+	result, err = svc.ListPartsWithOptions(ctx, "test-bucket", "test-key", upload.UploadID, ListPartsOptions{PartNumberMarker: 3})
+	if err != nil {
+		t.Fatalf("ListPartsWithOptions() error = %v", err)
+	}
+	if len(result.Parts) != 2 || result.IsTruncated || result.MaxParts != MaxListParts {
+		t.Errorf("ListPartsWithOptions() after part 3 = %+v, want the last 2 parts", result)
+	}
+}
+
 func TestObjectService_ObjectLock(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -1311,13 +1614,13 @@ func TestObjectService_ObjectRetention(t *testing.T) {
 
 	svc := New(storage, meta, logger)
 
-	retention := &metadata.ObjectRetention{Mode: "GOVERNANCE"}
-	err := svc.PutObjectRetention(ctx, "test-bucket", "test-key", retention)
+	retention := &metadata.ObjectRetention{Mode: "GOVERNANCE", RetainUntilDate: time.Now().Add(time.Hour).Unix()}
+	err := svc.PutObjectRetention(ctx, "test-bucket", "test-key", "", retention, RetentionOptions{})
 	if err != nil {
 		t.Fatalf("PutObjectRetention() error = %v", err)
 	}
 
-	result, err := svc.GetObjectRetention(ctx, "test-bucket", "test-key")
+	result, err := svc.GetObjectRetention(ctx, "test-bucket", "test-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectRetention() error = %v", err)
 	}
@@ -1332,14 +1635,16 @@ func TestObjectService_ObjectLegalHold(t *testing.T) {
 	ctx := context.Background()
 
 	svc := New(storage, meta, logger)
+	svc.CreateBucket(ctx, "test-bucket")
+	svc.PutObject(ctx, "test-bucket", "test-key", bytes.NewReader([]byte("data")), PutObjectOptions{})
 
 	legalHold := &metadata.ObjectLegalHold{Status: "ON"}
-	err := svc.PutObjectLegalHold(ctx, "test-bucket", "test-key", legalHold)
+	err := svc.PutObjectLegalHold(ctx, "test-bucket", "test-key", "", legalHold)
 	if err != nil {
 		t.Fatalf("PutObjectLegalHold() error = %v", err)
 	}
 
-	result, err := svc.GetObjectLegalHold(ctx, "test-bucket", "test-key")
+	result, err := svc.GetObjectLegalHold(ctx, "test-bucket", "test-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectLegalHold() error = %v", err)
 	}
@@ -1633,6 +1938,16 @@ func TestObjectService_BucketMetrics(t *testing.T) {
 	}
 }
 
+// enablePresign lets svc sign presigned URLs with the test-key credential
+func enablePresign(t *testing.T, svc *ObjectService) *auth.Auth {
+	t.Helper()
+	signer := auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"})
+	if err := svc.SetPresigner(signer, "http://localhost:8080/s3"); err != nil {
+		t.Fatalf("SetPresigner() error = %v", err)
+	}
+	return signer
+}
+
 func TestObjectService_PresignedURL(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -1641,12 +1956,27 @@ func TestObjectService_PresignedURL(t *testing.T) {
 	ctx := context.Background()
 
 	svc := New(storage, meta, logger)
+	signer := enablePresign(t, svc)
 
-	url, err := svc.GeneratePresignedURL(ctx, "test-bucket", "test-key", "GET", 3600)
+	url, err := svc.GeneratePresignedURL(ctx, "test-key", "test-bucket", "dir/test key.txt", "GET", 3600)
 	if err != nil {
 		t.Fatalf("GeneratePresignedURL() error = %v", err)
 	}
-	_ = url
+	for _, param := range []string{"X-Amz-Algorithm", "X-Amz-Credential", "X-Amz-Date", "X-Amz-Expires", "X-Amz-SignedHeaders", "X-Amz-Signature"} {
+		if !strings.Contains(url, param+"=") {
+			t.Errorf("presigned URL %s is missing %s", url, param)
+		}
+	}
+
+	// The URL must verify as a SigV4 presigned request against the same credentials
+	req, _ := http.NewRequest("GET", url, nil)
+	bucket, key, err := signer.VerifyPresignedURL(req)
+	if err != nil {
+		t.Fatalf("VerifyPresignedURL(%s) error = %v", url, err)
+	}
+	if bucket != "test-bucket" || key != "dir/test key.txt" {
+		t.Errorf("VerifyPresignedURL() = %q, %q; want test-bucket, \"dir/test key.txt\"", bucket, key)
+	}
 
 	result, err := svc.ValidatePresignedURL(ctx, "http://example.com/signed-url")
 	if err != nil {
@@ -1660,6 +1990,23 @@ func TestObjectService_PresignedURL(t *testing.T) {
 	}
 }
 
+func TestObjectService_GeneratePresignedURL_NotEnabled(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+
+	if _, err := svc.GeneratePresignedURL(context.Background(), "test-key", "bucket", "key", "GET", 3600); err == nil {
+		t.Error("GeneratePresignedURL() should fail without a presigner")
+	}
+}
+
+func TestObjectService_GeneratePresignedURL_UnknownAccessKey(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	enablePresign(t, svc)
+
+	if _, err := svc.GeneratePresignedURL(context.Background(), "other-key", "bucket", "key", "GET", 3600); err == nil {
+		t.Error("GeneratePresignedURL() should fail for an unknown access key")
+	}
+}
+
 func TestObjectService_CopyObject(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -1678,7 +2025,7 @@ func TestObjectService_CopyObject(t *testing.T) {
 		t.Fatalf("PutObject() error = %v", err)
 	}
 
-	result, err := svc.CopyObject(ctx, "src-bucket", "src-key", "dst-bucket", "dst-key")
+	result, err := svc.CopyObject(ctx, "src-bucket", "src-key", "dst-bucket", "dst-key", CopyObjectOptions{})
 	if err != nil {
 		t.Fatalf("CopyObject() error = %v", err)
 	}
@@ -1687,6 +2034,70 @@ func TestObjectService_CopyObject(t *testing.T) {
 	}
 }
 
+func TestObjectService_CopyObject_MetadataDirective(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("data")), PutObjectOptions{
+		ContentType:  "text/plain",
+		CacheControl: "no-cache",
+		Metadata:     map[string]string{"origin": "source"},
+	})
+
+	if _, err := svc.CopyObject(ctx, "bucket", "src", "bucket", "copied", CopyObjectOptions{
+		ContentType: "ignored/type",
+		Metadata:    map[string]string{"ignored": "yes"},
+	}); err != nil {
+		t.Fatalf("CopyObject(COPY) error = %v", err)
+	}
+	info, _ := svc.HeadObject(ctx, "bucket", "copied")
+	if info.ContentType != "text/plain" || info.CacheControl != "no-cache" || info.Metadata["origin"] != "source" || info.Metadata["ignored"] != "" {
+		t.Errorf("COPY destination = %+v, want the source's metadata", info)
+	}
+
+	if _, err := svc.CopyObject(ctx, "bucket", "src", "bucket", "replaced", CopyObjectOptions{
+		MetadataDirective: MetadataDirectiveReplace,
+		ContentType:       "application/json",
+		CacheControl:      "max-age=60",
+		Metadata:          map[string]string{"origin": "copy"},
+	}); err != nil {
+		t.Fatalf("CopyObject(REPLACE) error = %v", err)
+	}
+	info, _ = svc.HeadObject(ctx, "bucket", "replaced")
+	if info.ContentType != "application/json" || info.CacheControl != "max-age=60" || info.Metadata["origin"] != "copy" {
+		t.Errorf("REPLACE destination = %+v, want the request's metadata", info)
+	}
+}
+
+func TestObjectService_CopyObject_Preconditions(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	put, _ := svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("data")), PutObjectOptions{})
+
+	tests := []struct {
+		name    string
+		opts    CopyObjectOptions
+		wantErr bool
+	}{
+		{"if-match hit", CopyObjectOptions{IfMatch: put.ETag}, false},
+		{"if-match miss", CopyObjectOptions{IfMatch: `"other"`}, true},
+		{"if-none-match hit", CopyObjectOptions{IfNoneMatch: put.ETag}, true},
+		{"if-none-match miss", CopyObjectOptions{IfNoneMatch: `"other"`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CopyObject(ctx, "bucket", "src", "bucket", "dst", tt.opts)
+			if tt.wantErr && !errors.Is(err, ErrPreconditionFailed) {
+				t.Errorf("CopyObject() error = %v, want ErrPreconditionFailed", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CopyObject() error = %v", err)
+			}
+		})
+	}
+}
+
 func TestObjectService_CopyObject_SourceBucketNotFound(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -1694,7 +2105,7 @@ func TestObjectService_CopyObject_SourceBucketNotFound(t *testing.T) {
 
 	svc := New(storage, meta, logger)
 
-	_, err := svc.CopyObject(context.Background(), "nonexistent", "src-key", "dst-bucket", "dst-key")
+	_, err := svc.CopyObject(context.Background(), "nonexistent", "src-key", "dst-bucket", "dst-key", CopyObjectOptions{})
 	if err == nil {
 		t.Error("CopyObject() should fail for nonexistent source bucket")
 	}
@@ -1710,7 +2121,7 @@ func TestObjectService_CopyObject_DestBucketNotFound(t *testing.T) {
 
 	svc := New(storage, meta, logger)
 
-	_, err := svc.CopyObject(ctx, "src-bucket", "src-key", "nonexistent", "dst-key")
+	_, err := svc.CopyObject(ctx, "src-bucket", "src-key", "nonexistent", "dst-key", CopyObjectOptions{})
 	if err == nil {
 		t.Error("CopyObject() should fail for nonexistent destination bucket")
 	}
@@ -1772,12 +2183,12 @@ func TestObjectService_CompleteMultipartUpload(t *testing.T) {
 		t.Fatalf("CreateMultipartUpload() error = %v", err)
 	}
 
+	// Listing a part that was never uploaded is rejected
 	parts := []PartInfo{{PartNumber: 1, ETag: "etag1"}}
-	result, err := svc.CompleteMultipartUpload(ctx, "test-bucket", "test-key", uploadResult.UploadID, parts)
-	if err != nil {
-		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	_, err = svc.CompleteMultipartUpload(ctx, "test-bucket", "test-key", uploadResult.UploadID, parts)
+	if !errors.Is(err, ErrInvalidPart) {
+		t.Fatalf("CompleteMultipartUpload() error = %v, want ErrInvalidPart", err)
 	}
-	_ = result
 }
 
 func TestObjectService_SelectObjectContent(t *testing.T) {
@@ -1797,13 +2208,16 @@ func TestObjectService_SelectObjectContent(t *testing.T) {
 		t.Fatalf("PutObject() error = %v", err)
 	}
 
-	result, err := svc.SelectObjectContent(ctx, "test-bucket", "test.csv", "SELECT * FROM s3object")
+	result, err := svc.SelectObjectContent(ctx, "test-bucket", "test.csv", "SELECT s.name FROM s3object s WHERE s.age > 28")
 	if err != nil {
 		t.Fatalf("SelectObjectContent() error = %v", err)
 	}
 	if result == nil {
 		t.Fatal("SelectObjectContent() returned nil")
 	}
+	if result.Body != "john\n" {
+		t.Errorf("SelectObjectContent() body = %q, want %q", result.Body, "john\n")
+	}
 }
 
 func TestObjectService_SelectObjectContent_BucketNotFound(t *testing.T) {
@@ -1862,6 +2276,104 @@ func TestObjectService_UploadPart(t *testing.T) {
 	}
 }
 
+func TestObjectService_UploadPart_PartNumberLimit(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	uploadResult, err := svc.CreateMultipartUpload(ctx, "test-bucket", "test-key", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	for _, n := range []int{0, -1, MaxPartNumber + 1} {
+		_, err := svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, n, bytes.NewReader([]byte("part data")))
+		if !errors.Is(err, ErrInvalidPartNumber) {
+			t.Errorf("UploadPart(part %d) error = %v, want ErrInvalidPartNumber", n, err)
+		}
+	}
+	if _, err := svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, MaxPartNumber, bytes.NewReader([]byte("part data"))); err != nil {
+		t.Errorf("UploadPart(part %d) error = %v", MaxPartNumber, err)
+	}
+
+	parts := make([]PartInfo, MaxPartNumber+1)
+	for i := range parts {
+		parts[i] = PartInfo{PartNumber: i + 1}
+	}
+	if _, err := svc.CompleteMultipartUpload(ctx, "test-bucket", "test-key", uploadResult.UploadID, parts); !errors.Is(err, ErrTooManyParts) {
+		t.Errorf("CompleteMultipartUpload() with %d parts error = %v, want ErrTooManyParts", len(parts), err)
+	}
+}
+
+func TestObjectService_UploadPartCopy(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	svc.CreateBucket(ctx, "test-bucket")
+	if _, err := svc.PutObject(ctx, "test-bucket", "source", bytes.NewReader([]byte("0123456789")), PutObjectOptions{}); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	upload, err := svc.CreateMultipartUpload(ctx, "test-bucket", "dest", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	result, err := svc.UploadPartCopy(ctx, "test-bucket", "source", "test-bucket", "dest", upload.UploadID, 1, UploadPartCopyOptions{})
+	if err != nil {
+		t.Fatalf("UploadPartCopy() error = %v", err)
+	}
+	// As for UploadPart, the part ETag is the MD5 of the copied bytes
+	wantETag := fmt.Sprintf("\"%x\"", md5.Sum([]byte("0123456789")))
+	if result.ETag != wantETag || result.Size != 10 || result.LastModified == 0 {
+		t.Errorf("UploadPartCopy() = %+v, want ETag %s and size 10", result, wantETag)
+	}
+
+	if _, err := svc.CompleteMultipartUpload(ctx, "test-bucket", "dest", upload.UploadID, []PartInfo{{PartNumber: 1, ETag: result.ETag}}); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	obj, err := svc.GetObject(ctx, "test-bucket", "dest", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	if body, _ := io.ReadAll(obj.Body); string(body) != "0123456789" {
+		t.Errorf("copied object = %q, want %q", body, "0123456789")
+	}
+}
+
+func TestObjectService_UploadPartCopy_Errors(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	svc.CreateBucket(ctx, "test-bucket")
+	src, err := svc.PutObject(ctx, "test-bucket", "source", bytes.NewReader([]byte("0123456789")), PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	upload, err := svc.CreateMultipartUpload(ctx, "test-bucket", "dest", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		srcKey   string
+		uploadID string
+		opts     UploadPartCopyOptions
+		want     error
+	}{
+		{"missing source", "missing", upload.UploadID, UploadPartCopyOptions{}, ErrObjectNotFound},
+		{"range past end", "source", upload.UploadID, UploadPartCopyOptions{Range: &storage.Range{Start: 5, End: 11}}, ErrInvalidCopyRange},
+		{"empty range", "source", upload.UploadID, UploadPartCopyOptions{Range: &storage.Range{Start: 5, End: 5}}, ErrInvalidCopyRange},
+		{"if-match", "source", upload.UploadID, UploadPartCopyOptions{IfMatch: `"other"`}, ErrPreconditionFailed},
+		{"if-none-match", "source", upload.UploadID, UploadPartCopyOptions{IfNoneMatch: src.ETag}, ErrPreconditionFailed},
+	}
+	for _, tt := range tests {
+		_, err := svc.UploadPartCopy(ctx, "test-bucket", tt.srcKey, "test-bucket", "dest", tt.uploadID, 1, tt.opts)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: UploadPartCopy() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
 func TestObjectService_PutPart(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -1890,27 +2402,30 @@ func TestObjectService_CompleteMultipartUploadWithParts(t *testing.T) {
 	ctx := context.Background()
 
 	svc := New(storage, meta, logger)
+	svc.minPartSize = 0
 
-	uploadResult, err := svc.CreateMultipartUpload(ctx, "test-bucket", "test-key", PutObjectOptions{})
+	uploadResult, err := svc.CreateMultipartUpload(ctx, "test-bucket", "test-key", PutObjectOptions{
+		Metadata: map[string]string{"owner": "alice"},
+	})
 	if err != nil {
 		t.Fatalf("CreateMultipartUpload() error = %v", err)
 	}
 
 	data1 := bytes.NewReader([]byte("part one "))
-	_, err = svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, 1, data1)
+	part1, err := svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, 1, data1)
 	if err != nil {
 		t.Fatalf("UploadPart(1) error = %v", err)
 	}
 
 	data2 := bytes.NewReader([]byte("part two"))
-	_, err = svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, 2, data2)
+	part2, err := svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, 2, data2)
 	if err != nil {
 		t.Fatalf("UploadPart(2) error = %v", err)
 	}
 
 	parts := []PartInfo{
-		{PartNumber: 1, ETag: "etag1"},
-		{PartNumber: 2, ETag: "etag2"},
+		{PartNumber: 1, ETag: part1.ETag},
+		{PartNumber: 2, ETag: part2.ETag},
 	}
 	result, err := svc.CompleteMultipartUpload(ctx, "test-bucket", "test-key", uploadResult.UploadID, parts)
 	if err != nil {
@@ -1922,6 +2437,110 @@ func TestObjectService_CompleteMultipartUploadWithParts(t *testing.T) {
 	if result.Size != 17 {
 		t.Errorf("CompleteMultipartUpload() Size = %d, want 17", result.Size)
 	}
+	// User metadata given when the upload was created is kept on the object
+	if obj, _ := meta.GetObject(ctx, "test-bucket", "test-key", ""); obj == nil || obj.Metadata["owner"] != "alice" {
+		t.Errorf("object metadata = %v, want owner=alice", obj)
+	}
+}
+
+func TestObjectService_CompleteMultipartUpload_PartOrder(t *testing.T) {
+	storage := NewMockStorageBackend()
+	meta := NewMockMetadataStore()
+	svc := New(storage, meta, zap.NewNop().Sugar())
+	svc.minPartSize = 0
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+
+	uploadResult, err := svc.CreateMultipartUpload(ctx, "test-bucket", "test-key", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	// Upload out of order so the store enumerates parts unsorted
+	for _, n := range []int{12, 3, 10, 1, 7, 2, 11, 5, 9, 4, 8, 6} {
+		data := bytes.NewReader([]byte(fmt.Sprintf("[%d]", n)))
+		if _, err := svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, n, data); err != nil {
+			t.Fatalf("UploadPart(%d) error = %v", n, err)
+		}
+	}
+
+	listed, err := svc.ListParts(ctx, "test-bucket", "test-key", uploadResult.UploadID)
+	if err != nil {
+		t.Fatalf("ListParts() error = %v", err)
+	}
+	var parts []PartInfo
+	var want string
+	for i, part := range listed {
+		if part.PartNumber != i+1 {
+			t.Errorf("ListParts()[%d].PartNumber = %d, want %d", i, part.PartNumber, i+1)
+		}
+		parts = append(parts, part)
+		want += fmt.Sprintf("[%d]", i+1)
+	}
+
+	if _, err := svc.CompleteMultipartUpload(ctx, "test-bucket", "test-key", uploadResult.UploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+
+	obj, err := svc.GetObject(ctx, "test-bucket", "test-key", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	got, _ := io.ReadAll(obj.Body)
+	if string(got) != want {
+		t.Errorf("completed object = %q, want %q", got, want)
+	}
+}
+
+func TestObjectService_CompleteMultipartUpload_ETag(t *testing.T) {
+	storage := NewMockStorageBackend()
+	meta := NewMockMetadataStore()
+	svc := New(storage, meta, zap.NewNop().Sugar())
+	svc.minPartSize = 0
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+
+	uploadResult, err := svc.CreateMultipartUpload(ctx, "test-bucket", "test-key", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+
+	partData := []string{"first part", "second part", "third part"}
+	var parts []PartInfo
+	var digests []byte
+	for i, data := range partData {
+		result, err := svc.UploadPart(ctx, "test-bucket", "test-key", uploadResult.UploadID, i+1, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("UploadPart(%d) error = %v", i+1, err)
+		}
+		parts = append(parts, PartInfo{PartNumber: i + 1, ETag: result.ETag})
+		sum := md5.Sum([]byte(data))
+		digests = append(digests, sum[:]...)
+		if wantPart := fmt.Sprintf("\"%s\"", hex.EncodeToString(sum[:])); result.ETag != wantPart {
+			t.Errorf("UploadPart(%d) ETag = %s, want the part MD5 %s", i+1, result.ETag, wantPart)
+		}
+	}
+
+	result, err := svc.CompleteMultipartUpload(ctx, "test-bucket", "test-key", uploadResult.UploadID, parts)
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	sum := md5.Sum(digests)
+	want := fmt.Sprintf("\"%s-3\"", hex.EncodeToString(sum[:]))
+	if result.ETag != want {
+		t.Errorf("ETag = %s, want %s", result.ETag, want)
+	}
+
+	// The ETag is not a content hash, so integrity checks must not use it
+	obj, err := svc.GetObject(ctx, "test-bucket", "test-key", GetObjectOptions{VerifyIntegrity: true})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	if _, err := io.ReadAll(obj.Body); err != nil {
+		t.Errorf("reading completed object: %v", err)
+	}
 }
 
 func TestObjectService_CompleteMultipartUpload_NoParts(t *testing.T) {
@@ -1969,6 +2588,50 @@ func TestObjectService_DeleteBucketWithObjects(t *testing.T) {
 	}
 }
 
+func TestObjectService_DeleteBucketWithMetadataOnlyObject(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+
+	// The storage holds no data, but the bucket still has an object
+	meta.PutObject(ctx, "test-bucket", "marker", &metadata.ObjectMetadata{Key: "marker", VersionID: "dm", IsDeleteMarker: true})
+
+	if err := svc.DeleteBucket(ctx, "test-bucket"); !errors.Is(err, ErrBucketNotEmpty) {
+		t.Errorf("DeleteBucket() error = %v, want %v", err, ErrBucketNotEmpty)
+	}
+}
+
+func TestLocker_LockBucket(t *testing.T) {
+	l := NewLocker()
+	defer l.Stop()
+
+	unlockObject := l.Lock("test-bucket", "key")
+	locked := make(chan struct{})
+	go func() {
+		unlock := l.LockBucket("test-bucket")
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("LockBucket() returned while an object write was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Other buckets are not held up
+	l.Lock("other-bucket", "key")()
+
+	unlockObject()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("LockBucket() did not return once the object was unlocked")
+	}
+}
+
 func TestObjectService_CreateBucketAlreadyExists(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -1984,8 +2647,14 @@ func TestObjectService_CreateBucketAlreadyExists(t *testing.T) {
 	}
 
 	err = svc.CreateBucket(ctx, "test-bucket")
-	if err != nil {
-		t.Logf("CreateBucket() second call: %v", err)
+	if !errors.Is(err, ErrBucketOwnedByYou) {
+		t.Errorf("CreateBucket() second call error = %v, want ErrBucketOwnedByYou", err)
+	}
+
+	meta.buckets["other-bucket"] = &metadata.BucketMetadata{Name: "other-bucket", Owner: "someone-else"}
+	err = svc.CreateBucket(ctx, "other-bucket")
+	if !errors.Is(err, ErrBucketExists) {
+		t.Errorf("CreateBucket() of another owner's bucket error = %v, want ErrBucketExists", err)
 	}
 }
 
@@ -1995,13 +2664,14 @@ func TestObjectService_GeneratePresignedURLErrors(t *testing.T) {
 	logger := zap.NewNop().Sugar()
 
 	svc := New(storage, meta, logger)
+	enablePresign(t, svc)
 
-	_, err := svc.GeneratePresignedURL(context.Background(), "", "key", "GET", 3600)
+	_, err := svc.GeneratePresignedURL(context.Background(), "test-key", "", "key", "GET", 3600)
 	if err == nil {
 		t.Error("GeneratePresignedURL() should fail with empty bucket")
 	}
 
-	_, err = svc.GeneratePresignedURL(context.Background(), "bucket", "", "GET", 3600)
+	_, err = svc.GeneratePresignedURL(context.Background(), "test-key", "bucket", "", "GET", 3600)
 	if err == nil {
 		t.Error("GeneratePresignedURL() should fail with empty key")
 	}
@@ -2072,7 +2742,7 @@ func TestObjectService_DeleteObjectWithVersion(t *testing.T) {
 		t.Fatalf("PutObject() error = %v", err)
 	}
 
-	err = svc.DeleteObject(ctx, "test-bucket", "test-key", DeleteObjectOptions{VersionID: "version-123"})
+	_, err = svc.DeleteObject(ctx, "test-bucket", "test-key", DeleteObjectOptions{VersionID: "version-123"})
 	if err != nil {
 		t.Fatalf("DeleteObject() error = %v", err)
 	}
@@ -2104,6 +2774,93 @@ func TestObjectService_ListObjectsWithDelimiter(t *testing.T) {
 	_ = result
 }
 
+func TestObjectService_ListObjects_DelimiterRollup(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+	for _, key := range []string{"a/b/c.txt", "a/b/d.txt", "a/e.txt", "a/f/g.txt", "a/h.txt", "b/1.txt", "top.txt"} {
+		if _, err := svc.PutObject(ctx, "test-bucket", key, bytes.NewReader([]byte("test")), PutObjectOptions{}); err != nil {
+			t.Fatalf("PutObject(%s) error = %v", key, err)
+		}
+	}
+
+	keys := func(result *ListObjectsResult) []string {
+		var out []string
+		for _, obj := range result.Objects {
+			out = append(out, obj.Key)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name         string
+		opts         ListObjectsOptions
+		wantKeys     []string
+		wantPrefixes []string
+		wantNext     string
+		truncated    bool
+	}{
+		{
+			name:         "root",
+			opts:         ListObjectsOptions{Delimiter: "/"},
+			wantKeys:     []string{"top.txt"},
+			wantPrefixes: []string{"a/", "b/"},
+		},
+		{
+			name:         "nested",
+			opts:         ListObjectsOptions{Prefix: "a/", Delimiter: "/"},
+			wantKeys:     []string{"a/e.txt", "a/h.txt"},
+			wantPrefixes: []string{"a/b/", "a/f/"},
+		},
+		{
+			name:     "no delimiter",
+			opts:     ListObjectsOptions{Prefix: "a/b/"},
+			wantKeys: []string{"a/b/c.txt", "a/b/d.txt"},
+		},
+		{
+			name:         "prefixes count towards max keys",
+			opts:         ListObjectsOptions{Prefix: "a/", Delimiter: "/", MaxKeys: 2},
+			wantPrefixes: []string{"a/b/"},
+			wantKeys:     []string{"a/e.txt"},
+			wantNext:     "a/e.txt",
+			truncated:    true,
+		},
+		{
+			name:         "page ending on a prefix",
+			opts:         ListObjectsOptions{Prefix: "a/", Delimiter: "/", MaxKeys: 3},
+			wantKeys:     []string{"a/e.txt"},
+			wantPrefixes: []string{"a/b/", "a/f/"},
+			wantNext:     "a/f/",
+			truncated:    true,
+		},
+		{
+			name:     "marker on a prefix skips its keys",
+			opts:     ListObjectsOptions{Prefix: "a/", Delimiter: "/", Marker: "a/f/"},
+			wantKeys: []string{"a/h.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.ListObjects(ctx, "test-bucket", tt.opts)
+			if err != nil {
+				t.Fatalf("ListObjects() error = %v", err)
+			}
+			if got := keys(result); fmt.Sprint(got) != fmt.Sprint(tt.wantKeys) {
+				t.Errorf("Objects = %v, want %v", got, tt.wantKeys)
+			}
+			if fmt.Sprint(result.CommonPrefixes) != fmt.Sprint(tt.wantPrefixes) {
+				t.Errorf("CommonPrefixes = %v, want %v", result.CommonPrefixes, tt.wantPrefixes)
+			}
+			if result.IsTruncated != tt.truncated {
+				t.Errorf("IsTruncated = %v, want %v", result.IsTruncated, tt.truncated)
+			}
+			if tt.truncated && result.NextMarker != tt.wantNext {
+				t.Errorf("NextMarker = %q, want %q", result.NextMarker, tt.wantNext)
+			}
+		})
+	}
+}
+
 func TestObjectService_ListObjectsWithMaxKeys(t *testing.T) {
 	storage := NewMockStorageBackend()
 	meta := NewMockMetadataStore()
@@ -2130,6 +2887,74 @@ func TestObjectService_ListObjectsWithMaxKeys(t *testing.T) {
 	_ = result
 }
 
+func TestObjectService_ListObjects_Pagination(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "test-bucket")
+	svc.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	const objects = 2500
+	for i := 0; i < objects; i++ {
+		key := fmt.Sprintf("key-%05d", i)
+		if _, err := svc.PutObject(ctx, "test-bucket", key, bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+			t.Fatalf("PutObject(%s) error = %v", key, err)
+		}
+	}
+	// Overwriting keys keeps their earlier versions' data in storage, which
+	// must not shorten or extend pages
+	for i := 0; i < 1200; i++ {
+		key := fmt.Sprintf("key-%05d", i)
+		if _, err := svc.PutObject(ctx, "test-bucket", key, bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+			t.Fatalf("PutObject(%s) error = %v", key, err)
+		}
+	}
+
+	var pages []int
+	next := 0
+	marker := ""
+	for {
+		result, err := svc.ListObjects(ctx, "test-bucket", ListObjectsOptions{MaxKeys: 1000, Marker: marker})
+		if err != nil {
+			t.Fatalf("ListObjects() error = %v", err)
+		}
+		pages = append(pages, len(result.Objects))
+		for _, obj := range result.Objects {
+			if want := fmt.Sprintf("key-%05d", next); obj.Key != want {
+				t.Fatalf("object %d = %q, want %q", next, obj.Key, want)
+			}
+			next++
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextMarker
+	}
+	if next != objects {
+		t.Errorf("paged ListObjects() returned %d objects, want %d", next, objects)
+	}
+	if fmt.Sprint(pages) != "[1000 1000 500]" {
+		t.Errorf("page sizes = %v, want [1000 1000 500]", pages)
+	}
+
+	// A full last page is not reported as truncated
+	result, err := svc.ListObjects(ctx, "test-bucket", ListObjectsOptions{MaxKeys: 500, Marker: "key-01999"})
+	if err != nil {
+		t.Fatalf("ListObjects() error = %v", err)
+	}
+	if len(result.Objects) != 500 || result.IsTruncated {
+		t.Errorf("ListObjects(last page) = %d objects, truncated %v; want 500, false", len(result.Objects), result.IsTruncated)
+	}
+
+	// StartAfter and Marker combine to the later of the two
+	result, err = svc.ListObjects(ctx, "test-bucket", ListObjectsOptions{MaxKeys: 1, Marker: "key-00010", StartAfter: "key-00100"})
+	if err != nil {
+		t.Fatalf("ListObjects() error = %v", err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Key != "key-00101" {
+		t.Errorf("ListObjects(StartAfter) = %+v, want key-00101", result.Objects)
+	}
+}
+
 func TestParseInt(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -2152,37 +2977,55 @@ func TestParseInt(t *testing.T) {
 }
 
 func TestValidateBucketName(t *testing.T) {
-	validNames := []string{
-		"my-bucket",
-		"bucket123",
-		"my.bucket",
-		"mybucket",
-		"192.168.1.1",
-		"my-bucket-",
-	}
-	for _, name := range validNames {
-		if err := validateBucketName(name); err != nil {
-			t.Errorf("validateBucketName(%q) = %v, want nil", name, err)
-		}
-	}
-
-	invalidNames := []string{
-		"",
-		"ab",
-		"BUCKET",
-		"ab",
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"my-bucket", true},
+		{"bucket123", true},
+		{"my.bucket", true},
+		{"mybucket", true},
+		{"abc", true},
+		{"1bucket2", true},
+		{"a.b-c.d", true},
+		{"192.168.1.1.example", true},
+		{strings.Repeat("a", 63), true},
+		{"", false},
+		{"ab", false},
+		{strings.Repeat("a", 64), false},
+		{"BUCKET", false},
+		{"myBucket", false},
+		{"my_bucket", false},
+		{"my bucket", false},
+		{"-bucket", false},
+		{"bucket-", false},
+		{".bucket", false},
+		{"bucket.", false},
+		{"my..bucket", false},
+		{"my.-bucket", false},
+		{"my-.bucket", false},
+		{"192.168.1.1", false},
+		{"xn--bucket", false},
+		{"sthree-bucket", false},
+		{"bucket-s3alias", false},
+		{"bucket--ol-s3", false},
 	}
-	for _, name := range invalidNames {
-		if err := validateBucketName(name); err == nil {
-			t.Errorf("validateBucketName(%q) = nil, want error", name)
+	for _, tt := range tests {
+		err := validateBucketName(tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("validateBucketName(%q) = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidBucketName) {
+			t.Errorf("validateBucketName(%q) = %v, want ErrInvalidBucketName", tt.name, err)
 		}
 	}
 }
 
 func TestValidateBucketName_IPAddr(t *testing.T) {
-	err := validateBucketName("1.2.3.4.ipaddr")
-	if err == nil {
-		t.Error("validateBucketName() should fail for .ipaddr suffix")
+	for _, name := range []string{"1.2.3.4", "10.0.0.255"} {
+		if err := validateBucketName(name); err == nil || !strings.Contains(err.Error(), "IP address") {
+			t.Errorf("validateBucketName(%q) = %v, want IP address error", name, err)
+		}
 	}
 }
 
@@ -2321,7 +3164,7 @@ func TestObjectService_CopyObject_ObjectNotFound(t *testing.T) {
 	meta.CreateBucket(context.Background(), "dst-bucket")
 	svc := New(storage, meta, zap.NewNop().Sugar())
 
-	_, err := svc.CopyObject(context.Background(), "src-bucket", "nonexistent", "dst-bucket", "dst-key")
+	_, err := svc.CopyObject(context.Background(), "src-bucket", "nonexistent", "dst-bucket", "dst-key", CopyObjectOptions{})
 	if err == nil {
 		t.Error("CopyObject() should fail for nonexistent object")
 	}
@@ -2335,7 +3178,7 @@ func TestObjectService_CopyObject_StorageGetError(t *testing.T) {
 	svc := New(storage, meta, zap.NewNop().Sugar())
 
 	meta.PutObject(context.Background(), "src-bucket", "src-key", &metadata.ObjectMetadata{Key: "src-key"})
-	_, err := svc.CopyObject(context.Background(), "src-bucket", "src-key", "dst-bucket", "dst-key")
+	_, err := svc.CopyObject(context.Background(), "src-bucket", "src-key", "dst-bucket", "dst-key", CopyObjectOptions{})
 	if err == nil {
 		t.Error("CopyObject() should fail with storage get error")
 	}
@@ -2355,7 +3198,7 @@ func TestObjectService_CopyObject_StoragePutError(t *testing.T) {
 	storage := &errorStorage{MockStorageBackend: mockStorage, putErr: fmt.Errorf("put error")}
 	svc := New(storage, meta, zap.NewNop().Sugar())
 
-	_, err := svc.CopyObject(context.Background(), "src-bucket", "src-key", "dst-bucket", "dst-key")
+	_, err := svc.CopyObject(context.Background(), "src-bucket", "src-key", "dst-bucket", "dst-key", CopyObjectOptions{})
 	if err == nil {
 		t.Error("CopyObject() should fail with storage put error")
 	}
@@ -2392,7 +3235,7 @@ func TestObjectService_DeleteObject_StorageError(t *testing.T) {
 	storage := &errorStorage{MockStorageBackend: NewMockStorageBackend(), deleteErr: fmt.Errorf("delete error")}
 	svc := New(storage, meta, zap.NewNop().Sugar())
 
-	err := svc.DeleteObject(context.Background(), "test-bucket", "key", DeleteObjectOptions{})
+	_, err := svc.DeleteObject(context.Background(), "test-bucket", "key", DeleteObjectOptions{})
 	if err == nil {
 		t.Error("DeleteObject() should fail with storage error")
 	}
@@ -2486,6 +3329,7 @@ type errorMetadataStore struct {
 	createBktErr   error
 	delBktErr      error
 	lifecycleErr   error
+	emptyErr       error
 }
 
 func (e *errorMetadataStore) GetObject(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectMetadata, error) {
@@ -2551,6 +3395,13 @@ func (e *errorMetadataStore) DeleteBucket(ctx context.Context, bucket string) er
 	return e.MockMetadataStore.DeleteBucket(ctx, bucket)
 }
 
+func (e *errorMetadataStore) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	if e.emptyErr != nil {
+		return false, e.emptyErr
+	}
+	return e.MockMetadataStore.BucketIsEmpty(ctx, bucket)
+}
+
 func (e *errorMetadataStore) GetLifecycleRules(ctx context.Context, bucket string) ([]metadata.LifecycleRule, error) {
 	if e.lifecycleErr != nil {
 		return nil, e.lifecycleErr
@@ -2614,13 +3465,13 @@ func TestObjectService_CreateBucket_MetadataError(t *testing.T) {
 	}
 }
 
-func TestObjectService_DeleteBucket_ListError(t *testing.T) {
-	storage := &errorStorage{MockStorageBackend: NewMockStorageBackend(), listErr: fmt.Errorf("list error")}
-	svc := New(storage, NewMockMetadataStore(), zap.NewNop().Sugar())
+func TestObjectService_DeleteBucket_EmptyCheckError(t *testing.T) {
+	meta := &errorMetadataStore{MockMetadataStore: NewMockMetadataStore(), emptyErr: fmt.Errorf("scan error")}
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
 
 	err := svc.DeleteBucket(context.Background(), "test-bucket")
 	if err == nil {
-		t.Error("DeleteBucket() should fail with list error")
+		t.Error("DeleteBucket() should fail with empty check error")
 	}
 }
 
@@ -2712,7 +3563,7 @@ func TestObjectService_CompleteMultipartUpload_ReadPartError(t *testing.T) {
 
 func TestObjectService_CompleteMultipartUpload_WriteError(t *testing.T) {
 	mockMeta := NewMockMetadataStore()
-	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1})
+	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1, Size: 4})
 
 	mockStorage := NewMockStorageBackend()
 	mockStorage.Put(context.Background(), "bucket", "bucket/key/upload-id/1", bytes.NewReader([]byte("part")), 4, storage.PutOptions{})
@@ -2728,7 +3579,7 @@ func TestObjectService_CompleteMultipartUpload_WriteError(t *testing.T) {
 
 func TestObjectService_CompleteMultipartUpload_MetadataError(t *testing.T) {
 	mockMeta := NewMockMetadataStore()
-	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1})
+	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1, Size: 4})
 
 	mockStorage := NewMockStorageBackend()
 	mockStorage.Put(context.Background(), "bucket", "bucket/key/upload-id/1", bytes.NewReader([]byte("part")), 4, storage.PutOptions{})
@@ -2744,14 +3595,15 @@ func TestObjectService_CompleteMultipartUpload_MetadataError(t *testing.T) {
 
 func TestObjectService_CompleteMultipartUpload_SortParts(t *testing.T) {
 	mockMeta := NewMockMetadataStore()
-	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 2, &metadata.PartMetadata{PartNumber: 2})
-	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1})
+	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 2, &metadata.PartMetadata{PartNumber: 2, Size: 5})
+	mockMeta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1, Size: 5})
 
 	mockStorage := NewMockStorageBackend()
 	mockStorage.Put(context.Background(), "bucket", "bucket/key/upload-id/1", bytes.NewReader([]byte("part1")), 5, storage.PutOptions{})
 	mockStorage.Put(context.Background(), "bucket", "bucket/key/upload-id/2", bytes.NewReader([]byte("part2")), 5, storage.PutOptions{})
 
 	svc := New(mockStorage, mockMeta, zap.NewNop().Sugar())
+	svc.minPartSize = 0
 
 	result, err := svc.CompleteMultipartUpload(context.Background(), "bucket", "key", "upload-id", []PartInfo{{PartNumber: 1}, {PartNumber: 2}})
 	if err != nil {
@@ -2762,6 +3614,151 @@ func TestObjectService_CompleteMultipartUpload_SortParts(t *testing.T) {
 	}
 }
 
+// openCountingStorage tracks how many stored objects are open for reading
+type openCountingStorage struct {
+	*MockStorageBackend
+	mu      sync.Mutex
+	open    int
+	maxOpen int
+}
+
+type countedReader struct {
+	io.ReadCloser
+	storage *openCountingStorage
+}
+
+func (r *countedReader) Close() error {
+	r.storage.mu.Lock()
+	r.storage.open--
+	r.storage.mu.Unlock()
+	return r.ReadCloser.Close()
+}
+
+func (s *openCountingStorage) Get(ctx context.Context, bucket, key string, opts storage.GetOptions) (io.ReadCloser, error) {
+	reader, err := s.MockStorageBackend.Get(ctx, bucket, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.open++
+	s.maxOpen = max(s.maxOpen, s.open)
+	s.mu.Unlock()
+	return &countedReader{ReadCloser: reader, storage: s}, nil
+}
+
+func TestObjectService_CompleteMultipartUpload_StreamsParts(t *testing.T) {
+	store := &openCountingStorage{MockStorageBackend: NewMockStorageBackend()}
+	svc := New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.minPartSize = 0
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "key", PutObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []PartInfo
+	var want string
+	for i, data := range []string{"first ", "second ", "third"} {
+		part, err := svc.UploadPart(ctx, "bucket", "key", upload.UploadID, i+1, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("UploadPart(%d) error = %v", i+1, err)
+		}
+		parts = append(parts, PartInfo{PartNumber: i + 1, ETag: part.ETag})
+		want += data
+	}
+
+	result, err := svc.CompleteMultipartUpload(ctx, "bucket", "key", upload.UploadID, parts)
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	if store.maxOpen != 1 || store.open != 0 {
+		t.Errorf("parts open at once = %d, still open = %d; want one at a time, all closed", store.maxOpen, store.open)
+	}
+	if result.Size != int64(len(want)) || !strings.HasSuffix(result.ETag, "-3\"") {
+		t.Errorf("CompleteMultipartUpload() = %+v, want %d bytes and a 3-part ETag", result, len(want))
+	}
+	if got, _ := readObject(t, svc, "bucket", "key", GetObjectOptions{}); got != want {
+		t.Errorf("object data = %q, want %q", got, want)
+	}
+}
+
+func TestObjectService_CompleteMultipartUpload_PartSizeMismatch(t *testing.T) {
+	meta := NewMockMetadataStore()
+	meta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1, Size: 10})
+	store := NewMockStorageBackend()
+	store.Put(context.Background(), "bucket", "bucket/key/upload-id/1", bytes.NewReader([]byte("part")), 4, storage.PutOptions{})
+	svc := New(store, meta, zap.NewNop().Sugar())
+
+	if _, err := svc.CompleteMultipartUpload(context.Background(), "bucket", "key", "upload-id", []PartInfo{{PartNumber: 1}}); err == nil {
+		t.Error("CompleteMultipartUpload() should fail when a part is shorter than its metadata says")
+	}
+	if _, err := store.Head(context.Background(), "bucket", "key"); err == nil {
+		t.Error("object was written from a short part")
+	}
+}
+
+func TestObjectService_CompleteMultipartUpload_ValidatesParts(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.minPartSize = 4
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "key", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	etags := map[int]string{}
+	for n, data := range map[int]string{1: "aaaa", 2: "bb", 3: "cccc", 4: "dd"} {
+		part, err := svc.UploadPart(ctx, "bucket", "key", upload.UploadID, n, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("UploadPart(%d) error = %v", n, err)
+		}
+		etags[n] = part.ETag
+	}
+	listed := func(numbers ...int) []PartInfo {
+		parts := make([]PartInfo, len(numbers))
+		for i, n := range numbers {
+			parts[i] = PartInfo{PartNumber: n, ETag: etags[n]}
+		}
+		return parts
+	}
+
+	for _, tc := range []struct {
+		name  string
+		parts []PartInfo
+		want  error
+	}{
+		{"no parts", nil, ErrInvalidPart},
+		{"unknown part", listed(1, 5), ErrInvalidPart},
+		{"wrong ETag", []PartInfo{{PartNumber: 1, ETag: etags[3]}}, ErrInvalidPart},
+		{"descending", listed(3, 1), ErrInvalidPartOrder},
+		{"repeated", listed(1, 1), ErrInvalidPartOrder},
+		{"small part before the last", listed(2, 3), ErrEntityTooSmall},
+	} {
+		if _, err := svc.CompleteMultipartUpload(ctx, "bucket", "key", upload.UploadID, tc.parts); !errors.Is(err, tc.want) {
+			t.Errorf("%s: CompleteMultipartUpload() error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	// Only the listed parts are assembled; the last may be small, and ETags
+	// match with or without quotes
+	parts := listed(1, 3, 4)
+	parts[0].ETag = strings.Trim(parts[0].ETag, `"`)
+	result, err := svc.CompleteMultipartUpload(ctx, "bucket", "key", upload.UploadID, parts)
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	obj, err := svc.GetObject(ctx, "bucket", "key", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	if got, _ := io.ReadAll(obj.Body); string(got) != "aaaaccccdd" || result.Size != 10 {
+		t.Errorf("completed object = %q (%d bytes), want the listed parts aaaaccccdd", got, result.Size)
+	}
+}
+
 func TestObjectService_ListMultipartUpload_MetadataError(t *testing.T) {
 	meta := &errorMetadataStore{MockMetadataStore: NewMockMetadataStore(), listUploadsErr: fmt.Errorf("list error")}
 	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
@@ -2884,8 +3881,9 @@ func TestObjectService_BucketLogging_Nil(t *testing.T) {
 
 func TestObjectService_GeneratePresignedURL_EmptyMethod(t *testing.T) {
 	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	enablePresign(t, svc)
 
-	_, err := svc.GeneratePresignedURL(context.Background(), "bucket", "key", "", 3600)
+	_, err := svc.GeneratePresignedURL(context.Background(), "test-key", "bucket", "key", "", 3600)
 	if err == nil {
 		t.Error("GeneratePresignedURL() should fail with empty method")
 	}
@@ -2895,8 +3893,9 @@ func TestObjectService_GeneratePresignedURL_ObjectNotFound(t *testing.T) {
 	meta := NewMockMetadataStore()
 	meta.CreateBucket(context.Background(), "bucket")
 	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	enablePresign(t, svc)
 
-	_, err := svc.GeneratePresignedURL(context.Background(), "bucket", "nonexistent", "PUT", 3600)
+	_, err := svc.GeneratePresignedURL(context.Background(), "test-key", "bucket", "nonexistent", "PUT", 3600)
 	if err == nil {
 		t.Error("GeneratePresignedURL() should fail for nonexistent object with PUT method")
 	}
@@ -2906,8 +3905,9 @@ func TestObjectService_GeneratePresignedURL_DELETE_ObjectNotFound(t *testing.T)
 	meta := NewMockMetadataStore()
 	meta.CreateBucket(context.Background(), "bucket")
 	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	enablePresign(t, svc)
 
-	_, err := svc.GeneratePresignedURL(context.Background(), "bucket", "nonexistent", "DELETE", 3600)
+	_, err := svc.GeneratePresignedURL(context.Background(), "test-key", "bucket", "nonexistent", "DELETE", 3600)
 	if err == nil {
 		t.Error("GeneratePresignedURL() should fail for nonexistent object with DELETE method")
 	}
@@ -2928,8 +3928,9 @@ func (e *errorPutPresignedURLMetadata) PutPresignedURL(ctx context.Context, url
 func TestObjectService_GeneratePresignedURL_PutError(t *testing.T) {
 	meta := &errorPutPresignedURLMetadata{MockMetadataStore: NewMockMetadataStore(), putPresignedURLErr: fmt.Errorf("put error")}
 	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	enablePresign(t, svc)
 
-	_, err := svc.GeneratePresignedURL(context.Background(), "bucket", "key", "GET", 3600)
+	_, err := svc.GeneratePresignedURL(context.Background(), "test-key", "bucket", "key", "GET", 3600)
 	if err == nil {
 		t.Error("GeneratePresignedURL() should fail with put error")
 	}
@@ -2984,9 +3985,12 @@ func TestObjectService_DeleteObject_MetadataError(t *testing.T) {
 	errMeta := &errorDeleteObjectMetadata{MockMetadataStore: meta, delObjErr: fmt.Errorf("delete error")}
 	svc := New(mockStorage, errMeta, zap.NewNop().Sugar())
 
-	err := svc.DeleteObject(context.Background(), "test-bucket", "key", DeleteObjectOptions{})
-	if err != nil {
-		t.Errorf("DeleteObject() should not fail with metadata delete error: %v", err)
+	_, err := svc.DeleteObject(context.Background(), "test-bucket", "key", DeleteObjectOptions{})
+	if err == nil {
+		t.Error("DeleteObject() should fail with metadata delete error")
+	}
+	if _, err := mockStorage.Head(context.Background(), "test-bucket", "key"); err != nil {
+		t.Errorf("object data should survive a failed metadata delete: %v", err)
 	}
 }
 
@@ -3032,7 +4036,7 @@ func TestObjectService_CopyObject_MetadataError(t *testing.T) {
 	errMeta := &errorPutObjectMetadata{MockMetadataStore: meta, putObjErr: fmt.Errorf("put error")}
 	svc := New(mockStorage, errMeta, zap.NewNop().Sugar())
 
-	_, err := svc.CopyObject(context.Background(), "src-bucket", "src-key", "dst-bucket", "dst-key")
+	_, err := svc.CopyObject(context.Background(), "src-bucket", "src-key", "dst-bucket", "dst-key", CopyObjectOptions{})
 	if err != nil {
 		t.Errorf("CopyObject() should not fail with metadata put error: %v", err)
 	}
@@ -3158,7 +4162,7 @@ func TestObjectService_CompleteMultipartUpload_DeletePartError(t *testing.T) {
 	mockStorage.Put(context.Background(), "bucket", "bucket/key/upload-id/1", bytes.NewReader([]byte("part")), 4, storage.PutOptions{})
 
 	meta := NewMockMetadataStore()
-	meta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1})
+	meta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1, Size: 4})
 
 	svc := New(mockStorage, meta, zap.NewNop().Sugar())
 
@@ -3250,7 +4254,7 @@ func TestObjectService_CompleteMultipartUpload_CompleteError(t *testing.T) {
 	mockStorage.Put(context.Background(), "bucket", "bucket/key/upload-id/1", bytes.NewReader([]byte("part")), 4, storage.PutOptions{})
 
 	meta := NewMockMetadataStore()
-	meta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1})
+	meta.PutPart(context.Background(), "bucket", "key", "upload-id", 1, &metadata.PartMetadata{PartNumber: 1, Size: 4})
 
 	errMeta := &errorCompleteMultipartMetadata{MockMetadataStore: meta, completeMpuErr: fmt.Errorf("complete error")}
 	svc := New(mockStorage, errMeta, zap.NewNop().Sugar())
@@ -3325,3 +4329,133 @@ func TestObjectService_UploadPart_StoragePutError(t *testing.T) {
 		t.Error("UploadPart() should fail with storage put error")
 	}
 }
+
+// renamingStorage adds storage.Renamer to the mock backend
+type renamingStorage struct {
+	*MockStorageBackend
+	renames int
+}
+
+func (s *renamingStorage) Rename(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[s.objectKey(srcBucket, srcKey)]
+	if !ok {
+		return io.EOF
+	}
+	delete(s.objects, s.objectKey(srcBucket, srcKey))
+	s.objects[s.objectKey(dstBucket, dstKey)] = data
+	s.renames++
+	return nil
+}
+
+func TestObjectService_MoveObject(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		renamer bool
+	}{
+		{"copy fallback", false},
+		{"renamer", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := NewMockStorageBackend()
+			var backend storage.StorageBackend = mock
+			rs := &renamingStorage{MockStorageBackend: mock}
+			if tc.renamer {
+				backend = rs
+			}
+			meta := NewMockMetadataStore()
+			svc := New(backend, meta, zap.NewNop().Sugar())
+			ctx := context.Background()
+			meta.CreateBucket(ctx, "bucket")
+
+			svc.PutObject(ctx, "bucket", "old.txt", bytes.NewReader([]byte("payload")), PutObjectOptions{ContentType: "text/plain"})
+
+			if err := svc.MoveObject(ctx, "bucket", "old.txt", "bucket", "new.txt"); err != nil {
+				t.Fatalf("MoveObject() error = %v", err)
+			}
+
+			if _, err := svc.HeadObject(ctx, "bucket", "old.txt"); err == nil {
+				t.Error("old key should not exist after move")
+			}
+			obj, err := svc.GetObject(ctx, "bucket", "new.txt", GetObjectOptions{})
+			if err != nil {
+				t.Fatalf("GetObject(new) error = %v", err)
+			}
+			defer obj.Body.Close()
+			data, _ := io.ReadAll(obj.Body)
+			if string(data) != "payload" || obj.ContentType != "text/plain" {
+				t.Errorf("moved object = %q (%s), want payload (text/plain)", data, obj.ContentType)
+			}
+			if tc.renamer && rs.renames != 1 {
+				t.Errorf("renames = %d, want 1", rs.renames)
+			}
+		})
+	}
+}
+
+func TestObjectService_MoveObject_SourceMissing(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+
+	if err := svc.MoveObject(ctx, "bucket", "missing", "bucket", "dst"); err == nil {
+		t.Error("MoveObject() should fail for a missing source")
+	}
+}
+
+func TestObjectService_MoveObject_KeepsTagsAndDestination(t *testing.T) {
+	mock := NewMockStorageBackend()
+	meta := NewMockMetadataStore()
+	svc := New(&renamingStorage{MockStorageBackend: mock}, meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+
+	svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("source")), PutObjectOptions{})
+	svc.PutObject(ctx, "bucket", "taken", bytes.NewReader([]byte("existing")), PutObjectOptions{})
+	meta.PutObjectTags(ctx, "bucket", "src", "", map[string]string{"team": "a"})
+
+	// An existing destination is left alone, bytes included
+	if err := svc.MoveObject(ctx, "bucket", "src", "bucket", "taken"); !errors.Is(err, ErrObjectExists) {
+		t.Fatalf("MoveObject() onto an existing key error = %v, want ErrObjectExists", err)
+	}
+	obj, err := svc.GetObject(ctx, "bucket", "taken", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject(taken) error = %v", err)
+	}
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(data) != "existing" {
+		t.Errorf("destination after refused move = %q, want existing", data)
+	}
+
+	if err := svc.MoveObject(ctx, "bucket", "src", "bucket", "dst"); err != nil {
+		t.Fatalf("MoveObject() error = %v", err)
+	}
+	tags, err := svc.GetObjectTags(ctx, "bucket", "dst", "")
+	if err != nil || tags["team"] != "a" {
+		t.Errorf("GetObjectTags(dst) = %v, %v, want the source's tags", tags, err)
+	}
+	if tags, _ := meta.GetObjectTags(ctx, "bucket", "src", ""); len(tags) != 0 {
+		t.Errorf("GetObjectTags(src) after move = %v, want none", tags)
+	}
+}
+
+func TestObjectService_MoveObject_VersionedBucket(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	meta.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("source")), PutObjectOptions{})
+
+	for _, status := range []string{"Enabled", "Suspended"} {
+		meta.PutBucketVersioning(ctx, "bucket", &metadata.BucketVersioning{Status: status})
+		if err := svc.MoveObject(ctx, "bucket", "src", "bucket", "dst"); !errors.Is(err, ErrVersionedMove) {
+			t.Errorf("MoveObject() with versioning %s error = %v, want ErrVersionedMove", status, err)
+		}
+	}
+	if _, err := svc.HeadObject(ctx, "bucket", "src"); err != nil {
+		t.Errorf("HeadObject(src) after refused moves error = %v", err)
+	}
+}
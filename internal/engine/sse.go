@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/openendpoint/openendpoint/internal/encryption"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/storage"
+)
+
+// SSEAES256 is the server-side encryption with keys managed by the server
+// (SSE-S3), the only algorithm supported
+const SSEAES256 = "AES256"
+
+// SetEncryptionKey sets the 32-byte master key that wraps the data key of
+// every object stored with server-side encryption. Without one, writes that
+// ask for encryption fail with ErrEncryptionUnavailable, and so do reads of
+// objects stored encrypted.
+func (s *ObjectService) SetEncryptionKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	s.masterKey = key
+	return nil
+}
+
+// encryptionFor returns the server-side encryption to store a new object in
+// bucket with: the requested algorithm, or else the bucket's default
+// encryption, or none
+func (s *ObjectService) encryptionFor(ctx context.Context, bucket, requested string) (string, error) {
+	if requested == "" {
+		if config, err := s.metadata.GetBucketEncryption(ctx, bucket); err == nil && config != nil && config.Rule.Apply.SSEAlgorithm == SSEAES256 {
+			requested = SSEAES256
+		}
+	}
+	switch requested {
+	case "":
+		return "", nil
+	case SSEAES256:
+		if s.masterKey == nil {
+			return "", fmt.Errorf("%w: no master key", ErrEncryptionUnavailable)
+		}
+		return requested, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidEncryption, requested)
+}
+
+// sealed records how an object's data was encrypted
+type sealed struct {
+	algorithm  string
+	key, nonce []byte
+}
+
+// apply records the encryption in the object's metadata
+func (e sealed) apply(meta *metadata.ObjectMetadata) {
+	meta.SSEAlgorithm = e.algorithm
+	meta.SSEKey = e.key
+	meta.SSENonce = e.nonce
+}
+
+// seal returns data, size bytes long, encrypted with algorithm as returned
+// by encryptionFor, and the size of the encrypted data. The data is sealed as
+// it is read. Without an algorithm it is returned as is.
+func (s *ObjectService) seal(algorithm string, data io.Reader, size int64) (io.Reader, int64, sealed, error) {
+	if algorithm == "" {
+		return data, size, sealed{}, nil
+	}
+	key, wrappedKey, nonce, err := encryption.NewObjectKey(s.masterKey)
+	if err != nil {
+		return nil, 0, sealed{}, fmt.Errorf("failed to encrypt object: %w", err)
+	}
+	return key.Seal(data), encryption.SealedSize(size), sealed{algorithm: algorithm, key: wrappedKey, nonce: nonce}, nil
+}
+
+// openData reads the data of meta stored at dataKey. Encrypted data is
+// decrypted as it is read, and only the segments holding the requested
+// range are read from storage. Errors from the storage backend are returned
+// unwrapped.
+func (s *ObjectService) openData(ctx context.Context, bucket, dataKey string, meta *metadata.ObjectMetadata, opts storage.GetOptions) (io.ReadCloser, error) {
+	if meta.SSEAlgorithm == "" {
+		return s.storage.Get(ctx, bucket, dataKey, opts)
+	}
+	if s.masterKey == nil {
+		return nil, fmt.Errorf("%w: cannot decrypt %s/%s", ErrEncryptionUnavailable, bucket, meta.Key)
+	}
+	key, err := encryption.OpenObjectKey(s.masterKey, meta.SSEKey, meta.SSENonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s/%s: %w", bucket, meta.Key, err)
+	}
+
+	offset, limit := int64(0), meta.Size
+	if rng := opts.Range; rng != nil {
+		offset = min(rng.Start, meta.Size)
+		limit = max(offset, min(rng.End, meta.Size))
+		start, end, _ := encryption.SealedRange(meta.Size, offset, limit)
+		opts.Range = &storage.Range{Start: start, End: end}
+	}
+	reader, err := s.storage.Get(ctx, bucket, dataKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, _, segment := encryption.SealedRange(meta.Size, offset, limit)
+	plaintext := key.Open(reader, meta.Size, segment)
+
+	// The first segment may start before the range
+	if skip := offset - segment*encryption.SegmentSize; skip > 0 {
+		if _, err := io.CopyN(io.Discard, plaintext, skip); err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to decrypt %s/%s: %w", bucket, meta.Key, err)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(plaintext, limit-offset), reader}, nil
+}
+
+// storedSize returns how many bytes the data of meta takes in storage
+func storedSize(meta *metadata.ObjectMetadata) int64 {
+	if meta.SSEAlgorithm != "" {
+		return encryption.SealedSize(meta.Size)
+	}
+	return meta.Size
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/encryption"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"github.com/openendpoint/openendpoint/internal/storage"
+	"go.uber.org/zap"
+)
+
+func newEncryptingService(t *testing.T) (*ObjectService, *MockStorageBackend) {
+	t.Helper()
+	meta, err := pebble.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { meta.Close() })
+
+	store := NewMockStorageBackend()
+	svc := New(store, meta, zap.NewNop().Sugar())
+	if err := svc.SetEncryptionKey(bytes.Repeat([]byte{7}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	return svc, store
+}
+
+func readObject(t *testing.T, svc *ObjectService, bucket, key string, opts GetObjectOptions) (string, *GetObjectResult) {
+	t.Helper()
+	result, err := svc.GetObject(context.Background(), bucket, key, opts)
+	if err != nil {
+		t.Fatalf("GetObject(%s) error = %v", key, err)
+	}
+	defer result.Body.Close()
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", key, err)
+	}
+	return string(data), result
+}
+
+func TestObjectService_SSE_PutGet(t *testing.T) {
+	svc, store := newEncryptingService(t)
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	put, err := svc.PutObject(ctx, "bucket", "secret.txt", bytes.NewReader([]byte("top secret data")), PutObjectOptions{
+		ServerSideEncryption: SSEAES256,
+	})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if put.ServerSideEncryption != SSEAES256 || put.Size != 15 {
+		t.Errorf("PutObject() = %+v, want AES256 and the plaintext size", put)
+	}
+	if stored := store.objects["bucket/secret.txt"]; bytes.Contains(stored, []byte("secret")) {
+		t.Error("object data is stored in plaintext")
+	}
+
+	body, result := readObject(t, svc, "bucket", "secret.txt", GetObjectOptions{})
+	if body != "top secret data" || result.ServerSideEncryption != SSEAES256 {
+		t.Errorf("GetObject() = %q with SSE %q, want the plaintext and AES256", body, result.ServerSideEncryption)
+	}
+	if result.ETag != put.ETag {
+		t.Errorf("GetObject() ETag = %s, want %s", result.ETag, put.ETag)
+	}
+
+	// Ranges address the plaintext
+	body, _ = readObject(t, svc, "bucket", "secret.txt", GetObjectOptions{Range: &storage.Range{Start: 4, End: 10}})
+	if body != "secret" {
+		t.Errorf("ranged GetObject() = %q, want %q", body, "secret")
+	}
+	body, _ = readObject(t, svc, "bucket", "secret.txt", GetObjectOptions{VerifyIntegrity: true})
+	if body != "top secret data" {
+		t.Errorf("verified GetObject() = %q", body)
+	}
+
+	info, err := svc.HeadObject(ctx, "bucket", "secret.txt")
+	if err != nil || info.ServerSideEncryption != SSEAES256 || info.Size != 15 {
+		t.Errorf("HeadObject() = %+v, %v, want AES256 and size 15", info, err)
+	}
+
+	if _, err := svc.PutObject(ctx, "bucket", "kms.txt", bytes.NewReader([]byte("x")), PutObjectOptions{ServerSideEncryption: "aws:kms"}); !errors.Is(err, ErrInvalidEncryption) {
+		t.Errorf("PutObject(aws:kms) error = %v, want ErrInvalidEncryption", err)
+	}
+}
+
+func TestObjectService_SSE_BucketDefault(t *testing.T) {
+	svc, store := newEncryptingService(t)
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.CreateBucket(ctx, "plain")
+	svc.PutBucketEncryption(ctx, "bucket", &metadata.BucketEncryption{
+		Rule: metadata.EncryptionRule{Apply: metadata.ApplyEncryptionConfiguration{SSEAlgorithm: SSEAES256}},
+	})
+
+	put, err := svc.PutObject(ctx, "bucket", "a", bytes.NewReader([]byte("by default")), PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if put.ServerSideEncryption != SSEAES256 {
+		t.Errorf("PutObject() into a bucket with default encryption stored SSE %q", put.ServerSideEncryption)
+	}
+
+	// A copy out of the bucket is decrypted; the copy back in is sealed anew
+	copied, err := svc.CopyObject(ctx, "bucket", "a", "plain", "a", CopyObjectOptions{})
+	if err != nil {
+		t.Fatalf("CopyObject() error = %v", err)
+	}
+	if copied.ServerSideEncryption != "" || string(store.objects["plain/a"]) != "by default" {
+		t.Errorf("copy to an unencrypted bucket stored %q with SSE %q", store.objects["plain/a"], copied.ServerSideEncryption)
+	}
+	if _, err := svc.CopyObject(ctx, "plain", "a", "bucket", "b", CopyObjectOptions{}); err != nil {
+		t.Fatalf("CopyObject() error = %v", err)
+	}
+	if body, result := readObject(t, svc, "bucket", "b", GetObjectOptions{}); body != "by default" || result.ServerSideEncryption != SSEAES256 {
+		t.Errorf("copy into the encrypted bucket = %q with SSE %q", body, result.ServerSideEncryption)
+	}
+
+	// Multipart uploads are encrypted when they complete
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "multi", PutObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := svc.UploadPart(ctx, "bucket", "multi", upload.UploadID, 1, bytes.NewReader([]byte("multipart data")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := svc.CompleteMultipartUpload(ctx, "bucket", "multi", upload.UploadID, []PartInfo{{PartNumber: 1, ETag: part.ETag}})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	if done.ServerSideEncryption != SSEAES256 || bytes.Contains(store.objects["bucket/multi"], []byte("multipart")) {
+		t.Error("completed multipart upload is not stored encrypted")
+	}
+	if body, _ := readObject(t, svc, "bucket", "multi", GetObjectOptions{}); body != "multipart data" {
+		t.Errorf("GetObject(multi) = %q", body)
+	}
+}
+
+func TestObjectService_SSE_Versions(t *testing.T) {
+	svc, _ := newEncryptingService(t)
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutBucketVersioning(ctx, "bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	first, err := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("first")), PutObjectOptions{ServerSideEncryption: SSEAES256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("second")), PutObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The encrypted version keeps its data and key once it is noncurrent
+	if body, result := readObject(t, svc, "bucket", "key", GetObjectOptions{VersionID: first.VersionID}); body != "first" || result.ServerSideEncryption != SSEAES256 {
+		t.Errorf("GetObject(first version) = %q with SSE %q", body, result.ServerSideEncryption)
+	}
+	if body, result := readObject(t, svc, "bucket", "key", GetObjectOptions{}); body != "second" || result.ServerSideEncryption != "" {
+		t.Errorf("GetObject(current) = %q with SSE %q", body, result.ServerSideEncryption)
+	}
+}
+
+func TestObjectService_SSE_Segments(t *testing.T) {
+	svc, store := newEncryptingService(t)
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3*encryption.SegmentSize/16+7)
+	size := int64(len(data))
+	if _, err := svc.PutObject(ctx, "bucket", "big", bytes.NewReader(data), PutObjectOptions{ServerSideEncryption: SSEAES256}); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if stored := int64(len(store.objects["bucket/big"])); stored != encryption.SealedSize(size) {
+		t.Errorf("stored %d bytes, want %d", stored, encryption.SealedSize(size))
+	}
+
+	// Ranges only read the segments holding them from storage
+	for _, rng := range []storage.Range{
+		{Start: encryption.SegmentSize - 3, End: encryption.SegmentSize + 5},
+		{Start: 2*encryption.SegmentSize + 1, End: 3 * encryption.SegmentSize},
+		{Start: size - 1, End: size},
+		{Start: 10, End: size + 100},
+	} {
+		body, _ := readObject(t, svc, "bucket", "big", GetObjectOptions{Range: &rng})
+		if want := string(data[rng.Start:min(rng.End, size)]); body != want {
+			t.Errorf("GetObject(%d-%d) returned %d bytes, want %d", rng.Start, rng.End, len(body), len(want))
+		}
+	}
+
+	// Copies and multipart uploads are sealed as they stream
+	svc.PutBucketEncryption(ctx, "bucket", &metadata.BucketEncryption{
+		Rule: metadata.EncryptionRule{Apply: metadata.ApplyEncryptionConfiguration{SSEAlgorithm: SSEAES256}},
+	})
+	if _, err := svc.CopyObject(ctx, "bucket", "big", "bucket", "copy", CopyObjectOptions{}); err != nil {
+		t.Fatalf("CopyObject() error = %v", err)
+	}
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "multi", PutObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := bytes.Repeat([]byte{'x'}, 5*1024*1024)
+	var parts []PartInfo
+	for i, chunk := range [][]byte{first, data} {
+		part, err := svc.UploadPart(ctx, "bucket", "multi", upload.UploadID, i+1, bytes.NewReader(chunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, PartInfo{PartNumber: i + 1, ETag: part.ETag})
+	}
+	if _, err := svc.CompleteMultipartUpload(ctx, "bucket", "multi", upload.UploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	for key, want := range map[string]string{"copy": string(data), "multi": string(first) + string(data)} {
+		if body, result := readObject(t, svc, "bucket", key, GetObjectOptions{}); body != want || result.ServerSideEncryption != SSEAES256 {
+			t.Errorf("GetObject(%s) returned %d bytes with SSE %q, want %d bytes", key, len(body), result.ServerSideEncryption, len(want))
+		}
+	}
+
+	// A tampered segment fails the read
+	store.objects["bucket/big"][encryption.SegmentSize+encryption.Overhead+1] ^= 1
+	result, err := svc.GetObject(ctx, "bucket", "big", GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Body.Close()
+	if _, err := io.ReadAll(result.Body); err == nil {
+		t.Error("reading a tampered object should fail")
+	}
+}
+
+func TestObjectService_SSE_NoMasterKey(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	_, err := svc.PutObject(ctx, "bucket", "a", bytes.NewReader([]byte("x")), PutObjectOptions{ServerSideEncryption: SSEAES256})
+	if !errors.Is(err, ErrEncryptionUnavailable) {
+		t.Errorf("PutObject(AES256) without a master key error = %v, want ErrEncryptionUnavailable", err)
+	}
+	if err := svc.SetEncryptionKey([]byte("short")); err == nil {
+		t.Error("SetEncryptionKey() should reject a key that is not 32 bytes")
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/tags"
+)
+
+// PutObjectTags replaces the tag set of an existing object, or of one of its
+// versions when versionID is set. The set is checked against the S3 limits
+// (at most 10 tags, keys up to 128 and values up to 256 characters, no
+// duplicate keys) before anything is stored.
+func (s *ObjectService) PutObjectTags(ctx context.Context, bucket, key, versionID string, tagSet tags.TagSet) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := tags.NewTagValidator().Validate(tagSet); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+	if err := s.requireObject(ctx, bucket, key); err != nil {
+		return err
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return err
+	}
+	return s.checkMetadataWrite(s.metadata.PutObjectTags(ctx, bucket, key, version, tagSet.ToMap()))
+}
+
+// GetObjectTags returns the tag set of an existing object or of one of its
+// versions, which is empty if it was never tagged. A version without tags
+// of its own has the object's.
+func (s *ObjectService) GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.requireObject(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+	tagSet, err := s.metadata.GetObjectTags(ctx, bucket, key, version)
+	if err != nil || tagSet != nil || version == "" {
+		return tagSet, err
+	}
+	return s.metadata.GetObjectTags(ctx, bucket, key, "")
+}
+
+// DeleteObjectTags removes all tags from an existing object or from one of
+// its versions. A version is left with an empty tag set, so the object's
+// tags do not apply to it either.
+func (s *ObjectService) DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error {
+	key = s.normalizeKey(ctx, bucket, key)
+	if err := s.requireObject(ctx, bucket, key); err != nil {
+		return err
+	}
+	if err := s.checkBucketMode(ctx, bucket, true); err != nil {
+		return err
+	}
+	if err := s.requireWritable(ctx); err != nil {
+		return err
+	}
+	version, err := s.entryVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		return s.checkMetadataWrite(s.metadata.PutObjectTags(ctx, bucket, key, version, map[string]string{}))
+	}
+	return s.checkMetadataWrite(s.metadata.DeleteObjectTags(ctx, bucket, key, ""))
+}
+
+// requireObject reports ErrBucketNotFound or ErrObjectNotFound unless the
+// object exists. The key must already be normalized.
+func (s *ObjectService) requireObject(ctx context.Context, bucket, key string) error {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if _, err := s.metadata.GetObject(ctx, bucket, key, ""); err != nil {
+		return fmt.Errorf("%w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/tags"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_ObjectTags(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "a.txt", bytes.NewBufferString("data"), PutObjectOptions{})
+
+	if err := svc.PutObjectTags(ctx, "bucket", "a.txt", "", tags.TagSet{{Key: "env", Value: "test"}, {Key: "team", Value: "storage"}}); err != nil {
+		t.Fatalf("PutObjectTags() error = %v", err)
+	}
+	got, err := svc.GetObjectTags(ctx, "bucket", "a.txt", "")
+	if err != nil {
+		t.Fatalf("GetObjectTags() error = %v", err)
+	}
+	if len(got) != 2 || got["env"] != "test" || got["team"] != "storage" {
+		t.Errorf("GetObjectTags() = %v", got)
+	}
+
+	if err := svc.DeleteObjectTags(ctx, "bucket", "a.txt", ""); err != nil {
+		t.Fatalf("DeleteObjectTags() error = %v", err)
+	}
+	if got, _ := svc.GetObjectTags(ctx, "bucket", "a.txt", ""); len(got) != 0 {
+		t.Errorf("GetObjectTags() after delete = %v, want none", got)
+	}
+
+	// Deleting the object drops its tags
+	svc.PutObjectTags(ctx, "bucket", "a.txt", "", tags.TagSet{{Key: "env", Value: "test"}})
+	svc.DeleteObject(ctx, "bucket", "a.txt", DeleteObjectOptions{})
+	svc.PutObject(ctx, "bucket", "a.txt", bytes.NewBufferString("data"), PutObjectOptions{})
+	if got, _ := svc.GetObjectTags(ctx, "bucket", "a.txt", ""); len(got) != 0 {
+		t.Errorf("recreated object has tags %v, want none", got)
+	}
+
+	if _, err := svc.GetObjectTags(ctx, "bucket", "missing.txt", ""); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("GetObjectTags(missing) error = %v, want ErrObjectNotFound", err)
+	}
+	if err := svc.PutObjectTags(ctx, "nobucket", "a.txt", "", nil); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("PutObjectTags(missing bucket) error = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestObjectService_PutObjectTags_Limits(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "a.txt", bytes.NewBufferString("data"), PutObjectOptions{})
+
+	var eleven tags.TagSet
+	for i := 0; i < 11; i++ {
+		eleven = append(eleven, tags.Tag{Key: fmt.Sprintf("k%d", i), Value: "v"})
+	}
+
+	tests := []struct {
+		name string
+		set  tags.TagSet
+		ok   bool
+	}{
+		{"ten tags", eleven[:10], true},
+		{"eleven tags", eleven, false},
+		{"key at limit", tags.TagSet{{Key: strings.Repeat("k", 128)}}, true},
+		{"key too long", tags.TagSet{{Key: strings.Repeat("k", 129)}}, false},
+		{"multibyte key at limit", tags.TagSet{{Key: strings.Repeat("é", 128)}}, true},
+		{"value at limit", tags.TagSet{{Key: "k", Value: strings.Repeat("v", 256)}}, true},
+		{"value too long", tags.TagSet{{Key: "k", Value: strings.Repeat("v", 257)}}, false},
+		{"empty key", tags.TagSet{{Key: "", Value: "v"}}, false},
+		{"duplicate key", tags.TagSet{{Key: "k", Value: "1"}, {Key: "k", Value: "2"}}, false},
+	}
+
+	for _, tt := range tests {
+		err := svc.PutObjectTags(ctx, "bucket", "a.txt", "", tt.set)
+		if tt.ok && err != nil {
+			t.Errorf("%s: PutObjectTags() error = %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%s: PutObjectTags() error = %v, want ErrInvalidTag", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+// BucketUsage holds the bytes stored in a bucket and its object count
+type BucketUsage struct {
+	Bytes   int64
+	Objects int64
+}
+
+// usageTracker keeps running per-bucket usage totals so they can be read
+// without listing the bucket. Every change is written through to the metadata
+// store, so the totals survive a restart. A bucket is loaded from the store,
+// or failing that scanned, the first time its usage is requested; writes to
+// buckets with no totals yet are not tracked because that scan will see them.
+type usageTracker struct {
+	mu      sync.Mutex
+	store   metadata.Store
+	logger  *zap.SugaredLogger
+	buckets map[string]*BucketUsage
+}
+
+func newUsageTracker(store metadata.Store, logger *zap.SugaredLogger) *usageTracker {
+	return &usageTracker{store: store, logger: logger, buckets: make(map[string]*BucketUsage)}
+}
+
+// load returns the totals of a bucket, reading the ones persisted by an
+// earlier run the first time. The caller holds t.mu.
+func (t *usageTracker) load(ctx context.Context, bucket string) (*BucketUsage, bool) {
+	if u, ok := t.buckets[bucket]; ok {
+		return u, true
+	}
+	stored, err := t.store.GetBucketUsage(ctx, bucket)
+	if err != nil || stored == nil {
+		return nil, false
+	}
+	u := &BucketUsage{Bytes: stored.Bytes, Objects: stored.Objects}
+	t.buckets[bucket] = u
+	return u, true
+}
+
+// save persists the totals of a bucket. The caller holds t.mu, so saves land
+// in the order the changes were made. A failed save leaves the stored totals
+// behind until the bucket is rescanned.
+func (t *usageTracker) save(ctx context.Context, bucket string, u *BucketUsage) {
+	if err := t.store.PutBucketUsage(ctx, bucket, &metadata.BucketUsage{Bytes: u.Bytes, Objects: u.Objects}); err != nil {
+		t.logger.Warnw("failed to persist bucket usage", "bucket", bucket, "error", err)
+	}
+}
+
+// get returns the usage of a bucket with tracked totals
+func (t *usageTracker) get(ctx context.Context, bucket string) (BucketUsage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.load(ctx, bucket)
+	if !ok {
+		return BucketUsage{}, false
+	}
+	return *u, true
+}
+
+// set replaces the usage of a bucket
+func (t *usageTracker) set(ctx context.Context, bucket string, usage BucketUsage) {
+	t.mu.Lock()
+	t.buckets[bucket] = &usage
+	t.save(ctx, bucket, &usage)
+	t.mu.Unlock()
+	telemetry.SetBucketUsage(bucket, usage.Bytes, usage.Objects)
+}
+
+// remove forgets a bucket
+func (t *usageTracker) remove(bucket string) {
+	t.mu.Lock()
+	delete(t.buckets, bucket)
+	t.mu.Unlock()
+}
+
+// add applies a delta to a bucket with tracked totals
+func (t *usageTracker) add(ctx context.Context, bucket string, bytes, objects int64) {
+	t.mu.Lock()
+	u, ok := t.load(ctx, bucket)
+	if ok {
+		u.Bytes += bytes
+		u.Objects += objects
+		bytes, objects = u.Bytes, u.Objects
+		t.save(ctx, bucket, u)
+	}
+	t.mu.Unlock()
+	if ok {
+		telemetry.SetBucketUsage(bucket, bytes, objects)
+	}
+}
+
+// recordWrite accounts for an object of the given size replacing prev, which
+// is nil when the key did not exist before
+func (t *usageTracker) recordWrite(ctx context.Context, bucket string, prev *metadata.ObjectMetadata, size int64) {
+	if prev != nil {
+		t.add(ctx, bucket, size-prev.Size, 0)
+		return
+	}
+	t.add(ctx, bucket, size, 1)
+}
+
+// recordDelete accounts for an object leaving a bucket
+func (t *usageTracker) recordDelete(ctx context.Context, bucket string, prev *metadata.ObjectMetadata) {
+	if prev != nil {
+		t.add(ctx, bucket, -prev.Size, -1)
+	}
+}
+
+// currentObject returns the metadata of the object currently stored at key,
+// or nil if there is none
+func (s *ObjectService) currentObject(ctx context.Context, bucket, key string) *metadata.ObjectMetadata {
+	return s.objectVersion(ctx, bucket, key, "")
+}
+
+// objectVersion returns the metadata of the given version of key, or of the
+// current object when versionID is empty, or nil if that version is not
+// stored
+func (s *ObjectService) objectVersion(ctx context.Context, bucket, key, versionID string) *metadata.ObjectMetadata {
+	meta, err := s.metadata.GetObject(ctx, bucket, key, versionID)
+	if err != nil || meta == nil {
+		return nil
+	}
+	if versionID != "" && meta.VersionID != versionID {
+		return nil
+	}
+	return meta
+}
+
+// GetBucketUsage returns the bytes and object count stored in a bucket
+func (s *ObjectService) GetBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error) {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+
+	if usage, ok := s.usage.get(ctx, bucket); ok {
+		return &usage, nil
+	}
+	return s.RescanBucketUsage(ctx, bucket)
+}
+
+// RescanBucketUsage recomputes a bucket's usage from its object metadata and
+// replaces the running totals. Writes that race with the scan can leave the
+// totals slightly off; running the rescan again reconciles them.
+func (s *ObjectService) RescanBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error) {
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+
+	usage := BucketUsage{}
+	err := s.metadata.ListObjectsFunc(ctx, bucket, "", "", func(obj metadata.ObjectMetadata) error {
+		usage.Bytes += obj.Size
+		usage.Objects++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	s.usage.set(ctx, bucket, usage)
+	return &usage, nil
+}
+
+// ReconcileBucketUsage rescans the usage of every bucket, replacing the
+// running totals and the per-bucket metrics. It is meant to run once at
+// startup, so totals left behind by an unclean shutdown are corrected and
+// every bucket is tracked from then on. Buckets that fail to scan are
+// logged and skipped.
+func (s *ObjectService) ReconcileBucketUsage(ctx context.Context) error {
+	buckets, err := s.metadata.ListBuckets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list buckets: %w", err)
+	}
+	for _, bucket := range buckets {
+		if _, err := s.RescanBucketUsage(ctx, bucket); err != nil {
+			s.logger.Warnw("failed to rescan bucket usage", "bucket", bucket, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+func assertUsage(t *testing.T, svc *ObjectService, bucket string, wantBytes, wantObjects int64) {
+	t.Helper()
+	usage, err := svc.GetBucketUsage(context.Background(), bucket)
+	if err != nil {
+		t.Fatalf("GetBucketUsage(%s) error = %v", bucket, err)
+	}
+	if usage.Bytes != wantBytes || usage.Objects != wantObjects {
+		t.Errorf("usage(%s) = %d bytes / %d objects, want %d / %d",
+			bucket, usage.Bytes, usage.Objects, wantBytes, wantObjects)
+	}
+}
+
+func TestObjectService_BucketUsage_PutAndDelete(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	assertUsage(t, svc, "bucket", 0, 0)
+
+	svc.PutObject(ctx, "bucket", "a", bytes.NewReader(make([]byte, 100)), PutObjectOptions{})
+	svc.PutObject(ctx, "bucket", "b", bytes.NewReader(make([]byte, 50)), PutObjectOptions{})
+	assertUsage(t, svc, "bucket", 150, 2)
+
+	// Overwriting replaces the old size rather than adding an object
+	svc.PutObject(ctx, "bucket", "a", bytes.NewReader(make([]byte, 10)), PutObjectOptions{})
+	assertUsage(t, svc, "bucket", 60, 2)
+
+	if _, err := svc.DeleteObject(ctx, "bucket", "b", DeleteObjectOptions{}); err != nil {
+		t.Fatalf("DeleteObject() error = %v", err)
+	}
+	assertUsage(t, svc, "bucket", 10, 1)
+
+	// Deleting a missing key leaves usage alone
+	svc.DeleteObject(ctx, "bucket", "missing", DeleteObjectOptions{})
+	assertUsage(t, svc, "bucket", 10, 1)
+}
+
+func TestObjectService_BucketUsage_DeleteVersion(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	result, err := svc.PutObject(ctx, "bucket", "a", bytes.NewReader(make([]byte, 100)), PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+
+	// A version that is not stored deletes nothing, so the current object
+	// and its usage stay
+	if _, err := svc.DeleteObject(ctx, "bucket", "a", DeleteObjectOptions{VersionID: "other"}); err != nil {
+		t.Fatalf("DeleteObject(other version) error = %v", err)
+	}
+	assertUsage(t, svc, "bucket", 100, 1)
+	if _, err := svc.HeadObject(ctx, "bucket", "a"); err != nil {
+		t.Errorf("HeadObject() after deleting another version error = %v", err)
+	}
+
+	if _, err := svc.DeleteObject(ctx, "bucket", "a", DeleteObjectOptions{VersionID: result.VersionID}); err != nil {
+		t.Fatalf("DeleteObject(current version) error = %v", err)
+	}
+	assertUsage(t, svc, "bucket", 0, 0)
+}
+
+func TestObjectService_BucketUsage_Persisted(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "a", bytes.NewReader(make([]byte, 30)), PutObjectOptions{})
+
+	// A restarted engine reads the stored totals rather than rescanning, so
+	// metadata removed behind its back still counts until a rescan
+	meta.DeleteObject(ctx, "bucket", "a", "")
+	restarted := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	restarted.PutObject(ctx, "bucket", "b", bytes.NewReader(make([]byte, 20)), PutObjectOptions{})
+	assertUsage(t, restarted, "bucket", 50, 2)
+}
+
+func TestObjectService_BucketUsage_CopyAndMultipart(t *testing.T) {
+	svc := New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	svc.minPartSize = 0
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "src")
+	svc.CreateBucket(ctx, "dst")
+
+	svc.PutObject(ctx, "src", "obj", bytes.NewReader(make([]byte, 40)), PutObjectOptions{})
+	if _, err := svc.CopyObject(ctx, "src", "obj", "dst", "copy", CopyObjectOptions{}); err != nil {
+		t.Fatalf("CopyObject() error = %v", err)
+	}
+	assertUsage(t, svc, "src", 40, 1)
+	assertUsage(t, svc, "dst", 40, 1)
+
+	upload, err := svc.CreateMultipartUpload(ctx, "dst", "big", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	var parts []PartInfo
+	for i := 1; i <= 2; i++ {
+		part, err := svc.UploadPart(ctx, "dst", "big", upload.UploadID, i, bytes.NewReader(make([]byte, 25)))
+		if err != nil {
+			t.Fatalf("UploadPart() error = %v", err)
+		}
+		parts = append(parts, PartInfo{PartNumber: i, ETag: part.ETag, Size: part.Size})
+	}
+
+	// Parts in flight are not part of the bucket's object usage
+	assertUsage(t, svc, "dst", 40, 1)
+
+	if _, err := svc.CompleteMultipartUpload(ctx, "dst", "big", upload.UploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error = %v", err)
+	}
+	assertUsage(t, svc, "dst", 90, 2)
+}
+
+func TestObjectService_RescanBucketUsage(t *testing.T) {
+	meta := NewMockMetadataStore()
+	svc := New(NewMockStorageBackend(), meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutObject(ctx, "bucket", "a", bytes.NewReader(make([]byte, 30)), PutObjectOptions{})
+
+	// Simulate drift by removing metadata behind the engine's back
+	meta.DeleteObject(ctx, "bucket", "a", "")
+	assertUsage(t, svc, "bucket", 30, 1)
+
+	usage, err := svc.RescanBucketUsage(ctx, "bucket")
+	if err != nil {
+		t.Fatalf("RescanBucketUsage() error = %v", err)
+	}
+	if usage.Bytes != 0 || usage.Objects != 0 {
+		t.Errorf("RescanBucketUsage() = %+v, want empty", usage)
+	}
+	assertUsage(t, svc, "bucket", 0, 0)
+
+	if _, err := svc.GetBucketUsage(ctx, "nonexistent"); err == nil {
+		t.Error("GetBucketUsage() should fail for a missing bucket")
+	}
+}
+
+func TestObjectService_ReconcileBucketUsage(t *testing.T) {
+	storage, meta := NewMockStorageBackend(), NewMockMetadataStore()
+	svc := New(storage, meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "reconciled")
+	svc.PutObject(ctx, "reconciled", "a", bytes.NewReader(make([]byte, 40)), PutObjectOptions{})
+	svc.PutObject(ctx, "reconciled", "b", bytes.NewReader(make([]byte, 2)), PutObjectOptions{})
+
+	// Totals persisted by an earlier run are stale after an unclean stop
+	meta.PutBucketUsage(ctx, "reconciled", &metadata.BucketUsage{Bytes: 7, Objects: 9})
+	restarted := New(storage, meta, zap.NewNop().Sugar())
+	if err := restarted.ReconcileBucketUsage(ctx); err != nil {
+		t.Fatalf("ReconcileBucketUsage() error = %v", err)
+	}
+	assertUsage(t, restarted, "reconciled", 42, 2)
+
+	found := false
+	for _, stats := range telemetry.GetBucketStats() {
+		if stats.Bucket != "reconciled" {
+			continue
+		}
+		found = true
+		if stats.Bytes != 42 || stats.Objects != 2 {
+			t.Errorf("bucket stats = %+v, want 42 bytes / 2 objects", stats)
+		}
+	}
+	if !found {
+		t.Error("bucket stats do not include the reconciled bucket")
+	}
+}
@@ -0,0 +1,315 @@
+package engine
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/storage"
+	"github.com/openendpoint/openendpoint/internal/telemetry"
+)
+
+// DefaultVacuumMinAge is how old a part file must be before the vacuum job
+// will consider it. It protects parts whose upload metadata is written after
+// the vacuum pass has already snapshotted the live uploads.
+const DefaultVacuumMinAge = 1 * time.Hour
+
+// DefaultMultipartUploadMaxAge is how long a multipart upload may stay
+// incomplete before the sweeper aborts it
+const DefaultMultipartUploadMaxAge = 7 * 24 * time.Hour
+
+// VacuumResult contains the result of a vacuum pass
+type VacuumResult struct {
+	PartsScanned   int
+	PartsReclaimed int
+	BytesReclaimed int64
+}
+
+// VacuumOrphanedParts deletes stored part files that no longer belong to an
+// active multipart upload. Parts younger than minAge are always kept.
+func (s *ObjectService) VacuumOrphanedParts(ctx context.Context, minAge time.Duration) (*VacuumResult, error) {
+	buckets, err := s.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VacuumResult{}
+	for _, bucket := range buckets {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if err := s.vacuumBucket(ctx, bucket.Name, minAge, result); err != nil {
+			s.logger.Warnw("failed to vacuum bucket", "bucket", bucket.Name, "error", err)
+		}
+	}
+
+	if result.PartsReclaimed > 0 {
+		s.logger.Infow("vacuum reclaimed orphaned parts",
+			"parts", result.PartsReclaimed,
+			"bytes", result.BytesReclaimed)
+	}
+
+	return result, nil
+}
+
+// vacuumBucket reclaims orphaned parts in a single bucket
+func (s *ObjectService) vacuumBucket(ctx context.Context, bucket string, minAge time.Duration, result *VacuumResult) error {
+	// Snapshot live uploads before listing storage so that any upload created
+	// afterwards is covered by the minAge check instead.
+	uploads, err := s.metadata.ListMultipartUploads(ctx, bucket, "")
+	if err != nil {
+		return err
+	}
+	live := make(map[string]bool, len(uploads))
+	for _, u := range uploads {
+		live[u.UploadID] = true
+	}
+
+	listing, err := s.storage.List(ctx, bucket, bucket+"/", storage.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	cutoff := s.clock.Now().Add(-minAge).Unix()
+	for _, obj := range listing.Objects {
+		key, uploadID, ok := parsePartKey(bucket, obj.Key)
+		if !ok {
+			continue
+		}
+		result.PartsScanned++
+
+		if live[uploadID] {
+			continue
+		}
+		if minAge > 0 && obj.LastModified > cutoff {
+			continue
+		}
+		// A regular object may happen to look like a part key
+		if _, err := s.metadata.GetObject(ctx, bucket, obj.Key, ""); err == nil {
+			continue
+		}
+
+		if err := s.storage.Delete(ctx, bucket, obj.Key); err != nil {
+			s.logger.Warnw("failed to delete orphaned part", "bucket", bucket, "partKey", obj.Key, "error", err)
+			continue
+		}
+
+		s.logger.Debugw("reclaimed orphaned part",
+			"bucket", bucket,
+			"key", key,
+			"uploadId", uploadID,
+			"size", obj.Size)
+
+		result.PartsReclaimed++
+		result.BytesReclaimed += obj.Size
+		telemetry.VacuumPartsReclaimed.Inc()
+		telemetry.VacuumBytesReclaimed.Add(float64(obj.Size))
+	}
+
+	return nil
+}
+
+// AbortStaleMultipartUploads aborts multipart uploads started more than
+// maxAge ago, or longer ago than the DaysAfterInitiation of an enabled
+// AbortIncompleteMultipartUpload lifecycle rule covering their key, deleting
+// their parts. With a maxAge of zero only lifecycle rules apply. Resumable
+// uploads expire on their own TTL and are left alone. It returns the number
+// of uploads aborted.
+func (s *ObjectService) AbortStaleMultipartUploads(ctx context.Context, maxAge time.Duration) (int, error) {
+	buckets, err := s.ListBuckets(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.clock.Now()
+	aborted := 0
+	for _, bucket := range buckets {
+		if err := ctx.Err(); err != nil {
+			return aborted, err
+		}
+		rules, err := s.metadata.GetLifecycleRules(ctx, bucket.Name)
+		if err != nil {
+			s.logger.Warnw("failed to get lifecycle rules", "bucket", bucket.Name, "error", err)
+		}
+		uploads, err := s.metadata.ListMultipartUploads(ctx, bucket.Name, "")
+		if err != nil {
+			s.logger.Warnw("failed to list multipart uploads", "bucket", bucket.Name, "error", err)
+			continue
+		}
+		for _, upload := range uploads {
+			if isResumableUploadID(upload.UploadID) {
+				continue
+			}
+			age := incompleteUploadMaxAge(upload.Key, maxAge, rules)
+			if age <= 0 || upload.Initiated > now.Add(-age).Unix() {
+				continue
+			}
+			if err := s.abortStaleUpload(ctx, upload); err != nil {
+				s.logger.Warnw("failed to abort stale multipart upload",
+					"bucket", upload.Bucket, "key", upload.Key, "uploadId", upload.UploadID, "error", err)
+				continue
+			}
+			aborted++
+		}
+	}
+
+	if aborted > 0 {
+		s.logger.Infow("aborted stale multipart uploads", "uploads", aborted)
+	}
+	return aborted, nil
+}
+
+// incompleteUploadMaxAge returns how long an upload of key may stay
+// incomplete: the shortest of maxAge and the DaysAfterInitiation of the
+// enabled lifecycle rules whose prefix covers key, or zero for no limit
+func incompleteUploadMaxAge(key string, maxAge time.Duration, rules []metadata.LifecycleRule) time.Duration {
+	for _, rule := range rules {
+		abort := rule.AbortIncompleteMultipartUpload
+		if rule.Status != "Enabled" || abort == nil || abort.DaysAfterInitiation <= 0 {
+			continue
+		}
+		prefix := rule.Prefix
+		if rule.Filter != nil {
+			prefix = rule.Filter.Prefix
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if age := time.Duration(abort.DaysAfterInitiation) * 24 * time.Hour; maxAge <= 0 || age < maxAge {
+			maxAge = age
+		}
+	}
+	return maxAge
+}
+
+// abortStaleUpload aborts one upload under the object lock, so it cannot
+// race a completion of the same upload
+func (s *ObjectService) abortStaleUpload(ctx context.Context, upload metadata.MultipartUploadMetadata) error {
+	unlock := s.locker.Lock(upload.Bucket, upload.Key)
+	defer unlock()
+
+	return s.AbortMultipartUpload(ctx, upload.Bucket, upload.Key, upload.UploadID)
+}
+
+// parsePartKey splits a part storage key of the form
+// bucket/key/uploadID/partNumber into its object key and upload ID.
+func parsePartKey(bucket, storageKey string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(storageKey, bucket+"/")
+	if !ok {
+		return "", "", false
+	}
+
+	idx := strings.LastIndex(rest, "/")
+	if idx < 0 {
+		return "", "", false
+	}
+	if n, err := strconv.Atoi(rest[idx+1:]); err != nil || n < 1 {
+		return "", "", false
+	}
+	rest = rest[:idx]
+
+	idx = strings.LastIndex(rest, "/")
+	if idx <= 0 {
+		return "", "", false
+	}
+	uploadID := rest[idx+1:]
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", "", false
+	}
+
+	return rest[:idx], uploadID, true
+}
+
+// Vacuumer periodically aborts expired resumable uploads and stale multipart
+// uploads, and reclaims orphaned multipart part files
+type Vacuumer struct {
+	service      *ObjectService
+	interval     time.Duration
+	intervals    *config.WorkerIntervals
+	minAge       time.Duration
+	resumableTTL time.Duration
+	uploadMaxAge time.Duration
+	stopCh       chan struct{}
+	stopOnce     sync.Once
+	wg           sync.WaitGroup
+}
+
+// NewVacuumer creates a new vacuum job
+func NewVacuumer(service *ObjectService, interval, minAge time.Duration) *Vacuumer {
+	return &Vacuumer{
+		service:      service,
+		interval:     interval,
+		minAge:       minAge,
+		resumableTTL: DefaultResumableUploadTTL,
+		uploadMaxAge: DefaultMultipartUploadMaxAge,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// SetIntervals makes the job take its run interval from shared worker
+// intervals, where it is the multipart sweeper. It must be called before
+// Start.
+func (v *Vacuumer) SetIntervals(intervals *config.WorkerIntervals) {
+	v.intervals = intervals
+	v.interval = intervals.Get(config.WorkerMultipartSweeper)
+}
+
+// SetUploadMaxAge sets how long a multipart upload may stay incomplete
+// before it is aborted. Zero leaves that to AbortIncompleteMultipartUpload
+// lifecycle rules. It must be called before Start.
+func (v *Vacuumer) SetUploadMaxAge(maxAge time.Duration) {
+	v.uploadMaxAge = maxAge
+}
+
+// Start starts the vacuum loop
+func (v *Vacuumer) Start() {
+	v.wg.Add(1)
+	go v.run()
+	v.service.logger.Infow("vacuum job started", "interval", v.interval, "minAge", v.minAge)
+}
+
+// Stop stops the vacuum loop and waits for a running pass to finish
+func (v *Vacuumer) Stop() {
+	v.stopOnce.Do(func() {
+		close(v.stopCh)
+		v.wg.Wait()
+	})
+}
+
+// run runs the vacuum loop
+func (v *Vacuumer) run() {
+	defer v.wg.Done()
+
+	for v.intervals.Sleep(config.WorkerMultipartSweeper, v.interval, v.stopCh) {
+		v.runOnce()
+	}
+}
+
+// runOnce performs a single vacuum pass
+func (v *Vacuumer) runOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-v.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if _, err := v.service.ExpireResumableUploads(ctx, v.resumableTTL); err != nil {
+		v.service.logger.Warnw("expiring resumable uploads failed", "error", err)
+	}
+	if _, err := v.service.AbortStaleMultipartUploads(ctx, v.uploadMaxAge); err != nil {
+		v.service.logger.Warnw("aborting stale multipart uploads failed", "error", err)
+	}
+	if _, err := v.service.VacuumOrphanedParts(ctx, v.minAge); err != nil {
+		v.service.logger.Warnw("vacuum pass failed", "error", err)
+	}
+}
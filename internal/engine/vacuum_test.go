@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/clock"
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/storage"
+	"go.uber.org/zap"
+)
+
+func TestObjectService_VacuumOrphanedParts(t *testing.T) {
+	store := NewMockStorageBackend()
+	meta := NewMockMetadataStore()
+	svc := New(store, meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	store.CreateBucket(ctx, "bucket")
+	meta.CreateBucket(ctx, "bucket")
+
+	// Live upload with one part
+	upload, err := svc.CreateMultipartUpload(ctx, "bucket", "live", PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload() error = %v", err)
+	}
+	if _, err := svc.UploadPart(ctx, "bucket", "live", upload.UploadID, 1, bytes.NewReader([]byte("live part"))); err != nil {
+		t.Fatalf("UploadPart() error = %v", err)
+	}
+	livePartKey := fmt.Sprintf("bucket/live/%s/1", upload.UploadID)
+
+	// Orphaned part whose upload metadata is gone
+	orphanPartKey := fmt.Sprintf("bucket/orphan/%s/1", uuid.New().String())
+	store.Put(ctx, "bucket", orphanPartKey, bytes.NewReader([]byte("orphan")), 6, storage.PutOptions{})
+
+	// Regular object that is not a part
+	if _, err := svc.PutObject(ctx, "bucket", "regular.txt", bytes.NewReader([]byte("data")), PutObjectOptions{}); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+
+	result, err := svc.VacuumOrphanedParts(ctx, 0)
+	if err != nil {
+		t.Fatalf("VacuumOrphanedParts() error = %v", err)
+	}
+	if result.PartsScanned != 2 {
+		t.Errorf("PartsScanned = %d, want 2", result.PartsScanned)
+	}
+	if result.PartsReclaimed != 1 {
+		t.Errorf("PartsReclaimed = %d, want 1", result.PartsReclaimed)
+	}
+	if result.BytesReclaimed != 6 {
+		t.Errorf("BytesReclaimed = %d, want 6", result.BytesReclaimed)
+	}
+
+	if _, err := store.Head(ctx, "bucket", orphanPartKey); err == nil {
+		t.Error("orphaned part should have been reclaimed")
+	}
+	if _, err := store.Head(ctx, "bucket", livePartKey); err != nil {
+		t.Error("live upload part should be kept")
+	}
+	if _, err := store.Head(ctx, "bucket", "regular.txt"); err != nil {
+		t.Error("regular object should be kept")
+	}
+}
+
+func TestObjectService_VacuumOrphanedParts_MinAge(t *testing.T) {
+	// The mock backend reports LastModified as zero, so give it a real one
+	store := &timedStorage{MockStorageBackend: NewMockStorageBackend()}
+	svc := New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	store.CreateBucket(ctx, "bucket")
+	orphanPartKey := fmt.Sprintf("bucket/orphan/%s/1", uuid.New().String())
+	store.Put(ctx, "bucket", orphanPartKey, bytes.NewReader([]byte("orphan")), 6, storage.PutOptions{})
+
+	result, err := svc.VacuumOrphanedParts(ctx, DefaultVacuumMinAge)
+	if err != nil {
+		t.Fatalf("VacuumOrphanedParts() error = %v", err)
+	}
+	if result.PartsReclaimed != 0 {
+		t.Errorf("PartsReclaimed = %d, want 0 for a fresh part", result.PartsReclaimed)
+	}
+}
+
+// timedStorage stamps listed objects with the current time
+type timedStorage struct {
+	*MockStorageBackend
+}
+
+func (s *timedStorage) List(ctx context.Context, bucket, prefix string, opts storage.ListOptions) (*storage.ListResult, error) {
+	result, err := s.MockStorageBackend.List(ctx, bucket, prefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Objects {
+		result.Objects[i].LastModified = time.Now().Unix()
+	}
+	return result, nil
+}
+
+func TestObjectService_AbortStaleMultipartUploads(t *testing.T) {
+	store := NewMockStorageBackend()
+	meta := NewMockMetadataStore()
+	svc := New(store, meta, zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	store.CreateBucket(ctx, "bucket")
+	meta.CreateBucket(ctx, "bucket")
+	meta.PutLifecycleRule(ctx, "bucket", &metadata.LifecycleRule{
+		ID:                             "tmp",
+		Status:                         "Enabled",
+		Filter:                         &metadata.LifecycleFilter{Prefix: "tmp/"},
+		AbortIncompleteMultipartUpload: &metadata.AbortIncompleteMultipartUpload{DaysAfterInitiation: 1},
+	})
+
+	partKeys := make(map[string]string)
+	for _, key := range []string{"tmp/upload", "data/upload"} {
+		upload, err := svc.CreateMultipartUpload(ctx, "bucket", key, PutObjectOptions{})
+		if err != nil {
+			t.Fatalf("CreateMultipartUpload(%s) error = %v", key, err)
+		}
+		if _, err := svc.UploadPart(ctx, "bucket", key, upload.UploadID, 1, bytes.NewReader([]byte("part"))); err != nil {
+			t.Fatalf("UploadPart(%s) error = %v", key, err)
+		}
+		partKeys[key] = fmt.Sprintf("bucket/%s/%s/1", key, upload.UploadID)
+	}
+
+	fake := clock.NewFake(time.Now())
+	svc.SetClock(fake)
+	remaining := func() int {
+		uploads, _ := meta.ListMultipartUploads(ctx, "bucket", "")
+		return len(uploads)
+	}
+
+	// Nothing is old enough yet
+	if n, err := svc.AbortStaleMultipartUploads(ctx, 7*24*time.Hour); err != nil || n != 0 {
+		t.Fatalf("AbortStaleMultipartUploads() = %d, %v, want 0", n, err)
+	}
+
+	// The lifecycle rule aborts the upload under its prefix after a day
+	fake.Advance(36 * time.Hour)
+	if n, err := svc.AbortStaleMultipartUploads(ctx, 7*24*time.Hour); err != nil || n != 1 {
+		t.Fatalf("AbortStaleMultipartUploads() after a day = %d, %v, want 1", n, err)
+	}
+	if _, err := store.Head(ctx, "bucket", partKeys["tmp/upload"]); err == nil {
+		t.Error("part of the aborted upload should have been deleted")
+	}
+	if _, err := store.Head(ctx, "bucket", partKeys["data/upload"]); err != nil {
+		t.Errorf("part of the live upload was deleted: %v", err)
+	}
+
+	// Without a max age only lifecycle rules apply
+	fake.Advance(30 * 24 * time.Hour)
+	if n, err := svc.AbortStaleMultipartUploads(ctx, 0); err != nil || n != 0 {
+		t.Fatalf("AbortStaleMultipartUploads(0) = %d, %v, want 0", n, err)
+	}
+	if n, err := svc.AbortStaleMultipartUploads(ctx, 7*24*time.Hour); err != nil || n != 1 {
+		t.Fatalf("AbortStaleMultipartUploads() after the max age = %d, %v, want 1", n, err)
+	}
+	if remaining() != 0 {
+		t.Errorf("%d uploads remain, want 0", remaining())
+	}
+}
+
+func TestParsePartKey(t *testing.T) {
+	id := uuid.New().String()
+	tests := []struct {
+		storageKey string
+		wantKey    string
+		wantOK     bool
+	}{
+		{"bucket/a/b.txt/" + id + "/3", "a/b.txt", true},
+		{"bucket/key/" + id + "/0", "", false},
+		{"bucket/key/not-a-uuid/1", "", false},
+		{"bucket/" + id + "/1", "", false},
+		{"other/key/" + id + "/1", "", false},
+		{"regular.txt", "", false},
+	}
+
+	for _, tt := range tests {
+		key, uploadID, ok := parsePartKey("bucket", tt.storageKey)
+		if ok != tt.wantOK {
+			t.Errorf("parsePartKey(%q) ok = %v, want %v", tt.storageKey, ok, tt.wantOK)
+			continue
+		}
+		if ok && (key != tt.wantKey || uploadID != id) {
+			t.Errorf("parsePartKey(%q) = %q, %q", tt.storageKey, key, uploadID)
+		}
+	}
+}
+
+func TestVacuumer_UsesWorkerInterval(t *testing.T) {
+	store := NewMockStorageBackend()
+	svc := New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	ctx := context.Background()
+
+	store.CreateBucket(ctx, "bucket")
+	svc.metadata.CreateBucket(ctx, "bucket")
+	orphanPartKey := fmt.Sprintf("bucket/orphan/%s/1", uuid.New().String())
+	store.Put(ctx, "bucket", orphanPartKey, bytes.NewReader([]byte("orphan")), 6, storage.PutOptions{})
+
+	intervals := config.NewWorkerIntervals(config.WorkersConfig{MultipartSweeperInterval: 3600})
+	vacuumer := NewVacuumer(svc, 6*time.Hour, 0)
+	vacuumer.SetIntervals(intervals)
+	if vacuumer.interval != time.Hour {
+		t.Errorf("interval = %v, want the configured %v", vacuumer.interval, time.Hour)
+	}
+
+	vacuumer.Start()
+	defer vacuumer.Stop()
+
+	// Shortening the interval while the job waits reschedules its next run
+	if err := intervals.Set(config.WorkerMultipartSweeper, 10*time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := store.Head(ctx, "bucket", orphanPartKey); err != nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("orphaned part was not reclaimed after the interval was shortened")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,400 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/events"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/storage"
+	"github.com/openendpoint/openendpoint/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+// ObjectVersion is one entry of a version listing: either a stored version
+// of an object or a delete marker
+type ObjectVersion struct {
+	Key            string
+	VersionID      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	LastModified   int64
+	ETag           string
+	Size           int64
+	StorageClass   string
+}
+
+// ListObjectVersionsOptions contains options for ListObjectVersions
+type ListObjectVersionsOptions struct {
+	Prefix  string
+	MaxKeys int
+	// KeyMarker resumes a listing after this key, or, with VersionIDMarker,
+	// after that version of it
+	KeyMarker       string
+	VersionIDMarker string
+}
+
+// ListObjectVersionsResult is the result of ListObjectVersions
+type ListObjectVersionsResult struct {
+	Versions    []ObjectVersion
+	Prefix      string
+	MaxKeys     int
+	IsTruncated bool
+	// NextKeyMarker and NextVersionIDMarker continue a truncated listing
+	NextKeyMarker       string
+	NextVersionIDMarker string
+}
+
+// ListObjectVersions lists every version and delete marker under a prefix.
+// Entries are ordered by key and, within a key, newest first; the newest
+// entry of each key is its only IsLatest one, even when it is a delete marker.
+func (s *ObjectService) ListObjectVersions(ctx context.Context, bucket string, opts ListObjectVersionsOptions) (*ListObjectVersionsResult, error) {
+	opts.Prefix = s.normalizeKey(ctx, bucket, opts.Prefix)
+	if _, err := s.metadata.GetBucket(ctx, bucket); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, bucket)
+	}
+	if err := s.checkBucketMode(ctx, bucket, false); err != nil {
+		return nil, err
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 1000
+	}
+
+	metas, err := s.metadata.ListObjectVersions(ctx, bucket, opts.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list object versions: %w", err)
+	}
+
+	versions := make([]ObjectVersion, 0, len(metas))
+	for _, meta := range metas {
+		if !strings.HasPrefix(meta.Key, opts.Prefix) {
+			continue
+		}
+		versions = append(versions, ObjectVersion{
+			Key:            meta.Key,
+			VersionID:      meta.VersionID,
+			IsDeleteMarker: meta.IsDeleteMarker,
+			LastModified:   meta.LastModified,
+			ETag:           meta.ETag,
+			Size:           meta.Size,
+			StorageClass:   meta.StorageClass,
+		})
+	}
+	sortVersions(versions)
+	versions = versions[versionsStart(versions, opts.KeyMarker, opts.VersionIDMarker):]
+
+	result := &ListObjectVersionsResult{
+		Prefix:  opts.Prefix,
+		MaxKeys: opts.MaxKeys,
+	}
+	if len(versions) > opts.MaxKeys {
+		versions = versions[:opts.MaxKeys]
+		result.IsTruncated = true
+		last := versions[len(versions)-1]
+		result.NextKeyMarker, result.NextVersionIDMarker = last.Key, last.VersionID
+	}
+	result.Versions = versions
+	return result, nil
+}
+
+// versionsStart returns the index of the first sorted version after the
+// markers. A key marker alone skips every version of that key; with a
+// version ID marker the listing resumes after that version. An unknown
+// version ID marker resumes at the next key.
+func versionsStart(versions []ObjectVersion, keyMarker, versionIDMarker string) int {
+	if keyMarker == "" {
+		return 0
+	}
+	if versionIDMarker != "" {
+		for i, v := range versions {
+			if v.Key == keyMarker && v.VersionID == versionIDMarker {
+				return i + 1
+			}
+		}
+	}
+	return sort.Search(len(versions), func(i int) bool {
+		return versions[i].Key > keyMarker
+	})
+}
+
+// sortVersions orders versions by key, then newest first, and marks the
+// first entry of each key as the latest. Versions written in the same second
+// are ordered by version ID so listings are stable.
+func sortVersions(versions []ObjectVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.LastModified != b.LastModified {
+			return a.LastModified > b.LastModified
+		}
+		return a.VersionID > b.VersionID
+	})
+
+	for i := range versions {
+		versions[i].IsLatest = i == 0 || versions[i].Key != versions[i-1].Key
+	}
+}
+
+// nullVersionID is the version ID of objects written while a bucket's
+// versioning is suspended. Each such write replaces the previous null version.
+const nullVersionID = "null"
+
+// versionClock keeps version IDs issued within one millisecond in order
+var versionClock struct {
+	sync.Mutex
+	last int64
+	seq  uint16
+}
+
+// newVersionUUID returns a UUIDv7: the creation time in milliseconds, a
+// counter for IDs issued in the same millisecond, then random bits. IDs
+// issued by one process therefore sort in creation order, which the
+// metadata stores rely on to order versions written in the same second.
+func newVersionUUID() string {
+	versionClock.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= versionClock.last {
+		ms = versionClock.last
+		versionClock.seq++
+		if versionClock.seq > 0xfff {
+			ms++
+			versionClock.seq = 0
+		}
+	} else {
+		versionClock.seq = 0
+	}
+	versionClock.last = ms
+	seq := versionClock.seq
+	versionClock.Unlock()
+
+	id := uuid.New()
+	var stamp [8]byte
+	binary.BigEndian.PutUint64(stamp[:], uint64(ms))
+	copy(id[:6], stamp[2:])
+	id[6] = 0x70 | byte(seq>>8)
+	id[7] = byte(seq)
+	return id.String()
+}
+
+// versioningStatus returns the bucket's versioning status, Enabled or
+// Suspended, or "" when versioning was never turned on
+func (s *ObjectService) versioningStatus(ctx context.Context, bucket string) string {
+	versioning, err := s.metadata.GetBucketVersioning(ctx, bucket)
+	if err != nil || versioning == nil {
+		return ""
+	}
+	switch versioning.Status {
+	case "Enabled", "Suspended":
+		return versioning.Status
+	}
+	return ""
+}
+
+// entryVersion checks the version named by a request for an object's tags,
+// retention or legal hold, and returns the version its entries are kept
+// under. An empty versionID addresses the object's own entries, which apply
+// to every version without entries of its own. So does naming the object in
+// an unversioned bucket, which has only the one version. The key must
+// already be normalized.
+func (s *ObjectService) entryVersion(ctx context.Context, bucket, key, versionID string) (string, error) {
+	if versionID == "" {
+		return "", nil
+	}
+	meta := s.objectVersion(ctx, bucket, key, versionID)
+	if meta == nil || meta.IsDeleteMarker {
+		return "", fmt.Errorf("%w: %s/%s?versionId=%s", ErrNoSuchVersion, bucket, key, versionID)
+	}
+	if s.versioningStatus(ctx, bucket) == "" {
+		return "", nil
+	}
+	return versionID, nil
+}
+
+// newVersionID returns the version ID of a new write to bucket
+func (s *ObjectService) newVersionID(ctx context.Context, bucket string) string {
+	if s.versioningStatus(ctx, bucket) == "Suspended" {
+		return nullVersionID
+	}
+	return newVersionUUID()
+}
+
+// versionDataKey is the storage key holding the data of a noncurrent
+// version. The current version's data stays at the object's own key.
+func versionDataKey(bucket, key, versionID string) string {
+	return fmt.Sprintf("%s/%s/versions/%s", bucket, key, versionID)
+}
+
+// isVersionDataKey reports whether a storage key is one made by
+// versionDataKey
+func isVersionDataKey(bucket, storageKey string) bool {
+	rest, ok := strings.CutPrefix(storageKey, bucket+"/")
+	if !ok {
+		return false
+	}
+	idx := strings.LastIndex(rest, "/versions/")
+	if idx <= 0 {
+		return false
+	}
+	versionID := rest[idx+len("/versions/"):]
+	if versionID == nullVersionID {
+		return true
+	}
+	_, err := uuid.Parse(versionID)
+	return err == nil
+}
+
+// dataKey returns the storage key holding the data of meta, a version of key
+func (s *ObjectService) dataKey(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) string {
+	if cur := s.currentObject(ctx, bucket, key); cur != nil && cur.VersionID == meta.VersionID {
+		return key
+	}
+	return versionDataKey(bucket, key, meta.VersionID)
+}
+
+// copyData copies the data stored at srcKey to dstKey in the same bucket
+func (s *ObjectService) copyData(ctx context.Context, bucket, srcKey, dstKey string, meta *metadata.ObjectMetadata) error {
+	data, err := s.storage.Get(ctx, bucket, srcKey, storage.GetOptions{})
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	return s.storage.Put(ctx, bucket, dstKey, data, storedSize(meta), storage.PutOptions{
+		ContentType:     meta.ContentType,
+		ContentEncoding: meta.ContentEncoding,
+		CacheControl:    meta.CacheControl,
+		Metadata:        meta.Metadata,
+		StorageClass:    meta.StorageClass,
+	})
+}
+
+// keepNoncurrent prepares key for a write of version versionID, which will
+// overwrite the data at the object's own key. In a bucket with versioning
+// configured, the current version prev keeps its data by copying it to its
+// version data key, and a noncurrent null version that a suspended write
+// replaces has its data removed. It returns the version the write replaces,
+// for usage accounting: prev itself in a bucket without versioning.
+func (s *ObjectService) keepNoncurrent(ctx context.Context, bucket, key string, prev *metadata.ObjectMetadata, versionID string) (*metadata.ObjectMetadata, error) {
+	if s.versioningStatus(ctx, bucket) == "" {
+		return prev, nil
+	}
+	if prev != nil && prev.VersionID == versionID {
+		return prev, nil
+	}
+
+	if prev != nil {
+		if err := s.copyData(ctx, bucket, key, versionDataKey(bucket, key, prev.VersionID), prev); err != nil {
+			return nil, fmt.Errorf("failed to keep version %s: %w", prev.VersionID, err)
+		}
+	}
+	return s.replacedNullVersion(ctx, bucket, key, versionID), nil
+}
+
+// replacedNullVersion removes the data of the noncurrent null version that a
+// write of versionID replaces, and returns that version. It returns nil when
+// the write is not to the null version or there is no noncurrent one to
+// replace.
+func (s *ObjectService) replacedNullVersion(ctx context.Context, bucket, key, versionID string) *metadata.ObjectMetadata {
+	if versionID != nullVersionID {
+		return nil
+	}
+	old := s.objectVersion(ctx, bucket, key, nullVersionID)
+	if old == nil || old.IsDeleteMarker {
+		return nil
+	}
+	if err := s.storage.Delete(ctx, bucket, versionDataKey(bucket, key, nullVersionID)); err != nil {
+		s.logger.Warnw("failed to delete replaced null version", "bucket", bucket, "key", key, "error", err)
+	}
+	return old
+}
+
+// putDeleteMarker deletes key in a bucket with versioning configured by
+// making a delete marker its latest version. The current version's data moves
+// to its version data key, except when the marker replaces it as the null
+// version, in which case it is deleted. It returns the marker's version ID.
+func (s *ObjectService) putDeleteMarker(ctx context.Context, bucket, key string) (string, error) {
+	prev := s.currentObject(ctx, bucket, key)
+	versionID := s.newVersionID(ctx, bucket)
+
+	replaced := s.replacedNullVersion(ctx, bucket, key, versionID)
+	kept := prev != nil && prev.VersionID != versionID
+	if prev != nil && !kept {
+		replaced = prev
+	}
+	if kept {
+		if err := s.moveData(ctx, bucket, key, versionDataKey(bucket, key, prev.VersionID), prev); err != nil {
+			return "", fmt.Errorf("failed to keep version %s: %w", prev.VersionID, err)
+		}
+	}
+
+	marker := &metadata.ObjectMetadata{
+		Key:            key,
+		Bucket:         bucket,
+		VersionID:      versionID,
+		IsLatest:       true,
+		IsDeleteMarker: true,
+		LastModified:   s.clock.Now().Unix(),
+	}
+	if err := s.checkMetadataWrite(s.metadata.PutObject(ctx, bucket, key, marker)); err != nil {
+		s.logger.Error("failed to save delete marker", zap.Error(err))
+		if kept {
+			if rerr := s.moveData(ctx, bucket, versionDataKey(bucket, key, prev.VersionID), key, prev); rerr != nil {
+				s.logger.Errorw("failed to restore object data", "bucket", bucket, "key", key, "error", rerr)
+			}
+		}
+		return "", fmt.Errorf("failed to save delete marker: %w", err)
+	}
+
+	if prev != nil && !kept {
+		if err := s.storage.Delete(ctx, bucket, key); err != nil {
+			s.logger.Warnw("failed to delete replaced null version", "bucket", bucket, "key", key, "error", err)
+		}
+	}
+	s.usage.recordDelete(ctx, bucket, replaced)
+
+	if prev != nil {
+		s.publish(events.ObjectEvent{
+			Type:      events.EventObjectDeleteMarkerCreated,
+			Bucket:    bucket,
+			Key:       key,
+			VersionID: versionID,
+		})
+	}
+
+	telemetry.IncOperation("DeleteObject")
+	telemetry.OperationsTotal.WithLabelValues("DeleteObject", "success").Inc()
+	return versionID, nil
+}
+
+// moveData moves the data stored at srcKey to dstKey in the same bucket,
+// in place when the backend implements storage.Renamer
+func (s *ObjectService) moveData(ctx context.Context, bucket, srcKey, dstKey string, meta *metadata.ObjectMetadata) error {
+	if renamer, ok := s.storage.(storage.Renamer); ok {
+		return renamer.Rename(ctx, bucket, srcKey, bucket, dstKey)
+	}
+	if err := s.copyData(ctx, bucket, srcKey, dstKey, meta); err != nil {
+		return err
+	}
+	return s.storage.Delete(ctx, bucket, srcKey)
+}
+
+// promoteCurrent moves the data of the version that became current after a
+// version was deleted to the object's own key. before is the version that
+// was current until then.
+func (s *ObjectService) promoteCurrent(ctx context.Context, bucket, key string, before *metadata.ObjectMetadata) {
+	cur := s.currentObject(ctx, bucket, key)
+	if cur == nil || (before != nil && before.VersionID == cur.VersionID) {
+		return
+	}
+	if err := s.moveData(ctx, bucket, versionDataKey(bucket, key, cur.VersionID), key, cur); err != nil {
+		s.logger.Warnw("failed to restore current version data", "bucket", bucket, "key", key, "versionId", cur.VersionID, "error", err)
+	}
+}
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"go.uber.org/zap"
+)
+
+// versionedMetadataStore returns a fixed set of versions from
+// ListObjectVersions, several per key
+type versionedMetadataStore struct {
+	*MockMetadataStore
+	versions []metadata.ObjectMetadata
+}
+
+func (m *versionedMetadataStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]metadata.ObjectMetadata, error) {
+	return m.versions, nil
+}
+
+func TestObjectService_ListObjectVersions(t *testing.T) {
+	store := &versionedMetadataStore{
+		MockMetadataStore: NewMockMetadataStore(),
+		versions: []metadata.ObjectMetadata{
+			{Key: "docs/a.txt", VersionID: "v1", LastModified: 100, IsLatest: true},
+			{Key: "other.txt", VersionID: "o1", LastModified: 150},
+			{Key: "docs/a.txt", VersionID: "dm", LastModified: 300, IsDeleteMarker: true},
+			{Key: "docs/b.txt", VersionID: "b1", LastModified: 50, IsLatest: true},
+			{Key: "docs/a.txt", VersionID: "v2", LastModified: 200, IsLatest: true},
+		},
+	}
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	result, err := svc.ListObjectVersions(ctx, "bucket", ListObjectVersionsOptions{Prefix: "docs/"})
+	if err != nil {
+		t.Fatalf("ListObjectVersions() error = %v", err)
+	}
+
+	want := []struct {
+		key, versionID string
+		latest, marker bool
+	}{
+		{"docs/a.txt", "dm", true, true},
+		{"docs/a.txt", "v2", false, false},
+		{"docs/a.txt", "v1", false, false},
+		{"docs/b.txt", "b1", true, false},
+	}
+	if len(result.Versions) != len(want) {
+		t.Fatalf("got %d versions, want %d: %+v", len(result.Versions), len(want), result.Versions)
+	}
+	for i, w := range want {
+		got := result.Versions[i]
+		if got.Key != w.key || got.VersionID != w.versionID || got.IsLatest != w.latest || got.IsDeleteMarker != w.marker {
+			t.Errorf("Versions[%d] = %+v, want key %s version %s latest %v delete marker %v",
+				i, got, w.key, w.versionID, w.latest, w.marker)
+		}
+	}
+}
+
+func TestObjectService_ListObjectVersions_MaxKeys(t *testing.T) {
+	store := &versionedMetadataStore{
+		MockMetadataStore: NewMockMetadataStore(),
+		versions: []metadata.ObjectMetadata{
+			{Key: "a", VersionID: "1", LastModified: 100},
+			{Key: "a", VersionID: "2", LastModified: 200},
+			{Key: "b", VersionID: "1", LastModified: 100},
+		},
+	}
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	result, err := svc.ListObjectVersions(ctx, "bucket", ListObjectVersionsOptions{MaxKeys: 2})
+	if err != nil {
+		t.Fatalf("ListObjectVersions() error = %v", err)
+	}
+	if !result.IsTruncated || len(result.Versions) != 2 {
+		t.Fatalf("got %d versions truncated=%v, want 2 truncated", len(result.Versions), result.IsTruncated)
+	}
+	if result.Versions[0].VersionID != "2" || !result.Versions[0].IsLatest {
+		t.Errorf("Versions[0] = %+v, want latest version 2", result.Versions[0])
+	}
+}
+
+func TestObjectService_ListObjectVersions_Markers(t *testing.T) {
+	store := &versionedMetadataStore{
+		MockMetadataStore: NewMockMetadataStore(),
+		versions: []metadata.ObjectMetadata{
+			{Key: "a", VersionID: "a1", LastModified: 100},
+			{Key: "a", VersionID: "a2", LastModified: 200},
+			{Key: "a", VersionID: "a3", LastModified: 300, IsDeleteMarker: true},
+			{Key: "b", VersionID: "b1", LastModified: 100},
+			{Key: "c", VersionID: "c1", LastModified: 100},
+			{Key: "c", VersionID: "c2", LastModified: 200},
+		},
+	}
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+
+	// Paging two at a time visits every version once, in order
+	var got []string
+	opts := ListObjectVersionsOptions{MaxKeys: 2}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("ListObjectVersions() did not finish paging")
+		}
+		result, err := svc.ListObjectVersions(ctx, "bucket", opts)
+		if err != nil {
+			t.Fatalf("ListObjectVersions() error = %v", err)
+		}
+		for _, v := range result.Versions {
+			got = append(got, v.VersionID)
+			// Latest is decided across the whole listing, not per page
+			if v.IsLatest != (v.VersionID == "a3" || v.VersionID == "b1" || v.VersionID == "c2") {
+				t.Errorf("version %s IsLatest = %v", v.VersionID, v.IsLatest)
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		opts.KeyMarker, opts.VersionIDMarker = result.NextKeyMarker, result.NextVersionIDMarker
+	}
+	if want := "a3,a2,a1,b1,c2,c1"; strings.Join(got, ",") != want {
+		t.Errorf("paged versions = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	// A key marker alone skips every version of that key
+	result, err := svc.ListObjectVersions(ctx, "bucket", ListObjectVersionsOptions{KeyMarker: "a"})
+	if err != nil {
+		t.Fatalf("ListObjectVersions() error = %v", err)
+	}
+	if len(result.Versions) != 3 || result.Versions[0].Key != "b" {
+		t.Errorf("ListObjectVersions(key-marker a) = %+v, want the versions of b and c", result.Versions)
+	}
+}
+
+func TestObjectService_CopyObject_SourceVersion(t *testing.T) {
+	dir := t.TempDir()
+	store, err := pebble.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutBucketVersioning(ctx, "bucket", &metadata.BucketVersioning{Status: "Enabled"})
+	first, _ := svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("first")), PutObjectOptions{})
+	svc.PutObject(ctx, "bucket", "src", bytes.NewReader([]byte("second")), PutObjectOptions{})
+
+	result, err := svc.CopyObject(ctx, "bucket", "src", "bucket", "restored", CopyObjectOptions{SourceVersionID: first.VersionID})
+	if err != nil {
+		t.Fatalf("CopyObject(versionId) error = %v", err)
+	}
+	if result.SourceVersionID != first.VersionID {
+		t.Errorf("SourceVersionID = %q, want %q", result.SourceVersionID, first.VersionID)
+	}
+	obj, err := svc.GetObject(ctx, "bucket", "restored", GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	data, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(data) != "first" {
+		t.Errorf("copied data = %q, want the first version", data)
+	}
+
+	if _, err := svc.CopyObject(ctx, "bucket", "src", "bucket", "restored", CopyObjectOptions{SourceVersionID: "missing"}); !errors.Is(err, ErrNoSuchVersion) {
+		t.Errorf("CopyObject(unknown versionId) error = %v, want ErrNoSuchVersion", err)
+	}
+}
+
+func TestObjectService_DeleteObject_Result(t *testing.T) {
+	store, err := pebble.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := New(NewMockStorageBackend(), store, zap.NewNop().Sugar())
+	ctx := context.Background()
+	svc.CreateBucket(ctx, "bucket")
+	svc.PutBucketVersioning(ctx, "bucket", &metadata.BucketVersioning{Status: "Enabled"})
+	put, _ := svc.PutObject(ctx, "bucket", "key", bytes.NewReader([]byte("data")), PutObjectOptions{})
+
+	marker, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{})
+	if err != nil {
+		t.Fatalf("DeleteObject() error = %v", err)
+	}
+	if !marker.DeleteMarker || marker.VersionID == "" || marker.VersionID == put.VersionID {
+		t.Errorf("DeleteObject() = %+v, want a new delete marker", marker)
+	}
+
+	removed, err := svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{VersionID: marker.VersionID})
+	if err != nil {
+		t.Fatalf("DeleteObject(marker) error = %v", err)
+	}
+	if !removed.DeleteMarker || removed.VersionID != marker.VersionID {
+		t.Errorf("DeleteObject(marker) = %+v, want the removed marker", removed)
+	}
+
+	removed, err = svc.DeleteObject(ctx, "bucket", "key", DeleteObjectOptions{VersionID: put.VersionID})
+	if err != nil {
+		t.Fatalf("DeleteObject(version) error = %v", err)
+	}
+	if removed.DeleteMarker || removed.VersionID != put.VersionID {
+		t.Errorf("DeleteObject(version) = %+v, want the removed version", removed)
+	}
+}
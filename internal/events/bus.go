@@ -0,0 +1,254 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// subscriberBufferSize is how many events a subscriber may fall behind
+// before further events are dropped for it
+const subscriberBufferSize = 1024
+
+// ObjectEvent describes a change to an object. The engine publishes one
+// ObjectEvent per operation and every subscriber receives its own copy.
+type ObjectEvent struct {
+	Type        EventType
+	Bucket      string
+	Key         string
+	Size        int64
+	ETag        string
+	VersionID   string
+	ContentType string
+	Time        time.Time
+
+	// SourceBucket and SourceKey are set for copies and moves
+	SourceBucket string
+	SourceKey    string
+
+	// UploadID is set for completed multipart uploads
+	UploadID string
+}
+
+// S3Event converts the event to the S3 notification format
+func (e ObjectEvent) S3Event() Event {
+	event := CreateEvent(string(e.Type), e.Bucket, e.Key, e.ETag, e.Size)
+	event.EventTime = e.Time
+	event.S3.Object.VersionID = e.VersionID
+	return event
+}
+
+// Handler consumes object events
+type Handler func(ObjectEvent)
+
+// subscription delivers events to one handler from its own goroutine, so a
+// slow consumer cannot hold up publishers or other consumers
+type subscription struct {
+	name    string
+	handler Handler
+	ch      chan ObjectEvent
+	dropped atomic.Int64
+
+	// enqueued holds the time each event still queued or being handled was
+	// published, oldest first. Publish appends under mu as it queues an
+	// event and the delivery goroutine removes the head once the handler
+	// returns, so the head is always the oldest undelivered event.
+	mu       sync.Mutex
+	enqueued []time.Time
+}
+
+// backlog returns the number of events queued or being handled and when the
+// oldest of them was published
+func (sub *subscription) backlog() (int, time.Time) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if len(sub.enqueued) == 0 {
+		return 0, time.Time{}
+	}
+	return len(sub.enqueued), sub.enqueued[0]
+}
+
+// QueueStatus describes the backlog of one subscriber
+type QueueStatus struct {
+	Name string
+	// Depth counts events queued or being handled
+	Depth    int
+	Capacity int
+	Dropped  int64
+	// OldestAge is how long the oldest undelivered event has waited
+	OldestAge time.Duration
+}
+
+// Bus fans object events out to independent subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscription
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler under name. The returned function removes
+// the subscription after delivering any events already queued for it.
+func (b *Bus) Subscribe(name string, handler Handler) func() {
+	sub := &subscription{
+		name:    name,
+		handler: handler,
+		ch:      make(chan ObjectEvent, subscriberBufferSize),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return func() {}
+	}
+	b.subscribers = append(b.subscribers, sub)
+	b.wg.Add(1)
+	b.mu.Unlock()
+
+	go func() {
+		defer b.wg.Done()
+		for event := range sub.ch {
+			sub.handler(event)
+			sub.mu.Lock()
+			sub.enqueued = sub.enqueued[1:]
+			sub.mu.Unlock()
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.remove(sub) })
+	}
+}
+
+// remove detaches a subscription and stops its goroutine
+func (b *Bus) remove(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, s := range b.subscribers {
+		if s == sub {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// Publish delivers an event to every subscriber without blocking. A
+// subscriber whose queue is full misses the event and has it counted as
+// dropped.
+func (b *Bus) Publish(event ObjectEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	now := time.Now()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subscribers {
+		sub.mu.Lock()
+		select {
+		case sub.ch <- event:
+			sub.enqueued = append(sub.enqueued, now)
+		default:
+			sub.dropped.Add(1)
+		}
+		sub.mu.Unlock()
+	}
+}
+
+// Dropped returns the number of events dropped per subscriber name
+func (b *Bus) Dropped() map[string]int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	dropped := make(map[string]int64, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		dropped[sub.name] += sub.dropped.Load()
+	}
+	return dropped
+}
+
+// Queues reports the backlog of every subscriber
+func (b *Bus) Queues() []QueueStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := time.Now()
+	queues := make([]QueueStatus, 0, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		depth, oldest := sub.backlog()
+		status := QueueStatus{
+			Name:     sub.name,
+			Depth:    depth,
+			Capacity: cap(sub.ch),
+			Dropped:  sub.dropped.Load(),
+		}
+		if depth > 0 {
+			status.OldestAge = now.Sub(oldest)
+		}
+		queues = append(queues, status)
+	}
+	return queues
+}
+
+// Drain waits until every event published so far has been handled, or until
+// ctx is done. Publishing continues while it waits, so on a busy server it
+// returns once the queues are momentarily empty.
+func (b *Bus) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if b.pending() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// pending counts events queued or being handled across all subscribers
+func (b *Bus) pending() int {
+	total := 0
+	for _, q := range b.Queues() {
+		total += q.Depth
+	}
+	return total
+}
+
+// Close stops accepting subscribers and waits for queued events to be handled
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for _, sub := range b.subscribers {
+		close(sub.ch)
+	}
+	b.subscribers = nil
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// HandleObjectEvent forwards an object event to the bucket's notification
+// subscribers. It can be passed directly to Bus.Subscribe.
+func (en *EventNotifier) HandleObjectEvent(event ObjectEvent) {
+	en.Notify(event.Bucket, event.S3Event())
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBus_FanOut(t *testing.T) {
+	bus := NewBus()
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		bus.Subscribe(name, func(e ObjectEvent) {
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+		})
+	}
+
+	bus.Publish(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "key"})
+	bus.Publish(ObjectEvent{Type: EventObjectRemoved, Bucket: "bucket", Key: "key"})
+	bus.Close()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if counts[name] != 2 {
+			t.Errorf("subscriber %s received %d events, want 2", name, counts[name])
+		}
+	}
+}
+
+func TestBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	bus.Subscribe("slow", func(e ObjectEvent) { <-release })
+
+	fast := make(chan ObjectEvent, subscriberBufferSize+10)
+	bus.Subscribe("fast", func(e ObjectEvent) { fast <- e })
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBufferSize+10; i++ {
+			bus.Publish(ObjectEvent{Type: EventObjectUploaded})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	if dropped := bus.Dropped()["slow"]; dropped == 0 {
+		t.Error("slow subscriber should have dropped events")
+	}
+	close(release)
+	bus.Close()
+
+	if len(fast) == 0 {
+		t.Error("fast subscriber should keep receiving events")
+	}
+}
+
+func TestBus_QueuesAndDrain(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	bus.Subscribe("blocked", func(e ObjectEvent) {
+		started <- struct{}{}
+		<-release
+	})
+
+	bus.Publish(ObjectEvent{Type: EventObjectUploaded})
+	<-started
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		bus.Publish(ObjectEvent{Type: EventObjectUploaded})
+	}
+
+	queues := bus.Queues()
+	if len(queues) != 1 {
+		t.Fatalf("Queues() = %+v, want one subscriber", queues)
+	}
+	q := queues[0]
+	if q.Name != "blocked" || q.Depth != 3 || q.Capacity != subscriberBufferSize {
+		t.Errorf("Queues()[0] = %+v, want depth 3 of %d", q, subscriberBufferSize)
+	}
+	if q.OldestAge < 20*time.Millisecond {
+		t.Errorf("OldestAge = %v, want the age of the first event published", q.OldestAge)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() while blocked = %v, want deadline exceeded", err)
+	}
+
+	close(release)
+	if err := bus.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if q := bus.Queues()[0]; q.Depth != 0 || q.OldestAge != 0 {
+		t.Errorf("after drain = %+v, want an empty queue", q)
+	}
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	received := make(chan ObjectEvent, 10)
+	unsubscribe := bus.Subscribe("test", func(e ObjectEvent) { received <- e })
+	unsubscribe()
+	unsubscribe()
+
+	bus.Publish(ObjectEvent{Type: EventObjectUploaded})
+	select {
+	case <-received:
+		t.Error("unsubscribed handler should not receive events")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestObjectEvent_S3Event(t *testing.T) {
+	now := time.Now()
+	e := ObjectEvent{
+		Type:      EventObjectMultipart,
+		Bucket:    "bucket",
+		Key:       "key",
+		Size:      42,
+		ETag:      "etag",
+		VersionID: "v1",
+		Time:      now,
+	}
+
+	s3 := e.S3Event()
+	if s3.EventName != string(EventObjectMultipart) || s3.S3.Bucket.Name != "bucket" || s3.S3.Object.Key != "key" {
+		t.Errorf("S3Event() = %+v", s3)
+	}
+	if s3.S3.Object.VersionID != "v1" || !s3.EventTime.Equal(now) {
+		t.Errorf("S3Event() lost version or time: %+v", s3)
+	}
+}
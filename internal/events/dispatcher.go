@@ -0,0 +1,329 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+// Delivery defaults. A failed delivery is retried after retryDelay, doubling
+// each time, until maxDeliveryAttempts have been made.
+const (
+	dispatchQueueSize   = 1024
+	dispatchWorkers     = 4
+	maxDeliveryAttempts = 5
+	retryDelay          = time.Second
+	deliveryTimeout     = 10 * time.Second
+)
+
+// NotificationSource provides the notification configuration of a bucket.
+// The engine implements it.
+type NotificationSource interface {
+	GetBucketNotification(ctx context.Context, bucket string) (*metadata.NotificationConfiguration, error)
+}
+
+// notificationTarget is one configured destination of bucket events
+type notificationTarget struct {
+	id         string
+	target     string
+	authHeader string
+	events     []string
+	filters    []metadata.FilterRule
+}
+
+// delivery is an event record queued for a webhook
+type delivery struct {
+	url        string
+	authHeader string
+	record     Event
+}
+
+// Dispatcher delivers object events to the targets in each bucket's
+// notification configuration. Events are matched when they are published
+// and POSTed in the S3 event format by a pool of workers, so slow or
+// failing targets do not hold up the engine.
+//
+// WebhookConfigurations are delivered to their URL, as are topic, queue and
+// Lambda targets given as http:// or https:// URLs. Other targets, such as
+// SQS or SNS ARNs, have no transport here and are skipped. Targets are
+// checked against the WebhookPolicy when they are dialed, and redirects are
+// not followed.
+type Dispatcher struct {
+	source NotificationSource
+	client *http.Client
+	logger *zap.SugaredLogger
+	policy *WebhookPolicy
+
+	maxAttempts int
+	retryDelay  time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan delivery
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher reading notification configurations
+// from source and starts its delivery workers
+func NewDispatcher(source NotificationSource, logger *zap.SugaredLogger) *Dispatcher {
+	d := &Dispatcher{
+		source:      source,
+		logger:      logger,
+		policy:      &WebhookPolicy{},
+		maxAttempts: maxDeliveryAttempts,
+		retryDelay:  retryDelay,
+		queue:       make(chan delivery, dispatchQueueSize),
+		done:        make(chan struct{}),
+	}
+	// Connections go straight to the addresses the policy checked, never
+	// through a proxy
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.webhookPolicy().dialContext(ctx, network, addr)
+	}
+	d.client = &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	d.wg.Add(dispatchWorkers)
+	for i := 0; i < dispatchWorkers; i++ {
+		go func() {
+			defer d.wg.Done()
+			for job := range d.queue {
+				d.deliver(job)
+			}
+		}()
+	}
+	return d
+}
+
+// SetWebhookPolicy sets the policy deciding which targets webhooks are
+// delivered to. By default any public address is.
+func (d *Dispatcher) SetWebhookPolicy(policy *WebhookPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.policy = policy
+}
+
+// webhookPolicy returns the policy deliveries are checked against
+func (d *Dispatcher) webhookPolicy() *WebhookPolicy {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.policy
+}
+
+// HandleObjectEvent queues the event for every matching target of its
+// bucket. It can be passed directly to Bus.Subscribe.
+func (d *Dispatcher) HandleObjectEvent(event ObjectEvent) {
+	config, err := d.source.GetBucketNotification(context.Background(), event.Bucket)
+	if err != nil {
+		d.logger.Debugw("failed to get bucket notification", "bucket", event.Bucket, "error", err)
+		return
+	}
+	if config == nil {
+		return
+	}
+
+	for _, target := range notificationTargets(config) {
+		if !target.matches(event) {
+			continue
+		}
+		if !isWebhook(target.target) {
+			d.logger.Debugw("skipping unsupported notification target",
+				"bucket", event.Bucket, "id", target.id, "target", target.target)
+			continue
+		}
+		d.enqueue(delivery{
+			url:        target.target,
+			authHeader: target.authHeader,
+			record:     notificationRecord(event, target.id),
+		})
+	}
+}
+
+// enqueue queues a delivery without blocking. Deliveries are dropped when
+// the queue is full or the dispatcher is closed.
+func (d *Dispatcher) enqueue(job delivery) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- job:
+	default:
+		d.logger.Warnw("notification queue full, dropping event",
+			"bucket", job.record.S3.Bucket.Name, "key", job.record.S3.Object.Key, "url", job.url)
+	}
+}
+
+// deliver POSTs a record to its webhook, retrying with exponential backoff.
+// Retries stop early once the dispatcher is closing, and targets the policy
+// refuses are not retried.
+func (d *Dispatcher) deliver(job delivery) {
+	body, err := json.Marshal(struct {
+		Records []Event `json:"Records"`
+	}{Records: []Event{job.record}})
+	if err != nil {
+		d.logger.Errorw("failed to encode notification", "error", err)
+		return
+	}
+
+	delay := d.retryDelay
+retry:
+	for attempt := 1; ; attempt++ {
+		err = d.post(job, body)
+		if err == nil {
+			return
+		}
+		if attempt >= d.maxAttempts || errors.Is(err, ErrWebhookTargetDenied) {
+			break
+		}
+		d.logger.Debugw("notification delivery failed, retrying",
+			"url", job.url, "attempt", attempt, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-d.done:
+			break retry
+		}
+		delay *= 2
+	}
+
+	d.logger.Warnw("failed to deliver notification",
+		"url", job.url,
+		"bucket", job.record.S3.Bucket.Name,
+		"key", job.record.S3.Object.Key,
+		"event", job.record.EventName,
+		"error", err)
+}
+
+// post sends one delivery attempt
+func (d *Dispatcher) post(job delivery, body []byte) error {
+	if err := d.webhookPolicy().CheckURL(job.url); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, job.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if job.authHeader != "" {
+		req.Header.Set("Authorization", job.authHeader)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for queued deliveries. Deliveries
+// that fail while closing are not retried.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.done)
+	close(d.queue)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// notificationTargets flattens the topic, queue, Lambda and webhook
+// configurations
+func notificationTargets(config *metadata.NotificationConfiguration) []notificationTarget {
+	var targets []notificationTarget
+	for _, c := range config.TopicConfigurations {
+		targets = append(targets, notificationTarget{id: c.ID, target: c.Topic, events: c.Events, filters: c.FilterRules})
+	}
+	for _, c := range config.QueueConfigurations {
+		targets = append(targets, notificationTarget{id: c.ID, target: c.Queue, events: c.Events, filters: c.FilterRules})
+	}
+	for _, c := range config.LambdaFunctionConfigurations {
+		targets = append(targets, notificationTarget{id: c.ID, target: c.Function, events: c.Events, filters: c.FilterRules})
+	}
+	for _, c := range config.WebhookConfigurations {
+		targets = append(targets, notificationTarget{
+			id:         c.ID,
+			target:     c.URL,
+			authHeader: c.AuthorizationHeader,
+			events:     c.Events,
+			filters:    c.FilterRules,
+		})
+	}
+	return targets
+}
+
+// matches reports whether the target is configured for the event type and
+// the object key passes every prefix and suffix filter rule
+func (t notificationTarget) matches(event ObjectEvent) bool {
+	matched := false
+	for _, pattern := range t.events {
+		if matchesEvent(pattern, string(event.Type)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	for _, rule := range t.filters {
+		switch strings.ToLower(rule.Name) {
+		case "prefix":
+			if !strings.HasPrefix(event.Key, rule.Value) {
+				return false
+			}
+		case "suffix":
+			if !strings.HasSuffix(event.Key, rule.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isWebhook reports whether a target is an HTTP endpoint
+func isWebhook(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// notificationRecord builds the S3 event record sent for a target. As in
+// S3, the event name has no "s3:" prefix and the key is URL encoded.
+func notificationRecord(event ObjectEvent, configID string) Event {
+	record := event.S3Event()
+	record.EventName = strings.TrimPrefix(record.EventName, "s3:")
+	record.S3.ConfigurationID = configID
+	record.S3.Object.Key = strings.ReplaceAll(url.QueryEscape(event.Key), "%2F", "/")
+	record.S3.Object.Sequencer = fmt.Sprintf("%016X", event.Time.UnixNano())
+	return record
+}
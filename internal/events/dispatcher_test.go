@@ -0,0 +1,278 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"go.uber.org/zap"
+)
+
+// staticSource serves one notification configuration for every bucket
+type staticSource struct {
+	config *metadata.NotificationConfiguration
+}
+
+func (s staticSource) GetBucketNotification(ctx context.Context, bucket string) (*metadata.NotificationConfiguration, error) {
+	return s.config, nil
+}
+
+// webhookRecorder is a webhook that fails its first failures requests
+type webhookRecorder struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	records  []Event
+	auth     []string
+}
+
+func (h *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.requests++
+	h.auth = append(h.auth, req.Header.Get("Authorization"))
+	if h.requests <= h.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var body struct {
+		Records []Event `json:"Records"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.records = append(h.records, body.Records...)
+}
+
+func (h *webhookRecorder) received() ([]Event, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Event(nil), h.records...), h.requests
+}
+
+// newTestDispatcher creates a dispatcher allowed to deliver to test servers
+// on the loopback address
+func newTestDispatcher(config *metadata.NotificationConfiguration) *Dispatcher {
+	d := NewDispatcher(staticSource{config}, zap.NewNop().Sugar())
+	d.retryDelay = time.Millisecond
+	policy, err := NewWebhookPolicy([]string{"127.0.0.1"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	d.SetWebhookPolicy(policy)
+	return d
+}
+
+func TestDispatcher_DeliversMatchingEvents(t *testing.T) {
+	hook := &webhookRecorder{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(&metadata.NotificationConfiguration{
+		QueueConfigurations: []metadata.QueueConfiguration{{
+			ID:     "images",
+			Queue:  server.URL,
+			Events: []string{"s3:ObjectCreated:*"},
+			FilterRules: []metadata.FilterRule{
+				{Name: "prefix", Value: "photos/"},
+				{Name: "Suffix", Value: ".jpg"},
+			},
+		}},
+		TopicConfigurations: []metadata.TopicConfiguration{{
+			ID:     "sns",
+			Topic:  "arn:aws:sns:us-east-1:123456789012:topic",
+			Events: []string{"s3:ObjectCreated:*"},
+		}},
+	})
+
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "photos/a b.jpg", Size: 3})
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "photos/a.png"})
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "docs/a.jpg"})
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectRemoved, Bucket: "bucket", Key: "photos/b.jpg"})
+	d.Close()
+
+	records, _ := hook.received()
+	if len(records) != 1 {
+		t.Fatalf("webhook received %d records, want 1", len(records))
+	}
+	record := records[0]
+	if record.EventName != "ObjectCreated:Put" {
+		t.Errorf("eventName = %q, want ObjectCreated:Put", record.EventName)
+	}
+	if record.S3.ConfigurationID != "images" {
+		t.Errorf("configurationId = %q, want images", record.S3.ConfigurationID)
+	}
+	if record.S3.Bucket.Name != "bucket" || record.S3.Object.Key != "photos/a+b.jpg" || record.S3.Object.Size != 3 {
+		t.Errorf("record s3 = %+v", record.S3)
+	}
+}
+
+func TestDispatcher_WebhookConfigurations(t *testing.T) {
+	hook := &webhookRecorder{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(&metadata.NotificationConfiguration{
+		WebhookConfigurations: []metadata.WebhookConfiguration{{
+			ID:                  "hook",
+			URL:                 server.URL,
+			AuthorizationHeader: "Bearer secret",
+			Events:              []string{"s3:ObjectRemoved:*"},
+			FilterRules:         []metadata.FilterRule{{Name: "suffix", Value: ".csv"}},
+		}},
+	})
+
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "a.csv"})
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectRemoved, Bucket: "bucket", Key: "a.txt"})
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectRemoved, Bucket: "bucket", Key: "a.csv"})
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectDeleteMarkerCreated, Bucket: "bucket", Key: "b.csv"})
+	d.Close()
+
+	records, _ := hook.received()
+	if len(records) != 2 {
+		t.Fatalf("webhook received %d records, want 2", len(records))
+	}
+	names := map[string]bool{}
+	for _, record := range records {
+		names[record.EventName] = true
+		if record.S3.ConfigurationID != "hook" {
+			t.Errorf("configurationId = %q, want hook", record.S3.ConfigurationID)
+		}
+	}
+	if !names["ObjectRemoved:Delete"] || !names["ObjectRemoved:DeleteMarkerCreated"] {
+		t.Errorf("event names = %v", names)
+	}
+	for _, auth := range hook.auth {
+		if auth != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the configured header", auth)
+		}
+	}
+}
+
+func TestDispatcher_RefusesNonPublicTargets(t *testing.T) {
+	hook := &webhookRecorder{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	// By default the loopback test server is not delivered to
+	d := NewDispatcher(staticSource{&metadata.NotificationConfiguration{
+		WebhookConfigurations: []metadata.WebhookConfiguration{{URL: server.URL, Events: []string{"s3:ObjectCreated:*"}}},
+	}}, zap.NewNop().Sugar())
+	d.retryDelay = time.Hour
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "a"})
+	d.Close()
+
+	if _, requests := hook.received(); requests != 0 {
+		t.Errorf("loopback webhook received %d requests, want 0", requests)
+	}
+}
+
+func TestDispatcher_DoesNotFollowRedirects(t *testing.T) {
+	hook := &webhookRecorder{}
+	internal := httptest.NewServer(hook)
+	defer internal.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	d := newTestDispatcher(&metadata.NotificationConfiguration{
+		WebhookConfigurations: []metadata.WebhookConfiguration{{URL: redirect.URL, Events: []string{"s3:ObjectCreated:*"}}},
+	})
+	d.maxAttempts = 1
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "a"})
+	d.Close()
+
+	if _, requests := hook.received(); requests != 0 {
+		t.Errorf("redirect target received %d requests, want 0", requests)
+	}
+}
+
+func TestDispatcher_RetriesFailedDeliveries(t *testing.T) {
+	hook := &webhookRecorder{failures: 2}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(&metadata.NotificationConfiguration{
+		LambdaFunctionConfigurations: []metadata.LambdaFunctionConfiguration{{
+			ID:       "fn",
+			Function: server.URL,
+			Events:   []string{"s3:ObjectRemoved:*"},
+		}},
+	})
+	defer d.Close()
+
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectRemoved, Bucket: "bucket", Key: "key"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records, requests := hook.received()
+		if len(records) == 1 {
+			if requests != 3 {
+				t.Errorf("webhook received %d requests, want 3", requests)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("event not delivered after %d requests", requests)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_GivesUpAfterMaxAttempts(t *testing.T) {
+	hook := &webhookRecorder{failures: 100}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(&metadata.NotificationConfiguration{
+		QueueConfigurations: []metadata.QueueConfiguration{{
+			Queue:  server.URL,
+			Events: []string{"s3:ObjectCreated:Put"},
+		}},
+	})
+	d.maxAttempts = 3
+
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "key"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, requests := hook.received(); requests >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was not retried")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	d.Close()
+
+	if _, requests := hook.received(); requests != 3 {
+		t.Errorf("webhook received %d requests, want 3", requests)
+	}
+}
+
+func TestDispatcher_ClosedDropsEvents(t *testing.T) {
+	hook := &webhookRecorder{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(&metadata.NotificationConfiguration{
+		QueueConfigurations: []metadata.QueueConfiguration{{
+			Queue:  server.URL,
+			Events: []string{"s3:ObjectCreated:*"},
+		}},
+	})
+	d.Close()
+	d.Close()
+
+	d.HandleObjectEvent(ObjectEvent{Type: EventObjectUploaded, Bucket: "bucket", Key: "key"})
+	if _, requests := hook.received(); requests != 0 {
+		t.Errorf("closed dispatcher delivered %d requests", requests)
+	}
+}
@@ -19,6 +19,7 @@ const (
 	EventObjectDeleted    EventType = "s3:ObjectRemoved:*"
 	EventObjectRemoved    EventType = "s3:ObjectRemoved:Delete"
 	EventObjectRemovedTag  EventType = "s3:ObjectRemoved:DeleteTagging"
+	EventObjectDeleteMarkerCreated EventType = "s3:ObjectRemoved:DeleteMarkerCreated"
 
 	// Object ACL events
 	EventObjectAclPut    EventType = "s3:ObjectAcl:Put"
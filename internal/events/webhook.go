@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrWebhookTargetDenied is returned for webhook targets the WebhookPolicy
+// does not deliver to
+var ErrWebhookTargetDenied = errors.New("webhook target not allowed")
+
+// nonPublicNets are the ranges, besides loopback, private, link-local and
+// multicast addresses, that webhooks are not delivered to by default
+var nonPublicNets = mustParseNets(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, which can reach any IPv4 address
+)
+
+// WebhookPolicy decides which targets webhook notifications are delivered
+// to. Denied hosts and networks are never delivered to. When allowed hosts
+// or networks are configured, only those are. Loopback, private,
+// link-local and other non-public addresses, cloud metadata endpoints among
+// them, are refused unless an allowed network covers them. Addresses are
+// checked after DNS resolution, for every connection.
+//
+// The zero value delivers to any public address.
+type WebhookPolicy struct {
+	allowHosts map[string]bool
+	allowNets  []*net.IPNet
+	denyHosts  map[string]bool
+	denyNets   []*net.IPNet
+}
+
+// NewWebhookPolicy creates a policy from allow and deny lists of host
+// names, IPs and CIDR ranges
+func NewWebhookPolicy(allow, deny []string) (*WebhookPolicy, error) {
+	p := &WebhookPolicy{allowHosts: map[string]bool{}, denyHosts: map[string]bool{}}
+	if err := parseWebhookTargets(allow, p.allowHosts, &p.allowNets); err != nil {
+		return nil, err
+	}
+	if err := parseWebhookTargets(deny, p.denyHosts, &p.denyNets); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseWebhookTargets sorts entries into host names and networks
+func parseWebhookTargets(entries []string, hosts map[string]bool, nets *[]*net.IPNet) error {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return fmt.Errorf("invalid webhook network %q: %w", entry, err)
+			}
+			*nets = append(*nets, ipNet)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			*nets = append(*nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		hosts[normalizeHost(entry)] = true
+	}
+	return nil
+}
+
+// CheckURL checks a webhook URL before it is stored or posted to: it must
+// be an absolute http or https URL outside the management API, and its
+// host must not be refused by the policy. Host names are checked again
+// against the addresses they resolve to when they are dialed.
+func (p *WebhookPolicy) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("malformed webhook URL %q", rawURL)
+	}
+	if clean := path.Clean("/" + u.Path); clean == "/_mgmt" || strings.HasPrefix(clean, "/_mgmt/") {
+		return fmt.Errorf("%w: %s is in the management API", ErrWebhookTargetDenied, rawURL)
+	}
+
+	host := normalizeHost(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip)
+	}
+	if p.denyHosts[host] {
+		return fmt.Errorf("%w: %s is denied", ErrWebhookTargetDenied, host)
+	}
+	if p.restricted() && !p.allowHosts[host] && len(p.allowNets) == 0 {
+		return fmt.Errorf("%w: %s is not in the allow list", ErrWebhookTargetDenied, host)
+	}
+	return nil
+}
+
+// checkIP checks an address host resolved to
+func (p *WebhookPolicy) checkIP(host string, ip net.IP) error {
+	if p.denyHosts[host] || containsIP(p.denyNets, ip) {
+		return fmt.Errorf("%w: %s (%s) is denied", ErrWebhookTargetDenied, host, ip)
+	}
+	allowedNet := containsIP(p.allowNets, ip)
+	if !allowedNet && !publicIP(ip) {
+		return fmt.Errorf("%w: %s resolves to non-public address %s", ErrWebhookTargetDenied, host, ip)
+	}
+	if p.restricted() && !allowedNet && !p.allowHosts[host] {
+		return fmt.Errorf("%w: %s (%s) is not in the allow list", ErrWebhookTargetDenied, host, ip)
+	}
+	return nil
+}
+
+// restricted reports whether only allowed targets are delivered to
+func (p *WebhookPolicy) restricted() bool {
+	return len(p.allowHosts) > 0 || len(p.allowNets) > 0
+}
+
+// dialContext dials the first address of addr's host the policy permits.
+// The checked address is dialed, rather than the host name, so the name
+// cannot resolve differently in between.
+func (p *WebhookPolicy) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	err = fmt.Errorf("no addresses found for %s", host)
+	for _, a := range addrs {
+		if err = p.checkIP(normalizeHost(host), a.IP); err != nil {
+			continue
+		}
+		conn, dialErr := dialer.DialContext(ctx, network, net.JoinHostPort(a.IP.String(), port))
+		if dialErr == nil {
+			return conn, nil
+		}
+		err = dialErr
+	}
+	return nil, err
+}
+
+// publicIP reports whether ip is a public unicast address
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || containsIP(nonPublicNets, ip))
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases a host name and drops its trailing dot
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func mustParseNets(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
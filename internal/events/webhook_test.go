@@ -0,0 +1,71 @@
+package events
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestWebhookPolicy_CheckURL(t *testing.T) {
+	open := &WebhookPolicy{}
+	restricted, err := NewWebhookPolicy([]string{"hooks.example.com", "10.1.0.0/16"}, []string{"bad.example.com", "203.0.113.9"})
+	if err != nil {
+		t.Fatalf("NewWebhookPolicy() error = %v", err)
+	}
+	// Host names can only be refused up front when no networks are allowed;
+	// otherwise they are checked once resolved
+	hostsOnly, _ := NewWebhookPolicy([]string{"hooks.example.com"}, nil)
+
+	tests := []struct {
+		name   string
+		policy *WebhookPolicy
+		url    string
+		denied bool
+	}{
+		{"public host", open, "https://hooks.example.com/s3", false},
+		{"public IP", open, "http://203.0.113.10:8080/", false},
+		{"loopback", open, "http://127.0.0.1:9000/", true},
+		{"IPv6 loopback", open, "http://[::1]/", true},
+		{"IPv4-mapped loopback", open, "http://[::ffff:127.0.0.1]/", true},
+		{"private", open, "http://192.168.1.10/", true},
+		{"metadata endpoint", open, "http://169.254.169.254/latest/meta-data/", true},
+		{"unspecified", open, "http://0.0.0.0/", true},
+		{"management API", open, "https://hooks.example.com/_mgmt/cluster", true},
+		{"management API, unclean path", open, "https://hooks.example.com/x/../_mgmt/", true},
+		{"allowed host", restricted, "https://hooks.example.com/s3", false},
+		{"allowed private network", restricted, "http://10.1.2.3/", false},
+		{"host not allowed", hostsOnly, "https://other.example.com/", true},
+		{"private network not allowed", restricted, "http://10.2.0.1/", true},
+		{"denied host", restricted, "https://BAD.example.com./", true},
+		{"denied IP", restricted, "http://203.0.113.9/", true},
+	}
+	for _, tt := range tests {
+		err := tt.policy.CheckURL(tt.url)
+		if denied := errors.Is(err, ErrWebhookTargetDenied); denied != tt.denied {
+			t.Errorf("%s: CheckURL(%s) = %v, want denied %v", tt.name, tt.url, err, tt.denied)
+		}
+	}
+
+	if err := open.CheckURL("ftp://hooks.example.com"); err == nil || errors.Is(err, ErrWebhookTargetDenied) {
+		t.Errorf("CheckURL(ftp) = %v, want a malformed URL error", err)
+	}
+	if _, err := NewWebhookPolicy([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("NewWebhookPolicy() should reject an invalid network")
+	}
+}
+
+func TestWebhookPolicy_CheckIP(t *testing.T) {
+	// Host names allowed by name may still not resolve to internal addresses
+	policy, _ := NewWebhookPolicy([]string{"hooks.example.com"}, nil)
+	if err := policy.checkIP("hooks.example.com", net.ParseIP("203.0.113.10")); err != nil {
+		t.Errorf("checkIP(public) = %v", err)
+	}
+	for _, ip := range []string{"127.0.0.1", "10.0.0.1", "169.254.169.254", "fd00:ec2::254", "100.64.0.1"} {
+		if err := policy.checkIP("hooks.example.com", net.ParseIP(ip)); !errors.Is(err, ErrWebhookTargetDenied) {
+			t.Errorf("checkIP(%s) = %v, want ErrWebhookTargetDenied", ip, err)
+		}
+	}
+	if err := policy.checkIP("other.example.com", net.ParseIP("203.0.113.10")); !errors.Is(err, ErrWebhookTargetDenied) {
+		t.Errorf("checkIP() of a host not in the allow list = %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package iam
 
 import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -982,3 +986,287 @@ func TestAttachPolicyGroupNotFound(t *testing.T) {
 		t.Error("AttachPolicy should fail for non-existent group")
 	}
 }
+
+func TestEvaluatePolicyDenyAndWildcards(t *testing.T) {
+	mgr := NewManager(zap.NewNop())
+
+	user, _ := mgr.CreateUser("tenant1", "reader", "reader@example.com")
+	group, _ := mgr.CreateGroup("tenant1", "readers")
+	mgr.AddUserToGroup(user.ID, group.ID)
+
+	allow, _ := mgr.CreatePolicy("tenant1", "ReadPhotos", PolicyDoc{
+		Statement: []Statement{{
+			Effect:    "Allow",
+			Actions:   []string{"s3:Get*", "s3:ListBucket"},
+			Resources: []string{"arn:aws:s3:::photos", "arn:aws:s3:::photos/*"},
+		}},
+	})
+	deny, _ := mgr.CreatePolicy("tenant1", "NoPrivate", PolicyDoc{
+		Statement: []Statement{{
+			Effect:    "Deny",
+			Actions:   []string{"*"},
+			Resources: []string{"arn:aws:s3:::photos/private-??/*"},
+		}},
+	})
+	mgr.AttachPolicy(allow.ID, group.ID, "group")
+	mgr.AttachPolicy(deny.ID, user.ID, "user")
+
+	tests := []struct {
+		action   string
+		resource string
+		want     bool
+	}{
+		{"s3:GetObject", "arn:aws:s3:::photos/cat.jpg", true},
+		{"S3:getobjecttagging", "arn:aws:s3:::photos/cat.jpg", true},
+		{"s3:ListBucket", "arn:aws:s3:::photos", true},
+		{"s3:PutObject", "arn:aws:s3:::photos/cat.jpg", false},
+		{"s3:GetObject", "arn:aws:s3:::videos/cat.mp4", false},
+		{"s3:GetObject", "arn:aws:s3:::photos/private-01/cat.jpg", false},
+		{"s3:GetObject", "arn:aws:s3:::photos/private-001/cat.jpg", true},
+	}
+	for _, tt := range tests {
+		allowed, err := mgr.EvaluatePolicy("tenant1", user.ID, tt.action, tt.resource)
+		if err != nil {
+			t.Fatalf("EvaluatePolicy(%s, %s) error: %v", tt.action, tt.resource, err)
+		}
+		if allowed != tt.want {
+			t.Errorf("EvaluatePolicy(%s, %s) = %v, want %v", tt.action, tt.resource, allowed, tt.want)
+		}
+	}
+}
+
+func TestIsAllowed(t *testing.T) {
+	mgr := NewManager(zap.NewNop())
+
+	user, _ := mgr.CreateUser("tenant1", "writer", "writer@example.com")
+	key, _ := mgr.CreateAccessKey(user.ID)
+	policy, _ := mgr.CreatePolicy("tenant1", "Write", PolicyDoc{
+		Statement: []Statement{{
+			Effect:     "Allow",
+			NotActions: []string{"s3:DeleteObject"},
+			Resources:  []string{"*"},
+		}},
+	})
+	mgr.AttachPolicy(policy.ID, user.ID, "user")
+
+	if allowed, managed := mgr.IsAllowed(key.ID, "s3:PutObject", "arn:aws:s3:::b/k"); !allowed || !managed {
+		t.Errorf("IsAllowed(PutObject) = %v, %v, want true, true", allowed, managed)
+	}
+	if allowed, managed := mgr.IsAllowed(key.ID, "s3:DeleteObject", "arn:aws:s3:::b/k"); allowed || !managed {
+		t.Errorf("IsAllowed(DeleteObject) = %v, %v, want false, true", allowed, managed)
+	}
+	if _, managed := mgr.IsAllowed("AKIAUNKNOWN", "s3:PutObject", "arn:aws:s3:::b/k"); managed {
+		t.Error("IsAllowed() should not manage an unknown access key")
+	}
+	if found, ok := mgr.GetUserByAccessKey(key.ID); !ok || found.ID != user.ID {
+		t.Errorf("GetUserByAccessKey() = %v, %v", found, ok)
+	}
+}
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"s3:*", "s3:GetObject", true},
+		{"s3:*Object", "s3:GetObject", true},
+		{"s3:*Object", "s3:GetObjectAcl", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+	for _, tt := range tests {
+		if got := matchWildcard(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("matchWildcard(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+// memStore is an in-memory Store
+type memStore map[string]map[string][]byte
+
+func (s memStore) PutIAMEntity(ctx context.Context, kind, id string, data []byte) error {
+	if s[kind] == nil {
+		s[kind] = make(map[string][]byte)
+	}
+	s[kind][id] = data
+	return nil
+}
+
+func (s memStore) DeleteIAMEntity(ctx context.Context, kind, id string) error {
+	delete(s[kind], id)
+	return nil
+}
+
+func (s memStore) ListIAMEntities(ctx context.Context, kind string) ([][]byte, error) {
+	var docs [][]byte
+	for _, doc := range s[kind] {
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func TestManagerStore(t *testing.T) {
+	store := memStore{}
+	ctx := context.Background()
+	mgr := NewManager(zap.NewNop())
+	if err := mgr.SetStore(ctx, store); err != nil {
+		t.Fatalf("SetStore() error: %v", err)
+	}
+
+	user, _ := mgr.CreateUser("tenant1", "alice", "alice@example.com")
+	key, _ := mgr.CreateAccessKey(user.ID)
+	group, _ := mgr.CreateGroup("tenant1", "readers")
+	mgr.AddUserToGroup(user.ID, group.ID)
+	policy, _ := mgr.CreatePolicy("tenant1", "Read", PolicyDoc{
+		Statement: []Statement{{Effect: "Allow", Actions: []string{"s3:GetObject"}, Resources: []string{"*"}}},
+	})
+	mgr.AttachPolicy(policy.ID, group.ID, "group")
+
+	// A second manager loads the same state
+	loaded := NewManager(zap.NewNop())
+	if err := loaded.SetStore(ctx, store); err != nil {
+		t.Fatalf("SetStore() error: %v", err)
+	}
+	if allowed, managed := loaded.IsAllowed(key.ID, "s3:GetObject", "arn:aws:s3:::b/k"); !allowed || !managed {
+		t.Errorf("IsAllowed() after reload = %v, %v, want true, true", allowed, managed)
+	}
+	if groups := loaded.ListGroups("tenant1"); len(groups) != 1 || len(groups[0].Members) != 1 {
+		t.Errorf("ListGroups() after reload = %+v", groups)
+	}
+
+	if err := loaded.DeletePolicy(policy.ID); !errors.Is(err, ErrPolicyAttached) {
+		t.Errorf("DeletePolicy() of an attached policy error = %v, want %v", err, ErrPolicyAttached)
+	}
+	if err := loaded.DeleteGroup(group.ID); err != nil {
+		t.Fatalf("DeleteGroup() error: %v", err)
+	}
+	if err := loaded.DeletePolicy(policy.ID); err != nil {
+		t.Fatalf("DeletePolicy() error: %v", err)
+	}
+	if err := loaded.DeleteAccessKey(key.ID); err != nil {
+		t.Fatalf("DeleteAccessKey() error: %v", err)
+	}
+	if err := loaded.DeleteAccessKey(key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteAccessKey() error = %v, want %v", err, ErrNotFound)
+	}
+
+	reloaded := NewManager(zap.NewNop())
+	reloaded.SetStore(ctx, store)
+	u, ok := reloaded.GetUser(user.ID)
+	if !ok || len(u.AccessKeys) != 0 || len(u.Groups) != 0 {
+		t.Errorf("user after deletes = %+v, %v, want no keys or groups", u, ok)
+	}
+	if len(reloaded.ListGroups("tenant1")) != 0 || len(reloaded.ListPolicies("tenant1")) != 0 {
+		t.Error("deleted group and policy were loaded again")
+	}
+}
+
+// credentialMap is a CredentialRegistry recording the registered keys
+type credentialMap map[string]string
+
+func (c credentialMap) AddCredential(accessKey, secretKey string) { c[accessKey] = secretKey }
+func (c credentialMap) RemoveCredential(accessKey string)         { delete(c, accessKey) }
+func (c credentialMap) AddSessionCredential(accessKey, secretKey, sessionToken string, expires time.Time) {
+	c[accessKey] = secretKey
+}
+
+func TestAccessKeyCredentials(t *testing.T) {
+	mgr := NewManager(zap.NewNop())
+	user, _ := mgr.CreateUser("tenant1", "alice", "alice@example.com")
+	existing, _ := mgr.CreateAccessKey(user.ID)
+
+	registry := credentialMap{}
+	mgr.SetCredentialRegistry(registry)
+	if registry[existing.ID] != existing.Secret {
+		t.Error("SetCredentialRegistry() did not register the existing key")
+	}
+
+	key, err := mgr.CreateAccessKey(user.ID)
+	if err != nil {
+		t.Fatalf("CreateAccessKey() error: %v", err)
+	}
+	if !regexp.MustCompile(`^AKIA[A-Z2-7]{16}$`).MatchString(key.ID) {
+		t.Errorf("access key ID %q is not AKIA-style", key.ID)
+	}
+	if len(key.Secret) != 40 {
+		t.Errorf("secret length = %d, want 40", len(key.Secret))
+	}
+	if registry[key.ID] != key.Secret {
+		t.Error("CreateAccessKey() did not register the new key")
+	}
+
+	u, _ := mgr.GetUser(user.ID)
+	for _, k := range u.WithoutSecrets().AccessKeys {
+		if k.Secret != "" {
+			t.Errorf("WithoutSecrets() kept the secret of %s", k.ID)
+		}
+	}
+	if u.AccessKeys[0].Secret == "" {
+		t.Error("WithoutSecrets() blanked the user's own secrets")
+	}
+
+	if err := mgr.DeleteAccessKey(key.ID); err != nil {
+		t.Fatalf("DeleteAccessKey() error: %v", err)
+	}
+	if _, ok := registry[key.ID]; ok {
+		t.Error("DeleteAccessKey() did not revoke the key")
+	}
+	if err := mgr.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser() error: %v", err)
+	}
+	if len(registry) != 0 {
+		t.Errorf("DeleteUser() left keys registered: %v", registry)
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	mgr := NewManager(zap.NewNop())
+	registry := credentialMap{}
+	mgr.SetCredentialRegistry(registry)
+
+	policy, _ := mgr.CreatePolicy("tenant1", "Read", PolicyDoc{
+		Statement: []Statement{{Effect: "Allow", Actions: []string{"s3:GetObject"}, Resources: []string{"*"}}},
+	})
+	if _, err := mgr.CreateRole("tenant1", "broken", []string{"arn:missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CreateRole() with an unknown policy error = %v, want %v", err, ErrNotFound)
+	}
+	role, err := mgr.CreateRole("tenant1", "reader", []string{policy.Arn})
+	if err != nil {
+		t.Fatalf("CreateRole() error: %v", err)
+	}
+
+	session, err := mgr.AssumeRole(role.Arn, "app", 0)
+	if err != nil {
+		t.Fatalf("AssumeRole() error: %v", err)
+	}
+	if !strings.HasPrefix(session.AccessKeyID, "ASIA") || session.SessionToken == "" {
+		t.Errorf("AssumeRole() = %+v, want an ASIA key with a session token", session)
+	}
+	if d := time.Until(session.Expiration); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("session expires in %v, want the default hour", d)
+	}
+	if registry[session.AccessKeyID] != session.SecretAccessKey {
+		t.Error("AssumeRole() did not register the session credentials")
+	}
+
+	if allowed, managed := mgr.IsAllowed(session.AccessKeyID, "s3:GetObject", "arn:aws:s3:::b/k"); !allowed || !managed {
+		t.Errorf("IsAllowed(GetObject) = %v, %v, want true, true", allowed, managed)
+	}
+	if allowed, _ := mgr.IsAllowed(session.AccessKeyID, "s3:PutObject", "arn:aws:s3:::b/k"); allowed {
+		t.Error("IsAllowed(PutObject) should follow the role's policies")
+	}
+
+	if _, err := mgr.AssumeRole(role.Arn, "app", time.Minute); !errors.Is(err, ErrInvalidDuration) {
+		t.Errorf("AssumeRole() with a minute error = %v, want %v", err, ErrInvalidDuration)
+	}
+	if _, err := mgr.AssumeRole("arn:missing", "app", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AssumeRole() of an unknown role error = %v, want %v", err, ErrNotFound)
+	}
+}
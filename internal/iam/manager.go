@@ -1,8 +1,13 @@
 package iam
 
 import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,6 +15,14 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrNotFound is returned for a user, group, policy or access key that does
+// not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrPolicyAttached is returned when deleting a policy still attached to a
+// user or group
+var ErrPolicyAttached = errors.New("policy is attached")
+
 // User represents an IAM user
 type User struct {
 	ID            string            `json:"id"`
@@ -28,7 +41,7 @@ type User struct {
 // AccessKey represents an access key
 type AccessKey struct {
 	ID        string    `json:"id"`
-	Secret    string    `json:"secret"`
+	Secret    string    `json:"secret,omitempty"`
 	Status    string    `json:"status"` // active, inactive
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
@@ -102,6 +115,24 @@ type Manager struct {
 	groups     map[string]*Group
 	policies   map[string]*Policy
 	roles      map[string]*Role
+
+	// store persists users, groups and policies, when set
+	store Store
+	// credentials is told about active access keys, when set
+	credentials CredentialRegistry
+	// sessions holds the temporary credentials AssumeRole issued, by
+	// access key ID
+	sessions map[string]*Session
+}
+
+// CredentialRegistry is kept in step with the active access keys, so that
+// requests signed with them authenticate. auth.Auth implements it.
+type CredentialRegistry interface {
+	AddCredential(accessKey, secretKey string)
+	// AddSessionCredential adds temporary credentials, which are only
+	// valid with the session token and until they expire
+	AddSessionCredential(accessKey, secretKey, sessionToken string, expires time.Time)
+	RemoveCredential(accessKey string)
 }
 
 // NewManager creates a new IAM manager
@@ -112,6 +143,7 @@ func NewManager(logger *zap.Logger) *Manager {
 		groups:   make(map[string]*Group),
 		policies: make(map[string]*Policy),
 		roles:    make(map[string]*Role),
+		sessions: make(map[string]*Session),
 	}
 }
 
@@ -133,6 +165,9 @@ func (m *Manager) CreateUser(tenantID, username, email string) (*User, error) {
 		LastActivity: time.Now(),
 	}
 
+	if err := m.save(kindUser, user.ID, user); err != nil {
+		return nil, err
+	}
 	m.users[user.ID] = user
 	m.logger.Info("User created",
 		zap.String("id", user.ID),
@@ -181,11 +216,33 @@ func (m *Manager) DeleteUser(userID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, ok := m.users[userID]; !ok {
-		return fmt.Errorf("user not found: %s", userID)
+	user, ok := m.users[userID]
+	if !ok {
+		return fmt.Errorf("user %w: %s", ErrNotFound, userID)
 	}
 
+	if err := m.remove(kindUser, userID); err != nil {
+		return err
+	}
 	delete(m.users, userID)
+	if m.credentials != nil {
+		for _, key := range user.AccessKeys {
+			m.credentials.RemoveCredential(key.ID)
+		}
+	}
+
+	// Drop the user from its groups
+	for _, groupID := range user.Groups {
+		group, ok := m.groups[groupID]
+		if !ok {
+			continue
+		}
+		group.Members = without(group.Members, userID)
+		if err := m.save(kindGroup, group.ID, group); err != nil {
+			return err
+		}
+	}
+
 	m.logger.Info("User deleted", zap.String("id", userID))
 	return nil
 }
@@ -197,17 +254,28 @@ func (m *Manager) CreateAccessKey(userID string) (*AccessKey, error) {
 
 	user, ok := m.users[userID]
 	if !ok {
-		return nil, fmt.Errorf("user not found: %s", userID)
+		return nil, fmt.Errorf("user %w: %s", ErrNotFound, userID)
 	}
 
+	id, secret, err := generateAccessKey()
+	if err != nil {
+		return nil, err
+	}
 	key := AccessKey{
-		ID:        "AKIA" + uuid.New().String()[:16],
-		Secret:    uuid.New().String() + uuid.New().String(),
+		ID:        id,
+		Secret:    secret,
 		Status:    "active",
 		CreatedAt: time.Now(),
 	}
 
 	user.AccessKeys = append(user.AccessKeys, key)
+	if err := m.save(kindUser, user.ID, user); err != nil {
+		user.AccessKeys = user.AccessKeys[:len(user.AccessKeys)-1]
+		return nil, err
+	}
+	if m.credentials != nil {
+		m.credentials.AddCredential(key.ID, key.Secret)
+	}
 
 	m.logger.Info("Access key created",
 		zap.String("user_id", userID),
@@ -216,6 +284,89 @@ func (m *Manager) CreateAccessKey(userID string) (*AccessKey, error) {
 	return &key, nil
 }
 
+// DeleteAccessKey deletes an access key from the user owning it
+func (m *Manager) DeleteAccessKey(keyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		for i, key := range user.AccessKeys {
+			if key.ID != keyID {
+				continue
+			}
+			keys := make([]AccessKey, 0, len(user.AccessKeys)-1)
+			keys = append(keys, user.AccessKeys[:i]...)
+			keys = append(keys, user.AccessKeys[i+1:]...)
+			previous := user.AccessKeys
+			user.AccessKeys = keys
+			if err := m.save(kindUser, user.ID, user); err != nil {
+				user.AccessKeys = previous
+				return err
+			}
+			if m.credentials != nil {
+				m.credentials.RemoveCredential(keyID)
+			}
+
+			m.logger.Info("Access key deleted",
+				zap.String("user_id", user.ID),
+				zap.String("key_id", keyID))
+			return nil
+		}
+	}
+	return fmt.Errorf("access key %w: %s", ErrNotFound, keyID)
+}
+
+// SetCredentialRegistry registers every active access key with registry and
+// keeps it updated as keys are created and deleted
+func (m *Manager) SetCredentialRegistry(registry CredentialRegistry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.credentials = registry
+	m.registerAccessKeys()
+}
+
+// registerAccessKeys registers the active access keys of every user. m.mu
+// must be held.
+func (m *Manager) registerAccessKeys() {
+	if m.credentials == nil {
+		return
+	}
+	for _, user := range m.users {
+		for _, key := range user.AccessKeys {
+			if key.Status == "active" {
+				m.credentials.AddCredential(key.ID, key.Secret)
+			}
+		}
+	}
+}
+
+// generateAccessKey returns a random AWS-style access key ID and 40
+// character secret
+func generateAccessKey() (id, secret string, err error) {
+	idBytes := make([]byte, 10)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate access key ID: %w", err)
+	}
+	secretBytes := make([]byte, 30)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate secret key: %w", err)
+	}
+	return "AKIA" + base32.StdEncoding.EncodeToString(idBytes), base64.StdEncoding.EncodeToString(secretBytes), nil
+}
+
+// WithoutSecrets returns a copy of the user with its access key secrets
+// blanked, for listing. Secrets are only returned when a key is created.
+func (u *User) WithoutSecrets() *User {
+	redacted := *u
+	redacted.AccessKeys = make([]AccessKey, len(u.AccessKeys))
+	for i, key := range u.AccessKeys {
+		key.Secret = ""
+		redacted.AccessKeys[i] = key
+	}
+	return &redacted
+}
+
 // CreateGroup creates a new group
 func (m *Manager) CreateGroup(tenantID, name string) (*Group, error) {
 	m.mu.Lock()
@@ -229,6 +380,9 @@ func (m *Manager) CreateGroup(tenantID, name string) (*Group, error) {
 		CreatedAt: time.Now(),
 	}
 
+	if err := m.save(kindGroup, group.ID, group); err != nil {
+		return nil, err
+	}
 	m.groups[group.ID] = group
 	m.logger.Info("Group created",
 		zap.String("id", group.ID),
@@ -237,6 +391,50 @@ func (m *Manager) CreateGroup(tenantID, name string) (*Group, error) {
 	return group, nil
 }
 
+// ListGroups lists all groups for a tenant
+func (m *Manager) ListGroups(tenantID string) []*Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*Group, 0)
+	for _, g := range m.groups {
+		if g.TenantID == tenantID {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// DeleteGroup deletes a group, removing its members from it
+func (m *Manager) DeleteGroup(groupID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[groupID]
+	if !ok {
+		return fmt.Errorf("group %w: %s", ErrNotFound, groupID)
+	}
+
+	if err := m.remove(kindGroup, groupID); err != nil {
+		return err
+	}
+	delete(m.groups, groupID)
+
+	for _, userID := range group.Members {
+		user, ok := m.users[userID]
+		if !ok {
+			continue
+		}
+		user.Groups = without(user.Groups, groupID)
+		if err := m.save(kindUser, user.ID, user); err != nil {
+			return err
+		}
+	}
+
+	m.logger.Info("Group deleted", zap.String("id", groupID))
+	return nil
+}
+
 // AddUserToGroup adds a user to a group
 func (m *Manager) AddUserToGroup(userID, groupID string) error {
 	m.mu.Lock()
@@ -244,12 +442,12 @@ func (m *Manager) AddUserToGroup(userID, groupID string) error {
 
 	group, ok := m.groups[groupID]
 	if !ok {
-		return fmt.Errorf("group not found: %s", groupID)
+		return fmt.Errorf("group %w: %s", ErrNotFound, groupID)
 	}
 
 	user, ok := m.users[userID]
 	if !ok {
-		return fmt.Errorf("user not found: %s", userID)
+		return fmt.Errorf("user %w: %s", ErrNotFound, userID)
 	}
 
 	// Check if user is already in group
@@ -261,6 +459,12 @@ func (m *Manager) AddUserToGroup(userID, groupID string) error {
 
 	group.Members = append(group.Members, userID)
 	user.Groups = append(user.Groups, groupID)
+	if err := m.save(kindUser, user.ID, user); err != nil {
+		return err
+	}
+	if err := m.save(kindGroup, group.ID, group); err != nil {
+		return err
+	}
 
 	m.logger.Info("User added to group",
 		zap.String("user_id", userID),
@@ -276,7 +480,7 @@ func (m *Manager) RemoveUserFromGroup(userID, groupID string) error {
 
 	group, ok := m.groups[groupID]
 	if !ok {
-		return fmt.Errorf("group not found: %s", groupID)
+		return fmt.Errorf("group %w: %s", ErrNotFound, groupID)
 	}
 
 	// Remove from group
@@ -298,6 +502,12 @@ func (m *Manager) RemoveUserFromGroup(userID, groupID string) error {
 			}
 		}
 		user.Groups = newGroups
+		if err := m.save(kindUser, user.ID, user); err != nil {
+			return err
+		}
+	}
+	if err := m.save(kindGroup, group.ID, group); err != nil {
+		return err
 	}
 
 	m.logger.Info("User removed from group",
@@ -324,6 +534,9 @@ func (m *Manager) CreatePolicy(tenantID, name string, doc PolicyDoc) (*Policy, e
 		UpdatedAt:  time.Now(),
 	}
 
+	if err := m.save(kindPolicy, policy.ID, policy); err != nil {
+		return nil, err
+	}
 	m.policies[policy.ID] = policy
 	m.logger.Info("Policy created",
 		zap.String("id", policy.ID),
@@ -332,6 +545,50 @@ func (m *Manager) CreatePolicy(tenantID, name string, doc PolicyDoc) (*Policy, e
 	return policy, nil
 }
 
+// ListPolicies lists all policies for a tenant
+func (m *Manager) ListPolicies(tenantID string) []*Policy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*Policy, 0)
+	for _, p := range m.policies {
+		if p.TenantID == tenantID {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// DeletePolicy deletes a policy. Like IAM, it refuses to delete a policy
+// still attached to a user or group.
+func (m *Manager) DeletePolicy(policyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	policy, ok := m.policies[policyID]
+	if !ok {
+		return fmt.Errorf("policy %w: %s", ErrNotFound, policyID)
+	}
+	for _, u := range m.users {
+		if contains(u.PolicyArns, policy.Arn) {
+			return fmt.Errorf("%w to user %s: %s", ErrPolicyAttached, u.ID, policyID)
+		}
+	}
+	for _, g := range m.groups {
+		if contains(g.PolicyArns, policy.Arn) {
+			return fmt.Errorf("%w to group %s: %s", ErrPolicyAttached, g.ID, policyID)
+		}
+	}
+
+	if err := m.remove(kindPolicy, policyID); err != nil {
+		return err
+	}
+	delete(m.policies, policyID)
+
+	m.logger.Info("Policy deleted", zap.String("id", policyID))
+	return nil
+}
+
 // GetPolicy returns a policy by ID
 func (m *Manager) GetPolicy(policyID string) (*Policy, bool) {
 	m.mu.RLock()
@@ -360,7 +617,7 @@ func (m *Manager) AttachPolicy(policyID, entityID, entityType string) error {
 
 	policy, ok := m.policies[policyID]
 	if !ok {
-		return fmt.Errorf("policy not found: %s", policyID)
+		return fmt.Errorf("policy %w: %s", ErrNotFound, policyID)
 	}
 
 	policy.IsAttached = true
@@ -369,18 +626,27 @@ func (m *Manager) AttachPolicy(policyID, entityID, entityType string) error {
 	case "user":
 		user, ok := m.users[entityID]
 		if !ok {
-			return fmt.Errorf("user not found: %s", entityID)
+			return fmt.Errorf("user %w: %s", ErrNotFound, entityID)
 		}
 		user.PolicyArns = append(user.PolicyArns, policy.Arn)
+		if err := m.save(kindUser, user.ID, user); err != nil {
+			return err
+		}
 	case "group":
 		group, ok := m.groups[entityID]
 		if !ok {
-			return fmt.Errorf("group not found: %s", entityID)
+			return fmt.Errorf("group %w: %s", ErrNotFound, entityID)
 		}
 		group.PolicyArns = append(group.PolicyArns, policy.Arn)
+		if err := m.save(kindGroup, group.ID, group); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("invalid entity type: %s", entityType)
 	}
+	if err := m.save(kindPolicy, policy.ID, policy); err != nil {
+		return err
+	}
 
 	m.logger.Info("Policy attached",
 		zap.String("policy_id", policyID),
@@ -399,7 +665,7 @@ func (m *Manager) DetachPolicy(policyID, entityID, entityType string) error {
 	case "user":
 		user, ok := m.users[entityID]
 		if !ok {
-			return fmt.Errorf("user not found: %s", entityID)
+			return fmt.Errorf("user %w: %s", ErrNotFound, entityID)
 		}
 		newArns := make([]string, 0)
 		for _, arn := range user.PolicyArns {
@@ -408,10 +674,13 @@ func (m *Manager) DetachPolicy(policyID, entityID, entityType string) error {
 			}
 		}
 		user.PolicyArns = newArns
+		if err := m.save(kindUser, user.ID, user); err != nil {
+			return err
+		}
 	case "group":
 		group, ok := m.groups[entityID]
 		if !ok {
-			return fmt.Errorf("group not found: %s", entityID)
+			return fmt.Errorf("group %w: %s", ErrNotFound, entityID)
 		}
 		newArns := make([]string, 0)
 		for _, arn := range group.PolicyArns {
@@ -420,6 +689,9 @@ func (m *Manager) DetachPolicy(policyID, entityID, entityType string) error {
 			}
 		}
 		group.PolicyArns = newArns
+		if err := m.save(kindGroup, group.ID, group); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("invalid entity type: %s", entityType)
 	}
@@ -432,16 +704,60 @@ func (m *Manager) DetachPolicy(policyID, entityID, entityType string) error {
 	return nil
 }
 
-// EvaluatePolicy evaluates if an action is allowed
+// GetUserByAccessKey returns the user owning an active access key
+func (m *Manager) GetUserByAccessKey(accessKey string) (*User, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.userByAccessKey(accessKey)
+}
+
+// userByAccessKey finds the user owning an active access key. m.mu must be
+// held.
+func (m *Manager) userByAccessKey(accessKey string) (*User, bool) {
+	for _, u := range m.users {
+		for _, key := range u.AccessKeys {
+			if key.ID == accessKey && key.Status == "active" {
+				return u, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// IsAllowed reports whether the user owning accessKey may perform action on
+// resource. managed is false when no user owns the key, so the caller can
+// fall back to its own rules for credentials IAM does not know about.
+func (m *Manager) IsAllowed(accessKey, action, resource string) (allowed, managed bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if allowed, managed := m.sessionAllows(accessKey, action, resource); managed {
+		return allowed, true
+	}
+	user, ok := m.userByAccessKey(accessKey)
+	if !ok {
+		return false, false
+	}
+	return user.Status != "inactive" && m.evaluateUser(user, action, resource), true
+}
+
+// EvaluatePolicy evaluates if an action is allowed. An explicit Deny in any
+// of the user's policies, group policies or inline policy overrides every
+// Allow, and anything not allowed is denied.
 func (m *Manager) EvaluatePolicy(tenantID, userID, action, resource string) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	user, ok := m.users[userID]
 	if !ok {
-		return false, fmt.Errorf("user not found: %s", userID)
+		return false, fmt.Errorf("user %w: %s", ErrNotFound, userID)
 	}
+	return m.evaluateUser(user, action, resource), nil
+}
 
+// evaluateUser evaluates every policy that applies to user. m.mu must be
+// held.
+func (m *Manager) evaluateUser(user *User, action, resource string) bool {
 	// Get all policies for the user
 	var policyArns []string
 	policyArns = append(policyArns, user.PolicyArns...)
@@ -453,68 +769,129 @@ func (m *Manager) EvaluatePolicy(tenantID, userID, action, resource string) (boo
 		}
 	}
 
-	// Evaluate each policy
+	statements := m.policyStatements(policyArns)
+
+	// Check inline policy
+	if user.InlinePolicy != nil {
+		statements = append(statements, user.InlinePolicy.Document.Statement...)
+	}
+	return evaluateStatements(statements, action, resource)
+}
+
+// policyStatements returns the statements of the policies with the given
+// ARNs. m.mu must be held.
+func (m *Manager) policyStatements(policyArns []string) []Statement {
+	var statements []Statement
 	for _, arn := range policyArns {
 		for _, policy := range m.policies {
 			if policy.Arn == arn {
-				allowed := m.evaluateStatement(policy.Document.Statement, action, resource)
-				if allowed {
-					return true, nil
-				}
+				statements = append(statements, policy.Document.Statement...)
 			}
 		}
 	}
-
-	// Check inline policy
-	if user.InlinePolicy != nil {
-		allowed := m.evaluateStatement(user.InlinePolicy.Document.Statement, action, resource)
-		if allowed {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return statements
 }
 
-// evaluateStatement evaluates a policy statement
-func (m *Manager) evaluateStatement(statements []Statement, action, resource string) bool {
+// evaluateStatements allows action on resource if a statement allows it and
+// none denies it
+func evaluateStatements(statements []Statement, action, resource string) bool {
+	allowed := false
 	for _, stmt := range statements {
-		// Check effect
-		if stmt.Effect != "Allow" {
+		if !statementMatches(stmt, action, resource) {
 			continue
 		}
-
-		// Check action
-		actionMatched := false
-		for _, a := range stmt.Actions {
-			if a == action || a == "*" {
-				actionMatched = true
-				break
-			}
+		switch stmt.Effect {
+		case "Deny":
+			return false
+		case "Allow":
+			allowed = true
 		}
-		if !actionMatched {
-			continue
+	}
+	return allowed
+}
+
+// statementMatches reports whether a statement covers action on resource.
+// Actions match case-insensitively, as in AWS, and resources exactly.
+func statementMatches(stmt Statement, action, resource string) bool {
+	if len(stmt.NotActions) > 0 {
+		if matchesAny(stmt.NotActions, action, true) {
+			return false
 		}
+	} else if !matchesAny(stmt.Actions, action, true) {
+		return false
+	}
 
-		// Check resource
-		resourceMatched := false
-		for _, r := range stmt.Resources {
-			if r == resource || r == "*" {
-				resourceMatched = true
-				break
-			}
+	if len(stmt.NotResources) > 0 {
+		return !matchesAny(stmt.NotResources, resource, false)
+	}
+	return matchesAny(stmt.Resources, resource, false)
+}
+
+// matchesAny reports whether value matches any of patterns
+func matchesAny(patterns []string, value string, foldCase bool) bool {
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern = strings.ToLower(pattern)
 		}
-		if !resourceMatched {
-			continue
+		if matchWildcard(pattern, value) {
+			return true
 		}
+	}
+	return false
+}
 
-		// All conditions met
-		return true
+// matchWildcard matches value against an IAM pattern, in which "*" stands
+// for any run of characters and "?" for any single character
+func matchWildcard(pattern, value string) bool {
+	// Backtrack to the last "*" on a mismatch, which keeps matching linear
+	// in practice
+	p, v := 0, 0
+	star, starV := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, starV = p, v
+			p++
+		case star >= 0:
+			starV++
+			p, v = star+1, starV
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
 	}
+	return p == len(pattern)
+}
 
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
 	return false
 }
 
+// without returns values with every occurrence of value removed
+func without(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
 // PolicyFromJSON creates a policy from JSON
 func PolicyFromJSON(data []byte) (*Policy, error) {
 	var policy Policy
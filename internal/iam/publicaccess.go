@@ -0,0 +1,204 @@
+package iam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// publicCannedACLs are the canned ACLs that grant access beyond the owner
+var publicCannedACLs = map[string]bool{
+	"public-read":        true,
+	"public-read-write":  true,
+	"authenticated-read": true,
+}
+
+// narrowingConditionKeys are condition keys that limit a statement to known
+// callers, so a wildcard principal they apply to does not make it public.
+// Keys are compared case-insensitively.
+var narrowingConditionKeys = map[string]bool{
+	"aws:sourceip":         true,
+	"aws:sourcevpc":        true,
+	"aws:sourcevpce":       true,
+	"aws:sourcearn":        true,
+	"aws:sourceaccount":    true,
+	"aws:sourceowner":      true,
+	"aws:principalaccount": true,
+	"aws:principalarn":     true,
+	"aws:principalorgid":   true,
+	"aws:userid":           true,
+}
+
+// policyDocument is the part of a bucket policy that public access checks
+// and anonymous access evaluation read. Statement may be a single statement
+// or a list of them.
+type policyDocument struct {
+	Statement json.RawMessage `json:"Statement"`
+}
+
+// policyStatement is a bucket policy statement. Principal may be "*" or an
+// object, and actions and resources a string or a list, so they are kept raw.
+type policyStatement struct {
+	Effect       string                                `json:"Effect"`
+	Principal    json.RawMessage                       `json:"Principal"`
+	NotPrincipal json.RawMessage                       `json:"NotPrincipal"`
+	Action       json.RawMessage                       `json:"Action"`
+	NotAction    json.RawMessage                       `json:"NotAction"`
+	Resource     json.RawMessage                       `json:"Resource"`
+	NotResource  json.RawMessage                       `json:"NotResource"`
+	Condition    map[string]map[string]json.RawMessage `json:"Condition"`
+}
+
+// parsePolicyStatements parses the statements of a bucket policy
+func parsePolicyStatements(policy []byte) ([]policyStatement, error) {
+	var doc policyDocument
+	if err := json.Unmarshal(policy, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	var stmts []policyStatement
+	raw := bytes.TrimSpace(doc.Statement)
+	if len(raw) > 0 && raw[0] == '{' {
+		var stmt policyStatement
+		if err := json.Unmarshal(raw, &stmt); err != nil {
+			return nil, fmt.Errorf("failed to parse policy statement: %w", err)
+		}
+		stmts = append(stmts, stmt)
+	} else if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &stmts); err != nil {
+			return nil, fmt.Errorf("failed to parse policy statements: %w", err)
+		}
+	}
+	return stmts, nil
+}
+
+// PolicyIsPublic reports whether a bucket policy grants access to everyone:
+// it has an Allow statement for the "*" principal, or for all principals
+// but some through NotPrincipal, that no condition limits to known callers.
+func PolicyIsPublic(policy []byte) (bool, error) {
+	stmts, err := parsePolicyStatements(policy)
+	if err != nil {
+		return false, err
+	}
+
+	for _, stmt := range stmts {
+		if !strings.EqualFold(stmt.Effect, "Allow") {
+			continue
+		}
+		if !principalIsWildcard(stmt.Principal) && len(stmt.NotPrincipal) == 0 {
+			continue
+		}
+		if conditionNarrows(stmt.Condition) {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// PolicyAllowsAnonymous reports whether a bucket policy lets anonymous
+// callers perform action on resource, an S3 ARN: an Allow statement for the
+// "*" principal covers it and no Deny statement for that principal does.
+// Conditions are not evaluated, so conditional statements only ever deny:
+// a conditional Allow is ignored and a conditional Deny applies.
+func PolicyAllowsAnonymous(policy []byte, action, resource string) (bool, error) {
+	stmts, err := parsePolicyStatements(policy)
+	if err != nil {
+		return false, err
+	}
+
+	allowed := false
+	for _, stmt := range stmts {
+		if !principalIsWildcard(stmt.Principal) {
+			continue
+		}
+		matches := statementMatches(Statement{
+			Actions:      stringOrList(stmt.Action),
+			NotActions:   stringOrList(stmt.NotAction),
+			Resources:    stringOrList(stmt.Resource),
+			NotResources: stringOrList(stmt.NotResource),
+		}, action, resource)
+		if !matches {
+			continue
+		}
+		switch {
+		case strings.EqualFold(stmt.Effect, "Deny"):
+			return false, nil
+		case strings.EqualFold(stmt.Effect, "Allow") && len(stmt.Condition) == 0:
+			allowed = true
+		}
+	}
+	return allowed, nil
+}
+
+// principalIsWildcard reports whether a statement principal, either "*" or
+// {"AWS": ...}, names every caller
+func principalIsWildcard(raw json.RawMessage) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s == "*"
+	}
+	var principal map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &principal); err != nil {
+		return false
+	}
+	for _, p := range stringOrList(principal["AWS"]) {
+		if p == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionNarrows reports whether a statement's conditions limit it to
+// known callers
+func conditionNarrows(condition map[string]map[string]json.RawMessage) bool {
+	for _, keys := range condition {
+		for key := range keys {
+			if narrowingConditionKeys[strings.ToLower(key)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringOrList decodes a policy value that may be a string or a list of them
+func stringOrList(raw json.RawMessage) []string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []string{s}
+	}
+	var list []string
+	json.Unmarshal(raw, &list)
+	return list
+}
+
+// GranteeIsPublic reports whether a grantee is one of the groups that make a
+// grant public: everyone, or every authenticated user
+func GranteeIsPublic(grantee Grantee) bool {
+	return grantee.URI == AllUsersGroup || grantee.URI == AuthenticatedGroup
+}
+
+// ACLIsPublic reports whether any grant of an ACL is public
+func ACLIsPublic(acl *ACL) bool {
+	if acl == nil {
+		return false
+	}
+	for _, grant := range acl.Grants {
+		if GranteeIsPublic(grant.Grantee) {
+			return true
+		}
+	}
+	return false
+}
+
+// CannedACLIsPublic reports whether a canned ACL, as sent in x-amz-acl,
+// grants access beyond the owner
+func CannedACLIsPublic(canned string) bool {
+	return publicCannedACLs[canned]
+}
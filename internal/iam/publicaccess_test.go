@@ -0,0 +1,162 @@
+package iam
+
+import "testing"
+
+func TestPolicyIsPublic(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   bool
+	}{
+		{
+			name:   "wildcard principal string",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`,
+			want:   true,
+		},
+		{
+			name:   "wildcard AWS principal",
+			policy: `{"Statement":{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::1:root","*"]},"Action":"s3:GetObject"}}`,
+			want:   true,
+		},
+		{
+			name:   "not principal",
+			policy: `{"Statement":[{"Effect":"Allow","NotPrincipal":{"AWS":"arn:aws:iam::1:root"},"Action":"s3:*"}]}`,
+			want:   true,
+		},
+		{
+			name:   "condition that does not narrow",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Condition":{"Bool":{"aws:SecureTransport":"true"}}}]}`,
+			want:   true,
+		},
+		{
+			name:   "source IP condition",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`,
+			want:   false,
+		},
+		{
+			name:   "named principal",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::1:user/alice"},"Action":"s3:GetObject"}]}`,
+			want:   false,
+		},
+		{
+			name:   "wildcard deny",
+			policy: `{"Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:DeleteObject"}]}`,
+			want:   false,
+		},
+		{
+			name:   "no statements",
+			policy: `{"Version":"2012-10-17"}`,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PolicyIsPublic([]byte(tt.policy))
+			if err != nil {
+				t.Fatalf("PolicyIsPublic() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PolicyIsPublic() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PolicyIsPublic([]byte(`{not json`)); err == nil {
+		t.Error("PolicyIsPublic() with invalid JSON should fail")
+	}
+}
+
+func TestPolicyAllowsAnonymous(t *testing.T) {
+	const publicRead = `{"Statement":[{"Effect":"Allow","Principal":"*","Action":["s3:GetObject"],"Resource":"arn:aws:s3:::bucket/*"}]}`
+	tests := []struct {
+		name     string
+		policy   string
+		action   string
+		resource string
+		want     bool
+	}{
+		{"public read object", publicRead, "s3:GetObject", "arn:aws:s3:::bucket/a/b.txt", true},
+		{"action case", publicRead, "S3:GETOBJECT", "arn:aws:s3:::bucket/a.txt", true},
+		{"other action", publicRead, "s3:PutObject", "arn:aws:s3:::bucket/a.txt", false},
+		{"other bucket", publicRead, "s3:GetObject", "arn:aws:s3:::other/a.txt", false},
+		{"bucket itself", publicRead, "s3:ListBucket", "arn:aws:s3:::bucket", false},
+		{
+			name:     "deny wins",
+			policy:   `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:*","Resource":"*"},{"Effect":"Deny","Principal":{"AWS":"*"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/secret/*"}]}`,
+			action:   "s3:GetObject",
+			resource: "arn:aws:s3:::bucket/secret/key",
+			want:     false,
+		},
+		{
+			name:     "not action",
+			policy:   `{"Statement":{"Effect":"Allow","Principal":"*","NotAction":"s3:Delete*","Resource":"*"}}`,
+			action:   "s3:DeleteObject",
+			resource: "arn:aws:s3:::bucket/a",
+			want:     false,
+		},
+		{
+			name:     "named principal",
+			policy:   `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::1:user/alice"},"Action":"s3:GetObject","Resource":"*"}]}`,
+			action:   "s3:GetObject",
+			resource: "arn:aws:s3:::bucket/a",
+			want:     false,
+		},
+		{
+			name:     "conditional allow",
+			policy:   `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`,
+			action:   "s3:GetObject",
+			resource: "arn:aws:s3:::bucket/a",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PolicyAllowsAnonymous([]byte(tt.policy), tt.action, tt.resource)
+			if err != nil {
+				t.Fatalf("PolicyAllowsAnonymous() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PolicyAllowsAnonymous(%s, %s) = %v, want %v", tt.action, tt.resource, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PolicyAllowsAnonymous([]byte("not json"), "s3:GetObject", "*"); err == nil {
+		t.Error("PolicyAllowsAnonymous() with an invalid policy should fail")
+	}
+}
+
+func TestACLIsPublic(t *testing.T) {
+	acl := NewACL("owner1", "Owner Name")
+	acl.AddGrant(Grantee{Type: "CanonicalUser", ID: "owner1"}, PermissionFullControl)
+	if ACLIsPublic(acl) {
+		t.Error("ACLIsPublic() = true for an owner-only ACL")
+	}
+
+	for _, uri := range []string{AllUsersGroup, AuthenticatedGroup} {
+		acl := NewACL("owner1", "Owner Name")
+		acl.AddGrant(Grantee{Type: "Group", URI: uri}, PermissionRead)
+		if !ACLIsPublic(acl) {
+			t.Errorf("ACLIsPublic() = false for a grant to %s", uri)
+		}
+	}
+
+	if ACLIsPublic(nil) {
+		t.Error("ACLIsPublic(nil) = true")
+	}
+}
+
+func TestCannedACLIsPublic(t *testing.T) {
+	for _, canned := range []string{"public-read", "public-read-write", "authenticated-read"} {
+		if !CannedACLIsPublic(canned) {
+			t.Errorf("CannedACLIsPublic(%q) = false", canned)
+		}
+	}
+	for _, canned := range []string{"", "private", "bucket-owner-full-control"} {
+		if CannedACLIsPublic(canned) {
+			t.Errorf("CannedACLIsPublic(%q) = true", canned)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package iam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Kinds of entity the manager persists
+const (
+	kindUser   = "user"
+	kindGroup  = "group"
+	kindPolicy = "policy"
+	kindRole   = "role"
+)
+
+// Store persists IAM entities as JSON documents keyed by kind and ID. The
+// metadata store implements it under its iam: keyspace.
+type Store interface {
+	PutIAMEntity(ctx context.Context, kind, id string, data []byte) error
+	DeleteIAMEntity(ctx context.Context, kind, id string) error
+	ListIAMEntities(ctx context.Context, kind string) ([][]byte, error)
+}
+
+// SetStore loads the users, groups, policies and roles saved in store, replacing
+// the ones held in memory, and saves every later change to it. The loaded
+// access keys are registered with the credential registry, if one is set.
+func (m *Manager) SetStore(ctx context.Context, store Store) error {
+	users := make(map[string]*User)
+	if err := loadEntities(ctx, store, kindUser, func(u *User) { users[u.ID] = u }); err != nil {
+		return err
+	}
+	groups := make(map[string]*Group)
+	if err := loadEntities(ctx, store, kindGroup, func(g *Group) { groups[g.ID] = g }); err != nil {
+		return err
+	}
+	policies := make(map[string]*Policy)
+	if err := loadEntities(ctx, store, kindPolicy, func(p *Policy) { policies[p.ID] = p }); err != nil {
+		return err
+	}
+	roles := make(map[string]*Role)
+	if err := loadEntities(ctx, store, kindRole, func(r *Role) { roles[r.ID] = r }); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.users = users
+	m.groups = groups
+	m.policies = policies
+	m.roles = roles
+	m.registerAccessKeys()
+	return nil
+}
+
+// loadEntities decodes every saved entity of a kind and passes it to add
+func loadEntities[T any](ctx context.Context, store Store, kind string, add func(*T)) error {
+	docs, err := store.ListIAMEntities(ctx, kind)
+	if err != nil {
+		return fmt.Errorf("failed to load IAM %ss: %w", kind, err)
+	}
+	for _, doc := range docs {
+		entity := new(T)
+		if err := json.Unmarshal(doc, entity); err != nil {
+			return fmt.Errorf("failed to decode IAM %s: %w", kind, err)
+		}
+		add(entity)
+	}
+	return nil
+}
+
+// save persists an entity, if the manager has a store. m.mu must be held.
+func (m *Manager) save(kind, id string, entity interface{}) error {
+	if m.store == nil {
+		return nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("failed to encode IAM %s: %w", kind, err)
+	}
+	if err := m.store.PutIAMEntity(context.Background(), kind, id, data); err != nil {
+		return fmt.Errorf("failed to save IAM %s %s: %w", kind, id, err)
+	}
+	return nil
+}
+
+// remove deletes a persisted entity, if the manager has a store. m.mu must
+// be held.
+func (m *Manager) remove(kind, id string) error {
+	if m.store == nil {
+		return nil
+	}
+	if err := m.store.DeleteIAMEntity(context.Background(), kind, id); err != nil {
+		return fmt.Errorf("failed to delete IAM %s %s: %w", kind, id, err)
+	}
+	return nil
+}
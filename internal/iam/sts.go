@@ -0,0 +1,183 @@
+package iam
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Session durations AssumeRole accepts, as in AWS STS
+const (
+	DefaultSessionDuration = time.Hour
+	MinSessionDuration     = 15 * time.Minute
+	MaxSessionDuration     = 12 * time.Hour
+)
+
+// ErrInvalidDuration is returned by AssumeRole for a session duration
+// outside MinSessionDuration to MaxSessionDuration
+var ErrInvalidDuration = errors.New("invalid session duration")
+
+// Session is a set of temporary credentials issued by AssumeRole. Requests
+// signed with them carry the session token and get the role's policies.
+type Session struct {
+	AccessKeyID     string    `json:"access_key_id"`
+	SecretAccessKey string    `json:"secret_access_key"`
+	SessionToken    string    `json:"session_token"`
+	Expiration      time.Time `json:"expiration"`
+	RoleArn         string    `json:"role_arn"`
+	RoleID          string    `json:"role_id"`
+	SessionName     string    `json:"session_name"`
+}
+
+// AssumedRoleArn returns the ARN of the role session, as reported to the
+// caller
+func (s *Session) AssumedRoleArn() string {
+	return s.RoleArn + "/" + s.SessionName
+}
+
+// CreateRole creates a role granted the policies with the given ARNs
+func (m *Manager) CreateRole(tenantID, name string, policyArns []string) (*Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, arn := range policyArns {
+		if !m.hasPolicyArn(arn) {
+			return nil, fmt.Errorf("policy %w: %s", ErrNotFound, arn)
+		}
+	}
+
+	role := &Role{
+		ID:         uuid.New().String(),
+		TenantID:   tenantID,
+		Name:       name,
+		Arn:        fmt.Sprintf("arn:openendpoint:%s:%s:role/%s", tenantID, "default", name),
+		Path:       "/",
+		PolicyArns: append([]string{}, policyArns...),
+		CreatedAt:  time.Now(),
+	}
+
+	if err := m.save(kindRole, role.ID, role); err != nil {
+		return nil, err
+	}
+	m.roles[role.ID] = role
+	m.logger.Info("Role created",
+		zap.String("id", role.ID),
+		zap.String("name", name))
+
+	return role, nil
+}
+
+// hasPolicyArn reports whether a policy has the ARN. m.mu must be held.
+func (m *Manager) hasPolicyArn(arn string) bool {
+	for _, p := range m.policies {
+		if p.Arn == arn {
+			return true
+		}
+	}
+	return false
+}
+
+// ListRoles lists all roles for a tenant
+func (m *Manager) ListRoles(tenantID string) []*Role {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*Role, 0)
+	for _, r := range m.roles {
+		if r.TenantID == tenantID {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// AssumeRole issues temporary credentials for a role, valid for duration,
+// or DefaultSessionDuration when it is zero. Sessions live in memory only,
+// so a restart ends them.
+func (m *Manager) AssumeRole(roleArn, sessionName string, duration time.Duration) (*Session, error) {
+	if duration == 0 {
+		duration = DefaultSessionDuration
+	}
+	if duration < MinSessionDuration || duration > MaxSessionDuration {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDuration, duration)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var role *Role
+	for _, r := range m.roles {
+		if r.Arn == roleArn {
+			role = r
+			break
+		}
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %w: %s", ErrNotFound, roleArn)
+	}
+
+	id, secret, err := generateAccessKey()
+	if err != nil {
+		return nil, err
+	}
+	token := make([]byte, 96)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	now := time.Now()
+	m.expireSessions(now)
+	session := &Session{
+		// Temporary access keys start with ASIA in AWS
+		AccessKeyID:     "ASIA" + id[len("AKIA"):],
+		SecretAccessKey: secret,
+		SessionToken:    base64.StdEncoding.EncodeToString(token),
+		Expiration:      now.Add(duration).UTC().Truncate(time.Second),
+		RoleArn:         role.Arn,
+		RoleID:          role.ID,
+		SessionName:     sessionName,
+	}
+	m.sessions[session.AccessKeyID] = session
+	if m.credentials != nil {
+		m.credentials.AddSessionCredential(session.AccessKeyID, session.SecretAccessKey, session.SessionToken, session.Expiration)
+	}
+
+	m.logger.Info("Role assumed",
+		zap.String("role", role.Arn),
+		zap.String("session", sessionName),
+		zap.String("key_id", session.AccessKeyID))
+
+	return session, nil
+}
+
+// expireSessions drops the sessions that have expired by now. m.mu must be
+// held.
+func (m *Manager) expireSessions(now time.Time) {
+	for id, session := range m.sessions {
+		if now.After(session.Expiration) {
+			delete(m.sessions, id)
+			if m.credentials != nil {
+				m.credentials.RemoveCredential(id)
+			}
+		}
+	}
+}
+
+// sessionAllows evaluates the role policies of a session. managed is false
+// when accessKey is not a session key. m.mu must be held.
+func (m *Manager) sessionAllows(accessKey, action, resource string) (allowed, managed bool) {
+	session, ok := m.sessions[accessKey]
+	if !ok {
+		return false, false
+	}
+	role, ok := m.roles[session.RoleID]
+	if !ok || time.Now().After(session.Expiration) {
+		return false, true
+	}
+	return evaluateStatements(m.policyStatements(role.PolicyArns), action, resource), true
+}
@@ -2,9 +2,12 @@ package lifecycle
 
 import (
 	"context"
+	"errors"
+	"math"
 	"sync"
 	"time"
 
+	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/engine"
 	"github.com/openendpoint/openendpoint/internal/metadata"
 	"go.uber.org/zap"
@@ -12,12 +15,15 @@ import (
 
 var logger, _ = zap.NewProduction()
 
-// Processor handles lifecycle rule processing
+// Processor handles lifecycle rule processing. Object ages are measured
+// against the engine's clock.
 type Processor struct {
-	engine   *engine.ObjectService
-	interval time.Duration
-	stopCh   chan struct{}
-	wg       sync.WaitGroup
+	engine    *engine.ObjectService
+	interval  time.Duration
+	intervals *config.WorkerIntervals
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
 }
 
 // NewProcessor creates a new lifecycle processor
@@ -29,6 +35,14 @@ func NewProcessor(eng *engine.ObjectService, interval time.Duration) *Processor
 	}
 }
 
+// SetIntervals makes the processor take its run interval from shared worker
+// intervals instead of the fixed one it was created with. It must be called
+// before Start.
+func (p *Processor) SetIntervals(intervals *config.WorkerIntervals) {
+	p.intervals = intervals
+	p.interval = intervals.Get(config.WorkerLifecycle)
+}
+
 // Start starts the lifecycle processor
 func (p *Processor) Start() {
 	p.wg.Add(1)
@@ -36,30 +50,25 @@ func (p *Processor) Start() {
 	logger.Info("lifecycle processor started", zap.Duration("interval", p.interval))
 }
 
-// Stop stops the lifecycle processor
+// Stop stops the lifecycle processor and waits for a running pass to finish.
+// Calling it again does nothing.
 func (p *Processor) Stop() {
-	close(p.stopCh)
-	p.wg.Wait()
-	logger.Info("lifecycle processor stopped")
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		p.wg.Wait()
+		logger.Info("lifecycle processor stopped")
+	})
 }
 
 // Run runs the lifecycle processor loop
 func (p *Processor) run() {
 	defer p.wg.Done()
 
-	ticker := time.NewTicker(p.interval)
-	defer ticker.Stop()
-
 	// Run immediately on start
 	p.processBuckets()
 
-	for {
-		select {
-		case <-ticker.C:
-			p.processBuckets()
-		case <-p.stopCh:
-			return
-		}
+	for p.intervals.Sleep(config.WorkerLifecycle, p.interval, p.stopCh) {
+		p.processBuckets()
 	}
 }
 
@@ -69,6 +78,14 @@ func (p *Processor) processBuckets() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
+	// Restored copies of archived objects go back to the archive once
+	// their restore window has passed
+	if n, err := p.engine.ExpireRestores(ctx); err != nil {
+		logger.Error("failed to re-archive restored objects", zap.Error(err))
+	} else if n > 0 {
+		logger.Info("re-archived restored objects", zap.Int("count", n))
+	}
+
 	buckets, err := p.engine.ListBuckets(ctx)
 	if err != nil {
 		logger.Error("failed to list buckets", zap.Error(err))
@@ -111,133 +128,342 @@ func (p *Processor) processBucket(ctx context.Context, bucket string) {
 func (p *Processor) processRule(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
 
 	// Process expiration
-	if rule.Expiration != nil && rule.Expiration.Days > 0 {
+	if rule.Expiration != nil && (rule.Expiration.Days > 0 || rule.Expiration.Date > 0) {
 		p.processExpiration(ctx, bucket, rule)
 	}
 
+	// Remove delete markers left with no versions behind them
+	if rule.Expiration != nil && rule.Expiration.ExpiredObjectDeleteMarker {
+		p.processExpiredDeleteMarkers(ctx, bucket, rule)
+	}
+
 	// Process transitions
 	if len(rule.Transitions) > 0 {
 		p.processTransitions(ctx, bucket, rule)
 	}
 
+	// Process noncurrent version transitions
+	if len(rule.NoncurrentVersionTransitions) > 0 {
+		p.processNoncurrentVersionTransitions(ctx, bucket, rule)
+	}
+
 	// Process noncurrent version expiration
 	if rule.NoncurrentVersionExpiration != nil {
 		p.processNoncurrentVersionExpiration(ctx, bucket, rule)
 	}
 }
 
-// processExpiration processes object expiration
-func (p *Processor) processExpiration(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
-	cutoffTime := time.Now().AddDate(0, 0, -rule.Expiration.Days).Unix()
-
-	// List objects
-	opts := engine.ListObjectsOptions{
-		Prefix:   rule.Prefix,
-		MaxKeys:  1000,
+// rulePrefix returns the key prefix a rule applies to, from its Filter or
+// else the rule-level Prefix
+func rulePrefix(rule *metadata.LifecycleRule) string {
+	if rule.Filter != nil {
+		return rule.Filter.Prefix
 	}
+	return rule.Prefix
+}
 
-	result, err := p.engine.ListObjects(ctx, bucket, opts)
+// matchesTags reports whether an object carries every tag the rule's filter
+// requires. Tags are only read when the filter has some.
+func (p *Processor) matchesTags(ctx context.Context, bucket, key string, rule *metadata.LifecycleRule) bool {
+	if rule.Filter == nil || len(rule.Filter.Tags) == 0 {
+		return true
+	}
+	tags, err := p.engine.GetObjectTags(ctx, bucket, key, "")
 	if err != nil {
-		logger.Error("failed to list objects for expiration", zap.Error(err))
-		return
+		return false
 	}
-
-	for _, obj := range result.Objects {
-		if obj.LastModified < cutoffTime {
-			err := p.engine.DeleteObject(ctx, bucket, obj.Key, engine.DeleteObjectOptions{})
-			if err != nil {
-				logger.Error("failed to delete expired object",
-					zap.String("key", obj.Key),
-					zap.Error(err))
-			} else {
-				logger.Info("deleted expired object",
-					zap.String("bucket", bucket),
-					zap.String("key", obj.Key))
-			}
+	for k, v := range rule.Filter.Tags {
+		if value, ok := tags[k]; !ok || value != v {
+			return false
 		}
 	}
+	return true
 }
 
-// processTransitions processes storage class transitions
-func (p *Processor) processTransitions(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
-	// Get transition actions from rule
-	transitions := rule.Transitions
-	if len(transitions) == 0 {
-		return
+// processExpiration deletes the objects the rule selects that are older than
+// its expiration days, or all of them once its expiration date has passed, a
+// page of objects at a time. In a versioned bucket the delete leaves a
+// delete marker, as an expiration does in S3.
+func (p *Processor) processExpiration(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
+	now := p.engine.Clock().Now()
+	var cutoffTime int64
+	if rule.Expiration.Date > 0 {
+		if now.Unix() < rule.Expiration.Date {
+			return
+		}
+		cutoffTime = math.MaxInt64
+	} else {
+		cutoffTime = now.AddDate(0, 0, -rule.Expiration.Days).Unix()
 	}
 
-	// Get all objects in bucket
-	result, err := p.engine.ListObjects(ctx, bucket, engine.ListObjectsOptions{
+	opts := engine.ListObjectsOptions{
+		Prefix:  rulePrefix(rule),
 		MaxKeys: 1000,
-	})
-	if err != nil {
-		logger.Error("failed to list objects for transition", zap.Error(err))
-		return
 	}
+	for {
+		result, err := p.engine.ListObjects(ctx, bucket, opts)
+		if err != nil {
+			logger.Error("failed to list objects for expiration", zap.Error(err))
+			return
+		}
+
+		for _, obj := range result.Objects {
+			if obj.LastModified < cutoffTime && p.matchesTags(ctx, bucket, obj.Key, rule) {
+				p.deleteExpired(ctx, bucket, obj.Key, "")
+			}
+		}
+
+		if !result.IsTruncated || result.NextMarker == "" {
+			return
+		}
+		opts.Marker = result.NextMarker
+
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// deleteExpired deletes an expired object, or one version of it, through the
+// engine. Lifecycle never bypasses object lock: a version under retention or
+// legal hold is left in place and retried on a later run.
+func (p *Processor) deleteExpired(ctx context.Context, bucket, key, versionID string) {
+	_, err := p.engine.DeleteObject(ctx, bucket, key, engine.DeleteObjectOptions{VersionID: versionID})
+	switch {
+	case errors.Is(err, engine.ErrRetentionLocked) || errors.Is(err, engine.ErrLegalHold):
+		logger.Info("skipping expired object under object lock",
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.String("version_id", versionID),
+			zap.Error(err))
+	case err != nil:
+		logger.Error("failed to delete expired object",
+			zap.String("key", key),
+			zap.String("version_id", versionID),
+			zap.Error(err))
+	default:
+		logger.Info("deleted expired object",
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.String("version_id", versionID))
+	}
+}
 
-	now := time.Now().Unix()
+// transitionTarget returns the storage class an object written at
+// lastModified belongs in at now: that of the latest transition whose day
+// count or date has been reached, or "" when none has
+func transitionTarget(transitions []metadata.Transition, lastModified int64, now time.Time) string {
+	target, reached := "", int64(0)
+	for _, t := range transitions {
+		at := t.Date
+		if at == 0 {
+			at = time.Unix(lastModified, 0).AddDate(0, 0, t.Days).Unix()
+		}
+		if at <= now.Unix() && (target == "" || at > reached) {
+			target, reached = t.StorageClass, at
+		}
+	}
+	return target
+}
 
-	for _, obj := range result.Objects {
-		// Get object metadata using HeadObject
-		objMeta, err := p.engine.HeadObject(ctx, bucket, obj.Key)
+// processTransitions moves the objects the rule selects to the storage class
+// of the latest transition they have reached, a page of objects at a time.
+// Only the object's metadata changes, so an object moved to an archive class
+// has to be restored before it can be read again.
+func (p *Processor) processTransitions(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
+	now := p.engine.Clock().Now()
+	opts := engine.ListObjectsOptions{
+		Prefix:  rulePrefix(rule),
+		MaxKeys: 1000,
+	}
+	for {
+		result, err := p.engine.ListObjects(ctx, bucket, opts)
 		if err != nil {
-			continue
+			logger.Error("failed to list objects for transition", zap.Error(err))
+			return
 		}
 
-		// Check each transition
-		for _, transition := range transitions {
-			days := transition.Days
-			if days == 0 {
+		for _, obj := range result.Objects {
+			if !p.matchesTags(ctx, bucket, obj.Key, rule) {
 				continue
 			}
+			objMeta, err := p.engine.HeadObject(ctx, bucket, obj.Key)
+			if err != nil {
+				continue
+			}
+			target := transitionTarget(rule.Transitions, objMeta.LastModified, now)
+			if target != "" && target != objMeta.StorageClass {
+				p.transition(ctx, bucket, obj.Key, "", target)
+			}
+		}
+
+		if !result.IsTruncated || result.NextMarker == "" {
+			return
+		}
+		opts.Marker = result.NextMarker
+
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// processNoncurrentVersionTransitions moves the versions under the rule's
+// prefix to the storage class of the latest noncurrent transition they have
+// reached. As for expiration, a version's noncurrent age runs from when the
+// next newer version of its key was written.
+func (p *Processor) processNoncurrentVersionTransitions(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
+	now := p.engine.Clock().Now()
+	transitions := make([]metadata.Transition, len(rule.NoncurrentVersionTransitions))
+	for i, t := range rule.NoncurrentVersionTransitions {
+		transitions[i] = metadata.Transition{Days: t.NoncurrentDays, StorageClass: t.StorageClass}
+	}
 
-			// Calculate age of object
-			age := now - objMeta.LastModified
-
-			// If object is old enough, transition to new storage class
-			if age >= int64(days) {
-				// Skip if already in target storage class
-				if objMeta.StorageClass == transition.StorageClass {
-					continue
-				}
-
-				// Perform the transition by copying to itself with new storage class
-				_, err := p.engine.CopyObject(ctx, bucket, obj.Key, bucket, obj.Key)
-				if err != nil {
-					logger.Error("failed to transition object",
-						zap.String("bucket", bucket),
-						zap.String("key", obj.Key),
-						zap.String("storage_class", transition.StorageClass),
-						zap.Error(err))
-					continue
-				}
-
-				logger.Info("transitioned object to storage class",
-					zap.String("bucket", bucket),
-					zap.String("key", obj.Key),
-					zap.String("storage_class", transition.StorageClass))
+	type move struct {
+		version engine.ObjectVersion
+		target  string
+	}
+	var moves []move
+	var newer engine.ObjectVersion
+	err := p.eachVersion(ctx, bucket, rulePrefix(rule), func(v engine.ObjectVersion) {
+		// Versions come newest first within each key
+		if !v.IsLatest && !v.IsDeleteMarker && newer.Key == v.Key {
+			target := transitionTarget(transitions, newer.LastModified, now)
+			if target != "" && target != v.StorageClass {
+				moves = append(moves, move{version: v, target: target})
+			}
+		}
+		newer = v
+	})
+	if err != nil {
+		logger.Error("failed to list object versions for transition", zap.Error(err))
+		return
+	}
+
+	for _, m := range moves {
+		select {
+		case <-p.stopCh:
+			return
+		default:
+			if p.matchesTags(ctx, bucket, m.version.Key, rule) {
+				p.transition(ctx, bucket, m.version.Key, m.version.VersionID, m.target)
 			}
 		}
 	}
 }
 
-// processNoncurrentVersionExpiration processes noncurrent version expiration
+// transition moves an object, or one version of it, to a storage class
+// through the engine
+func (p *Processor) transition(ctx context.Context, bucket, key, versionID, storageClass string) {
+	if err := p.engine.TransitionObject(ctx, bucket, key, versionID, storageClass); err != nil {
+		logger.Error("failed to transition object",
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.String("version_id", versionID),
+			zap.String("storage_class", storageClass),
+			zap.Error(err))
+		return
+	}
+	logger.Info("transitioned object to storage class",
+		zap.String("bucket", bucket),
+		zap.String("key", key),
+		zap.String("version_id", versionID),
+		zap.String("storage_class", storageClass))
+}
+
+// processNoncurrentVersionExpiration deletes the versions under the rule's
+// prefix that have been noncurrent for longer than NoncurrentDays. A version
+// becomes noncurrent when the next newer version of its key is written, so
+// its age is taken from that version's LastModified.
 func (p *Processor) processNoncurrentVersionExpiration(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
 	noncurrentExp := rule.NoncurrentVersionExpiration
 	if noncurrentExp == nil || noncurrentExp.NoncurrentDays == 0 {
 		return
 	}
+	cutoffTime := p.engine.Clock().Now().AddDate(0, 0, -noncurrentExp.NoncurrentDays).Unix()
+
+	// Collect the whole listing before deleting, since deleting the version
+	// a page ended on would make the next page skip the rest of its key
+	var expired []engine.ObjectVersion
+	var newer engine.ObjectVersion
+	err := p.eachVersion(ctx, bucket, rulePrefix(rule), func(v engine.ObjectVersion) {
+		// Versions come newest first within each key
+		if !v.IsLatest && newer.Key == v.Key && newer.LastModified < cutoffTime {
+			expired = append(expired, v)
+		}
+		newer = v
+	})
+	if err != nil {
+		logger.Error("failed to list object versions for expiration", zap.Error(err))
+		return
+	}
 
-	// Note: Full version expiration would require tracking all versions
-	// This is a simplified implementation that logs the action
-	logger.Info("noncurrent version expiration",
-		zap.String("bucket", bucket),
-		zap.Int("days", noncurrentExp.NoncurrentDays))
+	for _, v := range expired {
+		select {
+		case <-p.stopCh:
+			return
+		default:
+			if p.matchesTags(ctx, bucket, v.Key, rule) {
+				p.deleteExpired(ctx, bucket, v.Key, v.VersionID)
+			}
+		}
+	}
+}
+
+// processExpiredDeleteMarkers removes the delete markers under the rule's
+// prefix that are all that is left of their key. With no versions behind
+// them they only hide an object that no longer exists.
+func (p *Processor) processExpiredDeleteMarkers(ctx context.Context, bucket string, rule *metadata.LifecycleRule) {
+	versions := make(map[string]int)
+	var markers []engine.ObjectVersion
+	err := p.eachVersion(ctx, bucket, rulePrefix(rule), func(v engine.ObjectVersion) {
+		versions[v.Key]++
+		if v.IsLatest && v.IsDeleteMarker {
+			markers = append(markers, v)
+		}
+	})
+	if err != nil {
+		logger.Error("failed to list object versions for delete markers", zap.Error(err))
+		return
+	}
+
+	for _, m := range markers {
+		if versions[m.Key] != 1 {
+			continue
+		}
+		select {
+		case <-p.stopCh:
+			return
+		default:
+			p.deleteExpired(ctx, bucket, m.Key, m.VersionID)
+		}
+	}
+}
 
-	// In a full implementation, we would:
-	// 1. List all object versions in the bucket
-	// 2. For each non-latest version, check if it's older than NoncurrentDays
-	// 3. Delete versions that exceed the threshold
+// eachVersion calls fn for every version and delete marker under prefix, in
+// listing order, reading the listing a page at a time
+func (p *Processor) eachVersion(ctx context.Context, bucket, prefix string, fn func(engine.ObjectVersion)) error {
+	opts := engine.ListObjectVersionsOptions{
+		Prefix:  prefix,
+		MaxKeys: 1000,
+	}
+	for {
+		result, err := p.engine.ListObjectVersions(ctx, bucket, opts)
+		if err != nil {
+			return err
+		}
+		for _, v := range result.Versions {
+			fn(v)
+		}
+		if !result.IsTruncated {
+			return nil
+		}
+		opts.KeyMarker, opts.VersionIDMarker = result.NextKeyMarker, result.NextVersionIDMarker
+	}
 }
 
 // AddRule adds a lifecycle rule to a bucket
@@ -3,16 +3,22 @@ package lifecycle
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/openendpoint/openendpoint/internal/clock"
 	"github.com/openendpoint/openendpoint/internal/engine"
 	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
 	"github.com/openendpoint/openendpoint/internal/storage"
+	"github.com/openendpoint/openendpoint/internal/tags"
 	"go.uber.org/zap"
 )
 
@@ -20,12 +26,17 @@ type MockStorageBackend struct {
 	mu      sync.RWMutex
 	objects map[string][]byte
 	buckets map[string]bool
+	// clock, if set, stamps objects with their write time instead of two
+	// days ago
+	clock    clock.Clock
+	modTimes map[string]int64
 }
 
 func NewMockStorageBackend() *MockStorageBackend {
 	return &MockStorageBackend{
-		objects: make(map[string][]byte),
-		buckets: make(map[string]bool),
+		objects:  make(map[string][]byte),
+		buckets:  make(map[string]bool),
+		modTimes: make(map[string]int64),
 	}
 }
 
@@ -33,15 +44,25 @@ func (m *MockStorageBackend) objectKey(bucket, key string) string {
 	return bucket + "/" + key
 }
 
+func (m *MockStorageBackend) lastModified(objectKey string) int64 {
+	if t, ok := m.modTimes[objectKey]; ok {
+		return t
+	}
+	return time.Now().Add(-48 * time.Hour).Unix()
+}
+
 func (m *MockStorageBackend) Put(ctx context.Context, bucket, key string, data io.Reader, size int64, opts storage.PutOptions) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
 	b, err := io.ReadAll(data)
 	if err != nil {
 		return err
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.objects[m.objectKey(bucket, key)] = b
 	m.buckets[bucket] = true
+	if m.clock != nil {
+		m.modTimes[m.objectKey(bucket, key)] = m.clock.Now().Unix()
+	}
 	return nil
 }
 
@@ -59,6 +80,7 @@ func (m *MockStorageBackend) Delete(ctx context.Context, bucket, key string) err
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.objects, m.objectKey(bucket, key))
+	delete(m.modTimes, m.objectKey(bucket, key))
 	return nil
 }
 
@@ -72,7 +94,7 @@ func (m *MockStorageBackend) Head(ctx context.Context, bucket, key string) (*sto
 	return &storage.ObjectInfo{
 		Key:          key,
 		Size:         int64(len(data)),
-		LastModified: time.Now().Add(-48 * time.Hour).Unix(),
+		LastModified: m.lastModified(m.objectKey(bucket, key)),
 	}, nil
 }
 
@@ -86,10 +108,18 @@ func (m *MockStorageBackend) List(ctx context.Context, bucket, prefix string, op
 			objects = append(objects, storage.ObjectInfo{
 				Key:          k[len(bucket)+1:],
 				Size:         int64(len(v)),
-				LastModified: time.Now().Add(-48 * time.Hour).Unix(),
+				LastModified: m.lastModified(k),
 			})
 		}
 	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	if opts.Marker != "" {
+		i := sort.Search(len(objects), func(i int) bool { return objects[i].Key > opts.Marker })
+		objects = objects[i:]
+	}
+	if opts.MaxKeys > 0 && len(objects) > opts.MaxKeys {
+		objects = objects[:opts.MaxKeys]
+	}
 	return &storage.ListResult{Objects: objects}, nil
 }
 
@@ -140,6 +170,7 @@ type MockMetadataStore struct {
 	policies    map[string]*string
 	encryption  map[string]*metadata.BucketEncryption
 	tags        map[string]map[string]string
+	objectTags  map[string]map[string]string
 	replication map[string]*metadata.ReplicationConfig
 	lifecycle   map[string][]metadata.LifecycleRule
 	uploads     map[string][]metadata.MultipartUploadMetadata
@@ -155,6 +186,7 @@ func NewMockMetadataStore() *MockMetadataStore {
 		policies:    make(map[string]*string),
 		encryption:  make(map[string]*metadata.BucketEncryption),
 		tags:        make(map[string]map[string]string),
+		objectTags:  make(map[string]map[string]string),
 		replication: make(map[string]*metadata.ReplicationConfig),
 		lifecycle:   make(map[string][]metadata.LifecycleRule),
 		uploads:     make(map[string][]metadata.MultipartUploadMetadata),
@@ -221,6 +253,28 @@ func (m *MockMetadataStore) DeleteObject(ctx context.Context, bucket, key string
 	delete(m.objects, m.objectKey(bucket, key))
 	return nil
 }
+func (m *MockMetadataStore) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.objects[m.objectKey(srcBucket, srcKey)]
+	if !ok {
+		return io.EOF
+	}
+	delete(m.objects, m.objectKey(srcBucket, srcKey))
+	moved := *o
+	moved.Bucket, moved.Key = dstBucket, dstKey
+	m.objects[m.objectKey(dstBucket, dstKey)] = &moved
+	return nil
+}
+func (m *MockMetadataStore) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[m.objectKey(bucket, key)]; !ok {
+		return metadata.ErrObjectNotFound
+	}
+	m.objects[m.objectKey(bucket, key)] = meta
+	return nil
+}
 
 func (m *MockMetadataStore) ListObjects(ctx context.Context, bucket, prefix string, opts metadata.ListOptions) ([]metadata.ObjectMetadata, error) {
 	m.mu.RLock()
@@ -235,10 +289,52 @@ func (m *MockMetadataStore) ListObjects(ctx context.Context, bucket, prefix stri
 	return objects, nil
 }
 
+func (m *MockMetadataStore) ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(metadata.ObjectMetadata) error) error {
+	objects, _ := m.ListObjects(ctx, bucket, prefix, metadata.ListOptions{})
+	for _, obj := range objects {
+		if obj.Key <= marker {
+			continue
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockMetadataStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]metadata.ObjectMetadata, error) {
+	return m.ListObjects(ctx, bucket, prefix, metadata.ListOptions{})
+}
+
+func (m *MockMetadataStore) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	objects, err := m.ListObjects(ctx, bucket, "", metadata.ListOptions{})
+	return len(objects) == 0, err
+}
+
 func (m *MockMetadataStore) Close() error {
 	return nil
 }
 
+func (m *MockMetadataStore) Compact(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockMetadataStore) Stats() (*metadata.Stats, error) {
+	return &metadata.Stats{}, nil
+}
+
+func (m *MockMetadataStore) PutIAMEntity(ctx context.Context, kind, id string, data []byte) error {
+	return nil
+}
+
+func (m *MockMetadataStore) DeleteIAMEntity(ctx context.Context, kind, id string) error {
+	return nil
+}
+
+func (m *MockMetadataStore) ListIAMEntities(ctx context.Context, kind string) ([][]byte, error) {
+	return nil, nil
+}
+
 func (m *MockMetadataStore) CreateMultipartUpload(ctx context.Context, bucket, key, uploadID string, meta *metadata.ObjectMetadata) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -427,6 +523,35 @@ func (m *MockMetadataStore) DeleteBucketTags(ctx context.Context, bucket string)
 	return nil
 }
 
+// objectEntryKey keys the tags, retention and legal hold of an object or of
+// one of its versions
+func objectEntryKey(bucket, key, versionID string) string {
+	if versionID == "" {
+		return bucket + "/" + key
+	}
+	return bucket + "/" + key + "\x00" + versionID
+}
+
+func (m *MockMetadataStore) PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objectTags[objectEntryKey(bucket, key, versionID)] = tags
+	return nil
+}
+
+func (m *MockMetadataStore) GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.objectTags[objectEntryKey(bucket, key, versionID)], nil
+}
+
+func (m *MockMetadataStore) DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objectTags, bucket+"/"+key)
+	return nil
+}
+
 func (m *MockMetadataStore) PutObjectLock(ctx context.Context, bucket string, config *metadata.ObjectLockConfig) error {
 	return nil
 }
@@ -439,19 +564,19 @@ func (m *MockMetadataStore) DeleteObjectLock(ctx context.Context, bucket string)
 	return nil
 }
 
-func (m *MockMetadataStore) PutObjectRetention(ctx context.Context, bucket, key string, retention *metadata.ObjectRetention) error {
+func (m *MockMetadataStore) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error {
 	return nil
 }
 
-func (m *MockMetadataStore) GetObjectRetention(ctx context.Context, bucket, key string) (*metadata.ObjectRetention, error) {
+func (m *MockMetadataStore) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
 	return nil, nil
 }
 
-func (m *MockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *metadata.ObjectLegalHold) error {
+func (m *MockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
 	return nil
 }
 
-func (m *MockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key string) (*metadata.ObjectLegalHold, error) {
+func (m *MockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
 	return nil, nil
 }
 
@@ -467,6 +592,30 @@ func (m *MockMetadataStore) DeletePublicAccessBlock(ctx context.Context, bucket
 	return nil
 }
 
+func (m *MockMetadataStore) PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error {
+	return nil
+}
+
+func (m *MockMetadataStore) GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error) {
+	return nil, nil
+}
+
+func (m *MockMetadataStore) DeleteBucketACL(ctx context.Context, bucket string) error {
+	return nil
+}
+
+func (m *MockMetadataStore) PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	return nil
+}
+
+func (m *MockMetadataStore) GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error) {
+	return nil, nil
+}
+
+func (m *MockMetadataStore) DeleteObjectACL(ctx context.Context, bucket, key string) error {
+	return nil
+}
+
 func (m *MockMetadataStore) PutBucketAccelerate(ctx context.Context, bucket string, config *metadata.BucketAccelerateConfiguration) error {
 	return nil
 }
@@ -479,6 +628,30 @@ func (m *MockMetadataStore) DeleteBucketAccelerate(ctx context.Context, bucket s
 	return nil
 }
 
+func (m *MockMetadataStore) PutBucketKeyNormalization(ctx context.Context, bucket string, config *metadata.KeyNormalizationConfig) error {
+	return nil
+}
+
+func (m *MockMetadataStore) GetBucketKeyNormalization(ctx context.Context, bucket string) (*metadata.KeyNormalizationConfig, error) {
+	return nil, nil
+}
+
+func (m *MockMetadataStore) PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error {
+	return nil
+}
+
+func (m *MockMetadataStore) GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error) {
+	return nil, nil
+}
+
+func (m *MockMetadataStore) PutBucketUsage(ctx context.Context, bucket string, usage *metadata.BucketUsage) error {
+	return nil
+}
+
+func (m *MockMetadataStore) GetBucketUsage(ctx context.Context, bucket string) (*metadata.BucketUsage, error) {
+	return nil, nil
+}
+
 func (m *MockMetadataStore) PutBucketInventory(ctx context.Context, bucket, id string, config *metadata.InventoryConfiguration) error {
 	return nil
 }
@@ -862,6 +1035,38 @@ func TestProcessor_ProcessExpirationWithObjects(t *testing.T) {
 	processor.Stop()
 }
 
+func TestProcessor_ProcessExpirationWithClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMockStorageBackend()
+	store.clock = fake
+	eng := engine.New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	eng.SetClock(fake)
+	ctx := context.Background()
+
+	eng.CreateBucket(ctx, "test-bucket")
+	rule := &metadata.LifecycleRule{
+		ID:         "expiration-rule",
+		Status:     "Enabled",
+		Expiration: &metadata.Expiration{Days: 1},
+	}
+	eng.PutObject(ctx, "test-bucket", "file.txt", strings.NewReader("content"), engine.PutObjectOptions{})
+
+	processor := NewProcessor(eng, time.Minute)
+
+	// Exactly a day old is not yet expired
+	fake.Advance(24 * time.Hour)
+	processor.processExpiration(ctx, "test-bucket", rule)
+	if _, err := eng.HeadObject(ctx, "test-bucket", "file.txt"); err != nil {
+		t.Fatalf("object expired after exactly 1 day: %v", err)
+	}
+
+	fake.Advance(time.Second)
+	processor.processExpiration(ctx, "test-bucket", rule)
+	if _, err := eng.HeadObject(ctx, "test-bucket", "file.txt"); err == nil {
+		t.Error("object not expired after more than 1 day")
+	}
+}
+
 func TestProcessor_ProcessTransitionsWithObjects(t *testing.T) {
 	eng := createTestEngine(t)
 	ctx := context.Background()
@@ -1694,11 +1899,11 @@ type ErrorObjectService struct {
 	copyObjectErr error
 }
 
-func (e *ErrorObjectService) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) (*engine.CopyObjectResult, error) {
+func (e *ErrorObjectService) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, opts engine.CopyObjectOptions) (*engine.CopyObjectResult, error) {
 	if e.copyObjectErr != nil {
 		return nil, e.copyObjectErr
 	}
-	return e.ObjectService.CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	return e.ObjectService.CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey, opts)
 }
 
 func TestProcessor_RemoveRuleGetRulesError(t *testing.T) {
@@ -1781,3 +1986,394 @@ func TestProcessor_ProcessTransitionsCopyObjectError(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	processor.Stop()
 }
+
+func TestProcessor_ProcessExpirationPaginates(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMockStorageBackend()
+	store.clock = fake
+	eng := engine.New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	eng.SetClock(fake)
+	ctx := context.Background()
+	eng.CreateBucket(ctx, "test-bucket")
+
+	// More objects than one listing page returns
+	for i := 0; i < 1500; i++ {
+		eng.PutObject(ctx, "test-bucket", fmt.Sprintf("logs/%05d.txt", i), strings.NewReader("x"), engine.PutObjectOptions{})
+	}
+	eng.PutObject(ctx, "test-bucket", "keep/file.txt", strings.NewReader("x"), engine.PutObjectOptions{})
+
+	fake.Advance(48 * time.Hour)
+	processor := NewProcessor(eng, time.Minute)
+	processor.processExpiration(ctx, "test-bucket", &metadata.LifecycleRule{
+		ID:         "expire-logs",
+		Prefix:     "logs/",
+		Status:     "Enabled",
+		Expiration: &metadata.Expiration{Days: 1},
+	})
+
+	result, err := eng.ListObjects(ctx, "test-bucket", engine.ListObjectsOptions{})
+	if err != nil {
+		t.Fatalf("ListObjects() error = %v", err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Key != "keep/file.txt" {
+		t.Errorf("objects after expiration = %d, want only keep/file.txt", len(result.Objects))
+	}
+}
+
+// newVersionedTestEngine returns an engine over a Pebble metadata store,
+// which keeps object versions, with its clock set to fake
+func newVersionedTestEngine(t *testing.T, fake *clock.Fake) *engine.ObjectService {
+	dir, err := os.MkdirTemp("", "lifecycle-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	meta, err := pebble.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { meta.Close() })
+
+	store := NewMockStorageBackend()
+	store.clock = fake
+	eng := engine.New(store, meta, zap.NewNop().Sugar())
+	eng.SetClock(fake)
+	return eng
+}
+
+func TestProcessor_ProcessNoncurrentVersionExpiration(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	eng := newVersionedTestEngine(t, fake)
+	ctx := context.Background()
+	eng.CreateBucket(ctx, "test-bucket")
+	eng.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	put := func(key, body string) string {
+		result, err := eng.PutObject(ctx, "test-bucket", key, strings.NewReader(body), engine.PutObjectOptions{})
+		if err != nil {
+			t.Fatalf("PutObject(%s) error = %v", key, err)
+		}
+		return result.VersionID
+	}
+
+	// v1 is superseded on day 1 and v2 on day 10
+	v1 := put("doc.txt", "v1")
+	fake.Advance(24 * time.Hour)
+	v2 := put("doc.txt", "v2")
+	fake.Advance(9 * 24 * time.Hour)
+	v3 := put("doc.txt", "v3")
+	fake.Advance(2 * 24 * time.Hour)
+
+	processor := NewProcessor(eng, time.Minute)
+	processor.processNoncurrentVersionExpiration(ctx, "test-bucket", &metadata.LifecycleRule{
+		ID:                          "noncurrent",
+		Status:                      "Enabled",
+		NoncurrentVersionExpiration: &metadata.NoncurrentVersionExpiration{NoncurrentDays: 5},
+	})
+
+	result, err := eng.ListObjectVersions(ctx, "test-bucket", engine.ListObjectVersionsOptions{})
+	if err != nil {
+		t.Fatalf("ListObjectVersions() error = %v", err)
+	}
+	var got []string
+	for _, v := range result.Versions {
+		got = append(got, v.VersionID)
+	}
+	// v1 has been noncurrent for 11 days, v2 only for 2
+	if len(got) != 2 || got[0] != v3 || got[1] != v2 {
+		t.Errorf("versions after expiration = %v, want [%s %s] (v1 %s expired)", got, v3, v2, v1)
+	}
+
+	obj, err := eng.GetObject(ctx, "test-bucket", "doc.txt", engine.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	if body, _ := io.ReadAll(obj.Body); string(body) != "v3" {
+		t.Errorf("current version = %q, want v3", body)
+	}
+}
+
+func TestProcessor_ExpirationRespectsObjectLock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	eng := newVersionedTestEngine(t, fake)
+	ctx := context.Background()
+	eng.CreateBucket(ctx, "test-bucket")
+	eng.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	eng.PutObject(ctx, "test-bucket", "locked.txt", strings.NewReader("v1"), engine.PutObjectOptions{})
+	fake.Advance(24 * time.Hour)
+	eng.PutObject(ctx, "test-bucket", "locked.txt", strings.NewReader("v2"), engine.PutObjectOptions{})
+	eng.PutObject(ctx, "test-bucket", "held.txt", strings.NewReader("v1"), engine.PutObjectOptions{})
+	fake.Advance(24 * time.Hour)
+	eng.PutObject(ctx, "test-bucket", "held.txt", strings.NewReader("v2"), engine.PutObjectOptions{})
+
+	retainUntil := fake.Now().Add(30 * 24 * time.Hour).Unix()
+	if err := eng.PutObjectRetention(ctx, "test-bucket", "locked.txt", "", &metadata.ObjectRetention{
+		Mode:            engine.RetentionGovernance,
+		RetainUntilDate: retainUntil,
+	}, engine.RetentionOptions{}); err != nil {
+		t.Fatalf("PutObjectRetention() error = %v", err)
+	}
+	if err := eng.PutObjectLegalHold(ctx, "test-bucket", "held.txt", "", &metadata.ObjectLegalHold{Status: engine.LegalHoldOn}); err != nil {
+		t.Fatalf("PutObjectLegalHold() error = %v", err)
+	}
+
+	rule := &metadata.LifecycleRule{
+		ID:                          "noncurrent",
+		Status:                      "Enabled",
+		NoncurrentVersionExpiration: &metadata.NoncurrentVersionExpiration{NoncurrentDays: 1},
+	}
+	countVersions := func() int {
+		result, err := eng.ListObjectVersions(ctx, "test-bucket", engine.ListObjectVersionsOptions{})
+		if err != nil {
+			t.Fatalf("ListObjectVersions() error = %v", err)
+		}
+		return len(result.Versions)
+	}
+
+	fake.Advance(10 * 24 * time.Hour)
+	processor := NewProcessor(eng, time.Minute)
+	processor.processNoncurrentVersionExpiration(ctx, "test-bucket", rule)
+	if n := countVersions(); n != 4 {
+		t.Fatalf("versions after expiring locked objects = %d, want all 4 kept", n)
+	}
+
+	// Once the retention lapses and the hold is lifted, they expire
+	fake.Advance(30 * 24 * time.Hour)
+	eng.PutObjectLegalHold(ctx, "test-bucket", "held.txt", "", &metadata.ObjectLegalHold{Status: engine.LegalHoldOff})
+	processor.processNoncurrentVersionExpiration(ctx, "test-bucket", rule)
+	if n := countVersions(); n != 2 {
+		t.Errorf("versions after the locks lapsed = %d, want 2", n)
+	}
+}
+
+func TestProcessor_StopTwice(t *testing.T) {
+	processor := NewProcessor(createTestEngine(t), time.Minute)
+	processor.Start()
+	processor.Stop()
+	processor.Stop()
+}
+
+func TestProcessor_ExpirationFilterAndDate(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	eng := newVersionedTestEngine(t, fake)
+	ctx := context.Background()
+	eng.CreateBucket(ctx, "test-bucket")
+
+	for _, key := range []string{"logs/tagged.txt", "logs/untagged.txt", "data/tagged.txt"} {
+		if _, err := eng.PutObject(ctx, "test-bucket", key, strings.NewReader("x"), engine.PutObjectOptions{}); err != nil {
+			t.Fatalf("PutObject(%s) error = %v", key, err)
+		}
+	}
+	for _, key := range []string{"logs/tagged.txt", "data/tagged.txt"} {
+		if err := eng.PutObjectTags(ctx, "test-bucket", key, "", tags.TagSet{{Key: "class", Value: "temp"}}); err != nil {
+			t.Fatalf("PutObjectTags(%s) error = %v", key, err)
+		}
+	}
+
+	rule := &metadata.LifecycleRule{
+		ID:     "dated",
+		Status: "Enabled",
+		Filter: &metadata.LifecycleFilter{
+			Prefix: "logs/",
+			Tags:   map[string]string{"class": "temp"},
+		},
+		Expiration: &metadata.Expiration{
+			Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		},
+	}
+	exists := func(key string) bool {
+		_, err := eng.HeadObject(ctx, "test-bucket", key)
+		return err == nil
+	}
+
+	processor := NewProcessor(eng, time.Minute)
+	processor.processRule(ctx, "test-bucket", rule)
+	if !exists("logs/tagged.txt") {
+		t.Fatal("object expired before the expiration date")
+	}
+
+	fake.Advance(31 * 24 * time.Hour)
+	processor.processRule(ctx, "test-bucket", rule)
+	if exists("logs/tagged.txt") {
+		t.Error("logs/tagged.txt should have expired")
+	}
+	if !exists("logs/untagged.txt") {
+		t.Error("logs/untagged.txt lacks the filter tag and should be kept")
+	}
+	if !exists("data/tagged.txt") {
+		t.Error("data/tagged.txt is outside the filter prefix and should be kept")
+	}
+}
+
+func TestProcessor_ExpiredObjectDeleteMarker(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	eng := newVersionedTestEngine(t, fake)
+	ctx := context.Background()
+	eng.CreateBucket(ctx, "test-bucket")
+	eng.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	// gone.txt is left with only its delete marker, kept.txt still has a
+	// version behind its marker
+	gone, err := eng.PutObject(ctx, "test-bucket", "gone.txt", strings.NewReader("x"), engine.PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	eng.PutObject(ctx, "test-bucket", "kept.txt", strings.NewReader("x"), engine.PutObjectOptions{})
+	eng.DeleteObject(ctx, "test-bucket", "gone.txt", engine.DeleteObjectOptions{})
+	eng.DeleteObject(ctx, "test-bucket", "kept.txt", engine.DeleteObjectOptions{})
+	if _, err := eng.DeleteObject(ctx, "test-bucket", "gone.txt", engine.DeleteObjectOptions{VersionID: gone.VersionID}); err != nil {
+		t.Fatalf("DeleteObject(version) error = %v", err)
+	}
+
+	processor := NewProcessor(eng, time.Minute)
+	processor.processRule(ctx, "test-bucket", &metadata.LifecycleRule{
+		ID:         "markers",
+		Status:     "Enabled",
+		Expiration: &metadata.Expiration{ExpiredObjectDeleteMarker: true},
+	})
+
+	result, err := eng.ListObjectVersions(ctx, "test-bucket", engine.ListObjectVersionsOptions{})
+	if err != nil {
+		t.Fatalf("ListObjectVersions() error = %v", err)
+	}
+	var got []string
+	for _, v := range result.Versions {
+		got = append(got, fmt.Sprintf("%s:%v", v.Key, v.IsDeleteMarker))
+	}
+	want := []string{"kept.txt:true", "kept.txt:false"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("versions after cleanup = %v, want %v", got, want)
+	}
+}
+
+func TestProcessor_TransitionUpdatesStorageClass(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMockStorageBackend()
+	store.clock = fake
+	eng := engine.New(store, NewMockMetadataStore(), zap.NewNop().Sugar())
+	eng.SetClock(fake)
+	ctx := context.Background()
+
+	eng.CreateBucket(ctx, "test-bucket")
+	eng.PutObject(ctx, "test-bucket", "file.txt", strings.NewReader("content"), engine.PutObjectOptions{})
+	rule := &metadata.LifecycleRule{
+		ID:     "transition-rule",
+		Status: "Enabled",
+		Transitions: []metadata.Transition{
+			{Days: 30, StorageClass: "STANDARD_IA"},
+			{Days: 90, StorageClass: "GLACIER"},
+		},
+	}
+	processor := NewProcessor(eng, time.Minute)
+
+	storageClass := func() string {
+		info, err := eng.HeadObject(ctx, "test-bucket", "file.txt")
+		if err != nil {
+			t.Fatalf("HeadObject() error = %v", err)
+		}
+		return info.StorageClass
+	}
+
+	fake.Advance(29 * 24 * time.Hour)
+	processor.processTransitions(ctx, "test-bucket", rule)
+	if got := storageClass(); got == "STANDARD_IA" || got == "GLACIER" {
+		t.Fatalf("storage class after 29 days = %s, want unchanged", got)
+	}
+
+	fake.Advance(24 * time.Hour)
+	processor.processTransitions(ctx, "test-bucket", rule)
+	if got := storageClass(); got != "STANDARD_IA" {
+		t.Fatalf("storage class after 30 days = %s, want STANDARD_IA", got)
+	}
+	if _, err := eng.GetObject(ctx, "test-bucket", "file.txt", engine.GetObjectOptions{}); err != nil {
+		t.Fatalf("GetObject() of a STANDARD_IA object error = %v", err)
+	}
+
+	fake.Advance(60 * 24 * time.Hour)
+	processor.processTransitions(ctx, "test-bucket", rule)
+	if got := storageClass(); got != "GLACIER" {
+		t.Fatalf("storage class after 90 days = %s, want GLACIER", got)
+	}
+
+	// An archived object needs a restore before it can be read
+	if _, err := eng.GetObject(ctx, "test-bucket", "file.txt", engine.GetObjectOptions{}); !errors.Is(err, engine.ErrInvalidObjectState) {
+		t.Fatalf("GetObject() of a GLACIER object error = %v, want ErrInvalidObjectState", err)
+	}
+	if _, err := eng.RestoreObject(ctx, "test-bucket", "file.txt", engine.RestoreObjectOptions{Days: 1}); err != nil {
+		t.Fatalf("RestoreObject() error = %v", err)
+	}
+	obj, err := eng.GetObject(ctx, "test-bucket", "file.txt", engine.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() after restore error = %v", err)
+	}
+	obj.Body.Close()
+
+	fake.Advance(25 * time.Hour)
+	if _, err := eng.GetObject(ctx, "test-bucket", "file.txt", engine.GetObjectOptions{}); !errors.Is(err, engine.ErrInvalidObjectState) {
+		t.Errorf("GetObject() after the restore expired error = %v, want ErrInvalidObjectState", err)
+	}
+}
+
+func TestProcessor_NoncurrentVersionTransition(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	eng := newVersionedTestEngine(t, fake)
+	ctx := context.Background()
+	eng.CreateBucket(ctx, "test-bucket")
+	eng.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	put := func(body string) string {
+		result, err := eng.PutObject(ctx, "test-bucket", "doc.txt", strings.NewReader(body), engine.PutObjectOptions{})
+		if err != nil {
+			t.Fatalf("PutObject() error = %v", err)
+		}
+		return result.VersionID
+	}
+
+	// v1 is superseded on day 1 and v2 on day 10
+	v1 := put("v1")
+	fake.Advance(24 * time.Hour)
+	v2 := put("v2")
+	fake.Advance(9 * 24 * time.Hour)
+	v3 := put("v3")
+	fake.Advance(2 * 24 * time.Hour)
+
+	processor := NewProcessor(eng, time.Minute)
+	processor.processNoncurrentVersionTransitions(ctx, "test-bucket", &metadata.LifecycleRule{
+		ID:     "noncurrent-transition",
+		Status: "Enabled",
+		NoncurrentVersionTransitions: []metadata.NoncurrentVersionTransition{
+			{NoncurrentDays: 5, StorageClass: "GLACIER"},
+		},
+	})
+
+	result, err := eng.ListObjectVersions(ctx, "test-bucket", engine.ListObjectVersionsOptions{})
+	if err != nil {
+		t.Fatalf("ListObjectVersions() error = %v", err)
+	}
+	classes := make(map[string]string)
+	for _, v := range result.Versions {
+		classes[v.VersionID] = v.StorageClass
+	}
+	// v1 has been noncurrent for 11 days, v2 only for 2, and v3 is current
+	if classes[v1] != "GLACIER" {
+		t.Errorf("v1 storage class = %q, want GLACIER", classes[v1])
+	}
+	if classes[v2] == "GLACIER" || classes[v3] == "GLACIER" {
+		t.Errorf("v2, v3 storage classes = %q, %q, want neither GLACIER", classes[v2], classes[v3])
+	}
+
+	// The current version stays the latest and readable
+	obj, err := eng.GetObject(ctx, "test-bucket", "doc.txt", engine.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	defer obj.Body.Close()
+	if obj.VersionID != v3 {
+		t.Errorf("GetObject() version = %s, want %s", obj.VersionID, v3)
+	}
+	if _, err := eng.GetObject(ctx, "test-bucket", "doc.txt", engine.GetObjectOptions{VersionID: v1}); !errors.Is(err, engine.ErrInvalidObjectState) {
+		t.Errorf("GetObject(v1) error = %v, want ErrInvalidObjectState", err)
+	}
+}
@@ -0,0 +1,243 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default bulk ingest batching
+const (
+	DefaultBatchSize     = 256
+	DefaultFlushInterval = 100 * time.Millisecond
+)
+
+// ObjectWrite is one object metadata write of a batch
+type ObjectWrite struct {
+	Bucket string
+	Key    string
+	Meta   *ObjectMetadata
+}
+
+// BatchStore is a Store that can commit several object writes at once, with
+// a single sync for all of them
+type BatchStore interface {
+	Store
+	PutObjects(ctx context.Context, writes []ObjectWrite) error
+}
+
+// BatchWriter is a Store for bulk loads. PutObject queues the write and
+// returns, and queued writes are committed together once batchSize have
+// queued or flushInterval has passed, so one sync covers many objects.
+// Writes still queued are lost if the process dies, so it trades the
+// durability of the last flushInterval of writes for throughput; the store
+// it wraps keeps syncing every write when used directly.
+//
+// GetObject answers from the queue, so writes are read back at once. Every
+// other operation on object metadata flushes the queue first.
+type BatchWriter struct {
+	BatchStore
+
+	batchSize int
+
+	mu      sync.Mutex
+	pending []ObjectWrite
+	// err is the error of the last background flush, returned by the next
+	// Flush or PutObject
+	err error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBatchWriter wraps store, flushing every batchSize writes and every
+// flushInterval. Zero or negative values use the defaults.
+func NewBatchWriter(store BatchStore, batchSize int, flushInterval time.Duration) *BatchWriter {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+
+	w := &BatchWriter{
+		BatchStore: store,
+		batchSize:  batchSize,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go w.run(flushInterval)
+	return w
+}
+
+// run flushes the queue every interval until Close
+func (w *BatchWriter) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if err := w.flushLocked(context.Background()); err != nil {
+				w.err = err
+			}
+			w.mu.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// PutObject queues an object metadata write, flushing the queue when it
+// reaches the batch size. A write whose flush fails is dropped from the
+// queue and its error returned, so callers can undo it.
+func (w *BatchWriter) PutObject(ctx context.Context, bucket, key string, meta *ObjectMetadata) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.takeErr(); err != nil {
+		return err
+	}
+	w.pending = append(w.pending, ObjectWrite{Bucket: bucket, Key: key, Meta: meta})
+	if len(w.pending) < w.batchSize {
+		return nil
+	}
+	if err := w.flushLocked(ctx); err != nil {
+		w.pending = w.pending[:len(w.pending)-1]
+		return err
+	}
+	return nil
+}
+
+// GetObject gets object metadata, including writes still queued
+func (w *BatchWriter) GetObject(ctx context.Context, bucket, key string, versionID string) (*ObjectMetadata, error) {
+	w.mu.Lock()
+	for i := len(w.pending) - 1; i >= 0; i-- {
+		p := w.pending[i]
+		if p.Bucket != bucket || p.Key != key {
+			continue
+		}
+		if versionID != "" && p.Meta.VersionID != versionID {
+			continue
+		}
+		meta := *p.Meta
+		w.mu.Unlock()
+		if versionID == "" && meta.IsDeleteMarker {
+			return nil, fmt.Errorf("object %w: %s/%s", ErrObjectNotFound, bucket, key)
+		}
+		return &meta, nil
+	}
+	w.mu.Unlock()
+	return w.BatchStore.GetObject(ctx, bucket, key, versionID)
+}
+
+// Flush commits every queued write
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.flushLocked(ctx); err != nil {
+		return err
+	}
+	return w.takeErr()
+}
+
+// flushLocked commits the queue. Failed writes stay queued to be retried.
+// w.mu must be held.
+func (w *BatchWriter) flushLocked(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if err := w.BatchStore.PutObjects(ctx, w.pending); err != nil {
+		return fmt.Errorf("failed to flush %d object writes: %w", len(w.pending), err)
+	}
+	w.pending = nil
+	return nil
+}
+
+// takeErr returns and clears the last background flush error. w.mu must be
+// held.
+func (w *BatchWriter) takeErr() error {
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// DeleteObject flushes queued writes, then deletes object metadata
+func (w *BatchWriter) DeleteObject(ctx context.Context, bucket, key string, versionID string) error {
+	if err := w.Flush(ctx); err != nil {
+		return err
+	}
+	return w.BatchStore.DeleteObject(ctx, bucket, key, versionID)
+}
+
+// ListObjects flushes queued writes, then lists objects
+func (w *BatchWriter) ListObjects(ctx context.Context, bucket, prefix string, opts ListOptions) ([]ObjectMetadata, error) {
+	if err := w.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return w.BatchStore.ListObjects(ctx, bucket, prefix, opts)
+}
+
+// ListObjectsFunc flushes queued writes, then lists objects
+func (w *BatchWriter) ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(ObjectMetadata) error) error {
+	if err := w.Flush(ctx); err != nil {
+		return err
+	}
+	return w.BatchStore.ListObjectsFunc(ctx, bucket, prefix, marker, fn)
+}
+
+// ListObjectVersions flushes queued writes, then lists object versions
+func (w *BatchWriter) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]ObjectMetadata, error) {
+	if err := w.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return w.BatchStore.ListObjectVersions(ctx, bucket, prefix)
+}
+
+// BucketIsEmpty flushes queued writes, then checks the bucket
+func (w *BatchWriter) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	if err := w.Flush(ctx); err != nil {
+		return false, err
+	}
+	return w.BatchStore.BucketIsEmpty(ctx, bucket)
+}
+
+// MoveObject flushes queued writes, then moves object metadata
+func (w *BatchWriter) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if err := w.Flush(ctx); err != nil {
+		return err
+	}
+	return w.BatchStore.MoveObject(ctx, srcBucket, srcKey, dstBucket, dstKey)
+}
+
+// UpdateObjectVersion flushes queued writes, then rewrites a version
+func (w *BatchWriter) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *ObjectMetadata) error {
+	if err := w.Flush(ctx); err != nil {
+		return err
+	}
+	return w.BatchStore.UpdateObjectVersion(ctx, bucket, key, meta)
+}
+
+// CompleteMultipartUpload flushes queued writes, then completes the upload
+func (w *BatchWriter) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []PartInfo) error {
+	if err := w.Flush(ctx); err != nil {
+		return err
+	}
+	return w.BatchStore.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+}
+
+// Close stops the background flushes, commits the queue and closes the
+// store it wraps
+func (w *BatchWriter) Close() error {
+	close(w.stop)
+	<-w.done
+
+	flushErr := w.Flush(context.Background())
+	if err := w.BatchStore.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
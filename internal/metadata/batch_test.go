@@ -0,0 +1,164 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBatchStore records committed batches. Methods the tests do not use
+// are left to the nil Store and panic if called.
+type fakeBatchStore struct {
+	Store
+
+	mu       sync.Mutex
+	objects  map[string]*ObjectMetadata
+	batches  []int
+	putErr   error
+	isClosed bool
+}
+
+func newFakeBatchStore() *fakeBatchStore {
+	return &fakeBatchStore{objects: make(map[string]*ObjectMetadata)}
+}
+
+func (f *fakeBatchStore) PutObjects(ctx context.Context, writes []ObjectWrite) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.putErr != nil {
+		return f.putErr
+	}
+	for _, w := range writes {
+		f.objects[w.Bucket+"/"+w.Key] = w.Meta
+	}
+	f.batches = append(f.batches, len(writes))
+	return nil
+}
+
+func (f *fakeBatchStore) GetObject(ctx context.Context, bucket, key string, versionID string) (*ObjectMetadata, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	meta, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("object %w: %s/%s", ErrObjectNotFound, bucket, key)
+	}
+	return meta, nil
+}
+
+func (f *fakeBatchStore) ListObjects(ctx context.Context, bucket, prefix string, opts ListOptions) ([]ObjectMetadata, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var objects []ObjectMetadata
+	for _, meta := range f.objects {
+		objects = append(objects, *meta)
+	}
+	return objects, nil
+}
+
+func (f *fakeBatchStore) Close() error {
+	f.isClosed = true
+	return nil
+}
+
+func (f *fakeBatchStore) committed() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.batches...)
+}
+
+func TestBatchWriter_FlushesBySize(t *testing.T) {
+	store := newFakeBatchStore()
+	w := NewBatchWriter(store, 3, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 7; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if err := w.PutObject(ctx, "b", key, &ObjectMetadata{Key: key}); err != nil {
+			t.Fatalf("PutObject(%s) error: %v", key, err)
+		}
+	}
+	if got := store.committed(); len(got) != 2 || got[0] != 3 || got[1] != 3 {
+		t.Errorf("batches = %v, expected [3 3]", got)
+	}
+
+	// The seventh write is read back before it is committed
+	if meta, err := w.GetObject(ctx, "b", "k6", ""); err != nil || meta.Key != "k6" {
+		t.Errorf("GetObject(k6) = %+v, %v", meta, err)
+	}
+
+	// Listing sees every write
+	objects, err := w.ListObjects(ctx, "b", "", ListOptions{})
+	if err != nil || len(objects) != 7 {
+		t.Errorf("ListObjects() = %d objects, %v, expected 7", len(objects), err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if !store.isClosed {
+		t.Error("Close() did not close the wrapped store")
+	}
+}
+
+func TestBatchWriter_FlushesByInterval(t *testing.T) {
+	store := newFakeBatchStore()
+	w := NewBatchWriter(store, 100, 10*time.Millisecond)
+	defer w.Close()
+
+	if err := w.PutObject(context.Background(), "b", "k", &ObjectMetadata{Key: "k"}); err != nil {
+		t.Fatalf("PutObject() error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(store.committed()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("queued write was not flushed by the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBatchWriter_DeleteMarkerHidesObject(t *testing.T) {
+	w := NewBatchWriter(newFakeBatchStore(), 100, time.Hour)
+	defer w.Close()
+	ctx := context.Background()
+
+	_ = w.PutObject(ctx, "b", "k", &ObjectMetadata{Key: "k", VersionID: "v1"})
+	_ = w.PutObject(ctx, "b", "k", &ObjectMetadata{Key: "k", VersionID: "dm", IsDeleteMarker: true})
+
+	if _, err := w.GetObject(ctx, "b", "k", ""); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("GetObject() behind a delete marker error = %v, expected %v", err, ErrObjectNotFound)
+	}
+	if meta, err := w.GetObject(ctx, "b", "k", "v1"); err != nil || meta.VersionID != "v1" {
+		t.Errorf("GetObject(v1) = %+v, %v", meta, err)
+	}
+}
+
+func TestBatchWriter_FailedFlush(t *testing.T) {
+	store := newFakeBatchStore()
+	store.putErr = errors.New("disk full")
+	w := NewBatchWriter(store, 2, time.Hour)
+	ctx := context.Background()
+
+	if err := w.PutObject(ctx, "b", "k1", &ObjectMetadata{Key: "k1"}); err != nil {
+		t.Fatalf("queued PutObject() error: %v", err)
+	}
+	if err := w.PutObject(ctx, "b", "k2", &ObjectMetadata{Key: "k2"}); err == nil {
+		t.Fatal("PutObject() filling the batch should return the flush error")
+	}
+
+	// The failed write is dropped, the earlier one is retried
+	store.putErr = nil
+	if err := w.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if _, err := store.GetObject(ctx, "b", "k1", ""); err != nil {
+		t.Errorf("k1 was not committed: %v", err)
+	}
+	if _, err := store.GetObject(ctx, "b", "k2", ""); err == nil {
+		t.Error("k2 was committed after its PutObject failed")
+	}
+	w.Close()
+}
@@ -1,10 +1,12 @@
 package bbolt
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/openendpoint/openendpoint/internal/metadata"
@@ -71,6 +73,10 @@ func New(rootDir string) (*BBoltStore, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte("tags")); err != nil {
 			return err
 		}
+		// Object tags bucket
+		if _, err := tx.CreateBucketIfNotExists([]byte("objecttags")); err != nil {
+			return err
+		}
 		// ObjectLock bucket
 		if _, err := tx.CreateBucketIfNotExists([]byte("objectlock")); err != nil {
 			return err
@@ -79,6 +85,13 @@ func New(rootDir string) (*BBoltStore, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte("publicaccessblock")); err != nil {
 			return err
 		}
+		// ACL buckets
+		if _, err := tx.CreateBucketIfNotExists([]byte("acl")); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte("objectacl")); err != nil {
+			return err
+		}
 		// Accelerate bucket
 		if _, err := tx.CreateBucketIfNotExists([]byte("accelerate")); err != nil {
 			return err
@@ -115,18 +128,39 @@ func New(rootDir string) (*BBoltStore, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte("legalhold")); err != nil {
 			return err
 		}
+		// Key normalization bucket
+		if _, err := tx.CreateBucketIfNotExists([]byte("keynormalization")); err != nil {
+			return err
+		}
+		// Bucket mode bucket
+		if _, err := tx.CreateBucketIfNotExists([]byte("bucketmode")); err != nil {
+			return err
+		}
+		// Usage bucket
+		if _, err := tx.CreateBucketIfNotExists([]byte("usage")); err != nil {
+			return err
+		}
+		// IAM bucket
+		if _, err := tx.CreateBucketIfNotExists([]byte("iam")); err != nil {
+			return err
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create buckets: %w", err)
 	}
 
+	if err := migratePartKeys(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate part keys: %w", err)
+	}
+
 	return &BBoltStore{db: db}, nil
 }
 
 // CreateBucket creates a new bucket
 func (b *BBoltStore) CreateBucket(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		buckets := tx.Bucket([]byte("buckets"))
 		meta := &metadata.BucketMetadata{
 			Name:         bucket,
@@ -142,7 +176,7 @@ func (b *BBoltStore) CreateBucket(ctx context.Context, bucket string) error {
 
 // DeleteBucket deletes a bucket
 func (b *BBoltStore) DeleteBucket(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		buckets := tx.Bucket([]byte("buckets"))
 		return buckets.Delete([]byte(bucket))
 	})
@@ -177,7 +211,7 @@ func (b *BBoltStore) ListBuckets(ctx context.Context) ([]string, error) {
 
 // PutObject stores object metadata
 func (b *BBoltStore) PutObject(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		objects := tx.Bucket([]byte("objects"))
 		objKey := bucket + "/" + key
 		data, err := encode(meta)
@@ -196,7 +230,7 @@ func (b *BBoltStore) GetObject(ctx context.Context, bucket, key string, versionI
 		objKey := bucket + "/" + key
 		data := objects.Get([]byte(objKey))
 		if data == nil {
-			return fmt.Errorf("object not found: %s/%s", bucket, key)
+			return fmt.Errorf("object %w: %s/%s", metadata.ErrObjectNotFound, bucket, key)
 		}
 		return mustDecode(data, &meta)
 	})
@@ -205,19 +239,96 @@ func (b *BBoltStore) GetObject(ctx context.Context, bucket, key string, versionI
 
 // DeleteObject deletes object metadata
 func (b *BBoltStore) DeleteObject(ctx context.Context, bucket, key string, versionID string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		objects := tx.Bucket([]byte("objects"))
 		objKey := bucket + "/" + key
 		return objects.Delete([]byte(objKey))
 	})
 }
 
-// ListObjects lists objects with optional prefix
+// MoveObject moves object metadata, tags, ACL, retention and legal hold to a new
+// key within a single transaction
+func (b *BBoltStore) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		objects := tx.Bucket([]byte("objects"))
+		srcObjKey := srcBucket + "/" + srcKey
+		dstObjKey := dstBucket + "/" + dstKey
+		data := objects.Get([]byte(srcObjKey))
+		if data == nil {
+			return fmt.Errorf("object %w: %s/%s", metadata.ErrObjectNotFound, srcBucket, srcKey)
+		}
+		if objects.Get([]byte(dstObjKey)) != nil {
+			return fmt.Errorf("object %w: %s/%s", metadata.ErrObjectExists, dstBucket, dstKey)
+		}
+
+		var meta metadata.ObjectMetadata
+		if err := mustDecode(data, &meta); err != nil {
+			return err
+		}
+		meta.Bucket = dstBucket
+		meta.Key = dstKey
+
+		encoded, err := encode(&meta)
+		if err != nil {
+			return err
+		}
+		if err := objects.Delete([]byte(srcObjKey)); err != nil {
+			return err
+		}
+		if err := objects.Put([]byte(dstObjKey), encoded); err != nil {
+			return err
+		}
+
+		// Entries left at the destination by an earlier object there are
+		// dropped rather than inherited
+		for _, name := range []string{"objecttags", "objectacl", "retention", "legalhold"} {
+			bkt := tx.Bucket([]byte(name))
+			value := bkt.Get([]byte(srcObjKey))
+			if value == nil {
+				if err := bkt.Delete([]byte(dstObjKey)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := bkt.Put([]byte(dstObjKey), append([]byte(nil), value...)); err != nil {
+				return err
+			}
+			if err := bkt.Delete([]byte(srcObjKey)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateObjectVersion rewrites the stored object metadata in place. BBolt
+// keeps one version per key, so that is the one updated.
+func (b *BBoltStore) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
+	return b.update(func(tx *bolt.Tx) error {
+		objects := tx.Bucket([]byte("objects"))
+		objKey := bucket + "/" + key
+		if objects.Get([]byte(objKey)) == nil {
+			return fmt.Errorf("object %w: %s/%s", metadata.ErrObjectNotFound, bucket, key)
+		}
+		data, err := encode(meta)
+		if err != nil {
+			return err
+		}
+		return objects.Put([]byte(objKey), data)
+	})
+}
+
+// ListObjects lists objects with optional prefix, in key order starting
+// after opts.Marker
 func (b *BBoltStore) ListObjects(ctx context.Context, bucket, prefix string, opts metadata.ListOptions) ([]metadata.ObjectMetadata, error) {
 	var objects []metadata.ObjectMetadata
 	err := b.db.View(func(tx *bolt.Tx) error {
 		objectsBkt := tx.Bucket([]byte("objects"))
-		prefixKey := bucket + "/" + prefix
+		prefixKey := []byte(bucket + "/" + prefix)
+		from := prefixKey
+		if after := []byte(bucket + "/" + opts.Marker + "\x00"); opts.Marker != "" && bytes.Compare(after, from) > 0 {
+			from = after
+		}
 
 		maxKeys := opts.MaxKeys
 		if maxKeys == 0 {
@@ -225,9 +336,8 @@ func (b *BBoltStore) ListObjects(ctx context.Context, bucket, prefix string, opt
 		}
 
 		cursor := objectsBkt.Cursor()
-		for k, v := cursor.Seek([]byte(prefixKey)); k != nil && len(objects) < maxKeys; k, v = cursor.Next() {
-			key := string(k)
-			if len(key) < len(bucket)+1 || key[:len(bucket)+1] != bucket+"/" {
+		for k, v := cursor.Seek(from); k != nil && len(objects) < maxKeys; k, v = cursor.Next() {
+			if !bytes.HasPrefix(k, prefixKey) {
 				break
 			}
 
@@ -242,16 +352,95 @@ func (b *BBoltStore) ListObjects(ctx context.Context, bucket, prefix string, opt
 	return objects, err
 }
 
+// ListObjectsFunc calls fn for each object under prefix after marker in key
+// order, a page at a time so fn runs outside the read transaction
+func (b *BBoltStore) ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(metadata.ObjectMetadata) error) error {
+	start := []byte(bucket + "/" + prefix)
+	from := start
+	if after := []byte(bucket + "/" + marker + "\x00"); marker != "" && bytes.Compare(after, from) > 0 {
+		from = after
+	}
+	for from != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page := make([]metadata.ObjectMetadata, 0, metadata.ListPageSize)
+		next := from
+		from = nil
+		err := b.db.View(func(tx *bolt.Tx) error {
+			cursor := tx.Bucket([]byte("objects")).Cursor()
+			for k, v := cursor.Seek(next); k != nil && bytes.HasPrefix(k, start); k, v = cursor.Next() {
+				if len(page) == metadata.ListPageSize {
+					from = append([]byte(nil), k...)
+					return nil
+				}
+				var meta metadata.ObjectMetadata
+				if err := mustDecode(v, &meta); err != nil {
+					continue
+				}
+				page = append(page, meta)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, meta := range page {
+			if err := fn(meta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListObjectVersions lists the objects under prefix. This store keeps one
+// version of each object, so each is listed as its only version.
+func (b *BBoltStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]metadata.ObjectMetadata, error) {
+	var versions []metadata.ObjectMetadata
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefixKey := []byte(bucket + "/" + prefix)
+		cursor := tx.Bucket([]byte("objects")).Cursor()
+		for k, v := cursor.Seek(prefixKey); k != nil && bytes.HasPrefix(k, prefixKey); k, v = cursor.Next() {
+			var meta metadata.ObjectMetadata
+			if err := mustDecode(v, &meta); err != nil {
+				continue
+			}
+			versions = append(versions, meta)
+		}
+		return nil
+	})
+	return versions, err
+}
+
+// BucketIsEmpty reports whether no object is stored under the bucket's
+// prefix in the objects bucket
+func (b *BBoltStore) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	empty := true
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(bucket + "/")
+		k, _ := tx.Bucket([]byte("objects")).Cursor().Seek(prefix)
+		empty = k == nil || !bytes.HasPrefix(k, prefix)
+		return nil
+	})
+	return empty, err
+}
+
 // CreateMultipartUpload creates a new multipart upload
 func (b *BBoltStore) CreateMultipartUpload(ctx context.Context, bucket, key, uploadID string, meta *metadata.ObjectMetadata) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		multipart := tx.Bucket([]byte("multipart"))
 		multiMeta := &metadata.MultipartUploadMetadata{
-			UploadID:  uploadID,
-			Key:       key,
-			Bucket:    bucket,
-			Initiated: nowUnix(),
-			Metadata:  meta.Metadata,
+			UploadID:     uploadID,
+			Key:          key,
+			Bucket:       bucket,
+			Initiated:    nowUnix(),
+			Metadata:     meta.Metadata,
+			StorageClass: meta.StorageClass,
+			SSEAlgorithm: meta.SSEAlgorithm,
+			Checksum:     meta.Checksum,
 		}
 		multiKey := bucket + "/" + key + "/" + uploadID
 		return multipart.Put([]byte(multiKey), mustEncode(multiMeta))
@@ -260,16 +449,15 @@ func (b *BBoltStore) CreateMultipartUpload(ctx context.Context, bucket, key, upl
 
 // PutPart stores part metadata
 func (b *BBoltStore) PutPart(ctx context.Context, bucket, key, uploadID string, partNumber int, partMeta *metadata.PartMetadata) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		parts := tx.Bucket([]byte("parts"))
-		partKey := fmt.Sprintf("%s/%s/%s/%d", bucket, key, uploadID, partNumber)
-		return parts.Put([]byte(partKey), mustEncode(partMeta))
+		return parts.Put(partKey(bucket, key, uploadID, partNumber), mustEncode(partMeta))
 	})
 }
 
 // CompleteMultipartUpload completes a multipart upload
 func (b *BBoltStore) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []metadata.PartInfo) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		multipart := tx.Bucket([]byte("multipart"))
 		partsBkt := tx.Bucket([]byte("parts"))
 
@@ -278,10 +466,19 @@ func (b *BBoltStore) CompleteMultipartUpload(ctx context.Context, bucket, key, u
 			return err
 		}
 
-		// Delete parts
-		for i := 1; i <= len(parts); i++ {
-			partKey := fmt.Sprintf("%s/%s/%s/%d", bucket, key, uploadID, i)
-			partsBkt.Delete([]byte(partKey))
+		// Delete all parts, including any the client left out of the
+		// completion. Keys are collected first because deleting while
+		// iterating a cursor skips entries.
+		prefix := partPrefix(bucket, key, uploadID)
+		var partKeys [][]byte
+		cursor := partsBkt.Cursor()
+		for k, _ := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = cursor.Next() {
+			partKeys = append(partKeys, append([]byte(nil), k...))
+		}
+		for _, k := range partKeys {
+			if err := partsBkt.Delete(k); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -289,7 +486,7 @@ func (b *BBoltStore) CompleteMultipartUpload(ctx context.Context, bucket, key, u
 
 // AbortMultipartUpload aborts a multipart upload
 func (b *BBoltStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		multipart := tx.Bucket([]byte("multipart"))
 		multiKey := bucket + "/" + key + "/" + uploadID
 		return multipart.Delete([]byte(multiKey))
@@ -301,7 +498,7 @@ func (b *BBoltStore) ListParts(ctx context.Context, bucket, key, uploadID string
 	var parts []metadata.PartMetadata
 	err := b.db.View(func(tx *bolt.Tx) error {
 		partsBkt := tx.Bucket([]byte("parts"))
-		prefix := fmt.Sprintf("%s/%s/%s/", bucket, key, uploadID)
+		prefix := partPrefix(bucket, key, uploadID)
 
 		cursor := partsBkt.Cursor()
 		for k, v := cursor.Seek([]byte(prefix)); k != nil; k, v = cursor.Next() {
@@ -350,6 +547,66 @@ func (b *BBoltStore) ListMultipartUploads(ctx context.Context, bucket, prefix st
 	return uploads, err
 }
 
+// partPrefix returns the key prefix shared by all parts of an upload
+func partPrefix(bucket, key, uploadID string) string {
+	return bucket + "/" + key + "/" + uploadID + "/"
+}
+
+// partKey returns the key of a part. The part number is zero-padded so the
+// cursor visits parts in numeric order.
+func partKey(bucket, key, uploadID string, partNumber int) []byte {
+	return []byte(fmt.Sprintf("%s%05d", partPrefix(bucket, key, uploadID), partNumber))
+}
+
+// paddedPartKey returns the zero-padded form of a part key written before
+// part numbers were padded, and false for keys that are already padded
+func paddedPartKey(k []byte) ([]byte, bool) {
+	i := bytes.LastIndexByte(k, '/')
+	if i < 0 || len(k)-i-1 >= 5 {
+		return nil, false
+	}
+	n, err := strconv.Atoi(string(k[i+1:]))
+	if err != nil {
+		return nil, false
+	}
+	return []byte(fmt.Sprintf("%s%05d", k[:i+1], n)), true
+}
+
+// migratePartKeys rewrites unpadded part keys of uploads begun before part
+// numbers were zero-padded, so their parts list in order and a re-uploaded
+// part replaces the old entry instead of sitting beside it
+func migratePartKeys(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		parts := tx.Bucket([]byte("parts"))
+
+		// Keys are collected first because writing while iterating a
+		// cursor skips entries
+		var legacy [][]byte
+		err := parts.ForEach(func(k, v []byte) error {
+			if _, ok := paddedPartKey(k); ok {
+				legacy = append(legacy, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range legacy {
+			padded, _ := paddedPartKey(k)
+			if parts.Get(padded) == nil {
+				if err := parts.Put(padded, append([]byte(nil), parts.Get(k)...)); err != nil {
+					return err
+				}
+			}
+			if err := parts.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // containsPrefix checks if string contains the given prefix
 func containsPrefix(s, prefix string) bool {
 	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
@@ -357,7 +614,7 @@ func containsPrefix(s, prefix string) bool {
 
 // PutLifecycleRule puts a lifecycle rule
 func (b *BBoltStore) PutLifecycleRule(ctx context.Context, bucket string, rule *metadata.LifecycleRule) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		lifecycle := tx.Bucket([]byte("lifecycle"))
 		return lifecycle.Put([]byte(bucket+"_"+rule.ID), mustEncode(rule))
 	})
@@ -390,7 +647,7 @@ func (b *BBoltStore) GetLifecycleRules(ctx context.Context, bucket string) ([]me
 
 // DeleteLifecycleRule deletes a lifecycle rule from a bucket
 func (b *BBoltStore) DeleteLifecycleRule(ctx context.Context, bucket, ruleID string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		lifecycle := tx.Bucket([]byte("lifecycle"))
 		return lifecycle.Delete([]byte(bucket + "_" + ruleID))
 	})
@@ -398,7 +655,7 @@ func (b *BBoltStore) DeleteLifecycleRule(ctx context.Context, bucket, ruleID str
 
 // PutBucketVersioning puts bucket versioning configuration
 func (b *BBoltStore) PutBucketVersioning(ctx context.Context, bucket string, versioning *metadata.BucketVersioning) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		versioningBkt := tx.Bucket([]byte("versioning"))
 		return versioningBkt.Put([]byte(bucket), mustEncode(versioning))
 	})
@@ -423,7 +680,7 @@ func (b *BBoltStore) PutBucketPolicy(ctx context.Context, bucket string, policy
 	if policy == nil {
 		return fmt.Errorf("policy cannot be nil")
 	}
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		policyBkt := tx.Bucket([]byte("policy"))
 		return policyBkt.Put([]byte(bucket), []byte(*policy))
 	})
@@ -447,7 +704,7 @@ func (b *BBoltStore) GetBucketPolicy(ctx context.Context, bucket string) (*strin
 
 // DeleteBucketPolicy deletes bucket policy
 func (b *BBoltStore) DeleteBucketPolicy(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		policyBkt := tx.Bucket([]byte("policy"))
 		return policyBkt.Delete([]byte(bucket))
 	})
@@ -455,7 +712,7 @@ func (b *BBoltStore) DeleteBucketPolicy(ctx context.Context, bucket string) erro
 
 // PutBucketCors stores CORS configuration
 func (b *BBoltStore) PutBucketCors(ctx context.Context, bucket string, cors *metadata.CORSConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		corsBkt := tx.Bucket([]byte("cors"))
 		return corsBkt.Put([]byte(bucket), mustEncode(cors))
 	})
@@ -477,7 +734,7 @@ func (b *BBoltStore) GetBucketCors(ctx context.Context, bucket string) (*metadat
 
 // DeleteBucketCors deletes CORS configuration
 func (b *BBoltStore) DeleteBucketCors(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		corsBkt := tx.Bucket([]byte("cors"))
 		return corsBkt.Delete([]byte(bucket))
 	})
@@ -485,7 +742,7 @@ func (b *BBoltStore) DeleteBucketCors(ctx context.Context, bucket string) error
 
 // PutBucketEncryption stores encryption configuration
 func (b *BBoltStore) PutBucketEncryption(ctx context.Context, bucket string, encryption *metadata.BucketEncryption) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		encryptionBkt := tx.Bucket([]byte("encryption"))
 		return encryptionBkt.Put([]byte(bucket), mustEncode(encryption))
 	})
@@ -507,7 +764,7 @@ func (b *BBoltStore) GetBucketEncryption(ctx context.Context, bucket string) (*m
 
 // DeleteBucketEncryption deletes encryption configuration
 func (b *BBoltStore) DeleteBucketEncryption(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		encryptionBkt := tx.Bucket([]byte("encryption"))
 		return encryptionBkt.Delete([]byte(bucket))
 	})
@@ -515,7 +772,7 @@ func (b *BBoltStore) DeleteBucketEncryption(ctx context.Context, bucket string)
 
 // PutBucketTags stores bucket tags
 func (b *BBoltStore) PutBucketTags(ctx context.Context, bucket string, tags map[string]string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		tagsBkt := tx.Bucket([]byte("tags"))
 		return tagsBkt.Put([]byte(bucket), mustEncode(tags))
 	})
@@ -537,15 +794,54 @@ func (b *BBoltStore) GetBucketTags(ctx context.Context, bucket string) (map[stri
 
 // DeleteBucketTags deletes bucket tags
 func (b *BBoltStore) DeleteBucketTags(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		tagsBkt := tx.Bucket([]byte("tags"))
 		return tagsBkt.Delete([]byte(bucket))
 	})
 }
 
+// objectEntryKey is the key of an object's tags, retention or legal hold,
+// qualified with a version unless versionID is empty
+func objectEntryKey(bucket, key, versionID string) []byte {
+	if versionID == "" {
+		return []byte(bucket + "/" + key)
+	}
+	return []byte(bucket + "/" + key + "\x00" + versionID)
+}
+
+// PutObjectTags replaces the tag set of an object
+func (b *BBoltStore) PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		tagsBkt := tx.Bucket([]byte("objecttags"))
+		return tagsBkt.Put(objectEntryKey(bucket, key, versionID), mustEncode(tags))
+	})
+}
+
+// GetObjectTags gets the tag set of an object
+func (b *BBoltStore) GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	var tags map[string]string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		tagsBkt := tx.Bucket([]byte("objecttags"))
+		data := tagsBkt.Get(objectEntryKey(bucket, key, versionID))
+		if data == nil {
+			return nil
+		}
+		return mustDecode(data, &tags)
+	})
+	return tags, err
+}
+
+// DeleteObjectTags deletes the tag set of an object
+func (b *BBoltStore) DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		tagsBkt := tx.Bucket([]byte("objecttags"))
+		return tagsBkt.Delete(objectEntryKey(bucket, key, versionID))
+	})
+}
+
 // PutObjectLock stores object lock configuration
 func (b *BBoltStore) PutObjectLock(ctx context.Context, bucket string, config *metadata.ObjectLockConfig) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		objectLockBkt := tx.Bucket([]byte("objectlock"))
 		return objectLockBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -567,26 +863,26 @@ func (b *BBoltStore) GetObjectLock(ctx context.Context, bucket string) (*metadat
 
 // DeleteObjectLock deletes object lock configuration
 func (b *BBoltStore) DeleteObjectLock(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		objectLockBkt := tx.Bucket([]byte("objectlock"))
 		return objectLockBkt.Delete([]byte(bucket))
 	})
 }
 
 // PutObjectRetention stores object retention
-func (b *BBoltStore) PutObjectRetention(ctx context.Context, bucket, key string, retention *metadata.ObjectRetention) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+func (b *BBoltStore) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error {
+	return b.update(func(tx *bolt.Tx) error {
 		retentionBkt := tx.Bucket([]byte("retention"))
-		return retentionBkt.Put([]byte(bucket+"/"+key), mustEncode(retention))
+		return retentionBkt.Put(objectEntryKey(bucket, key, versionID), mustEncode(retention))
 	})
 }
 
 // GetObjectRetention retrieves object retention
-func (b *BBoltStore) GetObjectRetention(ctx context.Context, bucket, key string) (*metadata.ObjectRetention, error) {
+func (b *BBoltStore) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
 	var retention *metadata.ObjectRetention
 	err := b.db.View(func(tx *bolt.Tx) error {
 		retentionBkt := tx.Bucket([]byte("retention"))
-		data := retentionBkt.Get([]byte(bucket + "/" + key))
+		data := retentionBkt.Get(objectEntryKey(bucket, key, versionID))
 		if data == nil {
 			retention = nil
 			return nil
@@ -597,19 +893,19 @@ func (b *BBoltStore) GetObjectRetention(ctx context.Context, bucket, key string)
 }
 
 // PutObjectLegalHold stores object legal hold
-func (b *BBoltStore) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *metadata.ObjectLegalHold) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+func (b *BBoltStore) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
+	return b.update(func(tx *bolt.Tx) error {
 		legalHoldBkt := tx.Bucket([]byte("legalhold"))
-		return legalHoldBkt.Put([]byte(bucket+"/"+key), mustEncode(legalHold))
+		return legalHoldBkt.Put(objectEntryKey(bucket, key, versionID), mustEncode(legalHold))
 	})
 }
 
 // GetObjectLegalHold retrieves object legal hold
-func (b *BBoltStore) GetObjectLegalHold(ctx context.Context, bucket, key string) (*metadata.ObjectLegalHold, error) {
+func (b *BBoltStore) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
 	var legalHold *metadata.ObjectLegalHold
 	err := b.db.View(func(tx *bolt.Tx) error {
 		legalHoldBkt := tx.Bucket([]byte("legalhold"))
-		data := legalHoldBkt.Get([]byte(bucket + "/" + key))
+		data := legalHoldBkt.Get(objectEntryKey(bucket, key, versionID))
 		if data == nil {
 			legalHold = nil
 			return nil
@@ -621,7 +917,7 @@ func (b *BBoltStore) GetObjectLegalHold(ctx context.Context, bucket, key string)
 
 // PutPublicAccessBlock stores public access block configuration
 func (b *BBoltStore) PutPublicAccessBlock(ctx context.Context, bucket string, config *metadata.PublicAccessBlockConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		publicAccessBlockBkt := tx.Bucket([]byte("publicaccessblock"))
 		return publicAccessBlockBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -643,15 +939,143 @@ func (b *BBoltStore) GetPublicAccessBlock(ctx context.Context, bucket string) (*
 
 // DeletePublicAccessBlock deletes public access block configuration
 func (b *BBoltStore) DeletePublicAccessBlock(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		publicAccessBlockBkt := tx.Bucket([]byte("publicaccessblock"))
 		return publicAccessBlockBkt.Delete([]byte(bucket))
 	})
 }
 
+// PutBucketACL stores the ACL of a bucket
+func (b *BBoltStore) PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error {
+	return b.update(func(tx *bolt.Tx) error {
+		aclBkt := tx.Bucket([]byte("acl"))
+		return aclBkt.Put([]byte(bucket), mustEncode(acl))
+	})
+}
+
+// GetBucketACL gets the ACL of a bucket, nil if none is stored
+func (b *BBoltStore) GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error) {
+	var acl *metadata.AccessControlList
+	err := b.db.View(func(tx *bolt.Tx) error {
+		aclBkt := tx.Bucket([]byte("acl"))
+		data := aclBkt.Get([]byte(bucket))
+		if data == nil {
+			return nil
+		}
+		return mustDecode(data, &acl)
+	})
+	return acl, err
+}
+
+// DeleteBucketACL deletes the ACL of a bucket
+func (b *BBoltStore) DeleteBucketACL(ctx context.Context, bucket string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		aclBkt := tx.Bucket([]byte("acl"))
+		return aclBkt.Delete([]byte(bucket))
+	})
+}
+
+// PutObjectACL stores the ACL of an object
+func (b *BBoltStore) PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	return b.update(func(tx *bolt.Tx) error {
+		aclBkt := tx.Bucket([]byte("objectacl"))
+		return aclBkt.Put([]byte(bucket+"/"+key), mustEncode(acl))
+	})
+}
+
+// GetObjectACL gets the ACL of an object, nil if none is stored
+func (b *BBoltStore) GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error) {
+	var acl *metadata.AccessControlList
+	err := b.db.View(func(tx *bolt.Tx) error {
+		aclBkt := tx.Bucket([]byte("objectacl"))
+		data := aclBkt.Get([]byte(bucket + "/" + key))
+		if data == nil {
+			return nil
+		}
+		return mustDecode(data, &acl)
+	})
+	return acl, err
+}
+
+// DeleteObjectACL deletes the ACL of an object
+func (b *BBoltStore) DeleteObjectACL(ctx context.Context, bucket, key string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		aclBkt := tx.Bucket([]byte("objectacl"))
+		return aclBkt.Delete([]byte(bucket + "/" + key))
+	})
+}
+
+// PutBucketKeyNormalization stores bucket key normalization configuration
+func (b *BBoltStore) PutBucketKeyNormalization(ctx context.Context, bucket string, config *metadata.KeyNormalizationConfig) error {
+	return b.update(func(tx *bolt.Tx) error {
+		normBkt := tx.Bucket([]byte("keynormalization"))
+		return normBkt.Put([]byte(bucket), mustEncode(config))
+	})
+}
+
+// GetBucketKeyNormalization gets bucket key normalization configuration
+func (b *BBoltStore) GetBucketKeyNormalization(ctx context.Context, bucket string) (*metadata.KeyNormalizationConfig, error) {
+	var config metadata.KeyNormalizationConfig
+	err := b.db.View(func(tx *bolt.Tx) error {
+		normBkt := tx.Bucket([]byte("keynormalization"))
+		data := normBkt.Get([]byte(bucket))
+		if data == nil {
+			return nil
+		}
+		return mustDecode(data, &config)
+	})
+	return &config, err
+}
+
+// PutBucketMode stores the access mode of a bucket
+func (b *BBoltStore) PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error {
+	return b.update(func(tx *bolt.Tx) error {
+		modeBkt := tx.Bucket([]byte("bucketmode"))
+		return modeBkt.Put([]byte(bucket), mustEncode(mode))
+	})
+}
+
+// GetBucketMode gets the access mode of a bucket, or nil if none was set
+func (b *BBoltStore) GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error) {
+	var mode *metadata.BucketMode
+	err := b.db.View(func(tx *bolt.Tx) error {
+		modeBkt := tx.Bucket([]byte("bucketmode"))
+		data := modeBkt.Get([]byte(bucket))
+		if data == nil {
+			return nil
+		}
+		mode = &metadata.BucketMode{}
+		return mustDecode(data, mode)
+	})
+	return mode, err
+}
+
+// PutBucketUsage stores the usage totals of a bucket
+func (b *BBoltStore) PutBucketUsage(ctx context.Context, bucket string, usage *metadata.BucketUsage) error {
+	return b.update(func(tx *bolt.Tx) error {
+		usageBkt := tx.Bucket([]byte("usage"))
+		return usageBkt.Put([]byte(bucket), mustEncode(usage))
+	})
+}
+
+// GetBucketUsage gets the usage totals of a bucket, or nil if none are stored
+func (b *BBoltStore) GetBucketUsage(ctx context.Context, bucket string) (*metadata.BucketUsage, error) {
+	var usage *metadata.BucketUsage
+	err := b.db.View(func(tx *bolt.Tx) error {
+		usageBkt := tx.Bucket([]byte("usage"))
+		data := usageBkt.Get([]byte(bucket))
+		if data == nil {
+			return nil
+		}
+		usage = &metadata.BucketUsage{}
+		return mustDecode(data, usage)
+	})
+	return usage, err
+}
+
 // PutBucketAccelerate stores bucket accelerate configuration
 func (b *BBoltStore) PutBucketAccelerate(ctx context.Context, bucket string, config *metadata.BucketAccelerateConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		accelerateBkt := tx.Bucket([]byte("accelerate"))
 		return accelerateBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -673,7 +1097,7 @@ func (b *BBoltStore) GetBucketAccelerate(ctx context.Context, bucket string) (*m
 
 // DeleteBucketAccelerate deletes bucket accelerate configuration
 func (b *BBoltStore) DeleteBucketAccelerate(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		accelerateBkt := tx.Bucket([]byte("accelerate"))
 		return accelerateBkt.Delete([]byte(bucket))
 	})
@@ -681,7 +1105,7 @@ func (b *BBoltStore) DeleteBucketAccelerate(ctx context.Context, bucket string)
 
 // PutReplicationConfig stores replication configuration
 func (b *BBoltStore) PutReplicationConfig(ctx context.Context, bucket string, config *metadata.ReplicationConfig) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		replicationBkt := tx.Bucket([]byte("replication"))
 		return replicationBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -703,7 +1127,7 @@ func (b *BBoltStore) GetReplicationConfig(ctx context.Context, bucket string) (*
 
 // DeleteReplicationConfig deletes replication configuration
 func (b *BBoltStore) DeleteReplicationConfig(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		replicationBkt := tx.Bucket([]byte("replication"))
 		return replicationBkt.Delete([]byte(bucket))
 	})
@@ -711,7 +1135,7 @@ func (b *BBoltStore) DeleteReplicationConfig(ctx context.Context, bucket string)
 
 // PutBucketNotification stores bucket notification configuration
 func (b *BBoltStore) PutBucketNotification(ctx context.Context, bucket string, config *metadata.NotificationConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		notificationBkt := tx.Bucket([]byte("notification"))
 		return notificationBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -733,7 +1157,7 @@ func (b *BBoltStore) GetBucketNotification(ctx context.Context, bucket string) (
 
 // DeleteBucketNotification deletes bucket notification configuration
 func (b *BBoltStore) DeleteBucketNotification(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		notificationBkt := tx.Bucket([]byte("notification"))
 		return notificationBkt.Delete([]byte(bucket))
 	})
@@ -741,7 +1165,7 @@ func (b *BBoltStore) DeleteBucketNotification(ctx context.Context, bucket string
 
 // PutBucketLogging stores bucket logging configuration
 func (b *BBoltStore) PutBucketLogging(ctx context.Context, bucket string, config *metadata.LoggingConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		loggingBkt := tx.Bucket([]byte("logging"))
 		return loggingBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -763,7 +1187,7 @@ func (b *BBoltStore) GetBucketLogging(ctx context.Context, bucket string) (*meta
 
 // DeleteBucketLogging deletes bucket logging configuration
 func (b *BBoltStore) DeleteBucketLogging(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		loggingBkt := tx.Bucket([]byte("logging"))
 		return loggingBkt.Delete([]byte(bucket))
 	})
@@ -771,7 +1195,7 @@ func (b *BBoltStore) DeleteBucketLogging(ctx context.Context, bucket string) err
 
 // PutBucketLocation stores bucket location
 func (b *BBoltStore) PutBucketLocation(ctx context.Context, bucket string, location string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		locationBkt := tx.Bucket([]byte("location"))
 		return locationBkt.Put([]byte(bucket), []byte(location))
 	})
@@ -795,7 +1219,7 @@ func (b *BBoltStore) GetBucketLocation(ctx context.Context, bucket string) (stri
 
 // PutBucketOwnershipControls stores bucket ownership controls
 func (b *BBoltStore) PutBucketOwnershipControls(ctx context.Context, bucket string, config *metadata.OwnershipControls) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		ownershipBkt := tx.Bucket([]byte("ownership"))
 		return ownershipBkt.Put([]byte(bucket), mustEncode(config))
 	})
@@ -818,7 +1242,7 @@ func (b *BBoltStore) GetBucketOwnershipControls(ctx context.Context, bucket stri
 
 // DeleteBucketOwnershipControls deletes bucket ownership controls
 func (b *BBoltStore) DeleteBucketOwnershipControls(ctx context.Context, bucket string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		ownershipBkt := tx.Bucket([]byte("ownership"))
 		return ownershipBkt.Delete([]byte(bucket))
 	})
@@ -826,7 +1250,7 @@ func (b *BBoltStore) DeleteBucketOwnershipControls(ctx context.Context, bucket s
 
 // PutBucketMetrics stores bucket metrics configuration
 func (b *BBoltStore) PutBucketMetrics(ctx context.Context, bucket string, id string, config *metadata.MetricsConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		metricsBkt := tx.Bucket([]byte("metrics"))
 		key := bucket + ":" + id
 		return metricsBkt.Put([]byte(key), mustEncode(config))
@@ -851,7 +1275,7 @@ func (b *BBoltStore) GetBucketMetrics(ctx context.Context, bucket string, id str
 
 // DeleteBucketMetrics deletes bucket metrics configuration
 func (b *BBoltStore) DeleteBucketMetrics(ctx context.Context, bucket string, id string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		metricsBkt := tx.Bucket([]byte("metrics"))
 		key := bucket + ":" + id
 		return metricsBkt.Delete([]byte(key))
@@ -880,7 +1304,7 @@ func (b *BBoltStore) ListBucketMetrics(ctx context.Context, bucket string) ([]me
 
 // PutBucketAnalytics stores bucket analytics configuration
 func (b *BBoltStore) PutBucketAnalytics(ctx context.Context, bucket string, id string, config *metadata.AnalyticsConfiguration) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		analyticsBkt := tx.Bucket([]byte("analytics"))
 		key := bucket + ":" + id
 		return analyticsBkt.Put([]byte(key), mustEncode(config))
@@ -925,13 +1349,63 @@ func (b *BBoltStore) ListBucketAnalytics(ctx context.Context, bucket string) ([]
 
 // DeleteBucketAnalytics deletes bucket analytics configuration
 func (b *BBoltStore) DeleteBucketAnalytics(ctx context.Context, bucket string, id string) error {
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.update(func(tx *bolt.Tx) error {
 		analyticsBkt := tx.Bucket([]byte("analytics"))
 		key := bucket + ":" + id
 		return analyticsBkt.Delete([]byte(key))
 	})
 }
 
+// PutIAMEntity stores an IAM entity
+func (b *BBoltStore) PutIAMEntity(ctx context.Context, kind, id string, data []byte) error {
+	return b.update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("iam")).Put([]byte(kind+"/"+id), data)
+	})
+}
+
+// DeleteIAMEntity deletes an IAM entity
+func (b *BBoltStore) DeleteIAMEntity(ctx context.Context, kind, id string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("iam")).Delete([]byte(kind + "/" + id))
+	})
+}
+
+// ListIAMEntities lists the IAM entities of a kind
+func (b *BBoltStore) ListIAMEntities(ctx context.Context, kind string) ([][]byte, error) {
+	var entities [][]byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(kind + "/")
+		cursor := tx.Bucket([]byte("iam")).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			entities = append(entities, append([]byte(nil), v...))
+		}
+		return nil
+	})
+	return entities, err
+}
+
+// Compact does nothing: bbolt reuses the pages of deleted entries itself,
+// and shrinking its file needs the database copied while it is closed
+func (b *BBoltStore) Compact(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Stats returns the size of the database file and of the pages in use
+func (b *BBoltStore) Stats() (*metadata.Stats, error) {
+	var size int64
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	free := int64(b.db.Stats().FreePageN) * int64(b.db.Info().PageSize)
+	return &metadata.Stats{
+		DiskBytes: size,
+		LiveBytes: size - free,
+	}, nil
+}
+
 // Close closes the store
 func (b *BBoltStore) Close() error {
 	return b.db.Close()
@@ -960,6 +1434,12 @@ func mustEncode(v interface{}) []byte {
 	return data
 }
 
+// update runs fn in a read-write transaction, marking failures that mean the
+// database cannot accept writes at all
+func (b *BBoltStore) update(fn func(tx *bolt.Tx) error) error {
+	return metadata.WrapUnwritable(b.db.Update(fn), bolt.ErrDatabaseReadOnly)
+}
+
 // mustDecode panics on decode error
 func mustDecode(data []byte, v interface{}) error {
 	return json.Unmarshal(data, v)
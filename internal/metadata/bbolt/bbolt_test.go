@@ -2,10 +2,13 @@ package bbolt
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/metadatatest"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -648,6 +651,52 @@ func TestBucketTags(t *testing.T) {
 	}
 }
 
+func TestObjectTags(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	retrieved, err := store.GetObjectTags(ctx, "test-bucket", "a.txt", "")
+	if err != nil || retrieved != nil {
+		t.Fatalf("GetObjectTags() before put = %v, %v, want nil", retrieved, err)
+	}
+
+	if err := store.PutObjectTags(ctx, "test-bucket", "a.txt", "", map[string]string{"env": "test"}); err != nil {
+		t.Fatalf("PutObjectTags() error: %v", err)
+	}
+	if err := store.PutObjectTags(ctx, "test-bucket", "b.txt", "", map[string]string{"env": "prod"}); err != nil {
+		t.Fatalf("PutObjectTags() error: %v", err)
+	}
+
+	retrieved, err = store.GetObjectTags(ctx, "test-bucket", "a.txt", "")
+	if err != nil {
+		t.Fatalf("GetObjectTags() error: %v", err)
+	}
+	if len(retrieved) != 1 || retrieved["env"] != "test" {
+		t.Errorf("GetObjectTags() = %v, want env=test", retrieved)
+	}
+
+	if err := store.DeleteObjectTags(ctx, "test-bucket", "a.txt", ""); err != nil {
+		t.Fatalf("DeleteObjectTags() error: %v", err)
+	}
+	if retrieved, _ := store.GetObjectTags(ctx, "test-bucket", "a.txt", ""); retrieved != nil {
+		t.Errorf("GetObjectTags() after delete = %v, want nil", retrieved)
+	}
+	if retrieved, _ := store.GetObjectTags(ctx, "test-bucket", "b.txt", ""); retrieved["env"] != "prod" {
+		t.Errorf("GetObjectTags(b.txt) = %v, want env=prod", retrieved)
+	}
+}
+
 func TestObjectLock(t *testing.T) {
 	dir, err := os.MkdirTemp("", "bbolt-test-*")
 	if err != nil {
@@ -701,12 +750,12 @@ func TestObjectRetention(t *testing.T) {
 
 	retention := &metadata.ObjectRetention{Mode: "GOVERNANCE"}
 
-	err = store.PutObjectRetention(ctx, "test-bucket", "test-key", retention)
+	err = store.PutObjectRetention(ctx, "test-bucket", "test-key", "", retention)
 	if err != nil {
 		t.Fatalf("PutObjectRetention() error: %v", err)
 	}
 
-	retrieved, err := store.GetObjectRetention(ctx, "test-bucket", "test-key")
+	retrieved, err := store.GetObjectRetention(ctx, "test-bucket", "test-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectRetention() error: %v", err)
 	}
@@ -732,18 +781,32 @@ func TestObjectLegalHold(t *testing.T) {
 
 	legalHold := &metadata.ObjectLegalHold{Status: "ON"}
 
-	err = store.PutObjectLegalHold(ctx, "test-bucket", "test-key", legalHold)
+	err = store.PutObjectLegalHold(ctx, "test-bucket", "test-key", "", legalHold)
 	if err != nil {
 		t.Fatalf("PutObjectLegalHold() error: %v", err)
 	}
 
-	retrieved, err := store.GetObjectLegalHold(ctx, "test-bucket", "test-key")
+	retrieved, err := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectLegalHold() error: %v", err)
 	}
 	if retrieved == nil || retrieved.Status != "ON" {
 		t.Error("Legal hold status mismatch")
 	}
+
+	// Each version keeps its own hold, apart from the object's
+	if err := store.PutObjectLegalHold(ctx, "test-bucket", "test-key", "v1", &metadata.ObjectLegalHold{Status: "OFF"}); err != nil {
+		t.Fatalf("PutObjectLegalHold(v1) error: %v", err)
+	}
+	if hold, _ := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "v1"); hold == nil || hold.Status != "OFF" {
+		t.Errorf("GetObjectLegalHold(v1) = %+v, want OFF", hold)
+	}
+	if hold, _ := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "v2"); hold != nil {
+		t.Errorf("GetObjectLegalHold(v2) = %+v, want nil", hold)
+	}
+	if hold, _ := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", ""); hold == nil || hold.Status != "ON" {
+		t.Errorf("GetObjectLegalHold() after versioned put = %+v, want ON", hold)
+	}
 }
 
 func TestPublicAccessBlock(t *testing.T) {
@@ -1253,7 +1316,7 @@ func TestGetObjectRetentionNotFound(t *testing.T) {
 	defer store.Close()
 
 	ctx := context.Background()
-	retention, err := store.GetObjectRetention(ctx, "nonexistent-bucket", "nonexistent-key")
+	retention, err := store.GetObjectRetention(ctx, "nonexistent-bucket", "nonexistent-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectRetention() error: %v", err)
 	}
@@ -1276,7 +1339,7 @@ func TestGetObjectLegalHoldNotFound(t *testing.T) {
 	defer store.Close()
 
 	ctx := context.Background()
-	legalHold, err := store.GetObjectLegalHold(ctx, "nonexistent-bucket", "nonexistent-key")
+	legalHold, err := store.GetObjectLegalHold(ctx, "nonexistent-bucket", "nonexistent-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectLegalHold() error: %v", err)
 	}
@@ -2663,3 +2726,255 @@ func TestCloseTwice(t *testing.T) {
 		t.Log("Second close returned nil (acceptable)")
 	}
 }
+
+func TestListPartsNumericOrder(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestListPartsNumericOrder(t, store)
+}
+
+func TestBucketMode(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestBucketMode(t, store)
+}
+
+func TestACL(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestACL(t, store)
+}
+
+func TestListObjectsFunc(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestListObjectsFunc(t, store)
+}
+
+func TestMigratePartKeys(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Write parts the way stores did before part numbers were padded
+	db, err := bolt.Open(filepath.Join(dir, "metadata.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		parts := tx.Bucket([]byte("parts"))
+		for _, n := range []int{10, 2, 1} {
+			key := fmt.Sprintf("test-bucket/test-key/legacy-upload/%d", n)
+			if err := parts.Put([]byte(key), mustEncode(&metadata.PartMetadata{PartNumber: n})); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestMigratedPartKeys(t, store)
+}
+
+func TestMoveObjectAssociations(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestMoveObjectAssociations(t, store)
+}
+
+func TestBucketUsage(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestBucketUsage(t, store)
+}
+
+func TestReadOnlyWritesAreUnwritable(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := store.db.Path()
+	store.Close()
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := &BBoltStore{db: db}
+	defer readOnly.Close()
+
+	if err := readOnly.CreateBucket(context.Background(), "test-bucket"); !metadata.IsUnwritable(err) {
+		t.Errorf("CreateBucket() on read-only database error = %v, want unwritable", err)
+	}
+}
+
+func TestBucketIsEmpty(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+	_ = store.PutObject(ctx, "test-bucket-2", "k", &metadata.ObjectMetadata{Key: "k"})
+
+	if empty, err := store.BucketIsEmpty(ctx, "test-bucket"); err != nil || !empty {
+		t.Errorf("BucketIsEmpty() = %v, %v, expected true", empty, err)
+	}
+
+	_ = store.PutObject(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k"})
+	if empty, err := store.BucketIsEmpty(ctx, "test-bucket"); err != nil || empty {
+		t.Errorf("BucketIsEmpty() = %v, %v, expected false", empty, err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.PutObject(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k"})
+	if err := store.Compact(ctx); err != nil {
+		t.Fatalf("Compact() error: %v", err)
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error: %v", err)
+	}
+	if stats.DiskBytes <= 0 || stats.LiveBytes <= 0 || stats.LiveBytes > stats.DiskBytes {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestIAMEntities(t *testing.T) {
+	dir, err := os.MkdirTemp("", "bbolt-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.PutIAMEntity(ctx, "user", "u1", []byte(`{"id":"u1"}`))
+	_ = store.PutIAMEntity(ctx, "user", "u2", []byte(`{"id":"u2"}`))
+	_ = store.PutIAMEntity(ctx, "users", "u3", []byte(`{"id":"u3"}`))
+	_ = store.PutIAMEntity(ctx, "group", "g1", []byte(`{"id":"g1"}`))
+
+	users, err := store.ListIAMEntities(ctx, "user")
+	if err != nil || len(users) != 2 {
+		t.Fatalf("ListIAMEntities(user) = %q, %v, expected 2 users", users, err)
+	}
+
+	if err := store.DeleteIAMEntity(ctx, "user", "u1"); err != nil {
+		t.Fatalf("DeleteIAMEntity() error: %v", err)
+	}
+	users, _ = store.ListIAMEntities(ctx, "user")
+	if len(users) != 1 || string(users[0]) != `{"id":"u2"}` {
+		t.Errorf("ListIAMEntities(user) after delete = %q", users)
+	}
+}
@@ -0,0 +1,44 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// ErrObjectNotFound is wrapped by GetObject when no metadata is stored for
+// the requested key, so callers can tell a missing object from a failed read
+var ErrObjectNotFound = errors.New("not found")
+
+// ErrObjectExists is wrapped by MoveObject when the destination key already
+// holds an object
+var ErrObjectExists = errors.New("already exists")
+
+// ErrUnwritable is wrapped by a store's write errors when the store cannot
+// accept writes at all, because it was opened read-only or its disk is full,
+// as opposed to a failure of one particular write
+var ErrUnwritable = errors.New("metadata store is not writable")
+
+// IsUnwritable reports whether err was marked with ErrUnwritable
+func IsUnwritable(err error) bool {
+	return errors.Is(err, ErrUnwritable)
+}
+
+// WrapUnwritable wraps err in ErrUnwritable when it matches one of the
+// store's read-only errors or reports a full or read-only filesystem. Other
+// errors, including nil, are returned unchanged.
+func WrapUnwritable(err error, readOnly ...error) error {
+	if err == nil || errors.Is(err, ErrUnwritable) {
+		return err
+	}
+	unwritable := errors.Is(err, syscall.ENOSPC) ||
+		errors.Is(err, syscall.EROFS) ||
+		errors.Is(err, syscall.EDQUOT)
+	for _, target := range readOnly {
+		unwritable = unwritable || errors.Is(err, target)
+	}
+	if !unwritable {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrUnwritable, err)
+}
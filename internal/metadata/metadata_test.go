@@ -1,6 +1,10 @@
 package metadata
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -238,3 +242,29 @@ func TestObjectMetadata_RoundTrip(t *testing.T) {
 		t.Errorf("Size = %d, want %d", decoded.Size, original.Size)
 	}
 }
+
+func TestWrapUnwritable(t *testing.T) {
+	errReadOnly := errors.New("database is read-only")
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errReadOnly, true},
+		{fmt.Errorf("put object: %w", errReadOnly), true},
+		{&os.PathError{Op: "write", Path: "000001.log", Err: syscall.ENOSPC}, true},
+		{syscall.EROFS, true},
+		{errors.New("key not found"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		wrapped := WrapUnwritable(tt.err, errReadOnly)
+		if got := IsUnwritable(wrapped); got != tt.want {
+			t.Errorf("IsUnwritable(WrapUnwritable(%v)) = %v, want %v", tt.err, got, tt.want)
+		}
+		if tt.err != nil && !errors.Is(wrapped, tt.err) {
+			t.Errorf("WrapUnwritable(%v) = %v, which no longer wraps the original error", tt.err, wrapped)
+		}
+	}
+}
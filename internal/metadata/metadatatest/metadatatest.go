@@ -0,0 +1,335 @@
+// Package metadatatest holds behaviour checks shared by every metadata.Store
+// implementation, so each store's tests run the same assertions.
+package metadatatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/metadata"
+)
+
+// PartStore is the part of metadata.Store the multipart checks exercise
+type PartStore interface {
+	CreateBucket(ctx context.Context, bucket string) error
+	CreateMultipartUpload(ctx context.Context, bucket, key, uploadID string, meta *metadata.ObjectMetadata) error
+	PutPart(ctx context.Context, bucket, key, uploadID string, partNumber int, meta *metadata.PartMetadata) error
+	ListParts(ctx context.Context, bucket, key, uploadID string) ([]metadata.PartMetadata, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []metadata.PartInfo) error
+}
+
+// TestListPartsNumericOrder checks that parts list in numeric order however
+// they were uploaded, and that completing an upload with a subset of its
+// parts still removes all of them.
+func TestListPartsNumericOrder(t *testing.T, store PartStore) {
+	t.Helper()
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+	_ = store.CreateMultipartUpload(ctx, "test-bucket", "test-key", "upload-123", &metadata.ObjectMetadata{})
+
+	// Unpadded keys would list part 10 before part 2
+	for _, n := range []int{12, 3, 10, 1, 7, 2, 11, 5, 9, 4, 8, 6} {
+		if err := store.PutPart(ctx, "test-bucket", "test-key", "upload-123", n, &metadata.PartMetadata{PartNumber: n}); err != nil {
+			t.Fatalf("PutPart(%d) error: %v", n, err)
+		}
+	}
+
+	parts, err := store.ListParts(ctx, "test-bucket", "test-key", "upload-123")
+	if err != nil {
+		t.Fatalf("ListParts() error: %v", err)
+	}
+	if len(parts) != 12 {
+		t.Fatalf("ListParts() returned %d parts, expected 12", len(parts))
+	}
+	for i, part := range parts {
+		if part.PartNumber != i+1 {
+			t.Errorf("parts[%d].PartNumber = %d, expected %d", i, part.PartNumber, i+1)
+		}
+	}
+
+	if err := store.CompleteMultipartUpload(ctx, "test-bucket", "test-key", "upload-123", []metadata.PartInfo{{PartNumber: 1}, {PartNumber: 2}}); err != nil {
+		t.Fatalf("CompleteMultipartUpload() error: %v", err)
+	}
+	parts, _ = store.ListParts(ctx, "test-bucket", "test-key", "upload-123")
+	if len(parts) != 0 {
+		t.Errorf("ListParts() after completion returned %d parts, expected 0", len(parts))
+	}
+}
+
+// TestMigratedPartKeys checks a store reopened over parts written with
+// unpadded keys, which the caller has stored as parts 1, 2 and 10 of
+// upload "legacy-upload" for test-bucket/test-key. They must list in
+// numeric order, and re-uploading one must replace it rather than add a
+// second entry.
+func TestMigratedPartKeys(t *testing.T, store PartStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := store.PutPart(ctx, "test-bucket", "test-key", "legacy-upload", 2, &metadata.PartMetadata{PartNumber: 2, ETag: "new"}); err != nil {
+		t.Fatalf("PutPart() error: %v", err)
+	}
+
+	parts, err := store.ListParts(ctx, "test-bucket", "test-key", "legacy-upload")
+	if err != nil {
+		t.Fatalf("ListParts() error: %v", err)
+	}
+	want := []int{1, 2, 10}
+	if len(parts) != len(want) {
+		t.Fatalf("ListParts() returned %d parts, expected %d: %+v", len(parts), len(want), parts)
+	}
+	for i, part := range parts {
+		if part.PartNumber != want[i] {
+			t.Errorf("parts[%d].PartNumber = %d, expected %d", i, part.PartNumber, want[i])
+		}
+	}
+	if parts[1].ETag != "new" {
+		t.Errorf("re-uploaded part 2 has ETag %q, expected the new upload", parts[1].ETag)
+	}
+}
+
+// UsageStore is the part of metadata.Store that keeps bucket usage totals
+type UsageStore interface {
+	PutBucketUsage(ctx context.Context, bucket string, usage *metadata.BucketUsage) error
+	GetBucketUsage(ctx context.Context, bucket string) (*metadata.BucketUsage, error)
+}
+
+// TestBucketUsage checks that a bucket has no usage totals until some are
+// stored, and that stored totals read back and can be replaced.
+func TestBucketUsage(t *testing.T, store UsageStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	usage, err := store.GetBucketUsage(ctx, "test-bucket")
+	if err != nil || usage != nil {
+		t.Fatalf("GetBucketUsage() before any put = %+v, %v, expected nil", usage, err)
+	}
+
+	for _, want := range []metadata.BucketUsage{{Bytes: 150, Objects: 2}, {Bytes: 10, Objects: 1}} {
+		if err := store.PutBucketUsage(ctx, "test-bucket", &want); err != nil {
+			t.Fatalf("PutBucketUsage() error: %v", err)
+		}
+		usage, err := store.GetBucketUsage(ctx, "test-bucket")
+		if err != nil {
+			t.Fatalf("GetBucketUsage() error: %v", err)
+		}
+		if usage == nil || *usage != want {
+			t.Errorf("GetBucketUsage() = %+v, expected %+v", usage, want)
+		}
+	}
+}
+
+// MoveStore is the part of metadata.Store that MoveObject touches
+type MoveStore interface {
+	PutObject(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error
+	GetObject(ctx context.Context, bucket, key string, versionID string) (*metadata.ObjectMetadata, error)
+	MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error
+	GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error)
+	PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error
+	GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error)
+	PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error
+	GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error)
+}
+
+// TestMoveObjectAssociations checks that a move takes the object's tags,
+// retention and legal hold with it, drops any left at the destination by an
+// earlier object, and refuses to replace an object already at the
+// destination.
+func TestMoveObjectAssociations(t *testing.T, store MoveStore) {
+	t.Helper()
+	ctx := context.Background()
+	_ = store.PutObject(ctx, "bucket", "src", &metadata.ObjectMetadata{Key: "src", Bucket: "bucket", Size: 1})
+	_ = store.PutObject(ctx, "bucket", "taken", &metadata.ObjectMetadata{Key: "taken", Bucket: "bucket", Size: 2})
+	_ = store.PutObjectTags(ctx, "bucket", "src", "", map[string]string{"team": "a"})
+	_ = store.PutObjectRetention(ctx, "bucket", "src", "", &metadata.ObjectRetention{Mode: "GOVERNANCE", RetainUntilDate: 4102444800})
+	_ = store.PutObjectLegalHold(ctx, "bucket", "dst", "", &metadata.ObjectLegalHold{Status: "ON"})
+
+	if err := store.MoveObject(ctx, "bucket", "src", "bucket", "taken"); !errors.Is(err, metadata.ErrObjectExists) {
+		t.Fatalf("MoveObject() onto an existing key error = %v, expected ErrObjectExists", err)
+	}
+	if meta, err := store.GetObject(ctx, "bucket", "taken", ""); err != nil || meta.Size != 2 {
+		t.Errorf("destination after refused move = %+v, %v, expected it unchanged", meta, err)
+	}
+
+	if err := store.MoveObject(ctx, "bucket", "src", "bucket", "dst"); err != nil {
+		t.Fatalf("MoveObject() error: %v", err)
+	}
+	if tags, err := store.GetObjectTags(ctx, "bucket", "dst", ""); err != nil || tags["team"] != "a" {
+		t.Errorf("GetObjectTags(dst) = %v, %v, expected the source's tags", tags, err)
+	}
+	if tags, _ := store.GetObjectTags(ctx, "bucket", "src", ""); len(tags) != 0 {
+		t.Errorf("GetObjectTags(src) after move = %v, expected none", tags)
+	}
+	if retention, err := store.GetObjectRetention(ctx, "bucket", "dst", ""); err != nil || retention == nil || retention.RetainUntilDate != 4102444800 {
+		t.Errorf("GetObjectRetention(dst) = %+v, %v, expected the source's retention", retention, err)
+	}
+	if retention, _ := store.GetObjectRetention(ctx, "bucket", "src", ""); retention != nil {
+		t.Errorf("GetObjectRetention(src) after move = %+v, expected none", retention)
+	}
+	if legalHold, _ := store.GetObjectLegalHold(ctx, "bucket", "dst", ""); legalHold != nil {
+		t.Errorf("GetObjectLegalHold(dst) = %+v, expected the stale hold to be dropped", legalHold)
+	}
+}
+
+// ModeStore is the part of metadata.Store that keeps bucket access modes
+type ModeStore interface {
+	PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error
+	GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error)
+}
+
+// TestBucketMode checks that a bucket has no mode until one is stored, and
+// that a stored mode reads back and can be replaced.
+func TestBucketMode(t *testing.T, store ModeStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	mode, err := store.GetBucketMode(ctx, "test-bucket")
+	if err != nil || mode != nil {
+		t.Fatalf("GetBucketMode() before any put = %+v, %v, expected nil", mode, err)
+	}
+
+	for _, want := range []string{"read-only", "maintenance", "read-write"} {
+		if err := store.PutBucketMode(ctx, "test-bucket", &metadata.BucketMode{Mode: want}); err != nil {
+			t.Fatalf("PutBucketMode(%s) error: %v", want, err)
+		}
+		mode, err := store.GetBucketMode(ctx, "test-bucket")
+		if err != nil {
+			t.Fatalf("GetBucketMode() error: %v", err)
+		}
+		if mode == nil || mode.Mode != want {
+			t.Errorf("GetBucketMode() = %+v, expected %s", mode, want)
+		}
+	}
+}
+
+// ACLStore is the part of metadata.Store that keeps bucket and object ACLs
+type ACLStore interface {
+	PutObject(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error
+	MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error
+	GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error)
+	DeleteBucketACL(ctx context.Context, bucket string) error
+	PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error
+	GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error)
+	DeleteObjectACL(ctx context.Context, bucket, key string) error
+}
+
+// TestACL checks that bucket and object ACLs read back as stored, are nil
+// until stored and after a delete, and that an object's ACL moves with it.
+func TestACL(t *testing.T, store ACLStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	if acl, err := store.GetBucketACL(ctx, "bucket"); acl != nil || err != nil {
+		t.Fatalf("GetBucketACL() before any put = %+v, %v, expected nil", acl, err)
+	}
+	acl := &metadata.AccessControlList{
+		Owner: metadata.ACLOwner{ID: "owner"},
+		Grants: []metadata.ACLGrant{{
+			Grantee:    metadata.ACLGrantee{Type: "Group", URI: "http://acs.amazonaws.com/groups/global/AllUsers"},
+			Permission: "READ",
+		}},
+	}
+	if err := store.PutBucketACL(ctx, "bucket", acl); err != nil {
+		t.Fatalf("PutBucketACL() error: %v", err)
+	}
+	got, err := store.GetBucketACL(ctx, "bucket")
+	if err != nil || got == nil || got.Owner.ID != "owner" || len(got.Grants) != 1 || got.Grants[0] != acl.Grants[0] {
+		t.Errorf("GetBucketACL() = %+v, %v, expected the stored ACL", got, err)
+	}
+	if err := store.DeleteBucketACL(ctx, "bucket"); err != nil {
+		t.Fatalf("DeleteBucketACL() error: %v", err)
+	}
+	if got, _ := store.GetBucketACL(ctx, "bucket"); got != nil {
+		t.Errorf("GetBucketACL() after delete = %+v, expected nil", got)
+	}
+
+	_ = store.PutObject(ctx, "bucket", "src", &metadata.ObjectMetadata{Key: "src", Bucket: "bucket"})
+	if err := store.PutObjectACL(ctx, "bucket", "src", acl); err != nil {
+		t.Fatalf("PutObjectACL() error: %v", err)
+	}
+	if err := store.MoveObject(ctx, "bucket", "src", "bucket", "dst"); err != nil {
+		t.Fatalf("MoveObject() error: %v", err)
+	}
+	if got, _ := store.GetObjectACL(ctx, "bucket", "src"); got != nil {
+		t.Errorf("GetObjectACL(src) after move = %+v, expected nil", got)
+	}
+	if got, err := store.GetObjectACL(ctx, "bucket", "dst"); err != nil || got == nil || got.Grants[0] != acl.Grants[0] {
+		t.Errorf("GetObjectACL(dst) = %+v, %v, expected the source's ACL", got, err)
+	}
+	if err := store.DeleteObjectACL(ctx, "bucket", "dst"); err != nil {
+		t.Fatalf("DeleteObjectACL() error: %v", err)
+	}
+	if got, _ := store.GetObjectACL(ctx, "bucket", "dst"); got != nil {
+		t.Errorf("GetObjectACL() after delete = %+v, expected nil", got)
+	}
+}
+
+// ListStore is the part of metadata.Store that streaming listings use
+type ListStore interface {
+	PutObject(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error
+	GetObject(ctx context.Context, bucket, key string, versionID string) (*metadata.ObjectMetadata, error)
+	ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(metadata.ObjectMetadata) error) error
+}
+
+// TestListObjectsFunc checks that a streaming listing spans several pages in
+// key order, honours the prefix and marker, stops at the first error from
+// the callback, and lets the callback use the store between pages.
+func TestListObjectsFunc(t *testing.T, store ListStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	count := metadata.ListPageSize + 5
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("logs/%05d", i)
+		if err := store.PutObject(ctx, "bucket", key, &metadata.ObjectMetadata{Key: key, Bucket: "bucket", Size: 1}); err != nil {
+			t.Fatalf("PutObject(%s) error: %v", key, err)
+		}
+	}
+	_ = store.PutObject(ctx, "bucket", "other", &metadata.ObjectMetadata{Key: "other", Bucket: "bucket"})
+	_ = store.PutObject(ctx, "bucket2", "logs/00000", &metadata.ObjectMetadata{Key: "logs/00000", Bucket: "bucket2"})
+
+	var keys []string
+	err := store.ListObjectsFunc(ctx, "bucket", "logs/", "", func(meta metadata.ObjectMetadata) error {
+		if _, err := store.GetObject(ctx, "bucket", meta.Key, ""); err != nil {
+			return err
+		}
+		keys = append(keys, meta.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListObjectsFunc() error: %v", err)
+	}
+	if len(keys) != count {
+		t.Fatalf("ListObjectsFunc() listed %d objects, expected %d", len(keys), count)
+	}
+	for i, key := range keys {
+		if want := fmt.Sprintf("logs/%05d", i); key != want {
+			t.Fatalf("keys[%d] = %s, expected %s", i, key, want)
+		}
+	}
+
+	keys = nil
+	_ = store.ListObjectsFunc(ctx, "bucket", "logs/", "logs/01001", func(meta metadata.ObjectMetadata) error {
+		keys = append(keys, meta.Key)
+		return nil
+	})
+	if len(keys) != count-1002 || keys[0] != "logs/01002" {
+		t.Errorf("ListObjectsFunc() after marker listed %v, expected logs/01002 onwards", keys)
+	}
+
+	stop := errors.New("stop")
+	listed := 0
+	err = store.ListObjectsFunc(ctx, "bucket", "", "", func(meta metadata.ObjectMetadata) error {
+		listed++
+		if listed == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || listed != 3 {
+		t.Errorf("ListObjectsFunc() stopped after %d objects with %v, expected 3 with the callback's error", listed, err)
+	}
+}
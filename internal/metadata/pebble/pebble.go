@@ -6,7 +6,8 @@ import (
 	"encoding/gob"
 	"fmt"
 	"path/filepath"
-	"strings"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -41,6 +42,11 @@ func New(rootDir string) (*PebbleStore, error) {
 		return nil, fmt.Errorf("failed to open pebble database: %w", err)
 	}
 
+	if err := migratePartKeys(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate part keys: %w", err)
+	}
+
 	return &PebbleStore{
 		db:      db,
 		rootDir: rootDir,
@@ -57,11 +63,96 @@ func objectKey(bucket, key string) []byte {
 	return []byte("object:" + bucket + "/" + key)
 }
 
+// prefixIterOptions bounds an iterator to the keys starting with prefix, so
+// Pebble only reads the tables that can hold them
+func prefixIterOptions(prefix []byte) *pebble.IterOptions {
+	return &pebble.IterOptions{LowerBound: prefix, UpperBound: prefixUpperBound(prefix)}
+}
+
+// prefixUpperBound returns the smallest key after every key starting with
+// prefix, or nil when there is none
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
 // multipartKey generates a multipart upload key
 func multipartKey(bucket, key, uploadID string) []byte {
 	return []byte("multipart:" + bucket + "/" + key + "/" + uploadID)
 }
 
+// partPrefix generates the key prefix shared by all parts of an upload
+func partPrefix(bucket, key, uploadID string) string {
+	return "part:" + bucket + "/" + key + "/" + uploadID + "/"
+}
+
+// partKey generates a part key. The part number is zero-padded to the width
+// of the largest allowed part number so parts iterate in numeric order.
+func partKey(bucket, key, uploadID string, partNumber int) []byte {
+	return []byte(fmt.Sprintf("%s%05d", partPrefix(bucket, key, uploadID), partNumber))
+}
+
+// paddedPartKey returns the zero-padded form of a part key written before
+// part numbers were padded, and false for keys that are already padded
+func paddedPartKey(k []byte) ([]byte, bool) {
+	i := bytes.LastIndexByte(k, '/')
+	if i < 0 || len(k)-i-1 >= 5 {
+		return nil, false
+	}
+	n, err := strconv.Atoi(string(k[i+1:]))
+	if err != nil {
+		return nil, false
+	}
+	return []byte(fmt.Sprintf("%s%05d", k[:i+1], n)), true
+}
+
+// migratePartKeys rewrites unpadded part keys of uploads begun before part
+// numbers were zero-padded, so their parts list in order and a re-uploaded
+// part replaces the old entry instead of sitting beside it
+func migratePartKeys(db *pebble.DB) error {
+	iter, err := db.NewIter(&pebble.IterOptions{LowerBound: []byte("part:"), UpperBound: []byte("part;")})
+	if err != nil {
+		return err
+	}
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		padded, ok := paddedPartKey(iter.Key())
+		if !ok {
+			continue
+		}
+		_, closer, err := db.Get(padded)
+		switch {
+		case err == nil:
+			closer.Close()
+		case err == pebble.ErrNotFound:
+			err = batch.Set(padded, iter.Value(), nil)
+		}
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		if err := batch.Delete(iter.Key(), nil); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if batch.Empty() {
+		return nil
+	}
+	return batch.Commit(pebble.Sync)
+}
+
 // lifecycleKey generates a lifecycle rule key
 func lifecycleKey(bucket string) []byte {
 	return []byte("lifecycle:" + bucket)
@@ -112,6 +203,20 @@ func accelerateKey(bucket string) []byte {
 	return []byte("accelerate:" + bucket)
 }
 
+func keyNormalizationKey(bucket string) []byte {
+	return []byte("keynormalization:" + bucket)
+}
+
+// bucketModeKey generates a bucket mode key
+func bucketModeKey(bucket string) []byte {
+	return []byte("bucketmode:" + bucket)
+}
+
+// usageKey generates a bucket usage key
+func usageKey(bucket string) []byte {
+	return []byte("usage:" + bucket)
+}
+
 // inventoryKey generates an inventory key
 func inventoryKey(bucket, id string) []byte {
 	return []byte("inventory:" + bucket + "/" + id)
@@ -139,7 +244,7 @@ func (p *PebbleStore) CreateBucket(ctx context.Context, bucket string) error {
 		return err
 	}
 
-	return p.db.Set(bucketKey(bucket), data, pebble.Sync)
+	return p.set(bucketKey(bucket), data)
 }
 
 // DeleteBucket deletes a bucket
@@ -147,7 +252,7 @@ func (p *PebbleStore) DeleteBucket(ctx context.Context, bucket string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(bucketKey(bucket), pebble.Sync)
+	return p.delete(bucketKey(bucket))
 }
 
 // GetBucket gets bucket metadata
@@ -177,7 +282,8 @@ func (p *PebbleStore) ListBuckets(ctx context.Context) ([]string, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	iter, err := p.db.NewIter(nil)
+	prefix := []byte("bucket:")
+	iter, err := p.db.NewIter(prefixIterOptions(prefix))
 	if err != nil {
 		return nil, err
 	}
@@ -185,77 +291,454 @@ func (p *PebbleStore) ListBuckets(ctx context.Context) ([]string, error) {
 
 	var buckets []string
 	for iter.First(); iter.Valid(); iter.Next() {
-		key := string(iter.Key())
-		if len(key) > 7 && key[:7] == "bucket:" {
-			buckets = append(buckets, key[7:])
+		if name := string(iter.Key()[len(prefix):]); name != "" {
+			buckets = append(buckets, name)
 		}
 	}
 
 	return buckets, nil
 }
 
-// PutObject stores object metadata
+// versionKey generates the key of one version of an object. Object keys
+// cannot contain NUL, so the versions of a key never share a prefix with
+// another object's.
+func versionKey(bucket, key, versionID string) []byte {
+	return []byte(versionPrefix(bucket, key) + versionID)
+}
+
+// versionPrefix is the prefix shared by every version of an object
+func versionPrefix(bucket, key string) string {
+	return "version:" + bucket + "/" + key + "\x00"
+}
+
+// versioningStatus returns the bucket's versioning status, Enabled or
+// Suspended, or "" when versioning was never turned on. The caller holds
+// p.mu.
+func (p *PebbleStore) versioningStatus(bucket string) (string, error) {
+	data, closer, err := p.db.Get(versioningKey(bucket))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	defer closer.Close()
+
+	var versioning metadata.BucketVersioning
+	if err := decodeMeta(data, &versioning); err != nil {
+		return "", err
+	}
+	switch versioning.Status {
+	case "Enabled", "Suspended":
+		return versioning.Status, nil
+	}
+	return "", nil
+}
+
+// getMeta reads and decodes the object metadata stored at k. The caller
+// holds p.mu.
+func (p *PebbleStore) getMeta(k []byte) (*metadata.ObjectMetadata, error) {
+	return readMeta(p.db, k)
+}
+
+// readMeta decodes the object metadata stored at k in r, which may be a
+// batch holding writes not yet committed
+func readMeta(r pebble.Reader, k []byte) (*metadata.ObjectMetadata, error) {
+	data, closer, err := r.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	var meta metadata.ObjectMetadata
+	if err := decodeMeta(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// listVersions returns every stored version of an object, newest first.
+// Versions written in the same second are ordered by version ID, which the
+// engine issues in creation order. The caller holds p.mu.
+func (p *PebbleStore) listVersions(bucket, key string) ([]metadata.ObjectMetadata, error) {
+	iter, err := p.db.NewIter(prefixIterOptions([]byte(versionPrefix(bucket, key))))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var versions []metadata.ObjectMetadata
+	for iter.First(); iter.Valid(); iter.Next() {
+		var meta metadata.ObjectMetadata
+		if err := decodeMeta(iter.Value(), &meta); err != nil {
+			continue
+		}
+		versions = append(versions, meta)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].LastModified != versions[j].LastModified {
+			return versions[i].LastModified > versions[j].LastModified
+		}
+		return versions[i].VersionID > versions[j].VersionID
+	})
+	return versions, nil
+}
+
+// PutObject stores object metadata. In a bucket whose versioning is Enabled
+// or Suspended the metadata is stored as version meta.VersionID, replacing
+// only a version with the same ID, and becomes the latest version. The
+// object:bucket/key entry always holds the latest version, and is removed
+// when that is a delete marker. An object written before versioning was
+// turned on is kept as a version of its own.
 func (p *PebbleStore) PutObject(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
+	return p.PutObjects(ctx, []metadata.ObjectWrite{{Bucket: bucket, Key: key, Meta: meta}})
+}
+
+// PutObjects stores the metadata of several objects as PutObject does,
+// committing them in one batch with a single sync. Each write sees the
+// ones before it, so writes to the same key apply in order.
+func (p *PebbleStore) PutObjects(ctx context.Context, writes []metadata.ObjectWrite) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	batch := p.db.NewIndexedBatch()
+	defer batch.Close()
+	for _, w := range writes {
+		if err := p.putObject(batch, w.Bucket, w.Key, w.Meta); err != nil {
+			return err
+		}
+	}
+	return metadata.WrapUnwritable(batch.Commit(pebble.Sync), pebble.ErrReadOnly)
+}
+
+// putObject adds one PutObject write to batch. The caller holds p.mu.
+func (p *PebbleStore) putObject(batch *pebble.Batch, bucket, key string, meta *metadata.ObjectMetadata) error {
 	data, err := encodeMeta(meta)
 	if err != nil {
 		return err
 	}
 
-	// Include version ID in key if present
-	keyStr := bucket + "/" + key
-	if meta.VersionID != "" {
-		keyStr += "?v=" + meta.VersionID
+	status, err := p.versioningStatus(bucket)
+	if err != nil {
+		return err
+	}
+	if status == "" || meta.VersionID == "" {
+		return batch.Set(objectKey(bucket, key), data, nil)
 	}
 
-	return p.db.Set(objectKey(bucket, key), data, pebble.Sync)
+	latest, err := readMeta(batch, objectKey(bucket, key))
+	if err != nil && err != pebble.ErrNotFound {
+		return err
+	}
+	if latest != nil && latest.VersionID != meta.VersionID {
+		_, closer, err := batch.Get(versionKey(bucket, key, latest.VersionID))
+		if err == pebble.ErrNotFound {
+			latest.IsLatest = false
+			prior, err := encodeMeta(latest)
+			if err != nil {
+				return err
+			}
+			if err := batch.Set(versionKey(bucket, key, latest.VersionID), prior, nil); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else {
+			closer.Close()
+		}
+	}
+
+	if err := batch.Set(versionKey(bucket, key, meta.VersionID), data, nil); err != nil {
+		return err
+	}
+	if meta.IsDeleteMarker {
+		return batch.Delete(objectKey(bucket, key), nil)
+	}
+	return batch.Set(objectKey(bucket, key), data, nil)
 }
 
-// GetObject gets object metadata
+// GetObject gets object metadata: the latest version when versionID is
+// empty, otherwise that version, which may be a delete marker
 func (p *PebbleStore) GetObject(ctx context.Context, bucket, key string, versionID string) (*metadata.ObjectMetadata, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	data, closer, err := p.db.Get(objectKey(bucket, key))
+	if versionID != "" {
+		meta, err := p.getMeta(versionKey(bucket, key, versionID))
+		if err == nil {
+			return meta, nil
+		}
+		if err != pebble.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	meta, err := p.getMeta(objectKey(bucket, key))
 	if err != nil {
 		if err == pebble.ErrNotFound {
-			return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+			return nil, fmt.Errorf("object %w: %s/%s", metadata.ErrObjectNotFound, bucket, key)
 		}
 		return nil, err
 	}
-	defer closer.Close()
 
-	var meta metadata.ObjectMetadata
-	if err := decodeMeta(data, &meta); err != nil {
+	// An object stored without versioning is found by its own version ID
+	if versionID != "" && meta.VersionID != versionID {
+		return nil, fmt.Errorf("version %w: %s", metadata.ErrObjectNotFound, versionID)
+	}
+
+	return meta, nil
+}
+
+// DeleteObject deletes object metadata. Without a version ID it removes the
+// latest entry only; delete markers are written with PutObject. With one it
+// removes that version, and if it was the latest the next newest version
+// takes its place.
+func (p *PebbleStore) DeleteObject(ctx context.Context, bucket, key string, versionID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if versionID == "" {
+		return p.delete(objectKey(bucket, key))
+	}
+
+	latest, err := p.getMeta(objectKey(bucket, key))
+	if err != nil && err != pebble.ErrNotFound {
+		return err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	if err := batch.Delete(versionKey(bucket, key, versionID), nil); err != nil {
+		return err
+	}
+
+	versions, err := p.listVersions(bucket, key)
+	if err != nil {
+		return err
+	}
+	var remaining []metadata.ObjectMetadata
+	for _, v := range versions {
+		if v.VersionID != versionID {
+			remaining = append(remaining, v)
+		}
+	}
+
+	// The latest entry only changes when the removed version was newest
+	wasLatest := latest != nil && latest.VersionID == versionID
+	if len(versions) > 0 && versions[0].VersionID == versionID {
+		wasLatest = true
+	}
+	if wasLatest {
+		if len(remaining) == 0 || remaining[0].IsDeleteMarker {
+			err = batch.Delete(objectKey(bucket, key), nil)
+		} else {
+			next := remaining[0]
+			next.IsLatest = true
+			var data []byte
+			if data, err = encodeMeta(&next); err == nil {
+				err = batch.Set(objectKey(bucket, key), data, nil)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return metadata.WrapUnwritable(batch.Commit(pebble.Sync), pebble.ErrReadOnly)
+}
+
+// ListObjectVersions lists every stored version and delete marker of the
+// objects under prefix. Objects written without versioning are listed as
+// their only version.
+func (p *PebbleStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]metadata.ObjectMetadata, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var versions []metadata.ObjectMetadata
+	seen := make(map[string]bool)
+
+	iter, err := p.db.NewIter(prefixIterOptions([]byte("version:" + bucket + "/" + prefix)))
+	if err != nil {
 		return nil, err
 	}
+	for iter.First(); iter.Valid(); iter.Next() {
+		var meta metadata.ObjectMetadata
+		if err := decodeMeta(iter.Value(), &meta); err != nil {
+			continue
+		}
+		seen[meta.Key+"\x00"+meta.VersionID] = true
+		versions = append(versions, meta)
+	}
+	iter.Close()
 
-	// If version ID specified, verify it matches
-	if versionID != "" && meta.VersionID != versionID {
-		return nil, fmt.Errorf("version not found: %s", versionID)
+	iter, err = p.db.NewIter(prefixIterOptions([]byte("object:" + bucket + "/" + prefix)))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		var meta metadata.ObjectMetadata
+		if err := decodeMeta(iter.Value(), &meta); err != nil {
+			continue
+		}
+		if !seen[meta.Key+"\x00"+meta.VersionID] {
+			versions = append(versions, meta)
+		}
 	}
 
-	return &meta, nil
+	return versions, nil
 }
 
-// DeleteObject deletes object metadata
-func (p *PebbleStore) DeleteObject(ctx context.Context, bucket, key string, versionID string) error {
+// BucketIsEmpty reports whether no object, version or delete marker is
+// stored under the bucket's object: and version: prefixes
+func (p *PebbleStore) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, prefix := range []string{"object:" + bucket + "/", "version:" + bucket + "/"} {
+		iter, err := p.db.NewIter(prefixIterOptions([]byte(prefix)))
+		if err != nil {
+			return false, err
+		}
+		found := iter.First()
+		if err := iter.Close(); err != nil {
+			return false, err
+		}
+		if found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MoveObject moves object metadata, tags, ACL, retention and legal hold to a new
+// key. The deletes and the writes are committed in one batch so readers see
+// either the old key or the new one.
+func (p *PebbleStore) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, closer, err := p.db.Get(objectKey(srcBucket, srcKey))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return fmt.Errorf("object %w: %s/%s", metadata.ErrObjectNotFound, srcBucket, srcKey)
+		}
+		return err
+	}
+	var meta metadata.ObjectMetadata
+	err = decodeMeta(data, &meta)
+	closer.Close()
+	if err != nil {
+		return err
+	}
+
+	_, closer, err = p.db.Get(objectKey(dstBucket, dstKey))
+	if err == nil {
+		closer.Close()
+		return fmt.Errorf("object %w: %s/%s", metadata.ErrObjectExists, dstBucket, dstKey)
+	} else if err != pebble.ErrNotFound {
+		return err
+	}
+
+	meta.Bucket = dstBucket
+	meta.Key = dstKey
+	encoded, err := encodeMeta(&meta)
+	if err != nil {
+		return err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	if err := batch.Delete(objectKey(srcBucket, srcKey), nil); err != nil {
+		return err
+	}
+	if err := batch.Set(objectKey(dstBucket, dstKey), encoded, nil); err != nil {
+		return err
+	}
+
+	// Entries left at the destination by an earlier object there are
+	// dropped rather than inherited
+	for _, entryKey := range []func(bucket, key string) []byte{objectTagsKey, objectACLKey, retentionKey, legalHoldKey} {
+		value, closer, err := p.db.Get(entryKey(srcBucket, srcKey))
+		if err == pebble.ErrNotFound {
+			if err := batch.Delete(entryKey(dstBucket, dstKey), nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = batch.Set(entryKey(dstBucket, dstKey), value, nil)
+		closer.Close()
+		if err != nil {
+			return err
+		}
+		if err := batch.Delete(entryKey(srcBucket, srcKey), nil); err != nil {
+			return err
+		}
+	}
+	return metadata.WrapUnwritable(batch.Commit(pebble.Sync), pebble.ErrReadOnly)
+}
+
+// UpdateObjectVersion rewrites a stored version in place. The latest version
+// is held both in object:bucket/key and, once versioning is on, under its
+// version key, so both copies are rewritten in one batch.
+func (p *PebbleStore) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(objectKey(bucket, key), pebble.Sync)
+	data, err := encodeMeta(meta)
+	if err != nil {
+		return err
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+	found := false
+
+	latest, err := p.getMeta(objectKey(bucket, key))
+	if err != nil && err != pebble.ErrNotFound {
+		return err
+	}
+	if latest != nil && latest.VersionID == meta.VersionID {
+		if err := batch.Set(objectKey(bucket, key), data, nil); err != nil {
+			return err
+		}
+		found = true
+	}
+
+	if meta.VersionID != "" {
+		_, closer, err := p.db.Get(versionKey(bucket, key, meta.VersionID))
+		if err == nil {
+			closer.Close()
+			if err := batch.Set(versionKey(bucket, key, meta.VersionID), data, nil); err != nil {
+				return err
+			}
+			found = true
+		} else if err != pebble.ErrNotFound {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("object %w: %s/%s?versionId=%s", metadata.ErrObjectNotFound, bucket, key, meta.VersionID)
+	}
+	return metadata.WrapUnwritable(batch.Commit(pebble.Sync), pebble.ErrReadOnly)
 }
 
-// ListObjects lists objects with optional prefix
+// ListObjects lists objects with optional prefix, in key order starting
+// after opts.Marker
 func (p *PebbleStore) ListObjects(ctx context.Context, bucket, prefix string, opts metadata.ListOptions) ([]metadata.ObjectMetadata, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	prefixKey := "object:" + bucket + "/" + prefix
+	prefixKey := []byte("object:" + bucket + "/" + prefix)
+	from := prefixKey
+	if after := []byte("object:" + bucket + "/" + opts.Marker + "\x00"); opts.Marker != "" && bytes.Compare(after, from) > 0 {
+		from = after
+	}
 
-	iter, err := p.db.NewIter(nil)
+	iter, err := p.db.NewIter(prefixIterOptions(prefixKey))
 	if err != nil {
 		return nil, err
 	}
@@ -267,29 +750,71 @@ func (p *PebbleStore) ListObjects(ctx context.Context, bucket, prefix string, op
 		maxKeys = 1000
 	}
 
-	for iter.SeekGE([]byte(prefixKey)); iter.Valid() && len(objects) < maxKeys; iter.Next() {
-		key := string(iter.Key())
-		if len(key) < 8 || key[:8] != "object:" {
-			break
+	for iter.SeekGE(from); iter.Valid() && len(objects) < maxKeys; iter.Next() {
+		var meta metadata.ObjectMetadata
+		if err := decodeMeta(iter.Value(), &meta); err != nil {
+			continue
 		}
 
-		// Extract bucket/key
-		rest := key[8:]
-		if len(rest) <= len(bucket)+1 || rest[:len(bucket)+1] != bucket+"/" {
-			continue
+		objects = append(objects, meta)
+	}
+
+	return objects, nil
+}
+
+// ListObjectsFunc calls fn for each object under prefix after marker in key
+// order, a page at a time
+func (p *PebbleStore) ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(metadata.ObjectMetadata) error) error {
+	start := []byte("object:" + bucket + "/" + prefix)
+	from := start
+	if after := []byte("object:" + bucket + "/" + marker + "\x00"); marker != "" && bytes.Compare(after, from) > 0 {
+		from = after
+	}
+	for from != nil {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		objKey := rest[len(bucket)+1:]
-		_ = objKey // Reserved for future use
 
+		var page []metadata.ObjectMetadata
+		var err error
+		page, from, err = p.objectPage(start, from)
+		if err != nil {
+			return err
+		}
+		for _, meta := range page {
+			if err := fn(meta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// objectPage reads up to metadata.ListPageSize objects with keys under
+// prefix, starting at from. It returns the key to resume from, or nil once
+// no keys are left.
+func (p *PebbleStore) objectPage(prefix, from []byte) ([]metadata.ObjectMetadata, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	iter, err := p.db.NewIter(&pebble.IterOptions{LowerBound: from, UpperBound: prefixUpperBound(prefix)})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Close()
+
+	page := make([]metadata.ObjectMetadata, 0, metadata.ListPageSize)
+	for iter.First(); iter.Valid(); iter.Next() {
+		if len(page) == metadata.ListPageSize {
+			return page, append([]byte(nil), iter.Key()...), nil
+		}
 		var meta metadata.ObjectMetadata
 		if err := decodeMeta(iter.Value(), &meta); err != nil {
 			continue
 		}
-
-		objects = append(objects, meta)
+		page = append(page, meta)
 	}
-
-	return objects, nil
+	return page, nil, iter.Error()
 }
 
 // CreateMultipartUpload creates a new multipart upload
@@ -302,11 +827,14 @@ func (p *PebbleStore) CreateMultipartUpload(ctx context.Context, bucket, key, up
 	}
 
 	multiMeta := &metadata.MultipartUploadMetadata{
-		UploadID:  uploadID,
-		Key:       key,
-		Bucket:    bucket,
-		Initiated: nowUnix(),
-		Metadata:  meta.Metadata,
+		UploadID:     uploadID,
+		Key:          key,
+		Bucket:       bucket,
+		Initiated:    nowUnix(),
+		Metadata:     meta.Metadata,
+		StorageClass: meta.StorageClass,
+		SSEAlgorithm: meta.SSEAlgorithm,
+		Checksum:     meta.Checksum,
 	}
 
 	data, err := encodeMeta(multiMeta)
@@ -314,7 +842,7 @@ func (p *PebbleStore) CreateMultipartUpload(ctx context.Context, bucket, key, up
 		return err
 	}
 
-	return p.db.Set(multipartKey(bucket, key, uploadID), data, pebble.Sync)
+	return p.set(multipartKey(bucket, key, uploadID), data)
 }
 
 // PutPart stores part metadata
@@ -327,8 +855,7 @@ func (p *PebbleStore) PutPart(ctx context.Context, bucket, key, uploadID string,
 		return err
 	}
 
-	partKey := fmt.Sprintf("part:%s/%s/%s/%d", bucket, key, uploadID, partNumber)
-	return p.db.Set([]byte(partKey), data, pebble.Sync)
+	return p.set(partKey(bucket, key, uploadID, partNumber), data)
 }
 
 // CompleteMultipartUpload completes a multipart upload
@@ -337,18 +864,16 @@ func (p *PebbleStore) CompleteMultipartUpload(ctx context.Context, bucket, key,
 	defer p.mu.Unlock()
 
 	// Delete multipart upload metadata
-	err := p.db.Delete(multipartKey(bucket, key, uploadID), pebble.Sync)
+	err := p.delete(multipartKey(bucket, key, uploadID))
 	if err != nil {
 		return err
 	}
 
-	// Delete all parts
-	for i := 1; i <= len(parts); i++ {
-		partKey := fmt.Sprintf("part:%s/%s/%s/%d", bucket, key, uploadID, i)
-		p.db.Delete([]byte(partKey), pebble.Sync)
-	}
-
-	return nil
+	// Delete all parts, including any the client left out of the completion.
+	// The range ends at the prefix with its trailing '/' bumped to '0'.
+	prefix := partPrefix(bucket, key, uploadID)
+	end := prefix[:len(prefix)-1] + "0"
+	return metadata.WrapUnwritable(p.db.DeleteRange([]byte(prefix), []byte(end), pebble.Sync), pebble.ErrReadOnly)
 }
 
 // AbortMultipartUpload aborts a multipart upload
@@ -356,7 +881,7 @@ func (p *PebbleStore) AbortMultipartUpload(ctx context.Context, bucket, key, upl
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(multipartKey(bucket, key, uploadID), pebble.Sync)
+	return p.delete(multipartKey(bucket, key, uploadID))
 }
 
 // ListParts lists parts of a multipart upload
@@ -364,21 +889,14 @@ func (p *PebbleStore) ListParts(ctx context.Context, bucket, key, uploadID strin
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	prefix := fmt.Sprintf("part:%s/%s/%s/", bucket, key, uploadID)
-
-	iter, err := p.db.NewIter(nil)
+	iter, err := p.db.NewIter(prefixIterOptions([]byte(partPrefix(bucket, key, uploadID))))
 	if err != nil {
 		return nil, err
 	}
 	defer iter.Close()
 
 	var parts []metadata.PartMetadata
-	for iter.SeekGE([]byte(prefix)); iter.Valid(); iter.Next() {
-		keyStr := string(iter.Key())
-		if len(keyStr) < len(prefix) || keyStr[:len(prefix)] != prefix {
-			break
-		}
-
+	for iter.First(); iter.Valid(); iter.Next() {
 		var partMeta metadata.PartMetadata
 		if err := decodeMeta(iter.Value(), &partMeta); err != nil {
 			continue
@@ -395,9 +913,7 @@ func (p *PebbleStore) ListMultipartUploads(ctx context.Context, bucket, prefix s
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	bucketPrefix := "multipart:" + bucket + "/"
-
-	iter, err := p.db.NewIter(nil)
+	iter, err := p.db.NewIter(prefixIterOptions([]byte("multipart:" + bucket + "/" + prefix)))
 	if err != nil {
 		return nil, err
 	}
@@ -405,18 +921,6 @@ func (p *PebbleStore) ListMultipartUploads(ctx context.Context, bucket, prefix s
 
 	var uploads []metadata.MultipartUploadMetadata
 	for iter.First(); iter.Valid(); iter.Next() {
-		key := string(iter.Key())
-		if !strings.HasPrefix(key, bucketPrefix) {
-			continue
-		}
-
-		if prefix != "" {
-			rest := key[len(bucketPrefix):]
-			if !strings.HasPrefix(rest, prefix) {
-				continue
-			}
-		}
-
 		var meta metadata.MultipartUploadMetadata
 		if err := decodeMeta(iter.Value(), &meta); err != nil {
 			continue
@@ -454,7 +958,7 @@ func (p *PebbleStore) PutLifecycleRule(ctx context.Context, bucket string, rule
 		return err
 	}
 
-	return p.db.Set(lifecycleKey(bucket), data, pebble.Sync)
+	return p.set(lifecycleKey(bucket), data)
 }
 
 // GetLifecycleRules gets lifecycle rules for a bucket
@@ -508,7 +1012,7 @@ func (p *PebbleStore) DeleteLifecycleRule(ctx context.Context, bucket, ruleID st
 
 	// If no rules left, delete the key
 	if len(newRules) == 0 {
-		return p.db.Delete(lifecycleKey(bucket), pebble.Sync)
+		return p.delete(lifecycleKey(bucket))
 	}
 
 	// Save remaining rules
@@ -517,7 +1021,7 @@ func (p *PebbleStore) DeleteLifecycleRule(ctx context.Context, bucket, ruleID st
 		return err
 	}
 
-	return p.db.Set(lifecycleKey(bucket), data, pebble.Sync)
+	return p.set(lifecycleKey(bucket), data)
 }
 
 // PutBucketVersioning puts bucket versioning configuration
@@ -530,7 +1034,7 @@ func (p *PebbleStore) PutBucketVersioning(ctx context.Context, bucket string, ve
 		return err
 	}
 
-	return p.db.Set(versioningKey(bucket), data, pebble.Sync)
+	return p.set(versioningKey(bucket), data)
 }
 
 // GetBucketVersioning gets bucket versioning configuration
@@ -565,7 +1069,7 @@ func (p *PebbleStore) PutBucketCors(ctx context.Context, bucket string, cors *me
 		return err
 	}
 
-	return p.db.Set(corsKey(bucket), data, pebble.Sync)
+	return p.set(corsKey(bucket), data)
 }
 
 // GetBucketCors gets CORS configuration
@@ -595,7 +1099,7 @@ func (p *PebbleStore) DeleteBucketCors(ctx context.Context, bucket string) error
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(corsKey(bucket), pebble.Sync)
+	return p.delete(corsKey(bucket))
 }
 
 // PutBucketPolicy stores bucket policy
@@ -607,7 +1111,7 @@ func (p *PebbleStore) PutBucketPolicy(ctx context.Context, bucket string, policy
 		return fmt.Errorf("policy cannot be nil")
 	}
 
-	return p.db.Set(policyKey(bucket), []byte(*policy), pebble.Sync)
+	return p.set(policyKey(bucket), []byte(*policy))
 }
 
 // GetBucketPolicy gets bucket policy
@@ -633,7 +1137,7 @@ func (p *PebbleStore) DeleteBucketPolicy(ctx context.Context, bucket string) err
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(policyKey(bucket), pebble.Sync)
+	return p.delete(policyKey(bucket))
 }
 
 // PutBucketEncryption stores bucket encryption configuration
@@ -646,7 +1150,7 @@ func (p *PebbleStore) PutBucketEncryption(ctx context.Context, bucket string, en
 		return err
 	}
 
-	return p.db.Set(encryptionKey(bucket), data, pebble.Sync)
+	return p.set(encryptionKey(bucket), data)
 }
 
 // GetBucketEncryption gets bucket encryption configuration
@@ -676,7 +1180,7 @@ func (p *PebbleStore) DeleteBucketEncryption(ctx context.Context, bucket string)
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(encryptionKey(bucket), pebble.Sync)
+	return p.delete(encryptionKey(bucket))
 }
 
 // PutReplicationConfig stores replication configuration
@@ -689,7 +1193,7 @@ func (p *PebbleStore) PutReplicationConfig(ctx context.Context, bucket string, c
 		return err
 	}
 
-	return p.db.Set(replicationKey(bucket), data, pebble.Sync)
+	return p.set(replicationKey(bucket), data)
 }
 
 // GetReplicationConfig gets replication configuration
@@ -719,7 +1223,7 @@ func (p *PebbleStore) DeleteReplicationConfig(ctx context.Context, bucket string
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(replicationKey(bucket), pebble.Sync)
+	return p.delete(replicationKey(bucket))
 }
 
 // PutBucketTags stores bucket tags
@@ -732,7 +1236,7 @@ func (p *PebbleStore) PutBucketTags(ctx context.Context, bucket string, tags map
 		return err
 	}
 
-	return p.db.Set(tagsKey(bucket), data, pebble.Sync)
+	return p.set(tagsKey(bucket), data)
 }
 
 // GetBucketTags gets bucket tags
@@ -762,7 +1266,65 @@ func (p *PebbleStore) DeleteBucketTags(ctx context.Context, bucket string) error
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(tagsKey(bucket), pebble.Sync)
+	return p.delete(tagsKey(bucket))
+}
+
+// objectTagsKey generates an object tags key
+func objectTagsKey(bucket, key string) []byte {
+	return []byte("objtags:" + bucket + "/" + key)
+}
+
+// entryVersionKey qualifies the key of an object's tags, retention or legal
+// hold with a version. Object keys cannot contain NUL, so the entries of one
+// version never collide with another object's.
+func entryVersionKey(entryKey []byte, versionID string) []byte {
+	if versionID == "" {
+		return entryKey
+	}
+	return append(entryKey, "\x00"+versionID...)
+}
+
+// PutObjectTags replaces the tag set of an object
+func (p *PebbleStore) PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := encodeMeta(tags)
+	if err != nil {
+		return err
+	}
+
+	return p.set(entryVersionKey(objectTagsKey(bucket, key), versionID), data)
+}
+
+// GetObjectTags gets the tag set of an object
+func (p *PebbleStore) GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	data, closer, err := p.db.Get(entryVersionKey(objectTagsKey(bucket, key), versionID))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	var tags map[string]string
+	if err := decodeMeta(data, &tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// DeleteObjectTags deletes the tag set of an object
+func (p *PebbleStore) DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.delete(entryVersionKey(objectTagsKey(bucket, key), versionID))
 }
 
 // PutObjectLock stores object lock configuration
@@ -775,7 +1337,7 @@ func (p *PebbleStore) PutObjectLock(ctx context.Context, bucket string, config *
 		return err
 	}
 
-	return p.db.Set(objectLockKey(bucket), data, pebble.Sync)
+	return p.set(objectLockKey(bucket), data)
 }
 
 // GetObjectLock gets object lock configuration
@@ -805,7 +1367,7 @@ func (p *PebbleStore) DeleteObjectLock(ctx context.Context, bucket string) error
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(objectLockKey(bucket), pebble.Sync)
+	return p.delete(objectLockKey(bucket))
 }
 
 // retentionKey generates an object retention key
@@ -814,7 +1376,7 @@ func retentionKey(bucket, key string) []byte {
 }
 
 // PutObjectRetention stores object retention
-func (p *PebbleStore) PutObjectRetention(ctx context.Context, bucket, key string, retention *metadata.ObjectRetention) error {
+func (p *PebbleStore) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -823,15 +1385,15 @@ func (p *PebbleStore) PutObjectRetention(ctx context.Context, bucket, key string
 		return err
 	}
 
-	return p.db.Set(retentionKey(bucket, key), data, pebble.Sync)
+	return p.set(entryVersionKey(retentionKey(bucket, key), versionID), data)
 }
 
 // GetObjectRetention retrieves object retention
-func (p *PebbleStore) GetObjectRetention(ctx context.Context, bucket, key string) (*metadata.ObjectRetention, error) {
+func (p *PebbleStore) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	data, closer, err := p.db.Get(retentionKey(bucket, key))
+	data, closer, err := p.db.Get(entryVersionKey(retentionKey(bucket, key), versionID))
 	if err != nil {
 		if err == pebble.ErrNotFound {
 			return nil, nil
@@ -854,7 +1416,7 @@ func legalHoldKey(bucket, key string) []byte {
 }
 
 // PutObjectLegalHold stores object legal hold
-func (p *PebbleStore) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *metadata.ObjectLegalHold) error {
+func (p *PebbleStore) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -863,15 +1425,15 @@ func (p *PebbleStore) PutObjectLegalHold(ctx context.Context, bucket, key string
 		return err
 	}
 
-	return p.db.Set(legalHoldKey(bucket, key), data, pebble.Sync)
+	return p.set(entryVersionKey(legalHoldKey(bucket, key), versionID), data)
 }
 
 // GetObjectLegalHold retrieves object legal hold
-func (p *PebbleStore) GetObjectLegalHold(ctx context.Context, bucket, key string) (*metadata.ObjectLegalHold, error) {
+func (p *PebbleStore) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	data, closer, err := p.db.Get(legalHoldKey(bucket, key))
+	data, closer, err := p.db.Get(entryVersionKey(legalHoldKey(bucket, key), versionID))
 	if err != nil {
 		if err == pebble.ErrNotFound {
 			return nil, nil
@@ -898,7 +1460,7 @@ func (p *PebbleStore) PutPublicAccessBlock(ctx context.Context, bucket string, c
 		return err
 	}
 
-	return p.db.Set(publicAccessBlockKey(bucket), data, pebble.Sync)
+	return p.set(publicAccessBlockKey(bucket), data)
 }
 
 // GetPublicAccessBlock gets public access block configuration
@@ -928,7 +1490,199 @@ func (p *PebbleStore) DeletePublicAccessBlock(ctx context.Context, bucket string
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(publicAccessBlockKey(bucket), pebble.Sync)
+	return p.delete(publicAccessBlockKey(bucket))
+}
+
+// bucketACLKey generates a bucket ACL key
+func bucketACLKey(bucket string) []byte {
+	return []byte("acl:" + bucket)
+}
+
+// objectACLKey generates an object ACL key
+func objectACLKey(bucket, key string) []byte {
+	return []byte("objacl:" + bucket + "/" + key)
+}
+
+// PutBucketACL stores the ACL of a bucket
+func (p *PebbleStore) PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := encodeMeta(acl)
+	if err != nil {
+		return err
+	}
+
+	return p.set(bucketACLKey(bucket), data)
+}
+
+// GetBucketACL gets the ACL of a bucket, nil if none is stored
+func (p *PebbleStore) GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.getACL(bucketACLKey(bucket))
+}
+
+// DeleteBucketACL deletes the ACL of a bucket
+func (p *PebbleStore) DeleteBucketACL(ctx context.Context, bucket string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.delete(bucketACLKey(bucket))
+}
+
+// PutObjectACL stores the ACL of an object
+func (p *PebbleStore) PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := encodeMeta(acl)
+	if err != nil {
+		return err
+	}
+
+	return p.set(objectACLKey(bucket, key), data)
+}
+
+// GetObjectACL gets the ACL of an object, nil if none is stored
+func (p *PebbleStore) GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.getACL(objectACLKey(bucket, key))
+}
+
+// DeleteObjectACL deletes the ACL of an object
+func (p *PebbleStore) DeleteObjectACL(ctx context.Context, bucket, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.delete(objectACLKey(bucket, key))
+}
+
+// getACL decodes the ACL stored at k. The caller must hold p.mu.
+func (p *PebbleStore) getACL(k []byte) (*metadata.AccessControlList, error) {
+	data, closer, err := p.db.Get(k)
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	var acl metadata.AccessControlList
+	if err := decodeMeta(data, &acl); err != nil {
+		return nil, err
+	}
+
+	return &acl, nil
+}
+
+// PutBucketKeyNormalization stores bucket key normalization configuration
+func (p *PebbleStore) PutBucketKeyNormalization(ctx context.Context, bucket string, config *metadata.KeyNormalizationConfig) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := encodeMeta(config)
+	if err != nil {
+		return err
+	}
+
+	return p.set(keyNormalizationKey(bucket), data)
+}
+
+// GetBucketKeyNormalization gets bucket key normalization configuration
+func (p *PebbleStore) GetBucketKeyNormalization(ctx context.Context, bucket string) (*metadata.KeyNormalizationConfig, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	data, closer, err := p.db.Get(keyNormalizationKey(bucket))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	var config metadata.KeyNormalizationConfig
+	if err := decodeMeta(data, &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// PutBucketMode stores the access mode of a bucket
+func (p *PebbleStore) PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := encodeMeta(mode)
+	if err != nil {
+		return err
+	}
+
+	return p.set(bucketModeKey(bucket), data)
+}
+
+// GetBucketMode gets the access mode of a bucket, or nil if none was set
+func (p *PebbleStore) GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	data, closer, err := p.db.Get(bucketModeKey(bucket))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	var mode metadata.BucketMode
+	if err := decodeMeta(data, &mode); err != nil {
+		return nil, err
+	}
+
+	return &mode, nil
+}
+
+// PutBucketUsage stores the usage totals of a bucket
+func (p *PebbleStore) PutBucketUsage(ctx context.Context, bucket string, usage *metadata.BucketUsage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := encodeMeta(usage)
+	if err != nil {
+		return err
+	}
+
+	return p.set(usageKey(bucket), data)
+}
+
+// GetBucketUsage gets the usage totals of a bucket, or nil if none are stored
+func (p *PebbleStore) GetBucketUsage(ctx context.Context, bucket string) (*metadata.BucketUsage, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	data, closer, err := p.db.Get(usageKey(bucket))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+
+	var usage metadata.BucketUsage
+	if err := decodeMeta(data, &usage); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
 }
 
 // PutBucketAccelerate stores bucket accelerate configuration
@@ -941,7 +1695,7 @@ func (p *PebbleStore) PutBucketAccelerate(ctx context.Context, bucket string, co
 		return err
 	}
 
-	return p.db.Set(accelerateKey(bucket), data, pebble.Sync)
+	return p.set(accelerateKey(bucket), data)
 }
 
 // GetBucketAccelerate gets bucket accelerate configuration
@@ -971,7 +1725,7 @@ func (p *PebbleStore) DeleteBucketAccelerate(ctx context.Context, bucket string)
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(accelerateKey(bucket), pebble.Sync)
+	return p.delete(accelerateKey(bucket))
 }
 
 // PutBucketInventory stores bucket inventory configuration
@@ -984,7 +1738,7 @@ func (p *PebbleStore) PutBucketInventory(ctx context.Context, bucket, id string,
 		return err
 	}
 
-	return p.db.Set(inventoryKey(bucket, id), data, pebble.Sync)
+	return p.set(inventoryKey(bucket, id), data)
 }
 
 // GetBucketInventory gets bucket inventory configuration
@@ -1014,21 +1768,14 @@ func (p *PebbleStore) ListBucketInventory(ctx context.Context, bucket string) ([
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	prefix := "inventory:" + bucket + "/"
-
-	iter, err := p.db.NewIter(nil)
+	iter, err := p.db.NewIter(prefixIterOptions([]byte("inventory:" + bucket + "/")))
 	if err != nil {
 		return nil, err
 	}
 	defer iter.Close()
 
 	var configs []metadata.InventoryConfiguration
-	for iter.SeekGE([]byte(prefix)); iter.Valid(); iter.Next() {
-		key := string(iter.Key())
-		if len(key) < len(prefix) || key[:len(prefix)] != prefix {
-			break
-		}
-
+	for iter.First(); iter.Valid(); iter.Next() {
 		var config metadata.InventoryConfiguration
 		if err := decodeMeta(iter.Value(), &config); err != nil {
 			continue
@@ -1045,7 +1792,7 @@ func (p *PebbleStore) DeleteBucketInventory(ctx context.Context, bucket, id stri
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(inventoryKey(bucket, id), pebble.Sync)
+	return p.delete(inventoryKey(bucket, id))
 }
 
 // PutBucketAnalytics stores bucket analytics configuration
@@ -1058,7 +1805,7 @@ func (p *PebbleStore) PutBucketAnalytics(ctx context.Context, bucket, id string,
 		return err
 	}
 
-	return p.db.Set(analyticsKey(bucket, id), data, pebble.Sync)
+	return p.set(analyticsKey(bucket, id), data)
 }
 
 // GetBucketAnalytics gets bucket analytics configuration
@@ -1088,21 +1835,14 @@ func (p *PebbleStore) ListBucketAnalytics(ctx context.Context, bucket string) ([
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	prefix := "analytics:" + bucket + "/"
-
-	iter, err := p.db.NewIter(nil)
+	iter, err := p.db.NewIter(prefixIterOptions([]byte("analytics:" + bucket + "/")))
 	if err != nil {
 		return nil, err
 	}
 	defer iter.Close()
 
 	var configs []metadata.AnalyticsConfiguration
-	for iter.SeekGE([]byte(prefix)); iter.Valid(); iter.Next() {
-		key := string(iter.Key())
-		if len(key) < len(prefix) || key[:len(prefix)] != prefix {
-			break
-		}
-
+	for iter.First(); iter.Valid(); iter.Next() {
 		var config metadata.AnalyticsConfiguration
 		if err := decodeMeta(iter.Value(), &config); err != nil {
 			continue
@@ -1119,7 +1859,7 @@ func (p *PebbleStore) DeleteBucketAnalytics(ctx context.Context, bucket, id stri
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(analyticsKey(bucket, id), pebble.Sync)
+	return p.delete(analyticsKey(bucket, id))
 }
 
 // presignedURLKey returns the key for a presigned URL
@@ -1136,7 +1876,7 @@ func (p *PebbleStore) PutPresignedURL(ctx context.Context, url string, req *meta
 	if err != nil {
 		return err
 	}
-	return p.db.Set(presignedURLKey(url), data, pebble.Sync)
+	return p.set(presignedURLKey(url), data)
 }
 
 // GetPresignedURL retrieves a presigned URL request
@@ -1164,7 +1904,7 @@ func (p *PebbleStore) DeletePresignedURL(ctx context.Context, url string) error
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(presignedURLKey(url), pebble.Sync)
+	return p.delete(presignedURLKey(url))
 }
 
 // websiteKey generates a website configuration key
@@ -1182,7 +1922,7 @@ func (p *PebbleStore) PutBucketWebsite(ctx context.Context, bucket string, confi
 		return err
 	}
 
-	return p.db.Set(websiteKey(bucket), data, pebble.Sync)
+	return p.set(websiteKey(bucket), data)
 }
 
 // GetBucketWebsite gets bucket website configuration
@@ -1212,7 +1952,7 @@ func (p *PebbleStore) DeleteBucketWebsite(ctx context.Context, bucket string) er
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(websiteKey(bucket), pebble.Sync)
+	return p.delete(websiteKey(bucket))
 }
 
 // notificationKey generates a notification configuration key
@@ -1230,7 +1970,7 @@ func (p *PebbleStore) PutBucketNotification(ctx context.Context, bucket string,
 		return err
 	}
 
-	return p.db.Set(notificationKey(bucket), data, pebble.Sync)
+	return p.set(notificationKey(bucket), data)
 }
 
 // GetBucketNotification gets bucket notification configuration
@@ -1260,7 +2000,7 @@ func (p *PebbleStore) DeleteBucketNotification(ctx context.Context, bucket strin
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(notificationKey(bucket), pebble.Sync)
+	return p.delete(notificationKey(bucket))
 }
 
 // loggingKey generates a logging configuration key
@@ -1278,7 +2018,7 @@ func (p *PebbleStore) PutBucketLogging(ctx context.Context, bucket string, confi
 		return err
 	}
 
-	return p.db.Set(loggingKey(bucket), data, pebble.Sync)
+	return p.set(loggingKey(bucket), data)
 }
 
 // GetBucketLogging gets bucket logging configuration
@@ -1308,7 +2048,7 @@ func (p *PebbleStore) DeleteBucketLogging(ctx context.Context, bucket string) er
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(loggingKey(bucket), pebble.Sync)
+	return p.delete(loggingKey(bucket))
 }
 
 // locationKey generates a location configuration key
@@ -1321,7 +2061,7 @@ func (p *PebbleStore) PutBucketLocation(ctx context.Context, bucket string, loca
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Set(locationKey(bucket), []byte(location), pebble.Sync)
+	return p.set(locationKey(bucket), []byte(location))
 }
 
 // GetBucketLocation retrieves bucket location
@@ -1356,7 +2096,7 @@ func (p *PebbleStore) PutBucketOwnershipControls(ctx context.Context, bucket str
 		return err
 	}
 
-	return p.db.Set(ownershipKey(bucket), data, pebble.Sync)
+	return p.set(ownershipKey(bucket), data)
 }
 
 // GetBucketOwnershipControls retrieves bucket ownership controls
@@ -1386,7 +2126,7 @@ func (p *PebbleStore) DeleteBucketOwnershipControls(ctx context.Context, bucket
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(ownershipKey(bucket), pebble.Sync)
+	return p.delete(ownershipKey(bucket))
 }
 
 // metricsKey generates a metrics configuration key
@@ -1409,7 +2149,7 @@ func (p *PebbleStore) PutBucketMetrics(ctx context.Context, bucket string, id st
 		return err
 	}
 
-	return p.db.Set(metricsKey(bucket, id), data, pebble.Sync)
+	return p.set(metricsKey(bucket, id), data)
 }
 
 // GetBucketMetrics retrieves bucket metrics configuration
@@ -1439,7 +2179,7 @@ func (p *PebbleStore) DeleteBucketMetrics(ctx context.Context, bucket string, id
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return p.db.Delete(metricsKey(bucket, id), pebble.Sync)
+	return p.delete(metricsKey(bucket, id))
 }
 
 // ListBucketMetrics lists all metrics configurations for a bucket
@@ -1450,20 +2190,15 @@ func (p *PebbleStore) ListBucketMetrics(ctx context.Context, bucket string) ([]m
 	prefix := []byte("metrics:" + bucket + ":")
 	var configs []metadata.MetricsConfiguration
 
-	iter, err := p.db.NewIter(nil)
+	iter, err := p.db.NewIter(prefixIterOptions(prefix))
 	if err != nil {
 		return nil, err
 	}
 	defer iter.Close()
 
-	for iter.SeekGE(prefix); iter.Valid(); iter.Next() {
-		key := iter.Key()
-		if !bytes.HasPrefix(key, prefix) {
-			break
-		}
-
+	for iter.First(); iter.Valid(); iter.Next() {
 		// Skip the list key
-		if bytes.HasSuffix(key, []byte(":list")) {
+		if bytes.HasSuffix(iter.Key(), []byte(":list")) {
 			continue
 		}
 
@@ -1477,11 +2212,103 @@ func (p *PebbleStore) ListBucketMetrics(ctx context.Context, bucket string) ([]m
 	return configs, nil
 }
 
+// iamKey returns the key for an IAM entity
+func iamKey(kind, id string) []byte {
+	return []byte("iam:" + kind + "/" + id)
+}
+
+// PutIAMEntity stores an IAM entity
+func (p *PebbleStore) PutIAMEntity(ctx context.Context, kind, id string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.set(iamKey(kind, id), data)
+}
+
+// DeleteIAMEntity deletes an IAM entity
+func (p *PebbleStore) DeleteIAMEntity(ctx context.Context, kind, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.delete(iamKey(kind, id))
+}
+
+// ListIAMEntities lists the IAM entities of a kind
+func (p *PebbleStore) ListIAMEntities(ctx context.Context, kind string) ([][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	iter, err := p.db.NewIter(prefixIterOptions(iamKey(kind, "")))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var entities [][]byte
+	for iter.First(); iter.Valid(); iter.Next() {
+		entities = append(entities, append([]byte(nil), iter.Value()...))
+	}
+	return entities, iter.Error()
+}
+
+// Compact compacts every key in the database, dropping deleted entries
+// and the space they hold. Reads and writes go on while it runs.
+func (p *PebbleStore) Compact(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	iter, err := p.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	var start, end []byte
+	if iter.First() {
+		start = append([]byte(nil), iter.Key()...)
+	}
+	if iter.Last() {
+		// Compact's end is exclusive
+		end = append(append([]byte(nil), iter.Key()...), 0)
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if start == nil {
+		return nil
+	}
+
+	return metadata.WrapUnwritable(p.db.Compact(start, end, true), pebble.ErrReadOnly)
+}
+
+// Stats returns the database's size on disk and table counts
+func (p *PebbleStore) Stats() (*metadata.Stats, error) {
+	m := p.db.Metrics()
+	total := m.Total()
+	return &metadata.Stats{
+		DiskBytes:   int64(m.DiskSpaceUsage()),
+		LiveBytes:   total.Size,
+		Tables:      total.NumFiles,
+		Tombstones:  int64(m.Keys.TombstoneCount),
+		Compactions: m.Compact.Count,
+	}, nil
+}
+
 // Close closes the store
 func (p *PebbleStore) Close() error {
 	return p.db.Close()
 }
 
+// set durably writes a key, marking failures that mean the database cannot
+// accept writes at all
+func (p *PebbleStore) set(key, value []byte) error {
+	return metadata.WrapUnwritable(p.db.Set(key, value, pebble.Sync), pebble.ErrReadOnly)
+}
+
+// delete durably removes a key, marking failures like set does
+func (p *PebbleStore) delete(key []byte) error {
+	return metadata.WrapUnwritable(p.db.Delete(key, pebble.Sync), pebble.ErrReadOnly)
+}
+
 // encodeMeta encodes metadata to bytes using Gob
 func encodeMeta(v interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
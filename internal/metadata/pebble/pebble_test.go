@@ -3,12 +3,17 @@ package pebble
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/metadatatest"
 )
 
 func TestNew(t *testing.T) {
@@ -651,6 +656,52 @@ func TestBucketTags(t *testing.T) {
 	}
 }
 
+func TestObjectTags(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	retrieved, err := store.GetObjectTags(ctx, "test-bucket", "a.txt", "")
+	if err != nil || retrieved != nil {
+		t.Fatalf("GetObjectTags() before put = %v, %v, want nil", retrieved, err)
+	}
+
+	if err := store.PutObjectTags(ctx, "test-bucket", "a.txt", "", map[string]string{"env": "test"}); err != nil {
+		t.Fatalf("PutObjectTags() error: %v", err)
+	}
+	if err := store.PutObjectTags(ctx, "test-bucket", "b.txt", "", map[string]string{"env": "prod"}); err != nil {
+		t.Fatalf("PutObjectTags() error: %v", err)
+	}
+
+	retrieved, err = store.GetObjectTags(ctx, "test-bucket", "a.txt", "")
+	if err != nil {
+		t.Fatalf("GetObjectTags() error: %v", err)
+	}
+	if len(retrieved) != 1 || retrieved["env"] != "test" {
+		t.Errorf("GetObjectTags() = %v, want env=test", retrieved)
+	}
+
+	if err := store.DeleteObjectTags(ctx, "test-bucket", "a.txt", ""); err != nil {
+		t.Fatalf("DeleteObjectTags() error: %v", err)
+	}
+	if retrieved, _ := store.GetObjectTags(ctx, "test-bucket", "a.txt", ""); retrieved != nil {
+		t.Errorf("GetObjectTags() after delete = %v, want nil", retrieved)
+	}
+	if retrieved, _ := store.GetObjectTags(ctx, "test-bucket", "b.txt", ""); retrieved["env"] != "prod" {
+		t.Errorf("GetObjectTags(b.txt) = %v, want env=prod", retrieved)
+	}
+}
+
 func TestReplicationConfig(t *testing.T) {
 	dir, err := os.MkdirTemp("", "pebble-test-*")
 	if err != nil {
@@ -740,12 +791,12 @@ func TestObjectRetention(t *testing.T) {
 	ctx := context.Background()
 
 	retention := &metadata.ObjectRetention{Mode: "GOVERNANCE"}
-	err = store.PutObjectRetention(ctx, "test-bucket", "test-key", retention)
+	err = store.PutObjectRetention(ctx, "test-bucket", "test-key", "", retention)
 	if err != nil {
 		t.Fatalf("PutObjectRetention() error: %v", err)
 	}
 
-	retrieved, err := store.GetObjectRetention(ctx, "test-bucket", "test-key")
+	retrieved, err := store.GetObjectRetention(ctx, "test-bucket", "test-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectRetention() error: %v", err)
 	}
@@ -770,18 +821,32 @@ func TestObjectLegalHold(t *testing.T) {
 	ctx := context.Background()
 
 	legalHold := &metadata.ObjectLegalHold{Status: "ON"}
-	err = store.PutObjectLegalHold(ctx, "test-bucket", "test-key", legalHold)
+	err = store.PutObjectLegalHold(ctx, "test-bucket", "test-key", "", legalHold)
 	if err != nil {
 		t.Fatalf("PutObjectLegalHold() error: %v", err)
 	}
 
-	retrieved, err := store.GetObjectLegalHold(ctx, "test-bucket", "test-key")
+	retrieved, err := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "")
 	if err != nil {
 		t.Fatalf("GetObjectLegalHold() error: %v", err)
 	}
 	if retrieved.Status != "ON" {
 		t.Errorf("Status = %s, want ON", retrieved.Status)
 	}
+
+	// Each version keeps its own hold, apart from the object's
+	if err := store.PutObjectLegalHold(ctx, "test-bucket", "test-key", "v1", &metadata.ObjectLegalHold{Status: "OFF"}); err != nil {
+		t.Fatalf("PutObjectLegalHold(v1) error: %v", err)
+	}
+	if hold, _ := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "v1"); hold == nil || hold.Status != "OFF" {
+		t.Errorf("GetObjectLegalHold(v1) = %+v, want OFF", hold)
+	}
+	if hold, _ := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "v2"); hold != nil {
+		t.Errorf("GetObjectLegalHold(v2) = %+v, want nil", hold)
+	}
+	if hold, _ := store.GetObjectLegalHold(ctx, "test-bucket", "test-key", ""); hold == nil || hold.Status != "ON" {
+		t.Errorf("GetObjectLegalHold() after versioned put = %+v, want ON", hold)
+	}
 }
 
 func TestPublicAccessBlock(t *testing.T) {
@@ -2403,7 +2468,7 @@ func TestGetObjectRetention_NotFound(t *testing.T) {
 	ctx := context.Background()
 
 	// Get retention for non-existent object
-	retention, err := store.GetObjectRetention(ctx, "test-bucket", "nonexistent-object")
+	retention, err := store.GetObjectRetention(ctx, "test-bucket", "nonexistent-object", "")
 	if err != nil {
 		t.Errorf("GetObjectRetention() unexpected error: %v", err)
 	}
@@ -2428,7 +2493,7 @@ func TestGetObjectLegalHold_NotFound(t *testing.T) {
 	ctx := context.Background()
 
 	// Get legal hold for non-existent object
-	legalHold, err := store.GetObjectLegalHold(ctx, "test-bucket", "nonexistent-object")
+	legalHold, err := store.GetObjectLegalHold(ctx, "test-bucket", "nonexistent-object", "")
 	if err != nil {
 		t.Errorf("GetObjectLegalHold() unexpected error: %v", err)
 	}
@@ -3128,6 +3193,63 @@ func TestListObjectsWithMaxKeys(t *testing.T) {
 	}
 }
 
+func TestListObjectsWithMarker(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("logs/%02d", i)
+		_ = store.PutObject(ctx, "test-bucket", key, &metadata.ObjectMetadata{Key: key, Bucket: "test-bucket"})
+	}
+	_ = store.PutObject(ctx, "test-bucket", "other", &metadata.ObjectMetadata{Key: "other", Bucket: "test-bucket"})
+
+	// Paging with the last key of each page as the next marker visits every
+	// key under the prefix once and stops at the end of the prefix
+	var keys []string
+	marker := ""
+	for {
+		objects, err := store.ListObjects(ctx, "test-bucket", "logs/", metadata.ListOptions{MaxKeys: 4, Marker: marker})
+		if err != nil {
+			t.Fatalf("ListObjects() error: %v", err)
+		}
+		if len(objects) == 0 {
+			break
+		}
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+		marker = objects[len(objects)-1].Key
+	}
+	if len(keys) != 10 {
+		t.Fatalf("paged ListObjects() returned %d keys, want 10: %v", len(keys), keys)
+	}
+	for i, key := range keys {
+		if want := fmt.Sprintf("logs/%02d", i); key != want {
+			t.Errorf("key %d = %q, want %q", i, key, want)
+		}
+	}
+
+	// A marker before the prefix lists from the start of the prefix
+	objects, err := store.ListObjects(ctx, "test-bucket", "logs/", metadata.ListOptions{Marker: "a"})
+	if err != nil {
+		t.Fatalf("ListObjects() error: %v", err)
+	}
+	if len(objects) != 10 {
+		t.Errorf("ListObjects(marker before prefix) returned %d objects, want 10", len(objects))
+	}
+}
+
 func TestListObjectsWithInvalidData(t *testing.T) {
 	dir, err := os.MkdirTemp("", "pebble-test-*")
 	if err != nil {
@@ -3374,7 +3496,7 @@ func TestGetObjectRetentionOtherError(t *testing.T) {
 
 	store.db.Set(retentionKey("test-bucket", "test-key"), []byte("invalid-gob-data"), pebble.Sync)
 
-	_, err = store.GetObjectRetention(ctx, "test-bucket", "test-key")
+	_, err = store.GetObjectRetention(ctx, "test-bucket", "test-key", "")
 	if err == nil {
 		t.Error("GetObjectRetention() expected error for invalid data")
 	}
@@ -3397,7 +3519,7 @@ func TestGetObjectLegalHoldOtherError(t *testing.T) {
 
 	store.db.Set(legalHoldKey("test-bucket", "test-key"), []byte("invalid-gob-data"), pebble.Sync)
 
-	_, err = store.GetObjectLegalHold(ctx, "test-bucket", "test-key")
+	_, err = store.GetObjectLegalHold(ctx, "test-bucket", "test-key", "")
 	if err == nil {
 		t.Error("GetObjectLegalHold() expected error for invalid data")
 	}
@@ -3882,3 +4004,691 @@ func TestListObjectsBucketMismatchContinue(t *testing.T) {
 		}
 	}
 }
+
+func TestMoveObject(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	store.PutObject(ctx, "bucket", "old", &metadata.ObjectMetadata{Key: "old", Bucket: "bucket", Size: 7})
+
+	if err := store.MoveObject(ctx, "bucket", "old", "bucket", "new"); err != nil {
+		t.Fatalf("MoveObject() error: %v", err)
+	}
+
+	if _, err := store.GetObject(ctx, "bucket", "old", ""); err == nil {
+		t.Error("old key should be gone after move")
+	}
+	meta, err := store.GetObject(ctx, "bucket", "new", "")
+	if err != nil {
+		t.Fatalf("GetObject(new) error: %v", err)
+	}
+	if meta.Key != "new" || meta.Size != 7 {
+		t.Errorf("moved metadata = %+v, want key new size 7", meta)
+	}
+
+	if err := store.MoveObject(ctx, "bucket", "missing", "bucket", "other"); err == nil {
+		t.Error("MoveObject() of a missing key should fail")
+	}
+}
+
+func TestMoveObject_ConcurrentReaderSeesOneKey(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	store.PutObject(ctx, "bucket", "a", &metadata.ObjectMetadata{Key: "a", Bucket: "bucket"})
+
+	exists := func(r pebble.Reader, key string) bool {
+		_, closer, err := r.Get(objectKey("bucket", key))
+		if err != nil {
+			return false
+		}
+		closer.Close()
+		return true
+	}
+
+	done := make(chan struct{})
+	errCh := make(chan string, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				close(errCh)
+				return
+			default:
+			}
+			snap := store.db.NewSnapshot()
+			a, b := exists(snap, "a"), exists(snap, "b")
+			snap.Close()
+			if a == b {
+				errCh <- fmt.Sprintf("reader saw a=%v b=%v", a, b)
+				return
+			}
+		}
+	}()
+
+	src, dst := "a", "b"
+	for i := 0; i < 200; i++ {
+		if err := store.MoveObject(ctx, "bucket", src, "bucket", dst); err != nil {
+			t.Fatalf("MoveObject() error: %v", err)
+		}
+		src, dst = dst, src
+	}
+	close(done)
+
+	if msg, ok := <-errCh; ok {
+		t.Error(msg)
+	}
+}
+
+func TestListPartsNumericOrder(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestListPartsNumericOrder(t, store)
+}
+
+func TestBucketMode(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestBucketMode(t, store)
+}
+
+func TestACL(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestACL(t, store)
+}
+
+func TestListObjectsFunc(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestListObjectsFunc(t, store)
+}
+
+func TestListObjectsFuncBoundedMemory(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// 50000 objects carrying 1KB of user metadata each come to well over
+	// 50MB once decoded, far more than a page of them
+	const count = 50000
+	userMeta := map[string]string{"note": strings.Repeat("x", 1024)}
+	batch := store.db.NewBatch()
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("obj-%06d", i)
+		data, err := encodeMeta(&metadata.ObjectMetadata{Key: key, Bucket: "bucket", Size: 1, Metadata: userMeta})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := batch.Set(objectKey("bucket", key), data, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		t.Fatal(err)
+	}
+	batch.Close()
+
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	baseline := stats.HeapAlloc
+
+	const limit = 16 << 20
+	var listed int
+	var peak uint64
+	err = store.ListObjectsFunc(context.Background(), "bucket", "", "", func(meta metadata.ObjectMetadata) error {
+		listed++
+		if listed%metadata.ListPageSize == 0 {
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > baseline && stats.HeapAlloc-baseline > peak {
+				peak = stats.HeapAlloc - baseline
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListObjectsFunc() error: %v", err)
+	}
+	if listed != count {
+		t.Fatalf("ListObjectsFunc() listed %d objects, expected %d", listed, count)
+	}
+	if peak > limit {
+		t.Errorf("heap grew by %d bytes while listing, expected at most %d", peak, limit)
+	}
+}
+
+func TestMigratePartKeys(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Write parts the way stores did before part numbers were padded
+	db, err := pebble.Open(filepath.Join(dir, "metadata"), &pebble.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{10, 2, 1} {
+		data, _ := encodeMeta(&metadata.PartMetadata{PartNumber: n})
+		key := fmt.Sprintf("part:test-bucket/test-key/legacy-upload/%d", n)
+		if err := db.Set([]byte(key), data, pebble.Sync); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	store, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestMigratedPartKeys(t, store)
+}
+
+func TestMoveObjectAssociations(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestMoveObjectAssociations(t, store)
+}
+
+func TestBucketUsage(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	metadatatest.TestBucketUsage(t, store)
+}
+
+func TestReadOnlyWritesAreUnwritable(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	db, err := pebble.Open(dir+"/metadata", &pebble.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := &PebbleStore{db: db, rootDir: dir}
+	defer readOnly.Close()
+
+	if err := readOnly.CreateBucket(context.Background(), "test-bucket"); !metadata.IsUnwritable(err) {
+		t.Errorf("CreateBucket() on read-only database error = %v, want unwritable", err)
+	}
+}
+
+func TestObjectVersions(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+
+	put := func(versionID string, modified int64, marker bool) {
+		t.Helper()
+		meta := &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket", VersionID: versionID, LastModified: modified, IsDeleteMarker: marker}
+		if err := store.PutObject(ctx, "test-bucket", "k", meta); err != nil {
+			t.Fatalf("PutObject(%s) error: %v", versionID, err)
+		}
+	}
+	latest := func() string {
+		t.Helper()
+		meta, err := store.GetObject(ctx, "test-bucket", "k", "")
+		if err != nil {
+			return ""
+		}
+		return meta.VersionID
+	}
+
+	// Written before versioning, kept as a version once it is turned on
+	put("v0", 100, false)
+	_ = store.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+	put("v1", 200, false)
+	put("v2", 200, false)
+	if got := latest(); got != "v2" {
+		t.Fatalf("latest version = %q, expected v2", got)
+	}
+	for _, v := range []string{"v0", "v1", "v2"} {
+		if meta, err := store.GetObject(ctx, "test-bucket", "k", v); err != nil || meta.VersionID != v {
+			t.Errorf("GetObject(%s) = %+v, %v", v, meta, err)
+		}
+	}
+
+	put("dm", 300, true)
+	if got := latest(); got != "" {
+		t.Errorf("latest version behind a delete marker = %q, expected not found", got)
+	}
+	if meta, err := store.GetObject(ctx, "test-bucket", "k", "dm"); err != nil || !meta.IsDeleteMarker {
+		t.Errorf("GetObject(dm) = %+v, %v, expected the delete marker", meta, err)
+	}
+	if versions, _ := store.ListObjectVersions(ctx, "test-bucket", ""); len(versions) != 4 {
+		t.Errorf("ListObjectVersions() returned %d versions, expected 4: %+v", len(versions), versions)
+	}
+
+	// Removing the newest version brings back the next newest
+	steps := []struct{ remove, latest string }{
+		{"dm", "v2"},
+		{"v0", "v2"},
+		{"v2", "v1"},
+		{"v1", ""},
+	}
+	for _, step := range steps {
+		if err := store.DeleteObject(ctx, "test-bucket", "k", step.remove); err != nil {
+			t.Fatalf("DeleteObject(%s) error: %v", step.remove, err)
+		}
+		if got := latest(); got != step.latest {
+			t.Errorf("latest version after removing %s = %q, expected %q", step.remove, got, step.latest)
+		}
+	}
+
+	// Suspended versioning replaces the null version each time
+	_ = store.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Suspended"})
+	put("null", 400, false)
+	put("null", 500, false)
+	versions, _ := store.ListObjectVersions(ctx, "test-bucket", "")
+	if len(versions) != 1 || versions[0].LastModified != 500 {
+		t.Errorf("ListObjectVersions() after suspended writes = %+v, expected the one null version", versions)
+	}
+}
+
+func TestUpdateObjectVersion(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+	_ = store.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+	for _, v := range []string{"v1", "v2"} {
+		_ = store.PutObject(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket", VersionID: v})
+	}
+
+	// Updating a noncurrent version leaves the latest one alone
+	if err := store.UpdateObjectVersion(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket", VersionID: "v1", StorageClass: "GLACIER"}); err != nil {
+		t.Fatalf("UpdateObjectVersion(v1) error: %v", err)
+	}
+	if meta, _ := store.GetObject(ctx, "test-bucket", "k", ""); meta == nil || meta.VersionID != "v2" || meta.StorageClass != "" {
+		t.Errorf("latest after updating v1 = %+v, expected v2 unchanged", meta)
+	}
+	if meta, _ := store.GetObject(ctx, "test-bucket", "k", "v1"); meta == nil || meta.StorageClass != "GLACIER" {
+		t.Errorf("GetObject(v1) = %+v, expected GLACIER", meta)
+	}
+
+	// Updating the latest version updates both of its copies
+	if err := store.UpdateObjectVersion(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket", VersionID: "v2", StorageClass: "STANDARD_IA"}); err != nil {
+		t.Fatalf("UpdateObjectVersion(v2) error: %v", err)
+	}
+	for _, v := range []string{"", "v2"} {
+		if meta, _ := store.GetObject(ctx, "test-bucket", "k", v); meta == nil || meta.StorageClass != "STANDARD_IA" {
+			t.Errorf("GetObject(%q) = %+v, expected STANDARD_IA", v, meta)
+		}
+	}
+
+	if err := store.UpdateObjectVersion(ctx, "test-bucket", "k", &metadata.ObjectMetadata{VersionID: "missing"}); !errors.Is(err, metadata.ErrObjectNotFound) {
+		t.Errorf("UpdateObjectVersion(missing) error = %v, expected ErrObjectNotFound", err)
+	}
+}
+
+func TestBucketIsEmpty(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+	_ = store.CreateBucket(ctx, "test-bucket-2")
+
+	isEmpty := func() bool {
+		t.Helper()
+		empty, err := store.BucketIsEmpty(ctx, "test-bucket")
+		if err != nil {
+			t.Fatalf("BucketIsEmpty() error: %v", err)
+		}
+		return empty
+	}
+
+	// A bucket whose name extends this one's does not count
+	_ = store.PutObject(ctx, "test-bucket-2", "k", &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket-2"})
+	if !isEmpty() {
+		t.Error("BucketIsEmpty() = false for a bucket with no objects")
+	}
+
+	_ = store.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+	_ = store.PutObject(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket", VersionID: "v1"})
+	if isEmpty() {
+		t.Error("BucketIsEmpty() = true for a bucket with an object")
+	}
+
+	// Behind a delete marker only version entries remain
+	_ = store.PutObject(ctx, "test-bucket", "k", &metadata.ObjectMetadata{Key: "k", Bucket: "test-bucket", VersionID: "dm", IsDeleteMarker: true})
+	if isEmpty() {
+		t.Error("BucketIsEmpty() = true for a bucket with versions")
+	}
+
+	_ = store.DeleteObject(ctx, "test-bucket", "k", "dm")
+	_ = store.DeleteObject(ctx, "test-bucket", "k", "v1")
+	if !isEmpty() {
+		t.Error("BucketIsEmpty() = false after every version was removed")
+	}
+}
+
+func TestPutObjects(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.CreateBucket(ctx, "test-bucket")
+	_ = store.PutBucketVersioning(ctx, "test-bucket", &metadata.BucketVersioning{Status: "Enabled"})
+
+	// Later writes in a batch see earlier ones, so both versions are kept
+	writes := []metadata.ObjectWrite{
+		{Bucket: "test-bucket", Key: "k", Meta: &metadata.ObjectMetadata{Key: "k", VersionID: "v1", LastModified: 100}},
+		{Bucket: "test-bucket", Key: "k", Meta: &metadata.ObjectMetadata{Key: "k", VersionID: "v2", LastModified: 200}},
+		{Bucket: "test-bucket", Key: "other", Meta: &metadata.ObjectMetadata{Key: "other"}},
+	}
+	if err := store.PutObjects(ctx, writes); err != nil {
+		t.Fatalf("PutObjects() error: %v", err)
+	}
+
+	if meta, err := store.GetObject(ctx, "test-bucket", "k", ""); err != nil || meta.VersionID != "v2" {
+		t.Errorf("GetObject(k) = %+v, %v, expected v2", meta, err)
+	}
+	if meta, err := store.GetObject(ctx, "test-bucket", "k", "v1"); err != nil || meta.VersionID != "v1" {
+		t.Errorf("GetObject(k, v1) = %+v, %v", meta, err)
+	}
+	if _, err := store.GetObject(ctx, "test-bucket", "other", ""); err != nil {
+		t.Errorf("GetObject(other) error: %v", err)
+	}
+}
+
+func TestCompactAndStats(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// An empty database has nothing to compact
+	if err := store.Compact(ctx); err != nil {
+		t.Fatalf("Compact() of an empty store error: %v", err)
+	}
+
+	_ = store.CreateBucket(ctx, "test-bucket")
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		_ = store.PutObject(ctx, "test-bucket", key, &metadata.ObjectMetadata{Key: key})
+	}
+	for i := 0; i < 100; i += 2 {
+		_ = store.DeleteObject(ctx, "test-bucket", fmt.Sprintf("key-%03d", i), "")
+	}
+
+	if err := store.Compact(ctx); err != nil {
+		t.Fatalf("Compact() error: %v", err)
+	}
+	stats, err := store.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error: %v", err)
+	}
+	if stats.Tables == 0 || stats.LiveBytes <= 0 || stats.DiskBytes < stats.LiveBytes {
+		t.Errorf("Stats() after compaction = %+v", stats)
+	}
+	if stats.Compactions == 0 {
+		t.Errorf("Stats().Compactions = 0 after Compact()")
+	}
+
+	objects, _ := store.ListObjects(ctx, "test-bucket", "", metadata.ListOptions{})
+	if len(objects) != 50 {
+		t.Errorf("ListObjects() after compaction returned %d objects, expected 50", len(objects))
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := store.Compact(cancelled); err == nil {
+		t.Error("Compact() with a cancelled context should fail")
+	}
+}
+
+func TestPrefixUpperBound(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []byte
+	}{
+		{"object:b/", []byte("object:b0")},
+		{"part:", []byte("part;")},
+		{"a\xff", []byte("b")},
+		{"\xff\xff", nil},
+	}
+	for _, tt := range tests {
+		if got := prefixUpperBound([]byte(tt.prefix)); !bytes.Equal(got, tt.want) {
+			t.Errorf("prefixUpperBound(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestListsStayWithinPrefix(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Each bucket's keys sort right next to the other's
+	for _, bucket := range []string{"b", "b-2", "c"} {
+		_ = store.CreateBucket(ctx, bucket)
+		_ = store.PutObject(ctx, bucket, "docs/a", &metadata.ObjectMetadata{Key: "docs/a"})
+		_ = store.PutObject(ctx, bucket, "img/a", &metadata.ObjectMetadata{Key: "img/a"})
+		_ = store.CreateMultipartUpload(ctx, bucket, "docs/up", "u1", &metadata.ObjectMetadata{})
+		_ = store.CreateMultipartUpload(ctx, bucket, "img/up", "u2", &metadata.ObjectMetadata{})
+		_ = store.PutPart(ctx, bucket, "docs/up", "u1", 1, &metadata.PartMetadata{PartNumber: 1})
+		_ = store.PutBucketInventory(ctx, bucket, "inv", &metadata.InventoryConfiguration{ID: "inv"})
+		_ = store.PutBucketAnalytics(ctx, bucket, "an", &metadata.AnalyticsConfiguration{ID: "an"})
+	}
+
+	if buckets, _ := store.ListBuckets(ctx); len(buckets) != 3 {
+		t.Errorf("ListBuckets() = %v, expected 3 buckets", buckets)
+	}
+	if objects, _ := store.ListObjects(ctx, "b", "docs/", metadata.ListOptions{}); len(objects) != 1 || objects[0].Key != "docs/a" {
+		t.Errorf("ListObjects(b, docs/) = %+v, expected docs/a only", objects)
+	}
+	if uploads, _ := store.ListMultipartUploads(ctx, "b", ""); len(uploads) != 2 {
+		t.Errorf("ListMultipartUploads(b) returned %d uploads, expected 2", len(uploads))
+	}
+	if uploads, _ := store.ListMultipartUploads(ctx, "b", "img/"); len(uploads) != 1 || uploads[0].Key != "img/up" {
+		t.Errorf("ListMultipartUploads(b, img/) = %+v, expected img/up only", uploads)
+	}
+	if parts, _ := store.ListParts(ctx, "b", "docs/up", "u1"); len(parts) != 1 {
+		t.Errorf("ListParts() returned %d parts, expected 1", len(parts))
+	}
+	if configs, _ := store.ListBucketInventory(ctx, "b"); len(configs) != 1 {
+		t.Errorf("ListBucketInventory(b) returned %d configurations, expected 1", len(configs))
+	}
+	if configs, _ := store.ListBucketAnalytics(ctx, "b"); len(configs) != 1 {
+		t.Errorf("ListBucketAnalytics(b) returned %d configurations, expected 1", len(configs))
+	}
+}
+
+func TestIAMEntities(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pebble-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.PutIAMEntity(ctx, "user", "u1", []byte(`{"id":"u1"}`))
+	_ = store.PutIAMEntity(ctx, "user", "u2", []byte(`{"id":"u2"}`))
+	_ = store.PutIAMEntity(ctx, "users", "u3", []byte(`{"id":"u3"}`))
+	_ = store.PutIAMEntity(ctx, "group", "g1", []byte(`{"id":"g1"}`))
+
+	users, err := store.ListIAMEntities(ctx, "user")
+	if err != nil || len(users) != 2 {
+		t.Fatalf("ListIAMEntities(user) = %q, %v, expected 2 users", users, err)
+	}
+
+	if err := store.DeleteIAMEntity(ctx, "user", "u1"); err != nil {
+		t.Fatalf("DeleteIAMEntity() error: %v", err)
+	}
+	users, _ = store.ListIAMEntities(ctx, "user")
+	if len(users) != 1 || string(users[0]) != `{"id":"u2"}` {
+		t.Errorf("ListIAMEntities(user) after delete = %q", users)
+	}
+}
@@ -35,6 +35,27 @@ type Store interface {
 	GetObject(ctx context.Context, bucket, key string, versionID string) (*ObjectMetadata, error)
 	DeleteObject(ctx context.Context, bucket, key string, versionID string) error
 	ListObjects(ctx context.Context, bucket, prefix string, opts ListOptions) ([]ObjectMetadata, error)
+	// ListObjectsFunc calls fn for each object under prefix with a key after
+	// marker, in key order. It reads ListPageSize entries at a time so memory
+	// stays bounded however many objects match, and the store is not locked
+	// while fn runs. An error from fn stops the listing and is returned.
+	ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(ObjectMetadata) error) error
+	// ListObjectVersions lists every stored version and delete marker of the
+	// objects under prefix, in no particular order
+	ListObjectVersions(ctx context.Context, bucket, prefix string) ([]ObjectMetadata, error)
+	// BucketIsEmpty reports whether no object, version or delete marker is
+	// stored in the bucket. It reads at most one entry, however large the
+	// bucket is.
+	BucketIsEmpty(ctx context.Context, bucket string) (bool, error)
+	// MoveObject repoints object metadata and the object's tags, ACL, retention
+	// and legal hold to a new bucket/key in one atomic write. It fails with
+	// ErrObjectExists rather than overwrite an object at the destination.
+	MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	// UpdateObjectVersion rewrites the stored metadata of version
+	// meta.VersionID in place, or of the unversioned object when that is
+	// empty, without changing which version is the latest. It fails with
+	// ErrObjectNotFound when no such version is stored.
+	UpdateObjectVersion(ctx context.Context, bucket, key string, meta *ObjectMetadata) error
 
 	// Multipart upload operations
 	CreateMultipartUpload(ctx context.Context, bucket, key, uploadID string, meta *ObjectMetadata) error
@@ -78,24 +99,40 @@ type Store interface {
 	GetBucketTags(ctx context.Context, bucket string) (map[string]string, error)
 	DeleteBucketTags(ctx context.Context, bucket string) error
 
+	// Object tagging operations. These and the retention and legal hold
+	// operations keep one entry per version of an object; an empty
+	// versionID addresses the object's own entry.
+	PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error
+	GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error)
+	DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error
+
 	// Object Lock operations
 	PutObjectLock(ctx context.Context, bucket string, config *ObjectLockConfig) error
 	GetObjectLock(ctx context.Context, bucket string) (*ObjectLockConfig, error)
 	DeleteObjectLock(ctx context.Context, bucket string) error
 
 	// Object Retention operations
-	PutObjectRetention(ctx context.Context, bucket, key string, retention *ObjectRetention) error
-	GetObjectRetention(ctx context.Context, bucket, key string) (*ObjectRetention, error)
+	PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *ObjectRetention) error
+	GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*ObjectRetention, error)
 
 	// Object Legal Hold operations
-	PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *ObjectLegalHold) error
-	GetObjectLegalHold(ctx context.Context, bucket, key string) (*ObjectLegalHold, error)
+	PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *ObjectLegalHold) error
+	GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*ObjectLegalHold, error)
 
 	// PublicAccessBlock operations
 	PutPublicAccessBlock(ctx context.Context, bucket string, config *PublicAccessBlockConfiguration) error
 	GetPublicAccessBlock(ctx context.Context, bucket string) (*PublicAccessBlockConfiguration, error)
 	DeletePublicAccessBlock(ctx context.Context, bucket string) error
 
+	// ACL operations. A bucket or object without a stored ACL has the
+	// default one, which grants its owner full control.
+	PutBucketACL(ctx context.Context, bucket string, acl *AccessControlList) error
+	GetBucketACL(ctx context.Context, bucket string) (*AccessControlList, error)
+	DeleteBucketACL(ctx context.Context, bucket string) error
+	PutObjectACL(ctx context.Context, bucket, key string, acl *AccessControlList) error
+	GetObjectACL(ctx context.Context, bucket, key string) (*AccessControlList, error)
+	DeleteObjectACL(ctx context.Context, bucket, key string) error
+
 	// Accelerate operations
 	PutBucketAccelerate(ctx context.Context, bucket string, config *BucketAccelerateConfiguration) error
 	GetBucketAccelerate(ctx context.Context, bucket string) (*BucketAccelerateConfiguration, error)
@@ -148,10 +185,51 @@ type Store interface {
 	DeleteBucketMetrics(ctx context.Context, bucket string, id string) error
 	ListBucketMetrics(ctx context.Context, bucket string) ([]MetricsConfiguration, error)
 
+	// Key normalization operations
+	PutBucketKeyNormalization(ctx context.Context, bucket string, config *KeyNormalizationConfig) error
+	GetBucketKeyNormalization(ctx context.Context, bucket string) (*KeyNormalizationConfig, error)
+
+	// Bucket mode operations
+	PutBucketMode(ctx context.Context, bucket string, mode *BucketMode) error
+	GetBucketMode(ctx context.Context, bucket string) (*BucketMode, error)
+
+	// Usage operations
+	PutBucketUsage(ctx context.Context, bucket string, usage *BucketUsage) error
+	GetBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error)
+
+	// IAM operations
+	// IAM users, groups and policies are stored as opaque documents keyed
+	// by their kind and ID
+	PutIAMEntity(ctx context.Context, kind, id string, data []byte) error
+	DeleteIAMEntity(ctx context.Context, kind, id string) error
+	ListIAMEntities(ctx context.Context, kind string) ([][]byte, error)
+
+	// Maintenance operations
+	// Compact rewrites the store's files to drop deleted entries and reclaim
+	// their space. It can take a while on a large store.
+	Compact(ctx context.Context) error
+	Stats() (*Stats, error)
+
 	// Close closes the store
 	Close() error
 }
 
+// Stats describes the size of a metadata store on disk. Fields a store has
+// no notion of are left zero.
+type Stats struct {
+	// DiskBytes is the space the store takes on disk
+	DiskBytes int64 `json:"diskBytes"`
+	// LiveBytes is the size of the store's data files
+	LiveBytes int64 `json:"liveBytes"`
+	// Tables is the number of data files (SSTables)
+	Tables int64 `json:"tables"`
+	// Tombstones is the approximate number of deleted entries not yet
+	// compacted away
+	Tombstones int64 `json:"tombstones"`
+	// Compactions is the number of compactions run since the store opened
+	Compactions int64 `json:"compactions"`
+}
+
 // BucketMetadata contains bucket-level metadata
 type BucketMetadata struct {
 	Name          string    `json:"name"`
@@ -162,21 +240,50 @@ type BucketMetadata struct {
 
 // ObjectMetadata contains object-level metadata
 type ObjectMetadata struct {
-	Key             string            `json:"key"`
-	Bucket          string            `json:"bucket"`
-	Size            int64             `json:"size"`
-	ETag            string            `json:"etag"`
-	ContentType     string            `json:"content_type"`
-	ContentEncoding string            `json:"content_encoding"`
-	CacheControl    string            `json:"cache_control"`
-	Metadata        map[string]string `json:"metadata"`
-	StorageClass    string            `json:"storage_class"`
-	VersionID       string            `json:"version_id"`
-	IsLatest        bool              `json:"is_latest"`
-	IsDeleteMarker  bool              `json:"is_delete_marker"`
-	LastModified    int64             `json:"last_modified"`
-	Expires         int64             `json:"expires"`
-	Parts           []PartInfo        `json:"parts,omitempty"`
+	Key                string            `json:"key"`
+	Bucket             string            `json:"bucket"`
+	Size               int64             `json:"size"`
+	ETag               string            `json:"etag"`
+	ContentType        string            `json:"content_type"`
+	ContentEncoding    string            `json:"content_encoding"`
+	CacheControl       string            `json:"cache_control"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	Metadata           map[string]string `json:"metadata"`
+	StorageClass       string            `json:"storage_class"`
+	VersionID          string            `json:"version_id"`
+	IsLatest           bool              `json:"is_latest"`
+	IsDeleteMarker     bool              `json:"is_delete_marker"`
+	LastModified       int64             `json:"last_modified"`
+	Expires            int64             `json:"expires"`
+	Parts              []PartInfo        `json:"parts,omitempty"`
+	// RestoreExpiry is when the restored copy of an archived object stops
+	// being readable
+	RestoreExpiry int64 `json:"restore_expiry,omitempty"`
+	// RestoreReadyAt is when the restore of an archived object completes;
+	// until then the restore is ongoing and the object stays unreadable
+	RestoreReadyAt int64 `json:"restore_ready_at,omitempty"`
+	// ReplicationStatus is PENDING, COMPLETED or FAILED on an object being
+	// replicated, and REPLICA on a copy written by replication
+	ReplicationStatus string `json:"replication_status,omitempty"`
+	// SSEAlgorithm is the server-side encryption the data is stored with,
+	// AES256 or empty for none. SSEKey is the object's data key, encrypted
+	// with the server's master key, and SSENonce the nonce each segment's
+	// nonce is derived from.
+	SSEAlgorithm string `json:"sse_algorithm,omitempty"`
+	SSEKey       []byte `json:"sse_key,omitempty"`
+	SSENonce     []byte `json:"sse_nonce,omitempty"`
+	// Checksum is the additional checksum, such as a CRC32C or SHA-256, the
+	// object was uploaded with
+	Checksum *ObjectChecksum `json:"checksum,omitempty"`
+}
+
+// ObjectChecksum is an additional checksum of an object's data. Value is the
+// base64 digest; for a COMPOSITE checksum it is the digest of the parts'
+// digests followed by "-" and the part count.
+type ObjectChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
 }
 
 // PartInfo represents a part in a multipart upload
@@ -193,6 +300,7 @@ type PartMetadata struct {
 	Bucket       string `json:"bucket"`
 	PartNumber   int    `json:"part_number"`
 	ETag         string `json:"etag"`
+	MD5          string `json:"md5,omitempty"`
 	Size         int64  `json:"size"`
 	LastModified int64  `json:"last_modified"`
 }
@@ -204,16 +312,34 @@ type MultipartUploadMetadata struct {
 	Bucket   string            `json:"bucket"`
 	Initiated int64            `json:"initiated"`
 	Metadata map[string]string `json:"metadata"`
+	// StorageClass is applied to the object when the upload completes
+	StorageClass string `json:"storage_class,omitempty"`
+	// SSEAlgorithm is the server-side encryption requested for the object
+	SSEAlgorithm string `json:"sse_algorithm,omitempty"`
+	// Checksum is the checksum algorithm and type requested for the object;
+	// its Value is computed when the upload completes
+	Checksum *ObjectChecksum `json:"checksum,omitempty"`
 }
 
 // LifecycleRule defines a lifecycle rule
 type LifecycleRule struct {
-	ID         string     `json:"id"`
-	Prefix     string     `json:"prefix"`
-	Status     string     `json:"status"` // Enabled or Disabled
-	Expiration *Expiration `json:"expiration,omitempty"`
-	Transitions []Transition `json:"transitions,omitempty"`
-	NoncurrentVersionExpiration *NoncurrentVersionExpiration `json:"noncurrent_version_expiration,omitempty"`
+	ID                           string                        `json:"id"`
+	Prefix                       string                        `json:"prefix"`
+	Status                       string                        `json:"status"` // Enabled or Disabled
+	Expiration                   *Expiration                   `json:"expiration,omitempty"`
+	Transitions                  []Transition                  `json:"transitions,omitempty"`
+	NoncurrentVersionExpiration  *NoncurrentVersionExpiration  `json:"noncurrent_version_expiration,omitempty"`
+	NoncurrentVersionTransitions []NoncurrentVersionTransition `json:"noncurrent_version_transitions,omitempty"`
+	Filter                       *LifecycleFilter              `json:"filter,omitempty"`
+
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `json:"abort_incomplete_multipart_upload,omitempty"`
+}
+
+// LifecycleFilter narrows the objects a lifecycle rule applies to. An object
+// must be under Prefix and carry every one of Tags.
+type LifecycleFilter struct {
+	Prefix string            `json:"prefix,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
 }
 
 type Expiration struct {
@@ -232,6 +358,19 @@ type NoncurrentVersionExpiration struct {
 	NoncurrentDays int `json:"noncurrent_days"`
 }
 
+// AbortIncompleteMultipartUpload aborts multipart uploads still incomplete
+// DaysAfterInitiation days after they were started
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `json:"days_after_initiation"`
+}
+
+// NoncurrentVersionTransition moves versions to StorageClass once they have
+// been noncurrent for NoncurrentDays
+type NoncurrentVersionTransition struct {
+	NoncurrentDays int    `json:"noncurrent_days"`
+	StorageClass   string `json:"storage_class"`
+}
+
 // BucketEncryption contains bucket encryption configuration
 type BucketEncryption struct {
 	Rule        EncryptionRule `json:"Rule"`
@@ -272,11 +411,62 @@ type PublicAccessBlockConfiguration struct {
 	RestrictPublicBuckets bool `json:"RestrictPublicBuckets"`
 }
 
+// AccessControlList contains the owner of a bucket or object and the grants
+// made on it
+type AccessControlList struct {
+	Owner  ACLOwner   `json:"Owner"`
+	Grants []ACLGrant `json:"Grants"`
+}
+
+// ACLOwner identifies the owner of a bucket or object
+type ACLOwner struct {
+	ID          string `json:"ID"`
+	DisplayName string `json:"DisplayName,omitempty"`
+}
+
+// ACLGrant gives a grantee one permission: READ, WRITE, READ_ACP,
+// WRITE_ACP or FULL_CONTROL
+type ACLGrant struct {
+	Grantee    ACLGrantee `json:"Grantee"`
+	Permission string     `json:"Permission"`
+}
+
+// ACLGrantee is who receives a grant: a canonical user, identified by ID,
+// a user by email address, or a group, identified by URI
+type ACLGrantee struct {
+	Type         string `json:"Type"` // CanonicalUser, AmazonCustomerByEmail or Group
+	ID           string `json:"ID,omitempty"`
+	DisplayName  string `json:"DisplayName,omitempty"`
+	EmailAddress string `json:"EmailAddress,omitempty"`
+	URI          string `json:"URI,omitempty"`
+}
+
 // BucketAccelerateConfiguration contains bucket accelerate configuration
 type BucketAccelerateConfiguration struct {
 	Status string `json:"Status"` // Enabled or Suspended
 }
 
+// KeyNormalizationConfig controls how object keys in a bucket are normalized
+// before they are stored or looked up. The zero value keeps keys byte-exact,
+// as S3 does.
+type KeyNormalizationConfig struct {
+	CaseInsensitive bool `json:"case_insensitive"`
+	UnicodeNFC      bool `json:"unicode_nfc"`
+}
+
+// BucketMode restricts access to a bucket, for example during a migration.
+// An empty Mode leaves the bucket fully accessible.
+type BucketMode struct {
+	Mode string `json:"mode"` // read-write, read-only or maintenance
+}
+
+// BucketUsage holds the running usage totals of a bucket, kept so they
+// survive a restart without rescanning the bucket
+type BucketUsage struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
 // InventoryConfiguration contains bucket inventory configuration
 type InventoryConfiguration struct {
 	ID        string          `json:"Id"`
@@ -339,79 +529,107 @@ type AnalyticsBucketDestination struct {
 	Arn        string `json:"Arn"`
 }
 
-// WebsiteConfiguration contains bucket website configuration
+// WebsiteConfiguration contains bucket website configuration. The XML tags
+// follow the S3 WebsiteConfiguration document.
 type WebsiteConfiguration struct {
-	IndexDocument *IndexDocument `json:"IndexDocument,omitempty"`
-	ErrorDocument *ErrorDocument `json:"ErrorDocument,omitempty"`
-	RoutingRules  []RoutingRule `json:"RoutingRules,omitempty"`
+	XMLName               xml.Name               `xml:"WebsiteConfiguration" json:"-"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty" json:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty" json:"ErrorDocument,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty" json:"RedirectAllRequestsTo,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty" json:"RoutingRules,omitempty"`
 }
 
 // IndexDocument specifies the default index page
 type IndexDocument struct {
-	Suffix string `json:"Suffix"`
+	Suffix string `xml:"Suffix" json:"Suffix"`
 }
 
 // ErrorDocument specifies the error page
 type ErrorDocument struct {
-	Key string `json:"Key"`
+	Key string `xml:"Key" json:"Key"`
+}
+
+// RedirectAllRequestsTo redirects every website request to another host
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName" json:"HostName"`
+	Protocol string `xml:"Protocol,omitempty" json:"Protocol,omitempty"`
 }
 
 // RoutingRule represents a single routing rule
 type RoutingRule struct {
-	Condition *RoutingCondition `json:"Condition,omitempty"`
-	Redirect  *RoutingRedirect  `json:"Redirect,omitempty"`
+	Condition *RoutingCondition `xml:"Condition,omitempty" json:"Condition,omitempty"`
+	Redirect  *RoutingRedirect  `xml:"Redirect,omitempty" json:"Redirect,omitempty"`
 }
 
 // RoutingCondition specifies when a routing rule is applied
 type RoutingCondition struct {
-	KeyPrefixEquals              string `json:"KeyPrefixEquals,omitempty"`
-	HttpErrorCodeReturnedEquals string `json:"HttpErrorCodeReturnedEquals,omitempty"`
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty" json:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty" json:"HttpErrorCodeReturnedEquals,omitempty"`
 }
 
 // RoutingRedirect specifies how to redirect
 type RoutingRedirect struct {
-	Protocol           string `json:"Protocol,omitempty"`
-	HostName           string `json:"HostName,omitempty"`
-	ReplaceKeyPrefixWith string `json:"ReplaceKeyPrefixWith,omitempty"`
-	ReplaceKeyWith     string `json:"ReplaceKeyWith,omitempty"`
-	HttpRedirectCode   string `json:"HttpRedirectCode,omitempty"`
+	Protocol             string `xml:"Protocol,omitempty" json:"Protocol,omitempty"`
+	HostName             string `xml:"HostName,omitempty" json:"HostName,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty" json:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty" json:"ReplaceKeyWith,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty" json:"HttpRedirectCode,omitempty"`
 }
 
-// NotificationConfiguration contains bucket notification configuration
+// NotificationConfiguration contains bucket notification configuration.
+// The XML tags follow the S3 NotificationConfiguration document, where
+// Lambda targets are CloudFunctionConfiguration elements.
 type NotificationConfiguration struct {
-	TopicConfigurations []TopicConfiguration `json:"TopicConfigurations,omitempty"`
-	QueueConfigurations []QueueConfiguration `json:"QueueConfigurations,omitempty"`
-	LambdaFunctionConfigurations []LambdaFunctionConfiguration `json:"LambdaFunctionConfigurations,omitempty"`
+	XMLName xml.Name `xml:"NotificationConfiguration" json:"-"`
+	TopicConfigurations []TopicConfiguration `xml:"TopicConfiguration" json:"TopicConfigurations,omitempty"`
+	QueueConfigurations []QueueConfiguration `xml:"QueueConfiguration" json:"QueueConfigurations,omitempty"`
+	LambdaFunctionConfigurations []LambdaFunctionConfiguration `xml:"CloudFunctionConfiguration" json:"LambdaFunctionConfigurations,omitempty"`
+	WebhookConfigurations []WebhookConfiguration `xml:"WebhookConfiguration" json:"WebhookConfigurations,omitempty"`
+}
+
+// WebhookConfiguration sends notifications as HTTP POSTs to a URL. It is an
+// OpenEndpoint extension of the S3 document for deployments without SQS,
+// SNS or Lambda. AuthorizationHeader, if set, is sent as the Authorization
+// header of every POST. It is write-only: GetBucketNotification reports
+// AuthorizationHeaderSet in its place.
+type WebhookConfiguration struct {
+	ID                     string       `xml:"Id" json:"Id"`
+	URL                    string       `xml:"Url" json:"Url"`
+	AuthorizationHeader    string       `xml:"AuthorizationHeader,omitempty" json:"AuthorizationHeader,omitempty"`
+	AuthorizationHeaderSet bool         `xml:"AuthorizationHeaderSet,omitempty" json:"-"`
+	Events                 []string     `xml:"Event" json:"Event"`
+	FilterRules            []FilterRule `xml:"Filter>S3Key>FilterRule" json:"FilterRules,omitempty"`
 }
 
 // TopicConfiguration contains SNS topic notification configuration
 type TopicConfiguration struct {
-	ID        string   `json:"Id"`
-	Topic     string   `json:"Topic"`
-	Events    []string `json:"Event"`
-	FilterRules []FilterRule `json:"FilterRules,omitempty"`
+	ID        string   `xml:"Id" json:"Id"`
+	Topic     string   `xml:"Topic" json:"Topic"`
+	Events    []string `xml:"Event" json:"Event"`
+	FilterRules []FilterRule `xml:"Filter>S3Key>FilterRule" json:"FilterRules,omitempty"`
 }
 
 // QueueConfiguration contains SQS queue notification configuration
 type QueueConfiguration struct {
-	ID        string   `json:"Id"`
-	Queue     string   `json:"Queue"`
-	Events    []string `json:"Event"`
-	FilterRules []FilterRule `json:"FilterRules,omitempty"`
+	ID        string   `xml:"Id" json:"Id"`
+	Queue     string   `xml:"Queue" json:"Queue"`
+	Events    []string `xml:"Event" json:"Event"`
+	FilterRules []FilterRule `xml:"Filter>S3Key>FilterRule" json:"FilterRules,omitempty"`
 }
 
 // LambdaFunctionConfiguration contains Lambda notification configuration
 type LambdaFunctionConfiguration struct {
-	ID           string   `json:"Id"`
-	Function     string   `json:"Function"`
-	Events       []string `json:"Event"`
-	FilterRules  []FilterRule `json:"FilterRules,omitempty"`
+	ID           string   `xml:"Id" json:"Id"`
+	Function     string   `xml:"CloudFunction" json:"Function"`
+	Events       []string `xml:"Event" json:"Event"`
+	FilterRules  []FilterRule `xml:"Filter>S3Key>FilterRule" json:"FilterRules,omitempty"`
 }
 
-// FilterRule contains notification filter rules
+// FilterRule contains notification filter rules. Name is "prefix" or
+// "suffix".
 type FilterRule struct {
-	Name  string `json:"Name"`
-	Value string `json:"Value"`
+	Name  string `xml:"Name" json:"Name"`
+	Value string `xml:"Value" json:"Value"`
 }
 
 // LoggingConfiguration contains bucket logging configuration
@@ -487,6 +705,9 @@ type Destination struct {
 }
 
 // ListOptions contains options for listing objects
+// ListPageSize is how many objects ListObjectsFunc reads from a store at a time
+const ListPageSize = 1000
+
 type ListOptions struct {
 	Prefix       string
 	Delimiter    string
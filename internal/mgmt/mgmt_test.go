@@ -4,17 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/openendpoint/openendpoint/internal/auth"
 	"github.com/openendpoint/openendpoint/internal/bucketconfig"
 	"github.com/openendpoint/openendpoint/internal/cluster"
+	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/events"
+	"github.com/openendpoint/openendpoint/internal/iam"
 	"github.com/openendpoint/openendpoint/internal/lifecycle"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
 	"github.com/openendpoint/openendpoint/internal/replication"
+	"github.com/openendpoint/openendpoint/internal/telemetry"
 	"go.uber.org/zap"
 )
 
@@ -31,6 +47,7 @@ func createTestRouter(t *testing.T) (*Router, func()) {
 	}
 
 	router := NewRouter(svc, logger, nil, nil, dir)
+	router.SetAuth(auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"}))
 	return router, func() { os.RemoveAll(dir) }
 }
 
@@ -197,6 +214,350 @@ func TestRouter_HandleDeleteBucket(t *testing.T) {
 	}
 }
 
+func TestRouter_HandleDeleteBucketForce(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "full-bucket")
+	storedBytes := telemetry.GetStorageBytes()
+	for _, key := range []string{"a.txt", "dir/b.txt"} {
+		router.engine.PutObject(ctx, "full-bucket", key, strings.NewReader("data"), engine.PutObjectOptions{})
+	}
+
+	// Without force a bucket with objects is kept, as in S3
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/_mgmt/buckets/full-bucket", nil))
+	if w.Code == http.StatusOK {
+		t.Error("DELETE of a non-empty bucket succeeded without force")
+	}
+	if _, err := router.engine.GetBucket(ctx, "full-bucket"); err != nil {
+		t.Fatalf("bucket deleted without force: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/_mgmt/buckets/full-bucket?force=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE ?force=true status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var result deletedBucketJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if result.Name != "full-bucket" || result.Objects != 2 || result.Bytes != 8 {
+		t.Errorf("DELETE ?force=true = %+v, want 2 objects and 8 bytes removed", result)
+	}
+	if _, err := router.engine.GetBucket(ctx, "full-bucket"); err == nil {
+		t.Error("bucket still exists after a forced delete")
+	}
+	if got := telemetry.GetStorageBytes(); got != storedBytes {
+		t.Errorf("stored bytes gauge = %v after the forced delete, want %v", got, storedBytes)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/_mgmt/buckets/full-bucket?force=true", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("forced DELETE of a missing bucket status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouter_IAMPersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := pebble.New(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger := zap.NewNop().Sugar()
+	ctx := context.Background()
+
+	newRouter := func() *Router {
+		router := NewRouter(engine.New(NewMockStorageBackend(), store, logger), logger, nil, nil, dir)
+		router.SetAuth(auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"}))
+		if err := router.IAM().SetStore(ctx, store); err != nil {
+			t.Fatalf("SetStore() error: %v", err)
+		}
+		return router
+	}
+	serve := func(router *Router, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		signRequest(t, req, "test-key", "test-secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router := newRouter()
+	w := serve(router, "POST", "/_mgmt/iam/users", `{"username": "alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create user status = %d: %s", w.Code, w.Body.String())
+	}
+	var user iam.User
+	json.Unmarshal(w.Body.Bytes(), &user)
+
+	w = serve(router, "POST", "/_mgmt/iam/users/"+user.ID+"/keys", "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create key status = %d: %s", w.Code, w.Body.String())
+	}
+	var key iam.AccessKey
+	json.Unmarshal(w.Body.Bytes(), &key)
+	if len(key.Secret) != 40 {
+		t.Errorf("created key secret = %q, want 40 characters", key.Secret)
+	}
+	w = serve(router, "GET", "/_mgmt/iam/users/"+user.ID+"/keys", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), key.Secret) {
+		t.Errorf("GET keys = %d %s, want the key listed without its secret", w.Code, w.Body.String())
+	}
+	serve(router, "POST", "/_mgmt/iam/groups", `{"name": "readers"}`)
+	serve(router, "POST", "/_mgmt/iam/policies", `{"name": "read", "policy": {"Statement": [{"Effect": "Allow", "Actions": ["s3:GetObject"], "Resources": ["*"]}]}}`)
+
+	// A new router over the same store sees everything
+	router = newRouter()
+	if users := router.iamManager.ListUsers("default"); len(users) != 1 || len(users[0].AccessKeys) != 1 {
+		t.Fatalf("users after restart = %+v, want alice with one key", users)
+	}
+	for _, path := range []string{"/_mgmt/iam/groups", "/_mgmt/iam/policies"} {
+		w = serve(router, "GET", path, "")
+		var listed map[string][]json.RawMessage
+		json.Unmarshal(w.Body.Bytes(), &listed)
+		for _, items := range listed {
+			if len(items) != 1 {
+				t.Errorf("GET %s after restart = %s, want one entry", path, w.Body.String())
+			}
+		}
+	}
+
+	if w = serve(router, "DELETE", "/_mgmt/iam/users/keys/"+key.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("delete key status = %d: %s", w.Code, w.Body.String())
+	}
+	if w = serve(router, "DELETE", "/_mgmt/iam/users/"+user.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("delete user status = %d: %s", w.Code, w.Body.String())
+	}
+	router = newRouter()
+	if users := router.iamManager.ListUsers("default"); len(users) != 0 {
+		t.Errorf("users after delete and restart = %+v, want none", users)
+	}
+}
+
+func TestRouter_IAMRequiresAdmin(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	signer := auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"})
+	signer.SetPolicyChecker(router.iamManager)
+	router.iamManager.SetCredentialRegistry(signer)
+	router.SetAuth(signer)
+
+	newKey := func(username string, actions ...string) *iam.AccessKey {
+		user, _ := router.iamManager.CreateUser("default", username, "")
+		policy, _ := router.iamManager.CreatePolicy("default", username, iam.PolicyDoc{
+			Statement: []iam.Statement{{Effect: "Allow", Actions: actions, Resources: []string{"*"}}},
+		})
+		router.iamManager.AttachPolicy(policy.ID, user.ID, "user")
+		key, err := router.iamManager.CreateAccessKey(user.ID)
+		if err != nil {
+			t.Fatalf("CreateAccessKey() error: %v", err)
+		}
+		return key
+	}
+	reader := newKey("reader", "s3:*", "iam:ListUsers")
+	admin := newKey("admin", "iam:*")
+
+	user, _ := router.iamManager.CreateUser("default", "bob", "")
+	tests := []struct {
+		name                 string
+		method, path, body   string
+		accessKey, secretKey string
+		status               int
+	}{
+		{"unsigned list users", "GET", "/_mgmt/iam/users", "", "", "", http.StatusForbidden},
+		{"unsigned create user", "POST", "/_mgmt/iam/users", `{"username": "eve"}`, "", "", http.StatusForbidden},
+		{"unsigned create key", "POST", "/_mgmt/iam/users/" + user.ID + "/keys", "", "", "", http.StatusForbidden},
+		{"unsigned list keys", "GET", "/_mgmt/iam/users/" + user.ID + "/keys", "", "", "", http.StatusForbidden},
+		{"unsigned create policy", "POST", "/_mgmt/iam/policies", `{"name": "all", "policy": {"Statement": [{"Effect": "Allow", "Actions": ["*"], "Resources": ["*"]}]}}`, "", "", http.StatusForbidden},
+		{"wrong secret", "GET", "/_mgmt/iam/users", "", "test-key", "other-secret", http.StatusForbidden},
+		{"key without full IAM access", "POST", "/_mgmt/iam/users/" + user.ID + "/keys", "", reader.ID, reader.Secret, http.StatusForbidden},
+		{"key without full IAM access lists", "GET", "/_mgmt/iam/users", "", reader.ID, reader.Secret, http.StatusForbidden},
+		{"IAM administrator", "POST", "/_mgmt/iam/users/" + user.ID + "/keys", "", admin.ID, admin.Secret, http.StatusCreated},
+		{"root key", "GET", "/_mgmt/iam/users/" + user.ID + "/keys", "", "test-key", "test-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		if tt.accessKey != "" {
+			signRequest(t, req, tt.accessKey, tt.secretKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+	if keys := router.iamManager.ListUsers("default"); len(keys) != 3 {
+		t.Errorf("users = %d, want the 3 created directly", len(keys))
+	}
+}
+
+func TestRouter_AssumeRole(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	signer := auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"})
+	signer.SetPolicyChecker(router.iamManager)
+	router.iamManager.SetCredentialRegistry(signer)
+	router.SetAuth(signer)
+
+	policy, _ := router.iamManager.CreatePolicy("default", "read", iam.PolicyDoc{
+		Statement: []iam.Statement{{Effect: "Allow", Actions: []string{"s3:GetObject"}, Resources: []string{"*"}}},
+	})
+	req := httptest.NewRequest("POST", "/_mgmt/iam/roles", bytes.NewBufferString(`{"name": "reader", "policyArns": ["`+policy.Arn+`"]}`))
+	signRequest(t, req, "test-key", "test-secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create role status = %d: %s", w.Code, w.Body.String())
+	}
+	var role iam.Role
+	json.Unmarshal(w.Body.Bytes(), &role)
+
+	assumeRole := func(form url.Values, accessKey, secretKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/_mgmt/sts", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if accessKey != "" {
+			signRequest(t, req, accessKey, secretKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	form := url.Values{"Action": {"AssumeRole"}, "RoleArn": {role.Arn}, "RoleSessionName": {"app"}, "DurationSeconds": {"900"}}
+	w = assumeRole(form, "test-key", "test-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("AssumeRole status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Credentials struct {
+			AccessKeyID  string `xml:"AccessKeyId"`
+			SessionToken string
+			Expiration   string
+		} `xml:"AssumeRoleResult>Credentials"`
+		Arn string `xml:"AssumeRoleResult>AssumedRoleUser>Arn"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if !strings.HasPrefix(resp.Credentials.AccessKeyID, "ASIA") || resp.Credentials.SessionToken == "" || resp.Credentials.Expiration == "" {
+		t.Errorf("AssumeRole credentials = %+v", resp.Credentials)
+	}
+	if resp.Arn != role.Arn+"/app" {
+		t.Errorf("AssumedRoleUser Arn = %q, want %q", resp.Arn, role.Arn+"/app")
+	}
+
+	// Only signed requests from principals allowed sts:AssumeRole get
+	// credentials
+	if w := assumeRole(form, "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("unsigned AssumeRole status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := assumeRole(form, "test-key", "other-secret"); w.Code != http.StatusForbidden {
+		t.Errorf("AssumeRole with a wrong signature status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	newKey := func(username, action string) *iam.AccessKey {
+		user, _ := router.iamManager.CreateUser("default", username, "")
+		policy, _ := router.iamManager.CreatePolicy("default", username, iam.PolicyDoc{
+			Statement: []iam.Statement{{Effect: "Allow", Actions: []string{action}, Resources: []string{role.Arn}}},
+		})
+		router.iamManager.AttachPolicy(policy.ID, user.ID, "user")
+		key, err := router.iamManager.CreateAccessKey(user.ID)
+		if err != nil {
+			t.Fatalf("CreateAccessKey() error: %v", err)
+		}
+		return key
+	}
+	app := newKey("app", "sts:AssumeRole")
+	other := newKey("other", "s3:GetObject")
+	if w := assumeRole(form, other.ID, other.Secret); w.Code != http.StatusForbidden {
+		t.Errorf("AssumeRole without sts:AssumeRole status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := assumeRole(form, app.ID, app.Secret); w.Code != http.StatusOK {
+		t.Errorf("AssumeRole with sts:AssumeRole status = %d: %s", w.Code, w.Body.String())
+	}
+
+	form.Set("DurationSeconds", "60")
+	if w := assumeRole(form, "test-key", "test-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("AssumeRole with a 60s duration status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRouter_MetadataMaintenance(t *testing.T) {
+	dir := t.TempDir()
+	store, err := pebble.New(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger := zap.NewNop().Sugar()
+	router := NewRouter(engine.New(NewMockStorageBackend(), store, logger), logger, nil, nil, dir)
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+	router.engine.PutObject(ctx, "test-bucket", "key", bytes.NewBufferString("data"), engine.PutObjectOptions{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/maintenance/compact", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /maintenance/compact status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var compacted struct {
+		DurationMs int64          `json:"durationMs"`
+		Stats      metadata.Stats `json:"stats"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &compacted); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if compacted.Stats.Tables == 0 {
+		t.Errorf("POST /maintenance/compact stats = %+v, want the compacted tables counted", compacted.Stats)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/_mgmt/maintenance/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /maintenance/stats status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var stats metadata.Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if stats.DiskBytes <= 0 || stats.LiveBytes <= 0 {
+		t.Errorf("GET /maintenance/stats = %+v, want the store's size", stats)
+	}
+}
+
+func TestRouter_HandleSweepMultipartUploads(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+	router.engine.CreateBucket(context.Background(), "test-bucket")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/multipart/sweep?olderThan=24h", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /multipart/sweep status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var result map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if aborted, ok := result["aborted"]; !ok || aborted != 0 {
+		t.Errorf("POST /multipart/sweep = %v, want 0 uploads aborted", result)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/multipart/sweep?olderThan=soon", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /multipart/sweep?olderThan=soon status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
 func TestRouter_HandleListObjects(t *testing.T) {
 	router, cleanup := createTestRouter(t)
 	defer cleanup()
@@ -303,6 +664,16 @@ func TestRouter_HandleMetricsJSON(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("Status = %d, want %d", w.Code, http.StatusOK)
 	}
+
+	var resp struct {
+		Buckets []telemetry.BucketStats `json:"buckets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Buckets == nil {
+		t.Error("response has no buckets breakdown")
+	}
 }
 
 func TestRouter_HandleIAMUsers(t *testing.T) {
@@ -310,6 +681,7 @@ func TestRouter_HandleIAMUsers(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("GET", "/_mgmt/iam/users", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -325,6 +697,7 @@ func TestRouter_HandleCreateIAMUser(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"username": "testuser", "email": "test@example.com"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/users", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -341,6 +714,7 @@ func TestRouter_HandleCreateIAMUserMissingUsername(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"email": "test@example.com"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/users", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -356,6 +730,7 @@ func TestRouter_HandleIAMGroups(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("GET", "/_mgmt/iam/groups", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -370,6 +745,7 @@ func TestRouter_HandleIAMPolicies(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("GET", "/_mgmt/iam/policies", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -452,6 +828,367 @@ func TestRouter_HandleGetBucketNotFound(t *testing.T) {
 	}
 }
 
+func TestRouter_HandleGetBucketUsage(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+	router.engine.PutObject(ctx, "test-bucket", "a.txt", bytes.NewReader(make([]byte, 64)), engine.PutObjectOptions{})
+	router.engine.PutObject(ctx, "test-bucket", "b.txt", bytes.NewReader(make([]byte, 36)), engine.PutObjectOptions{})
+	router.engine.DeleteObject(ctx, "test-bucket", "b.txt", engine.DeleteObjectOptions{})
+
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{"GET", "/_mgmt/buckets/test-bucket/usage"},
+		{"POST", "/_mgmt/buckets/test-bucket/usage/rescan"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s status = %d, want %d", tc.method, tc.path, w.Code, http.StatusOK)
+		}
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["bytes"] != float64(64) || resp["objects"] != float64(1) {
+			t.Errorf("%s %s = %v, want 64 bytes and 1 object", tc.method, tc.path, resp)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/_mgmt/buckets/test-bucket", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	usage, ok := resp["Usage"].(map[string]interface{})
+	if resp["Name"] != "test-bucket" || !ok || usage["Bytes"] != float64(64) || usage["Objects"] != float64(1) {
+		t.Errorf("bucket details = %v, want test-bucket with 64 bytes in 1 object", resp)
+	}
+
+	req = httptest.NewRequest("GET", "/_mgmt/buckets/nonexistent/usage", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing bucket status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouter_HandleBucketMode(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+		wantMode           string
+	}{
+		{"GET", "/_mgmt/buckets/test-bucket/mode", "", http.StatusOK, "read-write"},
+		{"PUT", "/_mgmt/buckets/test-bucket/mode", `{"mode":"read-only"}`, http.StatusOK, "read-only"},
+		{"GET", "/_mgmt/buckets/test-bucket/mode", "", http.StatusOK, "read-only"},
+		{"PUT", "/_mgmt/buckets/test-bucket/mode", `{"mode":"frozen"}`, http.StatusBadRequest, ""},
+		{"PUT", "/_mgmt/buckets/test-bucket/mode", `not json`, http.StatusBadRequest, ""},
+		{"PUT", "/_mgmt/buckets/nonexistent/mode", `{"mode":"read-only"}`, http.StatusNotFound, ""},
+		{"GET", "/_mgmt/buckets/nonexistent/mode", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s %s %s status = %d, want %d", tt.method, tt.path, tt.body, w.Code, tt.wantStatus)
+		}
+		if tt.wantMode == "" {
+			continue
+		}
+		var resp map[string]string
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["mode"] != tt.wantMode {
+			t.Errorf("%s %s mode = %q, want %q", tt.method, tt.path, resp["mode"], tt.wantMode)
+		}
+	}
+
+	if _, err := router.engine.PutObject(ctx, "test-bucket", "a.txt", strings.NewReader("data"), engine.PutObjectOptions{}); !errors.Is(err, engine.ErrBucketReadOnly) {
+		t.Errorf("PutObject() in a read-only bucket error = %v, want ErrBucketReadOnly", err)
+	}
+}
+
+func TestRouter_HandleBucketPublicRead(t *testing.T) {
+	dir := t.TempDir()
+	store, err := pebble.New(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger := zap.NewNop().Sugar()
+	router := NewRouter(engine.New(NewMockStorageBackend(), store, logger), logger, nil, nil, dir)
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+		wantBody           string
+	}{
+		{"GET", "/_mgmt/buckets/test-bucket/public-read", "", http.StatusOK, `"enabled":false`},
+		{"PUT", "/_mgmt/buckets/test-bucket/public-read", `{"enabled":true}`, http.StatusOK, `"enabled":true`},
+		{"GET", "/_mgmt/buckets/test-bucket/public-read", "", http.StatusOK, `"enabled":true`},
+		{"PUT", "/_mgmt/buckets/test-bucket/public-read", `not json`, http.StatusBadRequest, ""},
+		{"PUT", "/_mgmt/buckets/nonexistent/public-read", `{"enabled":true}`, http.StatusNotFound, ""},
+		{"GET", "/_mgmt/buckets/nonexistent/public-read", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s %s = %d %s, want %d %s", tt.method, tt.path, tt.body, w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+		}
+	}
+
+	// A public access block restricting public buckets overrides it, and
+	// keeps it from being enabled again
+	router.engine.PutPublicAccessBlock(ctx, "test-bucket", &metadata.PublicAccessBlockConfiguration{RestrictPublicBuckets: true})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/_mgmt/buckets/test-bucket/public-read", nil))
+	if !strings.Contains(w.Body.String(), `"restricted":true`) {
+		t.Errorf("GET public-read with a public access block = %s, want restricted", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/_mgmt/buckets/test-bucket/public-read", strings.NewReader(`{"enabled":false}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("disabling public read status = %d, want %d", w.Code, http.StatusOK)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/_mgmt/buckets/test-bucket/public-read", strings.NewReader(`{"enabled":true}`)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("enabling restricted public read status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestRouter_HandleEmptyBucket(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+	for _, key := range []string{"a.txt", "b.txt", "dir/c.txt"} {
+		router.engine.PutObject(ctx, "test-bucket", key, strings.NewReader("data"), engine.PutObjectOptions{})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/buckets/nonexistent/empty", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("POST nonexistent/empty status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/buckets/test-bucket/empty?concurrency=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST empty?concurrency=0 status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/buckets/test-bucket/empty?deleteBucket=true&concurrency=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST empty status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	// A progress line per batch, then the result
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var result emptyBucketJSON
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &result); err != nil {
+		t.Fatalf("decoding result %q: %v", lines[len(lines)-1], err)
+	}
+	if len(lines) != 2 || !result.Done || !result.BucketDeleted || result.Objects != 3 || result.Error != "" {
+		t.Errorf("POST empty = %q, want one progress line and a done result with 3 objects and the bucket deleted", w.Body.String())
+	}
+	if _, err := router.engine.GetBucket(ctx, "test-bucket"); err == nil {
+		t.Error("bucket still exists after POST empty?deleteBucket=true")
+	}
+}
+
+func TestRouter_HandlePresignObject(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	signer := auth.New(config.AuthConfig{AccessKey: "test-key", SecretKey: "test-secret"})
+	if err := router.engine.SetPresigner(signer, "http://localhost:9000/s3"); err != nil {
+		t.Fatalf("SetPresigner() error: %v", err)
+	}
+	router.SetAuth(signer)
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+	router.engine.PutObject(ctx, "test-bucket", "dir/a.txt", bytes.NewBufferString("data"), engine.PutObjectOptions{})
+
+	presignAs := func(accessKey, secretKey, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/_mgmt/buckets/test-bucket/objects/"+key+"/presign", bytes.NewBufferString(body))
+		if accessKey != "" {
+			signRequest(t, req, accessKey, secretKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	presign := func(key, body string) *httptest.ResponseRecorder {
+		return presignAs("test-key", "test-secret", key, body)
+	}
+
+	// Anonymous callers and unknown keys get nothing signed
+	if w := presignAs("", "", "dir/a.txt", `{"method": "GET"}`); w.Code != http.StatusForbidden {
+		t.Errorf("unsigned presign status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := presignAs("unknown-key", "test-secret", "dir/a.txt", `{"method": "GET"}`); w.Code != http.StatusForbidden {
+		t.Errorf("presign with an unknown key status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := presign("dir/a.txt", `{"method": "GET", "expires": 600, "responseHeaders": {"Content-Disposition": "attachment; filename=a.txt"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", resp.URL, nil)
+	bucket, key, err := signer.VerifyPresignedURL(req)
+	if err != nil {
+		t.Fatalf("VerifyPresignedURL() error: %v", err)
+	}
+	if bucket != "test-bucket" || key != "dir/a.txt" {
+		t.Errorf("VerifyPresignedURL() = %s/%s, want test-bucket/dir/a.txt", bucket, key)
+	}
+	if got := req.URL.Query().Get("response-content-disposition"); got != "attachment; filename=a.txt" {
+		t.Errorf("response-content-disposition = %q", got)
+	}
+
+	// The override is signed, so changing it breaks the signature
+	tampered := httptest.NewRequest("GET", strings.Replace(resp.URL, "a.txt", "b.txt", 2), nil)
+	if _, _, err := signer.VerifyPresignedURL(tampered); err == nil {
+		t.Error("VerifyPresignedURL() accepted a URL with a changed override")
+	}
+
+	tests := []struct {
+		key, body string
+		status    int
+	}{
+		{"missing.txt", `{"method": "GET"}`, http.StatusNotFound},
+		{"missing.txt", `{"method": "PUT"}`, http.StatusOK},
+		{"dir/a.txt", `{"method": "GET", "expires": 604801}`, http.StatusBadRequest},
+		{"dir/a.txt", `{"method": "POST"}`, http.StatusBadRequest},
+		{"dir/a.txt", `{"method": "PUT", "responseHeaders": {"Content-Type": "text/plain"}}`, http.StatusBadRequest},
+		{"dir/a.txt", `{"responseHeaders": {"X-Custom": "1"}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := presign(tt.key, tt.body); w.Code != tt.status {
+			t.Errorf("presign %s %s: status = %d, want %d: %s", tt.key, tt.body, w.Code, tt.status, w.Body.String())
+		}
+	}
+
+	// A reader can presign downloads with its own key but not uploads
+	signer.SetPolicyChecker(router.iamManager)
+	router.iamManager.SetCredentialRegistry(signer)
+	user, _ := router.iamManager.CreateUser("default", "reader", "")
+	policy, _ := router.iamManager.CreatePolicy("default", "read", iam.PolicyDoc{
+		Statement: []iam.Statement{{Effect: "Allow", Actions: []string{"s3:GetObject"}, Resources: []string{"*"}}},
+	})
+	if err := router.iamManager.AttachPolicy(policy.ID, user.ID, "user"); err != nil {
+		t.Fatalf("AttachPolicy() error: %v", err)
+	}
+	readerKey, err := router.iamManager.CreateAccessKey(user.ID)
+	if err != nil {
+		t.Fatalf("CreateAccessKey() error: %v", err)
+	}
+	w = presignAs(readerKey.ID, readerKey.Secret, "dir/a.txt", `{"method": "GET"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reader presign GET status = %d: %s", w.Code, w.Body.String())
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !strings.Contains(resp.URL, "X-Amz-Credential="+readerKey.ID+"%2F") {
+		t.Errorf("reader presigned URL %q is not signed with the reader's key", resp.URL)
+	}
+	if w := presignAs(readerKey.ID, readerKey.Secret, "dir/a.txt", `{"method": "put"}`); w.Code != http.StatusForbidden {
+		t.Errorf("reader presign PUT status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// signRequest signs req for the SigV4 authenticator with the AWS SDK's signer
+func signRequest(t *testing.T, req *http.Request, accessKey, secretKey string) {
+	t.Helper()
+	// The server sees the Content-Length header a client sends, which
+	// httptest.NewRequest leaves out
+	if req.ContentLength > 0 {
+		req.Header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	}
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	creds := aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey}
+	if err := v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true }).SignHTTP(context.Background(), creds, req, "UNSIGNED-PAYLOAD", "s3", "us-east-1", time.Now()); err != nil {
+		t.Fatalf("SignHTTP() error: %v", err)
+	}
+}
+
+func TestRouter_HandleHeadObjects(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	router.engine.CreateBucket(ctx, "test-bucket")
+	router.engine.PutObject(ctx, "test-bucket", "a.txt", bytes.NewReader(make([]byte, 64)), engine.PutObjectOptions{})
+	router.engine.PutObject(ctx, "test-bucket", "dir/b.txt", bytes.NewReader(make([]byte, 8)), engine.PutObjectOptions{})
+
+	body := `{"keys": ["a.txt", "missing.txt", "dir/b.txt"]}`
+	req := httptest.NewRequest("POST", "/_mgmt/buckets/test-bucket/head", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp struct {
+		Objects []headObjectJSON `json:"objects"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Objects) != 3 {
+		t.Fatalf("got %d results, want 3", len(resp.Objects))
+	}
+
+	wantExists := map[string]bool{"a.txt": true, "missing.txt": false, "dir/b.txt": true}
+	wantSize := map[string]int64{"a.txt": 64, "missing.txt": 0, "dir/b.txt": 8}
+	for _, obj := range resp.Objects {
+		if obj.Exists != wantExists[obj.Key] || obj.Size != wantSize[obj.Key] {
+			t.Errorf("%s = %+v, want exists=%v size=%d", obj.Key, obj, wantExists[obj.Key], wantSize[obj.Key])
+		}
+		if obj.Exists && (obj.ETag == "" || obj.LastModified == 0) {
+			t.Errorf("%s is missing its ETag or modification time: %+v", obj.Key, obj)
+		}
+	}
+
+	tooMany := make([]string, engine.MaxHeadBatchSize+1)
+	data, _ := json.Marshal(map[string][]string{"keys": tooMany})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/buckets/test-bucket/head", bytes.NewReader(data)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("oversized batch status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/_mgmt/buckets/no-such-bucket/head", bytes.NewBufferString(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown bucket status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
 func TestRouter_HandleLifecycle(t *testing.T) {
 	router, cleanup := createTestRouter(t)
 	defer cleanup()
@@ -564,6 +1301,7 @@ func TestRouter_HandleCreateIAMGroup(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"name": "test-group"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/groups", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -580,6 +1318,7 @@ func TestRouter_HandleCreateIAMPolicy(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"name": "test-policy", "document": "{}"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/policies", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -730,7 +1469,9 @@ func TestRouter_HandleDeleteIAMGroup(t *testing.T) {
 	router, cleanup := createTestRouter(t)
 	defer cleanup()
 
-	req := httptest.NewRequest("DELETE", "/_mgmt/iam/groups/test-group", nil)
+	group, _ := router.iamManager.CreateGroup("default", "test-group")
+	req := httptest.NewRequest("DELETE", "/_mgmt/iam/groups/"+group.ID, nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -738,6 +1479,14 @@ func TestRouter_HandleDeleteIAMGroup(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("Status = %d, want %d", w.Code, http.StatusOK)
 	}
+
+	req = httptest.NewRequest("DELETE", "/_mgmt/iam/groups/"+group.ID, nil)
+	signRequest(t, req, "test-key", "test-secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want %d", w.Code, http.StatusNotFound)
+	}
 }
 
 func TestRouter_HandleCreateIAMGroupWithDescription(t *testing.T) {
@@ -746,6 +1495,7 @@ func TestRouter_HandleCreateIAMGroupWithDescription(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"name": "test-group", "description": "Test group description"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/groups", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -762,6 +1512,7 @@ func TestRouter_HandleCreateIAMPolicyWithDocument(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"name": "test-policy", "document": "{\"Version\": \"2012-10-17\"}"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/policies", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -856,6 +1607,7 @@ func TestRouter_HandleCreateIAMUserInvalidJSON(t *testing.T) {
 
 	body := bytes.NewBufferString(`invalid json`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/users", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -872,6 +1624,7 @@ func TestRouter_HandleCreateIAMGroupInvalidJSON(t *testing.T) {
 
 	body := bytes.NewBufferString(`invalid json`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/groups", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -888,6 +1641,7 @@ func TestRouter_HandleCreateIAMPolicyInvalidJSON(t *testing.T) {
 
 	body := bytes.NewBufferString(`invalid json`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/policies", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -933,6 +1687,7 @@ func TestRouter_HandleDeleteIAMUserViaRoute(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("DELETE", "/_mgmt/iam/users/user-1", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -947,6 +1702,7 @@ func TestRouter_HandleListIAMKeys(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("GET", "/_mgmt/iam/users/user-1/keys", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -961,6 +1717,7 @@ func TestRouter_HandleCreateIAMKey(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("POST", "/_mgmt/iam/users/user-1/keys", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -975,6 +1732,7 @@ func TestRouter_HandleDeleteIAMKey(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("DELETE", "/_mgmt/iam/users/keys/key-1", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -989,6 +1747,7 @@ func TestRouter_HandleDeleteIAMPolicyViaRoute(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("DELETE", "/_mgmt/iam/policies/policy-1", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -1042,8 +1801,8 @@ func TestDirectHandleDeleteIAMUser(t *testing.T) {
 
 	router.handleDeleteIAMUser(w, req, "test-user")
 
-	if w.Code != http.StatusOK && w.Code != http.StatusInternalServerError {
-		t.Errorf("Status = %d, unexpected", w.Code)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
 	}
 }
 
@@ -1070,8 +1829,8 @@ func TestDirectHandleCreateIAMKey(t *testing.T) {
 
 	router.handleCreateIAMKey(w, req, "test-user")
 
-	if w.Code != http.StatusCreated && w.Code != http.StatusInternalServerError {
-		t.Errorf("Status = %d, unexpected", w.Code)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
 	}
 }
 
@@ -1084,8 +1843,8 @@ func TestDirectHandleDeleteIAMKey(t *testing.T) {
 
 	router.handleDeleteIAMKey(w, req, "test-key")
 
-	if w.Code != http.StatusOK {
-		t.Errorf("Status = %d, want %d", w.Code, http.StatusOK)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
 	}
 }
 
@@ -1098,8 +1857,8 @@ func TestDirectHandleDeleteIAMPolicy(t *testing.T) {
 
 	router.handleDeleteIAMPolicy(w, req, "test-policy")
 
-	if w.Code != http.StatusOK && w.Code != http.StatusInternalServerError {
-		t.Errorf("Status = %d, unexpected", w.Code)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
 	}
 }
 
@@ -1454,6 +2213,68 @@ func TestRouter_HandleCluster_WithCluster(t *testing.T) {
 	_ = nodes
 }
 
+func TestRouter_DrainClusterNode(t *testing.T) {
+	svc := engine.New(NewMockStorageBackend(), NewMockMetadataStore(), zap.NewNop().Sugar())
+	clusterSvc := cluster.NewCluster(zap.NewNop(), cluster.WithNodeID("node-1"), cluster.WithBindPort(0))
+	if err := clusterSvc.Initialize(context.Background(), cluster.ReplicationFactor(3)); err != nil {
+		t.Fatalf("Failed to initialize cluster: %v", err)
+	}
+	defer clusterSvc.Stop()
+	router := NewRouter(svc, zap.NewNop().Sugar(), nil, clusterSvc, t.TempDir())
+
+	serve := func(method, target string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+
+	if code, _ := serve("POST", "/_mgmt/cluster/nodes/unknown/drain"); code != http.StatusNotFound {
+		t.Errorf("drain unknown node: status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	code, resp := serve("POST", "/_mgmt/cluster/nodes/node-1/drain")
+	if code != http.StatusOK {
+		t.Fatalf("drain: status = %d, want %d", code, http.StatusOK)
+	}
+	if resp["status"] != cluster.NodeStatusDraining {
+		t.Errorf("drain: node status = %v, want %s", resp["status"], cluster.NodeStatusDraining)
+	}
+
+	code, resp = serve("POST", "/_mgmt/cluster/rebalance")
+	if code != http.StatusOK {
+		t.Fatalf("rebalance: status = %d, want %d", code, http.StatusOK)
+	}
+	if released, _ := resp["released"].([]interface{}); len(released) != 1 || released[0] != "node-1" {
+		t.Errorf("rebalance: released = %v, want [node-1]", resp["released"])
+	}
+
+	// The drained node is reported with its new status and no ranges
+	_, resp = serve("GET", "/_mgmt/cluster")
+	nodes, _ := resp["nodes"].([]interface{})
+	if len(nodes) != 1 {
+		t.Fatalf("got %d nodes, want 1", len(nodes))
+	}
+	node := nodes[0].(map[string]interface{})
+	if node["status"] != cluster.NodeStatusDrained || node["ranges"] != float64(0) {
+		t.Errorf("drained node = status %v, ranges %v; want %s, 0", node["status"], node["ranges"], cluster.NodeStatusDrained)
+	}
+}
+
+func TestRouter_ClusterOperations_WithoutCluster(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	for _, target := range []string{"/_mgmt/cluster/nodes/node-1/drain", "/_mgmt/cluster/rebalance"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", target, nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("POST %s: status = %d, want %d", target, w.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
 // Test handleDeleteLifecycleRules success path
 func TestRouter_HandleDeleteLifecycleRules_Success(t *testing.T) {
 	router, cleanup := createTestRouter(t)
@@ -1659,6 +2480,7 @@ func TestRouter_HandleListIAMGroups_Comprehensive(t *testing.T) {
 	defer cleanup()
 
 	req := httptest.NewRequest("GET", "/_mgmt/iam/groups", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -1714,6 +2536,7 @@ func TestRouter_HandleCreateIAMGroup_MissingName(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"description": "Test group without name"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/groups", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -1748,6 +2571,7 @@ func TestRouter_HandleCreateIAMGroup_Valid(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"name": "test-group", "description": "Test group description"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/groups", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -1772,6 +2596,7 @@ func TestRouter_HandleCreateIAMPolicy_MissingName(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"policy": {"version": "2012-10-17"}}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/policies", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -1806,6 +2631,7 @@ func TestRouter_HandleCreateIAMPolicy_Valid(t *testing.T) {
 
 	body := bytes.NewBufferString(`{"name": "test-policy", "policy": {"version": "2012-10-17", "statement": [{"effect": "Allow", "action": "s3:GetObject", "resource": "*"}]}}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/policies", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -1966,6 +2792,7 @@ func TestRouter_HandleCreateIAMGroup_VariousInputs(t *testing.T) {
 
 			body := bytes.NewBufferString(tt.body)
 			req := httptest.NewRequest("POST", "/_mgmt/iam/groups", body)
+			signRequest(t, req, "test-key", "test-secret")
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -2019,6 +2846,7 @@ func TestRouter_HandleCreateIAMPolicy_VariousInputs(t *testing.T) {
 
 			body := bytes.NewBufferString(tt.body)
 			req := httptest.NewRequest("POST", "/_mgmt/iam/policies", body)
+			signRequest(t, req, "test-key", "test-secret")
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -2293,12 +3121,14 @@ func TestRouter_HandleDeleteIAMUser_WithUser(t *testing.T) {
 	// Create a user first
 	body := bytes.NewBufferString(`{"name": "test-user"}`)
 	req := httptest.NewRequest("POST", "/_mgmt/iam/users", body)
+	signRequest(t, req, "test-key", "test-secret")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	// Delete user
 	req = httptest.NewRequest("DELETE", "/_mgmt/iam/users/test-user", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -2671,6 +3501,7 @@ func TestRouter_HandleDeleteIAMUser_NonExistent(t *testing.T) {
 
 	// Delete non-existent user
 	req := httptest.NewRequest("DELETE", "/_mgmt/iam/users/nonexistent-user", nil)
+	signRequest(t, req, "test-key", "test-secret")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -2735,3 +3566,136 @@ func TestRouter_HandleSetBucketPolicy_NonExistentBucket(t *testing.T) {
 	}
 }
 
+func TestRouter_HandleSettingsWorkerIntervals(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	intervals := config.NewWorkerIntervals(config.WorkersConfig{ScrubberInterval: 600})
+	router.SetWorkerIntervals(intervals)
+
+	req := httptest.NewRequest("GET", "/_mgmt/settings", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var settings struct {
+		WorkerIntervals map[string]int64 `json:"workerIntervals"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("failed to decode settings: %v", err)
+	}
+	if settings.WorkerIntervals[config.WorkerScrubber] != 600 {
+		t.Errorf("scrubber interval = %d, want 600", settings.WorkerIntervals[config.WorkerScrubber])
+	}
+
+	body := bytes.NewBufferString(`{"workerIntervals": {"lifecycle": 90}}`)
+	req = httptest.NewRequest("POST", "/_mgmt/settings", body)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if got := intervals.Get(config.WorkerLifecycle); got != 90*time.Second {
+		t.Errorf("lifecycle interval = %v, want %v", got, 90*time.Second)
+	}
+
+	for _, invalid := range []string{
+		`{"workerIntervals": {"lifecycle": 0}}`,
+		`{"workerIntervals": {"lifecycle": 1.5}}`,
+		`{"workerIntervals": {"nope": 60}}`,
+		`{"workerIntervals": 60}`,
+	} {
+		req = httptest.NewRequest("POST", "/_mgmt/settings", bytes.NewBufferString(invalid))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Status = %d, want %d", invalid, w.Code, http.StatusBadRequest)
+		}
+	}
+	if got := intervals.Get(config.WorkerLifecycle); got != 90*time.Second {
+		t.Errorf("lifecycle interval = %v after rejected updates, want %v", got, 90*time.Second)
+	}
+}
+
+func TestRouter_Queues(t *testing.T) {
+	router, cleanup := createTestRouter(t)
+	defer cleanup()
+
+	bus := events.NewBus()
+	defer bus.Close()
+	release := make(chan struct{})
+	bus.Subscribe("notifications", func(e events.ObjectEvent) { <-release })
+	router.SetEventBus(bus)
+
+	// The replication worker is not started, so the change stays queued
+	router.replicationSvc.SetReplicator(func(ctx context.Context, task replication.Task) error { return nil })
+	router.replicationSvc.AddRule("test-bucket", &replication.Rule{Status: "Enabled", Destination: &replication.Destination{Bucket: "replica"}})
+	router.replicationSvc.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "test-bucket", Key: "a"})
+
+	// Published but not yet delivered
+	bus.Publish(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "test-bucket", Key: "a"})
+	bus.Publish(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "test-bucket", Key: "b"})
+
+	var resp struct {
+		Events []struct {
+			Name  string `json:"name"`
+			Depth int    `json:"depth"`
+		} `json:"events"`
+		Replication struct {
+			Pending          int64            `json:"pending"`
+			Buckets          map[string]int64 `json:"buckets"`
+			OldestAgeSeconds float64          `json:"oldestAgeSeconds"`
+		} `json:"replication"`
+		Drained *bool `json:"drained"`
+	}
+
+	req := httptest.NewRequest("GET", "/_mgmt/queues", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].Name != "notifications" || resp.Events[0].Depth != 2 {
+		t.Errorf("events = %+v, want notifications with depth 2", resp.Events)
+	}
+	if resp.Replication.Pending != 1 || resp.Replication.Buckets["test-bucket"] != 1 || resp.Replication.OldestAgeSeconds <= 0 {
+		t.Errorf("replication = %+v, want 1 aged change pending for test-bucket", resp.Replication)
+	}
+
+	req = httptest.NewRequest("POST", "/_mgmt/queues/drain?timeout=20ms", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Drained == nil || *resp.Drained {
+		t.Errorf("drain while blocked reported drained = %v", resp.Drained)
+	}
+
+	// Events are delivered but the replication change is still queued
+	close(release)
+	req = httptest.NewRequest("POST", "/_mgmt/queues/drain?timeout=50ms", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Drained == nil || *resp.Drained || resp.Events[0].Depth != 0 {
+		t.Errorf("drain = %v with events %+v, want events empty but replication pending", resp.Drained, resp.Events)
+	}
+
+	router.replicationSvc.Start(context.Background())
+	defer router.replicationSvc.Stop()
+	req = httptest.NewRequest("POST", "/_mgmt/queues/drain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp.Replication.Buckets = nil
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Drained == nil || !*resp.Drained || resp.Replication.Pending != 0 || resp.Replication.OldestAgeSeconds != 0 {
+		t.Errorf("drain = %v with replication %+v, want drained and empty", resp.Drained, resp.Replication)
+	}
+
+	req = httptest.NewRequest("POST", "/_mgmt/queues/drain?timeout=soon", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid timeout status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
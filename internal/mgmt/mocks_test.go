@@ -29,9 +29,9 @@ func (m *MockStorageBackend) objectKey(bucket, key string) string {
 }
 
 func (m *MockStorageBackend) Put(ctx context.Context, bucket, key string, data io.Reader, size int64, opts storage.PutOptions) error {
+	b, _ := io.ReadAll(data)
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	b, _ := io.ReadAll(data)
 	m.objects[m.objectKey(bucket, key)] = b
 	return nil
 }
@@ -114,12 +114,14 @@ type MockMetadataStore struct {
 	mu      sync.RWMutex
 	buckets map[string]*metadata.BucketMetadata
 	objects map[string]*metadata.ObjectMetadata
+	modes   map[string]*metadata.BucketMode
 }
 
 func NewMockMetadataStore() *MockMetadataStore {
 	return &MockMetadataStore{
 		buckets: make(map[string]*metadata.BucketMetadata),
 		objects: make(map[string]*metadata.ObjectMetadata),
+		modes:   make(map[string]*metadata.BucketMode),
 	}
 }
 
@@ -169,7 +171,7 @@ func (m *MockMetadataStore) GetObject(ctx context.Context, bucket, key string, v
 	if o, ok := m.objects[bucket+"/"+key]; ok {
 		return o, nil
 	}
-	return nil, os.ErrNotExist
+	return nil, metadata.ErrObjectNotFound
 }
 
 func (m *MockMetadataStore) DeleteObject(ctx context.Context, bucket, key string, versionID string) error {
@@ -178,6 +180,28 @@ func (m *MockMetadataStore) DeleteObject(ctx context.Context, bucket, key string
 	delete(m.objects, bucket+"/"+key)
 	return nil
 }
+func (m *MockMetadataStore) MoveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.objects[srcBucket+"/"+srcKey]
+	if !ok {
+		return os.ErrNotExist
+	}
+	delete(m.objects, srcBucket+"/"+srcKey)
+	moved := *o
+	moved.Bucket, moved.Key = dstBucket, dstKey
+	m.objects[dstBucket+"/"+dstKey] = &moved
+	return nil
+}
+func (m *MockMetadataStore) UpdateObjectVersion(ctx context.Context, bucket, key string, meta *metadata.ObjectMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[bucket+"/"+key]; !ok {
+		return metadata.ErrObjectNotFound
+	}
+	m.objects[bucket+"/"+key] = meta
+	return nil
+}
 
 func (m *MockMetadataStore) ListObjects(ctx context.Context, bucket, prefix string, opts metadata.ListOptions) ([]metadata.ObjectMetadata, error) {
 	m.mu.RLock()
@@ -191,6 +215,28 @@ func (m *MockMetadataStore) ListObjects(ctx context.Context, bucket, prefix stri
 	return objects, nil
 }
 
+func (m *MockMetadataStore) ListObjectsFunc(ctx context.Context, bucket, prefix, marker string, fn func(metadata.ObjectMetadata) error) error {
+	objects, _ := m.ListObjects(ctx, bucket, prefix, metadata.ListOptions{})
+	for _, obj := range objects {
+		if obj.Key <= marker {
+			continue
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockMetadataStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]metadata.ObjectMetadata, error) {
+	return m.ListObjects(ctx, bucket, prefix, metadata.ListOptions{})
+}
+
+func (m *MockMetadataStore) BucketIsEmpty(ctx context.Context, bucket string) (bool, error) {
+	objects, err := m.ListObjects(ctx, bucket, "", metadata.ListOptions{})
+	return len(objects) == 0, err
+}
+
 func (m *MockMetadataStore) CreateMultipartUpload(ctx context.Context, bucket, key, uploadID string, meta *metadata.ObjectMetadata) error {
 	return nil
 }
@@ -269,6 +315,15 @@ func (m *MockMetadataStore) GetBucketTags(ctx context.Context, bucket string) (m
 func (m *MockMetadataStore) DeleteBucketTags(ctx context.Context, bucket string) error {
 	return nil
 }
+func (m *MockMetadataStore) PutObjectTags(ctx context.Context, bucket, key, versionID string, tags map[string]string) error {
+	return nil
+}
+func (m *MockMetadataStore) GetObjectTags(ctx context.Context, bucket, key, versionID string) (map[string]string, error) {
+	return nil, nil
+}
+func (m *MockMetadataStore) DeleteObjectTags(ctx context.Context, bucket, key, versionID string) error {
+	return nil
+}
 func (m *MockMetadataStore) PutObjectLock(ctx context.Context, bucket string, config *metadata.ObjectLockConfig) error {
 	return nil
 }
@@ -278,16 +333,16 @@ func (m *MockMetadataStore) GetObjectLock(ctx context.Context, bucket string) (*
 func (m *MockMetadataStore) DeleteObjectLock(ctx context.Context, bucket string) error {
 	return nil
 }
-func (m *MockMetadataStore) PutObjectRetention(ctx context.Context, bucket, key string, retention *metadata.ObjectRetention) error {
+func (m *MockMetadataStore) PutObjectRetention(ctx context.Context, bucket, key, versionID string, retention *metadata.ObjectRetention) error {
 	return nil
 }
-func (m *MockMetadataStore) GetObjectRetention(ctx context.Context, bucket, key string) (*metadata.ObjectRetention, error) {
+func (m *MockMetadataStore) GetObjectRetention(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectRetention, error) {
 	return nil, nil
 }
-func (m *MockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *metadata.ObjectLegalHold) error {
+func (m *MockMetadataStore) PutObjectLegalHold(ctx context.Context, bucket, key, versionID string, legalHold *metadata.ObjectLegalHold) error {
 	return nil
 }
-func (m *MockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key string) (*metadata.ObjectLegalHold, error) {
+func (m *MockMetadataStore) GetObjectLegalHold(ctx context.Context, bucket, key, versionID string) (*metadata.ObjectLegalHold, error) {
 	return nil, nil
 }
 func (m *MockMetadataStore) PutPublicAccessBlock(ctx context.Context, bucket string, config *metadata.PublicAccessBlockConfiguration) error {
@@ -299,6 +354,24 @@ func (m *MockMetadataStore) GetPublicAccessBlock(ctx context.Context, bucket str
 func (m *MockMetadataStore) DeletePublicAccessBlock(ctx context.Context, bucket string) error {
 	return nil
 }
+func (m *MockMetadataStore) PutBucketACL(ctx context.Context, bucket string, acl *metadata.AccessControlList) error {
+	return nil
+}
+func (m *MockMetadataStore) GetBucketACL(ctx context.Context, bucket string) (*metadata.AccessControlList, error) {
+	return nil, nil
+}
+func (m *MockMetadataStore) DeleteBucketACL(ctx context.Context, bucket string) error {
+	return nil
+}
+func (m *MockMetadataStore) PutObjectACL(ctx context.Context, bucket, key string, acl *metadata.AccessControlList) error {
+	return nil
+}
+func (m *MockMetadataStore) GetObjectACL(ctx context.Context, bucket, key string) (*metadata.AccessControlList, error) {
+	return nil, nil
+}
+func (m *MockMetadataStore) DeleteObjectACL(ctx context.Context, bucket, key string) error {
+	return nil
+}
 func (m *MockMetadataStore) PutBucketAccelerate(ctx context.Context, bucket string, config *metadata.BucketAccelerateConfiguration) error {
 	return nil
 }
@@ -308,6 +381,29 @@ func (m *MockMetadataStore) GetBucketAccelerate(ctx context.Context, bucket stri
 func (m *MockMetadataStore) DeleteBucketAccelerate(ctx context.Context, bucket string) error {
 	return nil
 }
+func (m *MockMetadataStore) PutBucketKeyNormalization(ctx context.Context, bucket string, config *metadata.KeyNormalizationConfig) error {
+	return nil
+}
+func (m *MockMetadataStore) GetBucketKeyNormalization(ctx context.Context, bucket string) (*metadata.KeyNormalizationConfig, error) {
+	return nil, nil
+}
+func (m *MockMetadataStore) PutBucketMode(ctx context.Context, bucket string, mode *metadata.BucketMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.modes[bucket] = mode
+	return nil
+}
+func (m *MockMetadataStore) GetBucketMode(ctx context.Context, bucket string) (*metadata.BucketMode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.modes[bucket], nil
+}
+func (m *MockMetadataStore) PutBucketUsage(ctx context.Context, bucket string, usage *metadata.BucketUsage) error {
+	return nil
+}
+func (m *MockMetadataStore) GetBucketUsage(ctx context.Context, bucket string) (*metadata.BucketUsage, error) {
+	return nil, nil
+}
 func (m *MockMetadataStore) PutBucketInventory(ctx context.Context, bucket, id string, config *metadata.InventoryConfiguration) error {
 	return nil
 }
@@ -395,4 +491,13 @@ func (m *MockMetadataStore) ListBucketMetrics(ctx context.Context, bucket string
 func (m *MockMetadataStore) DeleteBucketMetrics(ctx context.Context, bucket, id string) error {
 	return nil
 }
-func (m *MockMetadataStore) Close() error { return nil }
+func (m *MockMetadataStore) Close() error                      { return nil }
+func (m *MockMetadataStore) Compact(ctx context.Context) error { return nil }
+func (m *MockMetadataStore) Stats() (*metadata.Stats, error)   { return &metadata.Stats{}, nil }
+func (m *MockMetadataStore) PutIAMEntity(ctx context.Context, kind, id string, data []byte) error {
+	return nil
+}
+func (m *MockMetadataStore) DeleteIAMEntity(ctx context.Context, kind, id string) error { return nil }
+func (m *MockMetadataStore) ListIAMEntities(ctx context.Context, kind string) ([][]byte, error) {
+	return nil, nil
+}
@@ -2,18 +2,27 @@ package mgmt
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/openendpoint/openendpoint/internal/auth"
 	"github.com/openendpoint/openendpoint/internal/bucketconfig"
 	"github.com/openendpoint/openendpoint/internal/cluster"
+	"github.com/openendpoint/openendpoint/internal/config"
 	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/events"
 	"github.com/openendpoint/openendpoint/internal/iam"
 	"github.com/openendpoint/openendpoint/internal/lifecycle"
+	"github.com/openendpoint/openendpoint/internal/metadata"
 	"github.com/openendpoint/openendpoint/internal/replication"
 	"github.com/openendpoint/openendpoint/internal/settings"
 	"github.com/openendpoint/openendpoint/internal/telemetry"
@@ -30,6 +39,10 @@ type Router struct {
 	replicationSvc *replication.Replication
 	bucketConfig   *bucketconfig.Config
 	settingsMgr    *settings.Manager
+	workerIntervals *config.WorkerIntervals
+	eventBus        *events.Bus
+	auth            *auth.Auth
+	uploadMaxAge    time.Duration
 }
 
 // NewRouter creates a new management API router
@@ -47,6 +60,48 @@ func NewRouter(engine *engine.ObjectService, logger *zap.SugaredLogger, config i
 		replicationSvc: replication.New(),
 		bucketConfig:   bucketconfig.New(),
 		settingsMgr:    settingsMgr,
+		uploadMaxAge:   defaultUploadMaxAge,
+	}
+}
+
+// Replication returns the replication manager backing the replication routes
+func (r *Router) Replication() *replication.Replication {
+	return r.replicationSvc
+}
+
+// IAM returns the IAM manager backing the IAM routes
+func (r *Router) IAM() *iam.Manager {
+	return r.iamManager
+}
+
+// SetEventBus exposes the queues of the engine's event bus through the
+// queue routes
+func (r *Router) SetEventBus(bus *events.Bus) {
+	r.eventBus = bus
+}
+
+// SetAuth sets the authenticator that verifies the callers of the routes
+// requiring credentials. Without one those routes refuse every request.
+func (r *Router) SetAuth(a *auth.Auth) {
+	r.auth = a
+}
+
+// SetMultipartUploadMaxAge sets how long a multipart upload may stay
+// incomplete before the multipart sweep route aborts it, unless the request
+// gives another age
+func (r *Router) SetMultipartUploadMaxAge(maxAge time.Duration) {
+	r.uploadMaxAge = maxAge
+}
+
+// SetWorkerIntervals exposes the background worker intervals through the
+// settings endpoint. Intervals saved by an earlier settings update take
+// precedence over the ones passed in.
+func (r *Router) SetWorkerIntervals(intervals *config.WorkerIntervals) {
+	r.workerIntervals = intervals
+	if saved, ok := r.settingsMgr.Get(workerIntervalsSetting); ok {
+		if err := r.applyWorkerIntervals(saved); err != nil {
+			r.logger.Warnw("ignoring saved worker intervals", "error", err)
+		}
 	}
 }
 
@@ -67,6 +122,12 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	r.logger.Debugw("mgmt request after strip", "path", path)
 
+	// IAM decides who may do what, so only administrators may read or
+	// change it
+	if strings.HasPrefix(path, "/iam/") && !r.authorizeAdmin(w, req) {
+		return
+	}
+
 	// Route request
 	r.route(w, req, path)
 }
@@ -101,6 +162,21 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request, path string) {
 		r.handleSettings(w, req)
 	case req.Method == http.MethodGet && path == "/cluster":
 		r.handleCluster(w, req)
+	case req.Method == http.MethodPost && path == "/cluster/rebalance":
+		r.handleClusterRebalance(w, req)
+	case req.Method == http.MethodPost && strings.HasPrefix(path, "/cluster/nodes/") && strings.HasSuffix(path, "/drain"):
+		nodeID := strings.TrimSuffix(strings.TrimPrefix(path, "/cluster/nodes/"), "/drain")
+		r.handleDrainClusterNode(w, req, nodeID)
+	case req.Method == http.MethodGet && path == "/queues":
+		r.handleGetQueues(w, req)
+	case req.Method == http.MethodPost && path == "/queues/drain":
+		r.handleDrainQueues(w, req)
+	case req.Method == http.MethodPost && path == "/multipart/sweep":
+		r.handleSweepMultipartUploads(w, req)
+	case req.Method == http.MethodPost && path == "/maintenance/compact":
+		r.handleCompactMetadata(w, req)
+	case req.Method == http.MethodGet && path == "/maintenance/stats":
+		r.handleMetadataStats(w, req)
 	// NOTE: Specific routes must come BEFORE general /buckets/{bucket} routes
 	case req.Method == http.MethodGet && len(path) > 9 && path[:9] == "/buckets/" && strings.Contains(path[9:], "/objects"):
 		// /buckets/{bucket}/objects or /buckets/{bucket}/objects/{prefix}
@@ -122,6 +198,10 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request, path string) {
 			return
 		}
 		r.handleDeleteBucket(w, req, rest)
+	case req.Method == http.MethodPost && len(path) > 9 && path[:9] == "/buckets/" && strings.Contains(path[9:], "/objects/") && strings.HasSuffix(path, "/presign"):
+		// /buckets/{bucket}/objects/{key}/presign
+		parts := strings.SplitN(strings.TrimSuffix(path[9:], "/presign"), "/objects/", 2)
+		r.handlePresignObject(w, req, parts[0], parts[1])
 	case req.Method == http.MethodPost && len(path) > 9 && path[:9] == "/buckets/" && strings.Contains(path[9:], "/objects"):
 		// Upload object - /buckets/{bucket}/objects
 		parts := strings.SplitN(path[9:], "/objects", 2)
@@ -129,6 +209,40 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request, path string) {
 		r.handleUploadObject(w, req, bucket)
 		return
 
+	// Bucket usage routes - MUST come before general /buckets/{bucket}
+	case req.Method == http.MethodGet && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/usage"):
+		bucket := strings.TrimSuffix(path[9:], "/usage")
+		r.handleGetBucketUsage(w, req, bucket)
+	case req.Method == http.MethodPost && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/usage/rescan"):
+		bucket := strings.TrimSuffix(path[9:], "/usage/rescan")
+		r.handleRescanBucketUsage(w, req, bucket)
+	case req.Method == http.MethodPost && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/empty"):
+		bucket := strings.TrimSuffix(path[9:], "/empty")
+		r.handleEmptyBucket(w, req, bucket)
+
+	case req.Method == http.MethodPost && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/head"):
+		bucket := strings.TrimSuffix(path[9:], "/head")
+		r.handleHeadObjects(w, req, bucket)
+
+	case req.Method == http.MethodGet && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/mode"):
+		bucket := strings.TrimSuffix(path[9:], "/mode")
+		r.handleGetBucketMode(w, req, bucket)
+	case req.Method == http.MethodPut && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/mode"):
+		bucket := strings.TrimSuffix(path[9:], "/mode")
+		r.handleSetBucketMode(w, req, bucket)
+	case req.Method == http.MethodGet && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/key-normalization"):
+		bucket := strings.TrimSuffix(path[9:], "/key-normalization")
+		r.handleGetKeyNormalization(w, req, bucket)
+	case req.Method == http.MethodPut && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/key-normalization"):
+		bucket := strings.TrimSuffix(path[9:], "/key-normalization")
+		r.handleSetKeyNormalization(w, req, bucket)
+	case req.Method == http.MethodGet && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/public-read"):
+		bucket := strings.TrimSuffix(path[9:], "/public-read")
+		r.handleGetBucketPublicRead(w, req, bucket)
+	case req.Method == http.MethodPut && len(path) > 9 && path[:9] == "/buckets/" && strings.HasSuffix(path, "/public-read"):
+		bucket := strings.TrimSuffix(path[9:], "/public-read")
+		r.handleSetBucketPublicRead(w, req, bucket)
+
 	// Bucket Config Routes (Versioning, CORS, Policy) - MUST come before general /buckets/{bucket}
 	case req.Method == http.MethodGet && len(path) > 9 && path[:9] == "/buckets/" && strings.Contains(path[9:], "/versioning"):
 		bucket := strings.SplitN(path[9:], "/versioning", 2)[0]
@@ -163,31 +277,35 @@ func (r *Router) route(w http.ResponseWriter, req *http.Request, path string) {
 		r.handleListIAMUsers(w, req)
 	case req.Method == http.MethodPost && path == "/iam/users":
 		r.handleCreateIAMUser(w, req)
-	case req.Method == http.MethodDelete && len(path) > 10 && path[:10] == "/iam/users/":
-		r.handleDeleteIAMUser(w, req, path[10:])
-	case req.Method == http.MethodGet && len(path) > 10 && path[:10] == "/iam/users/" && strings.Contains(path[10:], "/keys"):
-		// /iam/users/{name}/keys
-		parts := strings.SplitN(path[10:], "/keys", 2)
-		userName := parts[0]
-		r.handleListIAMKeys(w, req, userName)
-	case req.Method == http.MethodPost && len(path) > 10 && path[:10] == "/iam/users/" && strings.Contains(path[10:], "/keys"):
-		parts := strings.SplitN(path[10:], "/keys", 2)
-		userName := parts[0]
-		r.handleCreateIAMKey(w, req, userName)
-	case req.Method == http.MethodDelete && len(path) > 14 && path[:14] == "/iam/users/keys/":
-		r.handleDeleteIAMKey(w, req, path[14:])
+	case req.Method == http.MethodDelete && strings.HasPrefix(path, "/iam/users/keys/"):
+		r.handleDeleteIAMKey(w, req, strings.TrimPrefix(path, "/iam/users/keys/"))
+	case req.Method == http.MethodDelete && strings.HasPrefix(path, "/iam/users/"):
+		r.handleDeleteIAMUser(w, req, strings.TrimPrefix(path, "/iam/users/"))
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "/iam/users/") && strings.HasSuffix(path, "/keys"):
+		// /iam/users/{id}/keys
+		userID := strings.TrimSuffix(strings.TrimPrefix(path, "/iam/users/"), "/keys")
+		r.handleListIAMKeys(w, req, userID)
+	case req.Method == http.MethodPost && strings.HasPrefix(path, "/iam/users/") && strings.HasSuffix(path, "/keys"):
+		userID := strings.TrimSuffix(strings.TrimPrefix(path, "/iam/users/"), "/keys")
+		r.handleCreateIAMKey(w, req, userID)
 	case req.Method == http.MethodGet && path == "/iam/groups":
 		r.handleListIAMGroups(w, req)
 	case req.Method == http.MethodPost && path == "/iam/groups":
 		r.handleCreateIAMGroup(w, req)
-	case req.Method == http.MethodDelete && len(path) > 12 && path[:12] == "/iam/groups/":
-		r.handleDeleteIAMGroup(w, req, path[12:])
+	case req.Method == http.MethodDelete && strings.HasPrefix(path, "/iam/groups/"):
+		r.handleDeleteIAMGroup(w, req, strings.TrimPrefix(path, "/iam/groups/"))
 	case req.Method == http.MethodGet && path == "/iam/policies":
 		r.handleListIAMPolicies(w, req)
 	case req.Method == http.MethodPost && path == "/iam/policies":
 		r.handleCreateIAMPolicy(w, req)
-	case req.Method == http.MethodDelete && len(path) > 13 && path[:13] == "/iam/policies/":
-		r.handleDeleteIAMPolicy(w, req, path[13:])
+	case req.Method == http.MethodDelete && strings.HasPrefix(path, "/iam/policies/"):
+		r.handleDeleteIAMPolicy(w, req, strings.TrimPrefix(path, "/iam/policies/"))
+	case req.Method == http.MethodGet && path == "/iam/roles":
+		r.handleListIAMRoles(w, req)
+	case req.Method == http.MethodPost && path == "/iam/roles":
+		r.handleCreateIAMRole(w, req)
+	case req.Method == http.MethodPost && path == "/sts":
+		r.handleSTS(w, req)
 
 	// Lifecycle Routes
 	// Lifecycle Routes - use strings.HasPrefix
@@ -235,8 +353,17 @@ func (r *Router) handleHealth(w http.ResponseWriter, req *http.Request) {
 	})
 }
 
-// handleReady returns readiness check
+// handleReady returns readiness check. The node reports not ready while
+// its metadata store refuses writes.
 func (r *Router) handleReady(w http.ResponseWriter, req *http.Request) {
+	if err := r.engine.MetadataWritable(req.Context()); err != nil {
+		r.writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "not ready",
+			"error":  err.Error(),
+		})
+		return
+	}
+
 	r.writeJSON(w, http.StatusOK, map[string]string{
 		"status": "ready",
 	})
@@ -284,10 +411,24 @@ func (r *Router) handleCreateBucket(w http.ResponseWriter, req *http.Request) {
 	})
 }
 
-// handleDeleteBucket deletes a bucket
+// deletedBucketJSON is the management API form of a forced bucket delete:
+// the bucket's name and what was removed from it
+type deletedBucketJSON struct {
+	Name string `json:"name"`
+	engine.EmptyBucketProgress
+}
+
+// handleDeleteBucket deletes a bucket. As in S3 only an empty bucket can be
+// deleted, unless the force query parameter is true, in which case every
+// object, version and multipart upload in it is deleted first.
 func (r *Router) handleDeleteBucket(w http.ResponseWriter, req *http.Request, bucket string) {
 	ctx := req.Context()
 
+	if req.URL.Query().Get("force") == "true" {
+		r.forceDeleteBucket(w, req, bucket)
+		return
+	}
+
 	if err := r.engine.DeleteBucket(ctx, bucket); err != nil {
 		r.writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -298,6 +439,28 @@ func (r *Router) handleDeleteBucket(w http.ResponseWriter, req *http.Request, bu
 	})
 }
 
+// forceDeleteBucket empties a bucket and deletes it. A failure part way
+// leaves the bucket with what was not yet deleted.
+func (r *Router) forceDeleteBucket(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+	if _, err := r.engine.GetBucket(ctx, bucket); err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+
+	progress, err := r.engine.EmptyBucket(ctx, bucket, engine.EmptyBucketOptions{DeleteBucket: true})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, engine.ErrBucketReadOnly) || errors.Is(err, engine.ErrBucketMaintenance) || errors.Is(err, engine.ErrBucketNotEmpty) {
+			status = http.StatusConflict
+		}
+		r.writeError(w, status, err.Error())
+		return
+	}
+
+	r.writeJSON(w, http.StatusOK, deletedBucketJSON{Name: bucket, EmptyBucketProgress: *progress})
+}
+
 // handleGetBucket returns bucket details
 func (r *Router) handleGetBucket(w http.ResponseWriter, req *http.Request, bucket string) {
 	ctx := req.Context()
@@ -310,7 +473,12 @@ func (r *Router) handleGetBucket(w http.ResponseWriter, req *http.Request, bucke
 
 	for _, b := range buckets {
 		if b.Name == bucket {
-			r.writeJSON(w, http.StatusOK, b)
+			usage, err := r.engine.GetBucketUsage(ctx, bucket)
+			if err != nil {
+				r.writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			r.writeJSON(w, http.StatusOK, bucketDetailJSON{BucketInfo: b, Usage: *usage})
 			return
 		}
 	}
@@ -318,6 +486,313 @@ func (r *Router) handleGetBucket(w http.ResponseWriter, req *http.Request, bucke
 	r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
 }
 
+// bucketDetailJSON is the management API form of one bucket. It keeps the
+// field names of the bucket list entries, usage included.
+type bucketDetailJSON struct {
+	engine.BucketInfo
+	Usage engine.BucketUsage
+}
+
+// handleGetBucketUsage returns the bytes and object count stored in a bucket
+func (r *Router) handleGetBucketUsage(w http.ResponseWriter, req *http.Request, bucket string) {
+	usage, err := r.engine.GetBucketUsage(req.Context(), bucket)
+	if err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+	r.writeJSON(w, http.StatusOK, bucketUsageJSON(bucket, usage))
+}
+
+// handleRescanBucketUsage recounts a bucket's usage from its object metadata
+func (r *Router) handleRescanBucketUsage(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+	if _, err := r.engine.GetBucket(ctx, bucket); err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+
+	usage, err := r.engine.RescanBucketUsage(ctx, bucket)
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, bucketUsageJSON(bucket, usage))
+}
+
+// emptyBucketJSON is the management API form of an empty bucket run. A run
+// streams one line per batch as it goes; the last line has Done set.
+type emptyBucketJSON struct {
+	engine.EmptyBucketProgress
+	Done          bool   `json:"done,omitempty"`
+	BucketDeleted bool   `json:"bucketDeleted,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// handleEmptyBucket deletes every object, version and multipart upload in a
+// bucket, and the bucket itself when the deleteBucket query parameter is
+// true. Progress is streamed as newline-delimited JSON after each batch.
+// Closing the request cancels the run, leaving what was not yet deleted.
+func (r *Router) handleEmptyBucket(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+	if _, err := r.engine.GetBucket(ctx, bucket); err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+
+	query := req.URL.Query()
+	opts := engine.EmptyBucketOptions{DeleteBucket: query.Get("deleteBucket") == "true"}
+	if value := query.Get("concurrency"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			r.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid concurrency: %s", value))
+			return
+		}
+		opts.Concurrency = n
+	}
+
+	streaming := false
+	enc := json.NewEncoder(w)
+	opts.Progress = func(p engine.EmptyBucketProgress) {
+		if !streaming {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			streaming = true
+		}
+		enc.Encode(emptyBucketJSON{EmptyBucketProgress: p})
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	progress, err := r.engine.EmptyBucket(ctx, bucket, opts)
+	if err != nil && !streaming {
+		status := http.StatusInternalServerError
+		if errors.Is(err, engine.ErrBucketReadOnly) || errors.Is(err, engine.ErrBucketMaintenance) || errors.Is(err, engine.ErrBucketNotEmpty) {
+			status = http.StatusConflict
+		}
+		r.writeError(w, status, err.Error())
+		return
+	}
+
+	result := emptyBucketJSON{Done: true, BucketDeleted: opts.DeleteBucket && err == nil}
+	if progress != nil {
+		result.EmptyBucketProgress = *progress
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if !streaming {
+		r.writeJSON(w, http.StatusOK, result)
+		return
+	}
+	enc.Encode(result)
+}
+
+// keyNormalizationJSON is the management API form of a bucket's key normalization
+type keyNormalizationJSON struct {
+	CaseInsensitive bool `json:"caseInsensitive"`
+	UnicodeNFC      bool `json:"unicodeNFC"`
+}
+
+// handleGetKeyNormalization returns a bucket's object key normalization mode
+func (r *Router) handleGetKeyNormalization(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+	if _, err := r.engine.GetBucket(ctx, bucket); err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+
+	config, err := r.engine.GetBucketKeyNormalization(ctx, bucket)
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, keyNormalizationJSON{
+		CaseInsensitive: config.CaseInsensitive,
+		UnicodeNFC:      config.UnicodeNFC,
+	})
+}
+
+// handleSetKeyNormalization sets a bucket's object key normalization mode
+func (r *Router) handleSetKeyNormalization(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+	if _, err := r.engine.GetBucket(ctx, bucket); err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+
+	var body keyNormalizationJSON
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	config := &metadata.KeyNormalizationConfig{
+		CaseInsensitive: body.CaseInsensitive,
+		UnicodeNFC:      body.UnicodeNFC,
+	}
+	if err := r.engine.PutBucketKeyNormalization(ctx, bucket, config); err != nil {
+		r.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	r.writeJSON(w, http.StatusOK, body)
+}
+
+// bucketModeJSON is the management API form of a bucket's access mode
+type bucketModeJSON struct {
+	Mode string `json:"mode"`
+}
+
+// handleGetBucketMode returns whether a bucket is read-write, read-only or
+// under maintenance
+func (r *Router) handleGetBucketMode(w http.ResponseWriter, req *http.Request, bucket string) {
+	ctx := req.Context()
+	if _, err := r.engine.GetBucket(ctx, bucket); err != nil {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+
+	mode, err := r.engine.GetBucketMode(ctx, bucket)
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, bucketModeJSON{Mode: mode.Mode})
+}
+
+// handleSetBucketMode puts a bucket into read-write, read-only or
+// maintenance mode
+func (r *Router) handleSetBucketMode(w http.ResponseWriter, req *http.Request, bucket string) {
+	var body bucketModeJSON
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := r.engine.PutBucketMode(req.Context(), bucket, &metadata.BucketMode{Mode: body.Mode})
+	switch {
+	case errors.Is(err, engine.ErrInvalidBucketMode):
+		r.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, engine.ErrBucketNotFound):
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	case err != nil:
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r.writeJSON(w, http.StatusOK, body)
+}
+
+// bucketPublicReadJSON is the management API form of a bucket's public read
+// setting. Restricted reports that the bucket's public access block keeps
+// it from taking effect.
+type bucketPublicReadJSON struct {
+	Enabled    bool `json:"enabled"`
+	Restricted bool `json:"restricted"`
+}
+
+// handleGetBucketPublicRead returns whether anonymous clients can read a
+// bucket's objects
+func (r *Router) handleGetBucketPublicRead(w http.ResponseWriter, req *http.Request, bucket string) {
+	enabled, restricted, err := r.engine.BucketPublicRead(req.Context(), bucket)
+	switch {
+	case errors.Is(err, engine.ErrBucketNotFound):
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	case err != nil:
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, bucketPublicReadJSON{Enabled: enabled, Restricted: restricted})
+}
+
+// handleSetBucketPublicRead lets anonymous clients GET and HEAD a bucket's
+// objects, or stops doing so. The body is {"enabled": true|false}.
+func (r *Router) handleSetBucketPublicRead(w http.ResponseWriter, req *http.Request, bucket string) {
+	var body bucketPublicReadJSON
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := r.engine.SetBucketPublicRead(req.Context(), bucket, body.Enabled)
+	switch {
+	case errors.Is(err, engine.ErrBucketNotFound):
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	case errors.Is(err, engine.ErrPublicAccessRestricted):
+		r.writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r.handleGetBucketPublicRead(w, req, bucket)
+}
+
+// headObjectJSON is one entry of a batch HEAD response
+type headObjectJSON struct {
+	Key          string `json:"key"`
+	Exists       bool   `json:"exists"`
+	Size         int64  `json:"size,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified int64  `json:"lastModified,omitempty"`
+}
+
+// handleHeadObjects reports existence, size, ETag and modification time for
+// a list of keys in one request. The body is {"keys": [...]}.
+func (r *Router) handleHeadObjects(w http.ResponseWriter, req *http.Request, bucket string) {
+	var body struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(body.Keys) > engine.MaxHeadBatchSize {
+		r.writeError(w, http.StatusBadRequest, fmt.Sprintf("At most %d keys can be checked per request", engine.MaxHeadBatchSize))
+		return
+	}
+
+	heads, err := r.engine.HeadObjects(req.Context(), bucket, body.Keys)
+	if errors.Is(err, engine.ErrBucketNotFound) {
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	}
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	objects := make([]headObjectJSON, len(heads))
+	for i, h := range heads {
+		objects[i] = headObjectJSON{
+			Key:          h.Key,
+			Exists:       h.Exists,
+			Size:         h.Size,
+			ETag:         h.ETag,
+			LastModified: h.LastModified,
+		}
+	}
+
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"bucket":  bucket,
+		"objects": objects,
+	})
+}
+
+func bucketUsageJSON(bucket string, usage *engine.BucketUsage) map[string]interface{} {
+	return map[string]interface{}{
+		"bucket":  bucket,
+		"bytes":   usage.Bytes,
+		"objects": usage.Objects,
+	}
+}
+
 // handleListObjects lists objects in a bucket
 func (r *Router) handleListObjects(w http.ResponseWriter, req *http.Request, bucket, prefix string) {
 	ctx := req.Context()
@@ -364,7 +839,7 @@ func (r *Router) handleDeleteObject(w http.ResponseWriter, req *http.Request, bu
 		return
 	}
 
-	if err := r.engine.DeleteObject(ctx, bucket, key, engine.DeleteObjectOptions{}); err != nil {
+	if _, err := r.engine.DeleteObject(ctx, bucket, key, engine.DeleteObjectOptions{}); err != nil {
 		r.writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -422,6 +897,78 @@ func (r *Router) handleUploadObject(w http.ResponseWriter, req *http.Request, bu
 	})
 }
 
+// presignActions maps the methods a presigned URL can be issued for to the
+// S3 action the URL performs
+var presignActions = map[string]string{
+	http.MethodGet:    "s3:GetObject",
+	http.MethodHead:   "s3:GetObject",
+	http.MethodPut:    "s3:PutObject",
+	http.MethodDelete: "s3:DeleteObject",
+}
+
+// handlePresignObject issues a presigned URL for an object. The body
+// gives the method, the expiry in seconds and any response headers a GET
+// should override. The URL is signed with the caller's own access key,
+// which must be allowed the action the URL performs.
+func (r *Router) handlePresignObject(w http.ResponseWriter, req *http.Request, bucket, key string) {
+	accessKey, ok := r.authenticate(w, req)
+	if !ok {
+		return
+	}
+	key, err := url.PathUnescape(key)
+	if err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid object key")
+		return
+	}
+
+	body := struct {
+		Method          string            `json:"method"`
+		Expires         int64             `json:"expires"`
+		ResponseHeaders map[string]string `json:"responseHeaders"`
+	}{Expires: 3600}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Methods no action is known for are left to PresignObject to refuse
+	method := strings.ToUpper(body.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if action, ok := presignActions[method]; ok && !r.auth.IsAuthorized(accessKey, bucket+"/"+key, action) {
+		r.writeError(w, http.StatusForbidden, "Access Denied")
+		return
+	}
+
+	presigned, err := r.engine.PresignObject(req.Context(), accessKey, bucket, key, engine.PresignOptions{
+		Method:          body.Method,
+		Expiry:          time.Duration(body.Expires) * time.Second,
+		ResponseHeaders: body.ResponseHeaders,
+	})
+	switch {
+	case errors.Is(err, engine.ErrInvalidPresign):
+		r.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, engine.ErrBucketNotFound):
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Bucket not found: %s", bucket))
+		return
+	case errors.Is(err, engine.ErrObjectNotFound):
+		r.writeError(w, http.StatusNotFound, fmt.Sprintf("Object not found: %s/%s", bucket, key))
+		return
+	case err != nil:
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"bucket":    bucket,
+		"key":       key,
+		"url":       presigned,
+		"expiresAt": time.Now().Add(time.Duration(body.Expires) * time.Second).Unix(),
+	})
+}
+
 // handleMetrics returns Prometheus metrics
 func (r *Router) handleMetrics(w http.ResponseWriter, req *http.Request) {
 	// This is handled by the Prometheus middleware
@@ -468,6 +1015,7 @@ func (r *Router) handleMetricsJSON(w http.ResponseWriter, req *http.Request) {
 			"p95": telemetry.GetLatencyP95(),
 			"p99": telemetry.GetLatencyP99(),
 		},
+		"buckets":   telemetry.GetBucketStats(),
 		"timestamp": time.Now().Unix(),
 	})
 }
@@ -485,6 +1033,9 @@ func (r *Router) handleSettings(w http.ResponseWriter, req *http.Request) {
 	if req.Method == http.MethodGet {
 		// Return current settings from manager
 		settings := r.settingsMgr.GetAll()
+		if r.workerIntervals != nil {
+			settings[workerIntervalsSetting] = r.workerIntervalSeconds()
+		}
 		r.writeJSON(w, http.StatusOK, settings)
 		return
 	}
@@ -496,6 +1047,20 @@ func (r *Router) handleSettings(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Worker intervals take effect immediately; store the full set so a
+	// restart restores all of them
+	if value, ok := newSettings[workerIntervalsSetting]; ok {
+		if r.workerIntervals == nil {
+			r.writeError(w, http.StatusBadRequest, "Worker intervals cannot be changed on this server")
+			return
+		}
+		if err := r.applyWorkerIntervals(value); err != nil {
+			r.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		newSettings[workerIntervalsSetting] = r.workerIntervalSeconds()
+	}
+
 	// Update settings in manager
 	r.settingsMgr.SetMultiple(newSettings)
 
@@ -513,6 +1078,56 @@ func (r *Router) handleSettings(w http.ResponseWriter, req *http.Request) {
 	})
 }
 
+// workerIntervalsSetting is the settings key holding worker intervals, as a
+// map of worker name to seconds
+const workerIntervalsSetting = "workerIntervals"
+
+// applyWorkerIntervals validates a workerIntervals setting and applies it.
+// Nothing is changed if any entry is invalid.
+func (r *Router) applyWorkerIntervals(value interface{}) error {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("workerIntervals must map worker names to seconds")
+	}
+
+	current := r.workerIntervals.All()
+	intervals := make(map[string]time.Duration, len(entries))
+	for name, raw := range entries {
+		if _, known := current[name]; !known {
+			return fmt.Errorf("unknown worker: %s", name)
+		}
+		var seconds float64
+		switch v := raw.(type) {
+		case float64:
+			seconds = v
+		case int64:
+			seconds = float64(v)
+		default:
+			seconds = -1
+		}
+		if seconds < 1 || seconds != float64(int64(seconds)) {
+			return fmt.Errorf("interval for %s must be a whole number of seconds", name)
+		}
+		intervals[name] = time.Duration(seconds) * time.Second
+	}
+
+	for name, interval := range intervals {
+		if err := r.workerIntervals.Set(name, interval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workerIntervalSeconds returns the current worker intervals in seconds
+func (r *Router) workerIntervalSeconds() map[string]interface{} {
+	seconds := make(map[string]interface{})
+	for name, interval := range r.workerIntervals.All() {
+		seconds[name] = int64(interval / time.Second)
+	}
+	return seconds
+}
+
 // handleCluster returns cluster status
 func (r *Router) handleCluster(w http.ResponseWriter, req *http.Request) {
 	if r.clusterService == nil {
@@ -525,6 +1140,7 @@ func (r *Router) handleCluster(w http.ResponseWriter, req *http.Request) {
 
 	nodes := r.clusterService.GetNodes()
 	info := r.clusterService.GetClusterInfo()
+	distribution := r.clusterService.GetRingDistribution()
 
 	// Convert nodes to dashboard format
 	nodeList := make([]map[string]interface{}, len(nodes))
@@ -534,7 +1150,8 @@ func (r *Router) handleCluster(w http.ResponseWriter, req *http.Request) {
 			"name":             node.Name,
 			"address":          node.Address,
 			"port":             node.Port,
-			"status":           node.Status(),
+			"status":           r.clusterService.NodeStatus(node),
+			"ranges":           distribution[node.ID],
 			"version":          node.Version,
 			"region":           node.Metadata.Region,
 			"zone":             node.Metadata.Zone,
@@ -550,10 +1167,192 @@ func (r *Router) handleCluster(w http.ResponseWriter, req *http.Request) {
 		"replicationFactor":  info.ReplicationFactor,
 		"totalNodes":         len(nodes),
 		"nodes":              nodeList,
-		"ringDistribution":   r.clusterService.GetRingDistribution(),
+		"ringDistribution":   distribution,
 	})
 }
 
+// handleDrainClusterNode marks a node as draining, so no new writes are
+// routed to it. Its ring ranges move to the other nodes on the next
+// rebalance; the drain is done once the node reports status "drained" and
+// zero ranges.
+func (r *Router) handleDrainClusterNode(w http.ResponseWriter, req *http.Request, nodeID string) {
+	if r.clusterService == nil {
+		r.writeError(w, http.StatusServiceUnavailable, "Clustering is not enabled")
+		return
+	}
+	if nodeID == "" || strings.Contains(nodeID, "/") {
+		r.writeError(w, http.StatusBadRequest, "Invalid node ID")
+		return
+	}
+
+	if err := r.clusterService.DrainNode(nodeID); err != nil {
+		if errors.Is(err, cluster.ErrNodeNotFound) {
+			r.writeError(w, http.StatusNotFound, fmt.Sprintf("Node not found: %s", nodeID))
+			return
+		}
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":     nodeID,
+		"status": r.clusterService.NodeStatus(&cluster.Node{ID: nodeID}),
+		"ranges": r.clusterService.GetRingDistribution()[nodeID],
+	})
+}
+
+// handleClusterRebalance recomputes the ring range assignment: alive nodes
+// missing from the ring join it and draining nodes release their ranges
+func (r *Router) handleClusterRebalance(w http.ResponseWriter, req *http.Request) {
+	if r.clusterService == nil {
+		r.writeError(w, http.StatusServiceUnavailable, "Clustering is not enabled")
+		return
+	}
+
+	result, err := r.clusterService.Rebalance(req.Context())
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, result)
+}
+
+// defaultDrainTimeout bounds a queue drain when the request sets no timeout
+const defaultDrainTimeout = 30 * time.Second
+
+// eventQueueJSON is the management API form of one event subscriber's queue
+type eventQueueJSON struct {
+	Name             string  `json:"name"`
+	Depth            int     `json:"depth"`
+	Capacity         int     `json:"capacity"`
+	Dropped          int64   `json:"dropped"`
+	OldestAgeSeconds float64 `json:"oldestAgeSeconds"`
+}
+
+// queuesJSON reports the event subscriber queues and the replication backlog
+func (r *Router) queuesJSON() map[string]interface{} {
+	eventQueues := []eventQueueJSON{}
+	if r.eventBus != nil {
+		for _, q := range r.eventBus.Queues() {
+			eventQueues = append(eventQueues, eventQueueJSON{
+				Name:             q.Name,
+				Depth:            q.Depth,
+				Capacity:         q.Capacity,
+				Dropped:          q.Dropped,
+				OldestAgeSeconds: q.OldestAge.Seconds(),
+			})
+		}
+	}
+
+	pending := r.replicationSvc.GetPendingByBucket()
+	var totalPending int64
+	for _, n := range pending {
+		totalPending += n
+	}
+
+	return map[string]interface{}{
+		"events": eventQueues,
+		"replication": map[string]interface{}{
+			"pending":          totalPending,
+			"buckets":          pending,
+			"oldestAgeSeconds": r.replicationSvc.OldestPending().Seconds(),
+		},
+	}
+}
+
+// handleGetQueues reports the depth of the asynchronous delivery queues
+func (r *Router) handleGetQueues(w http.ResponseWriter, req *http.Request) {
+	r.writeJSON(w, http.StatusOK, r.queuesJSON())
+}
+
+// handleDrainQueues waits for the event queues and then the replication
+// backlog to empty, up to the duration in the timeout query parameter, and
+// reports whether they did. It only waits for work already queued: events
+// dropped because a subscriber fell behind are counted, not replayed.
+func (r *Router) handleDrainQueues(w http.ResponseWriter, req *http.Request) {
+	timeout := defaultDrainTimeout
+	if value := req.URL.Query().Get("timeout"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			r.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid timeout: %s", value))
+			return
+		}
+		timeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	drained := true
+	if r.eventBus != nil {
+		drained = r.eventBus.Drain(ctx) == nil
+	}
+	if drained {
+		drained = r.replicationSvc.Drain(ctx) == nil
+	}
+
+	resp := r.queuesJSON()
+	resp["drained"] = drained
+	r.writeJSON(w, http.StatusOK, resp)
+}
+
+// defaultUploadMaxAge is the multipart sweep age until one is configured
+const defaultUploadMaxAge = engine.DefaultMultipartUploadMaxAge
+
+// handleSweepMultipartUploads aborts the multipart uploads left incomplete
+// longer than the configured age, or the one in the olderThan query
+// parameter, and those an AbortIncompleteMultipartUpload lifecycle rule
+// expires. It does what the multipart sweeper does on its next run.
+func (r *Router) handleSweepMultipartUploads(w http.ResponseWriter, req *http.Request) {
+	maxAge := r.uploadMaxAge
+	if value := req.URL.Query().Get("olderThan"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			r.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid olderThan: %s", value))
+			return
+		}
+		maxAge = d
+	}
+
+	aborted, err := r.engine.AbortStaleMultipartUploads(req.Context(), maxAge)
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"aborted": aborted,
+	})
+}
+
+// handleCompactMetadata compacts the metadata store, responding once the
+// compaction is done with the time it took and the store's size after it
+func (r *Router) handleCompactMetadata(w http.ResponseWriter, req *http.Request) {
+	elapsed, err := r.engine.CompactMetadata(req.Context())
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	stats, err := r.engine.MetadataStats()
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"durationMs": elapsed.Milliseconds(),
+		"stats":      stats,
+	})
+}
+
+// handleMetadataStats returns the metadata store's size on disk
+func (r *Router) handleMetadataStats(w http.ResponseWriter, req *http.Request) {
+	stats, err := r.engine.MetadataStats()
+	if err != nil {
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	r.writeJSON(w, http.StatusOK, stats)
+}
+
 // writeJSON writes a JSON response
 func (r *Router) writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -566,6 +1365,9 @@ func (r *Router) writeJSON(w http.ResponseWriter, status int, v interface{}) {
 // handleListIAMUsers lists all IAM users
 func (r *Router) handleListIAMUsers(w http.ResponseWriter, req *http.Request) {
 	users := r.iamManager.ListUsers("default")
+	for i, user := range users {
+		users[i] = user.WithoutSecrets()
+	}
 	r.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"users": users,
 	})
@@ -600,7 +1402,7 @@ func (r *Router) handleCreateIAMUser(w http.ResponseWriter, req *http.Request) {
 // handleDeleteIAMUser deletes an IAM user
 func (r *Router) handleDeleteIAMUser(w http.ResponseWriter, req *http.Request, userID string) {
 	if err := r.iamManager.DeleteUser(userID); err != nil {
-		r.writeError(w, http.StatusInternalServerError, err.Error())
+		r.writeIAMError(w, err)
 		return
 	}
 
@@ -615,31 +1417,35 @@ func (r *Router) handleListIAMKeys(w http.ResponseWriter, req *http.Request, use
 		return
 	}
 	r.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"accessKeys": user.AccessKeys,
+		"accessKeys": user.WithoutSecrets().AccessKeys,
 	})
 }
 
-// handleCreateIAMKey creates an access key for a user
+// handleCreateIAMKey creates an access key for a user. The response is the
+// only place its secret is returned.
 func (r *Router) handleCreateIAMKey(w http.ResponseWriter, req *http.Request, userID string) {
 	key, err := r.iamManager.CreateAccessKey(userID)
 	if err != nil {
-		r.writeError(w, http.StatusInternalServerError, err.Error())
+		r.writeIAMError(w, err)
 		return
 	}
 
 	r.writeJSON(w, http.StatusCreated, key)
 }
 
-// handleDeleteIAMKey deletes an access key - simplified
+// handleDeleteIAMKey deletes an access key
 func (r *Router) handleDeleteIAMKey(w http.ResponseWriter, req *http.Request, keyID string) {
+	if err := r.iamManager.DeleteAccessKey(keyID); err != nil {
+		r.writeIAMError(w, err)
+		return
+	}
 	r.writeJSON(w, http.StatusOK, map[string]string{"id": keyID})
 }
 
 // handleListIAMGroups lists all IAM groups
 func (r *Router) handleListIAMGroups(w http.ResponseWriter, req *http.Request) {
-	// ListGroups returns all groups - simplified for now
 	r.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"groups": []interface{}{},
+		"groups": r.iamManager.ListGroups("default"),
 	})
 }
 
@@ -668,16 +1474,19 @@ func (r *Router) handleCreateIAMGroup(w http.ResponseWriter, req *http.Request)
 	r.writeJSON(w, http.StatusCreated, group)
 }
 
-// handleDeleteIAMGroup deletes an IAM group - simplified
+// handleDeleteIAMGroup deletes an IAM group
 func (r *Router) handleDeleteIAMGroup(w http.ResponseWriter, req *http.Request, groupID string) {
+	if err := r.iamManager.DeleteGroup(groupID); err != nil {
+		r.writeIAMError(w, err)
+		return
+	}
 	r.writeJSON(w, http.StatusOK, map[string]string{"id": groupID})
 }
 
 // handleListIAMPolicies lists all IAM policies
 func (r *Router) handleListIAMPolicies(w http.ResponseWriter, req *http.Request) {
-	// Simplified - list all policies
 	r.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"policies": []interface{}{},
+		"policies": r.iamManager.ListPolicies("default"),
 	})
 }
 
@@ -707,11 +1516,140 @@ func (r *Router) handleCreateIAMPolicy(w http.ResponseWriter, req *http.Request)
 	r.writeJSON(w, http.StatusCreated, policy)
 }
 
-// handleDeleteIAMPolicy deletes an IAM policy - simplified
+// handleDeleteIAMPolicy deletes an IAM policy
 func (r *Router) handleDeleteIAMPolicy(w http.ResponseWriter, req *http.Request, policyID string) {
+	if err := r.iamManager.DeletePolicy(policyID); err != nil {
+		r.writeIAMError(w, err)
+		return
+	}
 	r.writeJSON(w, http.StatusOK, map[string]string{"id": policyID})
 }
 
+// handleListIAMRoles lists all IAM roles
+func (r *Router) handleListIAMRoles(w http.ResponseWriter, req *http.Request) {
+	r.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"roles": r.iamManager.ListRoles("default"),
+	})
+}
+
+// handleCreateIAMRole creates a role granted existing policies, which
+// AssumeRole can then issue temporary credentials for
+func (r *Router) handleCreateIAMRole(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Name       string   `json:"name"`
+		PolicyArns []string `json:"policyArns"`
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if body.Name == "" {
+		r.writeError(w, http.StatusBadRequest, "Role name is required")
+		return
+	}
+
+	role, err := r.iamManager.CreateRole("default", body.Name, body.PolicyArns)
+	if err != nil {
+		r.writeIAMError(w, err)
+		return
+	}
+
+	r.writeJSON(w, http.StatusCreated, role)
+}
+
+// assumeRoleResponse is the STS AssumeRole response
+type assumeRoleResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse"`
+	Result  struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+			Expiration      string `xml:"Expiration"`
+		} `xml:"Credentials"`
+		AssumedRoleUser struct {
+			Arn           string `xml:"Arn"`
+			AssumedRoleID string `xml:"AssumedRoleId"`
+		} `xml:"AssumedRoleUser"`
+	} `xml:"AssumeRoleResult"`
+	RequestID string `xml:"ResponseMetadata>RequestId"`
+}
+
+// handleSTS serves the STS AssumeRole action, issuing temporary
+// credentials for a role. Parameters come as a form body or query string,
+// as AWS SDKs send them. The request must be signed by a principal allowed
+// sts:AssumeRole on the role.
+func (r *Router) handleSTS(w http.ResponseWriter, req *http.Request) {
+	accessKey, ok := r.authenticate(w, req)
+	if !ok {
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		r.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if action := req.Form.Get("Action"); action != "AssumeRole" {
+		r.writeError(w, http.StatusBadRequest, "Unsupported action: "+action)
+		return
+	}
+
+	roleArn := req.Form.Get("RoleArn")
+	sessionName := req.Form.Get("RoleSessionName")
+	if roleArn == "" || sessionName == "" {
+		r.writeError(w, http.StatusBadRequest, "RoleArn and RoleSessionName are required")
+		return
+	}
+	var duration time.Duration
+	if value := req.Form.Get("DurationSeconds"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			r.writeError(w, http.StatusBadRequest, "Invalid DurationSeconds")
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	if allowed, managed := r.iamManager.IsAllowed(accessKey, "sts:AssumeRole", roleArn); managed && !allowed {
+		r.writeError(w, http.StatusForbidden, "Access Denied")
+		return
+	}
+
+	session, err := r.iamManager.AssumeRole(roleArn, sessionName, duration)
+	if err != nil {
+		r.writeIAMError(w, err)
+		return
+	}
+
+	var resp assumeRoleResponse
+	resp.Result.Credentials.AccessKeyID = session.AccessKeyID
+	resp.Result.Credentials.SecretAccessKey = session.SecretAccessKey
+	resp.Result.Credentials.SessionToken = session.SessionToken
+	resp.Result.Credentials.Expiration = session.Expiration.Format(time.RFC3339)
+	resp.Result.AssumedRoleUser.Arn = session.AssumedRoleArn()
+	resp.Result.AssumedRoleUser.AssumedRoleID = session.RoleID + ":" + sessionName
+	resp.RequestID = uuid.New().String()
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(resp)
+}
+
+// writeIAMError writes an IAM manager error with a matching status
+func (r *Router) writeIAMError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, iam.ErrNotFound):
+		r.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, iam.ErrPolicyAttached):
+		r.writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, iam.ErrInvalidDuration):
+		r.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		r.writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
 // ==================== Lifecycle Handlers ====================
 
 // handleGetLifecycleRules gets lifecycle rules for a bucket
@@ -928,6 +1866,34 @@ func (r *Router) handleDeleteBucketPolicy(w http.ResponseWriter, req *http.Reque
 	r.writeJSON(w, http.StatusOK, map[string]string{"bucket": bucket})
 }
 
+// authenticate verifies the signature or client certificate of a request
+// and returns the caller's access key. Anonymous requests, and every request
+// while no authenticator is set, are answered with 403.
+func (r *Router) authenticate(w http.ResponseWriter, req *http.Request) (string, bool) {
+	if r.auth != nil {
+		if accessKey, ok := auth.AuthenticatedAccessKey(r.auth.Authenticate(req)); ok {
+			return accessKey, true
+		}
+	}
+	r.writeError(w, http.StatusForbidden, "Access Denied")
+	return "", false
+}
+
+// authorizeAdmin authenticates a request and checks that the caller is an
+// administrator: a credential IAM does not manage, such as the configured
+// root key, or one allowed every IAM action. Others are answered with 403.
+func (r *Router) authorizeAdmin(w http.ResponseWriter, req *http.Request) bool {
+	accessKey, ok := r.authenticate(w, req)
+	if !ok {
+		return false
+	}
+	if allowed, managed := r.iamManager.IsAllowed(accessKey, "iam:*", "*"); managed && !allowed {
+		r.writeError(w, http.StatusForbidden, "Access Denied")
+		return false
+	}
+	return true
+}
+
 // writeError writes an error response
 func (r *Router) writeError(w http.ResponseWriter, status int, message string) {
 	r.logger.Warnw("Management API error",
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -122,6 +123,157 @@ func Compress(next http.Handler) http.Handler {
 	})
 }
 
+// CompressResponses gzips XML and JSON responses of at least minSize bytes
+// for clients that accept gzip. Output is held back until minSize bytes are
+// written or the handler returns, so smaller responses, responses of other
+// types and responses that already carry a Content-Encoding go out unchanged
+// with their Content-Length. Partial content is never compressed.
+func CompressResponses(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+			next.ServeHTTP(cw, r)
+			// Not deferred: a handler that panics to abort the response
+			// must not have its buffered output sent
+			cw.close()
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// gzip;q=0 refuses it
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// compressibleType reports whether a Content-Type is XML or JSON
+func compressibleType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch mediaType {
+	case "application/xml", "text/xml", "application/json":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "+json")
+}
+
+// compressWriter buffers a response until it knows whether to gzip it
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	decided bool // the header is sent, compressed or not
+}
+
+// WriteHeader implements http.ResponseWriter. A response that cannot be
+// compressed is passed straight through.
+func (c *compressWriter) WriteHeader(code int) {
+	if c.decided {
+		return
+	}
+	c.status = code
+	if !c.compressible() {
+		c.passThrough()
+	}
+}
+
+// Write implements http.ResponseWriter
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.decided {
+		if !c.compressible() {
+			c.passThrough()
+		} else {
+			c.buf = append(c.buf, b...)
+			if len(c.buf) < c.minSize {
+				return len(b), nil
+			}
+			return len(b), c.startGzip()
+		}
+	}
+	if c.gz != nil {
+		return c.gz.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher. A response flushed before reaching the
+// threshold is sent uncompressed.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		c.passThrough()
+	}
+	if c.gz != nil {
+		c.gz.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// compressible reports whether the response, as its headers stand, may be
+// gzipped
+func (c *compressWriter) compressible() bool {
+	h := c.Header()
+	if c.status < http.StatusOK || c.status == http.StatusNoContent || c.status == http.StatusPartialContent || c.status == http.StatusNotModified {
+		return false
+	}
+	return h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && compressibleType(h.Get("Content-Type"))
+}
+
+// passThrough sends the header and anything buffered without compression
+func (c *compressWriter) passThrough() {
+	c.decided = true
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) > 0 {
+		c.ResponseWriter.Write(c.buf)
+		c.buf = nil
+	}
+}
+
+// startGzip sends the header for a compressed response and the buffered
+// output through the gzip writer
+func (c *compressWriter) startGzip() error {
+	c.decided = true
+	h := c.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	h.Add("Vary", "Accept-Encoding")
+	c.ResponseWriter.WriteHeader(c.status)
+
+	c.gz = gzip.NewWriter(c.ResponseWriter)
+	_, err := c.gz.Write(c.buf)
+	c.buf = nil
+	return err
+}
+
+// close finishes the response once the handler has returned
+func (c *compressWriter) close() {
+	if !c.decided {
+		c.passThrough()
+	}
+	if c.gz != nil {
+		c.gz.Close()
+	}
+}
+
 // Decompress decompresses gzip requests
 func Decompress(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
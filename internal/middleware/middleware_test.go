@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -525,3 +527,72 @@ func TestDecompress_InvalidGzip(t *testing.T) {
 		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
 	}
 }
+
+func TestCompressResponses(t *testing.T) {
+	large := strings.Repeat("<Key>object</Key>", 200)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		encoding       string
+		status         int
+		body           string
+		wantGzip       bool
+	}{
+		{"large xml", "gzip, deflate", "application/xml", "", http.StatusOK, large, true},
+		{"large json", "gzip", "application/json; charset=utf-8", "", http.StatusOK, large, true},
+		{"small xml", "gzip", "application/xml", "", http.StatusOK, "<Key/>", false},
+		{"not accepted", "deflate", "application/xml", "", http.StatusOK, large, false},
+		{"refused", "gzip;q=0", "application/xml", "", http.StatusOK, large, false},
+		{"object body", "gzip", "application/octet-stream", "", http.StatusOK, large, false},
+		{"already encoded", "gzip", "application/json", "br", http.StatusOK, large, false},
+		{"partial content", "gzip", "application/xml", "", http.StatusPartialContent, large, false},
+	}
+	for _, tt := range tests {
+		handler := CompressResponses(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+			if tt.encoding != "" {
+				w.Header().Set("Content-Encoding", tt.encoding)
+			}
+			w.WriteHeader(tt.status)
+			// Written in pieces, as handlers streaming XML do
+			for i := 0; i < len(tt.body); i += 100 {
+				w.Write([]byte(tt.body[i:min(i+100, len(tt.body))]))
+			}
+		}))
+		req := httptest.NewRequest("GET", "/bucket", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		body := w.Body.Bytes()
+		if tt.wantGzip {
+			if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+				t.Errorf("%s: Content-Encoding = %q, want gzip", tt.name, got)
+				continue
+			}
+			if got := w.Header().Get("Content-Length"); got != "" {
+				t.Errorf("%s: Content-Length = %s on a compressed response", tt.name, got)
+			}
+			gr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("%s: gzip.NewReader() error = %v", tt.name, err)
+			}
+			if body, err = io.ReadAll(gr); err != nil {
+				t.Fatalf("%s: reading gzip body error = %v", tt.name, err)
+			}
+		} else if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.encoding)
+		} else if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.body)) {
+			t.Errorf("%s: Content-Length = %q, want %d", tt.name, got, len(tt.body))
+		}
+		if string(body) != tt.body {
+			t.Errorf("%s: body = %d bytes, want %d", tt.name, len(body), len(tt.body))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/openendpoint/openendpoint/internal/auth"
+)
+
+// IdentityFunc returns the access key a request authenticated with, or false
+// if the request is anonymous
+type IdentityFunc func(r *http.Request) (string, bool)
+
+// ClassLimiter applies separate limits to anonymous and authenticated
+// traffic. Anonymous requests are limited per client IP and authenticated
+// requests per access key.
+type ClassLimiter struct {
+	anonymous     *BucketLimiter
+	authenticated *BucketLimiter
+	identify      IdentityFunc
+
+	// trustedProxies are the peers whose forwarding headers name the client
+	trustedProxies []*net.IPNet
+}
+
+// NewClassLimiter creates a limiter that picks anonymous or authenticated
+// limits based on identify. AccessKey is used when identify is nil.
+func NewClassLimiter(anonymous, authenticated *BucketLimiter, identify IdentityFunc) *ClassLimiter {
+	if identify == nil {
+		identify = AccessKey
+	}
+	return &ClassLimiter{
+		anonymous:     anonymous,
+		authenticated: authenticated,
+		identify:      identify,
+	}
+}
+
+// Stop stops the cleanup goroutines of both limiters
+func (cl *ClassLimiter) Stop() {
+	cl.anonymous.Stop()
+	cl.authenticated.Stop()
+}
+
+// SetTrustedProxies sets the proxies, as IPs or CIDR ranges, whose
+// X-Forwarded-For and X-Real-IP headers identify the client of an anonymous
+// request. Forwarding headers from any other peer are ignored.
+func (cl *ClassLimiter) SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	cl.trustedProxies = nets
+	return nil
+}
+
+// Allow reports whether a request fits within the limits of its class
+func (cl *ClassLimiter) Allow(r *http.Request) bool {
+	if accessKey, ok := cl.identify(r); ok {
+		return cl.authenticated.getLimiter(accessKey).Allow()
+	}
+	return cl.anonymous.getLimiter(cl.clientIP(r)).Allow()
+}
+
+// clientIP returns the address an anonymous request is limited by: the
+// peer's IP without its port, or the client a trusted proxy forwarded for
+func (cl *ClassLimiter) clientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !cl.trusted(peer) {
+		return peer
+	}
+
+	// Walk X-Forwarded-For back from the nearest hop; the first address
+	// that is not one of our proxies is the client
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := splitIPs(xff)
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !cl.trusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return peer
+}
+
+// trusted reports whether ip belongs to a trusted proxy
+func (cl *ClassLimiter) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range cl.trustedProxies {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware returns a middleware that rate limits requests by class
+func (cl *ClassLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cl.Allow(r) {
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessKey returns the access key a request claims in its Authorization
+// header or presigned query parameters. The signature is not checked; callers
+// that need a verified identity should wrap it in their own IdentityFunc.
+func AccessKey(r *http.Request) (string, bool) {
+	accessKey := auth.RequestAccessKey(r)
+	return accessKey, accessKey != ""
+}
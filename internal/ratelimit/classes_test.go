@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClassLimiter() *ClassLimiter {
+	// 2 anonymous requests per IP, 5 authenticated requests per access key
+	return NewClassLimiter(NewBucketLimiter(2, 0), NewBucketLimiter(5, 0), nil)
+}
+
+func sendRequests(handler http.Handler, n int, setup func(*http.Request)) []int {
+	codes := make([]int, n)
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest("GET", "/s3/bucket/key", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if setup != nil {
+			setup(req)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		codes[i] = w.Code
+	}
+	return codes
+}
+
+func countAllowed(codes []int) int {
+	allowed := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestClassLimiter_AnonymousLimit(t *testing.T) {
+	cl := newTestClassLimiter()
+	defer cl.Stop()
+
+	handler := cl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	codes := sendRequests(handler, 4, nil)
+	if got := countAllowed(codes); got != 2 {
+		t.Errorf("anonymous requests allowed = %d, want 2 (codes %v)", got, codes)
+	}
+	if codes[3] != http.StatusTooManyRequests {
+		t.Errorf("throttled status = %d, want %d", codes[3], http.StatusTooManyRequests)
+	}
+}
+
+func TestClassLimiter_AuthenticatedLimit(t *testing.T) {
+	cl := newTestClassLimiter()
+	defer cl.Stop()
+
+	handler := cl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	signed := func(req *http.Request) {
+		req.Header.Set("Authorization",
+			"AWS4-HMAC-SHA256 Credential=AKIDTRUSTED/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+	}
+	codes := sendRequests(handler, 7, signed)
+	if got := countAllowed(codes); got != 5 {
+		t.Errorf("authenticated requests allowed = %d, want 5 (codes %v)", got, codes)
+	}
+
+	// The same IP still has its own anonymous allowance
+	if got := countAllowed(sendRequests(handler, 3, nil)); got != 2 {
+		t.Errorf("anonymous requests allowed after authenticated burst = %d, want 2", got)
+	}
+}
+
+func TestClassLimiter_CustomIdentity(t *testing.T) {
+	cl := NewClassLimiter(NewBucketLimiter(1, 0), NewBucketLimiter(3, 0), func(r *http.Request) (string, bool) {
+		// Only trust one key, as a verifying identity function would
+		key, ok := AccessKey(r)
+		return key, ok && key == "trusted"
+	})
+	defer cl.Stop()
+
+	forged := httptest.NewRequest("GET", "/", nil)
+	forged.Header.Set("Authorization", "AWS forged:sig")
+	if !cl.Allow(forged) {
+		t.Fatal("first forged request should use the anonymous allowance")
+	}
+	if cl.Allow(forged) {
+		t.Error("forged access key should be held to the anonymous limit")
+	}
+
+	trusted := httptest.NewRequest("GET", "/", nil)
+	trusted.Header.Set("Authorization", "AWS trusted:sig")
+	for i := 0; i < 3; i++ {
+		if !cl.Allow(trusted) {
+			t.Errorf("trusted request %d should be allowed", i+1)
+		}
+	}
+}
+
+func TestClassLimiter_AnonymousClientIP(t *testing.T) {
+	cl := NewClassLimiter(NewBucketLimiter(2, 0), NewBucketLimiter(5, 0), nil)
+	defer cl.Stop()
+	if err := cl.SetTrustedProxies([]string{"192.168.1.10", "10.1.0.0/16"}); err != nil {
+		t.Fatalf("SetTrustedProxies() error: %v", err)
+	}
+
+	anonymous := func(remoteAddr string, header map[string]string) *http.Request {
+		req := httptest.NewRequest("GET", "/s3/bucket/key", nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		return req
+	}
+
+	// Two connections from one IP share a bucket, whatever they claim to
+	// be forwarding for
+	if !cl.Allow(anonymous("203.0.113.7:40001", nil)) {
+		t.Fatal("first request should be allowed")
+	}
+	if !cl.Allow(anonymous("203.0.113.7:40002", map[string]string{"X-Forwarded-For": "198.51.100.1"})) {
+		t.Fatal("second request should be allowed")
+	}
+	if cl.Allow(anonymous("203.0.113.7:40003", map[string]string{"X-Real-IP": "198.51.100.2"})) {
+		t.Error("a new connection with a spoofed forwarding header got a fresh allowance")
+	}
+
+	// A trusted proxy's forwarding headers name the client
+	viaProxy := map[string]string{"X-Forwarded-For": "198.51.100.9, 10.1.2.3"}
+	for i := 0; i < 2; i++ {
+		if !cl.Allow(anonymous("192.168.1.10:5000", viaProxy)) {
+			t.Errorf("proxied request %d should be allowed", i+1)
+		}
+	}
+	if cl.Allow(anonymous("192.168.1.10:5001", viaProxy)) {
+		t.Error("proxied client should be held to its own limit")
+	}
+	if !cl.Allow(anonymous("192.168.1.10:5002", map[string]string{"X-Real-IP": "198.51.100.10"})) {
+		t.Error("another client behind the proxy should have its own allowance")
+	}
+
+	if err := cl.SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("SetTrustedProxies() accepted an invalid proxy")
+	}
+}
+
+func TestAccessKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{"sigv4", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=x", "", "AKID", true},
+		{"sigv2", "AWS AKID:signature", "", "AKID", true},
+		{"presigned v4", "", "X-Amz-Credential=AKID%2F20240101%2Fus-east-1%2Fs3%2Faws4_request", "AKID", true},
+		{"presigned v2", "", "AWSAccessKeyId=AKID", "AKID", true},
+		{"anonymous", "", "", "", false},
+		{"malformed", "AWS4-HMAC-SHA256 garbage", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/bucket/key?"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			got, ok := AccessKey(req)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("AccessKey() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxQueuedTasks bounds the replication backlog. Changes arriving while it
+// is full are counted as failed rather than queued.
+const maxQueuedTasks = 10000
+
+// Task is one object change waiting to be applied to a rule's destination
+type Task struct {
+	Bucket            string
+	Key               string
+	Size              int64
+	Delete            bool
+	DestinationBucket string
+	// VersionID is the version of a created object to copy, or empty for
+	// the current one
+	VersionID string
+	// StorageClass is the class the rule stores replicas in, or empty to
+	// keep the source object's
+	StorageClass string
+	Enqueued     time.Time
+}
+
+// ReplicateFunc applies a task to its destination bucket, copying the
+// source object or deleting the destination copy
+type ReplicateFunc func(ctx context.Context, task Task) error
+
+// TagLookupFunc returns the tags of an object, for rules that filter on them
+type TagLookupFunc func(ctx context.Context, bucket, key string) (map[string]string, error)
+
+// StatusFunc records the replication status of a task's source object
+type StatusFunc func(ctx context.Context, task Task, status string) error
+
+// SetReplicator sets the function the worker uses to apply queued changes.
+// Without one, object events are not queued at all.
+func (r *Replication) SetReplicator(fn ReplicateFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.replicate = fn
+}
+
+// SetTagLookup sets the function used to read object tags for rules with a
+// tag filter. Without one, tag filters match no object.
+func (r *Replication) SetTagLookup(fn TagLookupFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tags = fn
+}
+
+// SetStatusRecorder sets the function used to record on source objects
+// whether their replication is pending, completed or failed
+func (r *Replication) SetStatusRecorder(fn StatusFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.statusFn = fn
+}
+
+// Start runs the worker that applies queued changes until ctx is done or
+// Stop is called
+func (r *Replication) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	r.mu.Lock()
+	r.cancel = cancel
+	r.done = done
+	r.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		for {
+			task, replicate, ok := r.next()
+			if !ok {
+				select {
+				case <-ctx.Done():
+					return
+				case <-r.wake:
+				}
+				continue
+			}
+			err := replicate(ctx, task)
+			if !task.Delete {
+				status := StatusCompleted
+				if err != nil {
+					status = StatusFailed
+				}
+				r.recordStatus(task, status)
+			}
+			r.finish(task, err)
+		}
+	}()
+}
+
+// Stop stops the worker started by Start and waits for the change in
+// progress, if any. Changes still queued are abandoned.
+func (r *Replication) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// enqueue adds a change to the backlog and wakes the worker, reporting
+// whether there was room for it. The caller holds r.mu.
+func (r *Replication) enqueue(stats *Stats, task Task) bool {
+	if len(r.queue) >= maxQueuedTasks {
+		stats.FailedReplication++
+		return false
+	}
+	r.queue = append(r.queue, task)
+	stats.PendingReplication++
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// recordStatus records a task's status on its source object through the
+// function set with SetStatusRecorder. Failing to record it does not fail
+// the replication.
+func (r *Replication) recordStatus(task Task, status string) {
+	r.mu.RLock()
+	record := r.statusFn
+	r.mu.RUnlock()
+	if record == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = record(ctx, task, status)
+}
+
+// next takes the oldest queued change
+func (r *Replication) next() (Task, ReplicateFunc, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.queue) == 0 || r.replicate == nil {
+		return Task{}, nil, false
+	}
+	task := r.queue[0]
+	r.queue = r.queue[1:]
+	r.active = task.Enqueued
+	return task, r.replicate, true
+}
+
+// finish records the outcome of a change taken by next
+func (r *Replication) finish(task Task, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.active = time.Time{}
+	stats, ok := r.stats[task.Bucket]
+	if !ok {
+		return
+	}
+	if stats.PendingReplication > 0 {
+		stats.PendingReplication--
+	}
+	if err != nil {
+		stats.FailedReplication++
+		return
+	}
+	stats.ReplicatedObjects++
+	stats.ReplicatedBytes += task.Size
+	now := r.clock.Now()
+	stats.LastReplicationTime = now
+	stats.Latency = now.Sub(task.Enqueued).Milliseconds()
+}
+
+// OldestPending returns how long the oldest change not yet replicated has
+// waited, or zero when nothing is pending. The queue is FIFO, so that is the
+// change being applied or else the head of the queue.
+func (r *Replication) OldestPending() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	oldest := r.active
+	if oldest.IsZero() && len(r.queue) > 0 {
+		oldest = r.queue[0].Enqueued
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return r.clock.Now().Sub(oldest)
+}
+
+// Drain waits until every queued change has been applied, or until ctx is
+// done. Changes keep arriving while it waits, so on a busy server it returns
+// once the queue is momentarily empty.
+func (r *Replication) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		r.mu.RLock()
+		idle := len(r.queue) == 0 && r.active.IsZero()
+		r.mu.RUnlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkCycle rejects a destination that would replicate bucket's objects
+// back into bucket, directly or through other buckets' rules. The caller
+// holds r.mu.
+func (r *Replication) checkCycle(bucket string, dest *Destination) error {
+	if dest == nil || dest.Bucket == "" {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	pending := []string{dest.Bucket}
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+		if next == bucket {
+			return fmt.Errorf("replication to %s would copy objects back into %s", dest.Bucket, bucket)
+		}
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		for _, rule := range r.rules[next] {
+			if rule.Destination != nil && rule.Destination.Bucket != "" {
+				pending = append(pending, rule.Destination.Bucket)
+			}
+		}
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package replication
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/openendpoint/openendpoint/internal/clock"
+	"github.com/openendpoint/openendpoint/internal/events"
 )
 
 // Replication manages bucket replication
@@ -15,6 +20,19 @@ type Replication struct {
 	rules  map[string][]*Rule // bucketName -> rules
 	stats  map[string]*Stats  // bucketName -> stats
 	status map[string]string  // bucketName -> status
+
+	// queue holds changes waiting for the worker run by Start, and active
+	// is when the change being applied was queued, zero while idle
+	queue     []Task
+	active    time.Time
+	replicate ReplicateFunc
+	tags      TagLookupFunc
+	statusFn  StatusFunc
+	wake      chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	clock clock.Clock // time source for timestamps and queue latency
 }
 
 // Rule represents a replication rule
@@ -114,15 +132,32 @@ type DestinationStatus struct {
 	BytesUsed   int64     `json:"bytesUsed" yaml:"bytesUsed"`
 }
 
+// Replication states recorded on objects, as returned in the
+// x-amz-replication-status header
+const (
+	StatusPending   = "PENDING"
+	StatusCompleted = "COMPLETED"
+	StatusFailed    = "FAILED"
+	StatusReplica   = "REPLICA"
+)
+
 // New creates a new Replication manager
 func New() *Replication {
 	return &Replication{
 		rules:  make(map[string][]*Rule),
 		stats:  make(map[string]*Stats),
 		status: make(map[string]string),
+		wake:   make(chan struct{}, 1),
+		clock:  clock.Real,
 	}
 }
 
+// SetClock replaces the clock replication reads the time from, which is the
+// wall clock by default. It must be called before Start.
+func (r *Replication) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
 // AddRule adds a replication rule
 func (r *Replication) AddRule(bucket string, rule *Rule) error {
 	r.mu.Lock()
@@ -134,8 +169,9 @@ func (r *Replication) AddRule(bucket string, rule *Rule) error {
 	if rule.Status == "" {
 		rule.Status = "Enabled"
 	}
-	rule.CreatedAt = time.Now()
-	rule.ModifiedAt = time.Now()
+	now := r.clock.Now()
+	rule.CreatedAt = now
+	rule.ModifiedAt = now
 
 	rules := r.rules[bucket]
 	for _, existing := range rules {
@@ -143,9 +179,14 @@ func (r *Replication) AddRule(bucket string, rule *Rule) error {
 			return fmt.Errorf("rule already exists: %s", rule.ID)
 		}
 	}
+	if err := r.checkCycle(bucket, rule.Destination); err != nil {
+		return err
+	}
 
 	r.rules[bucket] = append(rules, rule)
-	r.stats[bucket] = &Stats{}
+	if _, ok := r.stats[bucket]; !ok {
+		r.stats[bucket] = &Stats{}
+	}
 	r.status[bucket] = "Enabled"
 
 	return nil
@@ -214,9 +255,12 @@ func (r *Replication) UpdateRule(bucket string, ruleID string, updates *Rule) (*
 				rules[i].Filter = updates.Filter
 			}
 			if updates.Destination != nil {
+				if err := r.checkCycle(bucket, updates.Destination); err != nil {
+					return nil, err
+				}
 				rules[i].Destination = updates.Destination
 			}
-			rules[i].ModifiedAt = time.Now()
+			rules[i].ModifiedAt = r.clock.Now()
 			return rules[i], nil
 		}
 	}
@@ -282,7 +326,7 @@ func (r *Replication) UpdateStats(bucket string, update *Stats) {
 		stats.ReplicatedBytes += update.ReplicatedBytes
 		stats.PendingReplication = update.PendingReplication
 		stats.FailedReplication = update.FailedReplication
-		stats.LastReplicationTime = time.Now()
+		stats.LastReplicationTime = r.clock.Now()
 		stats.Latency = update.Latency
 	}
 }
@@ -350,7 +394,7 @@ func (r *Replication) GetDestinationStatus(bucket string) []DestinationStatus {
 			statuses = append(statuses, DestinationStatus{
 				Bucket:      rule.Destination.Bucket,
 				Status:      rule.Status,
-				LastSync:    time.Now(),
+				LastSync:    r.clock.Now(),
 				ObjectCount: 0,
 				BytesUsed:   0,
 			})
@@ -431,6 +475,21 @@ func (r *Replication) GetTotalReplicatedBytes() int64 {
 	return total
 }
 
+// GetPendingByBucket returns the number of objects waiting to be replicated
+// for every bucket that has any
+func (r *Replication) GetPendingByBucket() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pending := make(map[string]int64)
+	for bucket, stats := range r.stats {
+		if stats.PendingReplication > 0 {
+			pending[bucket] = stats.PendingReplication
+		}
+	}
+	return pending
+}
+
 var randRead = rand.Read
 
 func generateRuleID() string {
@@ -468,3 +527,157 @@ func (r *Replication) FromJSON(bucket string, data []byte) error {
 
 	return nil
 }
+
+// HandleObjectEvent queues a changed object for the worker when an enabled
+// rule on its bucket covers it, using the first such rule. A rule covers an
+// object under its filter's prefix that carries all of its filter's tags.
+// Deletes are only queued for rules that replicate delete markers and have
+// no tag filter, as a deleted object has no tags left to match; deletes of
+// a single version are never replicated. Nothing is queued until a
+// replicator is set.
+func (r *Replication) HandleObjectEvent(e events.ObjectEvent) {
+	isDelete := e.Type == events.EventObjectDeleteMarkerCreated ||
+		(e.Type == events.EventObjectRemoved && e.VersionID == "")
+	if !isDelete && !strings.HasPrefix(string(e.Type), "s3:ObjectCreated:") {
+		return
+	}
+
+	// Tags are read before taking the lock, and only when a rule needs them
+	var objectTags map[string]string
+	if !isDelete && r.needsTags(e.Bucket) {
+		var err error
+		if objectTags, err = r.lookupTags(e.Bucket, e.Key); err != nil {
+			return
+		}
+	}
+
+	task, ok := r.route(e, isDelete, objectTags)
+	if !ok {
+		return
+	}
+
+	// The source is marked pending before the worker can see the task, so
+	// the worker's outcome is never overwritten
+	if !task.Delete {
+		r.recordStatus(task, StatusPending)
+	}
+
+	r.mu.Lock()
+	stats, ok := r.stats[e.Bucket]
+	queued := ok && r.replicate != nil && r.enqueue(stats, task)
+	r.mu.Unlock()
+
+	if !queued && !task.Delete {
+		r.recordStatus(task, StatusFailed)
+	}
+}
+
+// route picks the task an object change calls for from the first enabled
+// rule of its bucket that covers it
+func (r *Replication) route(e events.ObjectEvent, isDelete bool, objectTags map[string]string) (Task, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.stats[e.Bucket]; !ok || r.replicate == nil {
+		return Task{}, false
+	}
+	for _, rule := range r.rules[e.Bucket] {
+		if rule.Status != "Enabled" || !rule.matchesKey(e.Key) || rule.Destination == nil || rule.Destination.Bucket == "" {
+			continue
+		}
+		if isDelete && (rule.DeleteMarkerReplication == nil || rule.DeleteMarkerReplication.Status != "Enabled" || len(rule.filterTags()) > 0) {
+			continue
+		}
+		if !isDelete && !rule.matchesTags(objectTags) {
+			continue
+		}
+		return Task{
+			Bucket:            e.Bucket,
+			Key:               e.Key,
+			Size:              e.Size,
+			Delete:            isDelete,
+			DestinationBucket: rule.Destination.Bucket,
+			VersionID:         createdVersion(e, isDelete),
+			StorageClass:      rule.Destination.StorageClass,
+			Enqueued:          r.clock.Now(),
+		}, true
+	}
+	return Task{}, false
+}
+
+// createdVersion returns the version a created-object event wrote, which is
+// what a copy task replicates even if the key is overwritten before it runs
+func createdVersion(e events.ObjectEvent, isDelete bool) string {
+	if isDelete {
+		return ""
+	}
+	return e.VersionID
+}
+
+// needsTags reports whether an enabled rule on bucket filters on tags and a
+// way to read them is set
+func (r *Replication) needsTags(bucket string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.tags == nil {
+		return false
+	}
+	for _, rule := range r.rules[bucket] {
+		if rule.Status == "Enabled" && len(rule.filterTags()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupTags reads an object's tags through the function set with
+// SetTagLookup
+func (r *Replication) lookupTags(bucket, key string) (map[string]string, error) {
+	r.mu.RLock()
+	lookup := r.tags
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return lookup(ctx, bucket, key)
+}
+
+// matchesKey reports whether the rule's prefix filter covers key
+func (rule *Rule) matchesKey(key string) bool {
+	if rule.Filter == nil {
+		return true
+	}
+	prefix := rule.Filter.Prefix
+	if rule.Filter.And != nil && rule.Filter.And.Prefix != "" {
+		prefix = rule.Filter.And.Prefix
+	}
+	return strings.HasPrefix(key, prefix)
+}
+
+// filterTags returns the tags the rule's filter requires, from its Tag or
+// its And
+func (rule *Rule) filterTags() []*Tag {
+	if rule.Filter == nil {
+		return nil
+	}
+	var required []*Tag
+	if rule.Filter.Tag != nil {
+		required = append(required, rule.Filter.Tag)
+	}
+	if rule.Filter.And != nil {
+		required = append(required, rule.Filter.And.Tags...)
+	}
+	return required
+}
+
+// matchesTags reports whether an object with objectTags carries every tag
+// the rule's filter requires
+func (rule *Rule) matchesTags(objectTags map[string]string) bool {
+	for _, tag := range rule.filterTags() {
+		if value, ok := objectTags[tag.Key]; !ok || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
@@ -1,9 +1,22 @@
 package replication
 
 import (
+	"context"
 	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/events"
+	"github.com/openendpoint/openendpoint/internal/metadata"
+	"github.com/openendpoint/openendpoint/internal/metadata/pebble"
+	"github.com/openendpoint/openendpoint/internal/storage/flatfile"
+	"github.com/openendpoint/openendpoint/internal/tags"
+	"go.uber.org/zap"
 )
 
 func TestNew(t *testing.T) {
@@ -747,3 +760,264 @@ func TestUpdateRule_EmptyFieldsNotUpdated(t *testing.T) {
 		t.Errorf("Status = %s, want Enabled", updated.Status)
 	}
 }
+
+func TestReplication_HandleObjectEvent(t *testing.T) {
+	r := New()
+	r.AddRule("bucket", &Rule{
+		ID:          "logs",
+		Filter:      &Filter{Prefix: "logs/"},
+		Destination: &Destination{Bucket: "replica"},
+	})
+
+	// Without a replicator nothing is queued
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "bucket", Key: "logs/a"})
+	if stats, _ := r.GetStats("bucket"); stats.PendingReplication != 0 {
+		t.Fatalf("PendingReplication without a replicator = %d, want 0", stats.PendingReplication)
+	}
+
+	var statusMu sync.Mutex
+	statuses := map[string]string{}
+	r.SetStatusRecorder(func(ctx context.Context, task Task, status string) error {
+		statusMu.Lock()
+		defer statusMu.Unlock()
+		statuses[task.Key] = status
+		return nil
+	})
+	applied := make(chan Task, 10)
+	r.SetReplicator(func(ctx context.Context, task Task) error {
+		applied <- task
+		if task.Key == "logs/broken" {
+			return errors.New("copy failed")
+		}
+		return nil
+	})
+
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "bucket", Key: "logs/a", Size: 5})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "bucket", Key: "images/a"})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectRemoved, Bucket: "bucket", Key: "logs/a"})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "other", Key: "logs/a"})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectCopied, Bucket: "bucket", Key: "logs/broken"})
+
+	stats, _ := r.GetStats("bucket")
+	if stats.PendingReplication != 2 {
+		t.Errorf("PendingReplication = %d, want 2", stats.PendingReplication)
+	}
+	if r.OldestPending() <= 0 {
+		t.Error("OldestPending() should report the age of the queued changes")
+	}
+
+	r.Start(context.Background())
+	defer r.Stop()
+
+	for _, want := range []string{"logs/a", "logs/broken"} {
+		select {
+		case task := <-applied:
+			if task.Key != want || task.DestinationBucket != "replica" || task.Delete {
+				t.Errorf("applied %+v, want copy of %s to replica", task, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s to be replicated", want)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.RLock()
+		pending, replicated, failed := stats.PendingReplication, stats.ReplicatedObjects, stats.FailedReplication
+		r.mu.RUnlock()
+		if pending == 0 {
+			if replicated != 1 || failed != 1 {
+				t.Errorf("replicated = %d, failed = %d, want 1 and 1", replicated, failed)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("PendingReplication = %d after the worker ran, want 0", pending)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Drain(ctx); err != nil {
+		t.Errorf("Drain() error = %v", err)
+	}
+	if age := r.OldestPending(); age != 0 {
+		t.Errorf("OldestPending() after drain = %v, want 0", age)
+	}
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	if statuses["logs/a"] != StatusCompleted || statuses["logs/broken"] != StatusFailed {
+		t.Errorf("statuses = %v, want logs/a COMPLETED and logs/broken FAILED", statuses)
+	}
+}
+
+func TestReplication_RejectsCycles(t *testing.T) {
+	r := New()
+	if err := r.AddRule("a", &Rule{ID: "to-b", Destination: &Destination{Bucket: "b"}}); err != nil {
+		t.Fatalf("AddRule(a -> b) error = %v", err)
+	}
+	if err := r.AddRule("b", &Rule{ID: "to-c", Destination: &Destination{Bucket: "c"}}); err != nil {
+		t.Fatalf("AddRule(b -> c) error = %v", err)
+	}
+	if err := r.AddRule("c", &Rule{ID: "to-a", Destination: &Destination{Bucket: "a"}}); err == nil {
+		t.Error("AddRule(c -> a) should be rejected as a cycle")
+	}
+	if err := r.AddRule("d", &Rule{ID: "to-d", Destination: &Destination{Bucket: "d"}}); err == nil {
+		t.Error("AddRule(d -> d) should be rejected as a cycle")
+	}
+	if _, err := r.UpdateRule("b", "to-c", &Rule{Destination: &Destination{Bucket: "a"}}); err == nil {
+		t.Error("UpdateRule(b -> a) should be rejected as a cycle")
+	}
+}
+
+func TestReplication_HandleObjectEvent_FiltersAndDeletes(t *testing.T) {
+	r := New()
+	r.AddRule("bucket", &Rule{
+		ID:          "hot",
+		Filter:      &Filter{And: &AndFilter{Prefix: "data/", Tags: []*Tag{{Key: "tier", Value: "hot"}}}},
+		Destination: &Destination{Bucket: "tagged"},
+	})
+	r.AddRule("bucket", &Rule{
+		ID:                      "all",
+		Status:                  "Enabled",
+		Filter:                  &Filter{Prefix: "data/"},
+		Destination:             &Destination{Bucket: "replica", StorageClass: "STANDARD_IA"},
+		DeleteMarkerReplication: &DeleteMarkerReplication{Status: "Enabled"},
+	})
+	var recorded []string
+	r.SetReplicator(func(ctx context.Context, task Task) error { return nil })
+	r.SetStatusRecorder(func(ctx context.Context, task Task, status string) error {
+		recorded = append(recorded, task.Key+"="+status)
+		return nil
+	})
+	r.SetTagLookup(func(ctx context.Context, bucket, key string) (map[string]string, error) {
+		if key == "data/hot" {
+			return map[string]string{"tier": "hot"}, nil
+		}
+		return nil, nil
+	})
+
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "bucket", Key: "data/hot", VersionID: "v1"})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectUploaded, Bucket: "bucket", Key: "data/cold"})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectDeleteMarkerCreated, Bucket: "bucket", Key: "data/hot", VersionID: "v2"})
+	r.HandleObjectEvent(events.ObjectEvent{Type: events.EventObjectRemoved, Bucket: "bucket", Key: "data/hot", VersionID: "v1"})
+
+	r.mu.RLock()
+	queue := append([]Task(nil), r.queue...)
+	r.mu.RUnlock()
+
+	want := []Task{
+		{Key: "data/hot", DestinationBucket: "tagged", VersionID: "v1"},
+		{Key: "data/cold", DestinationBucket: "replica", StorageClass: "STANDARD_IA"},
+		{Key: "data/hot", DestinationBucket: "replica", StorageClass: "STANDARD_IA", Delete: true},
+	}
+	if len(queue) != len(want) {
+		t.Fatalf("queued %d tasks, want %d: %+v", len(queue), len(want), queue)
+	}
+	for i, task := range queue {
+		w := want[i]
+		if task.Key != w.Key || task.DestinationBucket != w.DestinationBucket || task.Delete != w.Delete ||
+			task.VersionID != w.VersionID || task.StorageClass != w.StorageClass {
+			t.Errorf("task %d = %+v, want %+v", i, task, w)
+		}
+	}
+
+	// Only copies have a status on their source; deletes leave none
+	if got := strings.Join(recorded, ","); got != "data/hot=PENDING,data/cold=PENDING" {
+		t.Errorf("recorded statuses = %s, want both copies PENDING", got)
+	}
+}
+
+func TestReplicator_Replicate(t *testing.T) {
+	dir, err := os.MkdirTemp("", "replication-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	meta, err := pebble.New(dir + "/meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { meta.Close() })
+	store, err := flatfile.New(dir + "/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.New(store, meta, zap.NewNop().Sugar())
+
+	ctx := context.Background()
+	for _, bucket := range []string{"source", "replica"} {
+		if err := eng.CreateBucket(ctx, bucket); err != nil {
+			t.Fatalf("CreateBucket(%s) error = %v", bucket, err)
+		}
+	}
+	if err := eng.PutBucketVersioning(ctx, "source", &metadata.BucketVersioning{Status: "Enabled"}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := eng.PutObject(ctx, "source", "a", strings.NewReader("first"), engine.PutObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.PutObject(ctx, "source", "a", strings.NewReader("second"), engine.PutObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.PutObjectTags(ctx, "source", "a", "", tags.FromMap(map[string]string{"tier": "hot"})); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := NewReplicator(eng)
+	task := Task{Bucket: "source", Key: "a", DestinationBucket: "replica", VersionID: first.VersionID, StorageClass: "STANDARD_IA"}
+	if err := rep.Replicate(ctx, task); err != nil {
+		t.Fatalf("Replicate() error = %v", err)
+	}
+
+	// The task copies the version that was written, not the current one
+	result, err := eng.GetObject(ctx, "replica", "a", engine.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject(replica) error = %v", err)
+	}
+	body, _ := io.ReadAll(result.Body)
+	result.Body.Close()
+	if string(body) != "first" {
+		t.Errorf("replica body = %q, want %q", body, "first")
+	}
+	info, err := eng.HeadObject(ctx, "replica", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.StorageClass != "STANDARD_IA" {
+		t.Errorf("replica StorageClass = %q, want STANDARD_IA", info.StorageClass)
+	}
+	if info.ReplicationStatus != StatusReplica {
+		t.Errorf("replica ReplicationStatus = %q, want %s", info.ReplicationStatus, StatusReplica)
+	}
+	if err := rep.RecordStatus(ctx, task, StatusCompleted); err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+	for _, check := range []struct{ versionID, want string }{{first.VersionID, StatusCompleted}, {"", ""}} {
+		result, err := eng.GetObject(ctx, "source", "a", engine.GetObjectOptions{VersionID: check.versionID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.Body.Close()
+		if result.ReplicationStatus != check.want {
+			t.Errorf("source version %q ReplicationStatus = %q, want %q", check.versionID, result.ReplicationStatus, check.want)
+		}
+	}
+	if replicaTags, _ := rep.ObjectTags(ctx, "replica", "a"); replicaTags["tier"] != "hot" {
+		t.Errorf("replica tags = %v, want tier=hot", replicaTags)
+	}
+
+	if err := rep.Replicate(ctx, Task{Bucket: "source", Key: "a", DestinationBucket: "replica", Delete: true}); err != nil {
+		t.Fatalf("Replicate(delete) error = %v", err)
+	}
+	if _, err := eng.HeadObject(ctx, "replica", "a"); !errors.Is(err, engine.ErrObjectNotFound) {
+		t.Errorf("HeadObject(replica) after delete error = %v, want ErrObjectNotFound", err)
+	}
+
+	// A source that is gone by the time the task runs has nothing to copy
+	if err := rep.Replicate(ctx, Task{Bucket: "source", Key: "missing", DestinationBucket: "replica"}); err != nil {
+		t.Errorf("Replicate(missing) error = %v, want nil", err)
+	}
+}
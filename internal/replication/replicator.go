@@ -0,0 +1,78 @@
+package replication
+
+import (
+	"context"
+	"errors"
+
+	"github.com/openendpoint/openendpoint/internal/engine"
+	"github.com/openendpoint/openendpoint/internal/tags"
+)
+
+// Replicator applies queued changes through the object engine: created
+// objects are copied to the destination bucket with their tags, and delete
+// markers are replayed there as plain deletes. The engine knows nothing of
+// replication; it only publishes the events the Replication manager queues.
+type Replicator struct {
+	engine *engine.ObjectService
+}
+
+// NewReplicator creates a Replicator writing through eng
+func NewReplicator(eng *engine.ObjectService) *Replicator {
+	return &Replicator{engine: eng}
+}
+
+// Attach makes r the replicator, tag lookup and status recorder of a
+// Replication manager
+func (r *Replicator) Attach(rep *Replication) {
+	rep.SetReplicator(r.Replicate)
+	rep.SetTagLookup(r.ObjectTags)
+	rep.SetStatusRecorder(r.RecordStatus)
+}
+
+// Replicate applies one task to its destination bucket. A source version
+// that is gone by the time the task runs has been superseded, so there is
+// nothing left to copy and the task succeeds.
+func (r *Replicator) Replicate(ctx context.Context, task Task) error {
+	if task.Delete {
+		_, err := r.engine.DeleteObject(ctx, task.DestinationBucket, task.Key, engine.DeleteObjectOptions{})
+		return err
+	}
+
+	_, err := r.engine.CopyObject(ctx, task.Bucket, task.Key, task.DestinationBucket, task.Key, engine.CopyObjectOptions{
+		SourceVersionID: task.VersionID,
+	})
+	if errors.Is(err, engine.ErrObjectNotFound) || errors.Is(err, engine.ErrNoSuchVersion) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	objectTags, err := r.engine.GetObjectTags(ctx, task.Bucket, task.Key, task.VersionID)
+	if err != nil {
+		return err
+	}
+	if len(objectTags) > 0 {
+		if err := r.engine.PutObjectTags(ctx, task.DestinationBucket, task.Key, "", tags.FromMap(objectTags)); err != nil {
+			return err
+		}
+	}
+
+	if task.StorageClass != "" {
+		if err := r.engine.TransitionObject(ctx, task.DestinationBucket, task.Key, "", task.StorageClass); err != nil {
+			return err
+		}
+	}
+	return r.engine.SetReplicationStatus(ctx, task.DestinationBucket, task.Key, "", StatusReplica)
+}
+
+// RecordStatus records a task's replication status on the version of the
+// source object it copies
+func (r *Replicator) RecordStatus(ctx context.Context, task Task, status string) error {
+	return r.engine.SetReplicationStatus(ctx, task.Bucket, task.Key, task.VersionID, status)
+}
+
+// ObjectTags returns the tags of a source object, for rules filtering on them
+func (r *Replicator) ObjectTags(ctx context.Context, bucket, key string) (map[string]string, error) {
+	return r.engine.GetObjectTags(ctx, bucket, key, "")
+}
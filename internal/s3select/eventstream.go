@@ -0,0 +1,109 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// MaxRecordsPayload is the most result data carried by one Records event;
+// larger results are split across several
+const MaxRecordsPayload = 128 << 10
+
+// EventStreamContentType is the Content-Type of a Select response body
+const EventStreamContentType = "application/octet-stream"
+
+// Lengths of the fixed parts of an event stream message: the prelude (total
+// length, headers length and their CRC) and the trailing message CRC
+const (
+	preludeLen    = 12
+	messageCRCLen = 4
+)
+
+// headerTypeString is the event stream type tag of a string header value
+const headerTypeString = 7
+
+// eventHeader is a string-valued event stream message header
+type eventHeader struct {
+	name, value string
+}
+
+// statsPayload is the body of a Stats event
+type statsPayload struct {
+	XMLName        xml.Name `xml:"Stats"`
+	BytesScanned   int64    `xml:"BytesScanned"`
+	BytesProcessed int64    `xml:"BytesProcessed"`
+	BytesReturned  int64    `xml:"BytesReturned"`
+}
+
+// WriteEventStream writes a Select result in the event stream framing S3
+// Select responses use: the payload in one or more Records events, then a
+// Stats event and an End event.
+func WriteEventStream(w io.Writer, result *SelectResult) error {
+	payload := result.Payload
+	for len(payload) > 0 {
+		n := min(len(payload), MaxRecordsPayload)
+		if err := writeEvent(w, "Records", "application/octet-stream", payload[:n]); err != nil {
+			return err
+		}
+		payload = payload[n:]
+	}
+
+	var stats statsPayload
+	if result.Stats != nil {
+		stats.BytesScanned = result.Stats.BytesScanned
+		stats.BytesProcessed = result.Stats.BytesProcessed
+		stats.BytesReturned = result.Stats.BytesReturned
+	}
+	body, err := xml.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+	if err := writeEvent(w, "Stats", "text/xml", body); err != nil {
+		return err
+	}
+
+	return writeEvent(w, "End", "", nil)
+}
+
+// writeEvent writes one event message. An empty contentType omits the
+// header, as End events do.
+func writeEvent(w io.Writer, eventType, contentType string, payload []byte) error {
+	headers := []eventHeader{
+		{":event-type", eventType},
+		{":message-type", "event"},
+	}
+	if contentType != "" {
+		headers = append(headers, eventHeader{":content-type", contentType})
+	}
+	_, err := w.Write(encodeMessage(headers, payload))
+	return err
+}
+
+// encodeMessage frames headers and payload as an event stream message:
+//
+//	total length | headers length | prelude CRC | headers | payload | message CRC
+//
+// with the lengths as big-endian uint32 and both CRCs CRC-32 (IEEE).
+func encodeMessage(headers []eventHeader, payload []byte) []byte {
+	var hdr bytes.Buffer
+	for _, h := range headers {
+		hdr.WriteByte(byte(len(h.name)))
+		hdr.WriteString(h.name)
+		hdr.WriteByte(headerTypeString)
+		binary.Write(&hdr, binary.BigEndian, uint16(len(h.value)))
+		hdr.WriteString(h.value)
+	}
+
+	total := preludeLen + hdr.Len() + len(payload) + messageCRCLen
+	msg := make([]byte, 0, total)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(total))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(hdr.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, hdr.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
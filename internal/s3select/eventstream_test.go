@@ -0,0 +1,86 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+)
+
+func TestWriteEventStream(t *testing.T) {
+	records := strings.Repeat("John,30\n", MaxRecordsPayload/8+100)
+	result := &SelectResult{
+		Payload: []byte(records),
+		Stats:   &SelectStats{BytesScanned: 1000, BytesProcessed: 900, BytesReturned: int64(len(records))},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteEventStream(&buf, result); err != nil {
+		t.Fatalf("WriteEventStream failed: %v", err)
+	}
+
+	// The AWS SDK decoder checks both CRCs of every message
+	decoder := eventstream.NewDecoder()
+	var events []string
+	var payload bytes.Buffer
+	var stats statsPayload
+	for {
+		msg, err := decoder.Decode(&buf, nil)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode failed after %v: %v", events, err)
+		}
+		if mt := msg.Headers.Get(":message-type"); mt == nil || mt.String() != "event" {
+			t.Errorf(":message-type = %v, want event", mt)
+		}
+		eventType := msg.Headers.Get(":event-type").String()
+		events = append(events, eventType)
+		switch eventType {
+		case "Records":
+			payload.Write(msg.Payload)
+		case "Stats":
+			if err := xml.Unmarshal(msg.Payload, &stats); err != nil {
+				t.Fatalf("Stats payload %q: %v", msg.Payload, err)
+			}
+		}
+	}
+
+	want := []string{"Records", "Records", "Stats", "End"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if payload.String() != records {
+		t.Errorf("Records payload has %d bytes, want %d", payload.Len(), len(records))
+	}
+	if stats.BytesScanned != 1000 || stats.BytesProcessed != 900 || stats.BytesReturned != int64(len(records)) {
+		t.Errorf("Stats = %+v, want the result stats", stats)
+	}
+}
+
+func TestWriteEventStreamEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteEventStream(&buf, &SelectResult{}); err != nil {
+		t.Fatalf("WriteEventStream failed: %v", err)
+	}
+
+	decoder := eventstream.NewDecoder()
+	var events []string
+	for {
+		msg, err := decoder.Decode(&buf, nil)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		events = append(events, msg.Headers.Get(":event-type").String())
+	}
+	if strings.Join(events, ",") != "Stats,End" {
+		t.Errorf("events = %v, want [Stats End]", events)
+	}
+}
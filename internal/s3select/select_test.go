@@ -1,7 +1,11 @@
 package s3select
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -27,7 +31,7 @@ func TestNewSelectService(t *testing.T) {
 
 func TestNewEvaluator(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 	if evaluator == nil {
 		t.Fatal("Evaluator should not be nil")
@@ -40,15 +44,17 @@ func TestParserParseValid(t *testing.T) {
 
 	tests := []struct {
 		sql       string
-		wantCols  []string
-		wantWhere string
+		wantCols  int
+		wantWhere bool
 		wantLimit int64
 	}{
-		{"SELECT * FROM s3object", []string{"*"}, "", 0},
-		{"SELECT name, age FROM s3object", []string{"name", " age"}, "", 0},
-		{"SELECT * FROM s3object WHERE age > 18", []string{"*"}, "age > 18", 0},
-		{"SELECT * FROM s3object LIMIT 10", []string{"*"}, "", 10},
-		{"SELECT * FROM s3object WHERE age > 18 LIMIT 5", []string{"*"}, "age > 18 LIMIT 5", 5},
+		{"SELECT * FROM s3object", 0, false, 0},
+		{"SELECT name, age FROM s3object", 2, false, 0},
+		{"SELECT * FROM s3object WHERE age > 18", 0, true, 0},
+		{"SELECT * FROM s3object LIMIT 10", 0, false, 10},
+		{"SELECT * FROM s3object WHERE age > 18 LIMIT 5", 0, true, 5},
+		{"select s.name from S3Object s where s.name like 'J%' and not s.age < 18", 1, true, 0},
+		{"SELECT s.* FROM S3Object AS s", 0, false, 0},
 	}
 
 	for _, tt := range tests {
@@ -58,12 +64,12 @@ func TestParserParseValid(t *testing.T) {
 			continue
 		}
 
-		if len(ast.Columns) != len(tt.wantCols) {
-			t.Errorf("Parse(%q) columns count = %d, want %d", tt.sql, len(ast.Columns), len(tt.wantCols))
+		if len(ast.Columns) != tt.wantCols {
+			t.Errorf("Parse(%q) columns count = %d, want %d", tt.sql, len(ast.Columns), tt.wantCols)
 		}
 
-		if ast.WhereClause != tt.wantWhere {
-			t.Errorf("Parse(%q) WhereClause = %q, want %q", tt.sql, ast.WhereClause, tt.wantWhere)
+		if (ast.Where != nil) != tt.wantWhere {
+			t.Errorf("Parse(%q) Where = %v, want a WHERE clause %v", tt.sql, ast.Where, tt.wantWhere)
 		}
 
 		if ast.Limit != tt.wantLimit {
@@ -80,12 +86,20 @@ func TestParserParseInvalid(t *testing.T) {
 		"INVALID SQL",
 		"SELECT *",
 		"",
+		"SELECT * FROM table",
+		"SELECT name, * FROM s3object",
+		"SELECT * FROM s3object WHERE",
+		"SELECT * FROM s3object WHERE name = 'unterminated",
+		"SELECT * FROM s3object WHERE (age > 1",
+		"SELECT * FROM s3object LIMIT ten",
+		"SELECT * FROM s3object WHERE name LIKE 5",
+		"SELECT * FROM s3object extra tokens",
 	}
 
 	for _, sql := range tests {
 		_, err := parser.Parse(sql)
-		if err == nil {
-			t.Errorf("Parse(%q) should fail", sql)
+		if !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidExpression", sql, err)
 		}
 	}
 }
@@ -95,19 +109,27 @@ func TestParseColumns(t *testing.T) {
 	parser := NewParser(logger)
 
 	tests := []struct {
-		input string
-		want  int
+		sql  string
+		want []string
 	}{
-		{"*", 1},
-		{"name", 1},
-		{"name,age", 2},
-		{"name, age, city", 3},
+		{"SELECT name FROM s3object", []string{"name"}},
+		{"SELECT s.name, s.age FROM s3object s", []string{"name", "age"}},
+		{"SELECT _1, s._3 FROM s3object s", []string{"_1", "_3"}},
+		{`SELECT "select", s.address.city AS town FROM s3object s`, []string{"select", "town"}},
 	}
 
 	for _, tt := range tests {
-		cols := parser.parseColumns(tt.input)
-		if len(cols) != tt.want {
-			t.Errorf("parseColumns(%q) returned %d columns, want %d", tt.input, len(cols), tt.want)
+		ast, err := parser.Parse(tt.sql)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.sql, err)
+			continue
+		}
+		var names []string
+		for _, col := range ast.Columns {
+			names = append(names, col.Name())
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Parse(%q) columns = %v, want %v", tt.sql, names, tt.want)
 		}
 	}
 }
@@ -144,7 +166,7 @@ func TestExpressionTypeConstants(t *testing.T) {
 
 func TestEvaluateJSON(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
 	jsonData := `{"name":"John","age":30}
@@ -170,7 +192,7 @@ func TestEvaluateJSON(t *testing.T) {
 
 func TestEvaluateJSONWithLimit(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}, Limit: 1}
+	ast := &AST{Limit: 1}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
 	jsonData := `{"name":"John","age":30}
@@ -188,7 +210,7 @@ func TestEvaluateJSONWithLimit(t *testing.T) {
 
 func TestEvaluateCSV(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatCSV, logger)
 
 	csvData := `name,age
@@ -207,7 +229,7 @@ Jane,25`
 
 func TestEvaluateCSVWithColumns(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"name"}}
+	ast := &AST{Columns: []*Column{{Path: []string{"name"}}}}
 	evaluator := NewEvaluator(ast, FormatCSV, logger)
 
 	csvData := `name,age
@@ -224,83 +246,82 @@ Jane,25`
 	}
 }
 
-func TestSelectColumnsAll(t *testing.T) {
+func TestProjectAll(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
-	record := map[string]interface{}{"name": "John", "age": 30.0}
-	result := evaluator.selectColumns(record)
+	rec := &record{names: []string{"name", "age"}, values: []interface{}{"John", 30.0}}
+	row := evaluator.project(rec)
 
-	if !strings.Contains(result, "John") {
-		t.Errorf("selectColumns(*) should contain 'John', got %s", result)
+	if len(row) != 2 || row[0].name != "name" || row[0].value != "John" {
+		t.Errorf("project(*) = %+v, want every field in order", row)
 	}
 }
 
-func TestSelectColumnsSpecific(t *testing.T) {
+func TestProjectSpecific(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"name"}}
+	ast := &AST{Columns: []*Column{{Path: []string{"name"}}}}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
-	record := map[string]interface{}{"name": "John", "age": 30.0}
-	result := evaluator.selectColumns(record)
+	rec := &record{names: []string{"name", "age"}, values: []interface{}{"John", 30.0}}
+	row := evaluator.project(rec)
 
-	if result != "John" {
-		t.Errorf("selectColumns(name) = %s, want John", result)
+	if len(row) != 1 || row[0].value != "John" {
+		t.Errorf("project(name) = %+v, want John", row)
 	}
 }
 
-func TestSelectColumnsMissing(t *testing.T) {
+func TestProjectMissing(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"missing"}}
+	ast := &AST{Columns: []*Column{{Path: []string{"missing"}}}}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
-	record := map[string]interface{}{"name": "John"}
-	result := evaluator.selectColumns(record)
+	rec := &record{names: []string{"name"}, values: []interface{}{"John"}}
+	row := evaluator.project(rec)
 
-	if result != "" {
-		t.Errorf("selectColumns(missing) = %s, want empty", result)
+	if len(row) != 1 || !row[0].missing {
+		t.Errorf("project(missing) = %+v, want a missing field", row)
 	}
 }
 
 func TestFormatOutputJSON(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
-	records := []string{`{"name":"John"}`, `{"name":"Jane"}`}
-	output, err := evaluator.formatOutput(records)
+	rows := [][]field{
+		{{name: "name", value: "John"}, {name: "age", value: json.Number("30")}},
+		{{name: "name", value: "Jane"}, {name: "age", missing: true}},
+	}
+	output, err := evaluator.formatOutput(rows)
 	if err != nil {
 		t.Fatalf("formatOutput failed: %v", err)
 	}
 
-	if len(output) == 0 {
-		t.Error("output should not be empty")
+	want := `{"name":"John","age":30}` + "\n" + `{"name":"Jane"}` + "\n"
+	if string(output) != want {
+		t.Errorf("formatOutput = %q, want %q", output, want)
 	}
 }
 
 func TestFormatOutputCSV(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatCSV, logger)
 
-	records := []string{"John,30", "Jane,25"}
-	output, err := evaluator.formatOutput(records)
+	rows := [][]field{
+		{{name: "name", value: "John"}, {name: "city", value: "New York, NY"}},
+		{{name: "name", value: "Jane"}, {name: "city", missing: true}},
+	}
+	output, err := evaluator.formatOutput(rows)
 	if err != nil {
 		t.Fatalf("formatOutput failed: %v", err)
 	}
 
-	if len(output) == 0 {
-		t.Error("output should not be empty")
-	}
-}
-
-func TestEstimateRecordSize(t *testing.T) {
-	record := map[string]interface{}{"name": "John", "age": 30.0}
-	size := estimateRecordSize(record)
-
-	if size <= 0 {
-		t.Errorf("estimateRecordSize = %d, want > 0", size)
+	want := "John,\"New York, NY\"\nJane,\n"
+	if string(output) != want {
+		t.Errorf("formatOutput = %q, want %q", output, want)
 	}
 }
 
@@ -426,7 +447,7 @@ func TestOutputSerialization(t *testing.T) {
 
 func TestEvaluateEmptyInput(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
 	result, err := evaluator.Evaluate(context.Background(), strings.NewReader(""))
@@ -439,16 +460,6 @@ func TestEvaluateEmptyInput(t *testing.T) {
 	}
 }
 
-func TestParseColumnsErrorPath(t *testing.T) {
-	logger := zap.NewNop()
-	parser := NewParser(logger)
-
-	col := parser.parseColumns("\"unclosed")
-	if len(col) != 1 || col[0] != "\"unclosed" {
-		t.Errorf("parseColumns with unclosed quote should return single column, got %v", col)
-	}
-}
-
 type errorReader struct{}
 
 func (e *errorReader) Read(p []byte) (n int, err error) {
@@ -457,7 +468,7 @@ func (e *errorReader) Read(p []byte) (n int, err error) {
 
 func TestEvaluateCSVHeaderError(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatCSV, logger)
 
 	_, err := evaluator.Evaluate(context.Background(), &errorReader{})
@@ -469,19 +480,14 @@ func TestEvaluateCSVHeaderError(t *testing.T) {
 	}
 }
 
-func TestFormatOutputDefault(t *testing.T) {
+func TestFormatOutputUnsupported(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
-	evaluator := NewEvaluator(ast, FormatParquet, logger)
-
-	records := []string{"John,30", "Jane,25"}
-	output, err := evaluator.formatOutput(records)
-	if err != nil {
-		t.Fatalf("formatOutput failed: %v", err)
-	}
+	ast := &AST{}
+	evaluator := NewEvaluator(ast, FormatCSV, logger)
+	evaluator.output = OutputSerialization{Format: OutputRaw}
 
-	if string(output) != "John,30\nJane,25" {
-		t.Errorf("formatOutput default = %q, want %q", string(output), "John,30\nJane,25")
+	if _, err := evaluator.formatOutput(nil); err == nil {
+		t.Error("formatOutput should fail for an unsupported output format")
 	}
 }
 
@@ -510,7 +516,7 @@ func TestExecuteEvaluateError(t *testing.T) {
 
 func TestEvaluateCSVRecordError(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatCSV, logger)
 
 	csvData := "name,age\nJohn,30\n\"unclosed quote"
@@ -527,10 +533,14 @@ func TestEvaluateCSVRecordError(t *testing.T) {
 
 func TestEvaluateJSONWithWhereClause(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}, WhereClause: "age > 18"}
+	ast, err := NewParser(logger).Parse("SELECT * FROM s3object WHERE age > 18")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
-	jsonData := `{"name":"John","age":30}`
+	jsonData := `{"name":"John","age":30}
+{"name":"Tim","age":12}`
 
 	result, err := evaluator.Evaluate(context.Background(), strings.NewReader(jsonData))
 	if err != nil {
@@ -544,7 +554,7 @@ func TestEvaluateJSONWithWhereClause(t *testing.T) {
 
 func TestEvaluateCSVWithLimit(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}, Limit: 1}
+	ast := &AST{Limit: 1}
 	evaluator := NewEvaluator(ast, FormatCSV, logger)
 
 	csvData := `name,age
@@ -564,7 +574,7 @@ Bob,40`
 
 func TestEvaluateJSONDecodeError(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 
 	jsonData := `{"name":"John"}invalid`
@@ -581,7 +591,7 @@ func TestEvaluateJSONDecodeError(t *testing.T) {
 
 func TestEvaluateFormatOutputError(t *testing.T) {
 	logger := zap.NewNop()
-	ast := &AST{Columns: []string{"*"}}
+	ast := &AST{}
 	evaluator := NewEvaluator(ast, FormatJSON, logger)
 	evaluator.forceFormatErr = true
 
@@ -595,3 +605,184 @@ func TestEvaluateFormatOutputError(t *testing.T) {
 		t.Errorf("Error should contain 'forced format error', got %v", err)
 	}
 }
+
+func gzipData(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(data)); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSelectServiceExecuteContentEncoding(t *testing.T) {
+	svc := NewSelectService(zap.NewNop())
+	compressed := gzipData(t, `{"name":"John"}`+"\n"+`{"name":"Jane"}`)
+
+	// The object's Content-Encoding alone is enough to decompress it
+	req := &SelectRequest{
+		Expression:         "SELECT * FROM s3object",
+		InputSerialization: InputSerialization{Format: FormatJSON},
+		ContentEncoding:    "gzip",
+	}
+	result, err := svc.Execute(context.Background(), req, bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stats.RecordsReturned != 2 || !strings.Contains(string(result.Payload), "Jane") {
+		t.Errorf("result = %d records %s, want both decompressed records", result.Stats.RecordsReturned, result.Payload)
+	}
+
+	// An explicit CompressionType takes precedence over Content-Encoding, so
+	// the gzip bytes are read as-is and yield no records
+	req.InputSerialization.CompressionType = "NONE"
+	result, err = svc.Execute(context.Background(), req, bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stats.RecordsReturned != 0 {
+		t.Errorf("RecordsReturned = %d, want 0 for undecoded gzip data", result.Stats.RecordsReturned)
+	}
+}
+
+func TestCompressionType(t *testing.T) {
+	tests := []struct {
+		compression, encoding string
+		want                  string
+		wantErr               bool
+	}{
+		{"", "", CompressionNone, false},
+		{"", "identity", CompressionNone, false},
+		{"", "gzip", CompressionGZIP, false},
+		{"", "X-GZIP", CompressionGZIP, false},
+		{"", "bzip2", CompressionBZIP2, false},
+		{"gzip", "", CompressionGZIP, false},
+		{"NONE", "gzip", CompressionNone, false},
+		{"BZIP2", "gzip", CompressionBZIP2, false},
+		{"ZSTD", "", "", true},
+		{"", "br", "", true},
+	}
+
+	for _, tt := range tests {
+		req := &SelectRequest{
+			InputSerialization: InputSerialization{CompressionType: tt.compression},
+			ContentEncoding:    tt.encoding,
+		}
+		got, err := compressionType(req)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsupportedCompression) {
+				t.Errorf("compressionType(%q, %q) error = %v, want ErrUnsupportedCompression", tt.compression, tt.encoding, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("compressionType(%q, %q) = %q, %v, want %q", tt.compression, tt.encoding, got, err, tt.want)
+		}
+	}
+}
+
+func TestSelectServiceExecuteQueries(t *testing.T) {
+	svc := NewSelectService(zap.NewNop())
+	csvData := "name,age,city\nJohn,34,New York\nJane,28,Boston\nBob,41,Bern\nAlice,,Berlin\n"
+	jsonData := `{"name":"John","age":34,"address":{"city":"New York"}}
+{"name":"Jane","age":28,"address":{"city":"Boston"}}
+{"name":"Bob","age":41,"address":{"city":"Bern"}}
+{"name":"Alice","address":{"city":"Berlin"}}`
+
+	tests := []struct {
+		name   string
+		input  InputFormat
+		csv    *CSVInput
+		output OutputSerialization
+		sql    string
+		want   string
+	}{
+		{
+			name:  "csv where by name",
+			input: FormatCSV,
+			sql:   "SELECT s.name FROM S3Object s WHERE s.age > 30",
+			want:  "John\nBob\n",
+		},
+		{
+			name:  "csv positional without header",
+			input: FormatCSV,
+			csv:   &CSVInput{FileHeaderInfo: "IGNORE"},
+			sql:   "SELECT _1, _3 FROM S3Object WHERE _2 <= 30",
+			want:  "Jane,Boston\n",
+		},
+		{
+			name:  "csv header row as data",
+			input: FormatCSV,
+			csv:   &CSVInput{FileHeaderInfo: "NONE"},
+			sql:   "SELECT _1 FROM S3Object LIMIT 2",
+			want:  "name\nJohn\n",
+		},
+		{
+			name:   "csv to json",
+			input:  FormatCSV,
+			output: OutputSerialization{Format: OutputJSON},
+			sql:    "SELECT name, city AS town FROM S3Object WHERE city LIKE 'B%n' AND NOT name = 'Bob'",
+			want:   `{"name":"Jane","town":"Boston"}` + "\n" + `{"name":"Alice","town":"Berlin"}` + "\n",
+		},
+		{
+			name:  "csv or and parentheses",
+			input: FormatCSV,
+			sql:   "SELECT name FROM S3Object WHERE (age < 30 OR age >= 41) AND city NOT LIKE '%York'",
+			want:  "Jane\nBob\n",
+		},
+		{
+			name:  "json where and limit",
+			input: FormatJSON,
+			sql:   "SELECT s.name, s.age FROM S3Object s WHERE s.age > 30 LIMIT 1",
+			want:  `{"name":"John","age":34}` + "\n",
+		},
+		{
+			name:   "json nested path to csv",
+			input:  FormatJSON,
+			output: OutputSerialization{Format: OutputCSV, CSV: &CSVOutput{FieldDelimiter: "|", RecordDelimiter: "\r\n"}},
+			sql:    "SELECT s.name, s.address.city FROM S3Object s WHERE s.address.city LIKE 'B_r%'",
+			want:   "Bob|Bern\r\nAlice|Berlin\r\n",
+		},
+		{
+			name:  "json is null",
+			input: FormatJSON,
+			sql:   "SELECT * FROM S3Object WHERE age IS NULL",
+			want:  `{"name":"Alice","address":{"city":"Berlin"}}` + "\n",
+		},
+		{
+			name:  "json string comparison",
+			input: FormatJSON,
+			sql:   "SELECT name FROM S3Object WHERE name < 'Bz' AND name <> 'Alice'",
+			want:  `{"name":"Bob"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := jsonData
+			if tt.input == FormatCSV {
+				data = csvData
+			}
+			req := &SelectRequest{
+				Expression:          tt.sql,
+				InputSerialization:  InputSerialization{Format: tt.input, CSV: tt.csv},
+				OutputSerialization: tt.output,
+			}
+			result, err := svc.Execute(context.Background(), req, strings.NewReader(data))
+			if err != nil {
+				t.Fatalf("Execute(%q) failed: %v", tt.sql, err)
+			}
+			if string(result.Payload) != tt.want {
+				t.Errorf("Execute(%q) = %q, want %q", tt.sql, result.Payload, tt.want)
+			}
+			// A LIMIT stops the scan before the end of the input
+			if result.Stats.BytesScanned <= 0 || result.Stats.BytesScanned > int64(len(data)) {
+				t.Errorf("BytesScanned = %d, want 1 to %d", result.Stats.BytesScanned, len(data))
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package s3select
 
 import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
-	"sync"
 
 	"go.uber.org/zap"
 )
@@ -46,6 +50,10 @@ type SelectRequest struct {
 	InputSerialization  InputSerialization
 	OutputSerialization OutputSerialization
 	ScanRange           *ScanRange
+	// ContentEncoding is the Content-Encoding the object was stored with.
+	// It is used to decompress the object when the request does not name a
+	// CompressionType itself.
+	ContentEncoding string
 }
 
 // ScanRange represents a range of bytes to scan
@@ -59,9 +67,20 @@ type InputSerialization struct {
 	Format          InputFormat
 	JSON            *JSONInput
 	CSV             *CSVInput
-	CompressionType string
+	CompressionType string // NONE, GZIP or BZIP2
 }
 
+// Compression types accepted in InputSerialization
+const (
+	CompressionNone  = "NONE"
+	CompressionGZIP  = "GZIP"
+	CompressionBZIP2 = "BZIP2"
+)
+
+// ErrUnsupportedCompression is returned for a compression type or object
+// Content-Encoding that Select cannot decompress
+var ErrUnsupportedCompression = errors.New("unsupported compression type")
+
 // JSONInput contains JSON-specific input settings
 type JSONInput struct {
 	Type string // Document, Lines
@@ -122,85 +141,46 @@ func NewParser(logger *zap.Logger) *Parser {
 	return &Parser{logger: logger}
 }
 
-// Parse parses a SQL expression into an AST
+// Parse parses a SQL expression of the form
+//
+//	SELECT <columns|*> FROM S3Object [[AS] alias] [WHERE <predicate>] [LIMIT n]
+//
+// into an AST. Columns are referenced by name, optionally qualified with the
+// alias, or by position as _1, _2, ... Predicates combine comparisons, LIKE
+// and IS [NOT] NULL with AND, OR and NOT.
 func (p *Parser) Parse(sql string) (*AST, error) {
-	sql = strings.TrimSpace(sql)
-
-	// Simple SQL parser for SELECT statements
-	// Supports: SELECT columns FROM table [WHERE condition]
-
-	// Parse SELECT clause
-	if !strings.HasPrefix(strings.ToUpper(sql), "SELECT") {
-		return nil, fmt.Errorf("invalid SQL: must start with SELECT")
-	}
-
-	// Extract SELECT columns
-	selectIdx := 7 // len("SELECT")
-	fromIdx := strings.Index(sql, "FROM")
-	if fromIdx == -1 {
-		return nil, fmt.Errorf("invalid SQL: missing FROM clause")
-	}
-
-	columnsStr := strings.TrimSpace(sql[selectIdx:fromIdx])
-	columns := p.parseColumns(columnsStr)
-
-	// Extract WHERE clause
-	var whereClause string
-	whereIdx := strings.Index(sql, "WHERE")
-	if whereIdx != -1 {
-		whereClause = strings.TrimSpace(sql[whereIdx+5:])
-	}
-
-	// Extract LIMIT
-	var limit int64 = 0
-	limitIdx := strings.Index(sql, "LIMIT")
-	if limitIdx != -1 {
-		limitStr := strings.TrimSpace(sql[limitIdx+5:])
-		fmt.Sscanf(limitStr, "%d", &limit)
-	}
-
-	return &AST{
-		Columns:     columns,
-		WhereClause: whereClause,
-		Limit:       limit,
-	}, nil
-}
-
-// parseColumns parses column list
-func (p *Parser) parseColumns(colStr string) []string {
-	colStr = strings.TrimSpace(colStr)
-
-	if colStr == "*" {
-		return []string{"*"}
-	}
-
-	reader := csv.NewReader(strings.NewReader(colStr))
-	columns, err := reader.Read()
+	tokens, err := tokenize(sql)
 	if err != nil {
-		return []string{colStr}
+		return nil, err
 	}
-
-	return columns
+	return (&sqlParser{tokens: tokens}).parseSelect()
 }
 
 // AST represents a parsed SQL AST
 type AST struct {
-	Columns     []string
-	WhereClause string
-	Limit       int64
+	// Columns are the selected columns; nil selects every field
+	Columns []*Column
+	// Alias is the name S3Object is given in the FROM clause
+	Alias string
+	// Where filters records; nil keeps every record
+	Where expr
+	// Limit caps the number of records returned; 0 means no limit
+	Limit int64
 }
 
 // Evaluator evaluates the AST against records
 type Evaluator struct {
 	ast            *AST
 	input          InputFormat
+	csvInput       *CSVInput
+	output         OutputSerialization
 	logger         *zap.Logger
 	stats          SelectStats
-	mu             sync.Mutex
 	forceFormatErr bool
 }
 
-// NewEvaluator creates a new evaluator
+// NewEvaluator creates a new evaluator. Records are output in the input
+// format unless an output serialization is set.
 func NewEvaluator(ast *AST, input InputFormat, logger *zap.Logger) *Evaluator {
 	return &Evaluator{
 		ast:    ast,
@@ -210,51 +190,72 @@ func NewEvaluator(ast *AST, input InputFormat, logger *zap.Logger) *Evaluator {
 	}
 }
 
+// field is one selected value of an output row
+type field struct {
+	name  string
+	value interface{}
+	// missing is set when the record has no such field
+	missing bool
+}
+
 // Evaluate evaluates the select query on input data
 func (e *Evaluator) Evaluate(ctx context.Context, inputData io.Reader) (*SelectResult, error) {
-	var output []string
-	recordCount := int64(0)
+	counter := &countingReader{r: inputData}
+	var rows [][]field
+
+	// emit filters and projects a record, reporting whether to read on
+	emit := func(rec *record) bool {
+		if e.ast.Where != nil && !truthy(e.ast.Where.eval(rec)) {
+			return true
+		}
+		rows = append(rows, e.project(rec))
+		return e.ast.Limit <= 0 || int64(len(rows)) < e.ast.Limit
+	}
 
 	switch e.input {
 	case FormatJSON:
-		decoder := json.NewDecoder(inputData)
-		for {
-			var record map[string]interface{}
-			if err := decoder.Decode(&record); err != nil {
-				if err == io.EOF {
-					break
+		decoder := json.NewDecoder(counter)
+		for ctx.Err() == nil {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				if err != io.EOF {
+					e.logger.Debug("Error decoding JSON", zap.Error(err))
 				}
-				e.logger.Debug("Error decoding JSON", zap.Error(err))
 				break
 			}
+			e.stats.BytesProcessed += int64(len(raw))
 
-			e.stats.BytesProcessed += estimateRecordSize(record)
-
-			// Check where clause (simplified - always true for now)
-			if e.ast.WhereClause != "" {
-				// TODO: Implement where clause evaluation
+			rec, err := decodeJSONRecord(raw)
+			if err != nil {
+				e.logger.Debug("Error decoding JSON record", zap.Error(err))
+				continue
 			}
-
-			// Select columns
-			selected := e.selectColumns(record)
-			output = append(output, selected)
-			recordCount++
-
-			// Check limit
-			if e.ast.Limit > 0 && recordCount >= e.ast.Limit {
+			if !emit(rec) {
 				break
 			}
 		}
 
 	case FormatCSV:
-		reader := csv.NewReader(inputData)
-		headers, err := reader.Read()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read CSV headers: %w", err)
+		reader := e.csvReader(counter)
+		headerInfo := "USE"
+		if e.csvInput != nil && e.csvInput.FileHeaderInfo != "" {
+			headerInfo = strings.ToUpper(e.csvInput.FileHeaderInfo)
 		}
 
-		for {
-			record, err := reader.Read()
+		var headers []string
+		if headerInfo != "NONE" {
+			var err error
+			headers, err = reader.Read()
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to read CSV headers: %w", err)
+			}
+			if headerInfo == "IGNORE" {
+				headers = nil
+			}
+		}
+
+		for ctx.Err() == nil {
+			row, err := reader.Read()
 			if err != nil {
 				if err == io.EOF {
 					break
@@ -263,37 +264,36 @@ func (e *Evaluator) Evaluate(ctx context.Context, inputData io.Reader) (*SelectR
 				continue
 			}
 
-			e.stats.BytesProcessed += int64(len(strings.Join(record, ",")))
+			e.stats.BytesProcessed += int64(len(strings.Join(row, ",")))
 
-			// Convert to map
-			recordMap := make(map[string]interface{})
-			for i, h := range headers {
-				if i < len(record) {
-					recordMap[h] = record[i]
+			rec := &record{names: make([]string, len(row)), values: make([]interface{}, len(row))}
+			for i, v := range row {
+				if i < len(headers) {
+					rec.names[i] = headers[i]
+				} else {
+					rec.names[i] = "_" + strconv.Itoa(i+1)
 				}
+				rec.values[i] = v
 			}
-
-			// Select columns
-			selected := e.selectColumns(recordMap)
-			output = append(output, selected)
-			recordCount++
-
-			// Check limit
-			if e.ast.Limit > 0 && recordCount >= e.ast.Limit {
+			if !emit(rec) {
 				break
 			}
 		}
 	}
-
-	e.stats.RecordsReturned = recordCount
-	e.stats.BytesReturned = int64(len(strings.Join(output, "")))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Format output
-	payload, err := e.formatOutput(output)
+	payload, err := e.formatOutput(rows)
 	if err != nil {
 		return nil, err
 	}
 
+	e.stats.BytesScanned = counter.n
+	e.stats.RecordsReturned = int64(len(rows))
+	e.stats.BytesReturned = int64(len(payload))
+
 	return &SelectResult{
 		Payload:   payload,
 		Stats:     &e.stats,
@@ -301,48 +301,168 @@ func (e *Evaluator) Evaluate(ctx context.Context, inputData io.Reader) (*SelectR
 	}, nil
 }
 
-// selectColumns selects specific columns from a record
-func (e *Evaluator) selectColumns(record map[string]interface{}) string {
-	var result []string
+// csvReader returns a CSV reader honoring the input's field delimiter and
+// comment character
+func (e *Evaluator) csvReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if e.csvInput != nil {
+		if d := []rune(e.csvInput.FieldDelimiter); len(d) == 1 {
+			reader.Comma = d[0]
+		}
+		if c := []rune(e.csvInput.CommentCharacter); len(c) == 1 {
+			reader.Comment = c[0]
+		}
+	}
+	return reader
+}
 
-	for _, col := range e.ast.Columns {
-		col = strings.TrimSpace(col)
+// decodeJSONRecord decodes a JSON object into a record, keeping its keys in
+// document order. Any other JSON value becomes a record with the single
+// field _1.
+func decodeJSONRecord(raw json.RawMessage) (*record, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '{' {
+		var v interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		return &record{names: []string{"_1"}, values: []interface{}{v}}, nil
+	}
 
-		if col == "*" {
-			// Return all columns as JSON
-			data, _ := json.Marshal(record)
-			return string(data)
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	rec := &record{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
 		}
+		var v interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		rec.names = append(rec.names, key.(string))
+		rec.values = append(rec.values, v)
+	}
+	return rec, nil
+}
 
-		if val, ok := record[col]; ok {
-			result = append(result, fmt.Sprintf("%v", val))
-		} else {
-			result = append(result, "")
+// project selects the AST's columns from a record
+func (e *Evaluator) project(rec *record) []field {
+	if e.ast.Columns == nil {
+		row := make([]field, len(rec.values))
+		for i, v := range rec.values {
+			row[i] = field{name: rec.names[i], value: v}
 		}
+		return row
 	}
 
-	return strings.Join(result, ",")
+	row := make([]field, len(e.ast.Columns))
+	for i, col := range e.ast.Columns {
+		v, ok := rec.lookup(col)
+		row[i] = field{name: col.Name(), value: v, missing: !ok}
+	}
+	return row
+}
+
+// outputFormat returns the format records are written in, which defaults
+// to the input format
+func (e *Evaluator) outputFormat() OutputFormat {
+	if e.output.Format != "" {
+		return e.output.Format
+	}
+	if e.input == FormatCSV {
+		return OutputCSV
+	}
+	return OutputJSON
 }
 
 // formatOutput formats the output
-func (e *Evaluator) formatOutput(records []string) ([]byte, error) {
+func (e *Evaluator) formatOutput(rows [][]field) ([]byte, error) {
 	if e.forceFormatErr {
 		return nil, fmt.Errorf("forced format error")
 	}
-	switch e.input {
-	case FormatJSON:
-		return json.Marshal(records)
-	case FormatCSV:
-		return []byte(strings.Join(records, "\n") + "\n"), nil
+
+	var buf bytes.Buffer
+	switch e.outputFormat() {
+	case OutputCSV:
+		delimiter, comma := "\n", ','
+		if out := e.output.CSV; out != nil {
+			if out.RecordDelimiter != "" {
+				delimiter = out.RecordDelimiter
+			}
+			if d := []rune(out.FieldDelimiter); len(d) == 1 {
+				comma = d[0]
+			}
+		}
+		for _, row := range rows {
+			values := make([]string, len(row))
+			for i, f := range row {
+				values[i] = valueString(f.value)
+			}
+			var line bytes.Buffer
+			w := csv.NewWriter(&line)
+			w.Comma = comma
+			if err := w.Write(values); err != nil {
+				return nil, err
+			}
+			w.Flush()
+			buf.Write(bytes.TrimSuffix(line.Bytes(), []byte("\n")))
+			buf.WriteString(delimiter)
+		}
+
+	case OutputJSON:
+		delimiter := "\n"
+		if out := e.output.JSON; out != nil && out.RecordDelimiter != "" {
+			delimiter = out.RecordDelimiter
+		}
+		for _, row := range rows {
+			buf.WriteByte('{')
+			first := true
+			for _, f := range row {
+				if f.missing {
+					continue
+				}
+				name, err := json.Marshal(f.name)
+				if err != nil {
+					return nil, err
+				}
+				value, err := json.Marshal(f.value)
+				if err != nil {
+					return nil, err
+				}
+				if !first {
+					buf.WriteByte(',')
+				}
+				first = false
+				buf.Write(name)
+				buf.WriteByte(':')
+				buf.Write(value)
+			}
+			buf.WriteByte('}')
+			buf.WriteString(delimiter)
+		}
+
 	default:
-		return []byte(strings.Join(records, "\n")), nil
+		return nil, fmt.Errorf("unsupported output format %q", e.output.Format)
 	}
+	return buf.Bytes(), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
 }
 
-// estimateRecordSize estimates the size of a JSON record
-func estimateRecordSize(record map[string]interface{}) int64 {
-	data, _ := json.Marshal(record)
-	return int64(len(data))
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // SelectService provides S3 Select functionality
@@ -372,8 +492,15 @@ func (s *SelectService) Execute(ctx context.Context, req *SelectRequest, data io
 		return nil, fmt.Errorf("failed to parse SQL: %w", err)
 	}
 
+	data, err = decompress(req, data)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create evaluator
 	evaluator := NewEvaluator(ast, req.InputSerialization.Format, s.logger)
+	evaluator.csvInput = req.InputSerialization.CSV
+	evaluator.output = req.OutputSerialization
 
 	// Execute
 	result, err := evaluator.Evaluate(ctx, data)
@@ -389,6 +516,49 @@ func (s *SelectService) Execute(ctx context.Context, req *SelectRequest, data io
 	return result, nil
 }
 
+// compressionType returns the compression the object data is in. A
+// CompressionType in the request describes the stored bytes and wins;
+// otherwise the object's own Content-Encoding decides, so a gzip-encoded
+// object is queried by its content rather than its compressed bytes.
+func compressionType(req *SelectRequest) (string, error) {
+	if ct := strings.ToUpper(strings.TrimSpace(req.InputSerialization.CompressionType)); ct != "" {
+		switch ct {
+		case CompressionNone, CompressionGZIP, CompressionBZIP2:
+			return ct, nil
+		}
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedCompression, req.InputSerialization.CompressionType)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(req.ContentEncoding)) {
+	case "", "identity":
+		return CompressionNone, nil
+	case "gzip", "x-gzip":
+		return CompressionGZIP, nil
+	case "bzip2":
+		return CompressionBZIP2, nil
+	}
+	return "", fmt.Errorf("%w: content encoding %s", ErrUnsupportedCompression, req.ContentEncoding)
+}
+
+// decompress wraps data in a reader for the request's compression type
+func decompress(req *SelectRequest, data io.Reader) (io.Reader, error) {
+	ct, err := compressionType(req)
+	if err != nil {
+		return nil, err
+	}
+	switch ct {
+	case CompressionGZIP:
+		gr, err := gzip.NewReader(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return gr, nil
+	case CompressionBZIP2:
+		return bzip2.NewReader(data), nil
+	}
+	return data, nil
+}
+
 // GetStats returns current statistics
 func (s *SelectService) GetStats() SelectStats {
 	return SelectStats{}
@@ -0,0 +1,741 @@
+package s3select
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidExpression is returned for a Select expression that cannot be
+// parsed
+var ErrInvalidExpression = errors.New("invalid SQL expression")
+
+// keywords cannot be used as bare column names or table aliases; quote them
+// to refer to a field of that name
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "ESCAPE": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits a SQL expression into tokens
+func tokenize(sql string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			// A doubled quote inside a quoted string stands for the quote itself
+			var sb strings.Builder
+			j, closed := i+1, false
+			for j < len(sql) {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						sb.WriteByte(c)
+						j += 2
+						continue
+					}
+					closed = true
+					break
+				}
+				sb.WriteByte(sql[j])
+				j++
+			}
+			if !closed {
+				return nil, fmt.Errorf("%w: unterminated quote at position %d", ErrInvalidExpression, i)
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokQuotedIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: sb.String(), pos: i})
+			i = j + 1
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			j := i
+			for j < len(sql) && (isDigit(sql[j]) || sql[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: sql[i:j], pos: i})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(sql) && (isIdentStart(sql[j]) || isDigit(sql[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: sql[i:j], pos: i})
+			i = j
+		default:
+			if i+1 < len(sql) {
+				switch op := sql[i : i+2]; op {
+				case "<=", ">=", "<>", "!=":
+					tokens = append(tokens, token{kind: tokSymbol, text: op, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("*,.()=<>-", rune(c)) {
+				return nil, fmt.Errorf("%w: unexpected character %q at position %d", ErrInvalidExpression, c, i)
+			}
+			tokens = append(tokens, token{kind: tokSymbol, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(sql)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// sqlParser is a recursive descent parser over a token stream
+type sqlParser struct {
+	tokens []token
+	pos    int
+	alias  string
+}
+
+func (p *sqlParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *sqlParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *sqlParser) isKeyword(tok token, kw string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, kw)
+}
+
+// keyword consumes the next token if it is the keyword kw
+func (p *sqlParser) keyword(kw string) bool {
+	if p.isKeyword(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is the symbol s
+func (p *sqlParser) symbol(s string) bool {
+	if tok := p.peek(); tok.kind == tokSymbol && tok.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidExpression, fmt.Sprintf(format, args...))
+}
+
+func (p *sqlParser) unexpected() error {
+	tok := p.peek()
+	if tok.kind == tokEOF {
+		return p.errorf("unexpected end of expression")
+	}
+	return p.errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// parseSelect parses SELECT <columns> FROM S3Object [[AS] alias]
+// [WHERE <predicate>] [LIMIT n]. The FROM clause is read first so that
+// column references in the select list can be resolved against the alias.
+func (p *sqlParser) parseSelect() (*AST, error) {
+	if !p.keyword("SELECT") {
+		return nil, p.errorf("must start with SELECT")
+	}
+	start := p.pos
+	for p.peek().kind != tokEOF && !p.isKeyword(p.peek(), "FROM") {
+		p.pos++
+	}
+	if !p.keyword("FROM") {
+		return nil, p.errorf("missing FROM clause")
+	}
+	if err := p.parseFrom(); err != nil {
+		return nil, err
+	}
+	end := p.pos
+
+	p.pos = start
+	columns, err := p.parseColumns()
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("FROM") {
+		return nil, p.unexpected()
+	}
+	p.pos = end
+
+	ast := &AST{Columns: columns, Alias: p.alias}
+	if p.keyword("WHERE") {
+		if ast.Where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("LIMIT") {
+		tok := p.next()
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if tok.kind != tokNumber || err != nil || n < 0 {
+			return nil, p.errorf("invalid LIMIT %q", tok.text)
+		}
+		ast.Limit = n
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return ast, nil
+}
+
+// parseFrom parses the table and optional alias after FROM
+func (p *sqlParser) parseFrom() error {
+	if tok := p.next(); !p.isKeyword(tok, "S3Object") {
+		return p.errorf("can only select FROM S3Object, not %q", tok.text)
+	}
+	explicit := p.keyword("AS")
+	switch tok := p.peek(); {
+	case tok.kind == tokQuotedIdent, tok.kind == tokIdent && !keywords[strings.ToUpper(tok.text)]:
+		p.alias = p.next().text
+	case explicit:
+		return p.unexpected()
+	}
+	return nil
+}
+
+// parseColumns parses the select list. A nil result selects every field.
+func (p *sqlParser) parseColumns() ([]*Column, error) {
+	var columns []*Column
+	for {
+		col, err := p.parseColumnRef()
+		if err != nil {
+			return nil, err
+		}
+		if p.keyword("AS") {
+			tok := p.next()
+			if tok.kind != tokIdent && tok.kind != tokQuotedIdent {
+				return nil, p.errorf("expected a name after AS at position %d", tok.pos)
+			}
+			col.Alias = tok.text
+		}
+		columns = append(columns, col)
+		if !p.symbol(",") {
+			break
+		}
+	}
+	for _, col := range columns {
+		if col.star() {
+			if len(columns) > 1 {
+				return nil, p.errorf("* cannot be combined with other columns")
+			}
+			return nil, nil
+		}
+	}
+	return columns, nil
+}
+
+// parseColumnRef parses a column reference: [alias.]name[.name...], a
+// position _N, or *
+func (p *sqlParser) parseColumnRef() (*Column, error) {
+	var path []string
+	for {
+		switch tok := p.peek(); {
+		case tok.kind == tokSymbol && tok.text == "*":
+			path = append(path, "*")
+		case tok.kind == tokQuotedIdent:
+			path = append(path, tok.text)
+		case tok.kind == tokIdent && !keywords[strings.ToUpper(tok.text)]:
+			path = append(path, tok.text)
+		default:
+			return nil, p.unexpected()
+		}
+		p.pos++
+		if path[len(path)-1] == "*" || !p.symbol(".") {
+			break
+		}
+	}
+
+	if len(path) > 1 && (strings.EqualFold(path[0], p.alias) || strings.EqualFold(path[0], "S3Object")) {
+		path = path[1:]
+	}
+	col := &Column{Path: path}
+	if len(path) == 1 && len(path[0]) > 1 && path[0][0] == '_' {
+		if n, err := strconv.Atoi(path[0][1:]); err == nil && n > 0 {
+			col.Path, col.Position = nil, n
+		}
+	}
+	if len(path) > 1 && path[len(path)-1] == "*" {
+		return nil, p.errorf("* can only select every field of S3Object")
+	}
+	return col, nil
+}
+
+func (p *sqlParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseNot() (expr, error) {
+	if p.keyword("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e: e}, nil
+	}
+	return p.parsePredicate()
+}
+
+// parsePredicate parses a comparison, LIKE or IS NULL test, or a bare operand
+func (p *sqlParser) parsePredicate() (expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("IS") {
+		negate := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, p.unexpected()
+		}
+		return &isNullExpr{e: left, negate: negate}, nil
+	}
+
+	negate := false
+	if p.isKeyword(p.peek(), "NOT") && p.isKeyword(p.tokens[p.pos+1], "LIKE") {
+		p.pos++
+		negate = true
+	}
+	if p.keyword("LIKE") {
+		return p.parseLike(left, negate)
+	}
+
+	if tok := p.peek(); tok.kind == tokSymbol {
+		switch tok.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &compareExpr{op: tok.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parseLike parses the pattern and optional ESCAPE character of a LIKE
+func (p *sqlParser) parseLike(value expr, negate bool) (expr, error) {
+	pattern, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	like := &likeExpr{value: value, pattern: pattern, negate: negate}
+	if p.keyword("ESCAPE") {
+		tok := p.next()
+		if tok.kind != tokString || len([]rune(tok.text)) != 1 {
+			return nil, p.errorf("ESCAPE must be a single character")
+		}
+		like.escape = []rune(tok.text)[0]
+	}
+	// A literal pattern is compiled once rather than per record
+	if lit, ok := pattern.(*literalExpr); ok {
+		s, ok := lit.value.(string)
+		if !ok {
+			return nil, p.errorf("LIKE pattern must be a string")
+		}
+		like.re = compileLike(s, like.escape)
+	}
+	return like, nil
+}
+
+// parseOperand parses a literal, a column reference or a parenthesized
+// expression
+func (p *sqlParser) parseOperand() (expr, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokSymbol && tok.text == "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.unexpected()
+		}
+		return e, nil
+	case tok.kind == tokSymbol && tok.text == "-":
+		p.pos++
+		num := p.next()
+		f, err := strconv.ParseFloat(num.text, 64)
+		if num.kind != tokNumber || err != nil {
+			return nil, p.errorf("invalid number at position %d", tok.pos)
+		}
+		return &literalExpr{value: -f}, nil
+	case tok.kind == tokNumber:
+		p.pos++
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok.text)
+		}
+		return &literalExpr{value: f}, nil
+	case tok.kind == tokString:
+		p.pos++
+		return &literalExpr{value: tok.text}, nil
+	case p.isKeyword(tok, "TRUE"), p.isKeyword(tok, "FALSE"):
+		p.pos++
+		return &literalExpr{value: strings.EqualFold(tok.text, "TRUE")}, nil
+	case p.isKeyword(tok, "NULL"):
+		p.pos++
+		return &literalExpr{}, nil
+	}
+
+	col, err := p.parseColumnRef()
+	if err != nil {
+		return nil, err
+	}
+	if col.star() {
+		return nil, p.errorf("* is not a value")
+	}
+	return &columnExpr{col: col}, nil
+}
+
+// Column is a field reference in a Select expression: a field name, a path
+// into nested JSON, or a 1-based position written _1, _2, ...
+type Column struct {
+	Path     []string
+	Position int
+	// Alias is the output name given with AS
+	Alias string
+}
+
+func (c *Column) star() bool {
+	return len(c.Path) == 1 && c.Path[0] == "*"
+}
+
+// Name returns the name the column is output under
+func (c *Column) Name() string {
+	switch {
+	case c.Alias != "":
+		return c.Alias
+	case c.Position > 0:
+		return "_" + strconv.Itoa(c.Position)
+	}
+	return c.Path[len(c.Path)-1]
+}
+
+// record is one input row. Values keep the order of the input, so they can
+// be referenced by position and output in order.
+type record struct {
+	names  []string
+	values []interface{}
+}
+
+func (r *record) field(name string) (interface{}, bool) {
+	for i, n := range r.names {
+		if n == name {
+			return r.values[i], true
+		}
+	}
+	for i, n := range r.names {
+		if strings.EqualFold(n, name) {
+			return r.values[i], true
+		}
+	}
+	return nil, false
+}
+
+// lookup returns the value of a column and whether the record has it
+func (r *record) lookup(c *Column) (interface{}, bool) {
+	if c.Position > 0 {
+		if c.Position > len(r.values) {
+			return nil, false
+		}
+		return r.values[c.Position-1], true
+	}
+
+	v, ok := r.field(c.Path[0])
+	for _, name := range c.Path[1:] {
+		if !ok {
+			break
+		}
+		m, isMap := v.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		if v, ok = m[name]; !ok {
+			for k, mv := range m {
+				if strings.EqualFold(k, name) {
+					v, ok = mv, true
+					break
+				}
+			}
+		}
+	}
+	return v, ok
+}
+
+// expr is a parsed SQL expression evaluated against a record. Missing
+// fields and NULL evaluate to nil.
+type expr interface {
+	eval(r *record) interface{}
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(*record) interface{} { return e.value }
+
+type columnExpr struct {
+	col *Column
+}
+
+func (e *columnExpr) eval(r *record) interface{} {
+	v, _ := r.lookup(e.col)
+	return v
+}
+
+type logicalExpr struct {
+	or          bool
+	left, right expr
+}
+
+func (e *logicalExpr) eval(r *record) interface{} {
+	if e.or {
+		return truthy(e.left.eval(r)) || truthy(e.right.eval(r))
+	}
+	return truthy(e.left.eval(r)) && truthy(e.right.eval(r))
+}
+
+type notExpr struct {
+	e expr
+}
+
+func (e *notExpr) eval(r *record) interface{} { return !truthy(e.e.eval(r)) }
+
+type isNullExpr struct {
+	e      expr
+	negate bool
+}
+
+func (e *isNullExpr) eval(r *record) interface{} {
+	return (e.e.eval(r) == nil) != e.negate
+}
+
+// compareExpr compares two values. A comparison with NULL or between values
+// of incomparable types is false.
+type compareExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *compareExpr) eval(r *record) interface{} {
+	c, ok := compareValues(e.left.eval(r), e.right.eval(r))
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "=":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type likeExpr struct {
+	value, pattern expr
+	escape         rune
+	negate         bool
+	re             *regexp.Regexp
+}
+
+func (e *likeExpr) eval(r *record) interface{} {
+	v := e.value.eval(r)
+	if v == nil {
+		return false
+	}
+	re := e.re
+	if re == nil {
+		pattern, ok := e.pattern.eval(r).(string)
+		if !ok {
+			return false
+		}
+		re = compileLike(pattern, e.escape)
+	}
+	return re.MatchString(valueString(v)) != e.negate
+}
+
+// compileLike turns a LIKE pattern into a regular expression. % matches any
+// run of characters and _ any single character, unless preceded by escape.
+func compileLike(pattern string, escape rune) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case escape != 0 && c == escape:
+			escaped = true
+		case c == '%':
+			sb.WriteString(".*")
+		case c == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+func truthy(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+// compareValues orders a and b. Numbers and numeric strings compare
+// numerically, so CSV fields compare with number literals; other strings
+// compare lexically. It reports false when either side is NULL or the
+// values cannot be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ab == bb:
+			return 0, true
+		case !ab:
+			return -1, true
+		}
+		return 1, true
+	}
+
+	af, aNum := toNumber(a)
+	bf, bNum := toNumber(b)
+	switch {
+	case aNum && bNum:
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	case isNumber(a) || isNumber(b):
+		return 0, false
+	}
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	if !aStr || !bStr {
+		return 0, false
+	}
+	return strings.Compare(as, bs), true
+}
+
+// isNumber reports whether v is a number rather than a string holding one
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, json.Number:
+		return true
+	}
+	return false
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// valueString formats a value for CSV output and LIKE matching
+func valueString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case json.Number:
+		return s.String()
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
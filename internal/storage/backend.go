@@ -2,9 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
 )
 
+// ErrChecksumMismatch is returned while reading an object whose stored data
+// no longer matches the checksum recorded when it was written
+var ErrChecksumMismatch = errors.New("stored object data does not match its checksum")
+
 // Backend is an alias for StorageBackend
 type Backend = StorageBackend
 
@@ -41,6 +46,19 @@ type StorageBackend interface {
 	Close() error
 }
 
+// Renamer is implemented by backends that can move an object to a new
+// bucket/key without copying its data
+type Renamer interface {
+	Rename(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+}
+
+// ReadVerifier is implemented by backends that can check whole-object reads
+// against a checksum stored with the object, failing a read of corrupted
+// data with ErrChecksumMismatch
+type ReadVerifier interface {
+	VerifiesReads() bool
+}
+
 // PutResult contains the result of a Put operation
 type PutResult struct {
 	ETag         string
@@ -79,12 +97,30 @@ type GetOptions struct {
 	IfUnmodifiedSince string
 }
 
-// Range represents a byte range for partial reads
+// Range represents a byte range for partial reads, from Start up to but
+// not including End
 type Range struct {
 	Start int64
 	End   int64
 }
 
+// Resolve clamps the range to an object of the given size, so a range
+// running past the end reads up to the end. It reports false for a range
+// that starts before the object or past its end. Suffix and open-ended
+// HTTP ranges are resolved to Start and End before they reach a backend.
+func (r Range) Resolve(size int64) (Range, bool) {
+	if r.Start < 0 || r.Start > size {
+		return Range{}, false
+	}
+	if r.End > size {
+		r.End = size
+	}
+	if r.End < r.Start {
+		r.End = r.Start
+	}
+	return r, true
+}
+
 // ListOptions contains options for List operation
 type ListOptions struct {
 	Prefix    string
@@ -209,6 +209,30 @@ func TestRange(t *testing.T) {
 	}
 }
 
+func TestRange_Resolve(t *testing.T) {
+	tests := []struct {
+		rng  Range
+		size int64
+		ok   bool
+		want Range
+	}{
+		{Range{Start: 2, End: 6}, 10, true, Range{Start: 2, End: 6}},
+		{Range{Start: 5, End: 20}, 10, true, Range{Start: 5, End: 10}},
+		{Range{Start: 10, End: 12}, 10, true, Range{Start: 10, End: 10}},
+		{Range{Start: 0, End: 0}, 0, true, Range{Start: 0, End: 0}},
+		{Range{Start: 6, End: 2}, 10, true, Range{Start: 6, End: 6}},
+		{Range{Start: 11, End: 12}, 10, false, Range{}},
+		{Range{Start: -1, End: 5}, 10, false, Range{}},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.rng.Resolve(tt.size)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%+v.Resolve(%d) = %+v, %v, want %+v, %v", tt.rng, tt.size, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 func TestListOptions(t *testing.T) {
 	opts := ListOptions{
 		Prefix:    "test/",
@@ -0,0 +1,44 @@
+// Package factory opens the storage backend a configuration selects
+package factory
+
+import (
+	"fmt"
+
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/storage"
+	"github.com/openendpoint/openendpoint/internal/storage/flatfile"
+	"github.com/openendpoint/openendpoint/internal/storage/memory"
+	"github.com/openendpoint/openendpoint/internal/storage/s3proxy"
+)
+
+// New opens the storage backend named by cfg.StorageBackend: "flatfile",
+// the default, stores objects under cfg.DataDir, "memory" keeps them in
+// memory until the server stops, and "s3proxy" forwards them to the upstream
+// S3 service cfg.S3Proxy configures
+func New(cfg config.StorageConfig) (storage.StorageBackend, error) {
+	switch cfg.StorageBackend {
+	case "", "flatfile":
+		backend, err := flatfile.New(cfg.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		backend.SetVerifyReads(cfg.VerifyReads)
+		return backend, nil
+	case "memory":
+		return memory.New(), nil
+	case "s3proxy":
+		backend, err := s3proxy.New(s3proxy.Config{
+			Endpoint:     cfg.S3Proxy.Endpoint,
+			Region:       cfg.S3Proxy.Region,
+			AccessKey:    cfg.S3Proxy.AccessKey,
+			SecretKey:    cfg.S3Proxy.SecretKey,
+			UsePathStyle: cfg.S3Proxy.UsePathStyle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %q", cfg.StorageBackend)
+	}
+}
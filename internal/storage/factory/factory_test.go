@@ -0,0 +1,40 @@
+package factory
+
+import (
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/config"
+	"github.com/openendpoint/openendpoint/internal/storage/flatfile"
+	"github.com/openendpoint/openendpoint/internal/storage/memory"
+	"github.com/openendpoint/openendpoint/internal/storage/s3proxy"
+)
+
+func TestNew(t *testing.T) {
+	backend, err := New(config.StorageConfig{StorageBackend: "memory"})
+	if err != nil {
+		t.Fatalf("New(memory) error = %v", err)
+	}
+	if _, ok := backend.(*memory.Memory); !ok {
+		t.Errorf("New(memory) = %T, want *memory.Memory", backend)
+	}
+
+	backend, err = New(config.StorageConfig{StorageBackend: "flatfile", DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New(flatfile) error = %v", err)
+	}
+	if _, ok := backend.(*flatfile.FlatFile); !ok {
+		t.Errorf("New(flatfile) = %T, want *flatfile.FlatFile", backend)
+	}
+
+	backend, err = New(config.StorageConfig{StorageBackend: "s3proxy", S3Proxy: config.S3ProxyConfig{Endpoint: "http://localhost:9000"}})
+	if err != nil {
+		t.Fatalf("New(s3proxy) error = %v", err)
+	}
+	if _, ok := backend.(*s3proxy.S3Proxy); !ok {
+		t.Errorf("New(s3proxy) = %T, want *s3proxy.S3Proxy", backend)
+	}
+
+	if _, err := New(config.StorageConfig{StorageBackend: "tape"}); err == nil {
+		t.Error("New(tape) should fail")
+	}
+}
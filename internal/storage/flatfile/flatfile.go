@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
@@ -50,6 +51,10 @@ type FlatFile struct {
 	bufferPool sync.Pool
 	readCache  *cache
 	writeCache *cache
+
+	// verifyReads rehashes whole-object reads against the hash stored at
+	// write time
+	verifyReads bool
 }
 
 // cache is a simple in-memory cache for read/write optimization
@@ -153,6 +158,19 @@ func New(rootDir string) (*FlatFile, error) {
 	return ff, nil
 }
 
+// SetVerifyReads turns on checking every whole-object read against the
+// SHA-256 stored when the object was written, so data corrupted on disk
+// fails the read with storage.ErrChecksumMismatch instead of being served.
+// It costs a hash of each object read; range reads are never checked.
+func (f *FlatFile) SetVerifyReads(enabled bool) {
+	f.verifyReads = enabled
+}
+
+// VerifiesReads reports whether whole-object reads are checked
+func (f *FlatFile) VerifiesReads() bool {
+	return f.verifyReads
+}
+
 // bucketPath returns the filesystem path for a bucket
 func (f *FlatFile) bucketPath(bucket string) string {
 	// Sanitize bucket name to prevent path traversal
@@ -227,11 +245,14 @@ func (f *FlatFile) Put(ctx context.Context, bucket, key string, data io.Reader,
 		return err
 	}
 
+	// The data is copied to a temp file of its own without holding the lock,
+	// so a slow writer does not block other requests, and data read from
+	// this backend can be streamed into it. Only creating the temp file and
+	// renaming it into place are done under the lock.
 	f.mu.Lock()
-	defer f.mu.Unlock()
-
 	bucketDir := f.bucketPath(bucket)
 	if err := os.MkdirAll(bucketDir, 0755); err != nil {
+		f.mu.Unlock()
 		diskIOErrors.WithLabelValues("put_mkdir").Inc()
 		return fmt.Errorf("failed to create bucket directory: %w", err)
 	}
@@ -241,17 +262,23 @@ func (f *FlatFile) Put(ctx context.Context, bucket, key string, data io.Reader,
 	// Create parent directories
 	parentDir := filepath.Dir(objectPath)
 	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		f.mu.Unlock()
 		diskIOErrors.WithLabelValues("put_mkdir_parent").Inc()
 		return fmt.Errorf("failed to create parent directory: %w", err)
 	}
 
 	// Create temp file for atomic write
-	tmpPath := objectPath + ".tmp"
-	fh, err := os.Create(tmpPath)
+	fh, err := os.CreateTemp(parentDir, filepath.Base(objectPath)+".*.tmp")
+	f.mu.Unlock()
 	if err != nil {
 		diskIOErrors.WithLabelValues("put_create").Inc()
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
+	tmpPath := fh.Name()
+	// CreateTemp makes the file private; objects keep the mode os.Create gives
+	if err := fh.Chmod(0644); err != nil {
+		f.logger.Warnw("failed to set object file mode", "error", err)
+	}
 
 	// Copy data and calculate hash
 	hasher := sha256.New()
@@ -277,6 +304,9 @@ func (f *FlatFile) Put(ctx context.Context, bucket, key string, data io.Reader,
 		return fmt.Errorf("size mismatch: expected %d, got %d", size, written)
 	}
 
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	// Calculate and store hash for ETag
 	hash := hex.EncodeToString(hasher.Sum(nil))
 	hashPath := objectPath + ".hash"
@@ -331,27 +361,75 @@ func (f *FlatFile) Get(ctx context.Context, bucket, key string, opts storage.Get
 	}
 
 	var reader io.Reader = file
+	size := info.Size()
 
-	// Handle range requests
+	// Handle range requests: seek to the start and read no further than
+	// the end, clamped to the file
 	if opts.Range != nil {
-		reader = io.LimitReader(reader, opts.Range.End-opts.Range.Start)
-		_, err := file.Seek(opts.Range.Start, io.SeekStart)
-		if err != nil {
+		rng, ok := opts.Range.Resolve(info.Size())
+		if !ok {
+			file.Close()
+			return nil, fmt.Errorf("range start %d is outside an object of %d bytes", opts.Range.Start, info.Size())
+		}
+		if _, err := file.Seek(rng.Start, io.SeekStart); err != nil {
 			file.Close()
 			diskIOErrors.WithLabelValues("get_seek").Inc()
 			return nil, fmt.Errorf("failed to seek: %w", err)
 		}
+		size = rng.End - rng.Start
+		reader = io.LimitReader(reader, size)
+	}
+
+	// Objects written before hashes were stored have none to check against
+	if f.verifyReads && opts.Range == nil {
+		if hashData, err := os.ReadFile(objectPath + ".hash"); err == nil && len(hashData) > 0 {
+			reader = &checksumReader{
+				reader:   reader,
+				hasher:   sha256.New(),
+				expected: strings.TrimSpace(string(hashData)),
+				path:     objectPath,
+				logger:   f.logger,
+			}
+		}
 	}
 
-	bytesRead.Add(float64(info.Size()))
+	bytesRead.Add(float64(size))
 
 	return &readerWithSize{
 		Reader: reader,
-		Size:   info.Size(),
+		Size:   size,
 		Closer: file,
 	}, nil
 }
 
+// checksumReader hashes an object as it is read and fails the read at EOF
+// if the hash differs from the one stored with the object
+type checksumReader struct {
+	reader   io.Reader
+	hasher   hash.Hash
+	expected string
+	path     string
+	logger   *zap.SugaredLogger
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.hasher.Write(p[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(c.hasher.Sum(nil)); actual != c.expected {
+			diskIOErrors.WithLabelValues("get_checksum").Inc()
+			c.logger.Errorw("object data does not match its stored hash",
+				"path", c.path,
+				"expected", c.expected,
+				"actual", actual)
+			return n, storage.ErrChecksumMismatch
+		}
+	}
+	return n, err
+}
+
+// readerWithSize is an object's reader; Size is the number of bytes it
+// reads, the length of the range for a range request
 type readerWithSize struct {
 	io.Reader
 	Size int64
@@ -388,6 +466,49 @@ func (f *FlatFile) Delete(ctx context.Context, bucket, key string) error {
 	return nil
 }
 
+// Rename moves an object with a filesystem rename, so no data is copied
+func (f *FlatFile) Rename(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if err := validateKey(srcKey); err != nil {
+		return err
+	}
+	if err := validateKey(dstKey); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	srcPath := f.objectPath(srcBucket, srcKey)
+	dstPath := f.objectPath(dstBucket, dstKey)
+
+	if _, err := os.Stat(srcPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("object not found: %s/%s", srcBucket, srcKey)
+		}
+		diskIOErrors.WithLabelValues("rename_stat").Inc()
+		return fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		diskIOErrors.WithLabelValues("rename_mkdir").Inc()
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		diskIOErrors.WithLabelValues("rename").Inc()
+		return fmt.Errorf("failed to rename object: %w", err)
+	}
+
+	// Carry the hash file along; a stale one at the destination would be wrong
+	if err := os.Rename(srcPath+".hash", dstPath+".hash"); err != nil {
+		os.Remove(dstPath + ".hash")
+	}
+
+	f.cleanupEmptyDirs(filepath.Dir(srcPath))
+
+	return nil
+}
+
 func (f *FlatFile) cleanupEmptyDirs(dir string) {
 	for {
 		entries, err := os.ReadDir(dir)
@@ -475,6 +596,11 @@ func (f *FlatFile) List(ctx context.Context, bucket, prefix string, opts storage
 			return nil
 		}
 
+		// Skip the hash and temp files written alongside objects
+		if strings.HasSuffix(path, ".hash") || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
 		// Get relative path
 		relPath, err := filepath.Rel(bucketDir, path)
 		if err != nil {
@@ -520,22 +646,21 @@ func (f *FlatFile) List(ctx context.Context, bucket, prefix string, opts storage
 			LastModified: info.ModTime().Unix(),
 		})
 
-		// Check max keys
-		if opts.MaxKeys > 0 && len(objects) >= opts.MaxKeys {
-			return io.EOF
-		}
-
 		return nil
 	})
 
-	if err != nil && err != io.EOF {
+	if err != nil {
 		return nil, fmt.Errorf("failed to list objects: %w", err)
 	}
 
-	// Sort by key
+	// Sort by key. Escaped file names walk in a different order than the
+	// keys they hold, so max keys is only applied once sorted.
 	sort.Slice(objects, func(i, j int) bool {
 		return objects[i].Key < objects[j].Key
 	})
+	if opts.MaxKeys > 0 && len(objects) > opts.MaxKeys {
+		objects = objects[:opts.MaxKeys]
+	}
 
 	// Sort common prefixes
 	sort.Strings(commonPrefixes)
@@ -3,7 +3,9 @@ package flatfile
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -516,6 +518,63 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 }
 
+// lazyReader opens the object it reads from on the first Read, as a reader
+// streaming several stored objects into one does
+type lazyReader struct {
+	ff     *FlatFile
+	key    string
+	reader io.ReadCloser
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		reader, err := r.ff.Get(context.Background(), "test-bucket", r.key, storage.GetOptions{})
+		if err != nil {
+			return 0, err
+		}
+		r.reader = reader
+	}
+	return r.reader.Read(p)
+}
+
+func TestPutStreamsFromSameBackend(t *testing.T) {
+	ff, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FlatFile: %v", err)
+	}
+	ctx := context.Background()
+
+	data := []byte("source data")
+	if err := ff.Put(ctx, "test-bucket", "source", bytes.NewReader(data), int64(len(data)), storage.PutOptions{}); err != nil {
+		t.Fatalf("Put(source) error = %v", err)
+	}
+
+	// Reading from the backend while a Put into it runs must not deadlock
+	src := &lazyReader{ff: ff, key: "source"}
+	if err := ff.Put(ctx, "test-bucket", "copy", src, int64(len(data)), storage.PutOptions{}); err != nil {
+		t.Fatalf("Put(copy) error = %v", err)
+	}
+	src.reader.Close()
+
+	reader, err := ff.Get(ctx, "test-bucket", "copy", storage.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(copy) error = %v", err)
+	}
+	defer reader.Close()
+	got, _ := io.ReadAll(reader)
+	if !bytes.Equal(got, data) {
+		t.Errorf("copy = %q, want %q", got, data)
+	}
+
+	info, err := os.Stat(ff.objectPath("test-bucket", "copy"))
+	if err != nil {
+		t.Fatalf("Stat(copy) error = %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("copy mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
 func TestSanitizePathComponent(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -3090,6 +3149,93 @@ func TestGet_RangeSeek(t *testing.T) {
 	}
 }
 
+func TestGet_RangeReads(t *testing.T) {
+	ff, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FlatFile: %v", err)
+	}
+
+	ctx := context.Background()
+	data := []byte("0123456789")
+	ff.Put(ctx, "bucket", "key", bytes.NewReader(data), int64(len(data)), storage.PutOptions{})
+
+	tests := []struct {
+		name string
+		rng  storage.Range
+		want string
+	}{
+		{"middle", storage.Range{Start: 3, End: 7}, "3456"},
+		{"prefix", storage.Range{Start: 0, End: 4}, "0123"},
+		// A suffix range reaches storage resolved against the object's size
+		{"suffix", storage.Range{Start: 7, End: 10}, "789"},
+		{"past the end", storage.Range{Start: 8, End: 100}, "89"},
+	}
+	for _, tt := range tests {
+		rng := tt.rng
+		reader, err := ff.Get(ctx, "bucket", "key", storage.GetOptions{Range: &rng})
+		if err != nil {
+			t.Fatalf("%s: Get() error = %v", tt.name, err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("%s: ReadAll() error = %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: Get(%+v) = %q, want %q", tt.name, tt.rng, got, tt.want)
+		}
+		if size := reader.(*readerWithSize).Size; size != int64(len(tt.want)) {
+			t.Errorf("%s: reader Size = %d, want %d", tt.name, size, len(tt.want))
+		}
+	}
+
+	if _, err := ff.Get(ctx, "bucket", "key", storage.GetOptions{Range: &storage.Range{Start: 11, End: 12}}); err == nil {
+		t.Error("Get() with a range starting past the end should fail")
+	}
+}
+
+func TestGet_VerifyReads(t *testing.T) {
+	ff, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FlatFile: %v", err)
+	}
+	ff.SetVerifyReads(true)
+
+	ctx := context.Background()
+	data := []byte("0123456789")
+	ff.Put(ctx, "bucket", "key", bytes.NewReader(data), int64(len(data)), storage.PutOptions{})
+
+	read := func(opts storage.GetOptions) ([]byte, error) {
+		reader, err := ff.Get(ctx, "bucket", "key", opts)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+
+	if got, err := read(storage.GetOptions{}); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("intact object: Get() = %q, %v", got, err)
+	}
+
+	// Flip a byte on disk behind the backend's back
+	if err := os.WriteFile(ff.objectPath("bucket", "key"), []byte("0123456780"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := read(storage.GetOptions{}); !errors.Is(err, storage.ErrChecksumMismatch) {
+		t.Errorf("corrupted object: read error = %v, want ErrChecksumMismatch", err)
+	}
+	// A range covers only part of the object, so it is not checked
+	if got, err := read(storage.GetOptions{Range: &storage.Range{Start: 0, End: 3}}); err != nil || string(got) != "012" {
+		t.Errorf("range of a corrupted object: Get() = %q, %v", got, err)
+	}
+
+	ff.SetVerifyReads(false)
+	if _, err := read(storage.GetOptions{}); err != nil {
+		t.Errorf("corrupted object without verification: read error = %v", err)
+	}
+}
+
 func TestPut_SizeMismatchZero(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "flatfile-test-*")
 	if err != nil {
@@ -3463,3 +3609,44 @@ func TestList_WalkNonEOFError(t *testing.T) {
 		t.Logf("List walk non-EOF error: %v", err)
 	}
 }
+
+func TestRename(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flatfile-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ff, err := New(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create FlatFile: %v", err)
+	}
+
+	ctx := context.Background()
+	data := []byte("rename me")
+	if err := ff.Put(ctx, "bucket", "dir/old.txt", bytes.NewReader(data), int64(len(data)), storage.PutOptions{}); err != nil {
+		t.Fatalf("Failed to put object: %v", err)
+	}
+
+	if err := ff.Rename(ctx, "bucket", "dir/old.txt", "other", "new.txt"); err != nil {
+		t.Fatalf("Rename() error: %v", err)
+	}
+
+	if _, err := ff.Head(ctx, "bucket", "dir/old.txt"); err == nil {
+		t.Error("source should not exist after rename")
+	}
+	reader, err := ff.Get(ctx, "other", "new.txt", storage.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() after rename error: %v", err)
+	}
+	defer reader.Close()
+	got := new(bytes.Buffer)
+	got.ReadFrom(reader)
+	if got.String() != string(data) {
+		t.Errorf("renamed content = %q, want %q", got.String(), data)
+	}
+
+	if err := ff.Rename(ctx, "bucket", "missing", "bucket", "x"); err == nil {
+		t.Error("Rename() of a missing object should fail")
+	}
+}
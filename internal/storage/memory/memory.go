@@ -0,0 +1,283 @@
+// Package memory implements a storage backend that keeps objects in memory.
+// Nothing survives a restart, which suits tests and ephemeral deployments.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openendpoint/openendpoint/internal/storage"
+)
+
+// Memory is a storage backend holding every object in memory
+type Memory struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	created int64
+	objects map[string]*object
+}
+
+type object struct {
+	data     []byte
+	etag     string
+	modified int64
+}
+
+// New creates an empty in-memory storage backend
+func New() *Memory {
+	return &Memory{buckets: make(map[string]*bucket)}
+}
+
+// Put stores an object, creating its bucket if needed, as flatfile does
+func (m *Memory) Put(ctx context.Context, bucket, key string, data io.Reader, size int64, opts storage.PutOptions) error {
+	if key == "" {
+		return fmt.Errorf("object key cannot be empty")
+	}
+
+	// The data is read without holding the lock, so a slow writer does not
+	// block other requests and data read from this backend can be streamed
+	// into it
+	var buf bytes.Buffer
+	if size > 0 {
+		buf.Grow(int(size))
+	}
+	written, err := io.Copy(&buf, data)
+	if err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	if written != size && size > 0 {
+		return fmt.Errorf("size mismatch: expected %d, got %d", size, written)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.bucketLocked(bucket)
+	b.objects[key] = &object{
+		data:     buf.Bytes(),
+		etag:     fmt.Sprintf("\"%s\"", hex.EncodeToString(sum[:])),
+		modified: time.Now().Unix(),
+	}
+	return nil
+}
+
+// bucketLocked returns a bucket, creating it if it does not exist. m.mu
+// must be held for writing.
+func (m *Memory) bucketLocked(name string) *bucket {
+	b, ok := m.buckets[name]
+	if !ok {
+		b = &bucket{created: time.Now().Unix(), objects: make(map[string]*object)}
+		m.buckets[name] = b
+	}
+	return b
+}
+
+// lookup returns an object, or an error if it does not exist. m.mu must be
+// held.
+func (m *Memory) lookup(bucket, key string) (*object, error) {
+	if b, ok := m.buckets[bucket]; ok {
+		if obj, ok := b.objects[key]; ok {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+}
+
+// Get returns an object's data, or the byte range opts.Range selects. Stored
+// data is never modified in place, so the reader needs no lock.
+func (m *Memory) Get(ctx context.Context, bucket, key string, opts storage.GetOptions) (io.ReadCloser, error) {
+	m.mu.RLock()
+	obj, err := m.lookup(bucket, key)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	data := obj.data
+	if opts.Range != nil {
+		rng, ok := opts.Range.Resolve(int64(len(data)))
+		if !ok {
+			return nil, fmt.Errorf("range start %d is outside an object of %d bytes", opts.Range.Start, len(data))
+		}
+		data = data[rng.Start:rng.End]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete removes an object; deleting a missing object is not an error
+func (m *Memory) Delete(ctx context.Context, bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.buckets[bucket]; ok {
+		delete(b.objects, key)
+	}
+	return nil
+}
+
+// Rename moves an object without copying its data
+func (m *Memory) Rename(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if dstKey == "" {
+		return fmt.Errorf("object key cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, err := m.lookup(srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	delete(m.buckets[srcBucket].objects, srcKey)
+	m.bucketLocked(dstBucket).objects[dstKey] = obj
+	return nil
+}
+
+// Head returns an object's size, ETag and modification time
+func (m *Memory) Head(ctx context.Context, bucket, key string) (*storage.ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, err := m.lookup(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.ObjectInfo{
+		Key:          key,
+		Size:         int64(len(obj.data)),
+		ETag:         obj.etag,
+		LastModified: obj.modified,
+	}, nil
+}
+
+// List lists a bucket's objects in key order, grouping keys by delimiter
+// into common prefixes as flatfile does
+func (m *Memory) List(ctx context.Context, bucket, prefix string, opts storage.ListOptions) (*storage.ListResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, ok := m.buckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("bucket not found: %s", bucket)
+	}
+
+	var objects []storage.ObjectInfo
+	var commonPrefixes []string
+	commonPrefixSet := make(map[string]bool)
+	for key, obj := range b.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if opts.Marker != "" && key <= opts.Marker {
+			continue
+		}
+		if opts.Delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], opts.Delimiter); idx >= 0 {
+				folder := key[:len(prefix)+idx+len(opts.Delimiter)]
+				if !commonPrefixSet[folder] {
+					commonPrefixSet[folder] = true
+					commonPrefixes = append(commonPrefixes, folder)
+				}
+				continue
+			}
+		}
+		objects = append(objects, storage.ObjectInfo{
+			Key:          key,
+			Size:         int64(len(obj.data)),
+			ETag:         obj.etag,
+			LastModified: obj.modified,
+		})
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	if opts.MaxKeys > 0 && len(objects) > opts.MaxKeys {
+		objects = objects[:opts.MaxKeys]
+	}
+	sort.Strings(commonPrefixes)
+
+	return &storage.ListResult{
+		Objects:        objects,
+		CommonPrefixes: commonPrefixes,
+	}, nil
+}
+
+// CreateBucket creates a bucket; creating an existing one is not an error
+func (m *Memory) CreateBucket(ctx context.Context, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bucketLocked(bucket)
+	return nil
+}
+
+// DeleteBucket deletes an empty bucket
+func (m *Memory) DeleteBucket(ctx context.Context, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[bucket]
+	if !ok {
+		return fmt.Errorf("bucket not found: %s", bucket)
+	}
+	if len(b.objects) > 0 {
+		return fmt.Errorf("bucket not empty: %s", bucket)
+	}
+	delete(m.buckets, bucket)
+	return nil
+}
+
+// ListBuckets lists every bucket by name
+func (m *Memory) ListBuckets(ctx context.Context) ([]storage.BucketInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	buckets := make([]storage.BucketInfo, 0, len(m.buckets))
+	for name, b := range m.buckets {
+		buckets = append(buckets, storage.BucketInfo{
+			Name:         name,
+			CreationDate: b.created,
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Name < buckets[j].Name
+	})
+	return buckets, nil
+}
+
+// ComputeStorageMetrics computes total storage size and object count
+func (m *Memory) ComputeStorageMetrics() (int64, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var totalBytes, totalObjects int64
+	for _, b := range m.buckets {
+		for _, obj := range b.objects {
+			totalBytes += int64(len(obj.data))
+			totalObjects++
+		}
+	}
+	return totalBytes, totalObjects, nil
+}
+
+// Close releases every stored object
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buckets = make(map[string]*bucket)
+	return nil
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/storage"
+)
+
+var _ storage.StorageBackend = (*Memory)(nil)
+var _ storage.Renamer = (*Memory)(nil)
+
+func TestPutGetHeadDelete(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	if err := m.Put(ctx, "bucket", "dir/key", strings.NewReader("hello world"), 11, storage.PutOptions{}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	r, err := m.Get(ctx, "bucket", "dir/key", storage.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello world" {
+		t.Errorf("Get() = %q, want %q", data, "hello world")
+	}
+
+	info, err := m.Head(ctx, "bucket", "dir/key")
+	if err != nil {
+		t.Fatalf("Head() error = %v", err)
+	}
+	if info.Size != 11 || info.ETag == "" || info.LastModified == 0 {
+		t.Errorf("Head() = %+v", info)
+	}
+
+	if err := m.Delete(ctx, "bucket", "dir/key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := m.Get(ctx, "bucket", "dir/key", storage.GetOptions{}); err == nil {
+		t.Error("Get() after Delete() should fail")
+	}
+	if err := m.Delete(ctx, "bucket", "dir/key"); err != nil {
+		t.Errorf("Delete() of a missing object error = %v", err)
+	}
+}
+
+func TestPutSizeMismatch(t *testing.T) {
+	m := New()
+	if err := m.Put(context.Background(), "bucket", "key", strings.NewReader("short"), 10, storage.PutOptions{}); err == nil {
+		t.Error("Put() with a size mismatch should fail")
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	m.Put(ctx, "bucket", "key", strings.NewReader("0123456789"), 10, storage.PutOptions{})
+
+	tests := []struct {
+		start, end int64
+		want       string
+	}{
+		{0, 1, "0"},
+		{3, 7, "3456"},
+		{8, 20, "89"},
+		{10, 10, ""},
+	}
+	for _, tt := range tests {
+		r, err := m.Get(ctx, "bucket", "key", storage.GetOptions{Range: &storage.Range{Start: tt.start, End: tt.end}})
+		if err != nil {
+			t.Fatalf("Get([%d, %d)) error = %v", tt.start, tt.end, err)
+		}
+		data, _ := io.ReadAll(r)
+		if string(data) != tt.want {
+			t.Errorf("Get([%d, %d)) = %q, want %q", tt.start, tt.end, data, tt.want)
+		}
+	}
+
+	if _, err := m.Get(ctx, "bucket", "key", storage.GetOptions{Range: &storage.Range{Start: 11, End: 12}}); err == nil {
+		t.Error("Get() with a range past the end should fail")
+	}
+}
+
+func TestList(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	for _, key := range []string{"b.txt", "a.txt", "dir/one", "dir/two", "other/x"} {
+		m.Put(ctx, "bucket", key, bytes.NewReader([]byte(key)), int64(len(key)), storage.PutOptions{})
+	}
+
+	result, err := m.List(ctx, "bucket", "", storage.ListOptions{Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(result.Objects) != 2 || result.Objects[0].Key != "a.txt" || result.Objects[1].Key != "b.txt" {
+		t.Errorf("List() objects = %+v, want a.txt and b.txt", result.Objects)
+	}
+	if len(result.CommonPrefixes) != 2 || result.CommonPrefixes[0] != "dir/" || result.CommonPrefixes[1] != "other/" {
+		t.Errorf("List() common prefixes = %v, want [dir/ other/]", result.CommonPrefixes)
+	}
+
+	result, err = m.List(ctx, "bucket", "dir/", storage.ListOptions{Marker: "dir/one", MaxKeys: 5})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Key != "dir/two" {
+		t.Errorf("List(dir/ after dir/one) = %+v, want dir/two", result.Objects)
+	}
+
+	if _, err := m.List(ctx, "missing", "", storage.ListOptions{}); err == nil {
+		t.Error("List() of a missing bucket should fail")
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	m.CreateBucket(ctx, "b")
+	m.CreateBucket(ctx, "a")
+	buckets, err := m.ListBuckets(ctx)
+	if err != nil {
+		t.Fatalf("ListBuckets() error = %v", err)
+	}
+	if len(buckets) != 2 || buckets[0].Name != "a" || buckets[1].Name != "b" {
+		t.Errorf("ListBuckets() = %+v, want a and b", buckets)
+	}
+
+	m.Put(ctx, "a", "key", strings.NewReader("data"), 4, storage.PutOptions{})
+	if err := m.DeleteBucket(ctx, "a"); err == nil {
+		t.Error("DeleteBucket() of a non-empty bucket should fail")
+	}
+	if err := m.DeleteBucket(ctx, "b"); err != nil {
+		t.Errorf("DeleteBucket() error = %v", err)
+	}
+
+	size, count, err := m.ComputeStorageMetrics()
+	if err != nil || size != 4 || count != 1 {
+		t.Errorf("ComputeStorageMetrics() = %d, %d, %v, want 4, 1", size, count, err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	m.Put(ctx, "bucket", "old", strings.NewReader("data"), 4, storage.PutOptions{})
+
+	if err := m.Rename(ctx, "bucket", "old", "other", "new"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if _, err := m.Head(ctx, "bucket", "old"); err == nil {
+		t.Error("source still exists after Rename()")
+	}
+	if info, err := m.Head(ctx, "other", "new"); err != nil || info.Size != 4 {
+		t.Errorf("Head() of the renamed object = %+v, %v", info, err)
+	}
+	if err := m.Rename(ctx, "bucket", "missing", "bucket", "x"); err == nil {
+		t.Error("Rename() of a missing object should fail")
+	}
+}
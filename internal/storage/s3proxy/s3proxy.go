@@ -0,0 +1,300 @@
+// Package s3proxy implements a storage backend that forwards object data to
+// an upstream S3-compatible service, such as AWS S3 or MinIO, so OpenEndpoint
+// can run as a gateway in front of it. Buckets map one to one onto upstream
+// buckets of the same name; object metadata stays in the local metadata store.
+package s3proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/openendpoint/openendpoint/internal/storage"
+)
+
+// DefaultRegion is the upstream region used when none is configured
+const DefaultRegion = "us-east-1"
+
+// Config configures the upstream service
+type Config struct {
+	// Endpoint is the upstream base URL; empty uses AWS S3 for Region
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// UsePathStyle addresses buckets as endpoint/bucket instead of
+	// bucket.endpoint, which MinIO and most self-hosted services need
+	UsePathStyle bool
+}
+
+// S3Proxy is a storage backend backed by an upstream S3 service
+type S3Proxy struct {
+	client *s3.Client
+	region string
+}
+
+// New creates a backend forwarding to the upstream cfg describes
+func New(cfg Config) (*S3Proxy, error) {
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+	if (cfg.AccessKey == "") != (cfg.SecretKey == "") {
+		return nil, fmt.Errorf("s3proxy access key and secret key must be set together")
+	}
+
+	awsCfg := aws.Config{Region: cfg.Region}
+	if cfg.AccessKey != "" {
+		awsCfg.Credentials = credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &S3Proxy{client: client, region: cfg.Region}, nil
+}
+
+// Put streams an object to the upstream. The payload is sent unsigned so
+// the body need not be buffered or seekable to compute its hash.
+func (p *S3Proxy) Put(ctx context.Context, bucket, key string, data io.Reader, size int64, opts storage.PutOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   data,
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+
+	if _, err := p.client.PutObject(ctx, input, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)); err != nil {
+		return fmt.Errorf("failed to put object upstream: %w", err)
+	}
+	return nil
+}
+
+// Get returns the upstream object's body, which is streamed rather than
+// read into memory. opts.Range is sent as a Range header.
+func (p *S3Proxy) Get(ctx context.Context, bucket, key string, opts storage.GetOptions) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if rng := opts.Range; rng != nil {
+		// An empty range has no Range header form
+		if rng.End <= rng.Start {
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		input.Range = aws.String(rangeHeader(rng))
+	}
+
+	out, err := p.client.GetObject(ctx, input)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		}
+		return nil, fmt.Errorf("failed to get object upstream: %w", err)
+	}
+	return out.Body, nil
+}
+
+// rangeHeader renders a range, whose End is exclusive, as a Range header
+func rangeHeader(rng *storage.Range) string {
+	return fmt.Sprintf("bytes=%d-%d", rng.Start, rng.End-1)
+}
+
+// Delete removes an object; like S3, deleting a missing object succeeds
+func (p *S3Proxy) Delete(ctx context.Context, bucket, key string) error {
+	_, err := p.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object upstream: %w", err)
+	}
+	return nil
+}
+
+// Head returns the upstream object's size, ETag and metadata
+func (p *S3Proxy) Head(ctx context.Context, bucket, key string) (*storage.ObjectInfo, error) {
+	out, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("object not found: %s/%s", bucket, key)
+		}
+		return nil, fmt.Errorf("failed to head object upstream: %w", err)
+	}
+
+	info := &storage.ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(out.ContentLength),
+		ETag:         aws.ToString(out.ETag),
+		ContentType:  aws.ToString(out.ContentType),
+		Metadata:     out.Metadata,
+		StorageClass: string(out.StorageClass),
+		VersionID:    aws.ToString(out.VersionId),
+	}
+	if out.LastModified != nil {
+		info.LastModified = out.LastModified.Unix()
+	}
+	return info, nil
+}
+
+// List lists a bucket's objects, reading upstream pages until opts.MaxKeys
+// objects were listed or the listing ends
+func (p *S3Proxy) List(ctx context.Context, bucket, prefix string, opts storage.ListOptions) (*storage.ListResult, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.Marker != "" {
+		input.StartAfter = aws.String(opts.Marker)
+	}
+
+	result := &storage.ListResult{}
+	paginator := s3.NewListObjectsV2Paginator(p.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var noBucket *types.NoSuchBucket
+			if errors.As(err, &noBucket) {
+				return nil, fmt.Errorf("bucket not found: %s", bucket)
+			}
+			return nil, fmt.Errorf("failed to list objects upstream: %w", err)
+		}
+		for _, obj := range page.Contents {
+			info := storage.ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         aws.ToString(obj.ETag),
+				StorageClass: string(obj.StorageClass),
+			}
+			if obj.LastModified != nil {
+				info.LastModified = obj.LastModified.Unix()
+			}
+			result.Objects = append(result.Objects, info)
+		}
+		for _, cp := range page.CommonPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, aws.ToString(cp.Prefix))
+		}
+		if opts.MaxKeys > 0 && len(result.Objects) >= opts.MaxKeys {
+			result.Objects = result.Objects[:opts.MaxKeys]
+			break
+		}
+	}
+
+	sort.Strings(result.CommonPrefixes)
+	return result, nil
+}
+
+// CreateBucket creates the upstream bucket; one that already exists and is
+// owned by these credentials is not an error
+func (p *S3Proxy) CreateBucket(ctx context.Context, bucket string) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	if p.region != DefaultRegion {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(p.region),
+		}
+	}
+
+	if _, err := p.client.CreateBucket(ctx, input); err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &owned) {
+			return nil
+		}
+		return fmt.Errorf("failed to create bucket upstream: %w", err)
+	}
+	return nil
+}
+
+// DeleteBucket deletes the upstream bucket, which must be empty
+func (p *S3Proxy) DeleteBucket(ctx context.Context, bucket string) error {
+	if _, err := p.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		return fmt.Errorf("failed to delete bucket upstream: %w", err)
+	}
+	return nil
+}
+
+// ListBuckets lists the upstream buckets by name
+func (p *S3Proxy) ListBuckets(ctx context.Context) ([]storage.BucketInfo, error) {
+	out, err := p.client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets upstream: %w", err)
+	}
+
+	buckets := make([]storage.BucketInfo, 0, len(out.Buckets))
+	for _, b := range out.Buckets {
+		info := storage.BucketInfo{Name: aws.ToString(b.Name)}
+		if b.CreationDate != nil {
+			info.CreationDate = b.CreationDate.Unix()
+		}
+		buckets = append(buckets, info)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Name < buckets[j].Name
+	})
+	return buckets, nil
+}
+
+// ComputeStorageMetrics computes total storage size and object count. It
+// lists every upstream bucket in full, so it is as slow as the upstream.
+func (p *S3Proxy) ComputeStorageMetrics() (int64, int64, error) {
+	ctx := context.Background()
+	buckets, err := p.ListBuckets(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var totalBytes, totalObjects int64
+	for _, b := range buckets {
+		result, err := p.List(ctx, b.Name, "", storage.ListOptions{})
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, obj := range result.Objects {
+			totalBytes += obj.Size
+			totalObjects++
+		}
+	}
+	return totalBytes, totalObjects, nil
+}
+
+// Close releases nothing; the upstream client holds no resources of its own
+func (p *S3Proxy) Close() error {
+	return nil
+}
+
+// isNotFound reports whether err is the upstream's missing object error.
+// HEAD responses have no body, so they only report NotFound.
+func isNotFound(err error) bool {
+	var noKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.As(err, &noKey) || errors.As(err, &notFound)
+}
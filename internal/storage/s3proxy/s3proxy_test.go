@@ -0,0 +1,183 @@
+package s3proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openendpoint/openendpoint/internal/storage"
+)
+
+var _ storage.StorageBackend = (*S3Proxy)(nil)
+
+// fakeUpstream is a minimal path-style S3 service holding objects in memory
+type fakeUpstream struct {
+	mu        sync.Mutex
+	objects   map[string]string
+	lastRange string
+}
+
+func (f *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[bucket+"/"+key] = string(data)
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet && bucket == "":
+		fmt.Fprint(w, `<ListAllMyBucketsResult><Buckets><Bucket><Name>bucket</Name></Bucket></Buckets></ListAllMyBucketsResult>`)
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, bucket, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+			}
+			return
+		}
+		f.lastRange = r.Header.Get("Range")
+		if spec, ok := strings.CutPrefix(f.lastRange, "bytes="); ok {
+			first, last, _ := strings.Cut(spec, "-")
+			start, _ := strconv.Atoi(first)
+			end, _ := strconv.Atoi(last)
+			data = data[start : end+1]
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Method == http.MethodGet {
+			io.WriteString(w, data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeUpstream) list(w http.ResponseWriter, bucket, prefix string) {
+	var keys []string
+	for name := range f.objects {
+		if b, key, _ := strings.Cut(name, "/"); b == bucket && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><IsTruncated>false</IsTruncated><KeyCount>%d</KeyCount>`, bucket, len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><ETag>"etag"</ETag></Contents>`, key, len(f.objects[bucket+"/"+key]))
+	}
+	fmt.Fprint(w, `</ListBucketResult>`)
+}
+
+func newTestProxy(t *testing.T) (*S3Proxy, *fakeUpstream) {
+	t.Helper()
+	upstream := &fakeUpstream{objects: make(map[string]string)}
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+
+	p, err := New(Config{
+		Endpoint:     server.URL,
+		AccessKey:    "access",
+		SecretKey:    "secret",
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return p, upstream
+}
+
+func TestPutGetHeadDelete(t *testing.T) {
+	p, _ := newTestProxy(t)
+	ctx := context.Background()
+
+	// The body is not seekable, so it must be streamed rather than hashed
+	body := io.MultiReader(strings.NewReader("hello "), strings.NewReader("world"))
+	if err := p.Put(ctx, "bucket", "dir/key", body, 11, storage.PutOptions{}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	r, err := p.Get(ctx, "bucket", "dir/key", storage.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello world" {
+		t.Errorf("Get() = %q, want %q", data, "hello world")
+	}
+
+	info, err := p.Head(ctx, "bucket", "dir/key")
+	if err != nil {
+		t.Fatalf("Head() error = %v", err)
+	}
+	if info.Size != 11 || info.ETag != `"etag"` || info.LastModified == 0 {
+		t.Errorf("Head() = %+v", info)
+	}
+
+	if err := p.Delete(ctx, "bucket", "dir/key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := p.Get(ctx, "bucket", "dir/key", storage.GetOptions{}); err == nil || !strings.Contains(err.Error(), "object not found") {
+		t.Errorf("Get() after Delete() error = %v, want object not found", err)
+	}
+	if _, err := p.Head(ctx, "bucket", "dir/key"); err == nil || !strings.Contains(err.Error(), "object not found") {
+		t.Errorf("Head() after Delete() error = %v, want object not found", err)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	p, upstream := newTestProxy(t)
+	ctx := context.Background()
+	p.Put(ctx, "bucket", "key", strings.NewReader("0123456789"), 10, storage.PutOptions{})
+
+	r, err := p.Get(ctx, "bucket", "key", storage.GetOptions{Range: &storage.Range{Start: 3, End: 7}})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "3456" || upstream.lastRange != "bytes=3-6" {
+		t.Errorf("Get([3, 7)) = %q with Range %q, want %q with bytes=3-6", data, upstream.lastRange, "3456")
+	}
+}
+
+func TestList(t *testing.T) {
+	p, _ := newTestProxy(t)
+	ctx := context.Background()
+	for _, key := range []string{"a/1", "a/2", "b/1"} {
+		p.Put(ctx, "bucket", key, strings.NewReader(key), int64(len(key)), storage.PutOptions{})
+	}
+
+	result, err := p.List(ctx, "bucket", "a/", storage.ListOptions{MaxKeys: 1})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Key != "a/1" || result.Objects[0].Size != 3 {
+		t.Errorf("List(a/, max 1) = %+v, want a/1", result.Objects)
+	}
+
+	size, count, err := p.ComputeStorageMetrics()
+	if err != nil || size != 9 || count != 3 {
+		t.Errorf("ComputeStorageMetrics() = %d, %d, %v, want 9, 3", size, count, err)
+	}
+}
+
+func TestNew_PartialCredentials(t *testing.T) {
+	if _, err := New(Config{AccessKey: "access"}); err == nil {
+		t.Error("New() with an access key but no secret key should fail")
+	}
+}
@@ -3,6 +3,7 @@ package tags
 import (
 	"encoding/xml"
 	"fmt"
+	"unicode/utf8"
 )
 
 // TagSet represents a set of tags
@@ -123,10 +124,10 @@ func (v *TagValidator) Validate(tags TagSet) error {
 		if len(tag.Key) == 0 {
 			return fmt.Errorf("tag key cannot be empty")
 		}
-		if len(tag.Key) > v.maxKeyLength {
+		if utf8.RuneCountInString(tag.Key) > v.maxKeyLength {
 			return fmt.Errorf("tag key too long: maximum %d characters", v.maxKeyLength)
 		}
-		if len(tag.Value) > v.maxValueLength {
+		if utf8.RuneCountInString(tag.Value) > v.maxValueLength {
 			return fmt.Errorf("tag value too long: maximum %d characters", v.maxValueLength)
 		}
 		// Check for invalid characters
@@ -2,6 +2,7 @@ package telemetry
 
 import (
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -91,6 +92,12 @@ func buildLogger(config zap.Config) (*zap.SugaredLogger, error) {
 }
 
 func LoggingMiddleware(logger *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return SampledLoggingMiddleware(logger, nil)
+}
+
+// SampledLoggingMiddleware logs requests like LoggingMiddleware, but only
+// those the sampler keeps. Metrics are recorded for every request.
+func SampledLoggingMiddleware(logger *zap.SugaredLogger, sampler *LogSampler) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
@@ -100,13 +107,17 @@ func LoggingMiddleware(logger *zap.SugaredLogger) func(http.Handler) http.Handle
 
 			next.ServeHTTP(wrapped, r)
 
-			duration := time.Since(start).Seconds()
+			elapsed := time.Since(start)
+			duration := elapsed.Seconds()
 			path := r.URL.Path
 
 			// Update metrics
 			RequestsTotal.WithLabelValues(r.Method, path, string(rune(wrapped.statusCode))).Inc()
 			RequestDuration.WithLabelValues(r.Method, path).Observe(duration)
 
+			if !sampler.Keep(wrapped.statusCode, elapsed) {
+				return
+			}
 			logger.Infow("request completed",
 				"method", r.Method,
 				"path", path,
@@ -118,6 +129,37 @@ func LoggingMiddleware(logger *zap.SugaredLogger) func(http.Handler) http.Handle
 	}
 }
 
+// LogSampler decides which requests are written to the access log. It keeps
+// 1 in rate successful requests, and always keeps failed requests and those
+// that took at least slow.
+type LogSampler struct {
+	rate uint64
+	slow time.Duration
+	seen atomic.Uint64
+}
+
+// NewLogSampler creates a sampler keeping 1 in rate successful requests. A
+// rate of 0 or 1 keeps every request, and a slow threshold of 0 disables
+// the latency exemption.
+func NewLogSampler(rate int, slow time.Duration) *LogSampler {
+	if rate < 1 {
+		rate = 1
+	}
+	return &LogSampler{rate: uint64(rate), slow: slow}
+}
+
+// Keep reports whether a request with the given status and duration should
+// be logged. A nil sampler keeps everything.
+func (s *LogSampler) Keep(status int, duration time.Duration) bool {
+	if s == nil || s.rate == 1 || status >= http.StatusBadRequest {
+		return true
+	}
+	if s.slow > 0 && duration >= s.slow {
+		return true
+	}
+	return s.seen.Add(1)%s.rate == 1
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -1,6 +1,7 @@
 package telemetry
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -133,6 +134,23 @@ var (
 	)
 )
 
+// Vacuum metrics
+var (
+	VacuumPartsReclaimed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openendpoint_vacuum_parts_reclaimed_total",
+			Help: "Total number of orphaned multipart part files reclaimed",
+		},
+	)
+
+	VacuumBytesReclaimed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "openendpoint_vacuum_bytes_reclaimed_total",
+			Help: "Total bytes reclaimed from orphaned multipart part files",
+		},
+	)
+)
+
 // Mutex for thread-safe metric updates
 var metricsMutex sync.RWMutex
 
@@ -155,8 +173,31 @@ var (
 	opsDeleteObject float64
 	opsListObjects  float64
 	opsFailed       float64
+
+	// Per-bucket usage and request counts for dashboard
+	dashboardBuckets = make(map[string]*BucketStats)
 )
 
+// BucketStats is the usage of a bucket and the number of requests served
+// for it
+type BucketStats struct {
+	Bucket   string `json:"bucket"`
+	Objects  int64  `json:"objects"`
+	Bytes    int64  `json:"bytes"`
+	Requests int64  `json:"requests"`
+}
+
+// dashboardBucket returns the stats of a bucket, adding them if missing.
+// The caller holds metricsMutex.
+func dashboardBucket(bucket string) *BucketStats {
+	stats, ok := dashboardBuckets[bucket]
+	if !ok {
+		stats = &BucketStats{Bucket: bucket}
+		dashboardBuckets[bucket] = stats
+	}
+	return stats
+}
+
 // IncStorageBytes increments stored bytes
 func IncStorageBytes(bytes int64) {
 	StorageBytesStored.Add(float64(bytes))
@@ -228,22 +269,69 @@ func SetStorageDiskUsage(percent float64) {
 // IncBucketObjects increments object count for a bucket
 func IncBucketObjects(bucket string) {
 	BucketObjects.WithLabelValues(bucket).Inc()
+	metricsMutex.Lock()
+	dashboardBucket(bucket).Objects++
+	metricsMutex.Unlock()
 }
 
 // DecBucketObjects decrements object count for a bucket
 func DecBucketObjects(bucket string) {
 	BucketObjects.WithLabelValues(bucket).Dec()
+	metricsMutex.Lock()
+	dashboardBucket(bucket).Objects--
+	metricsMutex.Unlock()
 }
 
 // SetBucketBytes sets bytes for a bucket
 func SetBucketBytes(bucket string, bytes int64) {
 	BucketBytes.WithLabelValues(bucket).Set(float64(bytes))
+	metricsMutex.Lock()
+	dashboardBucket(bucket).Bytes = bytes
+	metricsMutex.Unlock()
+}
+
+// SetBucketUsage sets the bytes and object count stored in a bucket
+func SetBucketUsage(bucket string, bytes, objects int64) {
+	BucketBytes.WithLabelValues(bucket).Set(float64(bytes))
+	BucketObjects.WithLabelValues(bucket).Set(float64(objects))
+	metricsMutex.Lock()
+	stats := dashboardBucket(bucket)
+	stats.Bytes = bytes
+	stats.Objects = objects
+	metricsMutex.Unlock()
+}
+
+// IncBucketRequests counts a request served for a bucket. Only buckets with
+// recorded usage are counted, so requests naming buckets that do not exist
+// add no entries.
+func IncBucketRequests(bucket string) {
+	metricsMutex.Lock()
+	if stats, ok := dashboardBuckets[bucket]; ok {
+		stats.Requests++
+	}
+	metricsMutex.Unlock()
+}
+
+// GetBucketStats returns the stats of every bucket, sorted by name
+func GetBucketStats() []BucketStats {
+	metricsMutex.RLock()
+	stats := make([]BucketStats, 0, len(dashboardBuckets))
+	for _, s := range dashboardBuckets {
+		stats = append(stats, *s)
+	}
+	metricsMutex.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Bucket < stats[j].Bucket })
+	return stats
 }
 
 // DeleteBucketMetrics removes metrics for a deleted bucket
 func DeleteBucketMetrics(bucket string) {
 	BucketObjects.DeleteLabelValues(bucket)
 	BucketBytes.DeleteLabelValues(bucket)
+	metricsMutex.Lock()
+	delete(dashboardBuckets, bucket)
+	metricsMutex.Unlock()
 }
 
 // GetStorageBytes returns stored bytes for dashboard
@@ -348,6 +436,18 @@ func UpdateDashboardMetrics(uploaded, downloaded int64) {
 	metricsMutex.Unlock()
 }
 
+// AddBytesUploaded counts object bytes received from clients
+func AddBytesUploaded(bytes int64) {
+	BytesUploaded.Add(float64(bytes))
+	UpdateDashboardMetrics(bytes, 0)
+}
+
+// AddBytesDownloaded counts object bytes served to clients
+func AddBytesDownloaded(bytes int64) {
+	BytesDownloaded.Add(float64(bytes))
+	UpdateDashboardMetrics(0, bytes)
+}
+
 // UpdateLatency updates latency metrics
 func UpdateLatency(operation string, durationSeconds float64) {
 	metricsMutex.Lock()
@@ -3,10 +3,16 @@ package telemetry
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNewLoggerErrorPath(t *testing.T) {
@@ -175,6 +181,67 @@ func TestUpdateDashboardMetrics(t *testing.T) {
 	}
 }
 
+func TestAddBytesTransferred(t *testing.T) {
+	uploaded, downloaded := GetBytesUploaded(), GetBytesDownloaded()
+	uploadedTotal, downloadedTotal := counterValue(t, BytesUploaded), counterValue(t, BytesDownloaded)
+
+	AddBytesUploaded(100)
+	AddBytesDownloaded(250)
+
+	if got := GetBytesUploaded() - uploaded; got != 100 {
+		t.Errorf("dashboard bytes uploaded grew by %v, want 100", got)
+	}
+	if got := GetBytesDownloaded() - downloaded; got != 250 {
+		t.Errorf("dashboard bytes downloaded grew by %v, want 250", got)
+	}
+	if got := counterValue(t, BytesUploaded) - uploadedTotal; got != 100 {
+		t.Errorf("BytesUploaded grew by %v, want 100", got)
+	}
+	if got := counterValue(t, BytesDownloaded) - downloadedTotal; got != 250 {
+		t.Errorf("BytesDownloaded grew by %v, want 250", got)
+	}
+}
+
+// counterValue returns the current value of a counter
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("reading counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestGetBucketStats(t *testing.T) {
+	SetBucketUsage("stats-b", 300, 3)
+	SetBucketUsage("stats-a", 100, 1)
+	IncBucketRequests("stats-a")
+	IncBucketRequests("stats-a")
+	IncBucketRequests("stats-unknown")
+
+	var got []BucketStats
+	for _, stats := range GetBucketStats() {
+		if strings.HasPrefix(stats.Bucket, "stats-") {
+			got = append(got, stats)
+		}
+	}
+	want := []BucketStats{
+		{Bucket: "stats-a", Objects: 1, Bytes: 100, Requests: 2},
+		{Bucket: "stats-b", Objects: 3, Bytes: 300},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBucketStats() = %+v, want %+v", got, want)
+	}
+
+	DeleteBucketMetrics("stats-a")
+	DeleteBucketMetrics("stats-b")
+	for _, stats := range GetBucketStats() {
+		if strings.HasPrefix(stats.Bucket, "stats-") {
+			t.Errorf("deleted bucket %s still reported", stats.Bucket)
+		}
+	}
+}
+
 func TestUpdateLatency(t *testing.T) {
 	UpdateLatency("GetObject", 0.1)
 
@@ -317,6 +384,57 @@ func TestLoggingMiddlewarePost(t *testing.T) {
 	}
 }
 
+func TestSampledLoggingMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	sampler := NewLogSampler(10, time.Second)
+	middleware := SampledLoggingMiddleware(zap.New(core).Sugar(), sampler)
+
+	status := http.StatusOK
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	serve := func(n int) {
+		for i := 0; i < n; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sampled", nil))
+		}
+	}
+
+	// Successful requests are sampled 1 in 10
+	serve(100)
+	if got := logs.TakeAll(); len(got) != 10 {
+		t.Errorf("logged %d of 100 successful requests, want 10", len(got))
+	}
+
+	// Client and server errors are never sampled out
+	status = http.StatusNotFound
+	serve(20)
+	status = http.StatusInternalServerError
+	serve(20)
+	if got := logs.TakeAll(); len(got) != 40 {
+		t.Errorf("logged %d of 40 failed requests, want 40", len(got))
+	}
+}
+
+func TestLogSampler_Keep(t *testing.T) {
+	sampler := NewLogSampler(1000, 500*time.Millisecond)
+	sampler.Keep(http.StatusOK, 0) // the first request of each round is kept
+
+	if sampler.Keep(http.StatusOK, 10*time.Millisecond) {
+		t.Error("Keep() should sample out a fast successful request")
+	}
+	if !sampler.Keep(http.StatusOK, 500*time.Millisecond) {
+		t.Error("Keep() should keep a request at the slow threshold")
+	}
+	if !sampler.Keep(http.StatusServiceUnavailable, 0) {
+		t.Error("Keep() should keep failed requests")
+	}
+
+	var unsampled *LogSampler
+	if !unsampled.Keep(http.StatusOK, 0) || !NewLogSampler(0, 0).Keep(http.StatusOK, 0) {
+		t.Error("a nil sampler or a rate of 0 should keep every request")
+	}
+}
+
 func TestPrometheusMetrics(t *testing.T) {
 	if StorageBytesStored == nil {
 		t.Error("StorageBytesStored should be initialized")
@@ -358,7 +358,9 @@ func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, d
 		return err
 	}
 
-	req.Header.Set("x-amz-copy-source", fmt.Sprintf("/%s/%s", srcBucket, srcKey))
+	// The copy source is URL-encoded, so keys may hold any character
+	source := &url.URL{Path: fmt.Sprintf("/%s/%s", srcBucket, srcKey)}
+	req.Header.Set("x-amz-copy-source", source.EscapedPath())
 
 	resp, err := c.client.Do(req)
 	if err != nil {
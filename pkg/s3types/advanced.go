@@ -12,16 +12,32 @@ type BucketLifecycleConfiguration struct {
 
 // LifecycleRule represents a lifecycle rule
 type LifecycleRule struct {
-	XMLName                   xml.Name                    `xml:"Rule"`
-	ID                        string                      `xml:"ID"`
-	Prefix                   string                      `xml:"Prefix"`
-	Status                   string                      `xml:"Status"`
-	Transitions              []Transition                `xml:"Transition,omitempty"`
-	Expiration               *Expiration                 `xml:"Expiration,omitempty"`
-	NoncurrentVersionExpiration *NoncurrentVersionExpiration `xml:"NoncurrentVersionExpiration,omitempty"`
+	XMLName                        xml.Name                        `xml:"Rule"`
+	ID                             string                          `xml:"ID"`
+	Prefix                         string                          `xml:"Prefix,omitempty"`
+	Filter                         *LifecycleFilter                `xml:"Filter,omitempty"`
+	Status                         string                          `xml:"Status"`
+	Transitions                    []Transition                    `xml:"Transition,omitempty"`
+	Expiration                     *Expiration                     `xml:"Expiration,omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty"`
+	NoncurrentVersionTransitions   []NoncurrentVersionTransition   `xml:"NoncurrentVersionTransition,omitempty"`
 	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"`
 }
 
+// LifecycleFilter selects the objects a lifecycle rule applies to by a key
+// prefix or a tag, or by several of them combined under And
+type LifecycleFilter struct {
+	Prefix string              `xml:"Prefix,omitempty"`
+	Tag    *Tag                `xml:"Tag,omitempty"`
+	And    *LifecycleFilterAnd `xml:"And,omitempty"`
+}
+
+// LifecycleFilterAnd combines a prefix and tags that an object must all match
+type LifecycleFilterAnd struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
 // Transition represents storage class transition
 type Transition struct {
 	XMLName         xml.Name `xml:"Transition"`
@@ -44,6 +60,14 @@ type NoncurrentVersionExpiration struct {
 	NoncurrentDays     int      `xml:"NoncurrentDays"`
 }
 
+// NoncurrentVersionTransition represents storage class transition of
+// noncurrent versions
+type NoncurrentVersionTransition struct {
+	XMLName        xml.Name `xml:"NoncurrentVersionTransition"`
+	NoncurrentDays int      `xml:"NoncurrentDays"`
+	StorageClass   string   `xml:"StorageClass"`
+}
+
 // AbortIncompleteMultipartUpload represents abort incomplete multipart upload
 type AbortIncompleteMultipartUpload struct {
 	XMLName           xml.Name `xml:"AbortIncompleteMultipartUpload"`
@@ -275,3 +299,16 @@ type DeleteError struct {
 	Code      string   `xml:"Code"`
 	Message   string   `xml:"Message"`
 }
+
+// RestoreRequest is the request body for RestoreObject
+type RestoreRequest struct {
+	XMLName              xml.Name              `xml:"RestoreRequest"`
+	Days                 int                   `xml:"Days"`
+	Tier                 string                `xml:"Tier,omitempty"`
+	GlacierJobParameters *GlacierJobParameters `xml:"GlacierJobParameters,omitempty"`
+}
+
+// GlacierJobParameters sets the retrieval tier of a restore
+type GlacierJobParameters struct {
+	Tier string `xml:"Tier"`
+}
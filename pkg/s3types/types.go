@@ -49,7 +49,9 @@ type ListObjectsV2Output struct {
 	IsTruncated           bool    `xml:"IsTruncated"`
 	Contents              []Object `xml:"Contents"`
 	CommonPrefixes        []string `xml:"CommonPrefixes>Prefix"`
-	NextContinuationToken string   `xml:"NextContinuationToken"`
+	ContinuationToken     string   `xml:"ContinuationToken,omitempty"`
+	StartAfter            string   `xml:"StartAfter,omitempty"`
+	NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
 }
 
 // Object represents an object
@@ -79,8 +81,10 @@ type CompleteMultipartUploadInput struct {
 
 // Part represents a part in CompleteMultipartUpload
 type Part struct {
-	PartNumber int    `xml:"PartNumber"`
-	ETag       string `xml:"ETag"`
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified,omitempty"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size,omitempty"`
 }
 
 // CompleteMultipartUploadResult is the response for CompleteMultipartUpload
@@ -91,6 +95,18 @@ type CompleteMultipartUploadResult struct {
 	Key       string `xml:"Key"`
 	ETag      string `xml:"ETag"`
 	RequestID string `xml:"RequestId"`
+	// Checksum is the object's additional checksum, if it has one
+	*Checksum
+}
+
+// PostResponse is the response for a POST object upload whose form asks for
+// success_action_status 201
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
 }
 
 // ListPartsOutput is the response for ListParts
@@ -100,8 +116,13 @@ type ListPartsOutput struct {
 	Bucket    string `xml:"Bucket"`
 	Key       string `xml:"Key"`
 	UploadID  string `xml:"UploadId"`
-	StorageClass string `xml:"StorageClass"`
+	PartNumberMarker     int    `xml:"PartNumberMarker"`
+	NextPartNumberMarker int    `xml:"NextPartNumberMarker"`
+	MaxParts             int    `xml:"MaxParts"`
 	IsTruncated bool   `xml:"IsTruncated"`
+	Initiator            *Owner `xml:"Initiator,omitempty"`
+	Owner                *Owner `xml:"Owner,omitempty"`
+	StorageClass string `xml:"StorageClass"`
 	Parts     []Part `xml:"Part"`
 }
 
@@ -140,6 +161,15 @@ type CopyObjectResult struct {
 	ServerSideEncryption string `xml:"ServerSideEncryption,omitempty"`
 }
 
+// CopyPartResult is the response for UploadPartCopy
+type CopyPartResult struct {
+	XMLName      string `xml:"CopyPartResult"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	// Checksum is the part's additional checksum, if the upload has one
+	*Checksum
+}
+
 // CreateBucketConfiguration is the request for CreateBucket
 type CreateBucketConfiguration struct {
 	XMLName      string `xml:"CreateBucketConfiguration"`
@@ -188,12 +218,12 @@ type PutBucketLifecycleInput struct {
 type GetObjectAttributesOutput struct {
 	XMLName             string             `xml:"GetObjectAttributesOutput"`
 	xmlns               string             `xml:"xmlns,attr"`
-	ETag                string             `xml:"ETag"`
-	Checksum            *Checksum          `xml:"Checksum"`
+	ETag                string             `xml:"ETag,omitempty"`
+	Checksum            *Checksum          `xml:"Checksum,omitempty"`
 	ObjectParts         *ObjectParts       `xml:"ObjectParts,omitempty"`
-	StorageClass        string             `xml:"StorageClass"`
-	LastModified        string             `xml:"LastModified"`
-	ObjectSize          string             `xml:"ObjectSize"`
+	StorageClass        string             `xml:"StorageClass,omitempty"`
+	LastModified        string             `xml:"LastModified,omitempty"`
+	ObjectSize          string             `xml:"ObjectSize,omitempty"`
 	VersionId           string             `xml:"VersionId,omitempty"`
 	RequestCharged      string             `xml:"RequestCharged,omitempty"`
 	ServerSideEncryption string           `xml:"ServerSideEncryption,omitempty"`
@@ -204,6 +234,8 @@ type Checksum struct {
 	ChecksumSHA1   string `xml:"ChecksumSHA1,omitempty"`
 	ChecksumSHA256 string `xml:"ChecksumSHA256,omitempty"`
 	ChecksumCRC32  string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumType   string `xml:"ChecksumType,omitempty"`
 }
 
 // ObjectParts represents object parts information
@@ -225,8 +257,9 @@ type SelectObjectContentRequest struct {
 
 // InputSerialization defines input serialization
 type InputSerialization struct {
-	CSV    *CSVInput  `xml:"CSV,omitempty"`
-	JSON   *JSONInput `xml:"JSON,omitempty"`
+	CSV             *CSVInput  `xml:"CSV,omitempty"`
+	JSON            *JSONInput `xml:"JSON,omitempty"`
+	CompressionType string     `xml:"CompressionType,omitempty"`
 }
 
 // CSVInput defines CSV input serialization